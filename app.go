@@ -9,8 +9,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/HazelnutParadise/idensyra/internal"
+	"github.com/HazelnutParadise/idensyra/terminal"
 	"github.com/HazelnutParadise/insyra"
 	"github.com/traefik/yaegi/interp"
 	"github.com/traefik/yaegi/stdlib"
@@ -52,11 +54,15 @@ func main() {
 type App struct {
 	ctx       context.Context
 	mcpServer *MCPServer
+	watcher   *workspaceWatcher
+	terminals *terminal.Manager
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	a := &App{}
+	a.terminals = terminal.NewManager(a.emitTerminalOutput, a.emitTerminalExit)
+	return a
 }
 
 // startup is called when the app starts. The context is saved
@@ -65,9 +71,9 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	fmt.Println("Idensyra is starting...")
 
-	// Start MCP server on port 14320 using official SDK
+	// Start MCP server with the persisted settings using official SDK
 	a.mcpServer = NewMCPServer(a)
-	if err := a.mcpServer.Start(14320); err != nil {
+	if err := a.mcpServer.Start(loadMCPSettings()); err != nil {
 		fmt.Printf("Failed to start MCP server: %v\n", err)
 	}
 
@@ -204,14 +210,6 @@ func (a *App) SaveResult(result string) error {
 	return os.WriteFile(filename, []byte(result), 0644)
 }
 
-// MCPExecutionResult is called by frontend to send back execution results for MCP requests
-func (a *App) MCPExecutionResult(requestId string, result string) error {
-	if a.mcpServer != nil {
-		a.mcpServer.deliverExecutionResult(requestId, result)
-	}
-	return nil
-}
-
 // SaveResultToWorkspace saves execution result to a file in the workspace directory
 func (a *App) SaveResultToWorkspace(result string) (string, error) {
 	if globalWorkspace == nil || !globalWorkspace.initialized {
@@ -255,11 +253,57 @@ func (a *App) OpenOfficialSite() {
 	runtime.BrowserOpenURL(a.ctx, "https://insyra.hazelnut-paradise.com")
 }
 
+// goRun holds the cancel function of the Go or Python code execution in progress
+var goRun struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// stopGoExecution interrupts the Go or Python code execution in progress, if any.
+// Yaegi stops at its next cancellation point; a Python process is killed.
+func stopGoExecution() {
+	goRun.mu.Lock()
+	cancel := goRun.cancel
+	goRun.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 // executeGoCode uses yaegi to execute dynamic Go code and capture all output
 func executeGoCode(code string, colorBG string) string {
+	code = normalizeGoRangeLoops(code)
+	return runCapturingOutput(colorBG, func(ctx context.Context, out io.Writer) error {
+		// Initialize yaegi interpreter and set Stdout and Stderr
+		i := interp.New(interp.Options{
+			Stdout: out,
+			Stderr: out,
+		})
+		i.Use(stdlib.Symbols)   // Load standard library
+		i.Use(internal.Symbols) // Load internal package
+		_, err := i.EvalWithContext(ctx, code)
+		return err
+	})
+}
+
+// runCapturingOutput calls run in the workspace directory with standard output
+// and standard error captured and returns the output as HTML. run writes its
+// own output to out; stopGoExecution cancels ctx.
+func runCapturingOutput(colorBG string, run func(ctx context.Context, out io.Writer) error) string {
 	// Prepare a bytes.Buffer to capture all output
 	var buf bytes.Buffer
 
+	runCtx, cancel := context.WithCancel(context.Background())
+	goRun.mu.Lock()
+	goRun.cancel = cancel
+	goRun.mu.Unlock()
+	defer func() {
+		goRun.mu.Lock()
+		goRun.cancel = nil
+		goRun.mu.Unlock()
+		cancel()
+	}()
+
 	// Run code with workspace as the working directory if available
 	var oldWD string
 	var restoreWD bool
@@ -280,14 +324,6 @@ func executeGoCode(code string, colorBG string) string {
 		defer os.Chdir(oldWD)
 	}
 
-	// Initialize yaegi interpreter and set Stdout and Stderr
-	i := interp.New(interp.Options{
-		Stdout: &buf,
-		Stderr: &buf,
-	})
-	i.Use(stdlib.Symbols)   // Load standard library
-	i.Use(internal.Symbols) // Load internal package
-
 	// Redirect standard output and standard error
 	oldStdout := os.Stdout
 	oldStderr := os.Stderr
@@ -306,8 +342,6 @@ func executeGoCode(code string, colorBG string) string {
 		outputChan <- outputBuf.String()
 	}()
 
-	code = normalizeGoRangeLoops(code)
-
 	execErr := func() (err error) {
 		defer func() {
 			if r := recover(); r != nil {
@@ -318,8 +352,7 @@ func executeGoCode(code string, colorBG string) string {
 				}
 			}
 		}()
-		_, err = i.Eval(code)
-		return err
+		return run(runCtx, &buf)
 	}()
 	// Restore standard output and standard error
 	w.Close()
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/constant"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/HazelnutParadise/idensyra/igonb"
+	"github.com/HazelnutParadise/idensyra/internal"
+	"github.com/HazelnutParadise/idensyra/kernel"
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// pythonMagic starts a cell that runs as Python instead of Go
+const pythonMagic = "%%python"
+
+// cellMagics maps the first line of a cell to the igonb language it runs as
+var cellMagics = map[string]string{
+	pythonMagic: "python",
+	"%%bash":    "shell",
+	"%%sh":      "shell",
+	"%%sql":     "sql",
+}
+
+// notebookBackend runs the cells of a Jupyter session as igonb cells of one
+// executor, so Go and Python cells share their variables like in a notebook
+type notebookBackend struct {
+	mu   sync.Mutex
+	exec *igonb.Executor
+}
+
+func newNotebookBackend() (*notebookBackend, error) {
+	exec, err := newExecutor()
+	if err != nil {
+		return nil, err
+	}
+	return &notebookBackend{exec: exec}, nil
+}
+
+func newExecutor() (*igonb.Executor, error) {
+	exec, err := igonb.NewExecutorWithSymbols(internal.Symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+	if err := exec.PreloadGoImports(igonb.DefaultGoImports); err != nil {
+		return nil, fmt.Errorf("failed to import default packages: %w", err)
+	}
+	// Python cells use the environment of the workspace Jupyter started the kernel in
+	if dir, err := os.Getwd(); err == nil {
+		if env := (mcp.PythonEnv{Root: dir}); env.Exists() {
+			exec.SetPythonEnv(env.Dir())
+		}
+	}
+	return exec, nil
+}
+
+func (b *notebookBackend) executor() *igonb.Executor {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exec
+}
+
+// Execute runs code as a Go cell, or as a Python, shell or sql cell after a
+// %%python, %%bash, %%sh or %%sql line; shell output is streamed as it is produced
+func (b *notebookBackend) Execute(code string, x *kernel.Execution) error {
+	cell := igonb.Cell{Language: "go", Source: code}
+	if first, rest, _ := strings.Cut(code, "\n"); cellMagics[strings.TrimSpace(first)] != "" {
+		cell = igonb.Cell{Language: cellMagics[strings.TrimSpace(first)], Source: rest}
+	}
+	exec := b.executor()
+	exec.ClearStop()
+	exec.SetOutputHandler(func(_ int, output string) {
+		x.Stream("stdout", output)
+	})
+	defer exec.SetOutputHandler(nil)
+	nb := &igonb.Notebook{Version: igonb.CurrentVersion, Cells: []igonb.Cell{cell}}
+	results, err := exec.RunNotebookCell(nb, 0)
+	if len(results) > 0 {
+		if cell.Language != "shell" {
+			x.Stream("stdout", results[0].Output)
+		}
+		cell.Error = results[0].Error
+		cell.Execution = results[0].Execution
+	}
+	if cell.Error == "" && err != nil {
+		cell.Error = err.Error()
+	}
+	if cell.Error == "" {
+		return nil
+	}
+	name, value := cell.ErrorNameValue()
+	if errors.Is(err, igonb.ErrExecutionStopped) {
+		name = "KeyboardInterrupt"
+	}
+	return &kernel.Error{
+		Name:      name,
+		Value:     value,
+		Traceback: strings.Split(strings.TrimRight(cell.Error, "\n"), "\n"),
+	}
+}
+
+// Complete completes package members after "pkg.", and otherwise keywords,
+// builtins, package names and session variables
+func (b *notebookBackend) Complete(code string, cursor int) ([]string, int, int) {
+	start := identifierStart(code, cursor)
+	prefix := code[start:cursor]
+	var candidates []string
+	if pkg, ok := qualifier(code, start); ok {
+		for name := range packageSymbols()[pkg] {
+			candidates = append(candidates, name)
+		}
+	} else {
+		candidates = append(candidates, goKeywords...)
+		candidates = append(candidates, goBuiltins...)
+		for pkg := range packageSymbols() {
+			candidates = append(candidates, pkg)
+		}
+		for _, variable := range b.executor().Variables() {
+			candidates = append(candidates, variable.Name)
+		}
+	}
+	seen := make(map[string]bool)
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) && !strings.HasPrefix(candidate, "_") && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches, start, cursor
+}
+
+// Inspect describes the package member or session variable at the cursor
+func (b *notebookBackend) Inspect(code string, cursor int, detailLevel int) (string, bool) {
+	start := identifierStart(code, cursor)
+	end := cursor
+	for end < len(code) && isIdentifierByte(code[end]) {
+		end++
+	}
+	name := code[start:end]
+	if name == "" {
+		return "", false
+	}
+	if pkg, ok := qualifier(code, start); ok {
+		value, found := packageSymbols()[pkg][name]
+		if !found {
+			return "", false
+		}
+		return describeSymbol(pkg, name, value), true
+	}
+	for _, variable := range b.executor().Variables() {
+		if variable.Name == name {
+			return fmt.Sprintf("%s %s\n%s", variable.Name, variable.Type, variable.Preview), true
+		}
+	}
+	if paths := packagePaths()[name]; len(paths) > 0 {
+		return "package " + name + "\n" + strings.Join(paths, "\n"), true
+	}
+	return "", false
+}
+
+// Interrupt stops the running cell
+func (b *notebookBackend) Interrupt() {
+	b.executor().RequestStop()
+}
+
+// Restart replaces the executor with a fresh one
+func (b *notebookBackend) Restart() error {
+	exec, err := newExecutor()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	old := b.exec
+	b.exec = exec
+	b.mu.Unlock()
+	return old.Close()
+}
+
+var goKeywords = []string{
+	"break", "case", "chan", "const", "continue", "default", "defer", "else",
+	"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+	"map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+}
+
+var goBuiltins = []string{
+	"any", "append", "bool", "byte", "cap", "clear", "close", "comparable",
+	"complex", "complex128", "complex64", "copy", "delete", "error", "false",
+	"float32", "float64", "imag", "int", "int16", "int32", "int64", "int8",
+	"iota", "len", "make", "max", "min", "new", "nil", "panic", "print",
+	"println", "real", "recover", "rune", "string", "true", "uint", "uint16",
+	"uint32", "uint64", "uint8", "uintptr",
+}
+
+var (
+	symbolsOnce  sync.Once
+	symbolsByPkg map[string]map[string]reflect.Value
+	pathsByPkg   map[string][]string
+)
+
+// indexSymbols groups the yaegi symbols of the standard library and Insyra by
+// package name; the keys of a symbol table are "import/path/name"
+func indexSymbols() {
+	symbolsByPkg = make(map[string]map[string]reflect.Value)
+	pathsByPkg = make(map[string][]string)
+	for _, table := range []map[string]map[string]reflect.Value{stdlib.Symbols, internal.Symbols} {
+		for key, symbols := range table {
+			slash := strings.LastIndex(key, "/")
+			if slash < 0 {
+				continue
+			}
+			path, name := key[:slash], key[slash+1:]
+			if symbolsByPkg[name] == nil {
+				symbolsByPkg[name] = make(map[string]reflect.Value)
+			}
+			for symbol, value := range symbols {
+				symbolsByPkg[name][symbol] = value
+			}
+			pathsByPkg[name] = append(pathsByPkg[name], path)
+		}
+	}
+	for _, paths := range pathsByPkg {
+		sort.Strings(paths)
+	}
+}
+
+func packageSymbols() map[string]map[string]reflect.Value {
+	symbolsOnce.Do(indexSymbols)
+	return symbolsByPkg
+}
+
+func packagePaths() map[string][]string {
+	symbolsOnce.Do(indexSymbols)
+	return pathsByPkg
+}
+
+// describeSymbol shows a package member the way yaegi registers it: types as
+// nil pointers, variables as addressable values and constants as constant.Value
+func describeSymbol(pkg, name string, value reflect.Value) string {
+	qualified := pkg + "." + name
+	switch {
+	case value.Kind() == reflect.Func:
+		return "func " + qualified + strings.TrimPrefix(value.Type().String(), "func")
+	case value.Kind() == reflect.Pointer && value.IsNil():
+		return "type " + qualified + " " + value.Type().Elem().Kind().String()
+	case value.CanInterface():
+		if c, ok := value.Interface().(constant.Value); ok {
+			return "const " + qualified + " = " + c.String()
+		}
+	}
+	return "var " + qualified + " " + value.Type().String()
+}
+
+// identifierStart returns where the identifier that ends at cursor starts
+func identifierStart(code string, cursor int) int {
+	start := cursor
+	for start > 0 && isIdentifierByte(code[start-1]) {
+		start--
+	}
+	return start
+}
+
+// qualifier returns the package name before the "." that precedes start
+func qualifier(code string, start int) (string, bool) {
+	if start == 0 || code[start-1] != '.' {
+		return "", false
+	}
+	pkgStart := identifierStart(code, start-1)
+	if pkgStart == start-1 {
+		return "", false
+	}
+	return code[pkgStart : start-1], true
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
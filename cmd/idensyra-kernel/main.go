@@ -0,0 +1,142 @@
+// Command idensyra-kernel is a Jupyter kernel for Go with Insyra. It runs each
+// cell on the igonb executor, so cells share one session. Cells that start
+// with a %%python line run as Python sharing the same variables, and cells
+// that start with %%bash or %%sh run as shell commands. Cells that start with
+// %%sql run as SQL whose result becomes a DataTable variable.
+// Install its kernelspec, then pick "Go (Idensyra)" in JupyterLab:
+//
+//	idensyra-kernel install
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/kernel"
+)
+
+const usage = `usage:
+  idensyra-kernel -f CONNECTION_FILE
+  idensyra-kernel install [-prefix DIR] [-name NAME] [-display-name NAME]
+
+Jupyter starts the kernel with -f and the connection file of a session.
+
+install writes the kernelspec to the user's Jupyter data directory, or to
+DIR/share/jupyter/kernels with -prefix (for example a virtual environment).
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	var err error
+	if len(args) > 0 && args[0] == "install" {
+		err = runInstall(args[1:], stdout)
+	} else {
+		err = runKernel(args)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "idensyra-kernel: %v\n", err)
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			fmt.Fprint(stderr, usage)
+		}
+		return 2
+	}
+	return 0
+}
+
+var errUsage = errors.New("missing connection file")
+
+func runKernel(args []string) error {
+	fs := flag.NewFlagSet("idensyra-kernel", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	connectionFile := fs.String("f", "", "connection file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *connectionFile == "" {
+		return errUsage
+	}
+	conn, err := kernel.ReadConnectionFile(*connectionFile)
+	if err != nil {
+		return err
+	}
+	backend, err := newNotebookBackend()
+	if err != nil {
+		return err
+	}
+	k, err := kernel.New(conn, kernelInfo(), backend)
+	if err != nil {
+		return err
+	}
+	log.Printf("Idensyra kernel listening (shell port %d)", k.ConnectionInfo().ShellPort)
+	return k.Serve()
+}
+
+func kernelInfo() kernel.Info {
+	return kernel.Info{
+		Implementation:        "idensyra",
+		ImplementationVersion: buildVersion(),
+		Banner:                "Idensyra: Go with Insyra, and %%python, %%bash and %%sql cells",
+		Language: kernel.LanguageInfo{
+			Name:           "go",
+			Version:        strings.TrimPrefix(runtime.Version(), "go"),
+			MimeType:       "text/x-go",
+			FileExtension:  ".go",
+			PygmentsLexer:  "go",
+			CodeMirrorMode: "go",
+		},
+	}
+}
+
+// buildVersion returns the module version the kernel was built from
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return strings.TrimPrefix(info.Main.Version, "v")
+	}
+	return "dev"
+}
+
+func runInstall(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	prefix := fs.String("prefix", "", "install into DIR/share/jupyter/kernels")
+	name := fs.String("name", "idensyra", "kernelspec name")
+	displayName := fs.String("display-name", "Go (Idensyra)", "name shown in Jupyter")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the kernel executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	kernelsDir := kernel.PrefixKernelsDir(*prefix)
+	if *prefix == "" {
+		if kernelsDir, err = kernel.UserKernelsDir(); err != nil {
+			return err
+		}
+	}
+	dir, err := kernel.Install(kernel.Spec{
+		Argv:          []string{executable, "-f", "{connection_file}"},
+		DisplayName:   *displayName,
+		Language:      "go",
+		InterruptMode: "message",
+	}, kernelsDir, *name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Installed kernelspec %s in %s\n", *name, dir)
+	return nil
+}
@@ -0,0 +1,255 @@
+// Command igonb compares, merges and converts notebooks cell by cell, in .igonb
+// or the .igo.go and .igo.md text formats. It can be used as a git difftool,
+// mergetool, external diff or merge driver:
+//
+//	git config difftool.igonb.cmd 'igonb diff "$LOCAL" "$REMOTE"'
+//	git config mergetool.igonb.cmd 'igonb merge -o "$MERGED" "$BASE" "$LOCAL" "$REMOTE"'
+//	git config mergetool.igonb.trustExitCode true
+//	git config diff.igonb.command 'igonb diff'
+//	git config merge.igonb.driver 'igonb merge -o %A %O %A %B'
+//
+// with "*.igonb diff=igonb merge=igonb" in .gitattributes for the last two.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/HazelnutParadise/idensyra/igonb"
+)
+
+const usage = `usage:
+  igonb diff [-json] OLD NEW
+  igonb merge [-o OUTPUT] [-json] BASE OURS THEIRS
+  igonb convert [-pair] INPUT OUTPUT
+
+diff prints the cells that were added, removed, moved or modified. It also
+accepts the seven arguments git passes to an external diff command.
+
+merge writes the merged notebook to OUTPUT (default: stdout). Conflicting cell
+sources contain <<<<<<< ours / ======= / >>>>>>> theirs markers.
+
+convert writes INPUT in the format of OUTPUT's extension: .igonb, .igo.go (a Go
+file with // %% cell markers) or .igo.md (Markdown with fenced code cells). Cell
+sources convert losslessly; the text formats leave out outputs. -pair marks the
+text notebook as paired and writes the outputs to the .igonb file next to it.
+
+Notebooks are read in the format of their extension.
+
+Exit status: 0 on success, 1 when the merge has conflicts, 2 on errors.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	code := 0
+	switch args[0] {
+	case "diff":
+		err = runDiff(args[1:], stdout)
+	case "merge":
+		code, err = runMerge(args[1:], stdout, stderr)
+	case "convert":
+		err = runConvert(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "igonb: %v\n", err)
+		if errors.Is(err, flag.ErrHelp) || errors.Is(err, errUsage) {
+			fmt.Fprint(stderr, usage)
+		}
+		return 2
+	}
+	return code
+}
+
+var errUsage = errors.New("wrong number of arguments")
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	asJSON := fs.Bool("json", false, "print the diff as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	var label string
+	switch len(files) {
+	case 2:
+		label = files[1]
+	case 7:
+		// git external diff: path old-file old-hex old-mode new-file new-hex new-mode
+		label = files[0]
+		files = []string{files[1], files[4]}
+	default:
+		return errUsage
+	}
+	a, err := readNotebook(files[0])
+	if err != nil {
+		return err
+	}
+	b, err := readNotebook(files[1])
+	if err != nil {
+		return err
+	}
+	diff := igonb.Diff(a, b)
+	if *asJSON {
+		return writeJSON(stdout, diff)
+	}
+	if !diff.HasChanges() {
+		return nil
+	}
+	fmt.Fprintf(stdout, "--- a/%s\n+++ b/%s\n", label, label)
+	_, err = io.WriteString(stdout, diff.String())
+	return err
+}
+
+func runMerge(args []string, stdout, stderr io.Writer) (int, error) {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	output := fs.String("o", "", "write the merged notebook to this file instead of stdout")
+	asJSON := fs.Bool("json", false, "print the conflicts as JSON on stderr")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	files := fs.Args()
+	if len(files) != 3 {
+		return 0, errUsage
+	}
+	notebooks := make([]*igonb.Notebook, len(files))
+	for i, name := range files {
+		nb, err := readNotebook(name)
+		if err != nil {
+			return 0, err
+		}
+		notebooks[i] = nb
+	}
+
+	result := igonb.Merge(notebooks[0], notebooks[1], notebooks[2])
+	var data []byte
+	var err error
+	if format, ok := igonb.TextFormatForPath(files[1]); ok {
+		data, err = igonb.MarshalText(result.Notebook, format)
+	} else {
+		data, err = json.MarshalIndent(result.Notebook, "", "  ")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal merged notebook: %w", err)
+	}
+	if *output == "" {
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		if _, err := stdout.Write(data); err != nil {
+			return 0, err
+		}
+	} else if err := os.WriteFile(*output, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write igonb file: %w", err)
+	}
+
+	if *asJSON {
+		if err := writeJSON(stderr, result.Conflicts); err != nil {
+			return 0, err
+		}
+	} else {
+		for _, c := range result.Conflicts {
+			fmt.Fprintf(stderr, "conflict in cell %d", c.Index+1)
+			if c.ID != "" {
+				fmt.Fprintf(stderr, " (%s)", c.ID)
+			}
+			fmt.Fprintf(stderr, ": %s\n", c.Reason)
+		}
+	}
+	if len(result.Conflicts) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	pair := fs.Bool("pair", false, "keep the outputs in a .igonb file paired with the text notebook")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) != 2 {
+		return errUsage
+	}
+	nb, err := readNotebook(files[0])
+	if err != nil {
+		return err
+	}
+	if nb == nil {
+		return fmt.Errorf("%s: empty notebook", files[0])
+	}
+
+	format, isText := igonb.TextFormatForPath(files[1])
+	if !isText {
+		if *pair {
+			return fmt.Errorf("-pair needs a .igo.go or .igo.md output")
+		}
+		return igonb.WriteFile(files[1], nb)
+	}
+	if *pair {
+		if nb.Metadata == nil {
+			nb.Metadata = make(map[string]any)
+		}
+		nb.Metadata[igonb.MetaPaired] = true
+		if err := igonb.WriteFile(igonb.PairedPath(files[1]), nb); err != nil {
+			return err
+		}
+	}
+	data, err := igonb.MarshalText(nb, format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", files[1], err)
+	}
+	if err := os.WriteFile(files[1], data, 0644); err != nil {
+		return fmt.Errorf("failed to write text notebook: %w", err)
+	}
+	return nil
+}
+
+// readNotebook parses a notebook file; an empty file (such as the /dev/null git
+// passes for added and deleted files) is a notebook without cells
+func readNotebook(path string) (*igonb.Notebook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read igonb file: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var nb *igonb.Notebook
+	if format, ok := igonb.TextFormatForPath(path); ok {
+		nb, err = igonb.ParseText(data, format)
+	} else {
+		nb, err = igonb.Parse(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return nb, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// newConfirmFunc returns the confirmation callback used for Ask permissions.
+// Stdin/stdout carry the MCP protocol, so the prompt goes to the controlling
+// terminal instead; without a terminal every Ask operation is denied.
+func newConfirmFunc() func(operation, details string) bool {
+	in, out, err := openTTY()
+	if err != nil {
+		log.Printf("No TTY available (%v); operations that require confirmation will be denied", err)
+		return func(operation, details string) bool {
+			log.Printf("Operation: %s - %s (denied, no TTY to confirm)", operation, details)
+			return false
+		}
+	}
+
+	reader := bufio.NewReader(in)
+	var mu sync.Mutex
+	return func(operation, details string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fmt.Fprintf(out, "\n[MCP] %s: %s\nAllow? [y/N]: ", operation, details)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			log.Printf("Operation: %s - %s (denied, failed to read answer: %v)", operation, details, err)
+			return false
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		approved := answer == "y" || answer == "yes"
+		if approved {
+			log.Printf("Operation: %s - %s (approved)", operation, details)
+		} else {
+			log.Printf("Operation: %s - %s (denied by user)", operation, details)
+		}
+		return approved
+	}
+}
+
+// openTTY opens the controlling terminal for reading answers and writing prompts
+func openTTY() (io.Reader, io.Writer, error) {
+	if runtime.GOOS == "windows" {
+		in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+		if err != nil {
+			in.Close()
+			return nil, nil, err
+		}
+		if !term.IsTerminal(int(in.Fd())) {
+			in.Close()
+			out.Close()
+			return nil, nil, fmt.Errorf("console is not a terminal")
+		}
+		return in, out, nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !term.IsTerminal(int(tty.Fd())) {
+		tty.Close()
+		return nil, nil, fmt.Errorf("/dev/tty is not a terminal")
+	}
+	return tty, tty, nil
+}
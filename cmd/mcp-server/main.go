@@ -10,9 +10,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 
+	"github.com/HazelnutParadise/idensyra/igonb"
 	"github.com/HazelnutParadise/idensyra/internal"
 	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/HazelnutParadise/insyra/parquet"
 	"github.com/traefik/yaegi/interp"
 	"github.com/traefik/yaegi/stdlib"
 )
@@ -23,7 +28,7 @@ var preCode = `package main
 func main() {
 	var (
 		workspaceRoot = flag.String("workspace", ".", "Workspace root directory")
-		configFile    = flag.String("config", "", "Configuration file path")
+		configFile    = flag.String("config", "", "Permission configuration file (YAML or JSON); defaults to the file shared with the GUI if it exists")
 	)
 	flag.Parse()
 
@@ -34,19 +39,13 @@ func main() {
 	}
 
 	// Load configuration
-	config := mcp.DefaultConfig()
-	if *configFile != "" {
-		// TODO: Load config from file if needed
-		log.Printf("Using default configuration")
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Create confirmation function (auto-approve for CLI mode)
-	confirmFunc := func(operation, details string) bool {
-		// In CLI mode, we auto-approve all operations
-		// In GUI mode, this would show a dialog
-		log.Printf("Operation: %s - %s (auto-approved)", operation, details)
-		return true
-	}
+	// Ask permissions prompt on the terminal, or are denied without one
+	confirmFunc := newConfirmFunc()
 
 	// Create execution functions
 	executeGoFunc := func(code string, colorBG string) string {
@@ -54,7 +53,7 @@ func main() {
 	}
 
 	executePyFunc := func(filePath string) (string, error) {
-		return executePythonFile(filePath)
+		return executePythonFile(absWorkspace, filePath)
 	}
 
 	executePyContentFunc := func(filename string, content string) (string, error) {
@@ -68,7 +67,7 @@ func main() {
 			return "", fmt.Errorf("failed to write temp file: %v", err)
 		}
 		tmp.Close()
-		return executePythonFile(tmp.Name())
+		return executePythonFile(absWorkspace, tmp.Name())
 	}
 
 	executeCellFunc := func(language, code string) (string, error) {
@@ -77,6 +76,10 @@ func main() {
 			return executeGoCode(code, "dark"), nil
 		case "python":
 			return executePyContentFunc(".tmp_cell.py", code)
+		case "shell", "sh", "bash":
+			return executeShellCommand(absWorkspace, code)
+		case "sql":
+			return executeSQLCell(absWorkspace, code)
 		case "markdown":
 			return "Markdown cell (no execution)", nil
 		default:
@@ -210,7 +213,23 @@ func main() {
 		listFilesFunc,
 	)
 
+	server.SetCancelFunc(stopExecution)
+	server.SetParquetReader(func(ctx context.Context, path string) (mcp.Table, error) {
+		dt, err := parquet.Read(ctx, filepath.Join(absWorkspace, filepath.FromSlash(path)), parquet.ReadOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return dt, nil
+	})
+
+	auditLog := mcp.NewAuditLog(mcp.AuditLogPath(absWorkspace))
+	server.SetAuditLog(auditLog)
+	checkpointDir := mcp.CheckpointStorePath(absWorkspace)
+	server.SetCheckpointStore(mcp.NewCheckpointStore(checkpointDir), mcp.DirCheckpointFS{Root: absWorkspace})
+
 	log.Printf("MCP Server started. Workspace: %s", absWorkspace)
+	log.Printf("Audit log: %s", auditLog.Path())
+	log.Printf("Checkpoints: %s", checkpointDir)
 	log.Printf("Available tools: %d", len(server.ListTools()))
 
 	// Serve on stdin/stdout
@@ -220,8 +239,73 @@ func main() {
 	}
 }
 
+// loadConfig loads the permission configuration from path, or from the
+// per-user file shared with the GUI when path is empty
+func loadConfig(path string) (*mcp.Config, error) {
+	if path == "" {
+		defaultPath, err := mcp.DefaultConfigPath()
+		if err != nil {
+			log.Printf("Using default configuration")
+			return mcp.DefaultConfig(), nil
+		}
+		if _, err := os.Stat(defaultPath); err != nil {
+			log.Printf("Using default configuration")
+			return mcp.DefaultConfig(), nil
+		}
+		path = defaultPath
+	}
+
+	config, err := mcp.LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded configuration from %s (%d path rules)", path, len(config.PathRules))
+	return config, nil
+}
+
+// execution serializes code runs, which redirect the process output, and
+// holds the cancel function of the one in progress
+var execution struct {
+	run    sync.Mutex
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// startExecution waits for the code run in progress to finish and returns the
+// context of the next one with the function that ends it
+func startExecution() (context.Context, func()) {
+	execution.run.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	execution.mu.Lock()
+	execution.cancel = cancel
+	execution.mu.Unlock()
+	return ctx, func() {
+		execution.mu.Lock()
+		execution.cancel = nil
+		execution.mu.Unlock()
+		cancel()
+		execution.run.Unlock()
+	}
+}
+
+// stopExecution stops the code run of a cancelled or timed out execute tool.
+// Yaegi stops at its next cancellation point; Python and shell processes are killed.
+func stopExecution(tool string) {
+	if !strings.HasPrefix(tool, "execute_") {
+		return
+	}
+	execution.mu.Lock()
+	cancel := execution.cancel
+	execution.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 // executeGoCode executes Go code using yaegi interpreter
 func executeGoCode(code string, colorBG string) string {
+	ctx, done := startExecution()
+	defer done()
 	var buf bytes.Buffer
 
 	i := interp.New(interp.Options{
@@ -257,7 +341,7 @@ func executeGoCode(code string, colorBG string) string {
 				}
 			}
 		}()
-		_, err = i.Eval(code)
+		_, err = i.EvalWithContext(ctx, code)
 		return err
 	}()
 
@@ -275,10 +359,79 @@ func executeGoCode(code string, colorBG string) string {
 	return result
 }
 
-// executePythonFile executes a Python file
-func executePythonFile(filePath string) (string, error) {
-	ctx := context.Background()
-	cmd := exec.CommandContext(ctx, "python3", filePath)
+// executeShellCommand runs a shell cell in the workspace with bash, or sh
+// without it, and PowerShell on Windows
+func executeShellCommand(dir, code string) (string, error) {
+	ctx, done := startExecution()
+	defer done()
+	shell, args := "sh", []string{"-c", code}
+	if _, err := exec.LookPath("bash"); err == nil {
+		shell = "bash"
+	}
+	if runtime.GOOS == "windows" {
+		shell, args = "powershell.exe", []string{"-NoProfile", "-NonInteractive", "-Command", code}
+	}
+	cmd := exec.CommandContext(ctx, shell, args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), err
+	}
+	return string(output), nil
+}
+
+// sqlExecutor runs sql cells. It lives as long as the server, so the result
+// of one cell stays available to the next as with a notebook in the GUI.
+var sqlExecutor struct {
+	once sync.Once
+	exec *igonb.Executor
+	err  error
+}
+
+// executeSQLCell runs a sql cell with the igonb executor from the workspace, so
+// relative database and data file paths resolve against it
+func executeSQLCell(workspace, code string) (string, error) {
+	sqlExecutor.once.Do(func() {
+		sqlExecutor.exec, sqlExecutor.err = igonb.NewExecutorWithSymbols(internal.Symbols)
+	})
+	if sqlExecutor.err != nil {
+		return "", sqlExecutor.err
+	}
+	exec := sqlExecutor.exec
+
+	ctx, done := startExecution()
+	defer done()
+	exec.ClearStop()
+	stop := context.AfterFunc(ctx, exec.RequestStop)
+	defer stop()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chdir(workspace); err != nil {
+		return "", err
+	}
+	defer os.Chdir(wd)
+
+	nb := &igonb.Notebook{Version: igonb.CurrentVersion, Cells: []igonb.Cell{{Language: "sql", Source: code}}}
+	results, err := exec.RunNotebookCell(nb, 0)
+	if len(results) == 0 {
+		return "", err
+	}
+	return results[0].Output, err
+}
+
+// executePythonFile executes a Python file with the python of the workspace
+// environment, or python3 when the workspace has none
+func executePythonFile(workspace, filePath string) (string, error) {
+	python := "python3"
+	if env := (mcp.PythonEnv{Root: workspace}); env.Exists() {
+		python = env.Python()
+	}
+	ctx, done := startExecution()
+	defer done()
+	cmd := exec.CommandContext(ctx, python, filePath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(output), err
@@ -34,6 +34,7 @@ import {
   OpenWorkspaceAt,
 } from "../wailsjs/go/main/App";
 import { EventsOn } from "../wailsjs/runtime/runtime";
+import { TerminalScreen, terminalKeyInput } from "./terminal.js";
 
 const RenameFile = (...args) => window.go.main.App.RenameFile(...args);
 const SaveResultToWorkspace = (...args) =>
@@ -55,14 +56,80 @@ const PipList = (...args) => window.go.main.App.PipList(...args);
 const PipInstall = (...args) => window.go.main.App.PipInstall(...args);
 const PipUninstall = (...args) => window.go.main.App.PipUninstall(...args);
 const ReinstallPyEnv = (...args) => window.go.main.App.ReinstallPyEnv(...args);
+const GetPythonEnvStatus = (...args) =>
+  window.go.main.App.GetPythonEnvStatus(...args);
+const CreatePythonEnv = (...args) =>
+  window.go.main.App.CreatePythonEnv(...args);
+const LockPythonEnv = (...args) => window.go.main.App.LockPythonEnv(...args);
+const ExportPythonEnv = (...args) =>
+  window.go.main.App.ExportPythonEnv(...args);
+const RemovePythonEnv = (...args) =>
+  window.go.main.App.RemovePythonEnv(...args);
+const SelectWheelDirectory = (...args) =>
+  window.go.main.App.SelectWheelDirectory(...args);
 const GetIPyNBAsIgonbContent = (...args) =>
   window.go.main.App.GetIPyNBAsIgonbContent(...args);
 const ConvertIPyNBToIgonb = (...args) =>
   window.go.main.App.ConvertIPyNBToIgonb(...args);
 const UpdateIPyNBContent = (...args) =>
   window.go.main.App.UpdateIPyNBContent(...args);
+const GetTextNotebookAsIgonbContent = (...args) =>
+  window.go.main.App.GetTextNotebookAsIgonbContent(...args);
+const UpdateTextNotebookContent = (...args) =>
+  window.go.main.App.UpdateTextNotebookContent(...args);
 const AutoSaveTempWorkspace = (...args) =>
   window.go.main.App.AutoSaveTempWorkspace(...args);
+const GetMCPSettings = (...args) => window.go.main.App.GetMCPSettings(...args);
+const UpdateMCPSettings = (...args) =>
+  window.go.main.App.UpdateMCPSettings(...args);
+const GetMCPStatus = (...args) => window.go.main.App.GetMCPStatus(...args);
+const MCPAttachUI = (...args) => window.go.main.App.MCPAttachUI(...args);
+const MCPDetachUI = (...args) => window.go.main.App.MCPDetachUI(...args);
+const GetMCPClientConfigs = (...args) =>
+  window.go.main.App.GetMCPClientConfigs(...args);
+const GetMCPPermissions = (...args) =>
+  window.go.main.App.GetMCPPermissions(...args);
+const UpdateMCPPermissions = (...args) =>
+  window.go.main.App.UpdateMCPPermissions(...args);
+const GetMCPAuditLog = (...args) => window.go.main.App.GetMCPAuditLog(...args);
+const ListMCPCheckpoints = (...args) =>
+  window.go.main.App.ListMCPCheckpoints(...args);
+const DiffMCPCheckpoint = (...args) =>
+  window.go.main.App.DiffMCPCheckpoint(...args);
+const RestoreMCPCheckpoint = (...args) =>
+  window.go.main.App.RestoreMCPCheckpoint(...args);
+const GetWorkspaceConflicts = (...args) =>
+  window.go.main.App.GetWorkspaceConflicts(...args);
+const DiffWorkspaceConflict = (...args) =>
+  window.go.main.App.DiffWorkspaceConflict(...args);
+const ResolveWorkspaceConflict = (...args) =>
+  window.go.main.App.ResolveWorkspaceConflict(...args);
+const GetLargeFileInfo = (...args) =>
+  window.go.main.App.GetLargeFileInfo(...args);
+const ReadLargeFileLines = (...args) =>
+  window.go.main.App.ReadLargeFileLines(...args);
+const SearchLargeFile = (...args) =>
+  window.go.main.App.SearchLargeFile(...args);
+const PatchLargeFileLines = (...args) =>
+  window.go.main.App.PatchLargeFileLines(...args);
+const GetGitStatus = (...args) => window.go.main.App.GetGitStatus(...args);
+const GetGitDiff = (...args) => window.go.main.App.GetGitDiff(...args);
+const GitStage = (...args) => window.go.main.App.GitStage(...args);
+const GitUnstage = (...args) => window.go.main.App.GitUnstage(...args);
+const GitCommit = (...args) => window.go.main.App.GitCommit(...args);
+const GetGitLog = (...args) => window.go.main.App.GetGitLog(...args);
+const DiffNotebooks = (...args) => window.go.main.App.DiffNotebooks(...args);
+const MergeNotebooks = (...args) =>
+  window.go.main.App.MergeNotebooks(...args);
+const ResolveNotebookConflict = (...args) =>
+  window.go.main.App.ResolveNotebookConflict(...args);
+const CreateTerminal = (...args) => window.go.main.App.CreateTerminal(...args);
+const WriteTerminal = (...args) => window.go.main.App.WriteTerminal(...args);
+const ResizeTerminal = (...args) => window.go.main.App.ResizeTerminal(...args);
+const CloseTerminal = (...args) => window.go.main.App.CloseTerminal(...args);
+const ListTerminals = (...args) => window.go.main.App.ListTerminals(...args);
+const GetTerminalOutput = (...args) =>
+  window.go.main.App.GetTerminalOutput(...args);
 
 let editor;
 let liveRun = false;
@@ -84,12 +151,15 @@ let isWorkspaceInitialized = false;
 let isImagePreview = false; // Track if current file is an image
 let importProgressHideTimer = null;
 let isLargeFilePreview = false;
+let largeFileView = null; // { name, startLine, window, editing }
 let isBinaryPreview = false;
 let isIgonbView = false;
 let igonbState = null;
 const igonbSaveTimers = new Map();
 let igonbRenderToken = 0;
 let igonbIdCounter = 0;
+// Newest igonb format version; older notebooks are upgraded when saved
+const IGONB_FORMAT_VERSION = 2;
 const igonbEditors = new Map();
 let igonbOutputMode = "full";
 let igonbSelectedId = null;
@@ -119,7 +189,31 @@ let previewUpdateTimer = null;
 const excelSheetSelections = new Map();
 let excelPreviewToken = 0;
 let pythonPackagesLoading = false;
+let pythonEnvStatus = null;
+let pythonWheelDir = "";
 let mcpHandlersRegistered = false;
+let mcpResultUrl = "http://127.0.0.1:14320/mcp/result";
+let mcpResultToken = "";
+const mcpHandledRequests = new Set();
+let mcpStatus = null;
+let mcpPermissionConfig = null;
+const mcpAuditLimit = 100;
+const mcpCheckpointLimit = 50;
+const mcpPermissionOperations = [
+  ["file_edit", "File edit"],
+  ["file_create", "File create"],
+  ["file_rename", "File rename"],
+  ["file_delete", "File delete"],
+  ["execute_go", "Execute Go"],
+  ["execute_python", "Execute Python"],
+  ["execute_shell", "Execute shell & terminal"],
+  ["notebook_modify", "Notebook modify"],
+  ["notebook_execute", "Notebook execute"],
+  ["workspace_open", "Workspace open"],
+  ["workspace_save", "Workspace save"],
+  ["workspace_modify", "Workspace modify"],
+  ["git_commit", "Git stage & commit"],
+];
 
 function registerMcpHandlers() {
   if (mcpHandlersRegistered) return;
@@ -129,29 +223,81 @@ function registerMcpHandlers() {
   }
   mcpHandlersRegistered = true;
 
-  const respondToMcp = (requestId, result) => {
+  // Send a result (or a structured error) for a brokered MCP request
+  const respondToMcp = (requestId, result, error) => {
     if (!requestId) return;
-    const text = typeof result === "string" ? result : JSON.stringify(result);
+    const body = { request_id: requestId };
+    if (error) {
+      body.error = {
+        code: "ui_error",
+        message: error instanceof Error ? error.message : String(error),
+      };
+    } else {
+      body.result = typeof result === "string" ? result : JSON.stringify(result);
+    }
     // Use HTTP callback to avoid Wails IPC re-entrancy deadlocks.
     setTimeout(() => {
-      fetch("http://127.0.0.1:14320/mcp/result", {
+      fetch(mcpResultUrl, {
         method: "POST",
-        headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ request_id: requestId, result: text }),
+        headers: {
+          "Content-Type": "application/json",
+          "X-Idensyra-Token": mcpResultToken,
+        },
+        body: JSON.stringify(body),
       }).catch(() => { });
     }, 0);
   };
 
+  EventsOn("mcp:status", applyMcpStatus);
+  EventsOn("mcp:audit", () => {
+    const modal = document.getElementById("mcp-settings-modal");
+    if (modal && modal.classList.contains("active")) {
+      renderMcpAuditLog();
+      renderMcpCheckpoints();
+    }
+  });
+  EventsOn("mcp:checkpoint_restored", (paths) => {
+    reloadRestoredFiles(paths || []);
+  });
+  EventsOn("workspace:files-replaced", (paths) => {
+    reloadRestoredFiles(paths || []);
+  });
+  EventsOn("workspace:files-changed", (change) => {
+    if (!change) return;
+    reloadRestoredFiles([
+      ...(change.created || []),
+      ...(change.modified || []),
+      ...(change.deleted || []),
+    ]);
+  });
+  EventsOn("workspace:conflict", (conflict) => {
+    if (conflict) showWorkspaceConflict(conflict);
+  });
+  EventsOn("mcp:notebook_cell_result", (payload) => {
+    const data = Array.isArray(payload) ? payload[0] : payload;
+    if (!data || !isIgonbView || data.path !== activeFileName) return;
+    applyIgonbResult(data.result);
+  });
+  EventsOn("python:packages_changed", () => {
+    const modal = document.getElementById("python-packages-modal");
+    if (modal && modal.classList.contains("active")) {
+      refreshPythonPackages(true);
+    }
+  });
+  refreshMcpStatus();
+
+  const mcpRequestHandlers = {};
+
   const ensureEditorReady = async (requestId) => {
     if (editor) return true;
-    respondToMcp(requestId, "Error: UI not ready");
+    respondToMcp(requestId, null, "UI not ready");
     return false;
   };
 
   // MCP-triggered execution: emulate UI Run behavior exactly
-  EventsOn("mcp:execute_python_file", async (payload) => {
+  mcpRequestHandlers["mcp:execute_python_file"] = async (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
-    if (!data) return;
+    if (!data || !claimMcpRequest(data.request_id)) return;
     const requestId = data.request_id || null;
     if (!(await ensureEditorReady(requestId))) return;
     const path = data.path;
@@ -169,15 +315,14 @@ function registerMcpHandlers() {
       const res = await executeCode();
       respondToMcp(requestId, res);
     } catch (err) {
-      const errStr = `<div class="error-message">Error: ${err}</div>`;
-      setResultOutput(errStr);
-      respondToMcp(requestId, errStr);
+      setResultOutput(`<div class="error-message">Error: ${err}</div>`);
+      respondToMcp(requestId, null, err);
     }
-  });
+  };
 
-  EventsOn("mcp:execute_go_file", async (payload) => {
+  mcpRequestHandlers["mcp:execute_go_file"] = async (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
-    if (!data) return;
+    if (!data || !claimMcpRequest(data.request_id)) return;
     const requestId = data.request_id || null;
     if (!(await ensureEditorReady(requestId))) return;
     const path = data.path;
@@ -192,16 +337,15 @@ function registerMcpHandlers() {
       const res = await executeCode();
       respondToMcp(requestId, res);
     } catch (err) {
-      const errStr = `<div class="error-message">Error: ${err}</div>`;
-      setResultOutput(errStr);
-      respondToMcp(requestId, errStr);
+      setResultOutput(`<div class="error-message">Error: ${err}</div>`);
+      respondToMcp(requestId, null, err);
     }
-  });
+  };
 
   // Code-only executions (no file) - emulate creating a transient buffer and running it
-  EventsOn("mcp:execute_go_code", async (payload) => {
+  mcpRequestHandlers["mcp:execute_go_code"] = async (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
-    if (!data) return;
+    if (!data || !claimMcpRequest(data.request_id)) return;
     const requestId = data.request_id || null;
     if (!(await ensureEditorReady(requestId))) return;
     const code = data.code || "";
@@ -213,9 +357,8 @@ function registerMcpHandlers() {
       const res = await executeCode();
       respondToMcp(requestId, res);
     } catch (err) {
-      const errStr = `<div class="error-message">Error: ${err}</div>`;
-      setResultOutput(errStr);
-      respondToMcp(requestId, errStr);
+      setResultOutput(`<div class="error-message">Error: ${err}</div>`);
+      respondToMcp(requestId, null, err);
     } finally {
       // Restore previous active file if any
       if (prevActive) {
@@ -229,11 +372,11 @@ function registerMcpHandlers() {
         activeFileName = "";
       }
     }
-  });
+  };
 
-  EventsOn("mcp:execute_python_code", async (payload) => {
+  mcpRequestHandlers["mcp:execute_python_code"] = async (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
-    if (!data) return;
+    if (!data || !claimMcpRequest(data.request_id)) return;
     const requestId = data.request_id || null;
     if (!(await ensureEditorReady(requestId))) return;
     const code = data.code || "";
@@ -245,9 +388,8 @@ function registerMcpHandlers() {
       const res = await executeCode();
       respondToMcp(requestId, res);
     } catch (err) {
-      const errStr = `<div class="error-message">Error: ${err}</div>`;
-      setResultOutput(errStr);
-      respondToMcp(requestId, errStr);
+      setResultOutput(`<div class="error-message">Error: ${err}</div>`);
+      respondToMcp(requestId, null, err);
     } finally {
       if (prevActive) {
         try {
@@ -259,11 +401,11 @@ function registerMcpHandlers() {
         activeFileName = "";
       }
     }
-  });
+  };
 
-  EventsOn("mcp:ui_action", async (payload) => {
+  mcpRequestHandlers["mcp:ui_action"] = async (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
-    if (!data) return;
+    if (!data || !claimMcpRequest(data.request_id)) return;
     const action = data.action;
     const requestId = data.request_id || null;
     const respond = (result) => respondToMcp(requestId, result);
@@ -537,9 +679,38 @@ function registerMcpHandlers() {
           throw new Error(`unknown action: ${action}`);
       }
     } catch (err) {
-      const errStr = `Error: ${err}`;
-      respond(errStr);
+      respondToMcp(requestId, null, err);
+    }
+  };
+
+  for (const [event, handler] of Object.entries(mcpRequestHandlers)) {
+    EventsOn(event, handler);
+  }
+  attachMcpUI(mcpRequestHandlers);
+}
+
+// Skip requests this page is already handling (a replay may overlap a live event)
+function claimMcpRequest(requestId) {
+  if (!requestId) return true;
+  if (mcpHandledRequests.has(requestId)) return false;
+  mcpHandledRequests.add(requestId);
+  return true;
+}
+
+// Attach to the MCP request broker and replay requests queued while the UI was away
+async function attachMcpUI(handlers) {
+  try {
+    const attachment = await MCPAttachUI();
+    mcpResultToken = attachment.token || "";
+    for (const request of attachment.pending || []) {
+      const handler = handlers[request.event];
+      if (handler) handler(request.payload);
     }
+  } catch (err) {
+    console.error("Failed to attach MCP handlers:", err);
+  }
+  window.addEventListener("beforeunload", () => {
+    MCPDetachUI().catch(() => { });
   });
 }
 
@@ -1027,6 +1198,11 @@ function applyTextFileContent(filename, content, fromCache = false) {
     return;
   }
 
+  if (isTextNotebookFile(filename)) {
+    showTextNotebook(content, filename);
+    return;
+  }
+
   hideIgonbNotebook();
 
   const model = fromCache
@@ -1176,6 +1352,8 @@ function hideBinaryPreview() {
   isBinaryPreview = false;
 }
 
+const LARGE_FILE_WINDOW_LINES = 500;
+
 function showLargeFilePreview(filename, size) {
   hideImagePreview();
   hideBinaryPreview();
@@ -1191,31 +1369,206 @@ function showLargeFilePreview(filename, size) {
       height: 100%;
       display: flex;
       flex-direction: column;
-      align-items: center;
-      justify-content: center;
+      gap: 8px;
       background: var(--panel-background-color);
-      padding: 24px;
+      padding: 12px;
       box-sizing: border-box;
-      text-align: center;
       color: var(--text-color);
     `;
     editorContainer.parentElement.appendChild(largeContainer);
   }
 
   largeContainer.innerHTML = `
-    <div style="font-size: 18px; margin-bottom: 8px;">
-      <i class="fas fa-file-archive"></i> ${filename}
+    <div style="display: flex; align-items: baseline; gap: 12px;">
+      <span style="font-size: 16px;"><i class="fas fa-file-archive"></i> ${escapeHtml(filename)}</span>
+      <span id="large-file-meta" style="opacity: 0.7; font-size: 13px;">
+        ${formatBytes(size)} · indexing lines...
+      </span>
     </div>
-    <div style="opacity: 0.7; margin-bottom: 6px;">
-      File size: ${formatBytes(size)}
+    <div style="display: flex; flex-wrap: wrap; align-items: center; gap: 6px;">
+      <input id="large-file-line" type="number" min="1" value="1" style="width: 90px;" title="Line" />
+      <button class="secondary" id="large-file-go">Go</button>
+      <button class="secondary" id="large-file-prev"><i class="fas fa-chevron-left"></i> Prev</button>
+      <button class="secondary" id="large-file-next">Next <i class="fas fa-chevron-right"></i></button>
+      <span style="flex: 1;"></span>
+      <input id="large-file-query" type="text" placeholder="Search in file" style="width: 180px;" />
+      <label style="font-size: 12px;"><input id="large-file-regex" type="checkbox" /> Regex</label>
+      <button class="secondary" id="large-file-search"><i class="fas fa-search"></i></button>
     </div>
-    <div style="opacity: 0.7; font-size: 13px;">
-      File is too large to preview or edit in the editor.
+    <div id="large-file-results" style="display: none; max-height: 120px; overflow: auto; font-family: monospace; font-size: 12px;"></div>
+    <textarea id="large-file-content" readonly spellcheck="false"
+      style="flex: 1; min-height: 0; resize: none; font-family: monospace; font-size: 13px; white-space: pre; background: var(--background-color); color: var(--text-color);"></textarea>
+    <div style="display: flex; flex-wrap: wrap; align-items: center; gap: 6px;">
+      <span id="large-file-range" style="opacity: 0.7; font-size: 12px; flex: 1;"></span>
+      <button class="secondary" id="large-file-edit"><i class="fas fa-pen"></i> Edit lines</button>
+      <button class="success" id="large-file-save" style="display: none;"><i class="fas fa-save"></i> Save lines</button>
+      <button class="secondary" id="large-file-cancel" style="display: none;">Cancel</button>
+    </div>
+    <div style="display: flex; gap: 6px;">
+      <input id="large-file-append-text" type="text" placeholder="Text to append at the end of the file" style="flex: 1;" />
+      <button class="secondary" id="large-file-append"><i class="fas fa-plus"></i> Append line</button>
     </div>
   `;
 
+  largeFileView = { name: filename, startLine: 1, window: null, editing: false };
+  const lineInput = largeContainer.querySelector("#large-file-line");
+  largeContainer.querySelector("#large-file-go").addEventListener("click", () =>
+    loadLargeFileWindow(parseInt(lineInput.value, 10) || 1),
+  );
+  lineInput.addEventListener("keydown", (e) => {
+    if (e.key === "Enter") loadLargeFileWindow(parseInt(lineInput.value, 10) || 1);
+  });
+  largeContainer.querySelector("#large-file-prev").addEventListener("click", () =>
+    loadLargeFileWindow(largeFileView.startLine - LARGE_FILE_WINDOW_LINES),
+  );
+  largeContainer.querySelector("#large-file-next").addEventListener("click", () => {
+    const current = largeFileView.window;
+    loadLargeFileWindow(current ? current.endLine + 1 : 1);
+  });
+  largeContainer
+    .querySelector("#large-file-search")
+    .addEventListener("click", () => searchLargeFile());
+  largeContainer
+    .querySelector("#large-file-query")
+    .addEventListener("keydown", (e) => {
+      if (e.key === "Enter") searchLargeFile();
+    });
+  largeContainer
+    .querySelector("#large-file-edit")
+    .addEventListener("click", () => setLargeFileEditing(true));
+  largeContainer
+    .querySelector("#large-file-cancel")
+    .addEventListener("click", () => {
+      setLargeFileEditing(false);
+      loadLargeFileWindow(largeFileView.startLine);
+    });
+  largeContainer
+    .querySelector("#large-file-save")
+    .addEventListener("click", () => saveLargeFileWindow());
+  largeContainer
+    .querySelector("#large-file-append")
+    .addEventListener("click", () => appendToLargeFile());
+
   largeContainer.style.display = "flex";
   isLargeFilePreview = true;
+
+  GetLargeFileInfo(filename)
+    .then((info) => {
+      if (!largeFileView || largeFileView.name !== filename) return;
+      const meta = document.getElementById("large-file-meta");
+      if (meta) {
+        meta.textContent = `${formatBytes(info.size)} · ${info.lines.toLocaleString()} lines`;
+      }
+    })
+    .catch((err) => showMessage(`Failed to index file: ${err}`, "error"));
+  loadLargeFileWindow(1);
+}
+
+async function loadLargeFileWindow(startLine) {
+  if (!largeFileView) return;
+  const name = largeFileView.name;
+  try {
+    const win = await ReadLargeFileLines(
+      name,
+      Math.max(1, startLine),
+      LARGE_FILE_WINDOW_LINES,
+    );
+    if (!largeFileView || largeFileView.name !== name) return;
+    largeFileView.startLine = win.startLine;
+    largeFileView.window = win;
+    setLargeFileEditing(false);
+    document.getElementById("large-file-content").value = win.content;
+    document.getElementById("large-file-line").value = win.startLine;
+    const shown =
+      win.endLine >= win.startLine
+        ? `Lines ${win.startLine.toLocaleString()}–${win.endLine.toLocaleString()}`
+        : "No lines";
+    document.getElementById("large-file-range").textContent =
+      `${shown} of ${win.totalLines.toLocaleString()}` +
+      (win.truncated ? " (window cut at 4 MB)" : "");
+  } catch (err) {
+    showMessage(`Failed to read file: ${err}`, "error");
+  }
+}
+
+function setLargeFileEditing(editing) {
+  if (!largeFileView) return;
+  largeFileView.editing = editing;
+  document.getElementById("large-file-content").readOnly = !editing;
+  document.getElementById("large-file-edit").style.display = editing ? "none" : "";
+  document.getElementById("large-file-save").style.display = editing ? "" : "none";
+  document.getElementById("large-file-cancel").style.display = editing ? "" : "none";
+}
+
+async function saveLargeFileWindow() {
+  const win = largeFileView && largeFileView.window;
+  if (!win) return;
+  const text = document.getElementById("large-file-content").value;
+  try {
+    await PatchLargeFileLines(win.name, win.startLine, win.endLine, text);
+    showMessage(`Saved lines ${win.startLine}–${win.endLine}`, "success");
+    await loadLargeFileWindow(win.startLine);
+  } catch (err) {
+    showMessage(`Failed to save lines: ${err}`, "error");
+  }
+}
+
+async function appendToLargeFile() {
+  if (!largeFileView) return;
+  const input = document.getElementById("large-file-append-text");
+  const text = input.value;
+  if (!text) return;
+  try {
+    const info = await GetLargeFileInfo(largeFileView.name);
+    // Append as a new line after the current last line
+    await PatchLargeFileLines(largeFileView.name, info.lines + 1, info.lines, text + "\n");
+    input.value = "";
+    const updated = await GetLargeFileInfo(largeFileView.name);
+    const meta = document.getElementById("large-file-meta");
+    if (meta) {
+      meta.textContent = `${formatBytes(updated.size)} · ${updated.lines.toLocaleString()} lines`;
+    }
+    await loadLargeFileWindow(
+      Math.max(1, updated.lines - LARGE_FILE_WINDOW_LINES + 1),
+    );
+  } catch (err) {
+    showMessage(`Failed to append: ${err}`, "error");
+  }
+}
+
+async function searchLargeFile() {
+  if (!largeFileView) return;
+  const query = document.getElementById("large-file-query").value;
+  const results = document.getElementById("large-file-results");
+  if (!query) {
+    results.style.display = "none";
+    return;
+  }
+  try {
+    const result = await SearchLargeFile(largeFileView.name, {
+      query,
+      regex: document.getElementById("large-file-regex").checked,
+      max_results: 200,
+    });
+    const matches = result.matches || [];
+    results.innerHTML = matches.length
+      ? matches
+          .map(
+            (m) =>
+              `<div class="large-file-match" data-line="${m.line}" style="cursor: pointer; padding: 1px 4px;">${m.line}: ${escapeHtml(m.text)}</div>`,
+          )
+          .join("") +
+        (result.truncated ? "<div style=\"opacity: 0.7;\">More matches not shown</div>" : "")
+      : "<div style=\"opacity: 0.7;\">No matches</div>";
+    results.style.display = "block";
+    results.querySelectorAll(".large-file-match").forEach((el) => {
+      el.addEventListener("click", () =>
+        loadLargeFileWindow(parseInt(el.dataset.line, 10)),
+      );
+    });
+  } catch (err) {
+    showMessage(`Search failed: ${err}`, "error");
+  }
 }
 
 function hideLargeFilePreview() {
@@ -1223,6 +1576,7 @@ function hideLargeFilePreview() {
   if (largeContainer) {
     largeContainer.style.display = "none";
   }
+  largeFileView = null;
   const editorContainer = document.getElementById("code-editor");
   editorContainer.style.display = "block";
   isLargeFilePreview = false;
@@ -1251,6 +1605,12 @@ function ensureIgonbContainer() {
         <button class="success" id="igonb-convert-ipynb" title="Convert .ipynb to .igonb format" style="display: none;">
           <i class="fas fa-file-export"></i> Convert to .igonb
         </button>
+        <button class="secondary" id="igonb-pair" title="Keep outputs in a paired .igonb file" style="display: none;">
+          <i class="fas fa-link"></i> Pair .igonb
+        </button>
+        <button class="secondary" id="igonb-transfer-mode" title="Pass variables between Go and Python automatically, or only those named by //igonb:export, %import and %export">
+          <i class="fas fa-exchange-alt"></i> <span>Vars: Auto</span>
+        </button>
         <button class="secondary" id="igonb-clear-output" title="Clear output from all cells">
           <i class="fas fa-eraser"></i> Clear Output
         </button>
@@ -1284,6 +1644,12 @@ function ensureIgonbContainer() {
   container
     .querySelector("#igonb-convert-ipynb")
     .addEventListener("click", () => convertIPyNBToIgonb());
+  container
+    .querySelector("#igonb-pair")
+    .addEventListener("click", () => toggleIgonbPairing());
+  container
+    .querySelector("#igonb-transfer-mode")
+    .addEventListener("click", () => toggleIgonbTransferMode());
   container
     .querySelector("#igonb-clear-output")
     .addEventListener("click", () => clearIgonbOutputs());
@@ -1342,6 +1708,8 @@ function showIgonbNotebook(content, filename) {
   updateIgonbRunControls();
   updateIPyNBConvertButton(false); // Hide convert button for .igonb files
   updateAddGoCellButton(true); // Show Add Go button for .igonb files
+  updateIgonbPairButton(isTextNotebookFile(filename));
+  updateIgonbTransferModeButton();
 
   renderIgonbCells();
   setResultOutput(
@@ -1404,12 +1772,31 @@ async function showIPyNBNotebook(content, filename) {
   }
 }
 
+// Show a .igo.go or .igo.md text notebook in the notebook viewer. Text that
+// does not parse as a notebook opens in the editor so the markers can be fixed.
+async function showTextNotebook(content, filename) {
+  let igonbContent;
+  try {
+    igonbContent = await GetTextNotebookAsIgonbContent(filename);
+  } catch (error) {
+    showMessage(`Opened ${filename} as text: ${error}`, "warning");
+    hideIgonbNotebook();
+    const model = getOrCreateFileModel(filename, content);
+    editor.setModel(model);
+    monaco.editor.setModelLanguage(model, getLanguageFromFilename(filename));
+    return;
+  }
+  if (filename !== activeFileName) return;
+  showIgonbNotebook(igonbContent, filename);
+}
+
 function hideIgonbNotebook() {
   const container = document.getElementById("igonb-container");
   if (container) {
     container.style.display = "none";
   }
   updateIPyNBConvertButton(false);
+  updateIgonbPairButton(false);
   const editorContainer = document.getElementById("code-editor");
   editorContainer.style.display = "block";
   isIgonbView = false;
@@ -1445,6 +1832,122 @@ function updateIPyNBConvertButton(show) {
   }
 }
 
+// Show the pairing toggle for text notebooks and mark it when outputs are kept
+// in a paired .igonb file
+function updateIgonbPairButton(show) {
+  const container = document.getElementById("igonb-container");
+  if (!container) return;
+  const pairBtn = container.querySelector("#igonb-pair");
+  if (!pairBtn) return;
+  pairBtn.style.display = show ? "inline-flex" : "none";
+  pairBtn.classList.toggle(
+    "active",
+    Boolean(show && igonbState && igonbState.metadata && igonbState.metadata.paired),
+  );
+}
+
+function toggleIgonbPairing() {
+  if (!igonbState || !isTextNotebookFile(activeFileName)) return;
+  if (!igonbState.metadata) {
+    igonbState.metadata = normalizeIgonbMetadata(null);
+  }
+  if (igonbState.metadata.paired) {
+    delete igonbState.metadata.paired;
+    showMessage("Outputs are no longer kept in a paired .igonb file", "info");
+  } else {
+    igonbState.metadata.paired = true;
+    showMessage(
+      `Outputs are kept in ${getPairedNotebookPath(activeFileName)}`,
+      "success",
+    );
+  }
+  updateIgonbPairButton(true);
+  scheduleIgonbSave();
+}
+
+// Show whether variables pass between Go and Python automatically or only when
+// a cell exports them
+function updateIgonbTransferModeButton() {
+  const container = document.getElementById("igonb-container");
+  if (!container) return;
+  const button = container.querySelector("#igonb-transfer-mode");
+  if (!button) return;
+  const explicit = Boolean(
+    igonbState &&
+      igonbState.metadata &&
+      igonbState.metadata.variable_transfer === "explicit",
+  );
+  button.classList.toggle("active", explicit);
+  button.querySelector("span").textContent = explicit
+    ? "Vars: Explicit"
+    : "Vars: Auto";
+}
+
+function toggleIgonbTransferMode() {
+  if (!igonbState) return;
+  if (!igonbState.metadata) {
+    igonbState.metadata = normalizeIgonbMetadata(null);
+  }
+  if (igonbState.metadata.variable_transfer === "explicit") {
+    delete igonbState.metadata.variable_transfer;
+    showMessage("Variables pass between Go and Python automatically", "info");
+  } else {
+    igonbState.metadata.variable_transfer = "explicit";
+    showMessage(
+      "Only variables named by //igonb:export, %import and %export pass between Go and Python",
+      "info",
+    );
+  }
+  updateIgonbTransferModeButton();
+  scheduleIgonbSave();
+}
+
+function isTextNotebookFile(filename) {
+  return (
+    Boolean(filename) &&
+    (filename.endsWith(".igo.go") || filename.endsWith(".igo.md"))
+  );
+}
+
+function isNotebookFile(filename) {
+  return (
+    Boolean(filename) &&
+    (filename.endsWith(".igonb") ||
+      filename.endsWith(".ipynb") ||
+      isTextNotebookFile(filename))
+  );
+}
+
+// getPairedNotebookPath returns the .igonb file that holds the outputs of a text notebook
+function getPairedNotebookPath(filename) {
+  return filename.replace(/\.igo\.(go|md)$/, ".igonb");
+}
+
+// Store notebook content in the workspace in the format of the file
+async function updateNotebookFileContent(filename, content) {
+  if (filename.endsWith(".ipynb")) {
+    await UpdateIPyNBContent(filename, content);
+  } else if (isTextNotebookFile(filename)) {
+    await UpdateTextNotebookContent(filename, content);
+  } else {
+    await UpdateFileContent(filename, content);
+  }
+}
+
+// Write a text notebook's paired .igonb file when the notebook is saved
+async function savePairedNotebook(filename) {
+  if (
+    !isIgonbView ||
+    !isTextNotebookFile(filename) ||
+    !igonbState ||
+    !igonbState.metadata ||
+    !igonbState.metadata.paired
+  ) {
+    return;
+  }
+  await SaveFile(getPairedNotebookPath(filename));
+}
+
 // Update Add Go button visibility - hide for .ipynb files (Python notebooks)
 function updateAddGoCellButton(show) {
   const container = document.getElementById("igonb-container");
@@ -1504,19 +2007,43 @@ function parseIgonbContent(content) {
       rawCells.length > 0 ? rawCells : [{ language: "go", source: "" }];
     const metadata = normalizeIgonbMetadata(data.metadata);
     let maxId = igonbIdCounter;
-    const parsedCells = cells.map((cell) => {
+    cells.forEach((cell) => {
       if (typeof cell.id === "string") {
         const match = cell.id.match(/^igonb-(\d+)$/);
         if (match) {
           maxId = Math.max(maxId, Number(match[1]));
         }
       }
+    });
+    igonbIdCounter = Math.max(igonbIdCounter, maxId);
+    const usedIds = new Set();
+    const parsedCells = cells.map((cell) => {
+      let id = typeof cell.id === "string" ? cell.id : "";
+      while (!id || usedIds.has(id)) {
+        id = nextIgonbId();
+      }
+      usedIds.add(id);
       return {
-        id: cell.id || nextIgonbId(),
+        id,
         language: normalizeIgonbLanguage(cell.language),
         source: cell.source || "",
         output: typeof cell.output === "string" ? cell.output : "",
         error: typeof cell.error === "string" ? cell.error : "",
+        execution:
+          cell.execution && typeof cell.execution === "object"
+            ? cell.execution
+            : null,
+        metadata:
+          cell.metadata && typeof cell.metadata === "object"
+            ? { ...cell.metadata }
+            : {},
+        // Jupyter outputs and attachments kept from an .ipynb import
+        outputs: Array.isArray(cell.outputs) ? cell.outputs : undefined,
+        attachments:
+          cell.attachments && typeof cell.attachments === "object"
+            ? cell.attachments
+            : undefined,
+        skipped: "",
         running: false,
         waiting: false,
         done: Boolean(
@@ -1526,9 +2053,8 @@ function parseIgonbContent(content) {
         editing: false,
       };
     });
-    igonbIdCounter = Math.max(igonbIdCounter, maxId);
     return {
-      version: data.version || 1,
+      version: Math.max(Number(data.version) || 1, IGONB_FORMAT_VERSION),
       cells: parsedCells,
       metadata,
     };
@@ -1635,9 +2161,12 @@ function normalizeIgonbLanguage(language) {
     .trim();
   if (normalized === "py") return "python";
   if (normalized === "md") return "markdown";
+  if (normalized === "sh" || normalized === "bash") return "shell";
   if (
     normalized === "go" ||
     normalized === "python" ||
+    normalized === "shell" ||
+    normalized === "sql" ||
     normalized === "markdown"
   ) {
     return normalized;
@@ -1691,6 +2220,11 @@ function createIgonbCellElement(cell, index) {
   if (cell.id === igonbSelectedId) {
     container.classList.add("selected");
   }
+  if (cell.metadata && cell.metadata.frozen) {
+    container.classList.add("frozen");
+  } else if (isIgonbCellSkipped(cell)) {
+    container.classList.add("skipped");
+  }
 
   const toolbar = document.createElement("div");
   toolbar.className = "igonb-cell-toolbar";
@@ -1718,14 +2252,17 @@ function createIgonbCellElement(cell, index) {
     ? "Running..."
     : cell.waiting
       ? "Waiting..."
-      : "";
+      : cell.done
+        ? withIgonbExecutionSummary(cell, cell.error ? "Error" : "Done")
+        : "";
+  status.title = getIgonbExecutionTitle(cell);
 
   const actionGroup = document.createElement("div");
   actionGroup.className = "igonb-cell-actions";
 
   const languageSelect = document.createElement("select");
   languageSelect.className = "igonb-cell-language";
-  ["go", "python", "markdown"].forEach((lang) => {
+  ["go", "python", "shell", "sql", "markdown"].forEach((lang) => {
     const option = document.createElement("option");
     option.value = lang;
     option.textContent = lang.toUpperCase();
@@ -1739,6 +2276,7 @@ function createIgonbCellElement(cell, index) {
     cell.language = languageSelect.value;
     cell.output = "";
     cell.error = "";
+    cell.outputs = undefined;
     cell.running = false;
     cell.waiting = false;
     cell.done = false;
@@ -1792,6 +2330,42 @@ function createIgonbCellElement(cell, index) {
       clearIgonbCellOutput(index);
     });
     actionGroup.appendChild(clearBtn);
+
+    const skipBtn = document.createElement("button");
+    skipBtn.className = "secondary icon-only igonb-cell-flag";
+    skipBtn.classList.toggle("active", isIgonbCellSkipped(cell));
+    skipBtn.title = "Skip when running";
+    skipBtn.innerHTML = '<i class="fas fa-forward"></i>';
+    skipBtn.addEventListener("click", (event) => {
+      event.stopPropagation();
+      setIgonbCellMetadata(cell, "skip", isIgonbCellSkipped(cell) ? null : true);
+    });
+    actionGroup.appendChild(skipBtn);
+
+    const freezeBtn = document.createElement("button");
+    freezeBtn.className = "secondary icon-only igonb-cell-flag";
+    freezeBtn.classList.toggle("active", Boolean(cell.metadata && cell.metadata.frozen));
+    freezeBtn.title = "Freeze: keep the output and do not re-run";
+    freezeBtn.innerHTML = '<i class="fas fa-snowflake"></i>';
+    freezeBtn.addEventListener("click", (event) => {
+      event.stopPropagation();
+      setIgonbCellMetadata(
+        cell,
+        "frozen",
+        cell.metadata && cell.metadata.frozen ? null : true,
+      );
+    });
+    actionGroup.appendChild(freezeBtn);
+
+    const settingsBtn = document.createElement("button");
+    settingsBtn.className = "secondary icon-only";
+    settingsBtn.title = "Tags and timeout";
+    settingsBtn.innerHTML = '<i class="fas fa-sliders-h"></i>';
+    settingsBtn.addEventListener("click", (event) => {
+      event.stopPropagation();
+      editIgonbCellSettings(cell);
+    });
+    actionGroup.appendChild(settingsBtn);
   }
 
   const deleteBtn = document.createElement("button");
@@ -1838,18 +2412,80 @@ function createIgonbCellElement(cell, index) {
   } else {
     const output = document.createElement("div");
     output.className = "igonb-cell-output";
-    output.innerHTML = cell.output
-      ? cell.output
-      : '<div class="igonb-empty-output">No output</div>';
-    if (cell.error) {
-      output.innerHTML += `<div class="igonb-error-output">${escapeHtml(cell.error)}</div>`;
-    }
+    output.innerHTML = getIgonbOutputHtml(cell);
     container.appendChild(output);
   }
 
   return container;
 }
 
+// isIgonbCellSkipped mirrors igonb.Cell.Skipped: the skip flag or the skip-execution tag
+function isIgonbCellSkipped(cell) {
+  const metadata = cell.metadata || {};
+  return (
+    metadata.skip === true ||
+    (Array.isArray(metadata.tags) && metadata.tags.includes("skip-execution"))
+  );
+}
+
+function setIgonbCellMetadata(cell, key, value) {
+  if (!cell.metadata) {
+    cell.metadata = {};
+  }
+  if (value === null || value === undefined) {
+    delete cell.metadata[key];
+    if (key === "skip" && Array.isArray(cell.metadata.tags)) {
+      cell.metadata.tags = cell.metadata.tags.filter(
+        (tag) => tag !== "skip-execution",
+      );
+      if (cell.metadata.tags.length === 0) {
+        delete cell.metadata.tags;
+      }
+    }
+  } else {
+    cell.metadata[key] = value;
+  }
+  markIgonbModified();
+  scheduleIgonbSave();
+  renderIgonbCells();
+}
+
+function editIgonbCellSettings(cell) {
+  const metadata = cell.metadata || {};
+  const tagsInput = prompt(
+    "Tags (comma separated; skip-execution, raises-exception):",
+    Array.isArray(metadata.tags) ? metadata.tags.join(", ") : "",
+  );
+  if (tagsInput === null) return;
+  const timeoutInput = prompt(
+    "Timeout in seconds or as a duration like 1m30s (empty for none):",
+    metadata.timeout !== undefined ? String(metadata.timeout) : "",
+  );
+  if (timeoutInput === null) return;
+
+  const tags = tagsInput
+    .split(",")
+    .map((tag) => tag.trim())
+    .filter(Boolean);
+  let timeout = timeoutInput.trim();
+  if (timeout !== "" && /^\d+(\.\d+)?$/.test(timeout)) {
+    timeout = Number(timeout);
+  }
+  if (timeout !== "" && typeof timeout !== "number" && !/^(\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+$/.test(timeout)) {
+    showMessage(`Invalid timeout: ${timeout}`, "error");
+    return;
+  }
+  if (!cell.metadata) {
+    cell.metadata = {};
+  }
+  if (tags.length > 0) {
+    cell.metadata.tags = tags;
+  } else {
+    delete cell.metadata.tags;
+  }
+  setIgonbCellMetadata(cell, "timeout", timeout === "" || timeout === 0 ? null : timeout);
+}
+
 function updateMarkdownPreview(container, source) {
   const preview = container.querySelector(".igonb-markdown-preview");
   if (preview) {
@@ -1951,6 +2587,9 @@ function addIgonbCell(language) {
     source: "",
     output: "",
     error: "",
+    execution: null,
+    metadata: {},
+    skipped: "",
     running: false,
     waiting: false,
     done: false,
@@ -1997,6 +2636,9 @@ function clearIgonbOutputs() {
     if (cell.language === "markdown") return;
     cell.output = "";
     cell.error = "";
+    cell.execution = null;
+    cell.outputs = undefined;
+    cell.transfers = undefined;
     cell.done = false;
     updateIgonbCellOutput(cell);
   });
@@ -2009,6 +2651,9 @@ function clearIgonbCellOutput(index) {
   if (!cell || cell.language === "markdown") return;
   cell.output = "";
   cell.error = "";
+  cell.execution = null;
+  cell.outputs = undefined;
+  cell.transfers = undefined;
   cell.done = false;
   updateIgonbCellOutput(cell);
   scheduleIgonbSave();
@@ -2045,15 +2690,14 @@ async function stopIgonbExecution() {
 
 function scheduleIgonbSave() {
   if (!activeFileName) return;
-  if (!activeFileName.endsWith(".igonb") && !activeFileName.endsWith(".ipynb"))
-    return;
+  if (!isNotebookFile(activeFileName)) return;
   if (!igonbState) return;
   scheduleIgonbSaveForFile(activeFileName, igonbState);
 }
 
 function scheduleIgonbSaveForFile(filename, state) {
   if (!filename) return;
-  if (!filename.endsWith(".igonb") && !filename.endsWith(".ipynb")) return;
+  if (!isNotebookFile(filename)) return;
   if (!state) return;
   const existingTimer = igonbSaveTimers.get(filename);
   if (existingTimer) {
@@ -2061,13 +2705,7 @@ function scheduleIgonbSaveForFile(filename, state) {
   }
   const timer = setTimeout(async () => {
     const payload = getIgonbContentFromState(state);
-    if (filename.endsWith(".ipynb")) {
-      // For .ipynb files, use special API that converts igonb back to ipynb format
-      await UpdateIPyNBContent(filename, payload);
-    } else {
-      // For .igonb files, save as igonb format
-      await UpdateFileContent(filename, payload);
-    }
+    await updateNotebookFileContent(filename, payload);
     scheduleWorkspaceRefresh();
     igonbSaveTimers.delete(filename);
   }, 600);
@@ -2076,13 +2714,20 @@ function scheduleIgonbSaveForFile(filename, state) {
 
 function getIgonbContentFromState(state) {
   const payload = {
-    version: state ? state.version || 1 : 1,
+    version: state ? state.version || IGONB_FORMAT_VERSION : IGONB_FORMAT_VERSION,
     cells: (state ? state.cells : []).map((cell) => ({
       id: cell.id,
       language: cell.language,
       source: cell.source,
       output: cell.output || "",
       error: cell.error || "",
+      execution: cell.execution || undefined,
+      metadata:
+        cell.metadata && Object.keys(cell.metadata).length > 0
+          ? cell.metadata
+          : undefined,
+      outputs: cell.outputs || undefined,
+      attachments: cell.attachments || undefined,
     })),
     metadata: state ? state.metadata : undefined,
   };
@@ -2213,6 +2858,16 @@ function applyIgonbResult(result, state = igonbState, filename = activeFileName)
   const cell = state.cells[idx];
   cell.output = result.output || "";
   cell.error = result.error || "";
+  if (result.execution) {
+    cell.execution = result.execution;
+  }
+  if (!result.skipped) {
+    cell.outputs = undefined;
+  }
+  cell.skipped = result.skipped || "";
+  if (!result.skipped) {
+    cell.transfers = result.transfers || undefined;
+  }
   cell.running = false;
   cell.waiting = false;
   cell.done = true;
@@ -2289,6 +2944,10 @@ function getIgonbMonacoLanguage(language) {
   switch (language) {
     case "python":
       return "python";
+    case "shell":
+      return "shell";
+    case "sql":
+      return "sql";
     case "markdown":
       return "markdown";
     default:
@@ -2422,13 +3081,82 @@ function updateIgonbCellOutput(cell) {
 
   const output = container.querySelector(".igonb-cell-output");
   if (!output) return;
-  output.innerHTML = cell.output
-    ? cell.output
-    : '<div class="igonb-empty-output">No output</div>';
+  output.innerHTML = getIgonbOutputHtml(cell);
+  updateIgonbCellRunningUI(cell);
+}
+
+// getIgonbOutputHtml renders the output of a cell, the images among the Jupyter
+// outputs kept from an .ipynb import, and the error
+function getIgonbOutputHtml(cell) {
+  const images = (Array.isArray(cell.outputs) ? cell.outputs : [])
+    .map((out) => {
+      const data = out && out.data;
+      if (!data) return "";
+      const mime = ["image/png", "image/jpeg", "image/gif"].find(
+        (type) => typeof data[type] === "string",
+      );
+      if (!mime) return "";
+      const base64 = data[mime].replace(/\s/g, "");
+      return `<img class="igonb-output-image" src="data:${mime};base64,${base64}" alt="">`;
+    })
+    .join("");
+  let html =
+    cell.output || images
+      ? (cell.output || "") + images
+      : '<div class="igonb-empty-output">No output</div>';
   if (cell.error) {
-    output.innerHTML += `<div class="igonb-error-output">${escapeHtml(cell.error)}</div>`;
+    html += `<div class="igonb-error-output">${escapeHtml(cell.error)}</div>`;
   }
-  updateIgonbCellRunningUI(cell);
+  return html + getIgonbTransfersHtml(cell.transfers);
+}
+
+// getIgonbTransfersHtml lists the variables a cell passed between Go and Python,
+// with the reason next to the skipped ones
+function getIgonbTransfersHtml(transfers) {
+  if (!Array.isArray(transfers) || transfers.length === 0) return "";
+  const label = { go: "Go", python: "Python" };
+  const items = transfers
+    .map((t) => {
+      const direction = `${label[t.from] || t.from} → ${label[t.to] || t.to}`;
+      if (!t.skipped) {
+        return `<li>${escapeHtml(t.name)} <span class="igonb-transfer-direction">${escapeHtml(direction)}</span></li>`;
+      }
+      return `<li class="skipped">${escapeHtml(t.name)} <span class="igonb-transfer-direction">${escapeHtml(direction)}</span> skipped: ${escapeHtml(t.reason || "")}</li>`;
+    })
+    .join("");
+  const skipped = transfers.filter((t) => t.skipped).length;
+  const summary =
+    `Variables: ${transfers.length - skipped} passed` +
+    (skipped ? `, ${skipped} skipped` : "");
+  return `<details class="igonb-transfers"${skipped ? " open" : ""}><summary>${summary}</summary><ul>${items}</ul></details>`;
+}
+
+// withIgonbExecutionSummary prefixes a status with the execution count and duration of the last run
+function withIgonbExecutionSummary(cell, text) {
+  if (cell.skipped) {
+    return cell.skipped === "frozen" ? "Frozen" : "Skipped";
+  }
+  const execution = cell.execution;
+  if (!execution || !execution.count) return text;
+  const duration = Number(execution.durationMs) || 0;
+  const elapsed =
+    duration >= 1000 ? `${(duration / 1000).toFixed(1)} s` : `${duration} ms`;
+  const label =
+    execution.status === "stopped"
+      ? "Stopped"
+      : execution.status === "timeout"
+        ? "Timed out"
+        : text;
+  return `[${execution.count}] ${label} · ${elapsed}`;
+}
+
+function getIgonbExecutionTitle(cell) {
+  const execution = cell.execution;
+  if (!execution || !execution.startedAt) return "";
+  const started = new Date(execution.startedAt);
+  const ended = new Date(execution.endedAt);
+  if (Number.isNaN(started.getTime()) || Number.isNaN(ended.getTime())) return "";
+  return `Run ${execution.count}: ${started.toLocaleString()} – ${ended.toLocaleTimeString()}`;
 }
 
 function getIgonbRunnableIndices(upToIndex) {
@@ -2574,10 +3302,9 @@ function updateIgonbCellRunningUI(cell) {
       : cell.waiting
         ? "Waiting..."
         : cell.done
-          ? cell.error
-            ? "Error"
-            : "Done"
+          ? withIgonbExecutionSummary(cell, cell.error ? "Error" : "Done")
           : "";
+    status.title = getIgonbExecutionTitle(cell);
   }
   const runButtons = container.querySelectorAll(
     ".igonb-cell-run, .igonb-cell-run-up, .igonb-cell-run-down",
@@ -2852,7 +3579,7 @@ async function executeCode() {
     '<div style="color: #4ec9b0;">Executing code...</div>';
 
   try {
-    if (activeFileName.endsWith(".igonb")) {
+    if (isIgonbView && isNotebookFile(activeFileName)) {
       await runIgonbAll();
       const msg = '<div style="color: #888;">Notebook output is shown inline.</div>';
       setResultOutput(msg);
@@ -3095,6 +3822,7 @@ function setPythonPackagesLoading(isLoading) {
   if (reinstallBtn) reinstallBtn.disabled = isLoading;
   if (refreshBtn) refreshBtn.disabled = isLoading;
   if (input) input.disabled = isLoading;
+  updatePythonEnvControls();
 }
 
 function setPythonPackagesStatus(message) {
@@ -3162,6 +3890,7 @@ async function refreshPythonPackages(force = false) {
   if (pythonPackagesLoading && !force) return;
   setPythonPackagesLoading(true);
   setPythonPackagesStatus("Loading packages...");
+  await refreshPythonEnvStatus();
   try {
     const packages = await PipList();
     renderPythonPackageList(packages || {});
@@ -3189,7 +3918,7 @@ async function installPythonPackage() {
   setPythonPackagesLoading(true);
   setPythonPackagesStatus(`Installing ${pkgName}...`);
   try {
-    await PipInstall(pkgName);
+    await PipInstall(pkgName, pythonWheelDir);
     if (input) input.value = "";
     showMessage(`Installed ${pkgName}`, "success");
     await refreshPythonPackages(true);
@@ -3258,61 +3987,1388 @@ async function reinstallPythonEnvironment() {
   }
 }
 
-function openPythonPackageManager() {
-  const modal = document.getElementById("python-packages-modal");
-  if (!modal) return;
-  modal.classList.add("active");
-  refreshPythonPackages();
-}
+async function refreshPythonEnvStatus() {
+  try {
+    pythonEnvStatus = await GetPythonEnvStatus();
+  } catch (error) {
+    // Temporary workspaces have no environment of their own
+    pythonEnvStatus = null;
+  }
+  updatePythonEnvControls();
+}
+
+function updatePythonEnvControls() {
+  const status = pythonEnvStatus;
+  const hasEnv = !!(status && status.exists);
+  const title = document.getElementById("python-packages-title");
+  if (title) {
+    title.textContent = hasEnv
+      ? `Python Packages (${status.path})`
+      : "Python Packages (Insyra env)";
+  }
+  const hint = document.getElementById("python-packages-hint");
+  if (hint) {
+    if (!status) {
+      hint.textContent =
+        "Manage the embedded Python environment for .py and igonb. Open a workspace to give it its own environment.";
+    } else if (hasEnv) {
+      hint.textContent = `.py files and igonb Python cells use the workspace environment ${status.path}${
+        status.requirementsFile ? `, synced from ${status.requirementsFile}` : ""
+      }.`;
+    } else {
+      hint.textContent = `Manage the embedded Python environment for .py and igonb, or create a workspace environment${
+        status.requirementsFile ? ` from ${status.requirementsFile}` : ""
+      }.`;
+    }
+  }
+
+  const createBtn = document.getElementById("python-env-create");
+  if (createBtn) {
+    createBtn.disabled = pythonPackagesLoading || !status;
+    createBtn.innerHTML = hasEnv
+      ? '<i class="fas fa-arrows-rotate"></i> Sync Env'
+      : '<i class="fas fa-plus"></i> Create Env';
+    createBtn.title = hasEnv
+      ? "Install the packages of the workspace requirements into its environment"
+      : "Create a workspace environment from requirements.txt or pyproject.toml";
+  }
+  const lockBtn = document.getElementById("python-env-lock");
+  if (lockBtn) lockBtn.disabled = pythonPackagesLoading || !hasEnv;
+  const removeBtn = document.getElementById("python-env-remove");
+  if (removeBtn) removeBtn.disabled = pythonPackagesLoading || !hasEnv;
+  const exportBtn = document.getElementById("python-env-export");
+  if (exportBtn) exportBtn.disabled = pythonPackagesLoading;
+  const reinstallBtn = document.getElementById("python-package-reinstall");
+  if (reinstallBtn) reinstallBtn.style.display = hasEnv ? "none" : "";
 
-function closePythonPackageManager() {
-  const modal = document.getElementById("python-packages-modal");
-  if (!modal) return;
-  modal.classList.remove("active");
-  setPythonPackagesStatus("");
+  const wheelsBtn = document.getElementById("python-env-wheels");
+  if (wheelsBtn) {
+    wheelsBtn.disabled = pythonPackagesLoading || !status;
+    wheelsBtn.classList.toggle("active", !!pythonWheelDir);
+    wheelsBtn.title = pythonWheelDir
+      ? `Installing offline from ${pythonWheelDir}; click to install from the package index`
+      : "Install offline from a directory of wheels";
+  }
 }
 
-function initPythonPackageModal() {
-  const modal = document.getElementById("python-packages-modal");
-  if (!modal) return;
-
-  // Clicking outside the modal no longer closes it.
-  // Modal can be closed via the close button or programmatically.
+async function createPythonEnvironment() {
+  if (pythonPackagesLoading) return;
+  const syncing = !!(pythonEnvStatus && pythonEnvStatus.exists);
+  setPythonPackagesLoading(true);
+  setPythonPackagesStatus(
+    syncing
+      ? "Syncing workspace environment..."
+      : "Creating workspace environment...",
+  );
+  try {
+    await CreatePythonEnv(pythonWheelDir);
+    showMessage(
+      syncing ? "Workspace environment synced" : "Workspace environment created",
+      "success",
+    );
+    setPythonPackagesLoading(false);
+    await refreshPythonPackages(true);
+  } catch (error) {
+    console.error("Failed to create Python env:", error);
+    showMessage("Failed to create workspace environment: " + error, "error");
+    setPythonPackagesStatus("Environment setup failed");
+    await refreshPythonEnvStatus();
+  } finally {
+    setPythonPackagesLoading(false);
+  }
+}
 
-  const closeBtn = document.getElementById("python-packages-close");
+async function lockPythonEnvironment() {
+  if (pythonPackagesLoading) return;
+  setPythonPackagesLoading(true);
+  setPythonPackagesStatus("Locking package versions...");
+  try {
+    const file = await LockPythonEnv();
+    showMessage(`Package versions written to ${file}`, "success");
+    setPythonPackagesStatus(`Locked to ${file}`);
+    await refreshPythonEnvStatus();
+  } catch (error) {
+    console.error("Failed to lock Python env:", error);
+    showMessage("Failed to lock package versions: " + error, "error");
+    setPythonPackagesStatus("Lock failed");
+  } finally {
+    setPythonPackagesLoading(false);
+  }
+}
+
+async function exportPythonEnvironment() {
+  if (pythonPackagesLoading) return;
+  try {
+    await ExportPythonEnv();
+  } catch (error) {
+    console.error("Failed to export Python packages:", error);
+    showMessage("Failed to export packages: " + error, "error");
+  }
+}
+
+async function removePythonEnvironment() {
+  if (pythonPackagesLoading) return;
+  if (
+    !confirm(
+      "Remove the workspace environment? Python will use the embedded environment again.",
+    )
+  ) {
+    return;
+  }
+  setPythonPackagesLoading(true);
+  setPythonPackagesStatus("Removing workspace environment...");
+  try {
+    await RemovePythonEnv();
+    showMessage("Workspace environment removed", "success");
+    setPythonPackagesLoading(false);
+    await refreshPythonPackages(true);
+  } catch (error) {
+    console.error("Failed to remove Python env:", error);
+    showMessage("Failed to remove workspace environment: " + error, "error");
+    setPythonPackagesStatus("Remove failed");
+  } finally {
+    setPythonPackagesLoading(false);
+  }
+}
+
+async function togglePythonWheelDirectory() {
+  if (pythonWheelDir) {
+    pythonWheelDir = "";
+    setPythonPackagesStatus("Installing from the package index");
+    updatePythonEnvControls();
+    return;
+  }
+  try {
+    const dir = await SelectWheelDirectory();
+    if (dir) {
+      pythonWheelDir = dir;
+      setPythonPackagesStatus(`Installing offline from ${dir}`);
+    }
+  } catch (error) {
+    console.error("Failed to select wheel directory:", error);
+    showMessage("Failed to select wheel directory: " + error, "error");
+  }
+  updatePythonEnvControls();
+}
+
+function openPythonPackageManager() {
+  const modal = document.getElementById("python-packages-modal");
+  if (!modal) return;
+  modal.classList.add("active");
+  refreshPythonPackages();
+}
+
+function closePythonPackageManager() {
+  const modal = document.getElementById("python-packages-modal");
+  if (!modal) return;
+  modal.classList.remove("active");
+  setPythonPackagesStatus("");
+}
+
+function initPythonPackageModal() {
+  const modal = document.getElementById("python-packages-modal");
+  if (!modal) return;
+
+  // Clicking outside the modal no longer closes it.
+  // Modal can be closed via the close button or programmatically.
+
+  const closeBtn = document.getElementById("python-packages-close");
   if (closeBtn) {
     closeBtn.addEventListener("click", closePythonPackageManager);
   }
 
-  const installBtn = document.getElementById("python-package-install");
-  if (installBtn) {
-    installBtn.addEventListener("click", installPythonPackage);
+  const installBtn = document.getElementById("python-package-install");
+  if (installBtn) {
+    installBtn.addEventListener("click", installPythonPackage);
+  }
+
+  const uninstallBtn = document.getElementById("python-package-uninstall");
+  if (uninstallBtn) {
+    uninstallBtn.addEventListener("click", uninstallPythonPackage);
+  }
+
+  const reinstallBtn = document.getElementById("python-package-reinstall");
+  if (reinstallBtn) {
+    reinstallBtn.addEventListener("click", reinstallPythonEnvironment);
+  }
+
+  const refreshBtn = document.getElementById("python-package-refresh");
+  if (refreshBtn) {
+    refreshBtn.addEventListener("click", refreshPythonPackages);
+  }
+
+  const envActions = {
+    "python-env-create": createPythonEnvironment,
+    "python-env-lock": lockPythonEnvironment,
+    "python-env-export": exportPythonEnvironment,
+    "python-env-remove": removePythonEnvironment,
+    "python-env-wheels": togglePythonWheelDirectory,
+  };
+  Object.entries(envActions).forEach(([id, action]) => {
+    const button = document.getElementById(id);
+    if (button) button.addEventListener("click", action);
+  });
+
+  const input = document.getElementById("python-package-input");
+  if (input) {
+    input.addEventListener("keydown", (event) => {
+      if (event.key === "Enter") {
+        event.preventDefault();
+        installPythonPackage();
+      }
+    });
+  }
+}
+
+function applyMcpStatus(status) {
+  mcpStatus = status || null;
+  if (status && status.resultUrl) {
+    mcpResultUrl = status.resultUrl;
+  }
+
+  const dot = document.getElementById("mcp-status-dot");
+  if (dot) {
+    dot.classList.toggle("running", !!(status && status.running));
+    dot.classList.toggle("error", !!(status && status.error));
+  }
+  const btn = document.getElementById("mcp-btn");
+  if (btn) {
+    btn.title = status && status.running
+      ? `MCP server: ${status.url}`
+      : status && status.error
+        ? `MCP server error: ${status.error}`
+        : "MCP server stopped";
+  }
+
+  const statusEl = document.getElementById("mcp-settings-status");
+  if (statusEl) {
+    statusEl.classList.toggle("error", !!(status && status.error));
+    if (status && status.running) {
+      statusEl.textContent = `Running (${status.transport}) at ${status.url}`;
+    } else if (status && status.error) {
+      statusEl.textContent = `Not running: ${status.error}`;
+    } else {
+      statusEl.textContent = "Stopped";
+    }
+  }
+}
+
+async function refreshMcpStatus() {
+  try {
+    applyMcpStatus(await GetMCPStatus());
+  } catch (error) {
+    console.error("Failed to get MCP status:", error);
+  }
+}
+
+function fillMcpSettingsForm(settings) {
+  if (!settings) return;
+  document.getElementById("mcp-enabled-check").checked = !!settings.enabled;
+  document.getElementById("mcp-bind-input").value = settings.bindAddress || "";
+  const autoPort = settings.port === 0;
+  document.getElementById("mcp-auto-port-check").checked = autoPort;
+  const portInput = document.getElementById("mcp-port-input");
+  portInput.value = autoPort ? "" : settings.port;
+  portInput.disabled = autoPort;
+  document.getElementById("mcp-transport-select").value =
+    settings.transport || "sse";
+}
+
+function readMcpSettingsForm() {
+  const autoPort = document.getElementById("mcp-auto-port-check").checked;
+  const port = autoPort
+    ? 0
+    : parseInt(document.getElementById("mcp-port-input").value, 10);
+  return {
+    enabled: document.getElementById("mcp-enabled-check").checked,
+    bindAddress: document.getElementById("mcp-bind-input").value.trim(),
+    port: Number.isFinite(port) ? port : 14320,
+    transport: document.getElementById("mcp-transport-select").value,
+  };
+}
+
+async function renderMcpClientConfigs() {
+  const list = document.getElementById("mcp-client-configs");
+  if (!list) return;
+  list.innerHTML = "";
+  if (!mcpStatus || !mcpStatus.running) {
+    list.innerHTML =
+      '<div class="mcp-settings-empty">Start the server to generate client configurations.</div>';
+    return;
+  }
+  let snippets = [];
+  try {
+    snippets = (await GetMCPClientConfigs()) || [];
+  } catch (error) {
+    list.innerHTML = `<div class="mcp-settings-empty">${escapeHtml(String(error))}</div>`;
+    return;
+  }
+  snippets.forEach((snippet) => {
+    const item = document.createElement("div");
+    item.className = "mcp-client-config";
+    item.innerHTML = `
+      <div class="mcp-client-config-header">
+        <span>${escapeHtml(snippet.client)} <small>${escapeHtml(snippet.file)}</small></span>
+        <button class="secondary mcp-client-config-copy" title="Copy"><i class="fas fa-copy"></i></button>
+      </div>
+      <pre></pre>`;
+    item.querySelector("pre").textContent = snippet.config;
+    item
+      .querySelector(".mcp-client-config-copy")
+      .addEventListener("click", async () => {
+        try {
+          await navigator.clipboard.writeText(snippet.config);
+          showMessage(`Copied ${snippet.client} configuration`, "success");
+        } catch (error) {
+          showMessage("Failed to copy: " + error, "error");
+        }
+      });
+    list.appendChild(item);
+  });
+}
+
+function mcpPermissionSelect(value) {
+  const select = document.createElement("select");
+  ["always", "ask", "deny"].forEach((level) => {
+    const option = document.createElement("option");
+    option.value = level;
+    option.textContent = level;
+    select.appendChild(option);
+  });
+  select.value = value || "ask";
+  return select;
+}
+
+function renderMcpPermissions() {
+  const levels = document.getElementById("mcp-permission-levels");
+  const rules = document.getElementById("mcp-permission-rules");
+  if (!levels || !rules || !mcpPermissionConfig) return;
+
+  levels.innerHTML = "";
+  mcpPermissionOperations.forEach(([key, label]) => {
+    const row = document.createElement("label");
+    row.className = "mcp-permission-level";
+    row.textContent = label;
+    const select = mcpPermissionSelect(mcpPermissionConfig[key]);
+    select.addEventListener("change", () => {
+      mcpPermissionConfig[key] = select.value;
+    });
+    row.appendChild(select);
+    levels.appendChild(row);
+  });
+
+  rules.innerHTML = "";
+  const pathRules = mcpPermissionConfig.path_rules || [];
+  if (pathRules.length === 0) {
+    rules.innerHTML =
+      '<div class="mcp-settings-empty">No path rules. Example: data/raw/** deny file_edit, file_delete</div>';
+  }
+  pathRules.forEach((rule, index) => {
+    const row = document.createElement("div");
+    row.className = "mcp-permission-rule";
+
+    const pattern = document.createElement("input");
+    pattern.type = "text";
+    pattern.placeholder = "Pattern (e.g. data/raw/**)";
+    pattern.value = rule.pattern || "";
+    pattern.addEventListener("input", () => {
+      rule.pattern = pattern.value.trim();
+    });
+
+    const operations = document.createElement("input");
+    operations.type = "text";
+    operations.placeholder = "Operations (empty = all)";
+    operations.value = (rule.operations || []).join(", ");
+    operations.addEventListener("input", () => {
+      rule.operations = operations.value
+        .split(",")
+        .map((op) => op.trim())
+        .filter(Boolean);
+    });
+
+    const level = mcpPermissionSelect(rule.permission);
+    level.addEventListener("change", () => {
+      rule.permission = level.value;
+    });
+
+    const remove = document.createElement("button");
+    remove.className = "secondary icon-only";
+    remove.title = "Remove rule";
+    remove.innerHTML = '<i class="fas fa-trash"></i>';
+    remove.addEventListener("click", () => {
+      pathRules.splice(index, 1);
+      renderMcpPermissions();
+    });
+
+    row.append(pattern, operations, level, remove);
+    rules.appendChild(row);
+  });
+
+  renderMcpTimeouts();
+}
+
+function renderMcpTimeouts() {
+  const container = document.getElementById("mcp-permission-timeouts");
+  if (!container || !mcpPermissionConfig) return;
+  container.innerHTML = "";
+  const entries = Object.entries(mcpPermissionConfig.timeouts || {});
+  if (entries.length === 0) {
+    container.innerHTML =
+      '<div class="mcp-settings-empty">Default tool timeouts: 10m for execute_* and pip_install, 1m for workspace saves, 30s otherwise. Example: execute_all_cells 30m (0 = no timeout)</div>';
+    return;
+  }
+  const sync = () => {
+    mcpPermissionConfig.timeouts = {};
+    entries.forEach(([tool, value]) => {
+      if (tool) mcpPermissionConfig.timeouts[tool] = value;
+    });
+  };
+  entries.forEach((entry, index) => {
+    const row = document.createElement("div");
+    row.className = "mcp-permission-rule mcp-permission-timeout";
+
+    const tool = document.createElement("input");
+    tool.type = "text";
+    tool.placeholder = "Tool name or default";
+    tool.value = entry[0];
+    tool.addEventListener("input", () => {
+      entry[0] = tool.value.trim();
+      sync();
+    });
+
+    const duration = document.createElement("input");
+    duration.type = "text";
+    duration.placeholder = "Duration (e.g. 90s, 15m)";
+    duration.value = entry[1];
+    duration.addEventListener("input", () => {
+      entry[1] = duration.value.trim();
+      sync();
+    });
+
+    const remove = document.createElement("button");
+    remove.className = "secondary icon-only";
+    remove.title = "Remove timeout";
+    remove.innerHTML = '<i class="fas fa-trash"></i>';
+    remove.addEventListener("click", () => {
+      entries.splice(index, 1);
+      sync();
+      renderMcpTimeouts();
+    });
+
+    row.append(tool, duration, remove);
+    container.appendChild(row);
+  });
+}
+
+async function loadMcpPermissions() {
+  const pathEl = document.getElementById("mcp-permission-path");
+  try {
+    const result = await GetMCPPermissions();
+    mcpPermissionConfig = result.config;
+    if (pathEl) pathEl.textContent = result.path || "";
+  } catch (error) {
+    console.error("Failed to load MCP permissions:", error);
+    showMessage("Invalid MCP permission file: " + error, "error");
+  }
+  if (mcpPermissionConfig && !mcpPermissionConfig.path_rules) {
+    mcpPermissionConfig.path_rules = [];
+  }
+  renderMcpPermissions();
+}
+
+async function saveMcpPermissions() {
+  if (!mcpPermissionConfig) return;
+  if (mcpPermissionConfig.timeouts) {
+    delete mcpPermissionConfig.timeouts[""];
+  }
+  try {
+    await UpdateMCPPermissions(mcpPermissionConfig);
+    showMessage("MCP permissions saved", "success");
+  } catch (error) {
+    console.error("Failed to save MCP permissions:", error);
+    showMessage("Failed to save MCP permissions: " + error, "error");
+  }
+}
+
+function mcpAuditDecision(entry) {
+  const decisions = entry.decisions || [];
+  if (decisions.some((d) => d.decision === "denied")) return "denied";
+  if (decisions.some((d) => d.decision === "rejected")) return "rejected";
+  if (decisions.some((d) => d.decision === "approved")) return "approved";
+  if (decisions.length > 0) return "allowed";
+  return "";
+}
+
+async function renderMcpAuditLog() {
+  const list = document.getElementById("mcp-audit-list");
+  if (!list) return;
+  const filter = document.getElementById("mcp-audit-filter");
+  const tool = filter ? filter.value.trim() : "";
+  let entries = [];
+  try {
+    entries = (await GetMCPAuditLog(mcpAuditLimit, tool)) || [];
+  } catch (error) {
+    list.innerHTML = `<div class="mcp-settings-empty">${escapeHtml(String(error))}</div>`;
+    return;
+  }
+  list.innerHTML = "";
+  if (entries.length === 0) {
+    list.innerHTML =
+      '<div class="mcp-settings-empty">No MCP tool calls recorded in this workspace.</div>';
+    return;
+  }
+
+  entries.reverse().forEach((entry) => {
+    const item = document.createElement("details");
+    item.className = "mcp-audit-entry";
+    if (entry.is_error) item.classList.add("error");
+
+    const decision = mcpAuditDecision(entry);
+    const time = new Date(entry.time).toLocaleString();
+    const summary = document.createElement("summary");
+    summary.innerHTML = `
+      <span class="mcp-audit-time">${escapeHtml(time)}</span>
+      <span class="mcp-audit-tool">${escapeHtml(entry.tool)}</span>
+      ${decision ? `<span class="mcp-audit-decision ${decision}">${decision}</span>` : ""}
+      <span class="mcp-audit-duration">${entry.duration_ms} ms</span>
+      <small>${escapeHtml(entry.source || "")}</small>`;
+    item.appendChild(summary);
+
+    const body = document.createElement("pre");
+    const lines = [];
+    if (entry.arguments) {
+      lines.push("Arguments: " + JSON.stringify(entry.arguments, null, 2));
+    }
+    (entry.decisions || []).forEach((d) => {
+      const paths = d.paths && d.paths.length ? ` (${d.paths.join(", ")})` : "";
+      lines.push(`Permission: ${d.operation}${paths} ${d.level} -> ${d.decision}`);
+    });
+    if (entry.checkpoint) lines.push("Checkpoint: " + entry.checkpoint);
+    if (entry.error) lines.push("Error: " + entry.error);
+    if (entry.summary) lines.push("Result: " + entry.summary);
+    (entry.files || []).forEach((file) => {
+      lines.push(`\n${file.change}: ${file.path}`);
+      if (file.diff) lines.push(file.diff);
+    });
+    body.textContent = lines.join("\n");
+    item.appendChild(body);
+    list.appendChild(item);
+  });
+}
+
+async function reloadRestoredFiles(paths) {
+  const reloadActive = paths.includes(activeFileName);
+  paths.forEach((path) => removeFileModel(path));
+  await loadWorkspaceFiles();
+  if (!reloadActive) return;
+  const name = activeFileName;
+  // Drop the stale editor state instead of writing it back over the restored file
+  activeFileName = "";
+  const target = workspaceFiles.some((file) => file.name === name)
+    ? name
+    : getFirstWorkspaceFile();
+  if (target) {
+    await switchToFile(target, true);
+  }
+}
+
+let currentWorkspaceConflict = null;
+
+function showWorkspaceConflict(conflict) {
+  const modal = document.getElementById("file-conflict-modal");
+  if (!modal) return;
+  // Keep the conflict already on screen; the next one is shown once it is resolved
+  if (
+    currentWorkspaceConflict &&
+    currentWorkspaceConflict.path !== conflict.path
+  ) {
+    return;
+  }
+  currentWorkspaceConflict = conflict;
+  const message = document.getElementById("file-conflict-message");
+  message.textContent = conflict.deleted
+    ? `"${conflict.path}" was deleted on disk, but it has unsaved changes.`
+    : `"${conflict.path}" changed on disk, but it has unsaved changes.`;
+  document.getElementById("file-conflict-keep").innerHTML = conflict.deleted
+    ? '<i class="fas fa-check"></i> Keep (save to recreate)'
+    : '<i class="fas fa-check"></i> Keep mine';
+  const diff = document.getElementById("file-conflict-diff");
+  diff.textContent = "";
+  diff.style.display = "none";
+  modal.classList.add("active");
+}
+
+async function resolveCurrentConflict(action) {
+  const conflict = currentWorkspaceConflict;
+  if (!conflict) return;
+  try {
+    await ResolveWorkspaceConflict(conflict.path, action);
+    if (action === "reload") {
+      await reloadRestoredFiles([conflict.path]);
+    } else {
+      await loadWorkspaceFiles();
+    }
+  } catch (error) {
+    showMessage("Failed to resolve conflict: " + error, "error");
+  }
+  currentWorkspaceConflict = null;
+  document.getElementById("file-conflict-modal").classList.remove("active");
+  const remaining = (await GetWorkspaceConflicts()) || [];
+  if (remaining.length > 0) {
+    showWorkspaceConflict(remaining[0]);
+  }
+}
+
+function initWorkspaceConflictModal() {
+  const modal = document.getElementById("file-conflict-modal");
+  if (!modal) return;
+  document
+    .getElementById("file-conflict-reload")
+    .addEventListener("click", () => resolveCurrentConflict("reload"));
+  document
+    .getElementById("file-conflict-keep")
+    .addEventListener("click", () => resolveCurrentConflict("keep"));
+  document
+    .getElementById("file-conflict-show-diff")
+    .addEventListener("click", async () => {
+      if (!currentWorkspaceConflict) return;
+      const diff = document.getElementById("file-conflict-diff");
+      diff.style.display = "block";
+      diff.textContent = "Loading diff...";
+      try {
+        diff.textContent =
+          (await DiffWorkspaceConflict(currentWorkspaceConflict.path)) ||
+          "No differences.";
+      } catch (error) {
+        diff.textContent = String(error);
+      }
+    });
+}
+
+const gitStatusLetters = {
+  untracked: "U",
+  modified: "M",
+  deleted: "D",
+  staged: "S",
+  conflicted: "C",
+};
+
+function gitIndicator(status, staged) {
+  const letter = gitStatusLetters[status] || "?";
+  const title = staged && status !== "staged" ? `${status}, partly staged` : status;
+  return `<span class="git-indicator git-${status}" title="Git: ${title}">${letter}</span>`;
+}
+
+async function openGitDialog() {
+  const modal = document.getElementById("git-modal");
+  if (!modal) return;
+  modal.classList.add("active");
+  document.getElementById("git-diff").style.display = "none";
+  document.getElementById("git-history").innerHTML = "";
+  await renderGitChanges();
+}
+
+function closeGitDialog() {
+  document.getElementById("git-modal").classList.remove("active");
+}
+
+async function renderGitChanges() {
+  const list = document.getElementById("git-changes");
+  const message = document.getElementById("git-status-message");
+  list.innerHTML = "";
+  let statuses = [];
+  try {
+    statuses = (await GetGitStatus()) || [];
+  } catch (error) {
+    message.textContent = String(error);
+    return;
+  }
+  const stagedCount = statuses.filter((st) => st.staged).length;
+  message.textContent =
+    statuses.length === 0
+      ? "Working tree clean."
+      : `${statuses.length} changed, ${stagedCount} staged. Unsaved edits are saved when a file is staged.`;
+  document.getElementById("git-commit-btn").disabled = stagedCount === 0;
+
+  statuses.forEach((st) => {
+    const item = document.createElement("div");
+    item.className = "git-change";
+    const label = st.orig_path ? `${st.orig_path} → ${st.path}` : st.path;
+    item.innerHTML = `
+      ${gitIndicator(st.status, st.staged)}
+      <span class="git-change-path" title="${escapeHtml(st.path)}">${escapeHtml(label)}</span>
+      <button class="secondary git-change-diff" title="Diff against HEAD"><i class="fas fa-code-compare"></i></button>
+      <button class="secondary git-change-history" title="History"><i class="fas fa-clock-rotate-left"></i></button>
+      ${st.status === "conflicted" && st.path.endsWith(".igonb")
+        ? '<button class="secondary git-change-merge" title="Merge the conflicting versions cell by cell">Merge cells</button>'
+        : ""}
+      <button class="secondary git-change-stage">${st.staged && st.worktree === " " ? "Unstage" : "Stage"}</button>`;
+    item
+      .querySelector(".git-change-diff")
+      .addEventListener("click", () => showGitDiff(st.path));
+    item
+      .querySelector(".git-change-history")
+      .addEventListener("click", () => showGitHistory(st.path));
+    const mergeBtn = item.querySelector(".git-change-merge");
+    if (mergeBtn) {
+      mergeBtn.addEventListener("click", () => resolveNotebookConflict(st.path));
+    }
+    item
+      .querySelector(".git-change-stage")
+      .addEventListener("click", async () => {
+        try {
+          if (st.staged && st.worktree === " ") {
+            await GitUnstage([st.path]);
+          } else {
+            await GitStage([st.path]);
+          }
+        } catch (error) {
+          showMessage("Git failed: " + error, "error");
+        }
+        await renderGitChanges();
+        await loadWorkspaceFiles();
+      });
+    list.appendChild(item);
+  });
+}
+
+async function showGitDiff(path) {
+  const diff = document.getElementById("git-diff");
+  diff.style.display = "block";
+  diff.textContent = "Loading diff...";
+  try {
+    diff.textContent = (await GetGitDiff(path)) || "No saved changes.";
+  } catch (error) {
+    diff.textContent = String(error);
+  }
+}
+
+async function showGitHistory(path) {
+  const history = document.getElementById("git-history");
+  history.innerHTML = "";
+  let commits = [];
+  try {
+    commits = (await GetGitLog(path, 50)) || [];
+  } catch (error) {
+    history.innerHTML = `<div class="mcp-settings-empty">${escapeHtml(String(error))}</div>`;
+    return;
+  }
+  const heading = document.createElement("div");
+  heading.className = "mcp-settings-empty";
+  heading.textContent =
+    commits.length === 0
+      ? `No commits touch ${path || "this repository"}.`
+      : `History of ${path || "the repository"}:`;
+  history.appendChild(heading);
+  commits.forEach((commit) => {
+    const row = document.createElement("div");
+    row.className = "git-commit";
+    row.innerHTML = `
+      <code>${escapeHtml(commit.hash.slice(0, 8))}</code>
+      <span class="git-commit-subject">${escapeHtml(commit.subject)}</span>
+      <small>${escapeHtml(commit.author)}, ${escapeHtml(new Date(commit.date).toLocaleString())}</small>`;
+    history.appendChild(row);
+  });
+}
+
+async function commitGitChanges() {
+  const input = document.getElementById("git-commit-message");
+  const message = input.value.trim();
+  if (!message) {
+    showMessage("Enter a commit message", "error");
+    return;
+  }
+  try {
+    const commit = await GitCommit(message);
+    input.value = "";
+    showMessage(`Committed ${commit.hash.slice(0, 8)}`, "success");
+  } catch (error) {
+    showMessage("Commit failed: " + error, "error");
+  }
+  await renderGitChanges();
+  await loadWorkspaceFiles();
+}
+
+function initGitDialog() {
+  const modal = document.getElementById("git-modal");
+  if (!modal) return;
+  document
+    .getElementById("git-close")
+    .addEventListener("click", closeGitDialog);
+  document
+    .getElementById("git-refresh")
+    .addEventListener("click", renderGitChanges);
+  document
+    .getElementById("git-stage-all")
+    .addEventListener("click", async () => {
+      try {
+        await GitStage(["."]);
+      } catch (error) {
+        showMessage("Git failed: " + error, "error");
+      }
+      await renderGitChanges();
+      await loadWorkspaceFiles();
+    });
+  document
+    .getElementById("git-show-all-diff")
+    .addEventListener("click", () => showGitDiff(""));
+  document
+    .getElementById("git-show-log")
+    .addEventListener("click", () => showGitHistory(""));
+  document
+    .getElementById("git-commit-btn")
+    .addEventListener("click", commitGitChanges);
+  modal.addEventListener("click", (e) => {
+    if (e.target === modal) closeGitDialog();
+  });
+}
+
+// Integrated terminal sessions by ID, each with the screen its output draws
+const terminalSessions = new Map();
+let activeTerminalId = "";
+let terminalRenderPending = false;
+
+// getTerminalSession returns the session of info, adding it for sessions
+// started elsewhere, such as by an MCP agent
+function getTerminalSession(info) {
+  let session = terminalSessions.get(info.id);
+  if (!session) {
+    session = {
+      info,
+      screen: new TerminalScreen(info.cols || 80, info.rows || 24),
+    };
+    terminalSessions.set(info.id, session);
+  } else {
+    session.info = { ...session.info, ...info };
+  }
+  return session;
+}
+
+// measureTerminal returns how many columns and rows fit the terminal screen
+function measureTerminal() {
+  const screen = document.getElementById("terminal-screen");
+  const probe = document.createElement("span");
+  probe.textContent = "MMMMMMMMMM";
+  screen.appendChild(probe);
+  const rect = probe.getBoundingClientRect();
+  probe.remove();
+  const style = getComputedStyle(screen);
+  const width =
+    screen.clientWidth -
+    parseFloat(style.paddingLeft) -
+    parseFloat(style.paddingRight);
+  const height =
+    screen.clientHeight -
+    parseFloat(style.paddingTop) -
+    parseFloat(style.paddingBottom);
+  return {
+    cols: Math.max(20, Math.floor(width / (rect.width / 10 || 8))),
+    rows: Math.max(5, Math.floor(height / (rect.height || 16))),
+  };
+}
+
+function renderTerminal() {
+  if (terminalRenderPending) return;
+  terminalRenderPending = true;
+  requestAnimationFrame(() => {
+    terminalRenderPending = false;
+    const screen = document.getElementById("terminal-screen");
+    const session = terminalSessions.get(activeTerminalId);
+    if (!screen) return;
+    const atBottom =
+      screen.scrollTop + screen.clientHeight >= screen.scrollHeight - 4;
+    screen.innerHTML = session
+      ? session.screen.toHtml(document.activeElement === screen)
+      : "";
+    if (atBottom) {
+      screen.scrollTop = screen.scrollHeight;
+    }
+  });
+}
+
+function renderTerminalSelect() {
+  const select = document.getElementById("terminal-select");
+  select.innerHTML = "";
+  terminalSessions.forEach((session, id) => {
+    const option = document.createElement("option");
+    const shell = session.info.shell.split(/[\\/]/).pop();
+    option.value = id;
+    option.textContent = `${id}: ${shell}${session.info.exited ? " (exited)" : ""}`;
+    option.selected = id === activeTerminalId;
+    select.appendChild(option);
+  });
+  document.getElementById("terminal-kill").disabled = !activeTerminalId;
+}
+
+function selectTerminal(id) {
+  activeTerminalId = id;
+  renderTerminalSelect();
+  fitTerminal();
+  renderTerminal();
+  document.getElementById("terminal-screen").focus();
+}
+
+// fitTerminal resizes the active session to the terminal screen
+function fitTerminal() {
+  const session = terminalSessions.get(activeTerminalId);
+  const modal = document.getElementById("terminal-modal");
+  if (!session || session.info.exited || !modal.classList.contains("active")) {
+    return;
+  }
+  const { cols, rows } = measureTerminal();
+  if (cols === session.screen.cols && rows === session.screen.rows) return;
+  session.screen.resize(cols, rows);
+  ResizeTerminal(session.info.id, cols, rows).catch(() => {});
+  renderTerminal();
+}
+
+async function newTerminal() {
+  const { cols, rows } = measureTerminal();
+  try {
+    const info = await CreateTerminal(cols, rows);
+    getTerminalSession(info).screen.resize(cols, rows);
+    selectTerminal(info.id);
+  } catch (error) {
+    showMessage("Failed to start terminal: " + error, "error");
   }
+}
 
-  const uninstallBtn = document.getElementById("python-package-uninstall");
-  if (uninstallBtn) {
-    uninstallBtn.addEventListener("click", uninstallPythonPackage);
+async function killTerminal() {
+  const id = activeTerminalId;
+  if (!id) return;
+  try {
+    await CloseTerminal(id);
+  } catch (error) {
+    showMessage("Failed to close terminal: " + error, "error");
   }
+  terminalSessions.delete(id);
+  const next = [...terminalSessions.keys()].pop() || "";
+  selectTerminal(next);
+}
 
-  const reinstallBtn = document.getElementById("python-package-reinstall");
-  if (reinstallBtn) {
-    reinstallBtn.addEventListener("click", reinstallPythonEnvironment);
+async function openTerminalPanel() {
+  const modal = document.getElementById("terminal-modal");
+  if (!modal) return;
+  modal.classList.add("active");
+  try {
+    const infos = (await ListTerminals()) || [];
+    for (const info of infos) {
+      const known = terminalSessions.has(info.id);
+      const session = getTerminalSession(info);
+      if (!known) {
+        session.screen.write((await GetTerminalOutput(info.id)) || "");
+      }
+    }
+  } catch (error) {
+    showMessage("Failed to list terminals: " + error, "error");
   }
+  if (terminalSessions.size === 0) {
+    await newTerminal();
+    return;
+  }
+  selectTerminal(
+    terminalSessions.has(activeTerminalId)
+      ? activeTerminalId
+      : [...terminalSessions.keys()].pop(),
+  );
+}
 
-  const refreshBtn = document.getElementById("python-package-refresh");
-  if (refreshBtn) {
-    refreshBtn.addEventListener("click", refreshPythonPackages);
+function closeTerminalPanel() {
+  document.getElementById("terminal-modal").classList.remove("active");
+}
+
+function sendTerminalInput(data) {
+  const session = terminalSessions.get(activeTerminalId);
+  if (!session || session.info.exited || !data) return;
+  WriteTerminal(session.info.id, data).catch((error) =>
+    showMessage("Terminal write failed: " + error, "error"),
+  );
+}
+
+function initTerminalPanel() {
+  const modal = document.getElementById("terminal-modal");
+  if (!modal) return;
+  const screen = document.getElementById("terminal-screen");
+  document
+    .getElementById("terminal-close")
+    .addEventListener("click", closeTerminalPanel);
+  document.getElementById("terminal-new").addEventListener("click", newTerminal);
+  document
+    .getElementById("terminal-kill")
+    .addEventListener("click", killTerminal);
+  document
+    .getElementById("terminal-select")
+    .addEventListener("change", (e) => selectTerminal(e.target.value));
+  modal.addEventListener("click", (e) => {
+    if (e.target === modal) closeTerminalPanel();
+  });
+
+  screen.addEventListener("keydown", (e) => {
+    const input = terminalKeyInput(e);
+    if (!input) return;
+    e.preventDefault();
+    e.stopPropagation();
+    sendTerminalInput(input);
+  });
+  screen.addEventListener("paste", (e) => {
+    e.preventDefault();
+    const text = e.clipboardData ? e.clipboardData.getData("text") : "";
+    sendTerminalInput(text.replace(/\r?\n/g, "\r"));
+  });
+  screen.addEventListener("focus", renderTerminal);
+  screen.addEventListener("blur", renderTerminal);
+  new ResizeObserver(() => fitTerminal()).observe(screen);
+
+  EventsOn("terminal:output", (payload) => {
+    const data = Array.isArray(payload) ? payload[0] : payload;
+    if (!data) return;
+    const known = terminalSessions.has(data.id);
+    getTerminalSession({ id: data.id, shell: "shell" }).screen.write(
+      data.data || "",
+    );
+    if (!known) renderTerminalSelect();
+    if (data.id === activeTerminalId) renderTerminal();
+  });
+  EventsOn("terminal:exit", (payload) => {
+    const data = Array.isArray(payload) ? payload[0] : payload;
+    const session = data && terminalSessions.get(data.id);
+    if (!session) return;
+    session.info.exited = true;
+    session.screen.write(
+      `\r\n\x1b[2m[process exited with code ${data.exitCode}]\x1b[0m\r\n`,
+    );
+    renderTerminalSelect();
+    if (data.id === activeTerminalId) renderTerminal();
+  });
+}
+
+async function resolveNotebookConflict(path) {
+  try {
+    const report = await ResolveNotebookConflict(path);
+    await reloadRestoredFiles([path]);
+    const conflicts = report.conflicts || [];
+    showMessage(
+      conflicts.length === 0
+        ? `Merged ${path} without conflicts; stage it to mark it resolved`
+        : `Merged ${path}; ${conflicts.length} cell(s) still have conflict markers`,
+      conflicts.length === 0 ? "success" : "error",
+    );
+  } catch (error) {
+    showMessage("Notebook merge failed: " + error, "error");
   }
+  await renderGitChanges();
+}
 
-  const input = document.getElementById("python-package-input");
-  if (input) {
-    input.addEventListener("keydown", (event) => {
+function notebookFileNames() {
+  return workspaceFiles
+    .filter((file) => !file.isDir && file.name.endsWith(".igonb"))
+    .map((file) => file.name)
+    .sort();
+}
+
+function openNotebookCompare(path) {
+  const modal = document.getElementById("notebook-compare-modal");
+  if (!modal) return;
+  const names = notebookFileNames();
+  const others = names.filter((name) => name !== path);
+  const fill = (id, selected, allowEmpty) => {
+    const select = document.getElementById(id);
+    select.innerHTML =
+      (allowEmpty ? '<option value="">(none)</option>' : "") +
+      names
+        .map(
+          (name) =>
+            `<option value="${escapeHtml(name)}"${name === selected ? " selected" : ""}>${escapeHtml(name)}</option>`,
+        )
+        .join("");
+  };
+  fill("notebook-compare-old", others[0] || path, false);
+  fill("notebook-compare-new", path, false);
+  fill("notebook-compare-theirs", "", true);
+  document.getElementById("notebook-compare-output").value = path;
+  document.getElementById("notebook-compare-result").innerHTML = "";
+  modal.classList.add("active");
+}
+
+function renderNotebookDiff(diff) {
+  const result = document.getElementById("notebook-compare-result");
+  result.innerHTML = "";
+  const cells = (diff && diff.cells) || [];
+  if (cells.length === 0) {
+    result.innerHTML =
+      '<div class="mcp-settings-empty">The notebooks have the same cells.</div>';
+    return;
+  }
+  cells.forEach((cell) => {
+    const item = document.createElement("details");
+    item.className = `mcp-audit-entry notebook-diff-cell ${cell.kind}`;
+    item.open = cell.kind === "modified";
+    const index = cell.kind === "removed" ? cell.oldIndex : cell.newIndex;
+    let label = cell.kind;
+    if (cell.kind === "moved") {
+      label = `moved from ${cell.oldIndex + 1}`;
+    } else if (cell.moved) {
+      label += `, moved from ${cell.oldIndex + 1}`;
+    }
+    const summary = document.createElement("summary");
+    summary.innerHTML = `
+      <span class="mcp-audit-tool">Cell ${index + 1}</span>
+      <span class="mcp-audit-decision">${escapeHtml(cell.language)}</span>
+      <span>${escapeHtml(label)}</span>
+      ${cell.id ? `<small>${escapeHtml(cell.id)}</small>` : ""}`;
+    item.appendChild(summary);
+    const lines = [];
+    if (cell.oldLanguage) {
+      lines.push(`language: ${cell.oldLanguage} -> ${cell.language}`);
+    }
+    if (cell.sourceDiff) lines.push(cell.sourceDiff);
+    if (cell.outputDiff) lines.push("output:\n" + cell.outputDiff);
+    if (lines.length > 0) {
+      const body = document.createElement("pre");
+      body.textContent = lines.join("\n");
+      item.appendChild(body);
+    }
+    result.appendChild(item);
+  });
+}
+
+async function compareNotebooks() {
+  const oldName = document.getElementById("notebook-compare-old").value;
+  const newName = document.getElementById("notebook-compare-new").value;
+  try {
+    renderNotebookDiff(await DiffNotebooks(oldName, newName));
+  } catch (error) {
+    showMessage("Compare failed: " + error, "error");
+  }
+}
+
+async function mergeNotebooks() {
+  const base = document.getElementById("notebook-compare-old").value;
+  const ours = document.getElementById("notebook-compare-new").value;
+  const theirs = document.getElementById("notebook-compare-theirs").value;
+  const output = document.getElementById("notebook-compare-output").value.trim();
+  if (!theirs) {
+    showMessage("Choose the notebook to merge in (theirs)", "error");
+    return;
+  }
+  try {
+    const report = await MergeNotebooks(base, ours, theirs, output);
+    await reloadRestoredFiles([report.output]);
+    const result = document.getElementById("notebook-compare-result");
+    const conflicts = report.conflicts || [];
+    result.innerHTML =
+      conflicts.length === 0
+        ? `<div class="mcp-settings-empty">Merged into ${escapeHtml(report.output)} without conflicts (unsaved).</div>`
+        : `<div class="mcp-settings-empty">Merged into ${escapeHtml(report.output)} (unsaved) with conflicts:</div>` +
+          conflicts
+            .map(
+              (c) =>
+                `<div class="mcp-settings-empty">Cell ${c.index + 1}${c.id ? ` (${escapeHtml(c.id)})` : ""}: ${escapeHtml(c.reason)}</div>`,
+            )
+            .join("");
+  } catch (error) {
+    showMessage("Merge failed: " + error, "error");
+  }
+}
+
+function initNotebookCompare() {
+  const modal = document.getElementById("notebook-compare-modal");
+  if (!modal) return;
+  document
+    .getElementById("notebook-compare-close")
+    .addEventListener("click", () => modal.classList.remove("active"));
+  document
+    .getElementById("notebook-compare-diff-btn")
+    .addEventListener("click", compareNotebooks);
+  document
+    .getElementById("notebook-compare-merge-btn")
+    .addEventListener("click", mergeNotebooks);
+  modal.addEventListener("click", (e) => {
+    if (e.target === modal) modal.classList.remove("active");
+  });
+}
+
+async function renderMcpCheckpoints() {
+  const list = document.getElementById("mcp-checkpoint-list");
+  if (!list) return;
+  let checkpoints = [];
+  try {
+    checkpoints = (await ListMCPCheckpoints()) || [];
+  } catch (error) {
+    list.innerHTML = `<div class="mcp-settings-empty">${escapeHtml(String(error))}</div>`;
+    return;
+  }
+  list.innerHTML = "";
+  if (checkpoints.length === 0) {
+    list.innerHTML =
+      '<div class="mcp-settings-empty">No checkpoints. One is taken before every MCP tool call that changes files.</div>';
+    return;
+  }
+
+  checkpoints.slice(0, mcpCheckpointLimit).forEach((checkpoint) => {
+    const item = document.createElement("details");
+    item.className = "mcp-audit-entry";
+    const files = (checkpoint.files || []).map((file) => file.path);
+    const summary = document.createElement("summary");
+    summary.innerHTML = `
+      <span class="mcp-audit-time">${escapeHtml(new Date(checkpoint.time).toLocaleString())}</span>
+      <span class="mcp-audit-tool">${escapeHtml(checkpoint.tool)}</span>
+      <span class="mcp-checkpoint-files">${escapeHtml(files.join(", "))}</span>
+      <button class="secondary mcp-checkpoint-restore" title="Restore these files">
+        <i class="fas fa-rotate-left"></i> Restore
+      </button>`;
+    item.appendChild(summary);
+
+    const body = document.createElement("pre");
+    body.textContent = "Loading diff...";
+    item.appendChild(body);
+    item.addEventListener("toggle", async () => {
+      if (!item.open) return;
+      try {
+        const changes = (await DiffMCPCheckpoint(checkpoint.id, [])) || [];
+        body.textContent = changes.length
+          ? changes.map((c) => `${c.change}: ${c.path}\n${c.diff}`).join("\n")
+          : "No changes since this checkpoint.";
+      } catch (error) {
+        body.textContent = String(error);
+      }
+    });
+
+    summary
+      .querySelector(".mcp-checkpoint-restore")
+      .addEventListener("click", async (event) => {
+        event.preventDefault();
+        const ok = confirm(
+          `Restore ${files.join(", ")} to checkpoint ${checkpoint.id}?\nThe current state is checkpointed first.`,
+        );
+        if (!ok) return;
+        try {
+          // Push pending editor changes so the undo checkpoint captures them
+          if (
+            activeFileName &&
+            files.includes(activeFileName) &&
+            !isImagePreview &&
+            !isLargeFilePreview &&
+            !isBinaryPreview
+          ) {
+            if (isIgonbView) {
+              await updateNotebookFileContent(activeFileName, getIgonbContent());
+            } else {
+              await UpdateFileContent(activeFileName, editor.getValue());
+            }
+          }
+          const undoId = await RestoreMCPCheckpoint(checkpoint.id, []);
+          await reloadRestoredFiles(files);
+          showMessage(`Restored checkpoint ${checkpoint.id} (undo: ${undoId})`, "success");
+        } catch (error) {
+          showMessage("Failed to restore checkpoint: " + error, "error");
+        }
+        await renderMcpCheckpoints();
+      });
+    list.appendChild(item);
+  });
+}
+
+async function openMcpSettings() {
+  const modal = document.getElementById("mcp-settings-modal");
+  if (!modal) return;
+  modal.classList.add("active");
+  try {
+    fillMcpSettingsForm(await GetMCPSettings());
+  } catch (error) {
+    console.error("Failed to load MCP settings:", error);
+  }
+  await refreshMcpStatus();
+  await loadMcpPermissions();
+  await renderMcpAuditLog();
+  await renderMcpCheckpoints();
+  await renderMcpClientConfigs();
+}
+
+function closeMcpSettings() {
+  const modal = document.getElementById("mcp-settings-modal");
+  if (!modal) return;
+  modal.classList.remove("active");
+}
+
+async function applyMcpSettings() {
+  const settings = readMcpSettingsForm();
+  try {
+    applyMcpStatus(await UpdateMCPSettings(settings));
+    showMessage("MCP settings applied", "success");
+  } catch (error) {
+    console.error("Failed to apply MCP settings:", error);
+    showMessage("Failed to apply MCP settings: " + error, "error");
+    await refreshMcpStatus();
+  }
+  await renderMcpClientConfigs();
+}
+
+function initMcpSettingsModal() {
+  const closeBtn = document.getElementById("mcp-settings-close");
+  if (closeBtn) {
+    closeBtn.addEventListener("click", closeMcpSettings);
+  }
+  const applyBtn = document.getElementById("mcp-settings-apply");
+  if (applyBtn) {
+    applyBtn.addEventListener("click", applyMcpSettings);
+  }
+  const addRuleBtn = document.getElementById("mcp-permission-add-rule");
+  if (addRuleBtn) {
+    addRuleBtn.addEventListener("click", () => {
+      if (!mcpPermissionConfig) return;
+      mcpPermissionConfig.path_rules = mcpPermissionConfig.path_rules || [];
+      mcpPermissionConfig.path_rules.push({
+        pattern: "",
+        operations: [],
+        permission: "deny",
+      });
+      renderMcpPermissions();
+    });
+  }
+  const addTimeoutBtn = document.getElementById("mcp-permission-add-timeout");
+  if (addTimeoutBtn) {
+    addTimeoutBtn.addEventListener("click", () => {
+      if (!mcpPermissionConfig) return;
+      mcpPermissionConfig.timeouts = mcpPermissionConfig.timeouts || {};
+      if (!("" in mcpPermissionConfig.timeouts)) {
+        mcpPermissionConfig.timeouts[""] = "";
+      }
+      renderMcpTimeouts();
+    });
+  }
+  const savePermissionsBtn = document.getElementById("mcp-permission-save");
+  if (savePermissionsBtn) {
+    savePermissionsBtn.addEventListener("click", saveMcpPermissions);
+  }
+  const auditRefreshBtn = document.getElementById("mcp-audit-refresh");
+  if (auditRefreshBtn) {
+    auditRefreshBtn.addEventListener("click", renderMcpAuditLog);
+  }
+  const checkpointRefreshBtn = document.getElementById("mcp-checkpoint-refresh");
+  if (checkpointRefreshBtn) {
+    checkpointRefreshBtn.addEventListener("click", renderMcpCheckpoints);
+  }
+  const auditFilter = document.getElementById("mcp-audit-filter");
+  if (auditFilter) {
+    auditFilter.addEventListener("keydown", (event) => {
       if (event.key === "Enter") {
         event.preventDefault();
-        installPythonPackage();
+        renderMcpAuditLog();
+      }
+    });
+  }
+  const autoPort = document.getElementById("mcp-auto-port-check");
+  if (autoPort) {
+    autoPort.addEventListener("change", (e) => {
+      const portInput = document.getElementById("mcp-port-input");
+      portInput.disabled = e.target.checked;
+      if (!e.target.checked && !portInput.value) {
+        portInput.value = 14320;
       }
     });
   }
+  refreshMcpStatus();
 }
 
 function closeActionMenu() {
@@ -3429,14 +5485,19 @@ function isRunnableFileName(filename) {
   return (
     filename.endsWith(".go") ||
     filename.endsWith(".py") ||
-    filename.endsWith(".igonb")
+    filename.endsWith(".igonb") ||
+    isTextNotebookFile(filename)
   );
 }
 
 function isRunnableActiveFile() {
   if (!activeFileName) return false;
   if (!isRunnableFileName(activeFileName)) return false;
-  if (activeFileName.endsWith(".igonb") && !isIgonbView) return false;
+  if (
+    (activeFileName.endsWith(".igonb") || isTextNotebookFile(activeFileName)) &&
+    !isIgonbView
+  )
+    return false;
   return !isImagePreview && !isLargeFilePreview && !isBinaryPreview;
 }
 
@@ -4099,11 +6160,11 @@ async function moveWorkspaceEntry(sourcePath, targetDir) {
       !isLargeFilePreview &&
       !isBinaryPreview
     ) {
-      const currentContent =
-        isIgonbView && activeFileName.endsWith(".igonb")
-          ? getIgonbContent()
-          : editor.getValue();
-      await UpdateFileContent(sourcePath, currentContent);
+      if (isIgonbView && isNotebookFile(activeFileName)) {
+        await updateNotebookFileContent(sourcePath, getIgonbContent());
+      } else {
+        await UpdateFileContent(sourcePath, editor.getValue());
+      }
     }
 
     if (entry.isDir) {
@@ -4200,7 +6261,7 @@ function createFileItem(entry, depth) {
     iconClass = "fa-file-image";
   } else if (entry.path.endsWith(".igonb")) {
     iconClass = "fa-book";
-  } else if (entry.path.endsWith(".ipynb")) {
+  } else if (entry.path.endsWith(".ipynb") || isTextNotebookFile(entry.path)) {
     iconClass = "fa-book";
   } else if (entry.path.endsWith(".md")) {
     iconClass = "fa-file-lines";
@@ -4216,6 +6277,10 @@ function createFileItem(entry, depth) {
       ${entry.meta && entry.meta.tooLarge
       ? '<span class="large-indicator" title="Large file">L</span>'
       : ""
+    }
+      ${entry.meta && entry.meta.gitStatus
+      ? gitIndicator(entry.meta.gitStatus, entry.meta.gitStaged)
+      : ""
     }
       ${entry.meta && entry.meta.modified
       ? '<span class="modified-indicator">*</span>'
@@ -4226,6 +6291,12 @@ function createFileItem(entry, depth) {
           <i class="fas fa-ellipsis-h"></i>
         </button>
         <div class="file-action-menu">
+          ${entry.path.endsWith(".igonb")
+      ? `<button class="file-action-item file-action-compare" type="button">
+            Compare / Merge
+          </button>`
+      : ""
+    }
           <button class="file-action-item file-action-rename" type="button">
             Rename
           </button>
@@ -4294,6 +6365,7 @@ function createFileItem(entry, depth) {
   const actionMenu = fileItem.querySelector(".file-action-menu");
   const renameBtn = fileItem.querySelector(".file-action-rename");
   const deleteBtn = fileItem.querySelector(".file-action-delete");
+  const compareBtn = fileItem.querySelector(".file-action-compare");
 
   actionBtn.addEventListener("click", (e) => {
     e.stopPropagation();
@@ -4326,6 +6398,14 @@ function createFileItem(entry, depth) {
     }
   });
 
+  if (compareBtn) {
+    compareBtn.addEventListener("click", (e) => {
+      e.stopPropagation();
+      closeActionMenu();
+      openNotebookCompare(entry.path);
+    });
+  }
+
   return fileItem;
 }
 
@@ -4341,7 +6421,7 @@ function saveFileExecutionState(filename) {
   };
 
   // Save igonb cell states if this is a notebook
-  if (igonbState && (filename.endsWith('.igonb') || filename.endsWith('.ipynb'))) {
+  if (igonbState && isNotebookFile(filename)) {
     state.cellStates = igonbState.cells.map(cell => ({
       running: cell.running,
       waiting: cell.waiting,
@@ -4366,7 +6446,7 @@ function setFileExecutingState(filename, executing) {
 
 function updateFileExecutionCellStates(filename, state) {
   if (!filename || !state) return;
-  if (!filename.endsWith(".igonb") && !filename.endsWith(".ipynb")) return;
+  if (!isNotebookFile(filename)) return;
   const existing = fileExecutionState.get(filename) || {
     isExecuting: false,
     igonbIsExecuting: false,
@@ -4383,7 +6463,7 @@ function updateFileExecutionCellStates(filename, state) {
 
 function setFileIgonbExecutionState(filename, executing, runQueue = []) {
   if (!filename) return;
-  if (!filename.endsWith(".igonb") && !filename.endsWith(".ipynb")) return;
+  if (!isNotebookFile(filename)) return;
   const existing = fileExecutionState.get(filename) || {
     isExecuting: false,
     igonbIsExecuting: false,
@@ -4419,7 +6499,7 @@ function restoreFileExecutionState(filename) {
   igonbRunQueue = [...state.igonbRunQueue];
 
   // Restore igonb cell states if applicable
-  if (state.cellStates && igonbState && (filename.endsWith('.igonb') || filename.endsWith('.ipynb'))) {
+  if (state.cellStates && igonbState && isNotebookFile(filename)) {
     state.cellStates.forEach((cellState, idx) => {
       if (idx < igonbState.cells.length) {
         igonbState.cells[idx].running = cellState.running;
@@ -4461,14 +6541,9 @@ async function switchToFile(filename, force = false) {
         (file) => file.name === activeFileName,
       );
       if (isKnownFile) {
-        if (isIgonbView && activeFileName.endsWith(".ipynb")) {
-          // For .ipynb files, use special API that converts igonb back to ipynb format
-          const igonbContent = getIgonbContent();
-          await UpdateIPyNBContent(activeFileName, igonbContent);
-        } else if (isIgonbView && activeFileName.endsWith(".igonb")) {
-          // For .igonb files, save as igonb format
-          const currentContent = getIgonbContent();
-          await UpdateFileContent(activeFileName, currentContent);
+        if (isIgonbView && isNotebookFile(activeFileName)) {
+          // Notebooks are stored in the format of the file
+          await updateNotebookFileContent(activeFileName, getIgonbContent());
         } else {
           // For other files, use editor content
           await UpdateFileContent(activeFileName, editor.getValue());
@@ -4502,9 +6577,9 @@ async function switchToFile(filename, force = false) {
     }
 
     // Skip cached model for notebook files - they need fresh content from backend
-    const isNotebookFile =
-      filename.endsWith(".igonb") || filename.endsWith(".ipynb");
-    const cachedModel = isNotebookFile ? null : getCachedFileModel(filename);
+    const cachedModel = isNotebookFile(filename)
+      ? null
+      : getCachedFileModel(filename);
     if (cachedModel) {
       const cachedContent = cachedModel.getValue();
       hideImagePreview();
@@ -4860,14 +6935,9 @@ async function saveCurrentFile() {
 
   try {
     // Update file content before saving
-    if (isIgonbView && activeFileName.endsWith(".ipynb")) {
-      // For .ipynb files, use special API that converts igonb back to ipynb format
-      const igonbContent = getIgonbContent();
-      await UpdateIPyNBContent(activeFileName, igonbContent);
-    } else if (isIgonbView && activeFileName.endsWith(".igonb")) {
-      // For .igonb files, save as igonb format
-      const currentContent = getIgonbContent();
-      await UpdateFileContent(activeFileName, currentContent);
+    if (isIgonbView && isNotebookFile(activeFileName)) {
+      // Notebooks are stored in the format of the file
+      await updateNotebookFileContent(activeFileName, getIgonbContent());
     } else {
       // For other files, use editor content
       await UpdateFileContent(activeFileName, editor.getValue());
@@ -4875,6 +6945,7 @@ async function saveCurrentFile() {
 
     // Try to save to disk
     await SaveFile(activeFileName);
+    await savePairedNotebook(activeFileName);
     scheduleWorkspaceRefresh();
     showMessage(`Saved ${activeFileName}`, "success");
   } catch (error) {
@@ -4903,14 +6974,9 @@ async function saveAllFiles() {
       !isLargeFilePreview &&
       !isBinaryPreview
     ) {
-      if (isIgonbView && activeFileName.endsWith(".ipynb")) {
-        // For .ipynb files, use special API that converts igonb back to ipynb format
-        const igonbContent = getIgonbContent();
-        await UpdateIPyNBContent(activeFileName, igonbContent);
-      } else if (isIgonbView && activeFileName.endsWith(".igonb")) {
-        // For .igonb files, save as igonb format
-        const currentContent = getIgonbContent();
-        await UpdateFileContent(activeFileName, currentContent);
+      if (isIgonbView && isNotebookFile(activeFileName)) {
+        // Notebooks are stored in the format of the file
+        await updateNotebookFileContent(activeFileName, getIgonbContent());
       } else {
         // For other files, use editor content
         await UpdateFileContent(activeFileName, editor.getValue());
@@ -4961,12 +7027,11 @@ async function exportCurrentFile() {
       !isLargeFilePreview &&
       !isBinaryPreview
     ) {
-      const currentContent =
-        isIgonbView &&
-          (activeFileName.endsWith(".igonb") || activeFileName.endsWith(".ipynb"))
-          ? getIgonbContent()
-          : editor.getValue();
-      await UpdateFileContent(activeFileName, currentContent);
+      if (isIgonbView && isNotebookFile(activeFileName)) {
+        await updateNotebookFileContent(activeFileName, getIgonbContent());
+      } else {
+        await UpdateFileContent(activeFileName, editor.getValue());
+      }
     }
 
     await ExportCurrentFile();
@@ -5244,6 +7309,15 @@ async function initApp() {
                 <button class="secondary icon-only" id="theme-toggle" title="Toggle Theme">
                     <i class="fas fa-adjust"></i>
                 </button>
+                <button class="secondary" id="terminal-btn" title="Terminal">
+                    <i class="fas fa-terminal"></i> Terminal
+                </button>
+                <button class="secondary" id="git-btn" title="Git">
+                    <i class="fas fa-code-branch"></i> Git
+                </button>
+                <button class="secondary" id="mcp-btn" title="MCP Server">
+                    <span class="mcp-status-dot" id="mcp-status-dot"></span> MCP
+                </button>
                 <button class="secondary" id="github-btn" title="View on GitHub">
                     <i class="fab fa-github"></i>
                 </button>
@@ -5347,14 +7421,31 @@ async function initApp() {
         <div id="python-packages-modal" class="python-packages-modal">
             <div class="python-packages-card">
                 <div class="python-packages-header">
-                    <span>Python Packages (Insyra env)</span>
+                    <span id="python-packages-title">Python Packages (Insyra env)</span>
                     <button class="secondary icon-only" id="python-packages-close" title="Close">
                         <i class="fas fa-times"></i>
                     </button>
                 </div>
-                <div class="python-packages-hint">
+                <div class="python-packages-hint" id="python-packages-hint">
                     Manage the embedded Python environment for .py and igonb.
                 </div>
+                <div class="python-packages-controls python-env-controls">
+                    <button class="secondary" id="python-env-create">
+                        <i class="fas fa-plus"></i> Create Env
+                    </button>
+                    <button class="secondary" id="python-env-lock" title="Write the installed versions to requirements.lock">
+                        <i class="fas fa-lock"></i> Lock
+                    </button>
+                    <button class="secondary" id="python-env-export" title="Save the installed versions as a requirements file">
+                        <i class="fas fa-file-export"></i> Export
+                    </button>
+                    <button class="secondary" id="python-env-wheels">
+                        <i class="fas fa-box-archive"></i> Offline Wheels
+                    </button>
+                    <button class="danger" id="python-env-remove" title="Delete the workspace environment">
+                        <i class="fas fa-trash"></i> Remove Env
+                    </button>
+                </div>
                 <div class="python-packages-controls">
                     <input id="python-package-input" type="text" placeholder="Package name (e.g., numpy)">
                     <button class="secondary" id="python-package-install">
@@ -5374,6 +7465,181 @@ async function initApp() {
                 <div class="python-packages-list" id="python-packages-list"></div>
             </div>
         </div>
+        <div id="file-conflict-modal" class="python-packages-modal">
+            <div class="python-packages-card">
+                <div class="python-packages-header">
+                    <span>File Changed on Disk</span>
+                </div>
+                <div class="python-packages-hint" id="file-conflict-message"></div>
+                <div class="python-packages-controls">
+                    <button class="secondary" id="file-conflict-reload">
+                        <i class="fas fa-rotate"></i> Reload from disk
+                    </button>
+                    <button class="secondary" id="file-conflict-keep">
+                        <i class="fas fa-check"></i> Keep mine
+                    </button>
+                    <button class="secondary" id="file-conflict-show-diff">
+                        <i class="fas fa-code-compare"></i> Diff
+                    </button>
+                </div>
+                <pre class="python-packages-list" id="file-conflict-diff" style="display: none;"></pre>
+            </div>
+        </div>
+        <div id="git-modal" class="python-packages-modal git-modal">
+            <div class="python-packages-card">
+                <div class="python-packages-header">
+                    <span>Git</span>
+                    <button class="secondary icon-only" id="git-close" title="Close">
+                        <i class="fas fa-times"></i>
+                    </button>
+                </div>
+                <div class="python-packages-hint" id="git-status-message"></div>
+                <div class="python-packages-controls">
+                    <button class="secondary" id="git-refresh">
+                        <i class="fas fa-rotate"></i> Refresh
+                    </button>
+                    <button class="secondary" id="git-stage-all">
+                        <i class="fas fa-plus"></i> Stage all
+                    </button>
+                    <button class="secondary" id="git-show-all-diff">
+                        <i class="fas fa-code-compare"></i> Diff all
+                    </button>
+                    <button class="secondary" id="git-show-log">
+                        <i class="fas fa-clock-rotate-left"></i> Log
+                    </button>
+                </div>
+                <div class="git-changes" id="git-changes"></div>
+                <div class="python-packages-controls">
+                    <input type="text" id="git-commit-message" placeholder="Commit message">
+                    <button class="secondary" id="git-commit-btn">
+                        <i class="fas fa-check"></i> Commit
+                    </button>
+                </div>
+                <pre class="python-packages-list" id="git-diff" style="display: none;"></pre>
+                <div class="git-history" id="git-history"></div>
+            </div>
+        </div>
+        <div id="terminal-modal" class="python-packages-modal terminal-modal">
+            <div class="python-packages-card">
+                <div class="python-packages-header">
+                    <span>Terminal</span>
+                    <button class="secondary icon-only" id="terminal-close" title="Close">
+                        <i class="fas fa-times"></i>
+                    </button>
+                </div>
+                <div class="python-packages-controls">
+                    <select id="terminal-select" title="Session"></select>
+                    <button class="secondary" id="terminal-new">
+                        <i class="fas fa-plus"></i> New
+                    </button>
+                    <button class="secondary" id="terminal-kill">
+                        <i class="fas fa-trash"></i> Kill
+                    </button>
+                </div>
+                <pre class="terminal-screen" id="terminal-screen" tabindex="0"></pre>
+            </div>
+        </div>
+        <div id="notebook-compare-modal" class="python-packages-modal git-modal">
+            <div class="python-packages-card">
+                <div class="python-packages-header">
+                    <span>Compare / Merge Notebooks</span>
+                    <button class="secondary icon-only" id="notebook-compare-close" title="Close">
+                        <i class="fas fa-times"></i>
+                    </button>
+                </div>
+                <div class="python-packages-hint">
+                    Compare shows how the second notebook differs from the first, matching cells by ID.
+                    Merge applies the changes of both the second (ours) and third (theirs) notebook to the first (base).
+                </div>
+                <div class="notebook-compare-form">
+                    <label>Old / base <select id="notebook-compare-old"></select></label>
+                    <label>New / ours <select id="notebook-compare-new"></select></label>
+                    <label>Theirs <select id="notebook-compare-theirs"></select></label>
+                    <label>Merge into <input type="text" id="notebook-compare-output"></label>
+                </div>
+                <div class="python-packages-controls">
+                    <button class="secondary" id="notebook-compare-diff-btn">
+                        <i class="fas fa-code-compare"></i> Compare
+                    </button>
+                    <button class="secondary" id="notebook-compare-merge-btn">
+                        <i class="fas fa-code-merge"></i> Merge
+                    </button>
+                </div>
+                <div class="git-history" id="notebook-compare-result"></div>
+            </div>
+        </div>
+        <div id="mcp-settings-modal" class="python-packages-modal mcp-settings-modal">
+            <div class="python-packages-card">
+                <div class="python-packages-header">
+                    <span>MCP Server</span>
+                    <button class="secondary icon-only" id="mcp-settings-close" title="Close">
+                        <i class="fas fa-times"></i>
+                    </button>
+                </div>
+                <div class="python-packages-status mcp-settings-status" id="mcp-settings-status"></div>
+                <div class="mcp-settings-form">
+                    <label class="checkbox-container">
+                        <input type="checkbox" id="mcp-enabled-check">
+                        <span>Enabled</span>
+                    </label>
+                    <label>Bind address
+                        <input id="mcp-bind-input" type="text" placeholder="127.0.0.1">
+                    </label>
+                    <label>Port
+                        <input id="mcp-port-input" type="number" min="1" max="65535">
+                    </label>
+                    <label class="checkbox-container">
+                        <input type="checkbox" id="mcp-auto-port-check">
+                        <span>Pick a free port</span>
+                    </label>
+                    <label>Transport
+                        <select id="mcp-transport-select">
+                            <option value="sse">SSE</option>
+                            <option value="streamable-http">Streamable HTTP</option>
+                        </select>
+                    </label>
+                    <button class="secondary" id="mcp-settings-apply">
+                        <i class="fas fa-check"></i> Apply &amp; Restart
+                    </button>
+                </div>
+                <div class="python-packages-hint mcp-permission-header">
+                    <span>Permissions <small id="mcp-permission-path"></small></span>
+                    <span>
+                        <button class="secondary" id="mcp-permission-add-rule">
+                            <i class="fas fa-plus"></i> Path Rule
+                        </button>
+                        <button class="secondary" id="mcp-permission-add-timeout">
+                            <i class="fas fa-plus"></i> Timeout
+                        </button>
+                        <button class="secondary" id="mcp-permission-save">
+                            <i class="fas fa-save"></i> Save
+                        </button>
+                    </span>
+                </div>
+                <div class="mcp-permission-levels" id="mcp-permission-levels"></div>
+                <div class="mcp-permission-rules" id="mcp-permission-rules"></div>
+                <div class="mcp-permission-rules" id="mcp-permission-timeouts"></div>
+                <div class="python-packages-hint mcp-permission-header">
+                    <span>Audit log</span>
+                    <span>
+                        <input id="mcp-audit-filter" class="mcp-audit-filter" type="text" placeholder="Filter by tool">
+                        <button class="secondary icon-only" id="mcp-audit-refresh" title="Refresh">
+                            <i class="fas fa-rotate"></i>
+                        </button>
+                    </span>
+                </div>
+                <div class="mcp-audit-list" id="mcp-audit-list"></div>
+                <div class="python-packages-hint mcp-permission-header">
+                    <span>Checkpoints</span>
+                    <button class="secondary icon-only" id="mcp-checkpoint-refresh" title="Refresh">
+                        <i class="fas fa-rotate"></i>
+                    </button>
+                </div>
+                <div class="mcp-audit-list" id="mcp-checkpoint-list"></div>
+                <div class="python-packages-hint">Client configuration</div>
+                <div class="python-packages-list" id="mcp-client-configs"></div>
+            </div>
+        </div>
         <div id="import-progress-overlay" class="import-progress-overlay">
             <div class="import-progress-card">
                 <div id="import-progress-title" class="import-progress-title">Importing file</div>
@@ -5513,6 +7779,13 @@ async function initApp() {
   document
     .getElementById("theme-toggle")
     .addEventListener("click", toggleTheme);
+  document.getElementById("git-btn").addEventListener("click", openGitDialog);
+  document
+    .getElementById("terminal-btn")
+    .addEventListener("click", openTerminalPanel);
+  document
+    .getElementById("mcp-btn")
+    .addEventListener("click", openMcpSettings);
   document
     .getElementById("github-btn")
     .addEventListener("click", () => OpenGitHub());
@@ -5565,6 +7838,11 @@ async function initApp() {
   }
 
   initPythonPackageModal();
+  initWorkspaceConflictModal();
+  initGitDialog();
+  initTerminalPanel();
+  initNotebookCompare();
+  initMcpSettingsModal();
   updateRunButtonState();
 
   // Keyboard shortcuts
@@ -5693,10 +7971,7 @@ async function initApp() {
   editor.onDidChangeModelContent(() => {
     if (activeFileName) {
       // Skip for notebook files - they have their own content management via scheduleIgonbSave
-      if (
-        isIgonbView &&
-        (activeFileName.endsWith(".igonb") || activeFileName.endsWith(".ipynb"))
-      ) {
+      if (isIgonbView && isNotebookFile(activeFileName)) {
         return;
       }
       updateActiveFileModelSize();
@@ -5730,6 +8005,18 @@ async function initApp() {
     applyIgonbResult(data);
   });
 
+  // Shell cells stream their output while they run
+  EventsOn("igonb:cell-output", (payload) => {
+    const data = Array.isArray(payload) ? payload[0] : payload;
+    if (!data || !igonbState || !isIgonbView) return;
+    const cell = igonbState.cells[data.index];
+    if (!cell || !cell.running) return;
+    cell.output = data.output || "";
+    cell.error = "";
+    cell.outputs = undefined;
+    updateIgonbCellOutput(cell);
+  });
+
   EventsOn("import:file-progress", (payload) => {
     const data = Array.isArray(payload) ? payload[0] : payload;
     if (!data) return;
@@ -0,0 +1,528 @@
+// A small terminal screen for the integrated terminal panel. It understands
+// the escape sequences shells and common CLI tools use: cursor movement,
+// erasing, scroll regions, the alternate screen and SGR colors.
+
+const SCROLLBACK_LINES = 2000;
+
+const ANSI_COLORS = [
+  "#000000",
+  "#cd3131",
+  "#0dbc79",
+  "#e5e510",
+  "#2472c8",
+  "#bc3fbc",
+  "#11a8cd",
+  "#e5e5e5",
+  "#666666",
+  "#f14c4c",
+  "#23d18b",
+  "#f5f543",
+  "#3b8eea",
+  "#d670d6",
+  "#29b8db",
+  "#ffffff",
+];
+
+// color256 returns the CSS color of an xterm 256-color palette index
+function color256(n) {
+  if (n < 16) return ANSI_COLORS[n];
+  if (n < 232) {
+    const i = n - 16;
+    const level = (v) => (v === 0 ? 0 : 55 + v * 40);
+    const r = level(Math.floor(i / 36));
+    const g = level(Math.floor(i / 6) % 6);
+    const b = level(i % 6);
+    return `rgb(${r},${g},${b})`;
+  }
+  const gray = 8 + (n - 232) * 10;
+  return `rgb(${gray},${gray},${gray})`;
+}
+
+const defaultStyle = () => ({
+  fg: null,
+  bg: null,
+  bold: false,
+  dim: false,
+  italic: false,
+  underline: false,
+  inverse: false,
+});
+
+function blankCell(style) {
+  return { ch: " ", style };
+}
+
+function escapeText(text) {
+  return text
+    .replace(/&/g, "&amp;")
+    .replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;");
+}
+
+export class TerminalScreen {
+  constructor(cols = 80, rows = 24) {
+    this.cols = cols;
+    this.rows = rows;
+    this.style = defaultStyle();
+    this.scrollback = [];
+    this.lines = this.blankLines(rows);
+    this.x = 0;
+    this.y = 0;
+    this.top = 0;
+    this.bottom = rows - 1;
+    this.cursorVisible = true;
+    this.saved = null;
+    this.alternate = null;
+    this.pending = "";
+  }
+
+  blankLine() {
+    return Array.from({ length: this.cols }, () => blankCell(this.style));
+  }
+
+  blankLines(count) {
+    return Array.from({ length: count }, () => this.blankLine());
+  }
+
+  // resize changes the size of the screen, keeping the lines above the cursor
+  resize(cols, rows) {
+    if (cols === this.cols && rows === this.rows) return;
+    const fit = (line) => {
+      const out = line.slice(0, cols);
+      while (out.length < cols) out.push(blankCell(defaultStyle()));
+      return out;
+    };
+    this.cols = cols;
+    this.lines = this.lines.map(fit);
+    while (this.lines.length > rows && this.y > 0) {
+      this.pushScrollback(this.lines.shift());
+      this.y--;
+    }
+    this.lines = this.lines.slice(0, rows);
+    while (this.lines.length < rows) this.lines.push(this.blankLine());
+    this.rows = rows;
+    this.top = 0;
+    this.bottom = rows - 1;
+    this.x = Math.min(this.x, cols - 1);
+    this.y = Math.min(this.y, rows - 1);
+  }
+
+  pushScrollback(line) {
+    if (this.alternate) return;
+    this.scrollback.push(line);
+    if (this.scrollback.length > SCROLLBACK_LINES) this.scrollback.shift();
+  }
+
+  // write interprets output of the shell
+  write(data) {
+    const text = this.pending + data;
+    this.pending = "";
+    let i = 0;
+    while (i < text.length) {
+      const ch = text[i];
+      if (ch === "\x1b") {
+        const end = this.escape(text, i);
+        if (end < 0) {
+          // keep an unfinished sequence for the next write
+          this.pending = text.slice(i);
+          return;
+        }
+        i = end;
+        continue;
+      }
+      switch (ch) {
+        case "\r":
+          this.x = 0;
+          break;
+        case "\n":
+        case "\x0b":
+        case "\x0c":
+          this.lineFeed();
+          break;
+        case "\b":
+          this.x = Math.max(0, this.x - 1);
+          break;
+        case "\t":
+          this.x = Math.min(this.cols - 1, (Math.floor(this.x / 8) + 1) * 8);
+          break;
+        case "\x07":
+          break;
+        default:
+          if (ch >= " ") this.put(ch);
+      }
+      i++;
+    }
+  }
+
+  put(ch) {
+    if (this.x >= this.cols) {
+      this.x = 0;
+      this.lineFeed();
+    }
+    this.lines[this.y][this.x] = { ch, style: this.style };
+    this.x++;
+  }
+
+  lineFeed() {
+    if (this.y === this.bottom) {
+      this.scrollUp(1);
+    } else if (this.y < this.rows - 1) {
+      this.y++;
+    }
+  }
+
+  scrollUp(count) {
+    for (let n = 0; n < count; n++) {
+      const [line] = this.lines.splice(this.top, 1);
+      if (this.top === 0) this.pushScrollback(line);
+      this.lines.splice(this.bottom, 0, this.blankLine());
+    }
+  }
+
+  scrollDown(count) {
+    for (let n = 0; n < count; n++) {
+      this.lines.splice(this.bottom, 1);
+      this.lines.splice(this.top, 0, this.blankLine());
+    }
+  }
+
+  // escape handles the sequence starting at text[i] and returns the index
+  // after it, or -1 when the sequence is not complete yet
+  escape(text, i) {
+    if (i + 1 >= text.length) return -1;
+    const kind = text[i + 1];
+    if (kind === "[") {
+      let j = i + 2;
+      while (j < text.length && !/[@-~]/.test(text[j])) j++;
+      if (j >= text.length) return -1;
+      this.csi(text.slice(i + 2, j), text[j]);
+      return j + 1;
+    }
+    if (kind === "]") {
+      // operating system commands, such as window titles, are ignored
+      for (let j = i + 2; j < text.length; j++) {
+        if (text[j] === "\x07") return j + 1;
+        if (text[j] === "\x1b" && text[j + 1] === "\\") return j + 2;
+      }
+      return -1;
+    }
+    if (kind === "(" || kind === ")") {
+      return i + 2 < text.length ? i + 3 : -1;
+    }
+    switch (kind) {
+      case "7":
+        this.saveCursor();
+        break;
+      case "8":
+        this.restoreCursor();
+        break;
+      case "D":
+        this.lineFeed();
+        break;
+      case "E":
+        this.x = 0;
+        this.lineFeed();
+        break;
+      case "M":
+        if (this.y === this.top) this.scrollDown(1);
+        else this.y = Math.max(0, this.y - 1);
+        break;
+      case "c":
+        this.style = defaultStyle();
+        this.lines = this.blankLines(this.rows);
+        this.x = 0;
+        this.y = 0;
+        break;
+    }
+    return i + 2;
+  }
+
+  saveCursor() {
+    this.saved = { x: this.x, y: this.y, style: this.style };
+  }
+
+  restoreCursor() {
+    if (!this.saved) return;
+    this.x = this.saved.x;
+    this.y = this.saved.y;
+    this.style = this.saved.style;
+  }
+
+  csi(params, final) {
+    const priv = params.startsWith("?");
+    const args = (priv ? params.slice(1) : params)
+      .split(";")
+      .map((v) => (v === "" ? NaN : Number(v)));
+    const arg = (n, def = 1) =>
+      Number.isNaN(args[n]) || args[n] === undefined ? def : args[n];
+    const clampY = (y) => Math.max(0, Math.min(this.rows - 1, y));
+    const clampX = (x) => Math.max(0, Math.min(this.cols - 1, x));
+    if (priv) {
+      if (final === "h" || final === "l") this.privateMode(args, final === "h");
+      return;
+    }
+    switch (final) {
+      case "A":
+        this.y = Math.max(this.top, this.y - arg(0));
+        break;
+      case "B":
+        this.y = Math.min(this.bottom, this.y + arg(0));
+        break;
+      case "C":
+        this.x = clampX(this.x + arg(0));
+        break;
+      case "D":
+        this.x = clampX(Math.min(this.x, this.cols - 1) - arg(0));
+        break;
+      case "E":
+        this.x = 0;
+        this.y = clampY(this.y + arg(0));
+        break;
+      case "F":
+        this.x = 0;
+        this.y = clampY(this.y - arg(0));
+        break;
+      case "G":
+      case "`":
+        this.x = clampX(arg(0) - 1);
+        break;
+      case "d":
+        this.y = clampY(arg(0) - 1);
+        break;
+      case "H":
+      case "f":
+        this.y = clampY(arg(0) - 1);
+        this.x = clampX(arg(1) - 1);
+        break;
+      case "J":
+        this.eraseDisplay(arg(0, 0));
+        break;
+      case "K":
+        this.eraseLine(arg(0, 0));
+        break;
+      case "L":
+        for (let n = 0; n < arg(0); n++) {
+          this.lines.splice(this.bottom, 1);
+          this.lines.splice(this.y, 0, this.blankLine());
+        }
+        break;
+      case "M":
+        for (let n = 0; n < arg(0); n++) {
+          this.lines.splice(this.y, 1);
+          this.lines.splice(this.bottom, 0, this.blankLine());
+        }
+        break;
+      case "P": {
+        const line = this.lines[this.y];
+        line.splice(this.x, arg(0));
+        while (line.length < this.cols) line.push(blankCell(this.style));
+        break;
+      }
+      case "@": {
+        const line = this.lines[this.y];
+        for (let n = 0; n < arg(0); n++) line.splice(this.x, 0, blankCell(this.style));
+        line.length = this.cols;
+        break;
+      }
+      case "X":
+        for (let n = 0; n < arg(0) && this.x + n < this.cols; n++) {
+          this.lines[this.y][this.x + n] = blankCell(this.style);
+        }
+        break;
+      case "S":
+        this.scrollUp(arg(0));
+        break;
+      case "T":
+        this.scrollDown(arg(0));
+        break;
+      case "r":
+        this.top = clampY(arg(0) - 1);
+        this.bottom = clampY(arg(1, this.rows) - 1);
+        if (this.top >= this.bottom) {
+          this.top = 0;
+          this.bottom = this.rows - 1;
+        }
+        this.x = 0;
+        this.y = 0;
+        break;
+      case "s":
+        this.saveCursor();
+        break;
+      case "u":
+        this.restoreCursor();
+        break;
+      case "m":
+        this.sgr(args);
+        break;
+    }
+  }
+
+  privateMode(args, on) {
+    args.forEach((mode) => {
+      if (mode === 25) {
+        this.cursorVisible = on;
+      } else if (mode === 1049 || mode === 47 || mode === 1047) {
+        if (on && !this.alternate) {
+          this.saveCursor();
+          this.alternate = { lines: this.lines };
+          this.lines = this.blankLines(this.rows);
+        } else if (!on && this.alternate) {
+          this.lines = this.alternate.lines;
+          this.alternate = null;
+          this.restoreCursor();
+        }
+      }
+    });
+  }
+
+  eraseDisplay(mode) {
+    if (mode === 0) {
+      this.eraseLine(0);
+      for (let y = this.y + 1; y < this.rows; y++) this.lines[y] = this.blankLine();
+    } else if (mode === 1) {
+      this.eraseLine(1);
+      for (let y = 0; y < this.y; y++) this.lines[y] = this.blankLine();
+    } else {
+      this.lines = this.blankLines(this.rows);
+      if (mode === 3) this.scrollback = [];
+    }
+  }
+
+  eraseLine(mode) {
+    const line = this.lines[this.y];
+    const from = mode === 0 ? Math.min(this.x, this.cols) : 0;
+    const to = mode === 1 ? Math.min(this.x + 1, this.cols) : this.cols;
+    for (let x = from; x < to; x++) line[x] = blankCell(this.style);
+  }
+
+  sgr(args) {
+    const style = { ...this.style };
+    for (let i = 0; i < args.length; i++) {
+      const code = Number.isNaN(args[i]) ? 0 : args[i];
+      if (code === 0) Object.assign(style, defaultStyle());
+      else if (code === 1) style.bold = true;
+      else if (code === 2) style.dim = true;
+      else if (code === 3) style.italic = true;
+      else if (code === 4) style.underline = true;
+      else if (code === 7) style.inverse = true;
+      else if (code === 22) style.bold = style.dim = false;
+      else if (code === 23) style.italic = false;
+      else if (code === 24) style.underline = false;
+      else if (code === 27) style.inverse = false;
+      else if (code >= 30 && code <= 37) style.fg = ANSI_COLORS[code - 30];
+      else if (code === 39) style.fg = null;
+      else if (code >= 40 && code <= 47) style.bg = ANSI_COLORS[code - 40];
+      else if (code === 49) style.bg = null;
+      else if (code >= 90 && code <= 97) style.fg = ANSI_COLORS[code - 82];
+      else if (code >= 100 && code <= 107) style.bg = ANSI_COLORS[code - 92];
+      else if (code === 38 || code === 48) {
+        let color = null;
+        if (args[i + 1] === 5) {
+          color = color256(args[i + 2] || 0);
+          i += 2;
+        } else if (args[i + 1] === 2) {
+          color = `rgb(${args[i + 2] || 0},${args[i + 3] || 0},${args[i + 4] || 0})`;
+          i += 4;
+        }
+        if (code === 38) style.fg = color;
+        else style.bg = color;
+      }
+    }
+    this.style = style;
+  }
+
+  // toHtml renders the scrollback and the screen, marking the cursor
+  toHtml(showCursor) {
+    const render = (line, cursorX) => {
+      let html = "";
+      let run = "";
+      let runStyle = null;
+      const flush = () => {
+        if (!run) return;
+        html += runStyle ? `<span style="${runStyle}">${escapeText(run)}</span>` : escapeText(run);
+        run = "";
+      };
+      let end = line.length;
+      while (
+        end > cursorX + 1 &&
+        line[end - 1].ch === " " &&
+        !line[end - 1].style.bg &&
+        !line[end - 1].style.inverse
+      ) {
+        end--;
+      }
+      for (let x = 0; x < end; x++) {
+        const cell = line[x];
+        const css = cellCss(cell.style, x === cursorX);
+        if (css !== runStyle) {
+          flush();
+          runStyle = css;
+        }
+        run += cell.ch;
+      }
+      flush();
+      return html;
+    };
+    const cursorY = showCursor && this.cursorVisible ? this.y : -1;
+    const out = [];
+    if (!this.alternate) this.scrollback.forEach((line) => out.push(render(line, -1)));
+    this.lines.forEach((line, y) =>
+      out.push(render(line, y === cursorY ? Math.min(this.x, this.cols - 1) : -1)),
+    );
+    return out.join("\n");
+  }
+}
+
+function cellCss(style, cursor) {
+  let fg = style.fg;
+  let bg = style.bg;
+  if (style.inverse !== cursor) {
+    fg = bg || "var(--background-color)";
+    bg = style.fg || "var(--text-color)";
+  }
+  const parts = [];
+  if (fg) parts.push(`color:${fg}`);
+  if (bg) parts.push(`background:${bg}`);
+  if (style.bold) parts.push("font-weight:bold");
+  if (style.dim) parts.push("opacity:0.7");
+  if (style.italic) parts.push("font-style:italic");
+  if (style.underline) parts.push("text-decoration:underline");
+  return parts.length ? parts.join(";") : null;
+}
+
+const KEY_SEQUENCES = {
+  Enter: "\r",
+  Backspace: "\x7f",
+  Tab: "\t",
+  Escape: "\x1b",
+  ArrowUp: "\x1b[A",
+  ArrowDown: "\x1b[B",
+  ArrowRight: "\x1b[C",
+  ArrowLeft: "\x1b[D",
+  Home: "\x1b[H",
+  End: "\x1b[F",
+  Insert: "\x1b[2~",
+  Delete: "\x1b[3~",
+  PageUp: "\x1b[5~",
+  PageDown: "\x1b[6~",
+  F1: "\x1bOP",
+  F2: "\x1bOQ",
+  F3: "\x1bOR",
+  F4: "\x1bOS",
+};
+
+// terminalKeyInput returns what a key press sends to the shell, or "" for keys
+// the terminal leaves to the browser, such as Ctrl+Shift+C to copy
+export function terminalKeyInput(e) {
+  if (e.metaKey || (e.ctrlKey && e.shiftKey)) return "";
+  if (e.ctrlKey && !e.altKey && e.key.length === 1) {
+    const code = e.key.toUpperCase().charCodeAt(0);
+    if (code >= 64 && code <= 95) return String.fromCharCode(code - 64);
+    if (e.key === " ") return "\x00";
+    return "";
+  }
+  const sequence = KEY_SEQUENCES[e.key];
+  if (sequence) return e.shiftKey && e.key === "Tab" ? "\x1b[Z" : sequence;
+  if (e.key.length === 1) return e.altKey ? "\x1b" + e.key : e.key;
+  return "";
+}
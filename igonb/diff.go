@@ -0,0 +1,469 @@
+package igonb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CellChangeKind describes how a cell differs between two notebook versions
+type CellChangeKind string
+
+const (
+	CellAdded    CellChangeKind = "added"
+	CellRemoved  CellChangeKind = "removed"
+	CellModified CellChangeKind = "modified"
+	CellMoved    CellChangeKind = "moved"
+)
+
+const (
+	// diffContextLines is the number of unchanged lines shown around a change
+	diffContextLines = 3
+	// maxLCSCells bounds the LCS table; larger rewrites are treated as replacements
+	maxLCSCells = 4_000_000
+)
+
+// CellDiff is one changed cell. OldIndex and NewIndex are 0-based and -1 when the
+// cell does not exist on that side. A modified cell that also moved has Moved set.
+// MetadataDiff compares the cell metadata rendered as indented JSON.
+type CellDiff struct {
+	Kind         CellChangeKind `json:"kind"`
+	ID           string         `json:"id,omitempty"`
+	OldIndex     int            `json:"oldIndex"`
+	NewIndex     int            `json:"newIndex"`
+	OldLanguage  string         `json:"oldLanguage,omitempty"`
+	Language     string         `json:"language"`
+	Moved        bool           `json:"moved,omitempty"`
+	SourceDiff   string         `json:"sourceDiff,omitempty"`
+	OutputDiff   string         `json:"outputDiff,omitempty"`
+	MetadataDiff string         `json:"metadataDiff,omitempty"`
+}
+
+// NotebookDiff lists the changed cells of two notebook versions in the order of the new version
+type NotebookDiff struct {
+	Cells []CellDiff `json:"cells"`
+}
+
+// Diff compares two notebooks cell by cell. Cells are matched by ID; cells without an
+// ID are aligned by content. Either notebook may be nil.
+func Diff(a, b *Notebook) *NotebookDiff {
+	var oldCells, newCells []Cell
+	if a != nil {
+		oldCells = a.Cells
+	}
+	if b != nil {
+		newCells = b.Cells
+	}
+	match := matchCells(oldCells, newCells)
+	moved := movedCells(match)
+
+	diff := &NotebookDiff{Cells: []CellDiff{}}
+	matchedNew := make(map[int]int, len(match))
+	for i, j := range match {
+		if j >= 0 {
+			matchedNew[j] = i
+		}
+	}
+	for i, cell := range oldCells {
+		if match[i] < 0 {
+			diff.Cells = append(diff.Cells, CellDiff{
+				Kind:       CellRemoved,
+				ID:         cell.ID,
+				OldIndex:   i,
+				NewIndex:   -1,
+				Language:   cell.Language,
+				SourceDiff: lineDiff(cell.Source, ""),
+				OutputDiff: lineDiff(cellOutputText(cell), ""),
+			})
+		}
+	}
+	for j, cell := range newCells {
+		i, ok := matchedNew[j]
+		if !ok {
+			diff.Cells = append(diff.Cells, CellDiff{
+				Kind:       CellAdded,
+				ID:         cell.ID,
+				OldIndex:   -1,
+				NewIndex:   j,
+				Language:   cell.Language,
+				SourceDiff: lineDiff("", cell.Source),
+				OutputDiff: lineDiff("", cellOutputText(cell)),
+			})
+			continue
+		}
+		old := oldCells[i]
+		change := CellDiff{
+			ID:           cell.ID,
+			OldIndex:     i,
+			NewIndex:     j,
+			Language:     cell.Language,
+			Moved:        moved[i],
+			SourceDiff:   lineDiff(old.Source, cell.Source),
+			OutputDiff:   lineDiff(cellOutputText(old), cellOutputText(cell)),
+			MetadataDiff: lineDiff(cellMetadataText(old), cellMetadataText(cell)),
+		}
+		if old.Language != cell.Language {
+			change.OldLanguage = old.Language
+		}
+		switch {
+		case change.SourceDiff != "" || change.OutputDiff != "" || change.MetadataDiff != "" || change.OldLanguage != "":
+			change.Kind = CellModified
+		case change.Moved:
+			change.Kind = CellMoved
+			change.Moved = false
+		default:
+			continue
+		}
+		diff.Cells = append(diff.Cells, change)
+	}
+	// Removed cells are listed where they used to be relative to the new version
+	sort.SliceStable(diff.Cells, func(x, y int) bool {
+		return diffSortKey(diff.Cells[x], match, moved) < diffSortKey(diff.Cells[y], match, moved)
+	})
+	return diff
+}
+
+// diffSortKey places a change at its new index, and a removed cell just after the
+// new position of the unmoved cell that preceded it
+func diffSortKey(c CellDiff, match []int, moved map[int]bool) float64 {
+	if c.NewIndex >= 0 {
+		return float64(c.NewIndex)
+	}
+	for i := c.OldIndex - 1; i >= 0; i-- {
+		if match[i] >= 0 && !moved[i] {
+			return float64(match[i]) + 0.5
+		}
+	}
+	return -0.5
+}
+
+// HasChanges reports whether any cell differs
+func (d *NotebookDiff) HasChanges() bool {
+	return d != nil && len(d.Cells) > 0
+}
+
+// String renders the diff as text with one section per changed cell
+func (d *NotebookDiff) String() string {
+	if !d.HasChanges() {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range d.Cells {
+		index := c.NewIndex
+		if c.Kind == CellRemoved {
+			index = c.OldIndex
+		}
+		fmt.Fprintf(&sb, "## cell %d [%s]", index+1, c.Language)
+		if c.ID != "" {
+			fmt.Fprintf(&sb, " %s", c.ID)
+		}
+		switch {
+		case c.Kind == CellMoved:
+			fmt.Fprintf(&sb, " moved from %d", c.OldIndex+1)
+		case c.Moved:
+			fmt.Fprintf(&sb, " %s, moved from %d", c.Kind, c.OldIndex+1)
+		default:
+			fmt.Fprintf(&sb, " %s", c.Kind)
+		}
+		sb.WriteByte('\n')
+		if c.OldLanguage != "" {
+			fmt.Fprintf(&sb, "language: %s -> %s\n", c.OldLanguage, c.Language)
+		}
+		if c.SourceDiff != "" {
+			sb.WriteString(c.SourceDiff)
+		}
+		if c.OutputDiff != "" {
+			sb.WriteString("### output\n")
+			sb.WriteString(c.OutputDiff)
+		}
+		if c.MetadataDiff != "" {
+			sb.WriteString("### metadata\n")
+			sb.WriteString(c.MetadataDiff)
+		}
+	}
+	return sb.String()
+}
+
+// cellMetadataText is the metadata of a cell as compared by Diff, with sorted keys
+func cellMetadataText(c Cell) string {
+	if len(c.Metadata) == 0 {
+		return ""
+	}
+	data, err := json.MarshalIndent(c.Metadata, "", "  ")
+	if err != nil {
+		return fmt.Sprint(c.Metadata)
+	}
+	return string(data)
+}
+
+// cellOutputText is the output of a cell as compared by Diff, including its error
+func cellOutputText(c Cell) string {
+	if c.Error == "" {
+		return c.Output
+	}
+	if c.Output == "" {
+		return "error: " + c.Error
+	}
+	return strings.TrimSuffix(c.Output, "\n") + "\nerror: " + c.Error
+}
+
+// matchCells pairs the cells of two versions, returning for each cell of a the index
+// of the same cell in b or -1. Cells are matched by ID first. The others, such as
+// cells whose ID was generated from content that has since changed, are aligned by
+// language and source, and unmatched cells of the same language between two
+// aligned cells are paired as modifications.
+func matchCells(a, b []Cell) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	byID := make(map[string]int, len(b))
+	for j, cell := range b {
+		if cell.ID != "" {
+			if _, dup := byID[cell.ID]; !dup {
+				byID[cell.ID] = j
+			}
+		}
+	}
+	taken := make([]bool, len(b))
+	for i, cell := range a {
+		if cell.ID == "" {
+			continue
+		}
+		if j, ok := byID[cell.ID]; ok && !taken[j] {
+			match[i] = j
+			taken[j] = true
+		}
+	}
+
+	// Align the cells left on both sides by content
+	var restA, restB []int
+	for i := range a {
+		if match[i] < 0 {
+			restA = append(restA, i)
+		}
+	}
+	for j := range b {
+		if !taken[j] {
+			restB = append(restB, j)
+		}
+	}
+	keys := func(cells []Cell, idx []int) []string {
+		out := make([]string, len(idx))
+		for k, i := range idx {
+			out[k] = cells[i].Language + "\x00" + cells[i].Source
+		}
+		return out
+	}
+	aligned := lcsMatch(keys(a, restA), keys(b, restB))
+	prevA, prevB := 0, 0
+	pairGap := func(endA, endB int) {
+		for x, y := prevA, prevB; x < endA && y < endB; x++ {
+			k := y
+			for k < endB && b[restB[k]].Language != a[restA[x]].Language {
+				k++
+			}
+			if k < endB {
+				match[restA[x]] = restB[k]
+				y = k + 1
+			}
+		}
+	}
+	for x, y := range aligned {
+		if y < 0 {
+			continue
+		}
+		pairGap(x, y)
+		match[restA[x]] = restB[y]
+		prevA, prevB = x+1, y+1
+	}
+	pairGap(len(restA), len(restB))
+	return match
+}
+
+// movedCells marks the matched cells that changed position: those outside the
+// longest run of matched cells that kept their relative order
+func movedCells(match []int) map[int]bool {
+	var olds []int
+	for i, j := range match {
+		if j >= 0 {
+			olds = append(olds, i)
+		}
+	}
+	// Longest increasing subsequence of new indices, in old order
+	n := len(olds)
+	length := make([]int, n)
+	prev := make([]int, n)
+	best := -1
+	for x := 0; x < n; x++ {
+		length[x], prev[x] = 1, -1
+		for y := 0; y < x; y++ {
+			if match[olds[y]] < match[olds[x]] && length[y]+1 > length[x] {
+				length[x], prev[x] = length[y]+1, y
+			}
+		}
+		if best < 0 || length[x] > length[best] {
+			best = x
+		}
+	}
+	stable := make(map[int]bool, n)
+	for x := best; x >= 0; x = prev[x] {
+		stable[olds[x]] = true
+	}
+	moved := make(map[int]bool)
+	for _, i := range olds {
+		if !stable[i] {
+			moved[i] = true
+		}
+	}
+	return moved
+}
+
+// lcsMatch returns for each element of a the index of the element of b it is aligned
+// with in a longest common subsequence, or -1
+func lcsMatch(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+	ma := a[prefix : len(a)-suffix]
+	mb := b[prefix : len(b)-suffix]
+	if len(ma) == 0 || len(mb) == 0 || len(ma)*len(mb) > maxLCSCells {
+		return match
+	}
+
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(ma) && j < len(mb); {
+		switch {
+		case ma[i] == mb[j]:
+			match[prefix+i] = prefix + j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineDiff returns a unified diff of two texts without file headers, or "" when they are equal
+func lineDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a, b := splitLines(before), splitLines(after)
+	match := lcsMatch(a, b)
+
+	type op struct {
+		kind byte
+		line string
+		ai   int
+		bi   int
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	j := 0
+	for i, m := range match {
+		if m < 0 {
+			ops = append(ops, op{'-', a[i], i, j})
+			continue
+		}
+		for ; j < m; j++ {
+			ops = append(ops, op{'+', b[j], i, j})
+		}
+		ops = append(ops, op{' ', a[i], i, j})
+		j++
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j], len(a), j})
+	}
+
+	var sb strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		start := max(k-diffContextLines, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				end += min(diffContextLines, run-end)
+				break
+			}
+			end = run
+		}
+		oldCount, newCount := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				oldCount++
+			}
+			if o.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(ops[start].ai, oldCount), hunkRange(ops[start].bi, newCount))
+		for _, o := range ops[start:end] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		k = end
+	}
+	if sb.Len() == 0 {
+		// Only a trailing newline differs
+		return "@@ -0,0 +0,0 @@\n\\ trailing newline changed\n"
+	}
+	return sb.String()
+}
+
+// hunkRange formats a unified diff range from a 0-based start
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
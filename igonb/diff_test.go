@@ -0,0 +1,142 @@
+package igonb
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCell(id, language, source string) Cell {
+	return Cell{ID: id, Language: language, Source: source}
+}
+
+func diffKinds(d *NotebookDiff) []string {
+	kinds := make([]string, len(d.Cells))
+	for i, c := range d.Cells {
+		kinds[i] = string(c.Kind) + " " + c.ID
+	}
+	return kinds
+}
+
+func TestDiffMatchesCellsByID(t *testing.T) {
+	old := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x := 1"),
+		testCell("b", "go", "y := 2"),
+		testCell("c", "markdown", "# Title"),
+		testCell("d", "go", "z := 3"),
+	}}
+	updated := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x := 1"),
+		testCell("c", "markdown", "# Title"),
+		testCell("b", "go", "y := 3"),
+		testCell("e", "python", "print(1)"),
+	}}
+	d := Diff(old, updated)
+	want := []string{"moved c", "modified b", "removed d", "added e"}
+	if got := diffKinds(d); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+	moved, modified, removed, added := d.Cells[0], d.Cells[1], d.Cells[2], d.Cells[3]
+	if moved.OldIndex != 2 || moved.NewIndex != 1 || moved.SourceDiff != "" {
+		t.Errorf("moved cell = %+v", moved)
+	}
+	if modified.OldIndex != 1 || modified.NewIndex != 2 || modified.SourceDiff != "@@ -1 +1 @@\n-y := 2\n+y := 3\n" {
+		t.Errorf("modified cell = %+v", modified)
+	}
+	if removed.OldIndex != 3 || removed.NewIndex != -1 || removed.SourceDiff != "@@ -1 +0,0 @@\n-z := 3\n" {
+		t.Errorf("removed cell = %+v", removed)
+	}
+	if added.OldIndex != -1 || added.NewIndex != 3 || added.Language != "python" {
+		t.Errorf("added cell = %+v", added)
+	}
+	text := d.String()
+	for _, line := range []string{"## cell 2 [markdown] c moved from 3", "## cell 3 [go] b modified", "## cell 4 [go] d removed", "## cell 4 [python] e added"} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("String() lacks %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestDiffAlignsCellsWithoutIDs(t *testing.T) {
+	old := &Notebook{Cells: []Cell{
+		testCell("", "go", "a"),
+		testCell("", "go", "b"),
+		testCell("", "markdown", "notes"),
+		testCell("", "go", "c"),
+	}}
+	updated := &Notebook{Cells: []Cell{
+		testCell("", "go", "a"),
+		testCell("", "go", "B"),
+		testCell("", "go", "c"),
+		testCell("", "go", "d"),
+	}}
+	d := Diff(old, updated)
+	if len(d.Cells) != 3 {
+		t.Fatalf("changes = %+v, want 3", d.Cells)
+	}
+	// The unmatched go cell between two aligned cells is a modification, not a replacement
+	if c := d.Cells[0]; c.Kind != CellModified || c.OldIndex != 1 || c.NewIndex != 1 {
+		t.Errorf("first change = %+v, want cell 2 modified", c)
+	}
+	if c := d.Cells[1]; c.Kind != CellRemoved || c.OldIndex != 2 || c.Language != "markdown" {
+		t.Errorf("second change = %+v, want the markdown cell removed", c)
+	}
+	if c := d.Cells[2]; c.Kind != CellAdded || c.NewIndex != 3 {
+		t.Errorf("third change = %+v, want cell 4 added", c)
+	}
+}
+
+func TestDiffMetadataOutputAndLanguage(t *testing.T) {
+	old := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x"),
+		{ID: "b", Language: "go", Source: "y", Output: "1\n"},
+		testCell("c", "go", "z"),
+	}}
+	updated := &Notebook{Cells: []Cell{
+		{ID: "a", Language: "go", Source: "x", Metadata: map[string]any{MetaSkip: true}},
+		{ID: "b", Language: "go", Source: "y", Output: "1\n", Error: "boom"},
+		testCell("c", "python", "z"),
+	}}
+	d := Diff(old, updated)
+	if len(d.Cells) != 3 {
+		t.Fatalf("changes = %+v, want 3", d.Cells)
+	}
+	if c := d.Cells[0]; c.Kind != CellModified || c.SourceDiff != "" || !strings.Contains(c.MetadataDiff, `+  "skip": true`) {
+		t.Errorf("metadata change = %+v", c)
+	}
+	if c := d.Cells[1]; c.Kind != CellModified || c.OutputDiff != "@@ -1 +1,2 @@\n 1\n+error: boom\n" {
+		t.Errorf("output change = %+v", c)
+	}
+	if c := d.Cells[2]; c.Kind != CellModified || c.OldLanguage != "go" || c.Language != "python" {
+		t.Errorf("language change = %+v", c)
+	}
+}
+
+func TestDiffWithoutChanges(t *testing.T) {
+	nb := &Notebook{Cells: []Cell{testCell("a", "go", "x"), testCell("", "go", "y")}}
+	if d := Diff(nb, nb); d.HasChanges() || d.String() != "" {
+		t.Errorf("diff of a notebook with itself = %+v", d.Cells)
+	}
+	if got := diffKinds(Diff(nil, nb)); strings.Join(got, ",") != "added a,added " {
+		t.Errorf("diff from nil = %v", got)
+	}
+	if got := diffKinds(Diff(nb, nil)); strings.Join(got, ",") != "removed a,removed " {
+		t.Errorf("diff to nil = %v", got)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	for _, tc := range []struct {
+		before, after, want string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\n", "a\nc\n", "@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+		{"", "new\n", "@@ -0,0 +1 @@\n+new\n"},
+		{"a", "a\n", "@@ -0,0 +0,0 @@\n\\ trailing newline changed\n"},
+		// Changes more than twice the context apart get hunks of their own
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n", "x\n2\n3\n4\n5\n6\n7\n8\ny\n", "@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -6,4 +6,4 @@\n 6\n 7\n 8\n-9\n+y\n"},
+	} {
+		if got := lineDiff(tc.before, tc.after); got != tc.want {
+			t.Errorf("lineDiff(%q, %q) = %q, want %q", tc.before, tc.after, got, tc.want)
+		}
+	}
+}
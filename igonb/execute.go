@@ -12,9 +12,11 @@ import (
 	"os"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/HazelnutParadise/insyra"
 	"github.com/traefik/yaegi/interp"
@@ -22,10 +24,16 @@ import (
 )
 
 type CellResult struct {
-	Index    int    `json:"index"`
-	Language string `json:"language"`
-	Output   string `json:"output"`
-	Error    string `json:"error,omitempty"`
+	Index     int            `json:"index"`
+	Language  string         `json:"language"`
+	Output    string         `json:"output"`
+	Error     string         `json:"error,omitempty"`
+	Execution *CellExecution `json:"execution,omitempty"`
+	// Skipped is MetaSkip or MetaFrozen when the cell was not run; the result then
+	// carries the output the cell already had
+	Skipped string `json:"skipped,omitempty"`
+	// Transfers reports the variables the cell passed between Go and Python
+	Transfers []Transfer `json:"transfers,omitempty"`
 }
 
 type Executor struct {
@@ -36,11 +44,28 @@ type Executor struct {
 	sharedMu       sync.Mutex
 	sharedVars     map[string]any
 	pythonRunID    int
+	executionCount int
 	pythonState    string
 	pythonDefs     []pythonDef
 	stopRequested  bool
+	cellTimedOut   bool
 	goCancel       context.CancelFunc
 	pythonCancel   context.CancelFunc
+	// cellCancel stops the running shell or sql cell
+	cellCancel context.CancelFunc
+	// onOutput receives the output of shell cells while they run
+	onOutput func(index int, output string)
+	// transferWarned holds the reason a variable was last not passed between
+	// Go and Python, so each warning is shown once
+	transferWarned map[string]string
+	// pythonExports holds the values Python cells last exported
+	pythonExports map[string]any
+	// pythonArrow is set once a Python cell reported that it can read Arrow files
+	pythonArrow bool
+	// transfers collects the variable transfers of the running cell
+	transfers []Transfer
+	// pythonEnv is the virtual environment Python cells use, if any
+	pythonEnv string
 }
 
 type GoSetupFunc func(*interp.Interpreter) error
@@ -57,6 +82,9 @@ const (
 
 var ErrExecutionStopped = errors.New("execution stopped")
 
+// ErrCellTimeout is returned for a cell that ran longer than its MetaTimeout
+var ErrCellTimeout = errors.New("cell timed out")
+
 func NewExecutor(goSetup GoSetupFunc) (*Executor, error) {
 	exec := &Executor{
 		goImports:  make(map[string]bool),
@@ -123,6 +151,9 @@ func (e *Executor) RunNotebookCellWithCallback(nb *Notebook, index int, onResult
 	cell := nb.Cells[index]
 	lang := NormalizeLanguage(cell.Language)
 	results := make([]CellResult, 0, 1)
+	if result, skipped := skippedResult(index, lang, cell); skipped {
+		return emit(result, results), nil
+	}
 
 	switch lang {
 	case "markdown":
@@ -131,21 +162,18 @@ func (e *Executor) RunNotebookCellWithCallback(nb *Notebook, index int, onResult
 			Language: lang,
 			Output:   "",
 		}, results)
-	case "go":
-		output, err := e.runGoCell(cell.Source)
+	case "go", "shell", "sql":
 		result := CellResult{
 			Index:    index,
 			Language: lang,
-			Output:   output,
 		}
-		if err != nil {
-			result.Error = err.Error()
-			results = emit(result, results)
+		err := e.runCell(&result, cell, e.codeRunner(nb, lang, index))
+		results = emit(result, results)
+		if stopsRun(cell, err) {
 			return results, err
 		}
-		results = emit(result, results)
 	case "python":
-		groupResults, err := e.runPythonGroup([]Cell{cell}, []int{index})
+		groupResults, err := e.runPythonGroup(nb, []Cell{cell}, []int{index})
 		for _, result := range groupResults {
 			results = emit(result, results)
 		}
@@ -197,6 +225,10 @@ func (e *Executor) RunNotebookWithCallback(nb *Notebook, index int, onResult fun
 		}
 		cell := nb.Cells[idx]
 		lang := NormalizeLanguage(cell.Language)
+		if result, skipped := skippedResult(idx, lang, cell); skipped {
+			emit(result)
+			continue
+		}
 
 		switch lang {
 		case "markdown":
@@ -205,19 +237,16 @@ func (e *Executor) RunNotebookWithCallback(nb *Notebook, index int, onResult fun
 				Language: lang,
 				Output:   "",
 			})
-		case "go":
-			output, err := e.runGoCell(cell.Source)
+		case "go", "shell", "sql":
 			result := CellResult{
 				Index:    idx,
 				Language: lang,
-				Output:   output,
 			}
-			if err != nil {
-				result.Error = err.Error()
-				emit(result)
+			err := e.runCell(&result, cell, e.codeRunner(nb, lang, idx))
+			emit(result)
+			if stopsRun(cell, err) {
 				return results, err
 			}
-			emit(result)
 		case "python":
 			group := make([]Cell, 0)
 			groupIndices := make([]int, 0)
@@ -226,6 +255,10 @@ func (e *Executor) RunNotebookWithCallback(nb *Notebook, index int, onResult fun
 					break
 				}
 				scanLang := NormalizeLanguage(nb.Cells[scan].Language)
+				if _, skipped := skippedResult(scan, scanLang, nb.Cells[scan]); skipped {
+					// Reported when the loop reaches it, like markdown cells
+					continue
+				}
 				if scanLang == "python" {
 					group = append(group, nb.Cells[scan])
 					groupIndices = append(groupIndices, scan)
@@ -242,7 +275,7 @@ func (e *Executor) RunNotebookWithCallback(nb *Notebook, index int, onResult fun
 				break
 			}
 
-			groupResults, err := e.runPythonGroup(group, groupIndices)
+			groupResults, err := e.runPythonGroup(nb, group, groupIndices)
 			for _, result := range groupResults {
 				emit(result)
 			}
@@ -263,13 +296,121 @@ func (e *Executor) RunNotebookWithCallback(nb *Notebook, index int, onResult fun
 	return results, nil
 }
 
-func (e *Executor) runGoCell(code string) (string, error) {
+// skippedResult returns the result of a code cell that is skipped or frozen instead of run
+func skippedResult(index int, lang string, cell Cell) (CellResult, bool) {
+	reason := ""
+	switch {
+	case lang == "markdown":
+		return CellResult{}, false
+	case cell.Frozen():
+		reason = MetaFrozen
+	case cell.Skipped():
+		reason = MetaSkip
+	default:
+		return CellResult{}, false
+	}
+	return CellResult{
+		Index:     index,
+		Language:  lang,
+		Output:    cell.Output,
+		Error:     cell.Error,
+		Execution: cell.Execution,
+		Skipped:   reason,
+	}, true
+}
+
+// stopsRun reports whether a cell's error ends a run. Cells tagged
+// TagRaisesException are expected to fail, unless the run was stopped.
+func stopsRun(cell Cell, err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrExecutionStopped) || !cell.HasTag(TagRaisesException)
+}
+
+// codeRunner returns the function that runs the source of a go, shell or sql cell
+func (e *Executor) codeRunner(nb *Notebook, lang string, index int) func(string) (string, error) {
+	switch lang {
+	case "shell":
+		return func(code string) (string, error) {
+			return e.runShellCell(index, code)
+		}
+	case "sql":
+		return func(code string) (string, error) {
+			return e.runSQLCell(nb, code)
+		}
+	case "python":
+		return func(code string) (string, error) {
+			return e.runPythonCell(nb, code)
+		}
+	}
+	return func(code string) (string, error) {
+		return e.runGoCell(nb, code)
+	}
+}
+
+// runCell runs the source of a code cell within its timeout and records its
+// output, error and execution in result
+func (e *Executor) runCell(result *CellResult, cell Cell, run func(string) (string, error)) error {
+	e.sharedMu.Lock()
+	e.executionCount++
+	count := e.executionCount
+	e.sharedMu.Unlock()
+
+	var timer *time.Timer
+	fired := make(chan struct{})
+	timeout := cell.Timeout()
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			e.timeoutCell()
+			close(fired)
+		})
+	}
+	e.takeTransfers()
+	started := time.Now()
+	output, err := run(cell.Source)
+	ended := time.Now()
+	result.Transfers = e.takeTransfers()
+	if timer != nil {
+		if !timer.Stop() {
+			<-fired
+		}
+		e.sharedMu.Lock()
+		timedOut := e.cellTimedOut
+		e.cellTimedOut = false
+		e.sharedMu.Unlock()
+		if timedOut && err != nil {
+			err = fmt.Errorf("%w after %s", ErrCellTimeout, timeout)
+		}
+	}
+	result.Output = output
+	execution := &CellExecution{
+		Count:      count,
+		Status:     StatusOK,
+		StartedAt:  started.UTC(),
+		EndedAt:    ended.UTC(),
+		DurationMs: ended.Sub(started).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		execution.Status = StatusError
+		if errors.Is(err, ErrExecutionStopped) {
+			execution.Status = StatusStopped
+		} else if errors.Is(err, ErrCellTimeout) {
+			execution.Status = StatusTimeout
+		}
+	}
+	result.Execution = execution
+	return err
+}
+
+func (e *Executor) runGoCell(nb *Notebook, code string) (string, error) {
 	if strings.TrimSpace(code) == "" {
 		return "", nil
 	}
 
 	code = normalizeGoRangeLoops(code)
-	defer e.syncSharedFromGo(code)
+	defer e.syncSharedFromGo(code, variableTransferMode(nb))
 	segments := expandGoSegments(splitGoSegments(code))
 	if len(segments) == 0 {
 		return "", nil
@@ -346,13 +487,20 @@ func (e *Executor) Close() error {
 	e.sharedMu.Lock()
 	e.sharedVars = make(map[string]any)
 	e.pythonRunID = 0
+	e.executionCount = 0
 	e.pythonState = ""
 	e.pythonDefs = nil
+	e.transferWarned = nil
+	e.pythonExports = nil
+	e.pythonArrow = false
+	e.transfers = nil
 	e.stopRequested = false
 	goCancel = e.goCancel
 	e.goCancel = nil
 	pythonCancel = e.pythonCancel
 	e.pythonCancel = nil
+	cellCancel := e.cellCancel
+	e.cellCancel = nil
 	e.sharedMu.Unlock()
 	if goCancel != nil {
 		goCancel()
@@ -360,6 +508,9 @@ func (e *Executor) Close() error {
 	if pythonCancel != nil {
 		pythonCancel()
 	}
+	if cellCancel != nil {
+		cellCancel()
+	}
 	return nil
 }
 
@@ -373,6 +524,26 @@ func (e *Executor) RequestStop() {
 	e.stopRequested = true
 	goCancel = e.goCancel
 	pythonCancel = e.pythonCancel
+	cellCancel := e.cellCancel
+	e.sharedMu.Unlock()
+	if goCancel != nil {
+		goCancel()
+	}
+	if pythonCancel != nil {
+		pythonCancel()
+	}
+	if cellCancel != nil {
+		cellCancel()
+	}
+}
+
+// timeoutCell interrupts the running cell without stopping the run
+func (e *Executor) timeoutCell() {
+	e.sharedMu.Lock()
+	e.cellTimedOut = true
+	goCancel := e.goCancel
+	pythonCancel := e.pythonCancel
+	cellCancel := e.cellCancel
 	e.sharedMu.Unlock()
 	if goCancel != nil {
 		goCancel()
@@ -380,6 +551,9 @@ func (e *Executor) RequestStop() {
 	if pythonCancel != nil {
 		pythonCancel()
 	}
+	if cellCancel != nil {
+		cellCancel()
+	}
 }
 
 func (e *Executor) ClearStop() {
@@ -427,13 +601,31 @@ func (e *Executor) clearPythonCancel(cancel context.CancelFunc) {
 	e.sharedMu.Unlock()
 }
 
+func (e *Executor) setCellCancel(cancel context.CancelFunc) {
+	if e == nil {
+		return
+	}
+	e.sharedMu.Lock()
+	e.cellCancel = cancel
+	e.sharedMu.Unlock()
+}
+
+func (e *Executor) clearCellCancel(cancel context.CancelFunc) {
+	if e == nil {
+		return
+	}
+	e.sharedMu.Lock()
+	e.cellCancel = nil
+	e.sharedMu.Unlock()
+}
+
 func (e *Executor) isStopRequested() bool {
 	if e == nil {
 		return false
 	}
 	e.sharedMu.Lock()
 	defer e.sharedMu.Unlock()
-	return e.stopRequested
+	return e.stopRequested || e.cellTimedOut
 }
 
 func (e *Executor) PreloadGoImports(paths []string) error {
@@ -604,6 +796,15 @@ func (e *Executor) setSharedVar(name string, value any) {
 	e.sharedMu.Unlock()
 }
 
+func (e *Executor) deleteSharedVar(name string) {
+	if e == nil {
+		return
+	}
+	e.sharedMu.Lock()
+	delete(e.sharedVars, name)
+	e.sharedMu.Unlock()
+}
+
 func (e *Executor) snapshotSharedVars() map[string]any {
 	if e == nil {
 		return nil
@@ -620,23 +821,92 @@ func (e *Executor) snapshotSharedVars() map[string]any {
 	return snapshot
 }
 
-func (e *Executor) syncSharedFromGo(code string) {
+// maxVariablePreview bounds the preview text of a session variable
+const maxVariablePreview = 200
+
+// Variable describes a value shared between the Go and Python cells of a session
+type Variable struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Preview string `json:"preview"`
+}
+
+// Variables returns the shared session variables sorted by name
+func (e *Executor) Variables() []Variable {
+	shared := e.snapshotSharedVars()
+	variables := make([]Variable, 0, len(shared))
+	for name, value := range shared {
+		variables = append(variables, Variable{
+			Name:    name,
+			Type:    fmt.Sprintf("%T", value),
+			Preview: previewVariable(value),
+		})
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// Variable returns the value of a shared session variable
+func (e *Executor) Variable(name string) (any, bool) {
+	if e == nil {
+		return nil, false
+	}
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	value, ok := e.sharedVars[name]
+	return value, ok
+}
+
+func previewVariable(value any) string {
+	switch v := value.(type) {
+	case insyra.IDataTable:
+		if v != nil {
+			rows, cols := v.Size()
+			return fmt.Sprintf("DataTable %d rows x %d columns", rows, cols)
+		}
+	case insyra.IDataList:
+		if v != nil {
+			return fmt.Sprintf("DataList of %d items", v.Len())
+		}
+	}
+	preview := []rune(fmt.Sprint(value))
+	if len(preview) > maxVariablePreview {
+		return string(preview[:maxVariablePreview]) + "…"
+	}
+	return string(preview)
+}
+
+// syncSharedFromGo shares the variables a Go cell exports with Python cells:
+// the ones named by //igonb:export and, in TransferAuto mode, the ones it assigns
+func (e *Executor) syncSharedFromGo(code string, mode string) {
 	if e == nil || e.goInterp == nil {
 		return
 	}
-	names := collectGoAssignedNames(code)
-	if len(names) == 0 {
-		return
+	exports := goExportNames(code)
+	names := exports
+	if mode != TransferExplicit {
+		names = collectGoAssignedNames(code)
+		for _, name := range exports {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
 	}
 	for _, name := range names {
 		if strings.HasPrefix(name, "__igonb") {
 			continue
 		}
+		transfer := Transfer{Name: name, From: "go", To: "python"}
 		value, err := e.goInterp.Eval(name)
 		if err != nil || !value.IsValid() || !value.CanInterface() {
+			if slices.Contains(exports, name) {
+				transfer.Skipped, transfer.Reason = true, "not defined"
+				e.recordTransfer(transfer)
+			}
 			continue
 		}
 		e.setSharedVar(name, value.Interface())
+		e.recordTransfer(transfer)
 	}
 }
 
@@ -824,7 +1094,7 @@ func captureStdIO(run func() error) (string, error) {
 	return pipeOutput, runErr
 }
 
-func (e *Executor) runPythonGroup(cells []Cell, indices []int) ([]CellResult, error) {
+func (e *Executor) runPythonGroup(nb *Notebook, cells []Cell, indices []int) ([]CellResult, error) {
 	if len(cells) == 0 {
 		return nil, nil
 	}
@@ -834,18 +1104,15 @@ func (e *Executor) runPythonGroup(cells []Cell, indices []int) ([]CellResult, er
 
 	results := make([]CellResult, 0, len(cells))
 	for i, cell := range cells {
-		output, runErr := e.runPythonCell(cell.Source)
 		result := CellResult{
 			Index:    indices[i],
 			Language: "python",
-			Output:   output,
 		}
-		if runErr != nil {
-			result.Error = runErr.Error()
-			results = append(results, result)
+		runErr := e.runCell(&result, cell, e.codeRunner(nb, "python", indices[i]))
+		results = append(results, result)
+		if stopsRun(cell, runErr) {
 			return results, runErr
 		}
-		results = append(results, result)
 	}
 
 	return results, nil
@@ -0,0 +1,103 @@
+package igonb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+	exec, err := NewExecutorWithSymbols(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { exec.Close() })
+	return exec
+}
+
+func TestRunNotebookSkipsAndFreezesCells(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		testCell("a", "go", "import \"fmt\"\nx := 1"),
+		{ID: "b", Language: "go", Source: "x = 2", Metadata: map[string]any{MetaSkip: true}},
+		{ID: "c", Language: "go", Source: "x = 3", Metadata: map[string]any{MetaTags: []any{TagSkipExecution}}},
+		{
+			ID: "d", Language: "go", Source: "x = 4\nfmt.Println(\"fresh\")", Output: "kept\n",
+			Execution: &CellExecution{Count: 7, Status: StatusOK},
+			Metadata:  map[string]any{MetaFrozen: true, MetaSkip: true},
+		},
+		testCell("e", "go", "fmt.Println(x)"),
+	}}
+	var streamed []CellResult
+	results, err := newTestExecutor(t).RunNotebookWithCallback(nb, -1, func(r CellResult) {
+		streamed = append(streamed, r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 || len(streamed) != 5 {
+		t.Fatalf("got %d results and %d callbacks, want 5", len(results), len(streamed))
+	}
+	for i, want := range []string{"", MetaSkip, MetaSkip, MetaFrozen, ""} {
+		if results[i].Skipped != want {
+			t.Errorf("cell %d skipped = %q, want %q", i+1, results[i].Skipped, want)
+		}
+	}
+	// A frozen cell reports the output and execution it already had
+	if frozen := results[3]; frozen.Output != "kept\n" || frozen.Execution == nil || frozen.Execution.Count != 7 {
+		t.Errorf("frozen cell result = %+v", frozen)
+	}
+	if results[1].Execution != nil {
+		t.Errorf("skipped cell got an execution %+v", results[1].Execution)
+	}
+	// Neither skipped nor frozen cells changed x
+	if last := results[4]; last.Output != "1\n" || last.Execution == nil || last.Execution.Count != 2 {
+		t.Errorf("last cell result = %+v, want output 1 as the second execution", last)
+	}
+}
+
+func TestRunNotebookCellTimeout(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		{ID: "a", Language: "go", Source: "import \"time\"\ntime.Sleep(time.Minute)", Metadata: map[string]any{MetaTimeout: "100ms"}},
+		testCell("b", "go", "y := 1"),
+	}}
+	results, err := newTestExecutor(t).RunNotebookWithCallback(nb, -1, nil)
+	if !errors.Is(err, ErrCellTimeout) {
+		t.Fatalf("err = %v, want ErrCellTimeout", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want the run to stop after the cell that timed out", len(results))
+	}
+	r := results[0]
+	if r.Execution == nil || r.Execution.Status != StatusTimeout || !strings.Contains(r.Error, "after 100ms") {
+		t.Errorf("result = %+v, execution %+v", r, r.Execution)
+	}
+}
+
+func TestRunNotebookContinuesAfterExpectedError(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		{ID: "a", Language: "go", Source: "undefinedName", Metadata: map[string]any{MetaTags: []any{TagRaisesException}}},
+		{ID: "b", Language: "go", Source: "import \"time\"\ntime.Sleep(time.Minute)", Metadata: map[string]any{
+			MetaTimeout: 0.1, MetaTags: []string{TagRaisesException},
+		}},
+		testCell("c", "go", "import \"fmt\"\nfmt.Println(\"ran\")"),
+		testCell("d", "go", "undefinedName"),
+		testCell("e", "go", "import \"fmt\"\nfmt.Println(\"not reached\")"),
+	}}
+	results, err := newTestExecutor(t).RunNotebookWithCallback(nb, -1, nil)
+	if err == nil || errors.Is(err, ErrCellTimeout) {
+		t.Fatalf("err = %v, want the error of cell 4", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	if results[0].Error == "" || results[0].Execution.Status != StatusError {
+		t.Errorf("first cell result = %+v", results[0])
+	}
+	if results[1].Execution.Status != StatusTimeout {
+		t.Errorf("second cell status = %q, want %q", results[1].Execution.Status, StatusTimeout)
+	}
+	if results[2].Output != "ran\n" {
+		t.Errorf("third cell output = %q, want it to run", results[2].Output)
+	}
+}
@@ -6,7 +6,8 @@ func FormatResult(result CellResult, format OutputFormatter) CellResult {
 	if format == nil {
 		return result
 	}
-	if result.Language == "markdown" || result.Output == "" {
+	// Skipped cells carry the output stored in the notebook, which is already formatted
+	if result.Language == "markdown" || result.Output == "" || result.Skipped != "" {
 		return result
 	}
 	result.Output = format(result.Output)
@@ -1,13 +1,19 @@
 package igonb
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
-const CurrentVersion = 1
+// CurrentVersion is the format version written by this package. Version 2 gives
+// every cell an ID and records how cells were last executed.
+const CurrentVersion = 2
 
 type Notebook struct {
 	Version  int            `json:"version"`
@@ -16,11 +22,38 @@ type Notebook struct {
 }
 
 type Cell struct {
-	ID       string `json:"id,omitempty"`
-	Language string `json:"language"`
-	Source   string `json:"source"`
-	Output   string `json:"output,omitempty"`
-	Error    string `json:"error,omitempty"`
+	ID        string         `json:"id"`
+	Language  string         `json:"language"`
+	Source    string         `json:"source"`
+	Output    string         `json:"output,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Execution *CellExecution `json:"execution,omitempty"`
+	// Metadata holds per-cell settings; see the Meta constants for the keys the
+	// executor and exports honor
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// Outputs are the Jupyter outputs of a cell imported from .ipynb, kept for
+	// export while Output and Error still hold their text
+	Outputs []Output `json:"outputs,omitempty"`
+	// Attachments are the files embedded in a markdown cell, as MIME bundles by name
+	Attachments map[string]map[string]any `json:"attachments,omitempty"`
+}
+
+// Statuses of a cell execution
+const (
+	StatusOK      = "ok"
+	StatusError   = "error"
+	StatusStopped = "stopped"
+	StatusTimeout = "timeout"
+)
+
+// CellExecution records the last run of a code cell. Count is the executor's
+// execution counter, like In [n] in Jupyter.
+type CellExecution struct {
+	Count      int       `json:"count"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	DurationMs int64     `json:"durationMs"`
 }
 
 func Parse(data []byte) (*Notebook, error) {
@@ -34,9 +67,16 @@ func Parse(data []byte) (*Notebook, error) {
 	}
 
 	if nb.Version == 0 {
-		nb.Version = CurrentVersion
+		nb.Version = 1
+	}
+	if nb.Version > CurrentVersion {
+		return nil, fmt.Errorf("unsupported igonb version %d, newest supported is %d", nb.Version, CurrentVersion)
 	}
 	normalizeNotebook(&nb)
+	if err := migrate(&nb); err != nil {
+		return nil, err
+	}
+	nb.EnsureCellIDs()
 
 	if err := nb.Validate(); err != nil {
 		return nil, err
@@ -52,12 +92,19 @@ func (n *Notebook) Validate() error {
 		return errors.New("igonb must contain at least one cell")
 	}
 
+	ids := make(map[string]bool, len(n.Cells))
 	for idx, cell := range n.Cells {
+		if cell.ID != "" {
+			if ids[cell.ID] {
+				return fmt.Errorf("cell %d has duplicate id: %s", idx+1, cell.ID)
+			}
+			ids[cell.ID] = true
+		}
 		lang := NormalizeLanguage(cell.Language)
 		if lang == "" {
 			return fmt.Errorf("cell %d has empty language", idx+1)
 		}
-		if lang != "go" && lang != "python" && lang != "markdown" {
+		if lang != "go" && lang != "python" && lang != "shell" && lang != "sql" && lang != "markdown" {
 			return fmt.Errorf("cell %d has unsupported language: %s", idx+1, cell.Language)
 		}
 	}
@@ -71,6 +118,8 @@ func NormalizeLanguage(input string) string {
 		return "python"
 	case "md":
 		return "markdown"
+	case "sh", "bash":
+		return "shell"
 	default:
 		return lang
 	}
@@ -82,8 +131,62 @@ func normalizeNotebook(n *Notebook) {
 	}
 }
 
+// EnsureCellIDs gives the cells without an ID, or with the ID of an earlier cell,
+// a new one. Generated IDs derive from the cell's language and source, so parsing
+// the same content again yields the same IDs.
+func (n *Notebook) EnsureCellIDs() {
+	used := make(map[string]bool, len(n.Cells))
+	for i := range n.Cells {
+		id := n.Cells[i].ID
+		if id == "" || used[id] {
+			id = newCellID(n.Cells[i], used)
+			n.Cells[i].ID = id
+		}
+		used[id] = true
+	}
+}
+
+// newCellID hashes the content of a cell into an 8 character ID that is not used yet
+func newCellID(cell Cell, used map[string]bool) string {
+	for attempt := 0; ; attempt++ {
+		sum := sha256.Sum256([]byte(cell.Language + "\x00" + cell.Source + "\x00" + strconv.Itoa(attempt)))
+		id := hex.EncodeToString(sum[:4])
+		if !used[id] {
+			return id
+		}
+	}
+}
+
+// CellIndex returns the index of the cell with the given ID, or -1
+func (n *Notebook) CellIndex(id string) int {
+	for i, cell := range n.Cells {
+		if cell.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ApplyResults stores the outputs and execution records of results in the cells they belong to
+func (n *Notebook) ApplyResults(results []CellResult) {
+	for _, result := range results {
+		if result.Index < 0 || result.Index >= len(n.Cells) {
+			continue
+		}
+		cell := &n.Cells[result.Index]
+		cell.Output = result.Output
+		cell.Error = result.Error
+		if result.Skipped == "" {
+			cell.Outputs = nil
+		}
+		if result.Execution != nil {
+			cell.Execution = result.Execution
+		}
+	}
+}
+
 func NewNotebook() *Notebook {
-	return &Notebook{
+	nb := &Notebook{
 		Version: CurrentVersion,
 		Cells: []Cell{
 			{
@@ -92,6 +195,8 @@ func NewNotebook() *Notebook {
 			},
 		},
 	}
+	nb.EnsureCellIDs()
+	return nb
 }
 
 func DefaultNotebookJSON() (string, error) {
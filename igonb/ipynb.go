@@ -1,59 +1,80 @@
 package igonb
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // IPyNBNotebook represents a Jupyter Notebook structure
 type IPyNBNotebook struct {
-	Cells    []IPyNBCell   `json:"cells"`
-	Metadata IPyNBMetadata `json:"metadata"`
-	NBFormat int           `json:"nbformat"`
-	Minor    int           `json:"nbformat_minor"`
+	Cells    []IPyNBCell    `json:"cells"`
+	Metadata map[string]any `json:"metadata"`
+	NBFormat int            `json:"nbformat"`
+	Minor    int            `json:"nbformat_minor"`
 }
 
 // IPyNBCell represents a cell in Jupyter Notebook
 type IPyNBCell struct {
-	CellType       string        `json:"cell_type"`
-	Source         interface{}   `json:"source"` // Can be string or []string
-	Outputs        []IPyNBOutput `json:"outputs,omitempty"`
-	ExecutionCount *int          `json:"execution_count,omitempty"`
-	Metadata       interface{}   `json:"metadata,omitempty"`
+	ID             string                    `json:"id,omitempty"`
+	CellType       string                    `json:"cell_type"`
+	Source         interface{}               `json:"source"` // Can be string or []string
+	Outputs        []IPyNBOutput             `json:"outputs,omitempty"`
+	ExecutionCount *int                      `json:"execution_count,omitempty"`
+	Metadata       map[string]any            `json:"metadata,omitempty"`
+	Attachments    map[string]map[string]any `json:"attachments,omitempty"`
 }
 
 // IPyNBOutput represents output from a Jupyter cell
 type IPyNBOutput struct {
-	OutputType string      `json:"output_type"`
-	Text       interface{} `json:"text,omitempty"`
-	Data       interface{} `json:"data,omitempty"`
-	Name       string      `json:"name,omitempty"`
-	EName      string      `json:"ename,omitempty"`
-	EValue     string      `json:"evalue,omitempty"`
-	Traceback  []string    `json:"traceback,omitempty"`
+	OutputType     string         `json:"output_type"`
+	Text           interface{}    `json:"text,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	Name           string         `json:"name,omitempty"`
+	EName          string         `json:"ename,omitempty"`
+	EValue         string         `json:"evalue,omitempty"`
+	Traceback      []string       `json:"traceback,omitempty"`
 }
 
-// IPyNBMetadata contains Jupyter notebook metadata
-type IPyNBMetadata struct {
-	KernelSpec   *IPyNBKernelSpec `json:"kernelspec,omitempty"`
-	LanguageInfo *IPyNBLangInfo   `json:"language_info,omitempty"`
+// Output is a Jupyter output of a code cell. Cells imported from .ipynb keep their
+// outputs so that they export unchanged; multi-line strings are stored joined.
+type Output struct {
+	OutputType     string         `json:"output_type"`
+	Name           string         `json:"name,omitempty"`
+	Text           string         `json:"text,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ExecutionCount *int           `json:"execution_count,omitempty"`
+	EName          string         `json:"ename,omitempty"`
+	EValue         string         `json:"evalue,omitempty"`
+	Traceback      []string       `json:"traceback,omitempty"`
 }
 
-// IPyNBKernelSpec contains kernel specification
-type IPyNBKernelSpec struct {
-	DisplayName string `json:"display_name"`
-	Language    string `json:"language"`
-	Name        string `json:"name"`
-}
+// Notebook metadata keys of notebooks imported from .ipynb
+const (
+	metaIPyNBSource   = "ipynb_source"
+	metaIPyNBFormat   = "ipynb_format"
+	metaIPyNBMinor    = "ipynb_format_minor"
+	metaIPyNBMetadata = "ipynb_metadata"
+)
 
-// IPyNBLangInfo contains language information
-type IPyNBLangInfo struct {
-	Name    string `json:"name"`
-	Version string `json:"version,omitempty"`
-}
+// ipynbMinor is the nbformat 4 minor version written for notebooks not imported from .ipynb
+const ipynbMinor = 5
+
+var (
+	ipynbCellID    = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+	exceptionLine  = regexp.MustCompile(`^([A-Za-z_][\w.]*): ?(.*)$`)
+	ipynbCellKeys  = map[string]bool{"id": true, "cell_type": true, "metadata": true, "source": true}
+	ipynbCodeKeys  = map[string]bool{"outputs": true, "execution_count": true}
+	ipynbOtherKeys = map[string]bool{"attachments": true}
+)
 
 // ReadIPyNBFile reads a .ipynb file and converts it to igonb Notebook format
 func ReadIPyNBFile(path string) (*Notebook, error) {
@@ -64,11 +85,16 @@ func ReadIPyNBFile(path string) (*Notebook, error) {
 	return ParseIPyNB(data)
 }
 
-// ParseIPyNB converts Jupyter notebook JSON to igonb Notebook format
+// ParseIPyNB converts Jupyter notebook JSON to igonb Notebook format. Outputs,
+// attachments, raw cells and notebook metadata are kept so that the notebook
+// exports unchanged.
 func ParseIPyNB(data []byte) (*Notebook, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty ipynb content")
 	}
+	if err := ValidateIPyNB(data); err != nil {
+		return nil, err
+	}
 
 	var ipynb IPyNBNotebook
 	if err := json.Unmarshal(data, &ipynb); err != nil {
@@ -76,7 +102,7 @@ func ParseIPyNB(data []byte) (*Notebook, error) {
 	}
 
 	// Detect the language from kernel spec
-	defaultLang := detectIPyNBLanguage(&ipynb)
+	defaultLang := detectIPyNBLanguage(ipynb.Metadata)
 
 	nb := &Notebook{
 		Version:  CurrentVersion,
@@ -85,10 +111,13 @@ func ParseIPyNB(data []byte) (*Notebook, error) {
 	}
 
 	// Store original ipynb metadata
-	nb.Metadata["ipynb_source"] = true
-	if ipynb.NBFormat > 0 {
-		nb.Metadata["ipynb_format"] = ipynb.NBFormat
+	nb.Metadata[metaIPyNBSource] = true
+	nb.Metadata[metaIPyNBFormat] = ipynb.NBFormat
+	nb.Metadata[metaIPyNBMinor] = ipynb.Minor
+	if ipynb.Metadata == nil {
+		ipynb.Metadata = make(map[string]any)
 	}
+	nb.Metadata[metaIPyNBMetadata] = ipynb.Metadata
 
 	for _, ipyCell := range ipynb.Cells {
 		cell := convertIPyNBCell(ipyCell, defaultLang)
@@ -104,25 +133,19 @@ func ParseIPyNB(data []byte) (*Notebook, error) {
 			Source:   "",
 		})
 	}
+	nb.EnsureCellIDs()
 
 	return nb, nil
 }
 
 // detectIPyNBLanguage determines the notebook language from metadata
-func detectIPyNBLanguage(ipynb *IPyNBNotebook) string {
-	if ipynb.Metadata.KernelSpec != nil {
-		lang := strings.ToLower(ipynb.Metadata.KernelSpec.Language)
-		if lang == "python" || strings.HasPrefix(lang, "python") {
-			return "python"
-		}
-		if lang == "go" || lang == "golang" {
-			return "go"
-		}
-	}
-
-	if ipynb.Metadata.LanguageInfo != nil {
-		lang := strings.ToLower(ipynb.Metadata.LanguageInfo.Name)
-		if lang == "python" || strings.HasPrefix(lang, "python") {
+func detectIPyNBLanguage(metadata map[string]any) string {
+	kernelSpec, _ := metadata["kernelspec"].(map[string]any)
+	languageInfo, _ := metadata["language_info"].(map[string]any)
+	for _, value := range []any{kernelSpec["language"], languageInfo["name"]} {
+		lang, _ := value.(string)
+		lang = strings.ToLower(lang)
+		if strings.HasPrefix(lang, "python") {
 			return "python"
 		}
 		if lang == "go" || lang == "golang" {
@@ -142,69 +165,85 @@ func convertIPyNBCell(ipyCell IPyNBCell, defaultLang string) *Cell {
 
 	switch ipyCell.CellType {
 	case "code":
-		cell = Cell{
-			Language: defaultLang,
-			Source:   source,
-			Output:   extractIPyNBOutputs(ipyCell.Outputs),
-			Error:    extractIPyNBError(ipyCell.Outputs),
+		outputs := make([]Output, 0, len(ipyCell.Outputs))
+		for _, out := range ipyCell.Outputs {
+			outputs = append(outputs, convertIPyNBOutput(out))
+		}
+		lang := defaultLang
+		if first, rest, _ := strings.Cut(source, "\n"); magicLanguage(first) != "" {
+			lang, source = magicLanguage(first), rest
 		}
-	case "markdown":
 		cell = Cell{
-			Language: "markdown",
+			Language: lang,
 			Source:   source,
+			Output:   outputsText(outputs),
+			Error:    outputsError(outputs),
+			Outputs:  outputs,
 		}
-	case "raw":
-		// Convert raw cells to markdown
+	case "markdown", "raw":
 		cell = Cell{
-			Language: "markdown",
-			Source:   source,
+			Language:    "markdown",
+			Source:      source,
+			Attachments: joinAttachments(ipyCell.Attachments),
 		}
 	default:
 		// Skip unknown cell types
 		return nil
 	}
+	cell.ID = ipyCell.ID
+	if len(ipyCell.Metadata) > 0 {
+		cell.Metadata = ipyCell.Metadata
+	}
+	if ipyCell.CellType == "raw" {
+		if cell.Metadata == nil {
+			cell.Metadata = make(map[string]any)
+		}
+		cell.Metadata[MetaRaw] = true
+	}
+	if ipyCell.ExecutionCount != nil {
+		cell.Execution = &CellExecution{Count: *ipyCell.ExecutionCount, Status: StatusOK}
+		if cell.Error != "" {
+			cell.Execution.Status = StatusError
+		}
+	}
 
 	return &cell
 }
 
+// convertIPyNBOutput converts a Jupyter output, joining its multi-line strings
+func convertIPyNBOutput(out IPyNBOutput) Output {
+	return Output{
+		OutputType:     out.OutputType,
+		Name:           out.Name,
+		Text:           extractOutputText(out.Text),
+		Data:           joinMimeBundle(out.Data),
+		Metadata:       out.Metadata,
+		ExecutionCount: out.ExecutionCount,
+		EName:          out.EName,
+		EValue:         out.EValue,
+		Traceback:      out.Traceback,
+	}
+}
+
 // extractIPyNBSource extracts source text from cell
 func extractIPyNBSource(source interface{}) string {
-	switch s := source.(type) {
-	case string:
-		return s
-	case []interface{}:
-		var lines []string
-		for _, line := range s {
-			if str, ok := line.(string); ok {
-				lines = append(lines, str)
-			}
-		}
-		return strings.Join(lines, "")
-	default:
-		return ""
-	}
+	return extractOutputText(source)
 }
 
-// extractIPyNBOutputs extracts output text from cell outputs
-func extractIPyNBOutputs(outputs []IPyNBOutput) string {
+// outputsText returns the text of the stream outputs and the text/plain data of
+// the results and displays, which is what the notebook view shows
+func outputsText(outputs []Output) string {
 	var parts []string
 
 	for _, out := range outputs {
 		switch out.OutputType {
 		case "stream":
-			text := extractOutputText(out.Text)
-			if text != "" {
-				parts = append(parts, text)
+			if out.Text != "" {
+				parts = append(parts, out.Text)
 			}
 		case "execute_result", "display_data":
-			// Try to get text/plain from data
-			if data, ok := out.Data.(map[string]interface{}); ok {
-				if textPlain, ok := data["text/plain"]; ok {
-					text := extractOutputText(textPlain)
-					if text != "" {
-						parts = append(parts, text)
-					}
-				}
+			if text, ok := out.Data["text/plain"].(string); ok && text != "" {
+				parts = append(parts, text)
 			}
 		}
 	}
@@ -212,8 +251,8 @@ func extractIPyNBOutputs(outputs []IPyNBOutput) string {
 	return strings.TrimRight(strings.Join(parts, ""), "\n")
 }
 
-// extractIPyNBError extracts error information from cell outputs
-func extractIPyNBError(outputs []IPyNBOutput) string {
+// outputsError returns the traceback of the first error output
+func outputsError(outputs []Output) string {
 	for _, out := range outputs {
 		if out.OutputType == "error" {
 			if len(out.Traceback) > 0 {
@@ -250,6 +289,60 @@ func extractOutputText(text interface{}) string {
 	}
 }
 
+// isJSONMime reports whether a MIME bundle value holds JSON rather than text
+func isJSONMime(mime string) bool {
+	return mime == "application/json" || (strings.HasPrefix(mime, "application/") && strings.HasSuffix(mime, "+json"))
+}
+
+// joinMimeBundle joins the values of a MIME bundle stored as lists of lines, as
+// nbformat does when reading
+func joinMimeBundle(bundle map[string]any) map[string]any {
+	if bundle == nil {
+		return nil
+	}
+	joined := make(map[string]any, len(bundle))
+	for mime, value := range bundle {
+		if lines, ok := value.([]any); ok && !isJSONMime(mime) && allStrings(lines) {
+			value = extractOutputText(lines)
+		}
+		joined[mime] = value
+	}
+	return joined
+}
+
+// splitMimeBundle splits the text values of a MIME bundle into lines, as nbformat
+// does when writing
+func splitMimeBundle(bundle map[string]any) map[string]any {
+	split := make(map[string]any, len(bundle))
+	for mime, value := range bundle {
+		if text, ok := value.(string); ok && (strings.HasPrefix(mime, "text/") || mime == "application/javascript" || mime == "image/svg+xml") {
+			value = splitSourceToLines(text)
+		}
+		split[mime] = value
+	}
+	return split
+}
+
+func joinAttachments(attachments map[string]map[string]any) map[string]map[string]any {
+	if len(attachments) == 0 {
+		return nil
+	}
+	joined := make(map[string]map[string]any, len(attachments))
+	for name, bundle := range attachments {
+		joined[name] = joinMimeBundle(bundle)
+	}
+	return joined
+}
+
+func allStrings(values []any) bool {
+	for _, v := range values {
+		if _, ok := v.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // stripANSI removes ANSI escape codes from a string
 func stripANSI(s string) string {
 	var result strings.Builder
@@ -310,116 +403,448 @@ func IgonbToIPyNBJSON(igonbData []byte) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to parse igonb: %w", err)
 	}
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
 
-	// Detect language from cells (default to Python for ipynb)
-	language := "python"
+// MarshalIPyNB writes a notebook as nbformat 4 JSON the way Jupyter does, with
+// sorted keys, one space indentation and multi-line strings split into lines,
+// so that a notebook imported from Jupyter exports byte for byte unchanged.
+// Notebooks not imported from .ipynb are written as nbformat 4.5.
+func MarshalIPyNB(nb *Notebook) ([]byte, error) {
+	if nb == nil {
+		return nil, fmt.Errorf("igonb notebook is nil")
+	}
+	minor := ipynbMinor
+	if v, ok := jsonInt(nb.Metadata[metaIPyNBMinor]); ok {
+		minor = v
+	}
+
+	metadata, ok := nb.Metadata[metaIPyNBMetadata].(map[string]any)
+	if !ok {
+		metadata = defaultIPyNBMetadata(nb)
+	}
+
+	withIDs := minor >= 5
+	used := make(map[string]bool, len(nb.Cells))
+	cells := make([]any, 0, len(nb.Cells))
 	for _, cell := range nb.Cells {
-		if cell.Language == "go" {
-			language = "go"
-			break
-		}
-		if cell.Language == "python" {
-			language = "python"
-			break
+		if withIDs {
+			if !ipynbCellID.MatchString(cell.ID) || used[cell.ID] {
+				cell.ID = newCellID(cell, used)
+			}
+			used[cell.ID] = true
 		}
+		cells = append(cells, convertCellToIPyNB(cell, withIDs))
 	}
 
-	// Create ipynb notebook structure
-	ipynb := IPyNBNotebook{
-		Cells:    make([]IPyNBCell, 0, len(nb.Cells)),
-		NBFormat: 4,
-		Minor:    5,
-		Metadata: IPyNBMetadata{
-			KernelSpec: &IPyNBKernelSpec{
-				DisplayName: strings.Title(language),
-				Language:    language,
-				Name:        language,
-			},
-			LanguageInfo: &IPyNBLangInfo{
-				Name: language,
-			},
-		},
+	doc := map[string]any{
+		"cells":          cells,
+		"metadata":       metadata,
+		"nbformat":       4,
+		"nbformat_minor": minor,
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal ipynb: %w", err)
 	}
+	if err := ValidateIPyNB(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("exported notebook is not valid nbformat: %w", err)
+	}
+	return buf.Bytes(), nil
+}
 
-	// Convert cells
-	execCount := 1
+// defaultIPyNBMetadata describes the kernel of a notebook not imported from .ipynb
+func defaultIPyNBMetadata(nb *Notebook) map[string]any {
+	// Detect language from cells (default to Python for ipynb)
+	language := "python"
 	for _, cell := range nb.Cells {
-		ipyCell := convertCellToIPyNB(cell, &execCount)
-		ipynb.Cells = append(ipynb.Cells, ipyCell)
+		if cell.Language == "go" || cell.Language == "python" {
+			language = cell.Language
+			break
+		}
 	}
+	kernelSpec := map[string]any{"display_name": "Python 3", "language": "python", "name": "python3"}
+	if language == "go" {
+		kernelSpec = map[string]any{"display_name": "Go", "language": "go", "name": "go"}
+	}
+	return map[string]any{
+		"kernelspec":    kernelSpec,
+		"language_info": map[string]any{"name": language},
+	}
+}
 
-	data, err := json.MarshalIndent(ipynb, "", " ")
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal ipynb: %w", err)
+// ipynbCellMetadata copies the metadata of a cell, adding Jupyter's flags for hidden source and outputs
+func ipynbCellMetadata(cell Cell) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(cell.Metadata)+1)
+	for k, v := range cell.Metadata {
+		if k == MetaRaw {
+			continue
+		}
+		metadata[k] = v
+	}
+	hideOutput := cell.HideOutput() && cell.Language != "markdown"
+	if cell.HideCode() || hideOutput {
+		jupyter := make(map[string]interface{})
+		for k, v := range jupyterMetadata(cell.Metadata) {
+			jupyter[k] = v
+		}
+		if cell.HideCode() {
+			jupyter["source_hidden"] = true
+		}
+		if hideOutput {
+			jupyter["outputs_hidden"] = true
+		}
+		metadata["jupyter"] = jupyter
 	}
+	return metadata
+}
 
-	return string(data), nil
+// cellMagics start the source of shell and sql cells in an .ipynb, so Jupyter's
+// Python kernel runs them with bash and the sql extension too
+var cellMagics = map[string]string{
+	"shell": "%%bash",
+	"sql":   "%%sql",
+}
+
+// magicLanguage returns the language that the first line of a code cell makes
+// it, or "" for a line without a cell magic
+func magicLanguage(line string) string {
+	switch strings.TrimSpace(line) {
+	case "%%bash", "%%sh":
+		return "shell"
+	case "%%sql":
+		return "sql"
+	}
+	return ""
 }
 
-// convertCellToIPyNB converts an igonb Cell to an IPyNB cell
-func convertCellToIPyNB(cell Cell, execCount *int) IPyNBCell {
-	// Split source into lines for ipynb format
-	sourceLines := splitSourceToLines(cell.Source)
+// convertCellToIPyNB converts an igonb Cell to an nbformat 4 cell
+func convertCellToIPyNB(cell Cell, withID bool) map[string]any {
+	source := cell.Source
+	if magic, ok := cellMagics[cell.Language]; ok {
+		source = magic + "\n" + source
+	}
+	ipyCell := map[string]any{
+		"cell_type": "code",
+		"metadata":  ipynbCellMetadata(cell),
+		"source":    splitSourceToLines(source),
+	}
+	if withID {
+		ipyCell["id"] = cell.ID
+	}
 
 	if cell.Language == "markdown" {
-		return IPyNBCell{
-			CellType: "markdown",
-			Source:   sourceLines,
-			Metadata: map[string]interface{}{},
+		ipyCell["cell_type"] = "markdown"
+		if metaBool(cell.Metadata, MetaRaw) {
+			ipyCell["cell_type"] = "raw"
 		}
+		if len(cell.Attachments) > 0 {
+			attachments := make(map[string]any, len(cell.Attachments))
+			for name, bundle := range cell.Attachments {
+				attachments[name] = splitMimeBundle(bundle)
+			}
+			ipyCell["attachments"] = attachments
+		}
+		return ipyCell
 	}
 
-	// Code cell
-	ipyCell := IPyNBCell{
-		CellType:       "code",
-		Source:         sourceLines,
-		Metadata:       map[string]interface{}{},
-		ExecutionCount: execCount,
-		Outputs:        make([]IPyNBOutput, 0),
+	// Code cell: outputs and execution_count are required, null when never run
+	ipyCell["execution_count"] = nil
+	if cell.Execution != nil && cell.Execution.Count > 0 {
+		ipyCell["execution_count"] = cell.Execution.Count
+	}
+	outputs := make([]any, 0)
+	for _, out := range cellOutputs(cell) {
+		outputs = append(outputs, out.ipynb())
 	}
-	*execCount++
+	ipyCell["outputs"] = outputs
+	return ipyCell
+}
 
-	// Convert output
+// cellOutputs returns the Jupyter outputs of a cell. Outputs kept from an .ipynb
+// import are used while the cell's output and error still match them; otherwise
+// the output becomes a stdout stream and the error an error output.
+func cellOutputs(cell Cell) []Output {
+	if cell.Outputs != nil && outputsText(cell.Outputs) == cell.Output && outputsError(cell.Outputs) == cell.Error {
+		return cell.Outputs
+	}
+	var outputs []Output
 	if cell.Output != "" {
-		outputLines := splitSourceToLines(cell.Output)
-		ipyCell.Outputs = append(ipyCell.Outputs, IPyNBOutput{
-			OutputType: "stream",
-			Name:       "stdout",
-			Text:       outputLines,
-		})
+		outputs = append(outputs, Output{OutputType: "stream", Name: "stdout", Text: cell.Output})
 	}
-
-	// Convert error
 	if cell.Error != "" {
-		errorLines := strings.Split(cell.Error, "\n")
-		ipyCell.Outputs = append(ipyCell.Outputs, IPyNBOutput{
+		ename, evalue := cell.ErrorNameValue()
+		outputs = append(outputs, Output{
 			OutputType: "error",
-			EName:      "Error",
-			EValue:     cell.Error,
-			Traceback:  errorLines,
+			EName:      ename,
+			EValue:     evalue,
+			Traceback:  strings.Split(cell.Error, "\n"),
 		})
 	}
+	return outputs
+}
 
-	return ipyCell
+// ErrorNameValue splits the error of a cell into a Jupyter exception name and
+// value. Python tracebacks and Go panics end or start with a "Name: value" line.
+func (c Cell) ErrorNameValue() (string, string) {
+	lines := strings.Split(strings.TrimSpace(c.Error), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	for _, line := range []string{last, strings.TrimSpace(lines[0])} {
+		if m := exceptionLine.FindStringSubmatch(line); m != nil {
+			return m[1], m[2]
+		}
+	}
+	if c.Execution != nil {
+		switch c.Execution.Status {
+		case StatusTimeout:
+			return "TimeoutError", last
+		case StatusStopped:
+			return "KeyboardInterrupt", last
+		}
+	}
+	switch c.Language {
+	case "go":
+		return "GoError", last
+	case "shell":
+		return "CalledProcessError", last
+	}
+	return "Exception", last
+}
+
+// ipynb returns the output as nbformat 4 JSON, with the properties its type requires
+func (o Output) ipynb() map[string]any {
+	out := map[string]any{"output_type": o.OutputType}
+	switch o.OutputType {
+	case "stream":
+		out["name"] = o.Name
+		out["text"] = splitSourceToLines(o.Text)
+	case "display_data", "execute_result":
+		out["data"] = splitMimeBundle(o.Data)
+		out["metadata"] = o.Metadata
+		if o.Metadata == nil {
+			out["metadata"] = map[string]any{}
+		}
+		if o.OutputType == "execute_result" {
+			out["execution_count"] = nil
+			if o.ExecutionCount != nil {
+				out["execution_count"] = *o.ExecutionCount
+			}
+		}
+	case "error":
+		out["ename"] = o.EName
+		out["evalue"] = o.EValue
+		out["traceback"] = o.Traceback
+		if o.Traceback == nil {
+			out["traceback"] = []string{}
+		}
+	}
+	return out
 }
 
-// splitSourceToLines splits source text into lines array for ipynb format
+// splitSourceToLines splits text into lines that keep their line endings, like
+// Python's str.splitlines(True), which nbformat uses for multi-line strings
 func splitSourceToLines(source string) []string {
-	if source == "" {
-		return []string{}
+	lines := []string{}
+	start := 0
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		i += size
+		switch r {
+		case '\r':
+			if i < len(source) && source[i] == '\n' {
+				i++
+			}
+		case '\n', '\v', '\f', '\x1c', '\x1d', '\x1e', '\u0085', '\u2028', '\u2029':
+		default:
+			continue
+		}
+		lines = append(lines, source[start:i])
+		start = i
+	}
+	if start < len(source) {
+		lines = append(lines, source[start:])
+	}
+	return lines
+}
+
+// jsonInt reads an integer that may have been decoded from JSON as a float
+func jsonInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// ValidateIPyNB checks that data is an nbformat 4 notebook: the structure of its
+// cells and outputs and the properties each type requires. Cells of nbformat 4.5
+// notebooks may lack an ID; ParseIPyNB assigns one.
+func ValidateIPyNB(data []byte) error {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid ipynb json: %w", err)
+	}
+	major, ok := jsonInt(doc["nbformat"])
+	if !ok {
+		return fmt.Errorf("ipynb has no nbformat version")
+	}
+	if major != 4 {
+		return fmt.Errorf("unsupported nbformat %d, only nbformat 4 is supported", major)
+	}
+	minor, ok := jsonInt(doc["nbformat_minor"])
+	if !ok || minor < 0 {
+		return fmt.Errorf("ipynb nbformat_minor must be a non-negative integer")
+	}
+	if _, ok := doc["metadata"].(map[string]any); !ok {
+		return fmt.Errorf("ipynb metadata must be an object")
+	}
+	cells, ok := doc["cells"].([]any)
+	if !ok {
+		return fmt.Errorf("ipynb cells must be a list")
+	}
+
+	ids := make(map[string]bool, len(cells))
+	for i, raw := range cells {
+		cell, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("ipynb cell %d is not an object", i+1)
+		}
+		if err := validateIPyNBCell(cell, minor); err != nil {
+			return fmt.Errorf("ipynb cell %d: %w", i+1, err)
+		}
+		if id, ok := cell["id"].(string); ok {
+			if ids[id] {
+				return fmt.Errorf("ipynb cell %d has duplicate id: %s", i+1, id)
+			}
+			ids[id] = true
+		}
+	}
+	return nil
+}
+
+func validateIPyNBCell(cell map[string]any, minor int) error {
+	cellType, _ := cell["cell_type"].(string)
+	switch cellType {
+	case "code", "markdown", "raw":
+	default:
+		return fmt.Errorf("unknown cell_type %q", cellType)
+	}
+	for key := range cell {
+		if !ipynbCellKeys[key] && !(cellType == "code" && ipynbCodeKeys[key]) && !(cellType != "code" && ipynbOtherKeys[key]) {
+			return fmt.Errorf("unexpected property %q in a %s cell", key, cellType)
+		}
+	}
+	if id, present := cell["id"]; present {
+		if minor < 5 {
+			return fmt.Errorf("cell ids need nbformat 4.5 or newer")
+		}
+		if s, ok := id.(string); !ok || !ipynbCellID.MatchString(s) {
+			return fmt.Errorf("invalid cell id %v", id)
+		}
+	}
+	if _, ok := cell["metadata"].(map[string]any); !ok {
+		return fmt.Errorf("metadata must be an object")
+	}
+	if !isMultilineString(cell["source"]) {
+		return fmt.Errorf("source must be a string or a list of strings")
+	}
+
+	if cellType != "code" {
+		if attachments, present := cell["attachments"]; present {
+			bundles, ok := attachments.(map[string]any)
+			if !ok {
+				return fmt.Errorf("attachments must be an object")
+			}
+			for name, bundle := range bundles {
+				if _, ok := bundle.(map[string]any); !ok {
+					return fmt.Errorf("attachment %s must be a MIME bundle", name)
+				}
+			}
+		}
+		return nil
 	}
 
-	lines := strings.Split(source, "\n")
-	result := make([]string, len(lines))
+	if count, present := cell["execution_count"]; !present {
+		return fmt.Errorf("code cell has no execution_count")
+	} else if !isExecutionCount(count) {
+		return fmt.Errorf("execution_count must be an integer or null")
+	}
+	outputs, ok := cell["outputs"].([]any)
+	if !ok {
+		return fmt.Errorf("code cell outputs must be a list")
+	}
+	for j, raw := range outputs {
+		out, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("output %d is not an object", j+1)
+		}
+		if err := validateIPyNBOutput(out); err != nil {
+			return fmt.Errorf("output %d: %w", j+1, err)
+		}
+	}
+	return nil
+}
 
-	for i, line := range lines {
-		// Add newline back except for the last line
-		if i < len(lines)-1 {
-			result[i] = line + "\n"
-		} else {
-			result[i] = line
+func validateIPyNBOutput(out map[string]any) error {
+	outputType, _ := out["output_type"].(string)
+	switch outputType {
+	case "stream":
+		if _, ok := out["name"].(string); !ok {
+			return fmt.Errorf("stream output has no name")
+		}
+		if !isMultilineString(out["text"]) {
+			return fmt.Errorf("stream text must be a string or a list of strings")
+		}
+	case "display_data", "execute_result":
+		if _, ok := out["data"].(map[string]any); !ok {
+			return fmt.Errorf("%s data must be a MIME bundle", outputType)
 		}
+		if _, ok := out["metadata"].(map[string]any); !ok {
+			return fmt.Errorf("%s metadata must be an object", outputType)
+		}
+		if outputType == "execute_result" {
+			if count, present := out["execution_count"]; !present || !isExecutionCount(count) {
+				return fmt.Errorf("execute_result execution_count must be an integer or null")
+			}
+		}
+	case "error":
+		_, hasName := out["ename"].(string)
+		_, hasValue := out["evalue"].(string)
+		traceback, ok := out["traceback"].([]any)
+		if !hasName || !hasValue || !ok || !allStrings(traceback) {
+			return fmt.Errorf("error output needs ename, evalue and a traceback list")
+		}
+	default:
+		return fmt.Errorf("unknown output_type %q", outputType)
 	}
+	return nil
+}
 
-	return result
+func isMultilineString(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return true
+	case []any:
+		return allStrings(v)
+	}
+	return false
+}
+
+func isExecutionCount(value any) bool {
+	if value == nil {
+		return true
+	}
+	count, ok := jsonInt(value)
+	return ok && count >= 0
 }
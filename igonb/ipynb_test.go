@@ -0,0 +1,318 @@
+package igonb
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// jupyterNotebook is a notebook as Jupyter writes it: nbformat 4.4 without cell
+// IDs, sorted keys, one space indentation and a trailing newline
+const jupyterNotebook = `{
+ "cells": [
+  {
+   "attachments": {
+    "plot.png": {
+     "image/png": "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+    }
+   },
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Résumé\n",
+    "\n",
+    "![plot](attachment:plot.png)"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {
+    "tags": [
+     "setup"
+    ]
+   },
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": [
+      "loading\n",
+      "done\n"
+     ]
+    },
+    {
+     "name": "stderr",
+     "output_type": "stream",
+     "text": [
+      "warning: slow\n"
+     ]
+    },
+    {
+     "data": {
+      "application/json": {
+       "rows": [
+        1,
+        2.5,
+        null
+       ]
+      },
+      "text/html": [
+       "<table>\n",
+       "<tr><td>1</td></tr>\n",
+       "</table>"
+      ],
+      "text/plain": [
+       "   a\n",
+       "0  1"
+      ]
+     },
+     "execution_count": 1,
+     "metadata": {},
+     "output_type": "execute_result"
+    }
+   ],
+   "source": [
+    "import pandas as pd\n",
+    "df = pd.DataFrame({\"a\": [1]})\n",
+    "df"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 2,
+   "metadata": {},
+   "outputs": [
+    {
+     "data": {
+      "image/png": "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==\n",
+      "text/plain": [
+       "<Figure size 640x480 with 1 Axes>"
+      ]
+     },
+     "metadata": {
+      "needs_background": "light"
+     },
+     "output_type": "display_data"
+    },
+    {
+     "ename": "ZeroDivisionError",
+     "evalue": "division by zero",
+     "output_type": "error",
+     "traceback": [
+      "\u001b[0;31m---------------------------------------------------------------------------\u001b[0m",
+      "\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"
+     ]
+    }
+   ],
+   "source": [
+    "plot(df)\n",
+    "1 / 0"
+   ]
+  },
+  {
+   "cell_type": "raw",
+   "metadata": {
+    "format": "text/restructuredtext"
+   },
+   "source": [
+    ".. note:: kept as is"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": [
+    "%%bash\n",
+    "echo hi"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": []
+  }
+ ],
+ "metadata": {
+  "kernelspec": {
+   "display_name": "Python 3 (ipykernel)",
+   "language": "python",
+   "name": "python3"
+  },
+  "language_info": {
+   "codemirror_mode": {
+    "name": "ipython",
+    "version": 3
+   },
+   "file_extension": ".py",
+   "name": "python",
+   "version": "3.11.4"
+  }
+ },
+ "nbformat": 4,
+ "nbformat_minor": 4
+}
+`
+
+func TestIPyNBRoundTripIsByteIdentical(t *testing.T) {
+	nb, err := ParseIPyNB([]byte(jupyterNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nb.Cells) != 6 {
+		t.Fatalf("read %d cells, want 6", len(nb.Cells))
+	}
+	for i, want := range []string{"markdown", "python", "python", "markdown", "shell", "python"} {
+		if nb.Cells[i].Language != want {
+			t.Errorf("cell %d language = %q, want %q", i+1, nb.Cells[i].Language, want)
+		}
+	}
+	if got := nb.Cells[1].Output; got != "loading\ndone\nwarning: slow\n   a\n0  1" {
+		t.Errorf("output = %q", got)
+	}
+	if got := nb.Cells[2].Error; got != "---------------------------------------------------------------------------\nZeroDivisionError: division by zero" {
+		t.Errorf("error = %q", got)
+	}
+	if nb.Cells[2].Execution == nil || nb.Cells[2].Execution.Status != StatusError {
+		t.Errorf("execution = %+v, want an error status", nb.Cells[2].Execution)
+	}
+	if nb.Cells[4].Source != "echo hi" {
+		t.Errorf("bash cell source = %q, want the magic removed", nb.Cells[4].Source)
+	}
+	if _, ok := nb.Cells[0].Attachments["plot.png"]; !ok {
+		t.Errorf("attachments = %v", nb.Cells[0].Attachments)
+	}
+
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != jupyterNotebook {
+		t.Fatalf("export differs from the original:\n%s", data)
+	}
+
+	// The notebook also survives a trip through an .igonb file
+	path := filepath.Join(t.TempDir(), "notebook.igonb")
+	if err := WriteFile(path, nb); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = MarshalIPyNB(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != jupyterNotebook {
+		t.Fatalf("export after saving as .igonb differs from the original:\n%s", data)
+	}
+}
+
+func TestIPyNBExportOfEditedCells(t *testing.T) {
+	nb, err := ParseIPyNB([]byte(jupyterNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A re-run replaces the outputs kept from Jupyter with a stream and an error
+	nb.Cells[2].Output = "new\n"
+	nb.Cells[2].Error = "Traceback (most recent call last):\nValueError: bad"
+	nb.Cells[2].Execution = &CellExecution{Count: 3, Status: StatusError}
+	outputs := cellOutputs(nb.Cells[2])
+	if len(outputs) != 2 || outputs[0].Text != "new\n" || outputs[1].EName != "ValueError" || outputs[1].EValue != "bad" {
+		t.Fatalf("outputs = %+v", outputs)
+	}
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\"execution_count\": 3,") || strings.Contains(string(data), "needs_background") {
+		t.Errorf("export kept the old outputs:\n%s", data)
+	}
+}
+
+func TestIPyNBExportOfNewNotebook(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		testCell("bad id", "go", "x := 1"),
+		testCell("", "sql", "SELECT 1"),
+	}}
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"nbformat_minor": 5`, `"language": "go"`, `"%%sql\n"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("export lacks %s:\n%s", want, data)
+		}
+	}
+	imported, err := ParseIPyNB(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Invalid IDs are replaced, as nbformat 4.5 requires
+	if id := imported.Cells[0].ID; id == "bad id" || !ipynbCellID.MatchString(id) {
+		t.Errorf("cell ID = %q", id)
+	}
+	if imported.Cells[1].Language != "sql" || imported.Cells[1].Source != "SELECT 1" {
+		t.Errorf("sql cell = %+v", imported.Cells[1])
+	}
+}
+
+func TestValidateIPyNBRejects(t *testing.T) {
+	const code = `{"cell_type": "code", "execution_count": null, "metadata": {}, "outputs": [%s], "source": ""}`
+	notebook := func(minor, cells string) string {
+		return `{"nbformat": 4, "nbformat_minor": ` + minor + `, "metadata": {}, "cells": [` + cells + `]}`
+	}
+	withOutput := func(output string) string {
+		return notebook("4", strings.Replace(code, "%s", output, 1))
+	}
+	for _, tc := range []struct {
+		name, doc, wantErr string
+	}{
+		{"invalid json", `{"cells": [`, "invalid ipynb json"},
+		{"no version", `{"cells": [], "metadata": {}}`, "no nbformat version"},
+		{"nbformat 3", `{"nbformat": 3, "nbformat_minor": 0, "metadata": {}, "worksheets": []}`, "unsupported nbformat 3"},
+		{"negative minor", notebook("-1", ""), "nbformat_minor must be a non-negative integer"},
+		{"no metadata", `{"nbformat": 4, "nbformat_minor": 4, "cells": []}`, "metadata must be an object"},
+		{"cells not a list", `{"nbformat": 4, "nbformat_minor": 4, "metadata": {}, "cells": {}}`, "cells must be a list"},
+		{"cell not an object", notebook("4", `"x"`), "cell 1 is not an object"},
+		{"unknown cell type", notebook("4", `{"cell_type": "heading", "metadata": {}, "source": ""}`), `unknown cell_type "heading"`},
+		{"outputs in a markdown cell", notebook("4", `{"cell_type": "markdown", "metadata": {}, "source": "", "outputs": []}`), `unexpected property "outputs"`},
+		{"attachments in a code cell", notebook("4", `{"cell_type": "code", "execution_count": null, "metadata": {}, "outputs": [], "source": "", "attachments": {}}`), `unexpected property "attachments"`},
+		{"id before 4.5", notebook("4", `{"id": "a", "cell_type": "raw", "metadata": {}, "source": ""}`), "cell ids need nbformat 4.5"},
+		{"invalid id", notebook("5", `{"id": "a b", "cell_type": "raw", "metadata": {}, "source": ""}`), "invalid cell id"},
+		{"duplicate id", notebook("5", `{"id": "a", "cell_type": "raw", "metadata": {}, "source": ""}, {"id": "a", "cell_type": "raw", "metadata": {}, "source": ""}`), "cell 2 has duplicate id: a"},
+		{"no cell metadata", notebook("4", `{"cell_type": "raw", "source": ""}`), "cell 1: metadata must be an object"},
+		{"source not text", notebook("4", `{"cell_type": "raw", "metadata": {}, "source": ["a", 1]}`), "source must be a string or a list of strings"},
+		{"attachment not a bundle", notebook("4", `{"cell_type": "markdown", "metadata": {}, "source": "", "attachments": {"a.png": "x"}}`), "attachment a.png must be a MIME bundle"},
+		{"no execution count", notebook("4", `{"cell_type": "code", "metadata": {}, "outputs": [], "source": ""}`), "code cell has no execution_count"},
+		{"negative execution count", notebook("4", `{"cell_type": "code", "execution_count": -1, "metadata": {}, "outputs": [], "source": ""}`), "execution_count must be an integer or null"},
+		{"outputs not a list", notebook("4", `{"cell_type": "code", "execution_count": 1, "metadata": {}, "outputs": {}, "source": ""}`), "outputs must be a list"},
+		{"unknown output type", withOutput(`{"output_type": "pyout"}`), `output 1: unknown output_type "pyout"`},
+		{"stream without name", withOutput(`{"output_type": "stream", "text": ""}`), "stream output has no name"},
+		{"stream text not text", withOutput(`{"output_type": "stream", "name": "stdout", "text": 1}`), "stream text must be"},
+		{"display without metadata", withOutput(`{"output_type": "display_data", "data": {}}`), "display_data metadata must be an object"},
+		{"result without data", withOutput(`{"output_type": "execute_result", "metadata": {}, "execution_count": 1}`), "execute_result data must be a MIME bundle"},
+		{"result without execution count", withOutput(`{"output_type": "execute_result", "data": {}, "metadata": {}}`), "execute_result execution_count"},
+		{"error without traceback", withOutput(`{"output_type": "error", "ename": "E", "evalue": ""}`), "error output needs ename, evalue and a traceback list"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateIPyNB([]byte(tc.doc))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+			if _, err := ParseIPyNB([]byte(tc.doc)); err == nil {
+				t.Fatal("ParseIPyNB accepted the notebook")
+			}
+		})
+	}
+
+	// A 4.5 notebook may leave out cell IDs
+	if err := ValidateIPyNB([]byte(notebook("5", `{"cell_type": "raw", "metadata": {}, "source": ""}`))); err != nil {
+		t.Errorf("cell without an ID rejected: %v", err)
+	}
+}
@@ -0,0 +1,350 @@
+package igonb
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Markers written around the two sides of a conflict in a cell source
+const (
+	ConflictOursMarker   = "<<<<<<< ours"
+	ConflictSeparator    = "======="
+	ConflictTheirsMarker = ">>>>>>> theirs"
+)
+
+// MergeConflict is a cell of the merged notebook that needs manual resolution
+type MergeConflict struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// MergeResult is a merged notebook and the cells in it that conflict
+type MergeResult struct {
+	Notebook  *Notebook       `json:"notebook"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// mergeEntry is one cell across the three versions; indexes are -1 where it is absent
+type mergeEntry struct {
+	base, ours, theirs int
+	cell               Cell
+	conflict           string
+}
+
+// Merge combines the changes ours and theirs made to base, matching cells like Diff.
+// A cell changed on one side takes that change; sources changed on both sides are
+// merged line by line, and overlapping changes are written into the source between
+// conflict markers. Outputs are never conflicts: ours wins when both sides changed.
+// Cell order follows ours unless only theirs reordered cells. Any notebook may be nil.
+func Merge(base, ours, theirs *Notebook) *MergeResult {
+	baseCells, oursCells, theirsCells := notebookCells(base), notebookCells(ours), notebookCells(theirs)
+	matchOurs := matchCells(baseCells, oursCells)
+	matchTheirs := matchCells(baseCells, theirsCells)
+
+	entries := make([]*mergeEntry, 0, len(baseCells)+len(oursCells)+len(theirsCells))
+	byOurs := make(map[int]*mergeEntry)
+	byTheirs := make(map[int]*mergeEntry)
+	for i := range baseCells {
+		e := &mergeEntry{base: i, ours: matchOurs[i], theirs: matchTheirs[i]}
+		entries = append(entries, e)
+		if e.ours >= 0 {
+			byOurs[e.ours] = e
+		}
+		if e.theirs >= 0 {
+			byTheirs[e.theirs] = e
+		}
+	}
+
+	// Cells added on both sides are the same cell when they match like Diff matches them
+	var addedOurs, addedTheirs []int
+	for j := range oursCells {
+		if byOurs[j] == nil {
+			addedOurs = append(addedOurs, j)
+		}
+	}
+	for j := range theirsCells {
+		if byTheirs[j] == nil {
+			addedTheirs = append(addedTheirs, j)
+		}
+	}
+	pickCells := func(cells []Cell, idx []int) []Cell {
+		out := make([]Cell, len(idx))
+		for k, j := range idx {
+			out[k] = cells[j]
+		}
+		return out
+	}
+	addedMatch := matchCells(pickCells(oursCells, addedOurs), pickCells(theirsCells, addedTheirs))
+	for k, j := range addedOurs {
+		e := &mergeEntry{base: -1, ours: j, theirs: -1}
+		if addedMatch[k] >= 0 {
+			e.theirs = addedTheirs[addedMatch[k]]
+			byTheirs[e.theirs] = e
+		}
+		byOurs[j] = e
+		entries = append(entries, e)
+	}
+	for _, j := range addedTheirs {
+		if byTheirs[j] == nil {
+			e := &mergeEntry{base: -1, ours: -1, theirs: j}
+			byTheirs[j] = e
+			entries = append(entries, e)
+		}
+	}
+
+	// Resolve the content of each cell and drop deleted ones
+	kept := make(map[*mergeEntry]bool, len(entries))
+	for _, e := range entries {
+		if mergeEntryCell(e, baseCells, oursCells, theirsCells) {
+			kept[e] = true
+		}
+	}
+
+	// Order the kept cells along one side and insert the other side's cells after
+	// the cell preceding them there
+	primary, secondary := byOurs, byTheirs
+	primaryLen, secondaryLen := len(oursCells), len(theirsCells)
+	if len(movedCells(matchOurs)) == 0 && len(movedCells(matchTheirs)) > 0 {
+		primary, secondary = byTheirs, byOurs
+		primaryLen, secondaryLen = len(theirsCells), len(oursCells)
+	}
+	order := make([]*mergeEntry, 0, len(kept))
+	placed := make(map[*mergeEntry]bool, len(kept))
+	for j := 0; j < primaryLen; j++ {
+		if e := primary[j]; kept[e] && !placed[e] {
+			order = append(order, e)
+			placed[e] = true
+		}
+	}
+	anchor := -1
+	for j := 0; j < secondaryLen; j++ {
+		e := secondary[j]
+		if !kept[e] {
+			continue
+		}
+		if placed[e] {
+			for k, o := range order {
+				if o == e {
+					anchor = k
+					break
+				}
+			}
+			continue
+		}
+		anchor++
+		order = append(order[:anchor], append([]*mergeEntry{e}, order[anchor:]...)...)
+		placed[e] = true
+	}
+
+	merged := &Notebook{
+		Version:  max(notebookVersion(base), notebookVersion(ours), notebookVersion(theirs)),
+		Cells:    make([]Cell, 0, len(order)),
+		Metadata: mergeMetadata(notebookMetadata(base), notebookMetadata(ours), notebookMetadata(theirs)),
+	}
+	result := &MergeResult{Notebook: merged, Conflicts: []MergeConflict{}}
+	for _, e := range order {
+		merged.Cells = append(merged.Cells, e.cell)
+	}
+	// Both sides may have given different cells the same ID
+	merged.EnsureCellIDs()
+	for i, e := range order {
+		if e.conflict != "" {
+			result.Conflicts = append(result.Conflicts, MergeConflict{Index: i, ID: merged.Cells[i].ID, Reason: e.conflict})
+		}
+	}
+	return result
+}
+
+// mergeEntryCell sets the merged content of a cell and reports whether it is kept
+func mergeEntryCell(e *mergeEntry, baseCells, oursCells, theirsCells []Cell) bool {
+	var b, o, t *Cell
+	if e.base >= 0 {
+		b = &baseCells[e.base]
+	}
+	if e.ours >= 0 {
+		o = &oursCells[e.ours]
+	}
+	if e.theirs >= 0 {
+		t = &theirsCells[e.theirs]
+	}
+
+	switch {
+	case o == nil && t == nil:
+		return false
+	case b != nil && t == nil:
+		// Deleted in theirs: fine unless ours changed the code
+		if sameCode(*b, *o) {
+			return false
+		}
+		e.cell = *o
+		e.cell.Source = conflictText(splitLines(o.Source), nil)
+		e.conflict = "modified in ours, deleted in theirs"
+		return true
+	case b != nil && o == nil:
+		if sameCode(*b, *t) {
+			return false
+		}
+		e.cell = *t
+		e.cell.Source = conflictText(nil, splitLines(t.Source))
+		e.conflict = "deleted in ours, modified in theirs"
+		return true
+	case o == nil:
+		e.cell = *t
+		return true
+	case t == nil:
+		e.cell = *o
+		return true
+	}
+
+	base := Cell{}
+	if b != nil {
+		base = *b
+	}
+	e.cell = *o
+	var reasons []string
+	switch {
+	case o.Language == base.Language:
+		e.cell.Language = t.Language
+	case t.Language != base.Language && t.Language != o.Language:
+		reasons = append(reasons, "language changed on both sides")
+	}
+	source, conflicted := mergeLines(base.Source, o.Source, t.Source)
+	e.cell.Source = source
+	if conflicted {
+		reasons = append(reasons, "source changed on both sides")
+	}
+	e.cell.Metadata = mergeMetadata(base.Metadata, o.Metadata, t.Metadata)
+	if o.Output == base.Output && o.Error == base.Error {
+		e.cell.Output, e.cell.Error, e.cell.Execution, e.cell.Outputs = t.Output, t.Error, t.Execution, t.Outputs
+	}
+	e.conflict = strings.Join(reasons, "; ")
+	return true
+}
+
+// sameCode reports whether two versions of a cell have the same language and source
+func sameCode(a, b Cell) bool {
+	return a.Language == b.Language && a.Source == b.Source
+}
+
+// mergeLines merges two edits of a text line by line and reports whether some
+// changes overlapped and were written between conflict markers
+func mergeLines(base, ours, theirs string) (string, bool) {
+	switch {
+	case ours == theirs || theirs == base:
+		return ours, false
+	case ours == base:
+		return theirs, false
+	}
+	baseLines, oursLines, theirsLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchOurs := lcsMatch(baseLines, oursLines)
+	matchTheirs := lcsMatch(baseLines, theirsLines)
+
+	var out []string
+	conflicted := false
+	i, o, t := 0, 0, 0
+	for {
+		for i < len(baseLines) && matchOurs[i] == o && matchTheirs[i] == t {
+			out = append(out, baseLines[i])
+			i, o, t = i+1, o+1, t+1
+		}
+		if i >= len(baseLines) && o >= len(oursLines) && t >= len(theirsLines) {
+			break
+		}
+		// The next base line kept by both sides ends the changed region
+		j := i
+		for j < len(baseLines) && (matchOurs[j] < 0 || matchTheirs[j] < 0) {
+			j++
+		}
+		oEnd, tEnd := len(oursLines), len(theirsLines)
+		if j < len(baseLines) {
+			oEnd, tEnd = matchOurs[j], matchTheirs[j]
+		}
+		baseChunk, oursChunk, theirsChunk := baseLines[i:j], oursLines[o:oEnd], theirsLines[t:tEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk):
+			out = append(out, theirsChunk...)
+		case equalLines(theirsChunk, baseChunk), equalLines(oursChunk, theirsChunk):
+			out = append(out, oursChunk...)
+		default:
+			conflicted = true
+			out = append(out, splitLines(conflictText(oursChunk, theirsChunk))...)
+		}
+		i, o, t = j, oEnd, tEnd
+	}
+	merged := strings.Join(out, "\n")
+	if strings.HasSuffix(ours, "\n") && merged != "" {
+		merged += "\n"
+	}
+	return merged, conflicted
+}
+
+// conflictText places both sides of a conflict between markers
+func conflictText(ours, theirs []string) string {
+	lines := make([]string, 0, len(ours)+len(theirs)+3)
+	lines = append(lines, ConflictOursMarker)
+	lines = append(lines, ours...)
+	lines = append(lines, ConflictSeparator)
+	lines = append(lines, theirs...)
+	lines = append(lines, ConflictTheirsMarker)
+	return strings.Join(lines, "\n")
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeMetadata applies the keys of notebook or cell metadata each side changed
+// relative to base; ours wins when both changed a key
+func mergeMetadata(base, ours, theirs map[string]any) map[string]any {
+	keys := make(map[string]bool)
+	for _, m := range []map[string]any{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	merged := make(map[string]any)
+	for k := range keys {
+		bv, inBase := base[k]
+		v, present := ours[k]
+		if present == inBase && reflect.DeepEqual(v, bv) {
+			v, present = theirs[k]
+		}
+		if present {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func notebookCells(nb *Notebook) []Cell {
+	if nb == nil {
+		return nil
+	}
+	return nb.Cells
+}
+
+func notebookMetadata(nb *Notebook) map[string]any {
+	if nb == nil {
+		return nil
+	}
+	return nb.Metadata
+}
+
+func notebookVersion(nb *Notebook) int {
+	if nb == nil || nb.Version == 0 {
+		return CurrentVersion
+	}
+	return nb.Version
+}
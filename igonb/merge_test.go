@@ -0,0 +1,163 @@
+package igonb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mergedSources(r *MergeResult) []string {
+	sources := make([]string, len(r.Notebook.Cells))
+	for i, c := range r.Notebook.Cells {
+		sources[i] = c.Source
+	}
+	return sources
+}
+
+func TestMergeLines(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantConflict       bool
+	}{
+		{"only ours", "a\nb\n", "A\nb\n", "a\nb\n", "A\nb\n", false},
+		{"only theirs", "a\nb\n", "a\nb\n", "a\nB\n", "a\nB\n", false},
+		{"same change", "a\n", "x\n", "x\n", "x\n", false},
+		{"separate lines", "a\nb\nc\n", "A\nb\nc\n", "a\nb\nC\n", "A\nb\nC\n", false},
+		{"insert and edit", "a\nb\nc\n", "a\nb\nnew\nc\n", "A\nb\nc\n", "A\nb\nnew\nc\n", false},
+		{"insert next to an edit", "a\nb\n", "a\nnew\nb\n", "A\nb\n", "<<<<<<< ours\na\nnew\n=======\nA\n>>>>>>> theirs\nb\n", true},
+		{"same line", "x = 1\n", "x = 2\n", "x = 3\n", "<<<<<<< ours\nx = 2\n=======\nx = 3\n>>>>>>> theirs\n", true},
+		{"conflict between kept lines", "a\nb\nc", "a\nB1\nc", "a\nB2\nc", "a\n<<<<<<< ours\nB1\n=======\nB2\n>>>>>>> theirs\nc", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, conflicted := mergeLines(tc.base, tc.ours, tc.theirs)
+			if got != tc.want || conflicted != tc.wantConflict {
+				t.Errorf("mergeLines = %q, %v, want %q, %v", got, conflicted, tc.want, tc.wantConflict)
+			}
+		})
+	}
+}
+
+func TestMergeCells(t *testing.T) {
+	base := &Notebook{Cells: []Cell{
+		testCell("a", "go", "a := 1\n"),
+		testCell("b", "go", "b := 1\n"),
+		testCell("c", "go", "c := 1\n"),
+	}}
+	ours := &Notebook{Cells: []Cell{
+		testCell("a", "go", "a := 2\n"),
+		testCell("b", "go", "b := 1\n"),
+		testCell("c", "go", "c := 1\n"),
+		testCell("o", "markdown", "ours"),
+	}}
+	theirs := &Notebook{Cells: []Cell{
+		testCell("t", "python", "theirs"),
+		testCell("a", "go", "a := 1\n"),
+		testCell("c", "go", "c := 2\n"),
+	}}
+	r := Merge(base, ours, theirs)
+	want := []string{"theirs", "a := 2\n", "c := 2\n", "ours"}
+	if got := mergedSources(r); !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged sources = %q, want %q", got, want)
+	}
+	if len(r.Conflicts) != 0 {
+		t.Fatalf("conflicts = %+v, want none", r.Conflicts)
+	}
+	if r.Notebook.Cells[0].ID != "t" || r.Notebook.Version != CurrentVersion {
+		t.Errorf("merged notebook = %+v", r.Notebook)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	base := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x = 1\n"),
+		testCell("b", "go", "keep\n"),
+		testCell("c", "go", "gone\n"),
+	}}
+	ours := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x = 2\n"),
+		testCell("b", "go", "keep, edited by ours\n"),
+	}}
+	theirs := &Notebook{Cells: []Cell{
+		testCell("a", "go", "x = 3\n"),
+		testCell("c", "go", "gone, edited by theirs\n"),
+	}}
+	r := Merge(base, ours, theirs)
+	// c follows a in theirs, so it is placed right after a
+	want := []string{
+		"<<<<<<< ours\nx = 2\n=======\nx = 3\n>>>>>>> theirs\n",
+		"<<<<<<< ours\n=======\ngone, edited by theirs\n>>>>>>> theirs",
+		"<<<<<<< ours\nkeep, edited by ours\n=======\n>>>>>>> theirs",
+	}
+	if got := mergedSources(r); !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged sources = %q, want %q", got, want)
+	}
+	wantConflicts := []MergeConflict{
+		{Index: 0, ID: "a", Reason: "source changed on both sides"},
+		{Index: 1, ID: "c", Reason: "deleted in ours, modified in theirs"},
+		{Index: 2, ID: "b", Reason: "modified in ours, deleted in theirs"},
+	}
+	if !reflect.DeepEqual(r.Conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", r.Conflicts, wantConflicts)
+	}
+
+	// A cell deleted on one side and left alone on the other is simply deleted
+	r = Merge(base, &Notebook{Cells: base.Cells[:2]}, base)
+	if got := mergedSources(r); len(got) != 2 || len(r.Conflicts) != 0 {
+		t.Errorf("merge of a plain deletion = %q, %+v", got, r.Conflicts)
+	}
+}
+
+func TestMergeWithoutIDs(t *testing.T) {
+	base := &Notebook{Cells: []Cell{testCell("", "go", "a\n"), testCell("", "go", "b\n")}}
+	ours := &Notebook{Cells: []Cell{testCell("", "go", "a\n"), testCell("", "go", "b\nours\n")}}
+	theirs := &Notebook{Cells: []Cell{testCell("", "go", "theirs\na\n"), testCell("", "go", "b\n")}}
+	r := Merge(base, ours, theirs)
+	if got, want := mergedSources(r), []string{"theirs\na\n", "b\nours\n"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged sources = %q, want %q", got, want)
+	}
+	if r.Notebook.Cells[0].ID == "" || r.Notebook.Cells[0].ID == r.Notebook.Cells[1].ID {
+		t.Errorf("merged cells lack unique IDs: %+v", r.Notebook.Cells)
+	}
+}
+
+func TestMergeMetadataAndOutputs(t *testing.T) {
+	base := &Notebook{
+		Metadata: map[string]any{"kernel": "go", "removed": true, "both": 1},
+		Cells: []Cell{
+			{ID: "a", Language: "go", Source: "x", Output: "old\n", Metadata: map[string]any{MetaTimeout: "10s"}},
+		},
+	}
+	ours := &Notebook{
+		Metadata: map[string]any{"kernel": "go", "both": 2, "ours": true},
+		Cells: []Cell{
+			{ID: "a", Language: "go", Source: "x", Output: "old\n", Metadata: map[string]any{MetaTimeout: "10s", MetaSkip: true}},
+		},
+	}
+	theirs := &Notebook{
+		Metadata: map[string]any{"kernel": "python", "removed": true, "both": 3},
+		Cells: []Cell{
+			{ID: "a", Language: "go", Source: "x", Output: "new\n", Metadata: map[string]any{MetaTimeout: "30s"}},
+		},
+	}
+	r := Merge(base, ours, theirs)
+	wantNotebook := map[string]any{"kernel": "python", "both": 2, "ours": true}
+	if !reflect.DeepEqual(r.Notebook.Metadata, wantNotebook) {
+		t.Errorf("notebook metadata = %v, want %v", r.Notebook.Metadata, wantNotebook)
+	}
+	cell := r.Notebook.Cells[0]
+	wantCell := map[string]any{MetaTimeout: "30s", MetaSkip: true}
+	if !reflect.DeepEqual(cell.Metadata, wantCell) {
+		t.Errorf("cell metadata = %v, want %v", cell.Metadata, wantCell)
+	}
+	// Only theirs ran the cell again, so its output is taken
+	if cell.Output != "new\n" || len(r.Conflicts) != 0 {
+		t.Errorf("cell = %+v, conflicts %+v", cell, r.Conflicts)
+	}
+	if got := mergeMetadata(map[string]any{"k": 1}, map[string]any{"k": 1}, map[string]any{"k": 1}); !reflect.DeepEqual(got, map[string]any{"k": 1}) {
+		t.Errorf("unchanged metadata merged to %v", got)
+	}
+	if got := mergeMetadata(map[string]any{"k": 1}, nil, map[string]any{"k": 1}); got != nil {
+		t.Errorf("metadata emptied by ours merged to %v, want nil", got)
+	}
+}
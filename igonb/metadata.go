@@ -0,0 +1,169 @@
+package igonb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Well-known cell metadata keys
+const (
+	// MetaSkip marks a cell that is never run
+	MetaSkip = "skip"
+	// MetaFrozen marks a cell whose output is kept and that is not re-run
+	MetaFrozen = "frozen"
+	// MetaTags lists the tags of a cell
+	MetaTags = "tags"
+	// MetaTimeout limits how long a cell may run, in seconds or as a duration such as "1m30s"
+	MetaTimeout = "timeout"
+	// MetaHideCode hides the source of a cell in exports
+	MetaHideCode = "hide_code"
+	// MetaHideOutput hides the output of a cell in exports
+	MetaHideOutput = "hide_output"
+	// MetaRaw marks a markdown cell imported from a Jupyter raw cell, which is
+	// exported as a raw cell again
+	MetaRaw = "raw"
+)
+
+// Cell tags with a meaning to the executor, as in Jupyter's nbclient
+const (
+	// TagSkipExecution skips a cell like MetaSkip
+	TagSkipExecution = "skip-execution"
+	// TagRaisesException lets a run continue after the cell fails
+	TagRaisesException = "raises-exception"
+)
+
+// Skipped reports whether the cell is never run
+func (c Cell) Skipped() bool {
+	return metaBool(c.Metadata, MetaSkip) || c.HasTag(TagSkipExecution)
+}
+
+// Frozen reports whether the cell keeps its output instead of being re-run
+func (c Cell) Frozen() bool {
+	return metaBool(c.Metadata, MetaFrozen)
+}
+
+// Tags returns the tags of the cell
+func (c Cell) Tags() []string {
+	switch tags := c.Metadata[MetaTags].(type) {
+	case []string:
+		return tags
+	case []any:
+		out := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// HasTag reports whether the cell has the given tag
+func (c Cell) HasTag(tag string) bool {
+	for _, t := range c.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout returns how long the cell may run, or 0 when it has no limit
+func (c Cell) Timeout() time.Duration {
+	switch v := c.Metadata[MetaTimeout].(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case int:
+		return time.Duration(v) * time.Second
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d
+		}
+	}
+	return 0
+}
+
+// HideCode reports whether exports hide the source of the cell; Jupyter's
+// jupyter.source_hidden is honored too
+func (c Cell) HideCode() bool {
+	return metaBool(c.Metadata, MetaHideCode) || metaBool(jupyterMetadata(c.Metadata), "source_hidden")
+}
+
+// HideOutput reports whether exports hide the output of the cell; Jupyter's
+// jupyter.outputs_hidden is honored too
+func (c Cell) HideOutput() bool {
+	return metaBool(c.Metadata, MetaHideOutput) || metaBool(jupyterMetadata(c.Metadata), "outputs_hidden")
+}
+
+// SetMetadata sets cell metadata keys; a nil value removes the key. Well-known
+// keys are checked for the type the executor expects.
+func (c *Cell) SetMetadata(values map[string]any) error {
+	for key, value := range values {
+		if value == nil {
+			continue
+		}
+		switch key {
+		case MetaSkip, MetaFrozen, MetaHideCode, MetaHideOutput:
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("cell metadata %s must be a boolean", key)
+			}
+		case MetaTags:
+			tags, ok := value.([]any)
+			if s, isStrings := value.([]string); isStrings {
+				ok = true
+				tags = make([]any, len(s))
+				for i := range s {
+					tags[i] = s[i]
+				}
+			}
+			if !ok {
+				return fmt.Errorf("cell metadata %s must be a list of strings", key)
+			}
+			for _, tag := range tags {
+				if _, ok := tag.(string); !ok {
+					return fmt.Errorf("cell metadata %s must be a list of strings", key)
+				}
+			}
+		case MetaTimeout:
+			valid := false
+			switch v := value.(type) {
+			case float64:
+				valid = v > 0
+			case int:
+				valid = v > 0
+			case string:
+				d, err := time.ParseDuration(strings.TrimSpace(v))
+				valid = err == nil && d > 0
+			}
+			if !valid {
+				return fmt.Errorf("cell metadata %s must be a positive number of seconds or a duration", key)
+			}
+		}
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(c.Metadata, key)
+			continue
+		}
+		if c.Metadata == nil {
+			c.Metadata = make(map[string]any)
+		}
+		c.Metadata[key] = value
+	}
+	if len(c.Metadata) == 0 {
+		c.Metadata = nil
+	}
+	return nil
+}
+
+func metaBool(metadata map[string]any, key string) bool {
+	v, _ := metadata[key].(bool)
+	return v
+}
+
+func jupyterMetadata(metadata map[string]any) map[string]any {
+	m, _ := metadata["jupyter"].(map[string]any)
+	return m
+}
@@ -0,0 +1,224 @@
+package igonb
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCellTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags any
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]any{"a", 1, "b"}, []string{"a", "b"}},
+		{"a", nil},
+	} {
+		cell := Cell{Metadata: map[string]any{MetaTags: tc.tags}}
+		if got := cell.Tags(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Tags() of %#v = %q, want %q", tc.tags, got, tc.want)
+		}
+	}
+
+	cell := Cell{Metadata: map[string]any{MetaTags: []any{TagSkipExecution}}}
+	if !cell.HasTag(TagSkipExecution) || cell.HasTag(TagRaisesException) {
+		t.Errorf("HasTag misreads tags %v", cell.Tags())
+	}
+	if !cell.Skipped() {
+		t.Error("a cell tagged skip-execution is not skipped")
+	}
+}
+
+func TestCellTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout any
+		want    time.Duration
+	}{
+		{nil, 0},
+		{1.5, 1500 * time.Millisecond},
+		{2, 2 * time.Second},
+		{" 1m30s ", 90 * time.Second},
+		{"soon", 0},
+		{true, 0},
+	} {
+		cell := Cell{Metadata: map[string]any{MetaTimeout: tc.timeout}}
+		if got := cell.Timeout(); got != tc.want {
+			t.Errorf("Timeout() of %#v = %v, want %v", tc.timeout, got, tc.want)
+		}
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		values  map[string]any
+		wantErr string
+	}{
+		{map[string]any{MetaSkip: true, MetaFrozen: false, MetaHideCode: true, MetaHideOutput: false}, ""},
+		{map[string]any{MetaTags: []string{"a"}}, ""},
+		{map[string]any{MetaTags: []any{"a", "b"}}, ""},
+		{map[string]any{MetaTimeout: 1.5}, ""},
+		{map[string]any{MetaTimeout: 3}, ""},
+		{map[string]any{MetaTimeout: "2m"}, ""},
+		{map[string]any{"custom": map[string]any{"any": "value"}}, ""},
+		{map[string]any{MetaSkip: "yes"}, "skip must be a boolean"},
+		{map[string]any{MetaHideOutput: 1}, "hide_output must be a boolean"},
+		{map[string]any{MetaTags: "a"}, "tags must be a list of strings"},
+		{map[string]any{MetaTags: []any{"a", 1}}, "tags must be a list of strings"},
+		{map[string]any{MetaTimeout: 0}, "timeout must be a positive number"},
+		{map[string]any{MetaTimeout: -1.0}, "timeout must be a positive number"},
+		{map[string]any{MetaTimeout: "-1s"}, "timeout must be a positive number"},
+		{map[string]any{MetaTimeout: "soon"}, "timeout must be a positive number"},
+	} {
+		cell := Cell{Metadata: map[string]any{"kept": true}}
+		err := cell.SetMetadata(tc.values)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("SetMetadata(%v): %v", tc.values, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("SetMetadata(%v) err = %v, want %q", tc.values, err, tc.wantErr)
+		}
+		// Nothing is set when a value is invalid
+		if !reflect.DeepEqual(cell.Metadata, map[string]any{"kept": true}) {
+			t.Errorf("SetMetadata(%v) changed the metadata to %v", tc.values, cell.Metadata)
+		}
+	}
+}
+
+func TestSetMetadataRemovesKeys(t *testing.T) {
+	cell := Cell{}
+	if err := cell.SetMetadata(map[string]any{MetaSkip: true, MetaTimeout: "1s"}); err != nil {
+		t.Fatal(err)
+	}
+	if !cell.Skipped() || cell.Timeout() != time.Second {
+		t.Fatalf("metadata = %v", cell.Metadata)
+	}
+	if err := cell.SetMetadata(map[string]any{MetaSkip: nil}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cell.Metadata, map[string]any{MetaTimeout: "1s"}) {
+		t.Fatalf("metadata = %v, want only the timeout", cell.Metadata)
+	}
+	if err := cell.SetMetadata(map[string]any{MetaTimeout: nil, "missing": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if cell.Metadata != nil {
+		t.Errorf("metadata = %v, want nil once every key is removed", cell.Metadata)
+	}
+}
+
+const metadataIPyNB = `{
+ "cells": [
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "id": "code",
+   "metadata": {
+    "tags": ["raises-exception", "slow"],
+    "timeout": 30,
+    "jupyter": {"source_hidden": true},
+    "custom": {"nested": [1, "two"]}
+   },
+   "outputs": [],
+   "source": ["x = 1"]
+  },
+  {
+   "cell_type": "raw",
+   "id": "raw",
+   "metadata": {"format": "text/x-rst"},
+   "source": ["raw text"]
+  },
+  {
+   "cell_type": "markdown",
+   "id": "md",
+   "metadata": {},
+   "source": ["# Title"]
+  }
+ ],
+ "metadata": {"kernelspec": {"display_name": "Python 3", "language": "python", "name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+func TestIPyNBCellMetadataRoundTrip(t *testing.T) {
+	nb, err := ParseIPyNB([]byte(metadataIPyNB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, raw, md := nb.Cells[0], nb.Cells[1], nb.Cells[2]
+	if !code.HasTag(TagRaisesException) || code.Timeout() != 30*time.Second || !code.HideCode() || code.HideOutput() {
+		t.Errorf("code cell metadata read as tags %v, timeout %v, hide code %v", code.Tags(), code.Timeout(), code.HideCode())
+	}
+	if !metaBool(raw.Metadata, MetaRaw) || raw.Metadata["format"] != "text/x-rst" {
+		t.Errorf("raw cell metadata = %v", raw.Metadata)
+	}
+	if md.Metadata != nil {
+		t.Errorf("empty metadata imported as %v", md.Metadata)
+	}
+
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateIPyNB(data); err != nil {
+		t.Fatal(err)
+	}
+	var got, want struct {
+		Cells []struct {
+			CellType string         `json:"cell_type"`
+			Metadata map[string]any `json:"metadata"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(metadataIPyNB), &want); err != nil {
+		t.Fatal(err)
+	}
+	// The raw marker is not written out; the cell type carries it
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exported cells = %+v, want %+v", got.Cells, want.Cells)
+	}
+
+	again, err := ParseIPyNB(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nb.Cells {
+		if !reflect.DeepEqual(again.Cells[i].Metadata, nb.Cells[i].Metadata) {
+			t.Errorf("cell %d metadata = %v after a round trip, want %v", i+1, again.Cells[i].Metadata, nb.Cells[i].Metadata)
+		}
+	}
+}
+
+func TestIPyNBExportsHiddenFlags(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		{ID: "a", Language: "go", Source: "x", Metadata: map[string]any{MetaHideCode: true, MetaHideOutput: true}},
+		{ID: "b", Language: "markdown", Source: "text", Metadata: map[string]any{MetaHideOutput: true}},
+	}}
+	data, err := MarshalIPyNB(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ParseIPyNB(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantJupyter := map[string]any{"source_hidden": true, "outputs_hidden": true}
+	if got := jupyterMetadata(imported.Cells[0].Metadata); !reflect.DeepEqual(got, wantJupyter) {
+		t.Errorf("jupyter metadata = %v, want %v", got, wantJupyter)
+	}
+	if !imported.Cells[0].HideCode() || !imported.Cells[0].HideOutput() {
+		t.Error("hidden flags lost in the round trip")
+	}
+	// Markdown cells have no outputs to hide
+	if got := jupyterMetadata(imported.Cells[1].Metadata); got != nil {
+		t.Errorf("markdown cell got jupyter metadata %v", got)
+	}
+}
@@ -0,0 +1,29 @@
+package igonb
+
+import "fmt"
+
+// migrations upgrade a notebook from the version at their index plus one to the next
+var migrations = []func(*Notebook) error{
+	migrateV1,
+}
+
+// migrate upgrades a parsed notebook to CurrentVersion
+func migrate(nb *Notebook) error {
+	for nb.Version < CurrentVersion {
+		if nb.Version < 1 || nb.Version > len(migrations) {
+			return fmt.Errorf("no migration from igonb version %d", nb.Version)
+		}
+		if err := migrations[nb.Version-1](nb); err != nil {
+			return fmt.Errorf("failed to migrate igonb version %d: %w", nb.Version, err)
+		}
+		nb.Version++
+	}
+	return nil
+}
+
+// migrateV1 gives the cells of a version 1 notebook IDs. Version 1 did not record
+// executions, so cells that have output keep it without an execution record.
+func migrateV1(nb *Notebook) error {
+	nb.EnsureCellIDs()
+	return nil
+}
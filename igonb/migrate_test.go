@@ -0,0 +1,124 @@
+package igonb
+
+import (
+	"strings"
+	"testing"
+)
+
+const v1Notebook = `{
+  "version": 1,
+  "cells": [
+    {"language": "go", "source": "x := 1", "output": "1\n"},
+    {"language": "py", "source": "print(2)"},
+    {"language": "go", "source": "x := 1"},
+    {"language": "markdown", "source": "# Notes"}
+  ]
+}`
+
+func TestParseMigratesV1(t *testing.T) {
+	nb, err := Parse([]byte(v1Notebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.Version != CurrentVersion {
+		t.Fatalf("version = %d, want %d", nb.Version, CurrentVersion)
+	}
+	seen := make(map[string]bool)
+	for i, cell := range nb.Cells {
+		if len(cell.ID) != 8 {
+			t.Errorf("cell %d has ID %q, want 8 hex characters", i+1, cell.ID)
+		}
+		if seen[cell.ID] {
+			t.Errorf("cell %d repeats ID %q", i+1, cell.ID)
+		}
+		seen[cell.ID] = true
+	}
+	if nb.Cells[1].Language != "python" {
+		t.Errorf("language = %q, want python", nb.Cells[1].Language)
+	}
+	// Output survives without an execution record
+	if nb.Cells[0].Output != "1\n" || nb.Cells[0].Execution != nil {
+		t.Errorf("first cell = %+v", nb.Cells[0])
+	}
+
+	// The same content yields the same IDs, including for the duplicated cell
+	again, err := Parse([]byte(v1Notebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range nb.Cells {
+		if nb.Cells[i].ID != again.Cells[i].ID {
+			t.Errorf("cell %d got ID %q, then %q", i+1, nb.Cells[i].ID, again.Cells[i].ID)
+		}
+	}
+	// A file without a version is version 1
+	unversioned, err := Parse([]byte(strings.Replace(v1Notebook, `"version": 1,`, "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unversioned.Cells[2].ID != nb.Cells[2].ID {
+		t.Errorf("unversioned file got ID %q, want %q", unversioned.Cells[2].ID, nb.Cells[2].ID)
+	}
+}
+
+func TestParseRejectsNewerVersion(t *testing.T) {
+	_, err := Parse([]byte(`{"version": 3, "cells": [{"id": "a", "language": "go", "source": ""}]}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported igonb version 3") {
+		t.Fatalf("err = %v, want an unsupported version error", err)
+	}
+	if err := migrate(&Notebook{Version: -1}); err == nil {
+		t.Error("migrate accepted version -1")
+	}
+}
+
+func TestEnsureCellIDs(t *testing.T) {
+	nb := &Notebook{Version: CurrentVersion, Cells: []Cell{
+		testCell("keep", "go", "a"),
+		testCell("keep", "go", "b"),
+		testCell("", "go", "c"),
+		testCell("", "go", "c"),
+		testCell("other", "go", "d"),
+	}}
+	nb.EnsureCellIDs()
+	if nb.Cells[0].ID != "keep" || nb.Cells[4].ID != "other" {
+		t.Errorf("unique IDs changed: %q, %q", nb.Cells[0].ID, nb.Cells[4].ID)
+	}
+	seen := make(map[string]bool)
+	for i, cell := range nb.Cells {
+		if cell.ID == "" || seen[cell.ID] {
+			t.Errorf("cell %d has ID %q, want a new unique one", i+1, cell.ID)
+		}
+		seen[cell.ID] = true
+	}
+	if err := nb.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Regenerated IDs are deterministic
+	other := &Notebook{Cells: []Cell{
+		testCell("keep", "go", "a"),
+		testCell("keep", "go", "b"),
+		testCell("", "go", "c"),
+		testCell("", "go", "c"),
+		testCell("other", "go", "d"),
+	}}
+	other.EnsureCellIDs()
+	for i := range nb.Cells {
+		if nb.Cells[i].ID != other.Cells[i].ID {
+			t.Errorf("cell %d got ID %q, then %q", i+1, nb.Cells[i].ID, other.Cells[i].ID)
+		}
+	}
+}
+
+func TestParseRegeneratesDuplicateIDs(t *testing.T) {
+	nb, err := Parse([]byte(`{"version": 2, "cells": [
+		{"id": "dup", "language": "go", "source": "a"},
+		{"id": "dup", "language": "go", "source": "b"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.Cells[0].ID != "dup" || nb.Cells[1].ID == "dup" || nb.Cells[1].ID == "" {
+		t.Errorf("IDs = %q, %q, want dup and a new one", nb.Cells[0].ID, nb.Cells[1].ID)
+	}
+}
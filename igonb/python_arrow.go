@@ -0,0 +1,463 @@
+package igonb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/HazelnutParadise/insyra"
+	"github.com/apache/arrow/go/v17/arrow"
+	"github.com/apache/arrow/go/v17/arrow/array"
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+	"github.com/apache/arrow/go/v17/arrow/memory"
+)
+
+// DefaultPythonArrowThreshold is the number of values from which a DataList or
+// DataTable passes between Go and Python cells as an Arrow IPC file instead of JSON
+const DefaultPythonArrowThreshold = 100000
+
+// MetaPythonArrowThreshold is the notebook metadata key that overrides
+// DefaultPythonArrowThreshold; 0 or a negative number always uses JSON
+const MetaPythonArrowThreshold = "python_arrow_threshold"
+
+// arrowIndexColumn holds the row names of a DataTable in an Arrow file, named
+// like the index column pandas writes
+const arrowIndexColumn = "__index_level_0__"
+
+// pythonArrowTransfer is where the Arrow files of one Python cell run are
+// written and how large a table must be to use one
+type pythonArrowTransfer struct {
+	dir       string
+	threshold int
+	// toPython is set once the Python session is known to read Arrow files
+	toPython bool
+	count    int
+}
+
+// pythonArrowThreshold returns the Arrow threshold of a notebook
+func pythonArrowThreshold(nb *Notebook) int {
+	if nb != nil {
+		switch v := nb.Metadata[MetaPythonArrowThreshold].(type) {
+		case float64:
+			return int(v)
+		case int:
+			return v
+		case int64:
+			return int(v)
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				return int(n)
+			}
+		}
+	}
+	return DefaultPythonArrowThreshold
+}
+
+// encodeArrowBinding writes a large DataList or DataTable to an Arrow file for
+// Python, and reports false when the value is small or cannot be written
+func (t *pythonArrowTransfer) encodeArrowBinding(value any) (map[string]any, bool) {
+	if t == nil || !t.toPython || t.threshold <= 0 || value == nil {
+		return nil, false
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, false
+	}
+	var names []string
+	var columns [][]any
+	var rowNames []string
+	binding := map[string]any{pythonTypeTag: "arrow"}
+	switch v := value.(type) {
+	case pythonDataList:
+		data := v.Data()
+		if len(data) < t.threshold {
+			return nil, false
+		}
+		columns = [][]any{data}
+		binding["kind"], binding["name"] = "datalist", v.GetName()
+	case pythonDataTable:
+		rows := v.To2DSlice()
+		if len(rows) == 0 || len(rows)*len(rows[0]) < t.threshold {
+			return nil, false
+		}
+		names = v.ColNames()
+		binding["kind"], binding["named"] = "datatable", hasNonEmptyStrings(names)
+		columns = make([][]any, len(rows[0]))
+		for j := range columns {
+			columns[j] = make([]any, len(rows))
+			for i, row := range rows {
+				if j < len(row) {
+					columns[j][i] = row[j]
+				}
+			}
+		}
+		rowNames = v.RowNames()
+	default:
+		return nil, false
+	}
+	if binding["kind"] == "datalist" {
+		names = []string{"values"}
+	} else if binding["named"] != true || len(names) != len(columns) {
+		names = make([]string, len(columns))
+		for i := range names {
+			names[i] = fmt.Sprintf("column_%d", i)
+		}
+	}
+	// Python restores the index only from a column that was written
+	if hasNonEmptyStrings(rowNames) && len(columns) > 0 && len(rowNames) == len(columns[0]) {
+		binding["index"] = arrowIndexColumn
+		index := make([]any, len(rowNames))
+		for i, name := range rowNames {
+			index[i] = name
+		}
+		names = append([]string{arrowIndexColumn}, names...)
+		columns = append([][]any{index}, columns...)
+	}
+	t.count++
+	path := filepath.Join(t.dir, fmt.Sprintf("go_%d.arrow", t.count))
+	if err := writeArrowFile(path, names, columns); err != nil {
+		return nil, false
+	}
+	binding["path"] = path
+	return binding, true
+}
+
+// writeArrowFile writes columns of Go values as one record batch of an Arrow
+// IPC file; each column must hold values of one kind
+func writeArrowFile(path string, names []string, columns [][]any) error {
+	mem := memory.NewGoAllocator()
+	fields := make([]arrow.Field, len(columns))
+	arrays := make([]arrow.Array, len(columns))
+	defer func() {
+		for _, arr := range arrays {
+			if arr != nil {
+				arr.Release()
+			}
+		}
+	}()
+	rows := 0
+	for i, column := range columns {
+		arr, err := buildArrowColumn(mem, column)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", names[i], err)
+		}
+		arrays[i] = arr
+		fields[i] = arrow.Field{Name: names[i], Type: arr.DataType(), Nullable: true}
+		rows = len(column)
+	}
+	schema := arrow.NewSchema(fields, nil)
+	record := array.NewRecord(schema, arrays, int64(rows))
+	defer record.Release()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer, err := ipc.NewFileWriter(file, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		file.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// arrowColumnKind returns the Arrow type a column of Go values is written as:
+// bool, int64, float64 (ints mixed with floats too), string or a UTC timestamp
+func arrowColumnKind(values []any) (arrow.DataType, error) {
+	var kind arrow.DataType = arrow.Null
+	for _, value := range values {
+		var next arrow.DataType
+		switch value.(type) {
+		case nil:
+			continue
+		case bool:
+			next = arrow.FixedWidthTypes.Boolean
+		case int, int8, int16, int32, int64, uint8, uint16, uint32:
+			next = arrow.PrimitiveTypes.Int64
+		case uint, uint64:
+			if _, ok := toInt64(value); !ok {
+				next = arrow.PrimitiveTypes.Float64
+			} else {
+				next = arrow.PrimitiveTypes.Int64
+			}
+		case float32, float64:
+			next = arrow.PrimitiveTypes.Float64
+		case string:
+			next = arrow.BinaryTypes.String
+		case time.Time:
+			next = arrow.FixedWidthTypes.Timestamp_us
+		default:
+			return nil, fmt.Errorf("unsupported type %T", value)
+		}
+		switch {
+		case kind == arrow.Null || arrow.TypeEqual(kind, next):
+			kind = next
+		case kind == arrow.PrimitiveTypes.Int64 && next == arrow.PrimitiveTypes.Float64:
+			kind = next
+		case kind == arrow.PrimitiveTypes.Float64 && next == arrow.PrimitiveTypes.Int64:
+		default:
+			return nil, fmt.Errorf("mixed %s and %s values", kind, next)
+		}
+	}
+	return kind, nil
+}
+
+func buildArrowColumn(mem memory.Allocator, values []any) (arrow.Array, error) {
+	kind, err := arrowColumnKind(values)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case arrow.Null:
+		return array.NewNull(len(values)), nil
+	case arrow.FixedWidthTypes.Boolean:
+		builder := array.NewBooleanBuilder(mem)
+		defer builder.Release()
+		for _, value := range values {
+			if value == nil {
+				builder.AppendNull()
+			} else {
+				builder.Append(value.(bool))
+			}
+		}
+		return builder.NewArray(), nil
+	case arrow.PrimitiveTypes.Int64:
+		builder := array.NewInt64Builder(mem)
+		defer builder.Release()
+		for _, value := range values {
+			if n, ok := toInt64(value); ok {
+				builder.Append(n)
+			} else {
+				builder.AppendNull()
+			}
+		}
+		return builder.NewArray(), nil
+	case arrow.PrimitiveTypes.Float64:
+		builder := array.NewFloat64Builder(mem)
+		defer builder.Release()
+		for _, value := range values {
+			if f, ok := toFloat64(value); ok {
+				builder.Append(f)
+			} else {
+				builder.AppendNull()
+			}
+		}
+		return builder.NewArray(), nil
+	case arrow.BinaryTypes.String:
+		builder := array.NewStringBuilder(mem)
+		defer builder.Release()
+		for _, value := range values {
+			if value == nil {
+				builder.AppendNull()
+			} else {
+				builder.Append(value.(string))
+			}
+		}
+		return builder.NewArray(), nil
+	default:
+		builder := array.NewTimestampBuilder(mem, kind.(*arrow.TimestampType))
+		defer builder.Release()
+		for _, value := range values {
+			if value == nil {
+				builder.AppendNull()
+			} else {
+				builder.Append(arrow.Timestamp(value.(time.Time).UnixMicro()))
+			}
+		}
+		return builder.NewArray(), nil
+	}
+}
+
+// decodeArrowValue reads the Arrow file a Python cell wrote for a DataFrame or
+// Series into a DataTable or DataList
+func decodeArrowValue(v map[string]any) (any, error) {
+	path, _ := v["path"].(string)
+	if path == "" {
+		return nil, errors.New("arrow value has no path")
+	}
+	names, columns, err := readArrowFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read arrow data: %w", err)
+	}
+	var rowNames []string
+	if index, _ := v["index"].(string); index != "" {
+		for i, name := range names {
+			if name != index {
+				continue
+			}
+			rowNames = make([]string, len(columns[i]))
+			for j, value := range columns[i] {
+				if value != nil {
+					rowNames[j] = fmt.Sprint(value)
+				}
+			}
+			names = append(names[:i:i], names[i+1:]...)
+			columns = append(columns[:i:i], columns[i+1:]...)
+			break
+		}
+	}
+
+	if v["kind"] == "datalist" {
+		if len(columns) == 0 {
+			return nil, errors.New("arrow data has no column")
+		}
+		list := insyra.NewDataList(columns[0]...)
+		if name, _ := v["name"].(string); name != "" {
+			list.SetName(name)
+		}
+		return list, nil
+	}
+	table := insyra.NewDataTable()
+	if len(columns) > 0 && len(columns[0]) > 0 {
+		rows := make([][]any, len(columns[0]))
+		for i := range rows {
+			rows[i] = make([]any, len(columns))
+			for j, column := range columns {
+				rows[i][j] = column[i]
+			}
+		}
+		if table, err = insyra.Slice2DToDataTable(rows); err != nil {
+			return nil, err
+		}
+	}
+	if hasNonEmptyStrings(names) {
+		table.SetColNames(names)
+	}
+	if hasNonEmptyStrings(rowNames) {
+		table.SetRowNames(rowNames)
+	}
+	return table, nil
+}
+
+// readArrowFile reads the columns of every record batch of an Arrow IPC file
+func readArrowFile(path string) ([]string, [][]any, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	reader, err := ipc.NewFileReader(file, ipc.WithAllocator(memory.NewGoAllocator()))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	fields := reader.Schema().Fields()
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	columns := make([][]any, len(fields))
+	for i := 0; i < reader.NumRecords(); i++ {
+		record, err := reader.Record(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, column := range record.Columns() {
+			if columns[j], err = appendArrowValues(columns[j], column); err != nil {
+				return nil, nil, fmt.Errorf("column %s: %w", names[j], err)
+			}
+		}
+	}
+	return names, columns, nil
+}
+
+// appendArrowValues converts the values of an Arrow array to the Go values the
+// JSON transfer produces, with nulls as nil
+func appendArrowValues(dst []any, arr arrow.Array) ([]any, error) {
+	n := arr.Len()
+	each := func(value func(i int) any) []any {
+		for i := 0; i < n; i++ {
+			if arr.IsNull(i) {
+				dst = append(dst, nil)
+			} else {
+				dst = append(dst, value(i))
+			}
+		}
+		return dst
+	}
+	switch a := arr.(type) {
+	case *array.Null:
+		return append(dst, make([]any, n)...), nil
+	case *array.Boolean:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.Int8:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Int16:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Int32:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Int64:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.Uint8:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Uint16:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Uint32:
+		return each(func(i int) any { return int64(a.Value(i)) }), nil
+	case *array.Uint64:
+		return each(func(i int) any {
+			if n, ok := toInt64(a.Value(i)); ok {
+				return n
+			}
+			return a.Value(i)
+		}), nil
+	case *array.Float16:
+		return each(func(i int) any { return float64(a.Value(i).Float32()) }), nil
+	case *array.Float32:
+		return each(func(i int) any { return float64(a.Value(i)) }), nil
+	case *array.Float64:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.String:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.LargeString:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.StringView:
+		return each(func(i int) any { return a.Value(i) }), nil
+	case *array.Binary:
+		return each(func(i int) any { return append([]byte(nil), a.Value(i)...) }), nil
+	case *array.LargeBinary:
+		return each(func(i int) any { return append([]byte(nil), a.Value(i)...) }), nil
+	case *array.BinaryView:
+		return each(func(i int) any { return append([]byte(nil), a.Value(i)...) }), nil
+	case *array.Timestamp:
+		unit := a.DataType().(*arrow.TimestampType)
+		loc := time.UTC
+		if unit.TimeZone != "" && unit.TimeZone != "UTC" {
+			if l, err := time.LoadLocation(unit.TimeZone); err == nil {
+				loc = l
+			}
+		}
+		return each(func(i int) any { return a.Value(i).ToTime(unit.Unit).In(loc) }), nil
+	case *array.Date32:
+		return each(func(i int) any { return a.Value(i).ToTime() }), nil
+	case *array.Date64:
+		return each(func(i int) any { return a.Value(i).ToTime() }), nil
+	case *array.Duration:
+		multiplier := a.DataType().(*arrow.DurationType).Unit.Multiplier()
+		return each(func(i int) any { return time.Duration(a.Value(i)) * multiplier }), nil
+	case *array.Dictionary:
+		values, err := appendArrowValues(nil, a.Dictionary())
+		if err != nil {
+			return nil, err
+		}
+		return each(func(i int) any { return values[a.GetValueIndex(i)] }), nil
+	}
+	switch arr.DataType().ID() {
+	case arrow.DECIMAL128, arrow.DECIMAL256, arrow.TIME32, arrow.TIME64, arrow.INTERVAL_MONTHS, arrow.INTERVAL_DAY_TIME, arrow.INTERVAL_MONTH_DAY_NANO:
+		return each(func(i int) any { return arr.ValueStr(i) }), nil
+	}
+	return nil, fmt.Errorf("unsupported arrow type %s", arr.DataType())
+}
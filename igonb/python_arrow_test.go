@@ -0,0 +1,129 @@
+package igonb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HazelnutParadise/insyra"
+)
+
+func TestPythonArrowTableRoundTrip(t *testing.T) {
+	stamp := time.Date(2024, 5, 6, 7, 8, 9, 123000, time.UTC)
+	table, err := insyra.Slice2DToDataTable([][]any{
+		{1, "a", 1.5, true, stamp},
+		{2, nil, 2, false, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.SetColNames([]string{"n", "s", "f", "b", "t"})
+	table.SetRowNames([]string{"x", "y"})
+
+	transfer := &pythonArrowTransfer{dir: t.TempDir(), threshold: 10, toPython: true}
+	binding, ok := transfer.encodeArrowBinding(table)
+	if !ok {
+		t.Fatal("expected the table to be written as an arrow file")
+	}
+	if binding["index"] != arrowIndexColumn || binding["named"] != true {
+		t.Fatalf("unexpected binding %v", binding)
+	}
+
+	decoded, err := decodeFromPython(binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := decoded.(pythonDataTable)
+	if !ok {
+		t.Fatalf("decoded %T, want a DataTable", decoded)
+	}
+	want := [][]any{
+		{int64(1), "a", 1.5, true, stamp},
+		{int64(2), nil, 2.0, false, nil},
+	}
+	if !reflect.DeepEqual(got.To2DSlice(), want) {
+		t.Errorf("rows = %#v, want %#v", got.To2DSlice(), want)
+	}
+	if !reflect.DeepEqual(got.ColNames(), []string{"n", "s", "f", "b", "t"}) {
+		t.Errorf("columns = %v", got.ColNames())
+	}
+	if !reflect.DeepEqual(got.RowNames(), []string{"x", "y"}) {
+		t.Errorf("index = %v", got.RowNames())
+	}
+}
+
+// arrowTestTable is a DataTable whose row names need not match its rows
+type arrowTestTable struct {
+	rows     [][]any
+	rowNames []string
+}
+
+func (a arrowTestTable) To2DSlice() [][]any { return a.rows }
+func (a arrowTestTable) ColNames() []string { return []string{"a", "b"} }
+func (a arrowTestTable) RowNames() []string { return a.rowNames }
+
+func TestPythonArrowIndexNeedsAllRowNames(t *testing.T) {
+	rows := [][]any{{1, 2}, {3, 4}, {5, 6}}
+	transfer := &pythonArrowTransfer{dir: t.TempDir(), threshold: 4, toPython: true}
+	for _, rowNames := range [][]string{nil, {"", "", ""}, {"x", "y"}} {
+		binding, ok := transfer.encodeArrowBinding(arrowTestTable{rows: rows, rowNames: rowNames})
+		if !ok {
+			t.Fatal("expected the table to be written as an arrow file")
+		}
+		if _, ok := binding["index"]; ok {
+			t.Errorf("row names %q: binding names an index column that was not written", rowNames)
+		}
+		names, _, err := readArrowFile(binding["path"].(string))
+		if err != nil || !reflect.DeepEqual(names, []string{"a", "b"}) {
+			t.Errorf("row names %q: columns = %v, %v", rowNames, names, err)
+		}
+	}
+}
+
+func TestPythonArrowThreshold(t *testing.T) {
+	list := insyra.NewDataList(1, 2, 3)
+	list.SetName("small")
+	transfer := &pythonArrowTransfer{dir: t.TempDir(), threshold: 4, toPython: true}
+	if _, ok := transfer.encodeArrowBinding(list); ok {
+		t.Error("a list below the threshold should use JSON")
+	}
+
+	list = insyra.NewDataList(1, 2.5, nil, 4)
+	list.SetName("large")
+	binding, ok := transfer.encodeArrowBinding(list)
+	if !ok {
+		t.Fatal("expected the list to be written as an arrow file")
+	}
+	decoded, err := decodeFromPython(binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := decoded.(pythonDataList)
+	if !ok {
+		t.Fatalf("decoded %T, want a DataList", decoded)
+	}
+	if want := []any{1.0, 2.5, nil, 4.0}; !reflect.DeepEqual(got.Data(), want) || got.GetName() != "large" {
+		t.Errorf("list = %s %#v, want large %#v", got.GetName(), got.Data(), want)
+	}
+
+	if _, ok := transfer.encodeArrowBinding(insyra.NewDataList(1, "a", 2, 3)); ok {
+		t.Error("a list of mixed types should use JSON")
+	}
+	transfer.toPython = false
+	if _, ok := transfer.encodeArrowBinding(list); ok {
+		t.Error("arrow should not be used before Python reported it can read it")
+	}
+
+	for _, tc := range []struct {
+		metadata map[string]any
+		want     int
+	}{
+		{nil, DefaultPythonArrowThreshold},
+		{map[string]any{MetaPythonArrowThreshold: float64(10)}, 10},
+		{map[string]any{MetaPythonArrowThreshold: float64(0)}, 0},
+	} {
+		if got := pythonArrowThreshold(&Notebook{Metadata: tc.metadata}); got != tc.want {
+			t.Errorf("threshold for %v = %d, want %d", tc.metadata, got, tc.want)
+		}
+	}
+}
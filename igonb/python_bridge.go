@@ -1,16 +1,21 @@
 package igonb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go/token"
+	"maps"
 	"math"
+	"os"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/HazelnutParadise/insyra"
 )
@@ -20,6 +25,14 @@ type pythonRunPayload struct {
 	Error string         `json:"error"`
 	State string         `json:"state"`
 	Defs  []pythonDef    `json:"defs"`
+	// Skipped maps the Python variables that were not exported to the reason
+	Skipped map[string]string `json:"skipped"`
+	// Missing lists the variables named by %export that Python does not define
+	Missing []string `json:"missing"`
+	// Unrestored maps the Go variables Python could not restore to the reason
+	Unrestored map[string]string `json:"unrestored"`
+	// Arrow reports whether pyarrow or polars can read Arrow files
+	Arrow bool `json:"arrow"`
 }
 
 type pythonDef struct {
@@ -27,11 +40,6 @@ type pythonDef struct {
 	Source string `json:"source"`
 }
 
-type pythonBinding struct {
-	name  string
-	value any
-}
-
 var errUnsupportedGoValue = errors.New("unsupported go value")
 
 type unsupportedGoValueError struct {
@@ -49,7 +57,7 @@ func (e unsupportedGoValueError) Unwrap() error {
 	return errUnsupportedGoValue
 }
 
-func (e *Executor) runPythonCell(code string) (string, error) {
+func (e *Executor) runPythonCell(nb *Notebook, code string) (string, error) {
 	if strings.TrimSpace(code) == "" {
 		return "", nil
 	}
@@ -66,10 +74,33 @@ func (e *Executor) runPythonCell(code string) (string, error) {
 		return "", ErrExecutionStopped
 	}
 
+	code, magics, err := parsePythonTransferMagics(code)
+	if err != nil {
+		return "", err
+	}
+	explicit := variableTransferMode(nb) == TransferExplicit
+
 	shared := e.snapshotSharedVars()
 	state := e.snapshotPythonState()
 	defs := e.snapshotPythonDefs()
-	bindings := e.buildPythonBindings(shared)
+	arrow := &pythonArrowTransfer{threshold: pythonArrowThreshold(nb), toPython: e.pythonArrowReady()}
+	if arrow.threshold > 0 {
+		dir, err := os.MkdirTemp("", "igonb-python-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create arrow transfer directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		arrow.dir = dir
+	}
+	var warnings []string
+	if explicit {
+		shared, warnings = e.selectPythonImports(shared, magics.imports)
+	}
+	bindings, bindingWarnings := e.buildPythonBindings(shared, arrow)
+	warnings = append(warnings, bindingWarnings...)
+	if explicit {
+		bindings[pythonExportsKey] = append([]string{}, magics.exports...)
+	}
 	defsJSON, err := json.Marshal(defs)
 	if err != nil {
 		return "", err
@@ -80,7 +111,7 @@ func (e *Executor) runPythonCell(code string) (string, error) {
 	}
 
 	// Serialize bindings and prepare wrapper template and args. Use py.RunCodefContext to pass args.
-	bindingsData, err := serializeBindingsToJSON(bindings)
+	bindingsData, err := json.Marshal(bindings)
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize bindings: %w", err)
 	}
@@ -101,8 +132,18 @@ func (e *Executor) runPythonCell(code string) (string, error) {
 	if err != nil {
 		return output, err
 	}
-	if err := e.applyPythonPayload(payload); err != nil {
-		return output, err
+	if payload.Arrow {
+		e.setPythonArrowReady()
+	}
+	for _, name := range slices.Sorted(maps.Keys(payload.Unrestored)) {
+		warnings = e.transferWarning(warnings, name, "Python", payload.Unrestored[name])
+	}
+	warnings = append(warnings, e.applyPythonPayload(payload)...)
+	if len(warnings) > 0 {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += strings.Join(warnings, "\n") + "\n"
 	}
 	if payload.Error != "" {
 		return output, fmt.Errorf("%s", payload.Error)
@@ -167,155 +208,135 @@ func (e *Executor) updatePythonDefs(defs []pythonDef) {
 	}
 }
 
-func (e *Executor) buildPythonBindings(shared map[string]any) []pythonBinding {
-	if len(shared) == 0 {
-		return nil
-	}
+// buildPythonBindings encodes the shared variables for Python, leaving out
+// and warning about the ones it cannot convert. Variables still holding what
+// Python exported are listed under pythonKeepKey, so Python keeps its own
+// objects for them, like dataclasses and DataFrame dtypes. Large tables are
+// written to Arrow files when arrow allows it
+func (e *Executor) buildPythonBindings(shared map[string]any, arrow *pythonArrowTransfer) (map[string]any, []string) {
 	names := make([]string, 0, len(shared))
 	for name := range shared {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 
-	bindings := make([]pythonBinding, 0, len(names))
+	bindings := make(map[string]any, len(names)+2)
+	keep := []string{}
+	var warnings []string
 	for _, name := range names {
-		value := shared[name]
-		if !canPassToPython(value) {
+		if e.isPythonExport(name, shared[name]) {
+			keep = append(keep, name)
+		}
+		if encoded, ok := arrow.encodeArrowBinding(shared[name]); ok {
+			e.transferWarning(nil, name, "Python", "")
+			bindings[name] = encoded
 			continue
 		}
-		bindings = append(bindings, pythonBinding{
-			name:  name,
-			value: value,
-		})
+		encoded, err := encodeForPython(shared[name])
+		if err != nil {
+			warnings = e.transferWarning(warnings, name, "Python", err.Error())
+			continue
+		}
+		e.transferWarning(nil, name, "Python", "")
+		bindings[name] = encoded
 	}
-	return bindings
+	bindings[pythonKeepKey] = keep
+	if arrow != nil && arrow.dir != "" {
+		bindings[pythonArrowKey] = map[string]any{"dir": arrow.dir, "threshold": arrow.threshold}
+	}
+	if env := e.pythonEnvDir(); env != "" {
+		bindings[pythonEnvKey] = env
+	}
+	return bindings, warnings
 }
 
-// serializeBindingsToJSON converts bindings to JSON for passing to Python via file
-func serializeBindingsToJSON(bindings []pythonBinding) ([]byte, error) {
-	if len(bindings) == 0 {
-		return []byte("{}"), nil
-	}
+// pythonKeepKey is the binding that lists the variables Python keeps
+const pythonKeepKey = "__igonb_keep"
 
-	result := make(map[string]any, len(bindings))
-	for _, binding := range bindings {
-		converted := convertValueForPythonJSON(binding.value)
-		result[binding.name] = converted
+// pythonExportsKey is the binding that lists the variables Python exports in
+// TransferExplicit mode; without it Python exports every variable it can
+const pythonExportsKey = "__igonb_exports"
+
+// selectPythonImports returns the shared variables a Python cell imports with
+// %import, warning about the ones no earlier cell exported
+func (e *Executor) selectPythonImports(shared map[string]any, names []string) (map[string]any, []string) {
+	selected := make(map[string]any, len(names))
+	var warnings []string
+	for _, name := range names {
+		value, ok := shared[name]
+		if !ok {
+			warnings = e.transferWarning(warnings, name, "Python", "not exported by an earlier cell")
+			continue
+		}
+		selected[name] = value
 	}
+	return selected, warnings
+}
 
-	return json.Marshal(result)
+// pythonArrowKey is the binding with the directory and threshold Python uses
+// to write large DataFrames and Series as Arrow files
+const pythonArrowKey = "__igonb_arrow"
+
+// pythonArrowReady reports whether Python cells have been seen to read Arrow files
+func (e *Executor) pythonArrowReady() bool {
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	return e.pythonArrow
 }
 
-// convertValueForPythonJSON converts Go values to Python-compatible JSON representations
-func convertValueForPythonJSON(value any) any {
-	if value == nil {
-		return nil
-	}
+func (e *Executor) setPythonArrowReady() {
+	e.sharedMu.Lock()
+	e.pythonArrow = true
+	e.sharedMu.Unlock()
+}
 
-	if dl, ok := value.(insyra.IDataList); ok && dl != nil {
-		return map[string]any{
-			"__igonb_type__": "datalist",
-			"data":           dl.Data(),
-			"name":           dl.GetName(),
-		}
+// setPythonExport records the value a Python cell exported for name, or
+// forgets it when value is nil
+func (e *Executor) setPythonExport(name string, value any) {
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	if value == nil {
+		delete(e.pythonExports, name)
+		return
 	}
-	if dt, ok := value.(insyra.IDataTable); ok && dt != nil {
-		return map[string]any{
-			"__igonb_type__": "datatable",
-			"data":           dt.To2DSlice(),
-			"columns":        dt.ColNames(),
-			"index":          dt.RowNames(),
-		}
-	}
-
-	rv := reflect.ValueOf(value)
-	if !rv.IsValid() {
-		return nil
+	if e.pythonExports == nil {
+		e.pythonExports = make(map[string]any)
 	}
+	e.pythonExports[name] = value
+}
 
-	// Handle pointers
-	if rv.Kind() == reflect.Ptr {
-		if rv.IsNil() {
-			return nil
-		}
-		rv = rv.Elem()
-		value = rv.Interface()
-	}
+// isPythonExport reports whether value is still what Python exported for name
+func (e *Executor) isPythonExport(name string, value any) bool {
+	e.sharedMu.Lock()
+	exported, ok := e.pythonExports[name]
+	e.sharedMu.Unlock()
+	return ok && reflect.DeepEqual(exported, value)
+}
 
-	// Handle IDataList
-	if isInsyraDataListType(rv.Type()) || isInsyraIDataListType(rv.Type()) || isIsrDlType(rv.Type()) {
-		if dl, ok := value.(interface {
-			Data() []any
-			GetName() string
-		}); ok {
-			return map[string]any{
-				"__igonb_type__": "datalist",
-				"data":           dl.Data(),
-				"name":           dl.GetName(),
-			}
-		}
-		if rv.CanAddr() {
-			if dl, ok := rv.Addr().Interface().(interface {
-				Data() []any
-				GetName() string
-			}); ok {
-				return map[string]any{
-					"__igonb_type__": "datalist",
-					"data":           dl.Data(),
-					"name":           dl.GetName(),
-				}
-			}
-		}
+// transferWarning adds the transfer of name to a language to the report of the
+// running cell and appends a warning that it was not passed, once for each
+// reason; an empty reason records that it was passed
+func (e *Executor) transferWarning(warnings []string, name, language, reason string) []string {
+	transfer := Transfer{Name: name, From: "go", To: "python", Skipped: reason != "", Reason: reason}
+	if language == "Go" {
+		transfer.From, transfer.To = "python", "go"
 	}
-
-	// Handle IDataTable
-	if isInsyraDataTableType(rv.Type()) || isInsyraIDataTableType(rv.Type()) || isIsrDtType(rv.Type()) {
-		if dt, ok := value.(interface {
-			To2DSlice() [][]any
-			ColNames() []string
-			RowNames() []string
-		}); ok {
-			return map[string]any{
-				"__igonb_type__": "datatable",
-				"data":           dt.To2DSlice(),
-				"columns":        dt.ColNames(),
-				"index":          dt.RowNames(),
-			}
-		}
-		if rv.CanAddr() {
-			if dt, ok := rv.Addr().Interface().(interface {
-				To2DSlice() [][]any
-				ColNames() []string
-				RowNames() []string
-			}); ok {
-				return map[string]any{
-					"__igonb_type__": "datatable",
-					"data":           dt.To2DSlice(),
-					"columns":        dt.ColNames(),
-					"index":          dt.RowNames(),
-				}
-			}
-		}
+	key := language + "\x00" + name
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	e.transfers = append(e.transfers, transfer)
+	if reason == "" {
+		delete(e.transferWarned, key)
+		return warnings
 	}
-
-	// Handle basic types that JSON can serialize directly
-	switch rv.Kind() {
-	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-		reflect.Float32, reflect.Float64, reflect.String:
-		return value
-	case reflect.Slice, reflect.Array:
-		return value
-	case reflect.Map:
-		return value
+	if e.transferWarned[key] == reason {
+		return warnings
 	}
-
-	// Try JSON marshal for other types
-	if _, err := json.Marshal(value); err == nil {
-		return value
+	if e.transferWarned == nil {
+		e.transferWarned = make(map[string]string)
 	}
-
-	return nil
+	e.transferWarned[key] = reason
+	return append(warnings, fmt.Sprintf("warning: %s was not passed to %s: %s", name, language, reason))
 }
 
 func (e *Executor) goNameExists(name string) bool {
@@ -345,6 +366,8 @@ try:
 	__igonb_injected = json.loads($v4)
 except Exception:
 	__igonb_injected = {}
+__igonb_export_names = __igonb_injected.pop("__igonb_exports", None)
+__igonb_env = __igonb_injected.pop("__igonb_env", None)
 __igonb_globals = globals()
 __igonb_reserved = {
 	"insyra",
@@ -439,135 +462,39 @@ def __igonb_dump_state(globs):
 	except Exception:
 		return ""
 
-def __igonb_restore_value(value):
-	if isinstance(value, dict):
-		vtype = value.get("__igonb_type__")
-		if vtype == "datalist":
-			try:
-				import pandas as pd
-				data = value.get("data", [])
-				name = value.get("name", None)
-				if name == "":
-					name = None
-				return pd.Series(data, name=name)
-			except Exception:
-				return value.get("data", [])
-		if vtype == "datatable":
-			try:
-				import pandas as pd
-				data = value.get("data", [])
-				df = pd.DataFrame(data)
-				cols = value.get("columns", [])
-				if isinstance(cols, list) and any(str(c).strip() != "" for c in cols):
-					df.columns = cols
-				rows = value.get("index", [])
-				if isinstance(rows, list) and any(str(r).strip() != "" for r in rows):
-					df.index = rows
-				return df
-			except Exception:
-				return value.get("data", [])
-		if vtype == "pyobject" and value.get("pickle"):
-			try:
-				data = base64.b64decode(value["pickle"].encode("utf-8"))
-				return pickle.loads(data)
-			except Exception:
-				return value
-		if vtype == "pyrepr":
-			return value.get("repr", "")
-	return value
-
-def __igonb_sanitize_for_json(value):
-	import math
-	if isinstance(value, float):
-		if math.isnan(value):
-			return None
-		if math.isinf(value):
-			return None
-	if isinstance(value, list):
-		return [__igonb_sanitize_for_json(v) for v in value]
-	if isinstance(value, dict):
-		return {k: __igonb_sanitize_for_json(v) for k, v in value.items()}
-	return value
-
-def __igonb_export_value(value):
-	try:
-		import pandas as pd
-		if isinstance(value, pd.Series):
-			data = value.where(pd.notnull(value), None).tolist()
-			return True, {
-				"__igonb_type__": "datalist",
-				"data": __igonb_sanitize_for_json(data),
-				"name": value.name if value.name is not None else "",
-			}
-		if isinstance(value, pd.DataFrame):
-			df_clean = value.where(pd.notnull(value), None)
-			data = df_clean.to_numpy().tolist()
-			return True, {
-				"__igonb_type__": "datatable",
-				"data": __igonb_sanitize_for_json(data),
-				"columns": list(value.columns),
-				"index": list(value.index),
-			}
-	except Exception:
-		pass
-	try:
-		import numpy as np
-		if isinstance(value, np.ndarray):
-			return True, __igonb_sanitize_for_json(value.tolist())
-		if isinstance(value, np.generic):
-			item = value.item()
-			return True, __igonb_sanitize_for_json(item)
-	except Exception:
-		pass
-	if value is None:
-		return True, None
-	if isinstance(value, (str, int, float, bool)):
-		return True, value
-	try:
-		import json
-		if isinstance(value, (list, tuple)):
-			json.dumps(value)
-			return True, list(value)
-		if isinstance(value, dict):
-			json.dumps(value)
-			return True, value
-	except Exception:
-		pass
-	try:
-		data = pickle.dumps(value)
-		return True, {
-			"__igonb_type__": "pyobject",
-			"pickle": base64.b64encode(data).decode("utf-8"),
-			"repr": repr(value),
-			"pytype": type(value).__name__,
-		}
-	except Exception:
-		pass
-	try:
-		return True, {
-			"__igonb_type__": "pyrepr",
-			"repr": repr(value),
-			"pytype": type(value).__name__,
-		}
-	except Exception:
-		return False, None
-
-def __igonb_export(globs):
+` + pythonConvertHelpers + pythonEnvHelpers + `
+def __igonb_export(globs, names=None):
 	exported = {}
-	for key, value in globs.items():
+	skipped = {}
+	missing = []
+	for key in (list(globs) if names is None else names):
+		if key not in globs:
+			missing.append(key)
+			continue
+		value = globs[key]
 		if key.startswith("_") or key in __igonb_reserved:
+			if names is not None:
+				skipped[key] = "reserved name"
 			continue
-		if isinstance(value, (types.ModuleType, types.FunctionType)):
+		if isinstance(value, (types.ModuleType, types.FunctionType, types.BuiltinFunctionType, type)):
+			if names is not None:
+				skipped[key] = "cannot export " + type(value).__name__
 			continue
-		ok, converted = __igonb_export_value(value)
-		if ok:
-			exported[key] = converted
-	return exported
+		try:
+			exported[key] = __igonb_encode(value)
+		except __IgonbUnsupported as exc:
+			skipped[key] = str(exc)
+		except Exception as exc:
+			skipped[key] = "failed to convert " + type(value).__name__ + ": " + str(exc)
+	return exported, skipped, missing
 
 __igonb_error = ""
 __igonb_state = ""
 __igonb_new_defs = []
+__igonb_unrestored = {}
 try:
+	if __igonb_env:
+		__igonb_use_env(__igonb_env)
 	__igonb_defs = []
 	if __igonb_defs_json:
 		try:
@@ -581,8 +508,17 @@ try:
 			except Exception:
 				pass
 	__igonb_load_state(__igonb_state_b64, __igonb_globals)
+	__igonb_keep = set(__igonb_injected.pop("__igonb_keep", []))
+	__igonb_arrow.update(__igonb_injected.pop("__igonb_arrow", None) or {})
 	for _k, _v in __igonb_injected.items():
-		__igonb_globals[_k] = __igonb_restore_value(_v)
+		if _k in __igonb_keep and _k in __igonb_globals:
+			continue
+		try:
+			__igonb_globals[_k] = __igonb_restore(_v)
+		except Exception as exc:
+			# an older value of the variable must not be exported over the Go one
+			__igonb_globals.pop(_k, None)
+			__igonb_unrestored[_k] = "failed to restore: " + str(exc)
 	_value = __igonb_exec(__igonb_code, __igonb_globals)
 	if _value is not None:
 		print(_value)
@@ -590,11 +526,11 @@ except Exception:
 	__igonb_error = traceback.format_exc()
 
 try:
-	__igonb_vars = __igonb_export(__igonb_globals)
+	__igonb_vars, __igonb_skipped, __igonb_missing = __igonb_export(__igonb_globals, __igonb_export_names)
 except Exception:
 	if not __igonb_error:
 		__igonb_error = traceback.format_exc()
-	__igonb_vars = {}
+	__igonb_vars, __igonb_skipped, __igonb_missing = {}, {}, []
 
 try:
 	__igonb_state = __igonb_dump_state(__igonb_globals)
@@ -606,7 +542,12 @@ try:
 except Exception:
 	__igonb_new_defs = []
 
-insyra.Return({"vars": __igonb_vars, "error": __igonb_error, "state": __igonb_state, "defs": __igonb_new_defs}, None)
+try:
+	__igonb_arrow_ok = __igonb_arrow_support()
+except Exception:
+	__igonb_arrow_ok = False
+
+insyra.Return({"vars": __igonb_vars, "skipped": __igonb_skipped, "missing": __igonb_missing, "unrestored": __igonb_unrestored, "arrow": __igonb_arrow_ok, "error": __igonb_error, "state": __igonb_state, "defs": __igonb_new_defs}, None)
 `
 
 	// wrapper is a template that expects 4 %s replacements: code, state_b64, defs_json, bindings_json
@@ -690,91 +631,64 @@ func decodePythonPayload(value any, payload *pythonRunPayload) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(raw, payload)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	return decoder.Decode(payload)
 }
 
-func (e *Executor) applyPythonPayload(payload pythonRunPayload) error {
+// applyPythonPayload stores the variables a Python cell exported in the session
+// and in Go, and returns warnings about the ones it could not pass to Go
+func (e *Executor) applyPythonPayload(payload pythonRunPayload) []string {
 	if payload.State != "" {
 		e.setPythonState(payload.State)
 	}
 	if len(payload.Defs) > 0 {
 		e.updatePythonDefs(payload.Defs)
 	}
-	if len(payload.Vars) == 0 {
-		return nil
+	var warnings []string
+	for _, name := range payload.Missing {
+		warnings = e.transferWarning(warnings, name, "Go", "not defined in Python")
+	}
+	for _, name := range slices.Sorted(maps.Keys(payload.Skipped)) {
+		if strings.HasPrefix(name, "__igonb") {
+			continue
+		}
+		// the Python session keeps the value; an older shared one must not replace it
+		e.deleteSharedVar(name)
+		e.setPythonExport(name, nil)
+		warnings = e.transferWarning(warnings, name, "Go", payload.Skipped[name])
 	}
-	for name, value := range payload.Vars {
+	for _, name := range slices.Sorted(maps.Keys(payload.Vars)) {
 		if name == "" || strings.HasPrefix(name, "__igonb") {
 			continue
 		}
-		goValue, ok := convertPythonValue(value)
-		if !ok {
+		goValue, err := decodeFromPython(payload.Vars[name])
+		if err != nil {
+			e.deleteSharedVar(name)
+			e.setPythonExport(name, nil)
+			warnings = e.transferWarning(warnings, name, "Go", err.Error())
 			continue
 		}
 		e.setSharedVar(name, goValue)
+		e.setPythonExport(name, goValue)
 		if !isGoIdentifier(name) {
+			warnings = e.transferWarning(warnings, name, "Go", "not a valid Go identifier")
 			continue
 		}
 		if err := e.setGoVariable(name, goValue); err != nil {
+			reason := err.Error()
 			if errors.Is(err, errUnsupportedGoValue) {
-				continue
-			}
-			return err
-		}
-	}
-	return nil
-}
-
-func convertPythonValue(value any) (any, bool) {
-	if value == nil {
-		return nil, true
-	}
-	rawMap, ok := value.(map[string]any)
-	if !ok {
-		return value, true
-	}
-	typeTag, ok := rawMap["__igonb_type__"].(string)
-	if !ok {
-		return value, true
-	}
-	switch typeTag {
-	case "datalist":
-		data, ok := coerceAnySlice(rawMap["data"])
-		if !ok {
-			return nil, false
-		}
-		list := insyra.NewDataList(data...)
-		if name, ok := rawMap["name"].(string); ok && name != "" {
-			list.SetName(name)
-		}
-		return list, true
-	case "datatable":
-		rows, ok := coerce2DAnySlice(rawMap["data"])
-		if !ok {
-			return nil, false
-		}
-		var table *insyra.DataTable
-		if len(rows) == 0 {
-			table = insyra.NewDataTable()
-		} else {
-			var err error
-			table, err = insyra.Slice2DToDataTable(rows)
-			if err != nil {
-				return nil, false
+				reason = fmt.Sprintf("cannot assign %T to its Go variable", goValue)
+				if targetType := e.goVarType(name); targetType != nil {
+					reason = fmt.Sprintf("cannot assign %T to its Go variable of type %s", goValue, targetType)
+				}
 			}
+			warnings = e.transferWarning(warnings, name, "Go", reason)
+			continue
 		}
-		cols := coerceStringSlice(rawMap["columns"])
-		if hasNonEmptyStrings(cols) {
-			table.SetColNames(cols)
-		}
-		index := coerceStringSlice(rawMap["index"])
-		if hasNonEmptyStrings(index) {
-			table.SetRowNames(index)
-		}
-		return table, true
-	default:
-		return value, true
+		e.transferWarning(nil, name, "Go", "")
 	}
+	return warnings
 }
 
 func (e *Executor) setGoVariable(name string, value any) error {
@@ -904,27 +818,9 @@ func (e *Executor) ensureLiteralImports(literal string) {
 	if strings.Contains(literal, "isr.") {
 		_ = e.PreloadGoImports([]string{"github.com/HazelnutParadise/insyra/isr"})
 	}
-}
-
-func canPassToPython(value any) bool {
-	if value == nil {
-		return true
-	}
-	switch value.(type) {
-	case insyra.IDataList, insyra.IDataTable:
-		return true
-	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-		return true
-	case []any, []string, []float64, []int, []bool, map[string]any:
-		return true
-	}
-	if _, ok := value.(map[any]any); ok {
-		return false
-	}
-	if _, err := json.Marshal(value); err == nil {
-		return true
+	if strings.Contains(literal, "time.") {
+		_ = e.PreloadGoImports([]string{"time"})
 	}
-	return false
 }
 
 func isGoIdentifier(name string) bool {
@@ -1074,6 +970,12 @@ func formatGoLiteral(value any) (string, bool) {
 		return formatFloatLiteral(float64(v)), true
 	case float64:
 		return formatFloatLiteral(v), true
+	case time.Time:
+		return formatTimeLiteral(v), true
+	case time.Duration:
+		return fmt.Sprintf("time.Duration(%d)", int64(v)), true
+	case []byte:
+		return fmt.Sprintf("[]byte(%s)", strconv.Quote(string(v))), true
 	case []any:
 		return formatAnySliceLiteral(v)
 	case []string:
@@ -1082,6 +984,8 @@ func formatGoLiteral(value any) (string, bool) {
 		return format2DAnySliceLiteral(v)
 	case map[string]any:
 		return formatAnyMapLiteral(v)
+	case map[any]any:
+		return formatMapLiteralForType(v, reflect.TypeOf(v))
 	case insyra.IDataList:
 		return formatDataListLiteral(v)
 	case insyra.IDataTable:
@@ -1147,6 +1051,12 @@ func formatValueForType(value any, targetType reflect.Type) (string, bool) {
 		}
 		return "", false
 	}
+	if targetType == timeType {
+		if t, ok := value.(time.Time); ok {
+			return formatTimeLiteral(t), true
+		}
+		return "", false
+	}
 	switch targetType.Kind() {
 	case reflect.Interface:
 		if targetType.NumMethod() == 0 {
@@ -1166,6 +1076,8 @@ func formatValueForType(value any, targetType reflect.Type) (string, bool) {
 		return formatArrayLiteralForType(value, targetType)
 	case reflect.Map:
 		return formatMapLiteralForType(value, targetType)
+	case reflect.Struct:
+		return formatStructLiteralForType(value, targetType)
 	default:
 		valueType := reflect.TypeOf(value)
 		if valueType != nil && valueType.AssignableTo(targetType) {
@@ -1219,6 +1131,9 @@ func formatSliceLiteralForType(value any, targetType reflect.Type) (string, bool
 	if value == nil {
 		return "nil", true
 	}
+	if data, ok := value.([]byte); ok && targetType.Elem().Kind() == reflect.Uint8 {
+		return fmt.Sprintf("%s(%s)", targetType, strconv.Quote(string(data))), true
+	}
 	rv := reflect.ValueOf(value)
 	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
 		return "", false
@@ -1284,30 +1199,70 @@ func formatMapLiteralForType(value any, targetType reflect.Type) (string, bool)
 	if rv.Kind() != reflect.Map {
 		return "", false
 	}
-	if targetType.Key().Kind() != reflect.String || rv.Type().Key().Kind() != reflect.String {
-		return "", false
-	}
 	keys := rv.MapKeys()
-	names := make([]string, 0, len(keys))
+	entries := make([][2]string, 0, len(keys))
 	for _, key := range keys {
-		names = append(names, key.String())
+		keyLiteral, ok := formatValueForType(key.Interface(), targetType.Key())
+		if !ok {
+			return "", false
+		}
+		valueLiteral, ok := formatValueForType(rv.MapIndex(key).Interface(), targetType.Elem())
+		if !ok {
+			return "", false
+		}
+		entries = append(entries, [2]string{keyLiteral, valueLiteral})
 	}
-	sort.Strings(names)
+	sort.Slice(entries, func(i, j int) bool { return entries[i][0] < entries[j][0] })
 
 	var builder strings.Builder
 	builder.WriteString(targetType.String())
 	builder.WriteString("{")
-	for i, key := range names {
-		valueLiteral, ok := formatValueForType(rv.MapIndex(reflect.ValueOf(key)).Interface(), targetType.Elem())
+	for i, entry := range entries {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(entry[0])
+		builder.WriteString(": ")
+		builder.WriteString(entry[1])
+	}
+	builder.WriteString("}")
+	return builder.String(), true
+}
+
+// formatStructLiteralForType builds a struct of targetType from the fields of
+// a dict or dataclass; every field of the struct must be exported
+func formatStructLiteralForType(value any, targetType reflect.Type) (string, bool) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	used := 0
+	var builder strings.Builder
+	builder.WriteString(targetType.String())
+	builder.WriteString("{")
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		if !field.IsExported() {
+			return "", false
+		}
+		fieldValue, ok := fields[field.Name]
+		if !ok {
+			continue
+		}
+		literal, ok := formatValueForType(fieldValue, field.Type)
 		if !ok {
 			return "", false
 		}
-		if i > 0 {
+		if used > 0 {
 			builder.WriteString(", ")
 		}
-		builder.WriteString(strconv.Quote(key))
+		used++
+		builder.WriteString(field.Name)
 		builder.WriteString(": ")
-		builder.WriteString(valueLiteral)
+		builder.WriteString(literal)
+	}
+	if used != len(fields) {
+		return "", false
 	}
 	builder.WriteString("}")
 	return builder.String(), true
@@ -1325,6 +1280,8 @@ func toString(value any) (string, bool) {
 
 func toInt64(value any) (int64, bool) {
 	switch v := value.(type) {
+	case time.Duration:
+		return int64(v), true
 	case int:
 		return int64(v), true
 	case int8:
@@ -1517,6 +1474,19 @@ func formatFloatLiteral(value float64) string {
 	return text
 }
 
+// formatTimeLiteral keeps the instant, and the zone when it is UTC or a fixed offset
+func formatTimeLiteral(t time.Time) string {
+	literal := fmt.Sprintf("time.Unix(%d, %d)", t.Unix(), t.Nanosecond())
+	switch name, offset := t.Zone(); {
+	case t.Location() == time.UTC:
+		return literal + ".UTC()"
+	case t.Location() == time.Local:
+		return literal
+	default:
+		return fmt.Sprintf("%s.In(time.FixedZone(%s, %d))", literal, strconv.Quote(name), offset)
+	}
+}
+
 func formatJSONNumberLiteral(value json.Number) (string, bool) {
 	if value == "" {
 		return "", false
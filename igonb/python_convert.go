@@ -0,0 +1,728 @@
+package igonb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/HazelnutParadise/insyra"
+)
+
+// Values pass between Go and Python cells as JSON. JSON has no type for most
+// of them, so those are sent as objects tagged with pythonTypeTag:
+//
+//	Go                               Python
+//	bool, string                     bool, str
+//	int*, uint*                      int (beyond ±2^53 tagged "int")
+//	float32, float64, NaN, ±Inf      float (integral, NaN and inf tagged "float")
+//	[]byte                           bytes (bytearray, memoryview from Python)
+//	time.Time                        datetime (aware; naive ones are taken as UTC)
+//	time.Duration                    timedelta
+//	slices, arrays                   list (tuple, set, range from Python)
+//	map[string]T                     dict with str keys
+//	map[K]T, map[any]any             dict with other keys (tagged "dict")
+//	named struct                     dataclass of the same name and fields (tagged "struct")
+//	unnamed struct, map[string]any   dict
+//	pointer, interface               the value they point to, nil as None
+//	insyra DataList                  pandas Series, polars Series, or list
+//	insyra DataTable                 pandas DataFrame, polars DataFrame, or rows
+//
+// A DataTable keeps its column names, and its row names as the index of a
+// pandas DataFrame or as a leading "row_name" column of a polars one; a
+// default RangeIndex is not sent back as row names. Only exported struct
+// fields are sent, and structs with unexported fields, channels, functions
+// and complex numbers are not transferred. A Python value of any other type
+// stays in the Python session and is reported as not passed to Go.
+const pythonTypeTag = "__igonb_type__"
+
+// maxPythonDepth bounds the nesting of a value sent to Python
+const maxPythonDepth = 64
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// pythonDataList and pythonDataTable match the insyra and isr list and table types
+type pythonDataList interface {
+	Data() []any
+	GetName() string
+}
+
+type pythonDataTable interface {
+	To2DSlice() [][]any
+	ColNames() []string
+	RowNames() []string
+}
+
+// encodeForPython converts a Go value to the JSON value Python restores it from
+func encodeForPython(value any) (any, error) {
+	return encodePythonValue(reflect.ValueOf(value), 0)
+}
+
+func encodePythonValue(rv reflect.Value, depth int) (any, error) {
+	if depth > maxPythonDepth {
+		return nil, errors.New("value is nested too deeply")
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+	if rv.CanInterface() {
+		switch v := rv.Interface().(type) {
+		case time.Time:
+			_, offset := v.Zone()
+			return map[string]any{pythonTypeTag: "datetime", "us": v.UnixMicro(), "offset": offset}, nil
+		case time.Duration:
+			return map[string]any{pythonTypeTag: "timedelta", "us": v.Microseconds()}, nil
+		case json.Number:
+			return v, nil
+		case pythonDataList:
+			data, err := encodePythonValue(reflect.ValueOf(v.Data()), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{pythonTypeTag: "datalist", "data": data, "name": v.GetName()}, nil
+		case pythonDataTable:
+			data, err := encodePythonValue(reflect.ValueOf(v.To2DSlice()), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			table := map[string]any{pythonTypeTag: "datatable", "data": data, "columns": v.ColNames()}
+			if rows := v.RowNames(); hasNonEmptyStrings(rows) {
+				table["index"] = rows
+			}
+			return table, nil
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return encodePythonValue(rv.Elem(), depth+1)
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return encodePythonFloat(rv.Float(), rv.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return map[string]any{pythonTypeTag: "bytes", "data": base64.StdEncoding.EncodeToString(rv.Bytes())}, nil
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			item, err := encodePythonValue(rv.Index(i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		return encodePythonMap(rv, depth)
+	case reflect.Struct:
+		return encodePythonStruct(rv, depth)
+	}
+	return nil, fmt.Errorf("unsupported type %s", rv.Type())
+}
+
+// encodePythonFloat keeps floats floats in Python: integral values get a
+// decimal point and NaN and infinities, which JSON lacks, are tagged
+func encodePythonFloat(value float64, bits int) any {
+	switch {
+	case math.IsNaN(value):
+		return map[string]any{pythonTypeTag: "float", "value": "nan"}
+	case math.IsInf(value, 1):
+		return map[string]any{pythonTypeTag: "float", "value": "inf"}
+	case math.IsInf(value, -1):
+		return map[string]any{pythonTypeTag: "float", "value": "-inf"}
+	}
+	text := strconv.FormatFloat(value, 'g', -1, bits)
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		text += ".0"
+	}
+	return json.Number(text)
+}
+
+func encodePythonMap(rv reflect.Value, depth int) (any, error) {
+	keys := rv.MapKeys()
+	if rv.Type().Key().Kind() == reflect.String {
+		out := make(map[string]any, len(keys))
+		for _, key := range keys {
+			item, err := encodePythonValue(rv.MapIndex(key), depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key.String(), err)
+			}
+			out[key.String()] = item
+		}
+		return out, nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	items := make([]any, 0, len(keys))
+	for _, key := range keys {
+		encodedKey, err := encodePythonValue(key, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		item, err := encodePythonValue(rv.MapIndex(key), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", key.Interface(), err)
+		}
+		items = append(items, []any{encodedKey, item})
+	}
+	return map[string]any{pythonTypeTag: "dict", "items": items}, nil
+}
+
+// encodePythonStruct sends a named struct as the fields of a dataclass and an
+// unnamed one as a dict
+func encodePythonStruct(rv reflect.Value, depth int) (any, error) {
+	t := rv.Type()
+	fields := make([]any, 0, t.NumField())
+	dict := make(map[string]any, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			return nil, fmt.Errorf("unsupported type %s: it has unexported fields", t)
+		}
+		item, err := encodePythonValue(rv.Field(i), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		fields = append(fields, []any{field.Name, item})
+		dict[field.Name] = item
+	}
+	if t.Name() == "" {
+		return dict, nil
+	}
+	return map[string]any{pythonTypeTag: "struct", "name": t.Name(), "fields": fields}, nil
+}
+
+// decodeFromPython converts a value exported by a Python cell, decoded with
+// json.Decoder.UseNumber, to the Go value it stands for
+func decodeFromPython(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", v)
+		}
+		return f, nil
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			decoded, err := decodeFromPython(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = decoded
+		}
+		return items, nil
+	case map[string]any:
+		if tag, ok := v[pythonTypeTag].(string); ok {
+			return decodeTaggedPythonValue(tag, v)
+		}
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			decoded, err := decodeFromPython(item)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			out[key] = decoded
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+func decodeTaggedPythonValue(tag string, v map[string]any) (any, error) {
+	switch tag {
+	case "float":
+		text, _ := v["value"].(string)
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", text)
+		}
+		return f, nil
+	case "int":
+		text, _ := v["value"].(string)
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return u, nil
+		}
+		return nil, fmt.Errorf("integer %s does not fit in 64 bits", text)
+	case "bytes":
+		text, _ := v["data"].(string)
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytes: %w", err)
+		}
+		return data, nil
+	case "datetime":
+		us, ok := toInt64(v["us"])
+		if !ok {
+			return nil, errors.New("invalid datetime")
+		}
+		t := time.UnixMicro(us).UTC()
+		if offset, ok := toInt64(v["offset"]); ok && offset != 0 {
+			t = t.In(time.FixedZone("", int(offset)))
+		}
+		return t, nil
+	case "timedelta":
+		us, ok := toInt64(v["us"])
+		if !ok {
+			return nil, errors.New("invalid timedelta")
+		}
+		return time.Duration(us) * time.Microsecond, nil
+	case "struct":
+		fields, _ := v["fields"].([]any)
+		out := make(map[string]any, len(fields))
+		for _, field := range fields {
+			pair, ok := field.([]any)
+			if !ok || len(pair) != 2 {
+				return nil, errors.New("invalid dataclass field")
+			}
+			name, _ := pair[0].(string)
+			decoded, err := decodeFromPython(pair[1])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			out[name] = decoded
+		}
+		return out, nil
+	case "dict":
+		items, _ := v["items"].([]any)
+		out := make(map[any]any, len(items))
+		for _, item := range items {
+			pair, ok := item.([]any)
+			if !ok || len(pair) != 2 {
+				return nil, errors.New("invalid dict item")
+			}
+			key, err := decodeFromPython(pair[0])
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("unsupported dict key of type %T", key)
+			}
+			value, err := decodeFromPython(pair[1])
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", key, err)
+			}
+			out[key] = value
+		}
+		return out, nil
+	case "datalist":
+		data, err := decodePythonColumn(v["data"], v["kind"])
+		if err != nil {
+			return nil, err
+		}
+		list := insyra.NewDataList(data...)
+		if name, ok := v["name"].(string); ok && name != "" {
+			list.SetName(name)
+		}
+		return list, nil
+	case "datatable":
+		return decodePythonTable(v)
+	case "arrow":
+		return decodeArrowValue(v)
+	}
+	return nil, fmt.Errorf("unknown value type %s", tag)
+}
+
+// decodePythonColumn decodes the values of a Series or DataFrame column, making
+// the whole numbers of a float column ("f" kind) float64 again
+func decodePythonColumn(value any, kind any) ([]any, error) {
+	decoded, err := decodeFromPython(value)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := coerceAnySlice(decoded)
+	if !ok {
+		return nil, errors.New("invalid column data")
+	}
+	if kind == "f" {
+		for i, item := range data {
+			if f, ok := toFloat64(item); ok {
+				data[i] = f
+			}
+		}
+	}
+	return data, nil
+}
+
+func decodePythonTable(v map[string]any) (any, error) {
+	decoded, err := decodeFromPython(v["data"])
+	if err != nil {
+		return nil, err
+	}
+	rows, ok := coerce2DAnySlice(decoded)
+	if !ok {
+		return nil, errors.New("invalid table data")
+	}
+	kinds := coerceStringSlice(v["kinds"])
+	for _, row := range rows {
+		for j, item := range row {
+			if j < len(kinds) && kinds[j] == "f" {
+				if f, ok := toFloat64(item); ok {
+					row[j] = f
+				}
+			}
+		}
+	}
+	table := insyra.NewDataTable()
+	if len(rows) > 0 {
+		if table, err = insyra.Slice2DToDataTable(rows); err != nil {
+			return nil, err
+		}
+	}
+	if cols := coerceStringSlice(v["columns"]); hasNonEmptyStrings(cols) {
+		table.SetColNames(cols)
+	}
+	if index := coerceStringSlice(v["index"]); hasNonEmptyStrings(index) {
+		table.SetRowNames(index)
+	}
+	return table, nil
+}
+
+// pythonConvertHelpers are the Python side of the conversions above:
+// __igonb_restore turns a JSON value from Go into a Python value and
+// __igonb_encode turns a Python value into JSON, raising __IgonbUnsupported
+// for values that have no Go equivalent
+const pythonConvertHelpers = `
+import base64 as __igonb_base64, dataclasses as __igonb_dataclasses, datetime as __igonb_datetime, math as __igonb_math, sys as __igonb_sys
+
+__igonb_tag = "__igonb_type__"
+__igonb_epoch = __igonb_datetime.datetime(1970, 1, 1, tzinfo=__igonb_datetime.timezone.utc)
+__igonb_struct_classes = {}
+__igonb_arrow = {"dir": None, "threshold": 0, "count": 0}
+
+class __IgonbUnsupported(Exception):
+	pass
+
+def __igonb_import(name):
+	try:
+		return __import__(name)
+	except Exception:
+		return None
+
+def __igonb_hashable(value):
+	if isinstance(value, list):
+		return tuple(__igonb_hashable(v) for v in value)
+	return value
+
+def __igonb_restore(value):
+	if isinstance(value, list):
+		return [__igonb_restore(v) for v in value]
+	if not isinstance(value, dict):
+		return value
+	tag = value.get(__igonb_tag)
+	if tag is None:
+		return {k: __igonb_restore(v) for k, v in value.items()}
+	if tag == "float":
+		return float(value["value"])
+	if tag == "int":
+		return int(value["value"])
+	if tag == "bytes":
+		return __igonb_base64.b64decode(value["data"])
+	if tag == "datetime":
+		t = __igonb_epoch + __igonb_datetime.timedelta(microseconds=value["us"])
+		offset = value.get("offset")
+		if offset is None:
+			return t.replace(tzinfo=None)
+		return t.astimezone(__igonb_datetime.timezone(__igonb_datetime.timedelta(seconds=offset)))
+	if tag == "timedelta":
+		return __igonb_datetime.timedelta(microseconds=value["us"])
+	if tag == "dict":
+		return {__igonb_hashable(__igonb_restore(k)): __igonb_restore(v) for k, v in value.get("items", [])}
+	if tag == "struct":
+		return __igonb_restore_struct(value)
+	if tag == "datalist":
+		return __igonb_restore_list(value)
+	if tag == "datatable":
+		return __igonb_restore_table(value)
+	if tag == "arrow":
+		return __igonb_restore_arrow(value)
+	return value
+
+def __igonb_restore_struct(value):
+	fields = [(f[0], __igonb_restore(f[1])) for f in value.get("fields", [])]
+	names = tuple(f[0] for f in fields)
+	try:
+		key = (value.get("name", ""), names)
+		cls = __igonb_struct_classes.get(key)
+		if cls is None:
+			cls = __igonb_dataclasses.make_dataclass(key[0], list(names))
+			__igonb_struct_classes[key] = cls
+		return cls(**dict(fields))
+	except Exception:
+		return dict(fields)
+
+def __igonb_restore_list(value):
+	data = __igonb_restore(value.get("data") or [])
+	name = value.get("name") or None
+	pd = __igonb_import("pandas")
+	if pd is not None:
+		try:
+			return pd.Series(data, name=name)
+		except Exception:
+			pass
+	pl = __igonb_import("polars")
+	if pl is not None:
+		try:
+			return pl.Series(name or "", data)
+		except Exception:
+			pass
+	return data
+
+def __igonb_restore_table(value):
+	rows = __igonb_restore(value.get("data") or [])
+	columns = value.get("columns") or []
+	index = value.get("index") or []
+	if not any(str(c).strip() for c in columns):
+		columns = None
+	if not any(str(r).strip() for r in index):
+		index = None
+	pd = __igonb_import("pandas")
+	if pd is not None:
+		try:
+			df = pd.DataFrame(rows, columns=columns)
+			if index is not None:
+				df.index = index
+			return df
+		except Exception:
+			pass
+	pl = __igonb_import("polars")
+	if pl is not None:
+		try:
+			width = len(columns) if columns else max((len(r) for r in rows), default=0)
+			names = list(columns) if columns else ["column_" + str(i) for i in range(width)]
+			data = {}
+			if index is not None:
+				data["row_name"] = index
+			for i, name in enumerate(names):
+				data[name] = [r[i] if i < len(r) else None for r in rows]
+			try:
+				return pl.DataFrame(data, strict=False)
+			except TypeError:
+				return pl.DataFrame(data)
+		except Exception:
+			pass
+	return rows
+
+def __igonb_restore_arrow(value):
+	path, kind, index = value["path"], value.get("kind"), value.get("index")
+	pa = __igonb_import("pyarrow")
+	if pa is not None:
+		import pyarrow.ipc
+		with pa.OSFile(path, "rb") as source:
+			table = pa.ipc.open_file(source).read_all()
+		pd = __igonb_import("pandas")
+		if pd is not None:
+			df = table.to_pandas()
+			if index is not None:
+				df = df.set_index(index)
+				df.index.name = None
+			if kind == "datalist":
+				return df.iloc[:, 0].rename(value.get("name") or None)
+			if not value.get("named"):
+				df.columns = range(df.shape[1])
+			return df
+		pl = __igonb_import("polars")
+		if pl is not None:
+			return __igonb_arrow_polars(pl.from_arrow(table), value)
+		if kind == "datalist":
+			return table.column(0)
+		return table
+	pl = __igonb_import("polars")
+	if pl is not None:
+		return __igonb_arrow_polars(pl.read_ipc(path, memory_map=False), value)
+	raise __IgonbUnsupported("reading Arrow data needs pyarrow or polars")
+
+def __igonb_arrow_polars(df, value):
+	if value.get("kind") == "datalist":
+		return df.to_series(0).alias(value.get("name") or "")
+	if value.get("index") is not None:
+		df = df.rename({value["index"]: "row_name"})
+	return df
+
+def __igonb_arrow_support():
+	import importlib.util
+	return any(importlib.util.find_spec(name) is not None for name in ("pyarrow", "polars"))
+
+def __igonb_arrow_path():
+	__igonb_arrow["count"] += 1
+	return __igonb_arrow["dir"] + "/python_" + str(__igonb_arrow["count"]) + ".arrow"
+
+def __igonb_encode_arrow(value):
+	kind, name, index = "datatable", None, None
+	pd = __igonb_sys.modules.get("pandas")
+	if pd is not None and isinstance(value, (pd.DataFrame, pd.Series)):
+		pa = __igonb_import("pyarrow")
+		if pa is None or value.size < __igonb_arrow["threshold"]:
+			return None
+		if isinstance(value, pd.Series):
+			kind, name = "datalist", value.name
+			value = value.reset_index(drop=True).to_frame(name="values")
+		import pyarrow.ipc
+		table = pa.Table.from_pandas(value)
+		if kind == "datatable":
+			index = next((c for c in (table.schema.pandas_metadata or {}).get("index_columns", []) if isinstance(c, str)), None)
+		path = __igonb_arrow_path()
+		with pa.OSFile(path, "wb") as sink:
+			with pa.ipc.new_file(sink, table.schema) as writer:
+				writer.write_table(table)
+		return {__igonb_tag: "arrow", "kind": kind, "path": path, "name": "" if name is None else str(name), "index": index}
+	pl = __igonb_sys.modules.get("polars")
+	if pl is not None and isinstance(value, (pl.DataFrame, pl.Series)):
+		if isinstance(value, pl.Series):
+			if len(value) < __igonb_arrow["threshold"]:
+				return None
+			kind, name = "datalist", value.name
+			value = value.to_frame()
+		elif value.height * value.width < __igonb_arrow["threshold"]:
+			return None
+		elif value.width and value.columns[0] == "row_name" and value.schema["row_name"] == pl.Utf8:
+			index = "row_name"
+		path = __igonb_arrow_path()
+		value.write_ipc(path, compression="uncompressed")
+		return {__igonb_tag: "arrow", "kind": kind, "path": path, "name": name or "", "index": index}
+	return None
+
+def __igonb_micros(delta):
+	return (delta.days * 86400 + delta.seconds) * 1000000 + delta.microseconds
+
+def __igonb_encode_datetime(value):
+	offset = value.utcoffset()
+	if offset is None:
+		value = value.replace(tzinfo=__igonb_datetime.timezone.utc)
+	return {
+		__igonb_tag: "datetime",
+		"us": __igonb_micros(value - __igonb_epoch),
+		"offset": None if offset is None else int(offset.total_seconds()),
+	}
+
+def __igonb_encode(value, depth=0):
+	if depth > 64:
+		raise __IgonbUnsupported("value is nested too deeply")
+	if value is None or isinstance(value, (bool, str)):
+		return value
+	if isinstance(value, int):
+		if -2**53 <= value <= 2**53:
+			return int(value)
+		return {__igonb_tag: "int", "value": str(int(value))}
+	if isinstance(value, float):
+		if __igonb_math.isfinite(value) and not value.is_integer():
+			return float(value)
+		return {__igonb_tag: "float", "value": repr(float(value))}
+	if isinstance(value, (bytes, bytearray, memoryview)):
+		return {__igonb_tag: "bytes", "data": __igonb_base64.b64encode(bytes(value)).decode("ascii")}
+	if isinstance(value, __igonb_datetime.datetime):
+		return __igonb_encode_datetime(value)
+	if isinstance(value, __igonb_datetime.date):
+		return __igonb_encode_datetime(__igonb_datetime.datetime(value.year, value.month, value.day))
+	if isinstance(value, __igonb_datetime.timedelta):
+		return {__igonb_tag: "timedelta", "us": __igonb_micros(value)}
+	if __igonb_dataclasses.is_dataclass(value) and not isinstance(value, type):
+		return {
+			__igonb_tag: "struct",
+			"name": type(value).__name__,
+			"fields": [[f.name, __igonb_encode(getattr(value, f.name), depth + 1)] for f in __igonb_dataclasses.fields(value)],
+		}
+	if isinstance(value, dict):
+		if all(isinstance(k, str) for k in value):
+			return {k: __igonb_encode(v, depth + 1) for k, v in value.items()}
+		return {__igonb_tag: "dict", "items": [[__igonb_encode(k, depth + 1), __igonb_encode(v, depth + 1)] for k, v in value.items()]}
+	if isinstance(value, (list, tuple, set, frozenset, range)):
+		return [__igonb_encode(v, depth + 1) for v in value]
+	encoded = __igonb_encode_frame(value, depth)
+	if encoded is not None:
+		return encoded
+	raise __IgonbUnsupported("no Go equivalent for " + type(value).__name__)
+
+def __igonb_encode_cell(value, kind):
+	if kind == "f" and isinstance(value, float) and __igonb_math.isfinite(value):
+		return float(value)
+	return __igonb_encode(value)
+
+def __igonb_pandas_column(series):
+	kind = series.dtype.kind
+	values = series.astype(object).where(series.notna(), None).tolist()
+	return kind, [__igonb_encode_cell(v, kind) for v in values]
+
+def __igonb_polars_column(pl, series):
+	kind = "f" if series.dtype in (pl.Float32, pl.Float64) else ""
+	return kind, [__igonb_encode_cell(v, kind) for v in series.to_list()]
+
+def __igonb_encode_table(columns, kinds, data, index):
+	table = {__igonb_tag: "datatable", "columns": columns, "kinds": kinds, "data": [list(row) for row in zip(*data)]}
+	if index is not None:
+		table["index"] = index
+	return table
+
+def __igonb_encode_frame(value, depth):
+	if __igonb_arrow["dir"] and __igonb_arrow["threshold"] > 0:
+		try:
+			encoded = __igonb_encode_arrow(value)
+		except Exception:
+			encoded = None
+		if encoded is not None:
+			return encoded
+	pd = __igonb_sys.modules.get("pandas")
+	if pd is not None:
+		if isinstance(value, pd.DataFrame):
+			kinds, data = [], []
+			for i in range(value.shape[1]):
+				kind, values = __igonb_pandas_column(value.iloc[:, i])
+				kinds.append(kind)
+				data.append(values)
+			index = None
+			if not (isinstance(value.index, pd.RangeIndex) and value.index.start == 0 and value.index.step == 1):
+				index = [str(v) for v in value.index]
+			return __igonb_encode_table([str(c) for c in value.columns], kinds, data, index)
+		if isinstance(value, pd.Series):
+			kind, data = __igonb_pandas_column(value)
+			return {__igonb_tag: "datalist", "name": "" if value.name is None else str(value.name), "data": data, "kind": kind}
+	pl = __igonb_sys.modules.get("polars")
+	if pl is not None:
+		if isinstance(value, pl.DataFrame):
+			columns = list(value.columns)
+			index = None
+			if columns and columns[0] == "row_name" and value.schema[columns[0]] == pl.Utf8:
+				index = ["" if v is None else v for v in value.get_column(columns[0]).to_list()]
+				columns = columns[1:]
+			kinds, data = [], []
+			for name in columns:
+				kind, values = __igonb_polars_column(pl, value.get_column(name))
+				kinds.append(kind)
+				data.append(values)
+			return __igonb_encode_table(columns, kinds, data, index)
+		if isinstance(value, pl.Series):
+			kind, data = __igonb_polars_column(pl, value)
+			return {__igonb_tag: "datalist", "name": value.name, "data": data, "kind": kind}
+	np = __igonb_sys.modules.get("numpy")
+	if np is not None:
+		if isinstance(value, np.ndarray):
+			return __igonb_encode(value.tolist(), depth + 1)
+		if isinstance(value, np.generic):
+			return __igonb_encode(value.item(), depth + 1)
+	return None
+`
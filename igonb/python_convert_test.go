@@ -0,0 +1,286 @@
+package igonb
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HazelnutParadise/insyra"
+)
+
+type point struct {
+	X, Y int
+}
+
+type account struct {
+	Name  string
+	token string
+}
+
+func TestEncodeForPython(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"int", 3, `3`},
+		{"whole float", 3.0, `3.0`},
+		{"float", 1.5, `1.5`},
+		{"float32", float32(0.1), `0.1`},
+		{"nan", math.NaN(), `{"__igonb_type__":"float","value":"nan"}`},
+		{"negative inf", math.Inf(-1), `{"__igonb_type__":"float","value":"-inf"}`},
+		{"bytes", []byte("hi"), `{"__igonb_type__":"bytes","data":"aGk="}`},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600)), `{"__igonb_type__":"datetime","offset":3600,"us":1704161045000006}`},
+		{"duration", 1500 * time.Millisecond, `{"__igonb_type__":"timedelta","us":1500000}`},
+		{"nested maps", []map[string]float64{{"a": 1, "b": math.Inf(1)}}, `[{"a":1.0,"b":{"__igonb_type__":"float","value":"inf"}}]`},
+		{"int keys", map[int]string{2: "b", 1: "a"}, `{"__igonb_type__":"dict","items":[[1,"a"],[2,"b"]]}`},
+		{"named struct", point{1, 2}, `{"__igonb_type__":"struct","fields":[["X",1],["Y",2]],"name":"point"}`},
+		{"pointer", &point{3, 4}, `{"__igonb_type__":"struct","fields":[["X",3],["Y",4]],"name":"point"}`},
+		{"unnamed struct", struct{ A []string }{[]string{"x"}}, `{"A":["x"]}`},
+		{"nil pointer", (*point)(nil), `null`},
+		{"datalist", insyra.NewDataList(1, 2.5, nil).SetName("x"), `{"__igonb_type__":"datalist","data":[1,2.5,null],"name":"x"}`},
+	}
+	for _, tt := range tests {
+		encoded, err := encodeForPython(tt.value)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := json.Marshal(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	for _, value := range []any{make(chan int), func() {}, account{Name: "a"}, []any{complex(1, 2)}} {
+		if _, err := encodeForPython(value); err == nil {
+			t.Errorf("%T: expected an error", value)
+		}
+	}
+}
+
+func decodeJSONForTest(t *testing.T, text string) (any, error) {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	return decodeFromPython(raw)
+}
+
+func TestDecodeFromPython(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want any
+	}{
+		{"int", `3`, int64(3)},
+		{"float", `1.5`, 1.5},
+		{"whole float", `{"__igonb_type__":"float","value":"3.0"}`, 3.0},
+		{"inf", `{"__igonb_type__":"float","value":"inf"}`, math.Inf(1)},
+		{"big int", `{"__igonb_type__":"int","value":"18446744073709551615"}`, uint64(math.MaxUint64)},
+		{"bytes", `{"__igonb_type__":"bytes","data":"aGk="}`, []byte("hi")},
+		{"timedelta", `{"__igonb_type__":"timedelta","us":1500000}`, 1500 * time.Millisecond},
+		{"dataclass", `{"__igonb_type__":"struct","name":"P","fields":[["X",1],["Tags",["a"]]]}`, map[string]any{"X": int64(1), "Tags": []any{"a"}}},
+		{"int keys", `{"__igonb_type__":"dict","items":[[1,"a"],[null,{"k":2.5}]]}`, map[any]any{int64(1): "a", nil: map[string]any{"k": 2.5}}},
+	}
+	for _, tt := range tests {
+		got, err := decodeJSONForTest(t, tt.json)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	got, err := decodeJSONForTest(t, `{"__igonb_type__":"datetime","us":1704161045000006,"offset":3600}`)
+	want := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600))
+	if tm, ok := got.(time.Time); err != nil || !ok || !tm.Equal(want) || tm.Format("-07:00") != "+01:00" {
+		t.Errorf("datetime: got %v, %v", got, err)
+	}
+	got, err = decodeJSONForTest(t, `{"__igonb_type__":"datetime","us":0,"offset":null}`)
+	if tm, ok := got.(time.Time); err != nil || !ok || tm.Location() != time.UTC || tm.Unix() != 0 {
+		t.Errorf("naive datetime: got %v, %v", got, err)
+	}
+
+	for _, text := range []string{
+		`{"__igonb_type__":"int","value":"99999999999999999999999"}`,
+		`{"__igonb_type__":"dict","items":[[[1,2],"a"]]}`,
+		`{"__igonb_type__":"ndarray"}`,
+	} {
+		if got, err := decodeJSONForTest(t, text); err == nil {
+			t.Errorf("%s: expected an error, got %#v", text, got)
+		}
+	}
+}
+
+func TestDecodeDataTableFromPython(t *testing.T) {
+	got, err := decodeJSONForTest(t, `{"__igonb_type__":"datatable","columns":["a","b"],"kinds":["f","O"],
+		"data":[[1,"x"],[2.5,null]],"index":["r1","r2"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, ok := got.(insyra.IDataTable)
+	if !ok {
+		t.Fatalf("got %T, want a DataTable", got)
+	}
+	if rows := table.To2DSlice(); !reflect.DeepEqual(rows, [][]any{{1.0, "x"}, {2.5, nil}}) {
+		t.Errorf("rows = %#v", rows)
+	}
+	if cols := table.ColNames(); !reflect.DeepEqual(cols, []string{"a", "b"}) {
+		t.Errorf("columns = %v", cols)
+	}
+	if rows := table.RowNames(); !reflect.DeepEqual(rows, []string{"r1", "r2"}) {
+		t.Errorf("row names = %v", rows)
+	}
+}
+
+func TestFormatGoLiteralForType(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  any
+		target any
+		want   string
+	}{
+		{"struct", map[string]any{"X": int64(1), "Y": "a"}, struct {
+			X int
+			Y string
+		}{}, `struct { X int; Y string }{X: 1, Y: "a"}`},
+		{"time", time.Unix(5, 7).UTC(), time.Time{}, `time.Unix(5, 7).UTC()`},
+		{"bytes", []byte("hi\xff"), []byte(nil), `[]uint8("hi\xff")`},
+		{"duration", int64(1500), time.Duration(0), `1500`},
+		{"int keys", map[any]any{int64(2): "b", int64(1): "a"}, map[int]string(nil), `map[int]string{1: "a", 2: "b"}`},
+	}
+	for _, tt := range tests {
+		got, ok := formatGoLiteralForType(tt.value, reflect.TypeOf(tt.target))
+		if !ok || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, ok, tt.want)
+		}
+	}
+
+	if got, ok := formatGoLiteralForType(map[string]any{"Name": "a"}, reflect.TypeOf(account{})); ok {
+		t.Errorf("struct with unexported fields: got %q", got)
+	}
+	if got, ok := formatGoLiteralForType(map[string]any{"X": int64(1), "Z": 2}, reflect.TypeOf(point{})); ok {
+		t.Errorf("unknown field: got %q", got)
+	}
+	if got, ok := formatGoLiteral(map[any]any{int64(1): []byte("a")}); !ok || got != `map[interface {}]interface {}{1: []byte("a")}` {
+		t.Errorf("map[any]any: got %q, %v", got, ok)
+	}
+}
+
+// TestPythonConvertHelpers sends values from Go through the Python helpers and
+// back, and converts values made in Python
+func TestPythonConvertHelpers(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	values := map[string]any{
+		"int":      3,
+		"float":    3.0,
+		"nan":      math.NaN(),
+		"bytes":    []byte{0, 255},
+		"time":     time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("", -5*3600)),
+		"duration": 90 * time.Second,
+		"point":    point{1, 2},
+		"keys":     map[int][]float64{1: {0.5, 2}},
+	}
+	encoded, err := encodeForPython(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := json.Marshal(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := pythonConvertHelpers + `
+import datetime, json, math, sys
+values = __igonb_restore(json.loads(sys.stdin.read()))
+assert type(values["point"]).__name__ == "point" and values["point"].Y == 2, values["point"]
+assert values["time"].utcoffset() == datetime.timedelta(hours=-5), values["time"]
+assert isinstance(values["float"], float) and isinstance(values["int"], int)
+assert math.isnan(values["nan"]) and values["bytes"] == b"\x00\xff"
+values["naive"] = datetime.datetime(2024, 1, 2)
+values["date"] = datetime.date(2024, 1, 2)
+values["huge"] = 2**70
+values["set"] = {1}
+# cells may rebind the names of modules the helpers use
+datetime = math = sys = base64 = dataclasses = None
+out = {}
+for key, value in values.items():
+	try:
+		out[key] = __igonb_encode(value)
+	except __IgonbUnsupported as exc:
+		out[key] = "skipped: " + str(exc)
+try:
+	__igonb_encode(object())
+	out["object"] = "encoded"
+except __IgonbUnsupported as exc:
+	out["object"] = "skipped: " + str(exc)
+print(json.dumps(out))
+`
+	cmd := exec.Command(python, "-c", script)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("python: %v: %s", err, stderr.String())
+	}
+	var raw map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	if raw["object"] != "skipped: no Go equivalent for object" {
+		t.Errorf("object: got %v", raw["object"])
+	}
+	delete(raw, "object")
+	got := make(map[string]any, len(raw))
+	for key, value := range raw {
+		decoded, err := decodeFromPython(value)
+		if err != nil && key != "huge" {
+			t.Errorf("%s: %v", key, err)
+		}
+		got[key] = decoded
+	}
+
+	if tm, ok := got["time"].(time.Time); !ok || !tm.Equal(values["time"].(time.Time)) {
+		t.Errorf("time: got %v", got["time"])
+	}
+	if tm, ok := got["naive"].(time.Time); !ok || !tm.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) || !tm.Equal(got["date"].(time.Time)) {
+		t.Errorf("naive datetime and date: got %v, %v", got["naive"], got["date"])
+	}
+	if f, ok := got["nan"].(float64); !ok || !math.IsNaN(f) {
+		t.Errorf("nan: got %#v", got["nan"])
+	}
+	want := map[string]any{
+		"int":      int64(3),
+		"float":    3.0,
+		"bytes":    []byte{0, 255},
+		"duration": 90 * time.Second,
+		"point":    map[string]any{"X": int64(1), "Y": int64(2)},
+		"keys":     map[any]any{int64(1): []any{0.5, 2.0}},
+		"set":      []any{int64(1)},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s: got %#v, want %#v", key, got[key], value)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package igonb
+
+import "encoding/json"
+
+// pythonEnvKey is the binding with the virtual environment Python cells use
+const pythonEnvKey = "__igonb_env"
+
+// pythonEnvHelpers puts the site-packages of a virtual environment in front of
+// the packages of the Insyra environment. The environment must be created with
+// the Python version Insyra runs, which is warned about otherwise
+const pythonEnvHelpers = `
+def __igonb_use_env(env):
+	import glob as _glob, os as _os, site as _site, sys as _sys
+	if _sys.platform == "win32":
+		path = _os.path.join(env, "Lib", "site-packages")
+	else:
+		version = "python" + str(_sys.version_info[0]) + "." + str(_sys.version_info[1])
+		path = _os.path.join(env, "lib", version, "site-packages")
+	if not _os.path.isdir(path):
+		found = _glob.glob(_os.path.join(env, "lib", "python*", "site-packages"))
+		if found:
+			print("warning: " + env + " was created for another Python version than " + _sys.version.split()[0], file=_sys.stderr)
+		return
+	if path in _sys.path:
+		return
+	before = list(_sys.path)
+	_site.addsitedir(path)
+	_sys.path[:] = [p for p in _sys.path if p not in before] + before
+	_os.environ["VIRTUAL_ENV"] = env
+`
+
+// SetPythonEnv sets the virtual environment whose packages Python cells import
+// before those of the Insyra environment; "" uses the Insyra environment only
+func (e *Executor) SetPythonEnv(dir string) {
+	if e == nil {
+		return
+	}
+	e.sharedMu.Lock()
+	e.pythonEnv = dir
+	e.sharedMu.Unlock()
+}
+
+func (e *Executor) pythonEnvDir() string {
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	return e.pythonEnv
+}
+
+// PythonEnvPrelude returns Python code that makes a script import the packages
+// of the virtual environment dir, as Python cells do with SetPythonEnv
+func PythonEnvPrelude(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	quoted, _ := json.Marshal(dir)
+	return pythonEnvHelpers + "\n__igonb_use_env(" + string(quoted) + ")\n"
+}
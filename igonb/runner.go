@@ -1,6 +1,7 @@
 package igonb
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
@@ -20,6 +21,14 @@ type RunOptions struct {
 	Index     int
 	OnResult  func(CellResult)
 	Formatter OutputFormatter
+	// Context stops the run like Cancel when it is done
+	Context context.Context
+	// OnOutput receives the output of a shell cell as it is produced, before
+	// OnResult receives the whole result
+	OnOutput func(index int, output string)
+	// PythonEnv is a virtual environment whose packages Python cells import
+	// before those of the Insyra environment
+	PythonEnv string
 }
 
 type RunnerOption func(*Runner)
@@ -72,6 +81,13 @@ func (r *Runner) ExecuteNotebook(nb *Notebook, options RunOptions) ([]CellResult
 		return nil, err
 	}
 	exec.ClearStop()
+	exec.SetOutputHandler(options.OnOutput)
+	defer exec.SetOutputHandler(nil)
+	exec.SetPythonEnv(options.PythonEnv)
+	if options.Context != nil {
+		stop := context.AfterFunc(options.Context, exec.RequestStop)
+		defer stop()
+	}
 
 	formattedResults := make([]CellResult, 0)
 	callback := func(result CellResult) {
@@ -110,6 +126,34 @@ func (r *Runner) Cancel(key string) {
 	}
 }
 
+// Variables returns the session variables of an executor, or nil if it has not run yet
+func (r *Runner) Variables(key string) []Variable {
+	if key == "" {
+		key = "default"
+	}
+	r.mu.Lock()
+	exec, ok := r.executors[key]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return exec.Variables()
+}
+
+// Variable returns a session variable of an executor
+func (r *Runner) Variable(key, name string) (any, bool) {
+	if key == "" {
+		key = "default"
+	}
+	r.mu.Lock()
+	exec, ok := r.executors[key]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return exec.Variable(name)
+}
+
 func (r *Runner) Reset(key string) error {
 	if key == "" {
 		key = "default"
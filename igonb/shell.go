@@ -0,0 +1,110 @@
+package igonb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellWaitDelay bounds how long a stopped shell cell waits for processes
+// that still hold its output open
+const shellWaitDelay = 2 * time.Second
+
+// shellCommand returns the command that runs the source of a shell cell:
+// bash when it is installed and sh otherwise, or PowerShell on Windows
+func shellCommand(ctx context.Context, code string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", code)
+	}
+	shell := "sh"
+	if path, err := exec.LookPath("bash"); err == nil {
+		shell = path
+	}
+	return exec.CommandContext(ctx, shell, "-c", code)
+}
+
+// runShellCell runs a shell cell in the working directory of the process,
+// streaming its combined output to the output handler of the run
+func (e *Executor) runShellCell(index int, code string) (string, error) {
+	if strings.TrimSpace(code) == "" {
+		return "", nil
+	}
+	if e.isStopRequested() {
+		return "", ErrExecutionStopped
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.setCellCancel(cancel)
+	defer func() {
+		e.clearCellCancel(cancel)
+		cancel()
+	}()
+
+	output := &shellOutput{index: index, onOutput: e.outputHandler()}
+	cmd := shellCommand(ctx, code)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = shellWaitDelay
+	killProcessTree(cmd)
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return output.String(), ErrExecutionStopped
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return output.String(), fmt.Errorf("shell exited with status %d", exitErr.ExitCode())
+		}
+		return output.String(), fmt.Errorf("failed to run shell: %w", err)
+	}
+	return output.String(), nil
+}
+
+// SetOutputHandler sets the function that receives the output of shell cells
+// while they run; nil stops streaming
+func (e *Executor) SetOutputHandler(onOutput func(index int, output string)) {
+	if e == nil {
+		return
+	}
+	e.sharedMu.Lock()
+	e.onOutput = onOutput
+	e.sharedMu.Unlock()
+}
+
+func (e *Executor) outputHandler() func(index int, output string) {
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	return e.onOutput
+}
+
+// shellOutput collects the output of a shell cell and passes each chunk on
+// as it is written
+type shellOutput struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	index    int
+	onOutput func(index int, output string)
+}
+
+func (o *shellOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(p)
+	if o.onOutput != nil {
+		o.onOutput(o.index, string(p))
+	}
+	return len(p), nil
+}
+
+func (o *shellOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
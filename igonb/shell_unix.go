@@ -0,0 +1,17 @@
+//go:build !windows
+
+package igonb
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessTree starts the shell in its own process group, so stopping the
+// cell also stops the commands it started
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,18 @@
+package igonb
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// killProcessTree makes stopping the cell also stop the commands the shell
+// started
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
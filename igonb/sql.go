@@ -0,0 +1,427 @@
+package igonb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/HazelnutParadise/insyra"
+	"github.com/HazelnutParadise/insyra/parquet"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultSQLVariable is the variable a sql cell stores its result in when it
+// has no into directive
+const DefaultSQLVariable = "sql_result"
+
+// MetaSQLConnections is the notebook metadata key of the databases that sql
+// cells select by name with a connection directive. Each name maps to the path
+// of a SQLite file, or to an object with a "driver" ("sqlite") and a "path".
+const MetaSQLConnections = "sql_connections"
+
+// sqlAttachedSchema is the schema a database is attached as when a sql cell
+// also registers data files, which are loaded into an in-memory database
+const sqlAttachedSchema = "db"
+
+// sqlDirective matches the "-- name: value" comment lines that configure a sql cell
+var sqlDirective = regexp.MustCompile(`^\s*--\s*(database|connection|table|into)\s*:\s*(.*?)\s*$`)
+
+// sqlCell is the parsed source of a sql cell
+type sqlCell struct {
+	database   string
+	connection string
+	tables     []sqlTable
+	into       string
+	statements []string
+}
+
+// sqlTable is a data file registered as a table
+type sqlTable struct {
+	name string
+	path string
+}
+
+// runSQLCell runs the statements of a sql cell and binds the rows of the last
+// one to a DataTable variable shared with Go and Python cells
+func (e *Executor) runSQLCell(nb *Notebook, code string) (string, error) {
+	cell, err := parseSQLCell(code)
+	if err != nil {
+		return "", err
+	}
+	if len(cell.statements) == 0 {
+		return "", nil
+	}
+	if e.isStopRequested() {
+		return "", ErrExecutionStopped
+	}
+	path, err := sqlDatabasePath(nb, cell)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.setCellCancel(cancel)
+	defer func() {
+		e.clearCellCancel(cancel)
+		cancel()
+	}()
+
+	table, affected, err := runSQL(ctx, path, cell)
+	if ctx.Err() != nil {
+		return "", ErrExecutionStopped
+	}
+	if err != nil {
+		return "", err
+	}
+	if table == nil {
+		return fmt.Sprintf("%d rows affected\n", affected), nil
+	}
+	if err := e.bindSQLResult(cell.into, table); err != nil {
+		return "", err
+	}
+	output, _ := showIDataTable(table)
+	return fmt.Sprintf("%s: %s\n%s", cell.into, previewVariable(table), output), nil
+}
+
+// bindSQLResult stores the result of a sql cell as a session variable and a
+// Go variable
+func (e *Executor) bindSQLResult(name string, table *insyra.DataTable) error {
+	e.setSharedVar(name, table)
+	if err := e.PreloadGoImports([]string{
+		"github.com/HazelnutParadise/insyra",
+		"github.com/HazelnutParadise/insyra/isr",
+		"math",
+	}); err != nil {
+		return err
+	}
+	if err := e.setGoVariable(name, table); err != nil && !errors.Is(err, errUnsupportedGoValue) {
+		return err
+	}
+	return nil
+}
+
+// parseSQLCell reads the directives and statements of a sql cell:
+//
+//	-- database: data/sales.db        query a SQLite file
+//	-- connection: warehouse          or a database from MetaSQLConnections
+//	-- table: orders = data/orders.csv  register a CSV or Parquet file as a table
+//	-- into: monthly                  name the result variable
+func parseSQLCell(code string) (sqlCell, error) {
+	cell := sqlCell{into: DefaultSQLVariable}
+	for _, line := range strings.Split(code, "\n") {
+		m := sqlDirective.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name, value := m[1], m[2]
+		if value == "" {
+			return cell, fmt.Errorf("empty %s directive", name)
+		}
+		switch name {
+		case "database":
+			cell.database = value
+		case "connection":
+			cell.connection = value
+		case "into":
+			if !isGoIdentifier(value) {
+				return cell, fmt.Errorf("invalid variable name in into directive: %s", value)
+			}
+			cell.into = value
+		case "table":
+			table := sqlTable{path: value}
+			if tableName, path, ok := strings.Cut(value, "="); ok {
+				table = sqlTable{name: strings.TrimSpace(tableName), path: strings.TrimSpace(path)}
+			} else {
+				table.name = sqlTableName(value)
+			}
+			if !isSQLName(table.name) || table.path == "" {
+				return cell, fmt.Errorf("invalid table directive: %s", value)
+			}
+			cell.tables = append(cell.tables, table)
+		}
+	}
+	if cell.database != "" && cell.connection != "" {
+		return cell, errors.New("a sql cell takes a database or a connection directive, not both")
+	}
+	cell.statements = splitSQLStatements(code)
+	return cell, nil
+}
+
+// sqlTableName derives a table name from the name of a data file
+func sqlTableName(path string) string {
+	base := filepath.Base(filepath.FromSlash(path))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, base)
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
+	}
+	return name
+}
+
+// isSQLName reports whether name can be used as a table name without quoting
+func isSQLName(name string) bool {
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// sqlDatabasePath returns the database file a sql cell queries, or "" for an
+// in-memory database
+func sqlDatabasePath(nb *Notebook, cell sqlCell) (string, error) {
+	if cell.connection == "" {
+		return cell.database, nil
+	}
+	var connections map[string]any
+	if nb != nil {
+		connections, _ = nb.Metadata[MetaSQLConnections].(map[string]any)
+	}
+	switch conn := connections[cell.connection].(type) {
+	case nil:
+		return "", fmt.Errorf("unknown sql connection %s, define it in the notebook metadata under %s", cell.connection, MetaSQLConnections)
+	case string:
+		return conn, nil
+	case map[string]any:
+		if driver, _ := conn["driver"].(string); driver != "" && driver != "sqlite" && driver != "sqlite3" {
+			return "", fmt.Errorf("unsupported driver %s for sql connection %s, only sqlite is supported", driver, cell.connection)
+		}
+		if path, _ := conn["path"].(string); path != "" {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("sql connection %s has no path", cell.connection)
+}
+
+// runSQL runs the statements of cell against the database at path and returns
+// the rows of the last statement, or the number of rows it changed when it
+// returns none
+func runSQL(ctx context.Context, path string, cell sqlCell) (*insyra.DataTable, int64, error) {
+	db, err := openSQLDatabase(path, len(cell.tables) > 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	for _, table := range cell.tables {
+		if err := registerSQLTable(ctx, db, table); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	db = db.WithContext(ctx)
+	last := len(cell.statements) - 1
+	for _, statement := range cell.statements[:last] {
+		if err := db.Exec(statement).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	if !returnsRows(cell.statements[last]) {
+		result := db.Exec(cell.statements[last])
+		return nil, result.RowsAffected, result.Error
+	}
+	table, err := insyra.ReadSQL(db, "", insyra.ReadSQLOptions{Query: cell.statements[last]})
+	if err != nil {
+		return nil, 0, err
+	}
+	return table, 0, nil
+}
+
+// openSQLDatabase opens the SQLite file at path, or an in-memory database when
+// path is empty or data files are to be registered, attaching the file then
+func openSQLDatabase(path string, registerFiles bool) (*gorm.DB, error) {
+	dsn := ":memory:"
+	if path != "" {
+		path = filepath.FromSlash(path)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		if !registerFiles {
+			dsn = path
+		}
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// every connection to :memory: opens a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	if path != "" && registerFiles {
+		if err := db.Exec("ATTACH DATABASE ? AS "+sqlAttachedSchema, path).Error; err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to attach database: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// registerSQLTable loads a CSV or Parquet file into a table of db
+func registerSQLTable(ctx context.Context, db *gorm.DB, table sqlTable) error {
+	path := filepath.FromSlash(table.path)
+	var data *insyra.DataTable
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		data, err = insyra.ReadCSV_File(path, false, true)
+	case ".parquet":
+		data, err = parquet.Read(ctx, path, parquet.ReadOptions{})
+	default:
+		return fmt.Errorf("unsupported table file: %s (use .csv or .parquet)", table.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", table.path, err)
+	}
+	if err := data.ToSQL(db, table.name, insyra.ToSQLOptions{IfExists: insyra.SQLActionIfTableExistsReplace}); err != nil {
+		return fmt.Errorf("failed to load %s into table %s: %w", table.path, table.name, err)
+	}
+	return nil
+}
+
+// splitSQLStatements splits SQL at the semicolons outside strings, quoted names
+// and comments, dropping statements that hold only comments
+func splitSQLStatements(code string) []string {
+	var statements []string
+	start, hasCode := 0, false
+	end := func(i int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(code[start:i]))
+		}
+		start, hasCode = i+1, false
+	}
+	for i := 0; i < len(code); i++ {
+		if j := sqlCommentEnd(code, i); j >= 0 {
+			i = j
+			continue
+		}
+		if j := sqlQuotedEnd(code, i); j >= 0 {
+			i, hasCode = j, true
+			continue
+		}
+		switch c := code[i]; {
+		case c == ';':
+			end(i)
+		case !unicode.IsSpace(rune(c)):
+			hasCode = true
+		}
+	}
+	end(len(code))
+	return statements
+}
+
+// sqlCommentEnd returns the index of the last byte of the comment starting at
+// code[i], or -1 when no comment starts there
+func sqlCommentEnd(code string, i int) int {
+	switch {
+	case strings.HasPrefix(code[i:], "--"):
+		if n := strings.IndexByte(code[i:], '\n'); n >= 0 {
+			return i + n
+		}
+		return len(code) - 1
+	case strings.HasPrefix(code[i:], "/*"):
+		if n := strings.Index(code[i+2:], "*/"); n >= 0 {
+			return i + n + 3
+		}
+		return len(code) - 1
+	}
+	return -1
+}
+
+// sqlQuotedEnd returns the index of the closing quote of the string or quoted
+// name starting at code[i], or -1 when none starts there. A doubled quote closes
+// the string and opens the next part of it.
+func sqlQuotedEnd(code string, i int) int {
+	closing := code[i]
+	switch closing {
+	case '\'', '"', '`':
+	case '[':
+		closing = ']'
+	default:
+		return -1
+	}
+	if n := strings.IndexByte(code[i+1:], closing); n >= 0 {
+		return i + 1 + n
+	}
+	return len(code) - 1
+}
+
+// sqlTopLevelWords returns the words of a statement outside parentheses,
+// strings, quoted names and comments, in upper case
+func sqlTopLevelWords(statement string) []string {
+	var words []string
+	depth := 0
+	for i := 0; i < len(statement); i++ {
+		if j := sqlCommentEnd(statement, i); j >= 0 {
+			i = j
+			continue
+		}
+		if j := sqlQuotedEnd(statement, i); j >= 0 {
+			i = j
+			continue
+		}
+		switch c := statement[i]; {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isSQLWordByte(c):
+			j := i
+			for j < len(statement) && isSQLWordByte(statement[j]) {
+				j++
+			}
+			if depth == 0 {
+				words = append(words, strings.ToUpper(statement[i:j]))
+			}
+			i = j - 1
+		}
+	}
+	return words
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// returnsRows reports whether a statement is a query that returns rows, which
+// includes changes with a RETURNING clause. A WITH statement is judged by the
+// statement that follows its common table expressions.
+func returnsRows(statement string) bool {
+	words := sqlTopLevelWords(statement)
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "SELECT", "VALUES", "PRAGMA", "EXPLAIN":
+		return true
+	case "INSERT", "REPLACE", "UPDATE", "DELETE":
+		return slices.Contains(words[1:], "RETURNING")
+	case "WITH":
+		for i, word := range words {
+			switch word {
+			case "SELECT", "VALUES":
+				return true
+			case "INSERT", "REPLACE", "UPDATE", "DELETE":
+				return slices.Contains(words[i+1:], "RETURNING")
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package igonb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSQLCell(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		code    string
+		want    sqlCell
+		wantErr string
+	}{
+		{
+			name: "no directives",
+			code: "SELECT 1",
+			want: sqlCell{into: DefaultSQLVariable, statements: []string{"SELECT 1"}},
+		},
+		{
+			name: "all directives",
+			code: "-- database: data/sales.db\n--table: orders = data/orders.csv\n  --  table : data/2024 items.parquet\n-- into: monthly\nSELECT * FROM orders;\nSELECT 2",
+			want: sqlCell{
+				database: "data/sales.db",
+				tables: []sqlTable{
+					{name: "orders", path: "data/orders.csv"},
+					{name: "_2024_items", path: "data/2024 items.parquet"},
+				},
+				into:       "monthly",
+				statements: []string{"-- database: data/sales.db\n--table: orders = data/orders.csv\n  --  table : data/2024 items.parquet\n-- into: monthly\nSELECT * FROM orders", "SELECT 2"},
+			},
+		},
+		{
+			name: "connection",
+			code: "-- connection: warehouse\nSELECT 1",
+			want: sqlCell{connection: "warehouse", into: DefaultSQLVariable, statements: []string{"-- connection: warehouse\nSELECT 1"}},
+		},
+		{
+			name: "directives only",
+			code: "-- database: a.db\n-- a comment",
+			want: sqlCell{database: "a.db", into: DefaultSQLVariable},
+		},
+		{
+			name: "unknown directives are comments",
+			code: "-- note: database: a.db\nSELECT 1",
+			want: sqlCell{into: DefaultSQLVariable, statements: []string{"-- note: database: a.db\nSELECT 1"}},
+		},
+		{name: "database and connection", code: "-- database: a.db\n-- connection: warehouse\nSELECT 1", wantErr: "a database or a connection directive, not both"},
+		{name: "empty directive", code: "-- into:\nSELECT 1", wantErr: "empty into directive"},
+		{name: "invalid variable", code: "-- into: 1st\nSELECT 1", wantErr: "invalid variable name in into directive: 1st"},
+		{name: "invalid table name", code: "-- table: my-orders = orders.csv\nSELECT 1", wantErr: "invalid table directive"},
+		{name: "table without path", code: "-- table: orders =\nSELECT 1", wantErr: "invalid table directive"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSQLCell(tc.code)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseSQLCell = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		code string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"one statement", "SELECT 1", []string{"SELECT 1"}},
+		{"trailing semicolon", " SELECT 1 ;\n", []string{"SELECT 1"}},
+		{"several statements", "CREATE TABLE t (x);INSERT INTO t VALUES (1);\nSELECT * FROM t", []string{"CREATE TABLE t (x)", "INSERT INTO t VALUES (1)", "SELECT * FROM t"}},
+		{"empty statements", ";; SELECT 1;;", []string{"SELECT 1"}},
+		{"semicolon in a string", "SELECT 'a;b'; SELECT 2", []string{"SELECT 'a;b'", "SELECT 2"}},
+		{"doubled quote", "SELECT 'it''s; fine'; SELECT 2", []string{"SELECT 'it''s; fine'", "SELECT 2"}},
+		{"quoted names", "SELECT \"a;b\", `c;d`, [e;f] FROM t; SELECT 2", []string{"SELECT \"a;b\", `c;d`, [e;f] FROM t", "SELECT 2"}},
+		{"line comment", "SELECT 1 -- no; split\n; SELECT 2", []string{"SELECT 1 -- no; split", "SELECT 2"}},
+		{"block comment", "SELECT /* a; b */ 1; SELECT 2", []string{"SELECT /* a; b */ 1", "SELECT 2"}},
+		{"comment-only statements", "-- just a note;\n; /* another; */ ;SELECT 1", []string{"SELECT 1"}},
+		{"unterminated string", "SELECT 'a;b", []string{"SELECT 'a;b"}},
+		{"unterminated comment", "SELECT 1; /* a; b", []string{"SELECT 1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := splitSQLStatements(tc.code); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitSQLStatements(%q) = %q, want %q", tc.code, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	for _, tc := range []struct {
+		statement string
+		want      bool
+	}{
+		{"SELECT 1", true},
+		{"  select * from t", true},
+		{"-- leading comment\n/* and another */ SELECT 1", true},
+		{"VALUES (1), (2)", true},
+		{"PRAGMA table_info(t)", true},
+		{"EXPLAIN QUERY PLAN SELECT 1", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT i FROM n", true},
+		{"with x as (select 1), y as (select 2) values (3)", true},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", false},
+		{"WITH x AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM x)", false},
+		{"WITH \"select\" AS (SELECT 1) UPDATE t SET x = (SELECT * FROM \"select\")", false},
+		{"INSERT INTO t SELECT 1", false},
+		{"INSERT INTO t (x) VALUES (1) RETURNING id", true},
+		{"update t set x = 2 where x = 1 returning *", true},
+		{"DELETE FROM t RETURNING id, x", true},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x RETURNING rowid", true},
+		{"INSERT INTO t VALUES ('RETURNING')", false},
+		{"UPDATE t SET x = (SELECT 1 RETURNING)", false},
+		{"CREATE TABLE t AS SELECT 1", false},
+		{"UPDATE t SET x = 'SELECT'", false},
+		{"SELECTED", false},
+		{"-- SELECT", false},
+		{"", false},
+	} {
+		if got := returnsRows(tc.statement); got != tc.want {
+			t.Errorf("returnsRows(%q) = %v, want %v", tc.statement, got, tc.want)
+		}
+	}
+}
+
+func TestSQLDatabasePath(t *testing.T) {
+	nb := &Notebook{Metadata: map[string]any{MetaSQLConnections: map[string]any{
+		"file":     "data/file.db",
+		"sqlite":   map[string]any{"driver": "sqlite3", "path": "data/sqlite.db"},
+		"default":  map[string]any{"path": "data/default.db"},
+		"postgres": map[string]any{"driver": "postgres", "path": "db"},
+		"nopath":   map[string]any{"driver": "sqlite"},
+		"invalid":  42,
+	}}}
+	for _, tc := range []struct {
+		name    string
+		nb      *Notebook
+		cell    sqlCell
+		want    string
+		wantErr string
+	}{
+		{name: "in memory", nb: nb, cell: sqlCell{}, want: ""},
+		{name: "database", nb: nb, cell: sqlCell{database: "a.db"}, want: "a.db"},
+		{name: "database without notebook", cell: sqlCell{database: "a.db"}, want: "a.db"},
+		{name: "connection path", nb: nb, cell: sqlCell{connection: "file"}, want: "data/file.db"},
+		{name: "connection object", nb: nb, cell: sqlCell{connection: "sqlite"}, want: "data/sqlite.db"},
+		{name: "connection without driver", nb: nb, cell: sqlCell{connection: "default"}, want: "data/default.db"},
+		{name: "other driver", nb: nb, cell: sqlCell{connection: "postgres"}, wantErr: "unsupported driver postgres for sql connection postgres"},
+		{name: "connection without path", nb: nb, cell: sqlCell{connection: "nopath"}, wantErr: "sql connection nopath has no path"},
+		{name: "invalid connection", nb: nb, cell: sqlCell{connection: "invalid"}, wantErr: "sql connection invalid has no path"},
+		{name: "unknown connection", nb: nb, cell: sqlCell{connection: "missing"}, wantErr: "unknown sql connection missing"},
+		{name: "connection without notebook", cell: sqlCell{connection: "file"}, wantErr: "unknown sql connection file"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sqlDatabasePath(tc.nb, tc.cell)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("sqlDatabasePath = %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+package igonb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TextFormat is a plain-text notebook format in the style of Jupytext. Text
+// notebooks hold the cell sources and cell metadata only, so they read and diff
+// like code; outputs can be kept in a paired .igonb file.
+type TextFormat string
+
+const (
+	// FormatPercent is a Go file with "// %%" cell markers and markdown cells as comments
+	FormatPercent TextFormat = "percent"
+	// FormatMarkdown is a Markdown document with fenced go and python code cells
+	FormatMarkdown TextFormat = "markdown"
+)
+
+// File extensions of the text formats
+const (
+	PercentExt  = ".igo.go"
+	MarkdownExt = ".igo.md"
+)
+
+// MetaPaired in notebook metadata keeps the full notebook, outputs included, in
+// a .igonb file next to a text notebook
+const MetaPaired = "paired"
+
+// TextFormatForPath returns the text format of a file name
+func TextFormatForPath(name string) (TextFormat, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, PercentExt):
+		return FormatPercent, true
+	case strings.HasSuffix(lower, MarkdownExt):
+		return FormatMarkdown, true
+	}
+	return "", false
+}
+
+// PairedPath returns the .igonb file paired with a text notebook
+func PairedPath(name string) string {
+	format, ok := TextFormatForPath(name)
+	if !ok {
+		return strings.TrimSuffix(name, path.Ext(name)) + ".igonb"
+	}
+	ext := PercentExt
+	if format == FormatMarkdown {
+		ext = MarkdownExt
+	}
+	return name[:len(name)-len(ext)] + ".igonb"
+}
+
+// Paired reports whether the notebook keeps a paired .igonb file
+func (n *Notebook) Paired() bool {
+	return metaBool(n.Metadata, MetaPaired)
+}
+
+const (
+	percentBuildTag = "//go:build ignore"
+	percentHeader   = "// igonb:"
+	markdownHeader  = "<!-- igonb:"
+	pairedOption    = "paired"
+)
+
+var (
+	// markerAttrs matches what follows %% in a cell marker: an optional
+	// [language], an optional id= and optional JSON cell metadata
+	markerAttrs   = regexp.MustCompile(`^(?:\s+\[([A-Za-z]+)\])?(?:\s+id=([\w.:-]+))?(?:\s+(\{.*\}))?\s*$`)
+	validMarkerID = regexp.MustCompile(`^[\w.:-]+$`)
+	codeFence     = regexp.MustCompile("^(`{3,})\\s*([A-Za-z]*)\\s*$")
+)
+
+// ParseText reads a text notebook. Cells without an ID marker get IDs derived
+// from their content, as in version 1 notebooks.
+func ParseText(data []byte, format TextFormat) (*Notebook, error) {
+	lines := textLines(data)
+	var cells []Cell
+	var paired bool
+	var err error
+	switch format {
+	case FormatPercent:
+		cells, paired, err = parsePercent(lines)
+	case FormatMarkdown:
+		cells, paired, err = parseMarkdown(lines)
+	default:
+		return nil, fmt.Errorf("unknown text notebook format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nb := &Notebook{Version: CurrentVersion, Cells: cells}
+	if len(nb.Cells) == 0 {
+		nb.Cells = []Cell{{Language: "go"}}
+	}
+	if paired {
+		nb.Metadata = map[string]any{MetaPaired: true}
+	}
+	normalizeNotebook(nb)
+	nb.EnsureCellIDs()
+	if err := nb.Validate(); err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+// MarshalText writes a notebook in a text format. Outputs, execution records and
+// notebook metadata other than MetaPaired are left out.
+func MarshalText(nb *Notebook, format TextFormat) ([]byte, error) {
+	if nb == nil {
+		return nil, fmt.Errorf("igonb notebook is nil")
+	}
+	if err := nb.Validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatPercent:
+		err = writePercent(&buf, nb)
+	case FormatMarkdown:
+		err = writeMarkdown(&buf, nb)
+	default:
+		return nil, fmt.Errorf("unknown text notebook format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CopyOutputs takes the outputs and execution records of the cells of src whose
+// source is unchanged in n, along with the notebook metadata n does not set. It
+// fills a notebook read from a text file from its paired .igonb.
+func (n *Notebook) CopyOutputs(src *Notebook) {
+	if src == nil {
+		return
+	}
+	match := matchCells(n.Cells, src.Cells)
+	for i, j := range match {
+		if j < 0 {
+			continue
+		}
+		from := src.Cells[j]
+		if from.Language != n.Cells[i].Language || from.Source != n.Cells[i].Source {
+			continue
+		}
+		n.Cells[i].Output = from.Output
+		n.Cells[i].Error = from.Error
+		n.Cells[i].Execution = from.Execution
+		n.Cells[i].Outputs = from.Outputs
+	}
+	for key, value := range src.Metadata {
+		if key == MetaPaired {
+			continue
+		}
+		if _, ok := n.Metadata[key]; ok {
+			continue
+		}
+		if n.Metadata == nil {
+			n.Metadata = make(map[string]any)
+		}
+		n.Metadata[key] = value
+	}
+}
+
+// textLines splits text into lines without their line endings
+func textLines(data []byte) []string {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// sourceLines splits a cell source so that strings.Join(lines, "\n") gives it back
+func sourceLines(source string) []string {
+	if source == "" {
+		return nil
+	}
+	return strings.Split(source, "\n")
+}
+
+// cutMarker returns what follows %% when line is a cell marker with the given prefix
+func cutMarker(line, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, prefix)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	return rest, true
+}
+
+// percentEscaped reports whether line reads as a "// %%" cell marker once any
+// "// " prefixes are removed. Like Jupytext, the writer comments out such lines
+// once more and the reader removes one comment again.
+func percentEscaped(line string) bool {
+	for {
+		if _, ok := cutMarker(line, "// %%"); ok {
+			return true
+		}
+		rest, ok := strings.CutPrefix(line, "// ")
+		if !ok {
+			return false
+		}
+		line = rest
+	}
+}
+
+// markdownEscaped reports whether line reads as a <!-- %% --> cell marker once
+// any extra % signs after "<!-- %%" are removed. The writer escapes such lines
+// with one more % sign and the reader removes it again.
+func markdownEscaped(line string) bool {
+	for {
+		if _, ok := markdownMarker(line); ok {
+			return true
+		}
+		rest, ok := strings.CutPrefix(line, "<!-- %%%")
+		if !ok {
+			return false
+		}
+		line = "<!-- %%" + rest
+	}
+}
+
+// unescapeMarkdown undoes the escaping of a line of a markdown cell
+func unescapeMarkdown(line string) string {
+	if rest, ok := strings.CutPrefix(line, "<!-- %%%"); ok && markdownEscaped("<!-- %%"+rest) {
+		return "<!-- %%" + rest
+	}
+	return line
+}
+
+// parseMarker reads the language, ID and metadata of a cell marker
+func parseMarker(attrs string, lineNo int) (Cell, error) {
+	m := markerAttrs.FindStringSubmatch(attrs)
+	if m == nil {
+		return Cell{}, fmt.Errorf("line %d: invalid cell marker", lineNo)
+	}
+	cell := Cell{Language: m[1], ID: m[2]}
+	if m[3] != "" {
+		if err := json.Unmarshal([]byte(m[3]), &cell.Metadata); err != nil {
+			return Cell{}, fmt.Errorf("line %d: invalid cell metadata: %w", lineNo, err)
+		}
+	}
+	return cell, nil
+}
+
+// markerAttrsText formats the attributes of a cell marker
+func markerAttrsText(cell Cell, lang string) (string, error) {
+	var b strings.Builder
+	if lang != "" {
+		b.WriteString(" [" + lang + "]")
+	}
+	if validMarkerID.MatchString(cell.ID) {
+		b.WriteString(" id=" + cell.ID)
+	}
+	if len(cell.Metadata) > 0 {
+		data, err := json.Marshal(cell.Metadata)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal cell metadata: %w", err)
+		}
+		b.WriteString(" " + string(data))
+	}
+	return b.String(), nil
+}
+
+// parseHeader skips the blank and header lines before the first cell
+func parseHeader(lines []string, isHeader func(string) (string, bool)) (int, bool) {
+	paired := false
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		options, ok := isHeader(line)
+		if !ok {
+			break
+		}
+		for _, option := range strings.Fields(options) {
+			if option == pairedOption {
+				paired = true
+			}
+		}
+	}
+	return i, paired
+}
+
+func parsePercent(lines []string) ([]Cell, bool, error) {
+	start, paired := parseHeader(lines, func(line string) (string, bool) {
+		if strings.HasPrefix(line, "//go:build") {
+			return "", true
+		}
+		options, ok := strings.CutPrefix(line, percentHeader)
+		return options, ok
+	})
+
+	var cells []Cell
+	current := -1
+	var body []string
+	flush := func(last bool) {
+		if current < 0 {
+			return
+		}
+		// the writer separates cells with one blank line
+		if !last && len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		if cells[current].Language == "markdown" {
+			for k, line := range body {
+				if text, ok := strings.CutPrefix(line, "//"); ok {
+					body[k] = strings.TrimPrefix(text, " ")
+				}
+			}
+		}
+		cells[current].Source = strings.Join(body, "\n")
+		body = nil
+	}
+	for i := start; i < len(lines); i++ {
+		attrs, ok := cutMarker(lines[i], "// %%")
+		if !ok {
+			if current < 0 {
+				// code before the first marker is a go cell
+				cells = append(cells, Cell{Language: "go"})
+				current = 0
+			}
+			line := lines[i]
+			if rest, ok := strings.CutPrefix(line, "// "); ok && percentEscaped(rest) {
+				line = rest
+			}
+			body = append(body, line)
+			continue
+		}
+		flush(false)
+		cell, err := parseMarker(attrs, i+1)
+		if err != nil {
+			return nil, false, err
+		}
+		if cell.Language == "" {
+			cell.Language = "go"
+		}
+		cells = append(cells, cell)
+		current = len(cells) - 1
+	}
+	flush(true)
+	return cells, paired, nil
+}
+
+func writePercent(buf *bytes.Buffer, nb *Notebook) error {
+	buf.WriteString(percentBuildTag + "\n")
+	if nb.Paired() {
+		buf.WriteString(percentHeader + " " + pairedOption + "\n")
+	}
+	for i, cell := range nb.Cells {
+		lang := NormalizeLanguage(cell.Language)
+		markerLang := lang
+		if lang == "go" {
+			markerLang = ""
+		}
+		attrs, err := markerAttrsText(cell, markerLang)
+		if err != nil {
+			return fmt.Errorf("cell %d: %w", i+1, err)
+		}
+		buf.WriteString("\n// %%" + attrs + "\n")
+		for _, line := range sourceLines(cell.Source) {
+			if lang == "markdown" {
+				if line == "" {
+					line = "//"
+				} else {
+					line = "// " + line
+				}
+			}
+			if percentEscaped(line) {
+				line = "// " + line
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+	return nil
+}
+
+func parseMarkdown(lines []string) ([]Cell, bool, error) {
+	start, paired := parseHeader(lines, func(line string) (string, bool) {
+		options, ok := strings.CutPrefix(line, markdownHeader)
+		if !ok || !strings.HasSuffix(options, "-->") {
+			return "", false
+		}
+		return strings.TrimSuffix(options, "-->"), true
+	})
+
+	var cells []Cell
+	var text []string // markdown outside markers, turned into a cell when not blank
+	flushText := func() {
+		first, last := 0, len(text)
+		for first < last && strings.TrimSpace(text[first]) == "" {
+			first++
+		}
+		for last > first && strings.TrimSpace(text[last-1]) == "" {
+			last--
+		}
+		if first < last {
+			cells = append(cells, Cell{Language: "markdown", Source: strings.Join(text[first:last], "\n")})
+		}
+		text = nil
+	}
+	// readFence reads the code block opening at lines[i] into cell and returns the
+	// index of the line after it
+	readFence := func(i int, cell Cell) (int, error) {
+		m := codeFence.FindStringSubmatch(lines[i])
+		if m == nil {
+			return 0, fmt.Errorf("line %d: expected a code fence after the cell marker", i+1)
+		}
+		if m[2] != "" {
+			cell.Language = m[2]
+		}
+		if cell.Language == "" {
+			cell.Language = "go"
+		}
+		for j := i + 1; j < len(lines); j++ {
+			closing := strings.TrimRight(lines[j], " \t")
+			if len(closing) >= len(m[1]) && strings.Trim(closing, "`") == "" {
+				cell.Source = strings.Join(lines[i+1:j], "\n")
+				cells = append(cells, cell)
+				return j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("line %d: unterminated code fence", i+1)
+	}
+
+	for i := start; i < len(lines); {
+		attrs, ok := markdownMarker(lines[i])
+		if !ok {
+			if m := codeFence.FindStringSubmatch(lines[i]); m != nil && isCodeLanguage(m[2]) {
+				flushText()
+				next, err := readFence(i, Cell{})
+				if err != nil {
+					return nil, false, err
+				}
+				i = next
+				continue
+			}
+			text = append(text, unescapeMarkdown(lines[i]))
+			i++
+			continue
+		}
+		flushText()
+		cell, err := parseMarker(attrs, i+1)
+		if err != nil {
+			return nil, false, err
+		}
+		if NormalizeLanguage(cell.Language) != "markdown" {
+			if i+1 >= len(lines) {
+				return nil, false, fmt.Errorf("line %d: expected a code fence after the cell marker", i+1)
+			}
+			next, err := readFence(i+1, cell)
+			if err != nil {
+				return nil, false, err
+			}
+			i = next
+			// the writer separates cells with one blank line
+			if i < len(lines) && lines[i] == "" {
+				i++
+			}
+			continue
+		}
+		// a marked markdown cell runs up to the next marker
+		j := i + 1
+		for j < len(lines) {
+			if _, ok := markdownMarker(lines[j]); ok {
+				break
+			}
+			j++
+		}
+		body := make([]string, 0, j-i-1)
+		for _, line := range lines[i+1 : j] {
+			body = append(body, unescapeMarkdown(line))
+		}
+		if j < len(lines) && len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		cell.Source = strings.Join(body, "\n")
+		cells = append(cells, cell)
+		i = j
+	}
+	flushText()
+	return cells, paired, nil
+}
+
+// markdownMarker returns what follows %% when line is a <!-- %% ... --> cell marker
+func markdownMarker(line string) (string, bool) {
+	inner, ok := strings.CutSuffix(strings.TrimRight(line, " \t"), "-->")
+	if !ok {
+		return "", false
+	}
+	return cutMarker(strings.TrimRight(inner, " \t"), "<!-- %%")
+}
+
+// isCodeLanguage reports whether a code fence's info string names a cell language
+func isCodeLanguage(lang string) bool {
+	switch NormalizeLanguage(lang) {
+	case "go", "python":
+		return true
+	}
+	return false
+}
+
+func writeMarkdown(buf *bytes.Buffer, nb *Notebook) error {
+	if nb.Paired() {
+		buf.WriteString(markdownHeader + " " + pairedOption + " -->\n\n")
+	}
+	for i, cell := range nb.Cells {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		lang := NormalizeLanguage(cell.Language)
+		markerLang := ""
+		if lang == "markdown" {
+			markerLang = lang
+		}
+		attrs, err := markerAttrsText(cell, markerLang)
+		if err != nil {
+			return fmt.Errorf("cell %d: %w", i+1, err)
+		}
+		buf.WriteString("<!-- %%" + attrs + " -->\n")
+		lines := sourceLines(cell.Source)
+		if lang == "markdown" {
+			for _, line := range lines {
+				if markdownEscaped(line) {
+					line = "<!-- %%%" + line[len("<!-- %%"):]
+				}
+				buf.WriteString(line + "\n")
+			}
+			continue
+		}
+		fence := strings.Repeat("`", max(3, longestRun(cell.Source, '`')+1))
+		buf.WriteString(fence + lang + "\n")
+		for _, line := range lines {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString(fence + "\n")
+	}
+	return nil
+}
+
+// longestRun returns the length of the longest run of c in s
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
@@ -0,0 +1,191 @@
+package igonb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func textTestNotebook(sources ...string) *Notebook {
+	nb := &Notebook{Version: CurrentVersion}
+	for i, source := range sources {
+		lang, source, _ := strings.Cut(source, ":")
+		nb.Cells = append(nb.Cells, testCell(string(rune('a'+i)), lang, source))
+	}
+	return nb
+}
+
+// checkTextRoundTrip writes nb in format, reads it back and checks that the
+// cells survive and that writing again gives the same text
+func checkTextRoundTrip(t *testing.T, nb *Notebook, format TextFormat) []byte {
+	t.Helper()
+	data, err := MarshalText(nb, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseText(data, format)
+	if err != nil {
+		t.Fatalf("ParseText: %v\n%s", err, data)
+	}
+	if len(parsed.Cells) != len(nb.Cells) {
+		t.Fatalf("read %d cells, want %d:\n%s", len(parsed.Cells), len(nb.Cells), data)
+	}
+	for i, want := range nb.Cells {
+		got := parsed.Cells[i]
+		if got.ID != want.ID || got.Language != want.Language || got.Source != want.Source || !reflect.DeepEqual(got.Metadata, want.Metadata) {
+			t.Errorf("cell %d read as %+v, want %+v\n%s", i+1, got, want, data)
+		}
+	}
+	if parsed.Paired() != nb.Paired() {
+		t.Errorf("paired = %v, want %v", parsed.Paired(), nb.Paired())
+	}
+	again, err := MarshalText(parsed, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("second write differs:\n%s\nwant:\n%s", again, data)
+	}
+	return data
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	nb := textTestNotebook(
+		"go:import \"fmt\"\n\nx := 1\nfmt.Println(x)",
+		"python:print(x)\n",
+		"markdown:# Title\n\nSome *text*.\n  indented",
+		"go:",
+		"markdown:",
+		"python:s = \"```\"",
+		"go:// a comment\n//go:generate echo\n\n",
+	)
+	nb.Metadata = map[string]any{MetaPaired: true}
+	nb.Cells[0].Metadata = map[string]any{MetaTimeout: "30s", MetaTags: []any{"setup"}}
+	nb.Cells[2].Metadata = map[string]any{MetaHideCode: true}
+	for _, format := range []TextFormat{FormatPercent, FormatMarkdown} {
+		t.Run(string(format), func(t *testing.T) {
+			checkTextRoundTrip(t, nb, format)
+		})
+	}
+}
+
+func TestTextEscapesMarkers(t *testing.T) {
+	nb := textTestNotebook(
+		"go:x := 1\n// %% note\n// // %% already escaped\n//%% not a marker\n// %%\n// %%x",
+		"markdown:%% heading\n// %% comment\n<!-- %% -->\n<!-- %%% [go] -->\n<!-- %%%% id=x -->\n<!-- %%x -->",
+		"python:# %%\n<!-- %% -->",
+	)
+	want := map[TextFormat][]string{
+		FormatPercent: {
+			"// // %% note\n",
+			"// // // %% already escaped\n",
+			"//%% not a marker\n",
+			"// // %%\n",
+			"// %%x\n",
+			"// // %% heading\n",
+			"// // // %% comment\n",
+		},
+		FormatMarkdown: {
+			"// %% note\n",
+			"<!-- %%% -->\n",
+			"<!-- %%%% [go] -->\n",
+			"<!-- %%%%% id=x -->\n",
+			"<!-- %%x -->\n",
+			"```python\n# %%\n<!-- %% -->\n```\n",
+		},
+	}
+	for _, format := range []TextFormat{FormatPercent, FormatMarkdown} {
+		t.Run(string(format), func(t *testing.T) {
+			data := checkTextRoundTrip(t, nb, format)
+			for _, line := range want[format] {
+				if !strings.Contains(string(data), line) {
+					t.Errorf("output lacks %q:\n%s", line, data)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	text := "//go:build ignore\n// igonb: paired\n\nx := 1\n\n// %% [markdown] {\"skip\":true}\n// # Title\n//\n//no space\n\n// %%\t[python]  id=p1\nprint(1)\n"
+	nb, err := ParseText([]byte(text), FormatPercent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nb.Paired() || len(nb.Cells) != 3 {
+		t.Fatalf("notebook = %+v", nb)
+	}
+	for i, want := range []Cell{
+		{Language: "go", Source: "x := 1"},
+		{Language: "markdown", Source: "# Title\n\nno space", Metadata: map[string]any{MetaSkip: true}},
+		{ID: "p1", Language: "python", Source: "print(1)"},
+	} {
+		got := nb.Cells[i]
+		if got.Language != want.Language || got.Source != want.Source || !reflect.DeepEqual(got.Metadata, want.Metadata) {
+			t.Errorf("cell %d = %+v, want %+v", i+1, got, want)
+		}
+		if want.ID != "" && got.ID != want.ID || got.ID == "" {
+			t.Errorf("cell %d ID = %q", i+1, got.ID)
+		}
+	}
+	// Cells without an ID marker get the same IDs every time
+	again, err := ParseText([]byte(text), FormatPercent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Cells[0].ID != nb.Cells[0].ID {
+		t.Errorf("ID changed from %q to %q", nb.Cells[0].ID, again.Cells[0].ID)
+	}
+
+	empty, err := ParseText(nil, FormatPercent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Cells) != 1 || empty.Cells[0].Language != "go" || empty.Cells[0].Source != "" {
+		t.Errorf("empty file read as %+v", empty.Cells)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	text := "# Title\n\nIntro.\n\n```go\nx := 1\n```\n\n```text\nnot a cell\n```\n\n<!-- %% id=py -->\n````python\ns = \"```\"\n````\n\nClosing words.\n"
+	nb, err := ParseText([]byte(text), FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cell{
+		{Language: "markdown", Source: "# Title\n\nIntro."},
+		{Language: "go", Source: "x := 1"},
+		{Language: "markdown", Source: "```text\nnot a cell\n```"},
+		{ID: "py", Language: "python", Source: "s = \"```\""},
+		{Language: "markdown", Source: "Closing words."},
+	}
+	if len(nb.Cells) != len(want) {
+		t.Fatalf("cells = %+v, want %d", nb.Cells, len(want))
+	}
+	for i := range want {
+		got := nb.Cells[i]
+		if got.Language != want[i].Language || got.Source != want[i].Source || want[i].ID != "" && got.ID != want[i].ID {
+			t.Errorf("cell %d = %+v, want %+v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	for _, tc := range []struct {
+		format  TextFormat
+		text    string
+		wantErr string
+	}{
+		{FormatPercent, "// %% [go] id=a b\n", "line 1: invalid cell marker"},
+		{FormatPercent, "x := 1\n// %% {\"skip\":}\n", "line 2: invalid cell metadata"},
+		{FormatMarkdown, "<!-- %% id=a -->\nx := 1\n", "line 2: expected a code fence"},
+		{FormatMarkdown, "<!-- %% -->", "line 1: expected a code fence"},
+		{FormatMarkdown, "```go\nx := 1\n", "line 1: unterminated code fence"},
+		{"rst", "", "unknown text notebook format"},
+	} {
+		_, err := ParseText([]byte(tc.text), tc.format)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("ParseText(%q, %s) err = %v, want %q", tc.text, tc.format, err, tc.wantErr)
+		}
+	}
+}
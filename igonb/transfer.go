@@ -0,0 +1,123 @@
+package igonb
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MetaVariableTransfer is the notebook metadata key that chooses how variables
+// pass between Go and Python cells, TransferAuto or TransferExplicit
+const MetaVariableTransfer = "variable_transfer"
+
+// Variable transfer modes
+const (
+	// TransferAuto passes every variable a Go cell assigns to Python cells and
+	// every Python global that can be converted back to Go
+	TransferAuto = "auto"
+	// TransferExplicit passes only the variables a Go cell names with
+	// //igonb:export and a Python cell names with %import and %export
+	TransferExplicit = "explicit"
+)
+
+// goExportDirective exports variables of a Go cell, as in "//igonb:export x, df"
+const goExportDirective = "//igonb:export"
+
+// Transfer reports a variable a cell passed from one language to another, or
+// why it was skipped
+type Transfer struct {
+	Name    string `json:"name"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// variableTransferMode returns the transfer mode of a notebook, TransferAuto
+// unless its metadata says TransferExplicit
+func variableTransferMode(nb *Notebook) string {
+	if nb != nil {
+		if mode, _ := nb.Metadata[MetaVariableTransfer].(string); strings.EqualFold(strings.TrimSpace(mode), TransferExplicit) {
+			return TransferExplicit
+		}
+	}
+	return TransferAuto
+}
+
+// goExportNames returns the variables named by the //igonb:export directives
+// of a Go cell
+func goExportNames(code string) []string {
+	var names []string
+	for _, line := range strings.Split(code, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), goExportDirective)
+		if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			continue
+		}
+		names = appendTransferNames(names, rest)
+	}
+	return names
+}
+
+// pythonTransferMagics are the %import and %export lines of a Python cell
+type pythonTransferMagics struct {
+	imports []string
+	exports []string
+}
+
+// parsePythonTransferMagics removes the unindented %import and %export lines
+// from the code of a Python cell and returns the names they list; the lines
+// are left empty so tracebacks keep their line numbers
+func parsePythonTransferMagics(code string) (string, pythonTransferMagics, error) {
+	var magics pythonTransferMagics
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		var target *[]string
+		var rest string
+		if r, ok := strings.CutPrefix(line, "%import"); ok {
+			target, rest = &magics.imports, r
+		} else if r, ok := strings.CutPrefix(line, "%export"); ok {
+			target, rest = &magics.exports, r
+		} else {
+			continue
+		}
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\r' {
+			continue
+		}
+		names := appendTransferNames(nil, rest)
+		if len(names) == 0 {
+			return code, magics, fmt.Errorf("line %d: %s needs variable names", i+1, strings.Fields(line)[0])
+		}
+		*target = appendTransferNames(*target, rest)
+		lines[i] = ""
+	}
+	return strings.Join(lines, "\n"), magics, nil
+}
+
+// appendTransferNames appends the comma or space separated names of list that
+// are not in names yet
+func appendTransferNames(names []string, list string) []string {
+	for _, name := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r'
+	}) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// recordTransfer adds a transfer to the report of the running cell
+func (e *Executor) recordTransfer(transfer Transfer) {
+	e.sharedMu.Lock()
+	e.transfers = append(e.transfers, transfer)
+	e.sharedMu.Unlock()
+}
+
+// takeTransfers returns and clears the report of the running cell
+func (e *Executor) takeTransfers() []Transfer {
+	e.sharedMu.Lock()
+	defer e.sharedMu.Unlock()
+	transfers := e.transfers
+	e.transfers = nil
+	return transfers
+}
@@ -0,0 +1,50 @@
+package igonb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGoExportNames(t *testing.T) {
+	code := "x := 1\n//igonb:export x, df\n  //igonb:export y df\n//igonb:exported z\n// igonb:export w\n"
+	if got, want := goExportNames(code), []string{"x", "df", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("goExportNames = %v, want %v", got, want)
+	}
+}
+
+func TestParsePythonTransferMagics(t *testing.T) {
+	code := "%import x, df\ny = x + 1\n%export y\n%export y z\nif y:\n    %export w\ns = '%importance'\n"
+	got, magics, err := parsePythonTransferMagics(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\ny = x + 1\n\n\nif y:\n    %export w\ns = '%importance'\n"; got != want {
+		t.Errorf("code = %q, want %q", got, want)
+	}
+	if want := []string{"x", "df"}; !reflect.DeepEqual(magics.imports, want) {
+		t.Errorf("imports = %v, want %v", magics.imports, want)
+	}
+	if want := []string{"y", "z"}; !reflect.DeepEqual(magics.exports, want) {
+		t.Errorf("exports = %v, want %v", magics.exports, want)
+	}
+
+	if _, _, err := parsePythonTransferMagics("x = 1\n%export\n"); err == nil {
+		t.Error("expected an error for an export magic without names")
+	}
+}
+
+func TestVariableTransferMode(t *testing.T) {
+	for _, tc := range []struct {
+		metadata map[string]any
+		want     string
+	}{
+		{nil, TransferAuto},
+		{map[string]any{MetaVariableTransfer: "explicit"}, TransferExplicit},
+		{map[string]any{MetaVariableTransfer: "auto"}, TransferAuto},
+		{map[string]any{MetaVariableTransfer: true}, TransferAuto},
+	} {
+		if got := variableTransferMode(&Notebook{Metadata: tc.metadata}); got != tc.want {
+			t.Errorf("mode for %v = %q, want %q", tc.metadata, got, tc.want)
+		}
+	}
+}
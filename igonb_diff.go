@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/igonb"
+)
+
+// NotebookMergeReport is the result of merging notebooks in the workspace
+type NotebookMergeReport struct {
+	Output    string                `json:"output"`
+	Conflicts []igonb.MergeConflict `json:"conflicts"`
+}
+
+// DiffNotebooks compares two workspace notebooks, including unsaved edits, cell by cell
+func (a *App) DiffNotebooks(oldName, newName string) (*igonb.NotebookDiff, error) {
+	oldNB, err := a.readWorkspaceNotebook(oldName)
+	if err != nil {
+		return nil, err
+	}
+	newNB, err := a.readWorkspaceNotebook(newName)
+	if err != nil {
+		return nil, err
+	}
+	return igonb.Diff(oldNB, newNB), nil
+}
+
+// MergeNotebooks merges the changes ours and theirs made to base into output. The
+// merged notebook becomes an unsaved edit of output, which is created if needed.
+func (a *App) MergeNotebooks(base, ours, theirs, output string) (*NotebookMergeReport, error) {
+	notebooks := make([]*igonb.Notebook, 3)
+	for i, name := range []string{base, ours, theirs} {
+		nb, err := a.readWorkspaceNotebook(name)
+		if err != nil {
+			return nil, err
+		}
+		notebooks[i] = nb
+	}
+	if !strings.HasSuffix(strings.ToLower(output), ".igonb") {
+		return nil, fmt.Errorf("merge output must be an .igonb file: %s", output)
+	}
+	result := igonb.Merge(notebooks[0], notebooks[1], notebooks[2])
+	if err := a.writeMergedNotebook(output, result.Notebook, false); err != nil {
+		return nil, err
+	}
+	return &NotebookMergeReport{Output: output, Conflicts: result.Conflicts}, nil
+}
+
+// ResolveNotebookConflict replaces a notebook left with git conflict markers by a
+// cell-level merge of the versions being merged and saves it. Cells that still
+// conflict carry conflict markers in their source.
+func (a *App) ResolveNotebookConflict(name string) (*NotebookMergeReport, error) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return nil, err
+	}
+	cleanName, err := cleanRelativePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(cleanName), ".igonb") {
+		return nil, fmt.Errorf("not an igonb notebook: %s", cleanName)
+	}
+	versions := make([]*igonb.Notebook, 3)
+	base, ours, theirs, err := repo.ConflictVersions(context.Background(), cleanName)
+	if err != nil {
+		return nil, err
+	}
+	for i, data := range [][]byte{base, ours, theirs} {
+		if len(data) == 0 {
+			continue
+		}
+		if versions[i], err = igonb.Parse(data); err != nil {
+			return nil, err
+		}
+	}
+	result := igonb.Merge(versions[0], versions[1], versions[2])
+	if err := a.writeMergedNotebook(cleanName, result.Notebook, true); err != nil {
+		return nil, err
+	}
+	invalidateGitStatus()
+	return &NotebookMergeReport{Output: cleanName, Conflicts: result.Conflicts}, nil
+}
+
+func (a *App) readWorkspaceNotebook(name string) (*igonb.Notebook, error) {
+	content, err := a.GetFileContent(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+	nb, err := igonb.Parse([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return nb, nil
+}
+
+// writeMergedNotebook stores a merged notebook in the workspace, creating the file if needed
+func (a *App) writeMergedNotebook(name string, nb *igonb.Notebook, save bool) error {
+	if len(nb.Cells) == 0 {
+		return fmt.Errorf("merged notebook has no cells")
+	}
+	data, err := json.MarshalIndent(nb, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal igonb: %w", err)
+	}
+	cleanName, err := cleanRelativePath(name)
+	if err != nil {
+		return err
+	}
+	globalWorkspace.mu.RLock()
+	_, exists := globalWorkspace.files[cleanName]
+	globalWorkspace.mu.RUnlock()
+	if !exists {
+		if err := a.CreateNewFile(cleanName); err != nil {
+			return err
+		}
+	}
+	if err := a.UpdateFileContent(cleanName, string(data)); err != nil {
+		return err
+	}
+	if save {
+		return a.SaveFile(cleanName)
+	}
+	return nil
+}
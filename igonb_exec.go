@@ -46,25 +46,37 @@ func (a *App) ExecuteIgonbCells(content string, cellIndex int) ([]igonb.CellResu
 		targetIndex = -cellIndex - 2
 	}
 
-	formatOutput := func(output string) string {
-		return internal.AnsiToHTMLWithBG(output, "dark")
-	}
-
+	key := getIgonbExecutorKey()
+	// streamed holds the output of running shell cells, sent whole each time
+	// so the frontend can render it like a finished result
+	streamed := make(map[int]string)
 	run := func() ([]igonb.CellResult, error) {
 		return igonbRunner.Execute(content, igonb.RunOptions{
-			Key:       getIgonbExecutorKey(),
+			Key:       key,
 			Mode:      mode,
 			Index:     targetIndex,
-			Formatter: formatOutput,
+			Formatter: formatIgonbOutput,
+			PythonEnv: workspacePythonEnvDir(),
 			OnResult: func(result igonb.CellResult) {
+				delete(streamed, result.Index)
 				if a != nil && a.ctx != nil {
 					runtime.EventsEmit(a.ctx, "igonb:cell-result", result)
 				}
 			},
+			OnOutput: func(index int, output string) {
+				streamed[index] += output
+				if a != nil && a.ctx != nil {
+					runtime.EventsEmit(a.ctx, "igonb:cell-output", map[string]any{
+						"index":  index,
+						"output": formatIgonbOutput(streamed[index]),
+					})
+				}
+			},
 		})
 	}
 
 	results, runErr := runIgonbInWorkspace(run)
+	a.notifyMCPVariablesChanged(key)
 	if runErr != nil && errors.Is(runErr, igonb.ErrExecutionStopped) {
 		if results == nil {
 			results = []igonb.CellResult{}
@@ -77,6 +89,11 @@ func (a *App) ExecuteIgonbCells(content string, cellIndex int) ([]igonb.CellResu
 	return results, runErr
 }
 
+// formatIgonbOutput renders notebook output as HTML for the frontend
+func formatIgonbOutput(output string) string {
+	return internal.AnsiToHTMLWithBG(output, "dark")
+}
+
 // ExecuteIgonb runs all cells and returns formatted results.
 func (a *App) ExecuteIgonb(content string) ([]igonb.CellResult, error) {
 	return a.ExecuteIgonbCells(content, -1)
@@ -108,7 +125,11 @@ func runIgonbInWorkspace(run func() ([]igonb.CellResult, error)) ([]igonb.CellRe
 // ResetIgonbEnvironment clears the Go/Python execution environment for the active notebook.
 func (a *App) ResetIgonbEnvironment() error {
 	key := getIgonbExecutorKey()
-	return igonbRunner.Reset(key)
+	if err := igonbRunner.Reset(key); err != nil {
+		return err
+	}
+	a.notifyMCPVariablesChanged(key)
+	return nil
 }
 
 // StopIgonbExecution requests the current notebook execution to stop after the active cell.
@@ -0,0 +1,615 @@
+// Package kernel implements the Jupyter messaging protocol for a kernel: the
+// shell, iopub, control, stdin and heartbeat channels over ZeroMQ with HMAC
+// signed messages. A Backend runs the code.
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// ConnectionInfo is the connection file Jupyter starts a kernel with
+type ConnectionInfo struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	StdinPort       int    `json:"stdin_port"`
+	ControlPort     int    `json:"control_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+	KernelName      string `json:"kernel_name,omitempty"`
+}
+
+// ReadConnectionFile reads a connection file
+func ReadConnectionFile(path string) (ConnectionInfo, error) {
+	var info ConnectionInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, fmt.Errorf("failed to read connection file: %w", err)
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("invalid connection file: %w", err)
+	}
+	return info, nil
+}
+
+// address returns where a channel listens: host:port for tcp, and the
+// ip-port socket path for ipc
+func (c ConnectionInfo) address(port int) string {
+	if c.Transport == "ipc" {
+		return c.IP + "-" + strconv.Itoa(port)
+	}
+	return net.JoinHostPort(c.IP, strconv.Itoa(port))
+}
+
+// LanguageInfo describes the language of a kernel in kernel_info_reply
+type LanguageInfo struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	MimeType       string `json:"mimetype"`
+	FileExtension  string `json:"file_extension"`
+	PygmentsLexer  string `json:"pygments_lexer,omitempty"`
+	CodeMirrorMode string `json:"codemirror_mode,omitempty"`
+}
+
+// Info describes a kernel in kernel_info_reply
+type Info struct {
+	Implementation        string
+	ImplementationVersion string
+	Banner                string
+	Language              LanguageInfo
+}
+
+// Backend runs the code of a kernel. Execute and Complete, Inspect and
+// Restart are called one at a time; Interrupt may be called while Execute runs.
+type Backend interface {
+	// Execute runs code, writing its output to x. An *Error is reported with
+	// its name and traceback; other errors are reported as an Error.
+	Execute(code string, x *Execution) error
+	// Complete returns the completions of the code at the byte offset cursor,
+	// and the byte range of code they replace
+	Complete(code string, cursor int) (matches []string, start, end int)
+	// Inspect returns the documentation of the name at the byte offset cursor
+	Inspect(code string, cursor int, detailLevel int) (text string, found bool)
+	// Interrupt stops the running Execute
+	Interrupt()
+	// Restart discards the state of the session
+	Restart() error
+}
+
+// Error is an execution error reported with a Jupyter exception name
+type Error struct {
+	Name      string
+	Value     string
+	Traceback []string
+}
+
+func (e *Error) Error() string {
+	return e.Name + ": " + e.Value
+}
+
+// ErrInterrupted is returned by Execution.Input when the execution is interrupted
+var ErrInterrupted = errors.New("interrupted")
+
+// ErrStdinNotAllowed is returned by Execution.Input when the client does not accept input requests
+var ErrStdinNotAllowed = errors.New("the client does not accept input requests")
+
+// Kernel serves a Backend over the channels of a connection file
+type Kernel struct {
+	conn    ConnectionInfo
+	info    Info
+	backend Backend
+	signer  *signer
+	session string
+
+	shell, control, stdin, iopub, hb *socket
+
+	iopubMu sync.Mutex
+	// execMu is held while the backend runs, so a restart waits for the
+	// interrupted execution to return
+	execMu sync.Mutex
+	count  int
+
+	currentMu sync.Mutex
+	current   *Execution
+	// inputReplies passes the values of input_reply messages to Input
+	inputReplies chan string
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// New binds the channels of conn. Ports that are 0 are picked by the system;
+// ConnectionInfo returns them.
+func New(conn ConnectionInfo, info Info, backend Backend) (*Kernel, error) {
+	if conn.Transport == "" {
+		conn.Transport = "tcp"
+	}
+	if conn.Transport != "tcp" && conn.Transport != "ipc" {
+		return nil, fmt.Errorf("unsupported transport %q", conn.Transport)
+	}
+	if conn.IP == "" {
+		conn.IP = "127.0.0.1"
+	}
+	if conn.SignatureScheme == "" {
+		conn.SignatureScheme = "hmac-sha256"
+	}
+	s, err := newSigner(conn.SignatureScheme, conn.Key)
+	if err != nil {
+		return nil, err
+	}
+	k := &Kernel{
+		conn:    conn,
+		info:    info,
+		backend: backend,
+		signer:  s,
+		session: newID(),
+		done:    make(chan struct{}),
+
+		inputReplies: make(chan string, 1),
+	}
+	channels := []struct {
+		sock *(*socket)
+		kind string
+		port *int
+	}{
+		{&k.shell, socketRouter, &k.conn.ShellPort},
+		{&k.control, socketRouter, &k.conn.ControlPort},
+		{&k.stdin, socketRouter, &k.conn.StdinPort},
+		{&k.iopub, socketPub, &k.conn.IOPubPort},
+		{&k.hb, socketRep, &k.conn.HBPort},
+	}
+	for _, channel := range channels {
+		sock, err := bind(channel.kind, conn.Transport, conn.address(*channel.port))
+		if err != nil {
+			k.closeSockets()
+			return nil, err
+		}
+		*channel.sock = sock
+		if port := sock.port(); port != 0 {
+			*channel.port = port
+		}
+	}
+	return k, nil
+}
+
+// ConnectionInfo returns the connection file of the kernel with its bound ports
+func (k *Kernel) ConnectionInfo() ConnectionInfo {
+	return k.conn
+}
+
+// Serve handles requests until a shutdown_request or Close
+func (k *Kernel) Serve() error {
+	k.publish(nil, "status", map[string]any{"execution_state": "starting"})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		k.serveChannel(k.shell)
+	}()
+	go func() {
+		defer wg.Done()
+		k.serveChannel(k.control)
+	}()
+	go func() {
+		defer wg.Done()
+		k.serveStdin()
+	}()
+	<-k.done
+	k.interrupt()
+	k.closeSockets()
+	wg.Wait()
+	return nil
+}
+
+// Close stops Serve
+func (k *Kernel) Close() error {
+	k.doneOnce.Do(func() { close(k.done) })
+	return nil
+}
+
+func (k *Kernel) closeSockets() {
+	for _, sock := range []*socket{k.shell, k.control, k.stdin, k.iopub, k.hb} {
+		if sock != nil {
+			sock.close()
+		}
+	}
+}
+
+func (k *Kernel) serveChannel(sock *socket) {
+	for {
+		frames, err := sock.recv()
+		if err != nil {
+			return
+		}
+		msg, err := k.signer.decode(frames)
+		if err != nil {
+			log.Printf("kernel: dropped message: %v", err)
+			continue
+		}
+		k.handle(sock, msg)
+	}
+}
+
+// handle answers a shell or control request between busy and idle statuses
+func (k *Kernel) handle(sock *socket, msg *Message) {
+	k.publish(msg, "status", map[string]any{"execution_state": "busy"})
+	defer k.publish(msg, "status", map[string]any{"execution_state": "idle"})
+
+	switch msg.Header.MsgType {
+	case "kernel_info_request":
+		k.reply(sock, msg, "kernel_info_reply", k.kernelInfo())
+	case "execute_request":
+		k.execute(sock, msg)
+	case "complete_request":
+		k.complete(sock, msg)
+	case "inspect_request":
+		k.inspect(sock, msg)
+	case "is_complete_request":
+		k.reply(sock, msg, "is_complete_reply", map[string]any{"status": "unknown"})
+	case "history_request":
+		k.reply(sock, msg, "history_reply", map[string]any{"status": "ok", "history": []any{}})
+	case "comm_info_request":
+		k.reply(sock, msg, "comm_info_reply", map[string]any{"status": "ok", "comms": map[string]any{}})
+	case "interrupt_request":
+		k.interrupt()
+		k.reply(sock, msg, "interrupt_reply", map[string]any{"status": "ok"})
+	case "shutdown_request":
+		k.shutdown(sock, msg)
+	default:
+		log.Printf("kernel: ignored %s message", msg.Header.MsgType)
+	}
+}
+
+func (k *Kernel) kernelInfo() map[string]any {
+	return map[string]any{
+		"status":                 "ok",
+		"protocol_version":       ProtocolVersion,
+		"implementation":         k.info.Implementation,
+		"implementation_version": k.info.ImplementationVersion,
+		"language_info":          k.info.Language,
+		"banner":                 k.info.Banner,
+		"help_links":             []any{},
+	}
+}
+
+// reply sends the reply to a request back to the peer that sent it
+func (k *Kernel) reply(sock *socket, parent *Message, msgType string, content any) {
+	m, err := newMessage(k.session, msgType, parent, content)
+	if err != nil {
+		log.Printf("kernel: %v", err)
+		return
+	}
+	m.Identities = parent.Identities
+	frames, err := k.signer.encode(m)
+	if err != nil {
+		log.Printf("kernel: %v", err)
+		return
+	}
+	sock.send(frames)
+}
+
+// publish broadcasts a message on iopub
+func (k *Kernel) publish(parent *Message, msgType string, content any) {
+	m, err := newMessage(k.session, msgType, parent, content)
+	if err != nil {
+		log.Printf("kernel: %v", err)
+		return
+	}
+	m.Identities = [][]byte{[]byte("kernel." + k.session + "." + msgType)}
+	frames, err := k.signer.encode(m)
+	if err != nil {
+		log.Printf("kernel: %v", err)
+		return
+	}
+	k.iopubMu.Lock()
+	defer k.iopubMu.Unlock()
+	k.iopub.send(frames)
+}
+
+type executeRequest struct {
+	Code            string            `json:"code"`
+	Silent          bool              `json:"silent"`
+	StoreHistory    *bool             `json:"store_history"`
+	UserExpressions map[string]string `json:"user_expressions"`
+	AllowStdin      bool              `json:"allow_stdin"`
+}
+
+// Execution is the running execute_request a Backend writes its output to
+type Execution struct {
+	k          *Kernel
+	parent     *Message
+	silent     bool
+	allowStdin bool
+	interrupt  chan struct{}
+	once       sync.Once
+}
+
+// Stream writes text to the "stdout" or "stderr" stream of the client
+func (x *Execution) Stream(name, text string) {
+	if x.silent || text == "" {
+		return
+	}
+	x.k.publish(x.parent, "stream", map[string]any{"name": name, "text": text})
+}
+
+// Display shows a MIME bundle, such as {"text/html": "..."}, in the client
+func (x *Execution) Display(data map[string]any) {
+	if x.silent {
+		return
+	}
+	x.k.publish(x.parent, "display_data", map[string]any{
+		"data":      data,
+		"metadata":  map[string]any{},
+		"transient": map[string]any{},
+	})
+}
+
+// Input asks the client for a line of input
+func (x *Execution) Input(prompt string, password bool) (string, error) {
+	if !x.allowStdin {
+		return "", ErrStdinNotAllowed
+	}
+	m, err := newMessage(x.k.session, "input_request", x.parent, map[string]any{
+		"prompt":   prompt,
+		"password": password,
+	})
+	if err != nil {
+		return "", err
+	}
+	m.Identities = x.parent.Identities
+	frames, err := x.k.signer.encode(m)
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-x.k.inputReplies:
+		// A late reply to an input request that was interrupted
+	default:
+	}
+	x.k.stdin.send(frames)
+	select {
+	case reply := <-x.k.inputReplies:
+		return reply, nil
+	case <-x.interrupt:
+		return "", ErrInterrupted
+	case <-x.k.done:
+		return "", ErrInterrupted
+	}
+}
+
+// Interrupted is closed when the client interrupts the execution
+func (x *Execution) Interrupted() <-chan struct{} {
+	return x.interrupt
+}
+
+func (k *Kernel) execute(sock *socket, msg *Message) {
+	var req executeRequest
+	if err := json.Unmarshal(msg.Content, &req); err != nil {
+		k.replyError(sock, msg, "execute_reply", &Error{Name: "ValueError", Value: "invalid execute_request: " + err.Error()})
+		return
+	}
+	storeHistory := !req.Silent
+	if req.StoreHistory != nil && !req.Silent {
+		storeHistory = *req.StoreHistory
+	}
+
+	k.execMu.Lock()
+	defer k.execMu.Unlock()
+	if storeHistory {
+		k.count++
+	}
+	count := k.count
+	if !req.Silent {
+		k.publish(msg, "execute_input", map[string]any{"code": req.Code, "execution_count": count})
+	}
+
+	x := &Execution{
+		k:          k,
+		parent:     msg,
+		silent:     req.Silent,
+		allowStdin: req.AllowStdin,
+		interrupt:  make(chan struct{}),
+	}
+	k.currentMu.Lock()
+	k.current = x
+	k.currentMu.Unlock()
+	err := k.backend.Execute(req.Code, x)
+	k.currentMu.Lock()
+	k.current = nil
+	k.currentMu.Unlock()
+
+	if err != nil {
+		var kernelErr *Error
+		if !errors.As(err, &kernelErr) {
+			kernelErr = &Error{Name: "Error", Value: err.Error()}
+		}
+		if kernelErr.Traceback == nil {
+			kernelErr.Traceback = []string{kernelErr.Error()}
+		}
+		if !req.Silent {
+			k.publish(msg, "error", map[string]any{
+				"ename":     kernelErr.Name,
+				"evalue":    kernelErr.Value,
+				"traceback": kernelErr.Traceback,
+			})
+		}
+		k.reply(sock, msg, "execute_reply", map[string]any{
+			"status":          "error",
+			"execution_count": count,
+			"ename":           kernelErr.Name,
+			"evalue":          kernelErr.Value,
+			"traceback":       kernelErr.Traceback,
+		})
+		return
+	}
+
+	expressions := make(map[string]any, len(req.UserExpressions))
+	for name := range req.UserExpressions {
+		expressions[name] = map[string]any{
+			"status":    "error",
+			"ename":     "NotImplementedError",
+			"evalue":    "user expressions are not supported",
+			"traceback": []string{},
+		}
+	}
+	k.reply(sock, msg, "execute_reply", map[string]any{
+		"status":           "ok",
+		"execution_count":  count,
+		"user_expressions": expressions,
+		"payload":          []any{},
+	})
+}
+
+// replyError answers a request with an error status
+func (k *Kernel) replyError(sock *socket, msg *Message, msgType string, err *Error) {
+	k.reply(sock, msg, msgType, map[string]any{
+		"status":    "error",
+		"ename":     err.Name,
+		"evalue":    err.Value,
+		"traceback": []string{err.Error()},
+	})
+}
+
+func (k *Kernel) complete(sock *socket, msg *Message) {
+	var req struct {
+		Code      string `json:"code"`
+		CursorPos int    `json:"cursor_pos"`
+	}
+	if err := json.Unmarshal(msg.Content, &req); err != nil {
+		k.replyError(sock, msg, "complete_reply", &Error{Name: "ValueError", Value: "invalid complete_request: " + err.Error()})
+		return
+	}
+	cursor := byteOffset(req.Code, req.CursorPos)
+	k.execMu.Lock()
+	matches, start, end := k.backend.Complete(req.Code, cursor)
+	k.execMu.Unlock()
+	if matches == nil {
+		matches = []string{}
+		start, end = cursor, cursor
+	}
+	k.reply(sock, msg, "complete_reply", map[string]any{
+		"status":       "ok",
+		"matches":      matches,
+		"cursor_start": runeOffset(req.Code, start),
+		"cursor_end":   runeOffset(req.Code, end),
+		"metadata":     map[string]any{},
+	})
+}
+
+func (k *Kernel) inspect(sock *socket, msg *Message) {
+	var req struct {
+		Code        string `json:"code"`
+		CursorPos   int    `json:"cursor_pos"`
+		DetailLevel int    `json:"detail_level"`
+	}
+	if err := json.Unmarshal(msg.Content, &req); err != nil {
+		k.replyError(sock, msg, "inspect_reply", &Error{Name: "ValueError", Value: "invalid inspect_request: " + err.Error()})
+		return
+	}
+	k.execMu.Lock()
+	text, found := k.backend.Inspect(req.Code, byteOffset(req.Code, req.CursorPos), req.DetailLevel)
+	k.execMu.Unlock()
+	data := map[string]any{}
+	if found {
+		data["text/plain"] = text
+	}
+	k.reply(sock, msg, "inspect_reply", map[string]any{
+		"status":   "ok",
+		"found":    found,
+		"data":     data,
+		"metadata": map[string]any{},
+	})
+}
+
+// interrupt stops the running execution, if any
+func (k *Kernel) interrupt() {
+	k.currentMu.Lock()
+	x := k.current
+	k.currentMu.Unlock()
+	if x != nil {
+		x.once.Do(func() { close(x.interrupt) })
+	}
+	k.backend.Interrupt()
+}
+
+func (k *Kernel) shutdown(sock *socket, msg *Message) {
+	var req struct {
+		Restart bool `json:"restart"`
+	}
+	if err := json.Unmarshal(msg.Content, &req); err != nil {
+		k.replyError(sock, msg, "shutdown_reply", &Error{Name: "ValueError", Value: "invalid shutdown_request: " + err.Error()})
+		return
+	}
+	k.interrupt()
+	if req.Restart {
+		k.execMu.Lock()
+		err := k.backend.Restart()
+		k.count = 0
+		k.execMu.Unlock()
+		if err != nil {
+			k.replyError(sock, msg, "shutdown_reply", &Error{Name: "RestartError", Value: err.Error()})
+			return
+		}
+	}
+	k.reply(sock, msg, "shutdown_reply", map[string]any{"status": "ok", "restart": req.Restart})
+	if !req.Restart {
+		k.Close()
+	}
+}
+
+// serveStdin passes the input_reply messages of the stdin channel to Input
+func (k *Kernel) serveStdin() {
+	for {
+		frames, err := k.stdin.recv()
+		if err != nil {
+			return
+		}
+		msg, err := k.signer.decode(frames)
+		if err != nil {
+			log.Printf("kernel: dropped message: %v", err)
+			continue
+		}
+		if msg.Header.MsgType != "input_reply" {
+			continue
+		}
+		var reply struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(msg.Content, &reply); err != nil {
+			log.Printf("kernel: invalid input_reply: %v", err)
+			continue
+		}
+		select {
+		case k.inputReplies <- reply.Value:
+		default:
+			log.Printf("kernel: dropped an input_reply nothing asked for")
+		}
+	}
+}
+
+// byteOffset converts a cursor position in code points, as the protocol
+// counts them, to a byte offset in code
+func byteOffset(code string, cursor int) int {
+	offset := 0
+	for i := 0; i < cursor && offset < len(code); i++ {
+		_, size := utf8.DecodeRuneInString(code[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// runeOffset converts a byte offset in code to a cursor position in code points
+func runeOffset(code string, offset int) int {
+	offset = max(0, min(offset, len(code)))
+	return utf8.RuneCountInString(code[:offset])
+}
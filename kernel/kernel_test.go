@@ -0,0 +1,454 @@
+package kernel
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend echoes code, fails on "fail", waits for an interrupt on "block"
+// and reads a line on "input"
+type fakeBackend struct {
+	mu         sync.Mutex
+	restarts   int
+	interrupts int
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{}
+}
+
+func (b *fakeBackend) Execute(code string, x *Execution) error {
+	switch code {
+	case "fail":
+		return &Error{Name: "GoError", Value: "boom", Traceback: []string{"GoError: boom", "at cell"}}
+	case "block":
+		<-x.Interrupted()
+		return &Error{Name: "KeyboardInterrupt", Value: "execution stopped"}
+	case "input":
+		value, err := x.Input("name? ", false)
+		if err != nil {
+			return err
+		}
+		x.Stream("stdout", "hello "+value)
+		return nil
+	}
+	x.Stream("stdout", "ran "+code)
+	x.Stream("stderr", "")
+	return nil
+}
+
+func (b *fakeBackend) Complete(code string, cursor int) ([]string, int, int) {
+	start := strings.LastIndex(code[:cursor], ".") + 1
+	return []string{"Println", "Printf"}, start, cursor
+}
+
+func (b *fakeBackend) Inspect(code string, cursor int, detailLevel int) (string, bool) {
+	if strings.Contains(code, "Println") {
+		return "func Println(a ...any) (n int, err error)", true
+	}
+	return "", false
+}
+
+func (b *fakeBackend) Interrupt() {
+	b.mu.Lock()
+	b.interrupts++
+	b.mu.Unlock()
+}
+
+func (b *fakeBackend) Restart() error {
+	b.mu.Lock()
+	b.restarts++
+	b.mu.Unlock()
+	return nil
+}
+
+// testClient is a scripted Jupyter client speaking ZMTP over TCP
+type testClient struct {
+	t       *testing.T
+	signer  *signer
+	session string
+	shell   *zmtpConn
+	control *zmtpConn
+	stdin   *zmtpConn
+	iopub   *zmtpConn
+	hb      *zmtpConn
+}
+
+func dial(t *testing.T, port int, socketType string, identity []byte) *zmtpConn {
+	t.Helper()
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c, err := handshake(conn, socketType, identity)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	t.Cleanup(func() { c.close() })
+	return c
+}
+
+func startKernel(t *testing.T, backend Backend) (*Kernel, *testClient, chan error) {
+	t.Helper()
+	k, err := New(ConnectionInfo{Transport: "tcp", IP: "127.0.0.1", Key: "secret", SignatureScheme: "hmac-sha256"}, Info{
+		Implementation:        "test",
+		ImplementationVersion: "1.0",
+		Language:              LanguageInfo{Name: "go", MimeType: "text/x-go", FileExtension: ".go"},
+	}, backend)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- k.Serve() }()
+	t.Cleanup(func() { k.Close() })
+
+	info := k.ConnectionInfo()
+	signer, _ := newSigner(info.SignatureScheme, info.Key)
+	identity := []byte("client-1")
+	c := &testClient{
+		t:       t,
+		signer:  signer,
+		session: newID(),
+		shell:   dial(t, info.ShellPort, socketDealer, identity),
+		control: dial(t, info.ControlPort, socketDealer, nil),
+		stdin:   dial(t, info.StdinPort, socketDealer, identity),
+		iopub:   dial(t, info.IOPubPort, socketSub, nil),
+		hb:      dial(t, info.HBPort, socketReq, nil),
+	}
+	if err := c.iopub.send([][]byte{{1}}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	// Like jupyter_client, ask for kernel info until iopub delivers its status
+	for attempt := 0; ; attempt++ {
+		if attempt == 50 {
+			t.Fatalf("iopub never delivered a status message")
+		}
+		request := c.send(c.shell, "kernel_info_request", map[string]any{})
+		c.recv(c.shell, 5*time.Second)
+		if msg := c.tryRecv(c.iopub, 100*time.Millisecond); msg != nil {
+			c.collect(request.Header.MsgID)
+			break
+		}
+	}
+	return k, c, served
+}
+
+func (c *testClient) send(conn *zmtpConn, msgType string, content any) *Message {
+	c.t.Helper()
+	msg, err := newMessage(c.session, msgType, nil, content)
+	if err != nil {
+		c.t.Fatalf("newMessage: %v", err)
+	}
+	frames, err := c.signer.encode(msg)
+	if err != nil {
+		c.t.Fatalf("encode: %v", err)
+	}
+	if err := conn.send(frames); err != nil {
+		c.t.Fatalf("send: %v", err)
+	}
+	return msg
+}
+
+func (c *testClient) tryRecv(conn *zmtpConn, timeout time.Duration) *Message {
+	c.t.Helper()
+	conn.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.conn.SetReadDeadline(time.Time{})
+	frames, err := conn.recv()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		c.t.Fatalf("recv: %v", err)
+	}
+	msg, err := c.signer.decode(frames)
+	if err != nil {
+		c.t.Fatalf("decode: %v", err)
+	}
+	return msg
+}
+
+func (c *testClient) recv(conn *zmtpConn, timeout time.Duration) *Message {
+	c.t.Helper()
+	msg := c.tryRecv(conn, timeout)
+	if msg == nil {
+		c.t.Fatalf("no message within %s", timeout)
+	}
+	return msg
+}
+
+// collect returns the iopub messages of a request, up to its idle status
+func (c *testClient) collect(msgID string) []*Message {
+	c.t.Helper()
+	var messages []*Message
+	for {
+		msg := c.recv(c.iopub, 5*time.Second)
+		var parent Header
+		json.Unmarshal(msg.Parent, &parent)
+		if parent.MsgID != msgID {
+			continue
+		}
+		messages = append(messages, msg)
+		if msg.Header.MsgType == "status" && content(c.t, msg)["execution_state"] == "idle" {
+			return messages
+		}
+	}
+}
+
+func content(t *testing.T, msg *Message) map[string]any {
+	t.Helper()
+	var c map[string]any
+	if err := json.Unmarshal(msg.Content, &c); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+	return c
+}
+
+func msgTypes(messages []*Message) []string {
+	types := make([]string, len(messages))
+	for i, msg := range messages {
+		types[i] = msg.Header.MsgType
+	}
+	return types
+}
+
+func TestKernelInfoAndHeartbeat(t *testing.T) {
+	_, c, _ := startKernel(t, newFakeBackend())
+
+	request := c.send(c.shell, "kernel_info_request", map[string]any{})
+	reply := c.recv(c.shell, 5*time.Second)
+	if reply.Header.MsgType != "kernel_info_reply" {
+		t.Fatalf("unexpected reply %s", reply.Header.MsgType)
+	}
+	var parent Header
+	json.Unmarshal(reply.Parent, &parent)
+	if parent.MsgID != request.Header.MsgID {
+		t.Fatalf("reply parent %q, want %q", parent.MsgID, request.Header.MsgID)
+	}
+	info := content(t, reply)
+	if info["protocol_version"] != ProtocolVersion || info["implementation"] != "test" {
+		t.Fatalf("unexpected kernel info: %v", info)
+	}
+	if language := info["language_info"].(map[string]any); language["name"] != "go" {
+		t.Fatalf("unexpected language info: %v", language)
+	}
+	if got := msgTypes(c.collect(request.Header.MsgID)); strings.Join(got, ",") != "status,status" {
+		t.Fatalf("unexpected iopub messages: %v", got)
+	}
+
+	if err := c.hb.send([][]byte{{}, []byte("ping")}); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	c.hb.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	echo, err := c.hb.recv()
+	if err != nil || len(echo) != 2 || string(echo[1]) != "ping" {
+		t.Fatalf("unexpected heartbeat echo %q: %v", echo, err)
+	}
+}
+
+func TestKernelExecute(t *testing.T) {
+	_, c, _ := startKernel(t, newFakeBackend())
+
+	request := c.send(c.shell, "execute_request", map[string]any{"code": "x := 1", "silent": false})
+	reply := content(t, c.recv(c.shell, 5*time.Second))
+	if reply["status"] != "ok" || reply["execution_count"] != float64(1) {
+		t.Fatalf("unexpected execute reply: %v", reply)
+	}
+	messages := c.collect(request.Header.MsgID)
+	if got := strings.Join(msgTypes(messages), ","); got != "status,execute_input,stream,status" {
+		t.Fatalf("unexpected iopub messages: %s", got)
+	}
+	if stream := content(t, messages[2]); stream["name"] != "stdout" || stream["text"] != "ran x := 1" {
+		t.Fatalf("unexpected stream: %v", stream)
+	}
+	if input := content(t, messages[1]); input["code"] != "x := 1" || input["execution_count"] != float64(1) {
+		t.Fatalf("unexpected execute_input: %v", input)
+	}
+
+	request = c.send(c.shell, "execute_request", map[string]any{"code": "fail"})
+	reply = content(t, c.recv(c.shell, 5*time.Second))
+	if reply["status"] != "error" || reply["ename"] != "GoError" || reply["evalue"] != "boom" || reply["execution_count"] != float64(2) {
+		t.Fatalf("unexpected error reply: %v", reply)
+	}
+	messages = c.collect(request.Header.MsgID)
+	if got := strings.Join(msgTypes(messages), ","); got != "status,execute_input,error,status" {
+		t.Fatalf("unexpected iopub messages: %s", got)
+	}
+	if traceback := content(t, messages[2])["traceback"].([]any); len(traceback) != 2 {
+		t.Fatalf("unexpected traceback: %v", traceback)
+	}
+
+	request = c.send(c.shell, "execute_request", map[string]any{"code": "quiet", "silent": true})
+	reply = content(t, c.recv(c.shell, 5*time.Second))
+	if reply["status"] != "ok" || reply["execution_count"] != float64(2) {
+		t.Fatalf("silent executions should not count: %v", reply)
+	}
+	if got := strings.Join(msgTypes(c.collect(request.Header.MsgID)), ","); got != "status,status" {
+		t.Fatalf("silent executions should not publish output: %s", got)
+	}
+}
+
+func TestKernelCompleteAndInspect(t *testing.T) {
+	_, c, _ := startKernel(t, newFakeBackend())
+
+	// Cursor positions count code points, so the é before the cursor is one
+	code := `s := "é"; fmt.Pri`
+	c.send(c.shell, "complete_request", map[string]any{"code": code, "cursor_pos": len([]rune(code))})
+	reply := content(t, c.recv(c.shell, 5*time.Second))
+	matches := reply["matches"].([]any)
+	if len(matches) != 2 || matches[0] != "Println" {
+		t.Fatalf("unexpected matches: %v", reply)
+	}
+	if reply["cursor_start"] != float64(14) || reply["cursor_end"] != float64(17) {
+		t.Fatalf("unexpected cursor range: %v", reply)
+	}
+
+	c.send(c.shell, "inspect_request", map[string]any{"code": "fmt.Println", "cursor_pos": 6, "detail_level": 0})
+	reply = content(t, c.recv(c.shell, 5*time.Second))
+	if reply["found"] != true || reply["data"].(map[string]any)["text/plain"] == nil {
+		t.Fatalf("unexpected inspect reply: %v", reply)
+	}
+	c.send(c.shell, "inspect_request", map[string]any{"code": "nothing", "cursor_pos": 3})
+	if reply := content(t, c.recv(c.shell, 5*time.Second)); reply["found"] != false {
+		t.Fatalf("unexpected inspect reply: %v", reply)
+	}
+}
+
+func TestKernelInterruptAndRestart(t *testing.T) {
+	backend := newFakeBackend()
+	_, c, _ := startKernel(t, backend)
+
+	c.send(c.shell, "execute_request", map[string]any{"code": "block"})
+	time.Sleep(50 * time.Millisecond)
+	c.send(c.control, "interrupt_request", map[string]any{})
+	if reply := c.recv(c.control, 5*time.Second); reply.Header.MsgType != "interrupt_reply" {
+		t.Fatalf("unexpected reply %s", reply.Header.MsgType)
+	}
+	reply := content(t, c.recv(c.shell, 5*time.Second))
+	if reply["status"] != "error" || reply["ename"] != "KeyboardInterrupt" {
+		t.Fatalf("unexpected execute reply: %v", reply)
+	}
+
+	c.send(c.control, "shutdown_request", map[string]any{"restart": true})
+	reply = content(t, c.recv(c.control, 5*time.Second))
+	if reply["status"] != "ok" || reply["restart"] != true {
+		t.Fatalf("unexpected shutdown reply: %v", reply)
+	}
+	backend.mu.Lock()
+	restarts, interrupts := backend.restarts, backend.interrupts
+	backend.mu.Unlock()
+	if restarts != 1 || interrupts < 1 {
+		t.Fatalf("restarts = %d, interrupts = %d", restarts, interrupts)
+	}
+
+	c.send(c.shell, "execute_request", map[string]any{"code": "y"})
+	if reply := content(t, c.recv(c.shell, 5*time.Second)); reply["execution_count"] != float64(1) {
+		t.Fatalf("restart should reset the execution count: %v", reply)
+	}
+}
+
+func TestKernelInput(t *testing.T) {
+	_, c, _ := startKernel(t, newFakeBackend())
+
+	request := c.send(c.shell, "execute_request", map[string]any{"code": "input", "allow_stdin": true})
+	prompt := c.recv(c.stdin, 5*time.Second)
+	if prompt.Header.MsgType != "input_request" || content(t, prompt)["prompt"] != "name? " {
+		t.Fatalf("unexpected input request: %v", content(t, prompt))
+	}
+	c.send(c.stdin, "input_reply", map[string]any{"value": "gopher"})
+	if reply := content(t, c.recv(c.shell, 5*time.Second)); reply["status"] != "ok" {
+		t.Fatalf("unexpected execute reply: %v", reply)
+	}
+	messages := c.collect(request.Header.MsgID)
+	if stream := content(t, messages[2]); stream["text"] != "hello gopher" {
+		t.Fatalf("unexpected stream: %v", stream)
+	}
+
+	c.send(c.shell, "execute_request", map[string]any{"code": "input", "allow_stdin": false})
+	if reply := content(t, c.recv(c.shell, 5*time.Second)); reply["status"] != "error" {
+		t.Fatalf("input without stdin should fail: %v", reply)
+	}
+}
+
+func TestKernelDropsBadSignatures(t *testing.T) {
+	_, c, _ := startKernel(t, newFakeBackend())
+
+	forged := *c
+	forged.signer, _ = newSigner("hmac-sha256", "wrong")
+	forged.send(c.shell, "execute_request", map[string]any{"code": "forged"})
+	request := c.send(c.shell, "kernel_info_request", map[string]any{})
+	reply := c.recv(c.shell, 5*time.Second)
+	var parent Header
+	json.Unmarshal(reply.Parent, &parent)
+	if parent.MsgID != request.Header.MsgID {
+		t.Fatalf("the forged request was answered: %s", reply.Header.MsgType)
+	}
+}
+
+func TestKernelShutdown(t *testing.T) {
+	_, c, served := startKernel(t, newFakeBackend())
+
+	c.send(c.control, "shutdown_request", map[string]any{"restart": false})
+	if reply := content(t, c.recv(c.control, 5*time.Second)); reply["restart"] != false {
+		t.Fatalf("unexpected shutdown reply: %v", reply)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Serve did not return after shutdown")
+	}
+}
+
+func TestInstallKernelspec(t *testing.T) {
+	dir, err := Install(Spec{
+		Argv:          []string{"/usr/local/bin/idensyra-kernel", "-f", "{connection_file}"},
+		DisplayName:   "Go (Idensyra)",
+		Language:      "go",
+		InterruptMode: "message",
+	}, t.TempDir(), "idensyra")
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "kernel.json"))
+	if err != nil {
+		t.Fatalf("read kernel.json: %v", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("kernel.json: %v", err)
+	}
+	if spec.Argv[2] != "{connection_file}" || spec.InterruptMode != "message" || filepath.Base(dir) != "idensyra" {
+		t.Fatalf("unexpected kernelspec %s: %+v", dir, spec)
+	}
+}
+
+func TestConnectionFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kernel.json")
+	os.WriteFile(path, []byte(`{"transport": "tcp", "ip": "127.0.0.1", "shell_port": 0, "iopub_port": 0,
+		"stdin_port": 0, "control_port": 0, "hb_port": 0, "key": "k", "signature_scheme": "hmac-sha256"}`), 0o644)
+	info, err := ReadConnectionFile(path)
+	if err != nil {
+		t.Fatalf("ReadConnectionFile: %v", err)
+	}
+	k, err := New(info, Info{}, newFakeBackend())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer k.closeSockets()
+	bound := k.ConnectionInfo()
+	if bound.ShellPort == 0 || bound.HBPort == 0 || bound.Key != "k" {
+		t.Fatalf("unexpected bound connection info: %+v", bound)
+	}
+	if _, err := New(ConnectionInfo{SignatureScheme: "hmac-sha3"}, Info{}, newFakeBackend()); err == nil {
+		t.Fatalf("unknown signature schemes should be rejected")
+	}
+}
@@ -0,0 +1,66 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Spec is the kernel.json of a kernelspec
+type Spec struct {
+	Argv          []string          `json:"argv"`
+	DisplayName   string            `json:"display_name"`
+	Language      string            `json:"language"`
+	InterruptMode string            `json:"interrupt_mode,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Metadata      map[string]any    `json:"metadata,omitempty"`
+}
+
+// UserKernelsDir returns the kernels directory of the user's Jupyter data
+// directory, honoring JUPYTER_DATA_DIR
+func UserKernelsDir() (string, error) {
+	if dir := os.Getenv("JUPYTER_DATA_DIR"); dir != "" {
+		return filepath.Join(dir, "kernels"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %w", err)
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "jupyter", "kernels"), nil
+		}
+		return filepath.Join(home, "AppData", "Roaming", "jupyter", "kernels"), nil
+	case "darwin":
+		return filepath.Join(home, "Library", "Jupyter", "kernels"), nil
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "jupyter", "kernels"), nil
+	}
+	return filepath.Join(home, ".local", "share", "jupyter", "kernels"), nil
+}
+
+// PrefixKernelsDir returns the kernels directory of a Jupyter installation
+// prefix, such as a virtual environment
+func PrefixKernelsDir(prefix string) string {
+	return filepath.Join(prefix, "share", "jupyter", "kernels")
+}
+
+// Install writes spec as kernelsDir/name/kernel.json and returns its directory
+func Install(spec Spec, kernelsDir, name string) (string, error) {
+	dir := filepath.Join(kernelsDir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create kernelspec directory: %w", err)
+	}
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode kernelspec: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kernel.json"), append(data, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write kernelspec: %w", err)
+	}
+	return dir, nil
+}
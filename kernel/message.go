@@ -0,0 +1,175 @@
+package kernel
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// ProtocolVersion is the version of the Jupyter messaging protocol the kernel speaks
+const ProtocolVersion = "5.3"
+
+// delimiter separates the routing identities of a message from its parts
+var delimiter = []byte("<IDS|MSG>")
+
+// Header is the header of a Jupyter message
+type Header struct {
+	MsgID    string `json:"msg_id"`
+	Session  string `json:"session"`
+	Username string `json:"username"`
+	Date     string `json:"date"`
+	MsgType  string `json:"msg_type"`
+	Version  string `json:"version"`
+}
+
+// Message is a Jupyter message. Parent is the raw header of the request a
+// message answers, so clients see it exactly as they sent it.
+type Message struct {
+	Identities [][]byte
+	Header     Header
+	Parent     json.RawMessage
+	Metadata   map[string]any
+	Content    json.RawMessage
+	Buffers    [][]byte
+	// rawHeader is the header as received
+	rawHeader json.RawMessage
+}
+
+// signer signs and verifies messages with the key of a connection file
+type signer struct {
+	key     []byte
+	newHash func() hash.Hash
+}
+
+func newSigner(scheme, key string) (*signer, error) {
+	s := &signer{key: []byte(key)}
+	switch scheme {
+	case "", "hmac-sha256":
+		s.newHash = sha256.New
+	case "hmac-sha512":
+		s.newHash = sha512.New
+	case "hmac-sha1":
+		s.newHash = sha1.New
+	case "hmac-md5":
+		s.newHash = md5.New
+	default:
+		return nil, fmt.Errorf("unsupported signature scheme %q", scheme)
+	}
+	return s, nil
+}
+
+// sign returns the hex HMAC of the message parts, or "" without a key
+func (s *signer) sign(parts ...[]byte) []byte {
+	if len(s.key) == 0 {
+		return nil
+	}
+	mac := hmac.New(s.newHash, s.key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// encode returns the wire frames of a message
+func (s *signer) encode(m *Message) ([][]byte, error) {
+	header, err := json.Marshal(m.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode header: %w", err)
+	}
+	parent := []byte(m.Parent)
+	if len(parent) == 0 {
+		parent = []byte("{}")
+	}
+	metadata := m.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	content := []byte(m.Content)
+	if len(content) == 0 {
+		content = []byte("{}")
+	}
+	frames := make([][]byte, 0, len(m.Identities)+6+len(m.Buffers))
+	frames = append(frames, m.Identities...)
+	frames = append(frames, delimiter, s.sign(header, parent, meta, content), header, parent, meta, content)
+	return append(frames, m.Buffers...), nil
+}
+
+// decode parses and verifies the wire frames of a message
+func (s *signer) decode(frames [][]byte) (*Message, error) {
+	split := -1
+	for i, frame := range frames {
+		if bytes.Equal(frame, delimiter) {
+			split = i
+			break
+		}
+	}
+	if split < 0 {
+		return nil, fmt.Errorf("message has no delimiter")
+	}
+	parts := frames[split+1:]
+	if len(parts) < 5 {
+		return nil, fmt.Errorf("message has %d parts, want at least 5", len(parts))
+	}
+	if len(s.key) > 0 && !hmac.Equal(parts[0], s.sign(parts[1:5]...)) {
+		return nil, fmt.Errorf("message has an invalid signature")
+	}
+	m := &Message{
+		Identities: frames[:split],
+		Parent:     json.RawMessage(parts[2]),
+		Content:    json.RawMessage(parts[4]),
+		Buffers:    parts[5:],
+		rawHeader:  json.RawMessage(parts[1]),
+	}
+	if err := json.Unmarshal(parts[1], &m.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if err := json.Unmarshal(parts[3], &m.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return m, nil
+}
+
+// newMessage builds a message of the session, answering parent if it is not nil
+func newMessage(session, msgType string, parent *Message, content any) (*Message, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s content: %w", msgType, err)
+	}
+	m := &Message{
+		Header: Header{
+			MsgID:    newID(),
+			Session:  session,
+			Username: "kernel",
+			Date:     time.Now().UTC().Format(time.RFC3339Nano),
+			MsgType:  msgType,
+			Version:  ProtocolVersion,
+		},
+		Content: data,
+	}
+	if parent != nil {
+		m.Parent = parent.rawHeader
+	}
+	return m, nil
+}
+
+// newID returns a random UUID
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
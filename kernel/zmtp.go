@@ -0,0 +1,480 @@
+package kernel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// This file is a small ZeroMQ implementation: ZMTP 3.x with the NULL security
+// mechanism, and the three socket types a kernel binds. ROUTER serves the
+// shell, control and stdin channels, PUB serves iopub, and REP echoes every
+// request, which is all the heartbeat channel needs. Peers may speak ZMTP 3.0
+// or 3.1, so subscriptions are accepted both as messages and as commands.
+
+// Kinds of socket, as sent in the Socket-Type property
+const (
+	socketRouter = "ROUTER"
+	socketPub    = "PUB"
+	socketRep    = "REP"
+	socketDealer = "DEALER"
+	socketSub    = "SUB"
+	socketReq    = "REQ"
+)
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// maxFrameSize bounds the frames a peer may send
+const maxFrameSize = 1 << 30
+
+var errSocketClosed = errors.New("socket closed")
+
+// zmtpConn is a connection that completed the ZMTP handshake
+type zmtpConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	wmu      sync.Mutex
+	peerType string
+	identity []byte
+}
+
+// handshake exchanges greetings and READY commands on conn
+func handshake(conn net.Conn, socketType string, identity []byte) (*zmtpConn, error) {
+	c := &zmtpConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	greeting[11] = 1
+	copy(greeting[12:32], "NULL")
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, fmt.Errorf("failed to send greeting: %w", err)
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(c.r, peer); err != nil {
+		return nil, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if peer[0] != 0xff || peer[9]&0x01 == 0 {
+		return nil, fmt.Errorf("peer is not a ZMTP 3 peer")
+	}
+	if peer[10] < 3 {
+		return nil, fmt.Errorf("unsupported ZMTP version %d.%d", peer[10], peer[11])
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return nil, fmt.Errorf("unsupported security mechanism %q", mechanism)
+	}
+
+	ready := appendCommandName(nil, "READY")
+	ready = appendProperty(ready, "Socket-Type", []byte(socketType))
+	if len(identity) > 0 {
+		ready = appendProperty(ready, "Identity", identity)
+	}
+	if _, err := conn.Write(appendFrame(nil, flagCommand, ready)); err != nil {
+		return nil, fmt.Errorf("failed to send READY: %w", err)
+	}
+
+	flags, body, err := c.readFrame()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read READY: %w", err)
+	}
+	name, data, err := parseCommand(body)
+	if err != nil || flags&flagCommand == 0 {
+		return nil, fmt.Errorf("expected a READY command")
+	}
+	if name == "ERROR" {
+		return nil, fmt.Errorf("peer refused the connection: %s", commandError(data))
+	}
+	if name != "READY" {
+		return nil, fmt.Errorf("expected a READY command, got %s", name)
+	}
+	properties, err := parseProperties(data)
+	if err != nil {
+		return nil, err
+	}
+	c.peerType = strings.ToUpper(string(properties["socket-type"]))
+	c.identity = properties["identity"]
+	if !compatibleSockets(socketType, c.peerType) {
+		return nil, fmt.Errorf("%s socket cannot talk to a %s socket", socketType, c.peerType)
+	}
+	return c, nil
+}
+
+// compatibleSockets reports whether ZeroMQ lets the two socket types connect
+func compatibleSockets(local, peer string) bool {
+	switch local {
+	case socketRouter:
+		return peer == socketDealer || peer == socketReq || peer == socketRouter
+	case socketPub:
+		return peer == socketSub || peer == "XSUB"
+	case socketRep:
+		return peer == socketReq || peer == socketDealer
+	case socketDealer:
+		return peer == socketRouter || peer == socketRep || peer == socketDealer
+	case socketSub:
+		return peer == socketPub || peer == "XPUB"
+	case socketReq:
+		return peer == socketRep || peer == socketRouter
+	}
+	return false
+}
+
+func appendFrame(buf []byte, flags byte, body []byte) []byte {
+	if len(body) > 255 {
+		buf = append(buf, flags|flagLong)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(body)))
+	} else {
+		buf = append(buf, flags, byte(len(body)))
+	}
+	return append(buf, body...)
+}
+
+func appendCommandName(buf []byte, name string) []byte {
+	buf = append(buf, byte(len(name)))
+	return append(buf, name...)
+}
+
+func appendProperty(buf []byte, name string, value []byte) []byte {
+	buf = append(buf, byte(len(name)))
+	buf = append(buf, name...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(value)))
+	return append(buf, value...)
+}
+
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil, fmt.Errorf("malformed command")
+	}
+	size := int(body[0])
+	return string(body[1 : 1+size]), body[1+size:], nil
+}
+
+// parseProperties reads the metadata of a READY command, by lowercase name
+func parseProperties(data []byte) (map[string][]byte, error) {
+	properties := make(map[string][]byte)
+	for len(data) > 0 {
+		size := int(data[0])
+		if len(data) < 1+size+4 {
+			return nil, fmt.Errorf("malformed READY property")
+		}
+		name := strings.ToLower(string(data[1 : 1+size]))
+		data = data[1+size:]
+		valueSize := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(valueSize) > uint64(len(data)) {
+			return nil, fmt.Errorf("malformed READY property %q", name)
+		}
+		properties[name] = data[:valueSize]
+		data = data[valueSize:]
+	}
+	return properties, nil
+}
+
+func commandError(data []byte) string {
+	if len(data) == 0 || int(data[0]) > len(data)-1 {
+		return "unknown reason"
+	}
+	return string(data[1 : 1+int(data[0])])
+}
+
+func (c *zmtpConn) readFrame() (byte, []byte, error) {
+	flags, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var long [8]byte
+		if _, err := io.ReadFull(c.r, long[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(long[:])
+	} else {
+		short, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// recv reads the next message. PING commands are answered, and ZMTP 3.1
+// SUBSCRIBE and CANCEL commands are returned in their ZMTP 3.0 message form.
+func (c *zmtpConn) recv() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		flags, body, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(body)
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "PING":
+				if err := c.pong(data); err != nil {
+					return nil, err
+				}
+			case "SUBSCRIBE":
+				return [][]byte{append([]byte{1}, data...)}, nil
+			case "CANCEL":
+				return [][]byte{append([]byte{0}, data...)}, nil
+			case "ERROR":
+				return nil, fmt.Errorf("peer closed the connection: %s", commandError(data))
+			}
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// pong answers a PING with its context, which follows a two-byte TTL
+func (c *zmtpConn) pong(ping []byte) error {
+	body := appendCommandName(nil, "PONG")
+	if len(ping) > 2 {
+		body = append(body, ping[2:]...)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(appendFrame(nil, flagCommand, body))
+	return err
+}
+
+// send writes the frames of a message in one write
+func (c *zmtpConn) send(frames [][]byte) error {
+	var buf []byte
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		buf = appendFrame(buf, flags, frame)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func (c *zmtpConn) close() error {
+	return c.conn.Close()
+}
+
+// socketPeer is a connected peer of a bound socket
+type socketPeer struct {
+	conn *zmtpConn
+	id   string
+	// subscriptions are the topic prefixes a SUB peer asked for
+	subscriptions [][]byte
+}
+
+// socket is a bound ZeroMQ socket
+type socket struct {
+	kind     string
+	listener net.Listener
+	mu       sync.Mutex
+	peers    map[string]*socketPeer
+	nextID   uint32
+	incoming chan [][]byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// bind listens on addr ("tcp" host:port, or an "ipc" socket path) and accepts
+// peers in the background
+func bind(kind, transport, addr string) (*socket, error) {
+	network := "tcp"
+	if transport == "ipc" {
+		network = "unix"
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind %s socket on %s: %w", kind, addr, err)
+	}
+	s := &socket{
+		kind:     kind,
+		listener: listener,
+		peers:    make(map[string]*socketPeer),
+		incoming: make(chan [][]byte, 64),
+		closed:   make(chan struct{}),
+	}
+	go s.accept()
+	return s, nil
+}
+
+// port returns the TCP port the socket is bound to, or 0 for ipc
+func (s *socket) port() int {
+	if addr, ok := s.listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+func (s *socket) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *socket) serve(conn net.Conn) {
+	c, err := handshake(conn, s.kind, nil)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	peer := s.addPeer(c)
+	defer s.removePeer(peer)
+	for {
+		frames, err := c.recv()
+		if err != nil {
+			return
+		}
+		switch s.kind {
+		case socketPub:
+			s.subscribe(peer, frames)
+		case socketRep:
+			// The heartbeat: echo the request, envelope included
+			if err := c.send(frames); err != nil {
+				return
+			}
+		case socketRouter:
+			message := append([][]byte{[]byte(peer.id)}, frames...)
+			select {
+			case s.incoming <- message:
+			case <-s.closed:
+				return
+			}
+		}
+	}
+}
+
+// addPeer registers a connection under the identity it announced, or under a
+// generated one like libzmq's when it announced none or one already in use
+func (s *socket) addPeer(c *zmtpConn) *socketPeer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := string(c.identity)
+	if _, taken := s.peers[id]; id == "" || taken {
+		s.nextID++
+		generated := binary.BigEndian.AppendUint32([]byte{0}, s.nextID)
+		id = string(generated)
+	}
+	peer := &socketPeer{conn: c, id: id}
+	s.peers[id] = peer
+	return peer
+}
+
+func (s *socket) removePeer(peer *socketPeer) {
+	s.mu.Lock()
+	if s.peers[peer.id] == peer {
+		delete(s.peers, peer.id)
+	}
+	s.mu.Unlock()
+	peer.conn.close()
+}
+
+// subscribe applies a subscription message: 1 or 0 followed by the topic
+func (s *socket) subscribe(peer *socketPeer, frames [][]byte) {
+	if len(frames) != 1 || len(frames[0]) == 0 {
+		return
+	}
+	topic := frames[0][1:]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch frames[0][0] {
+	case 1:
+		peer.subscriptions = append(peer.subscriptions, append([]byte(nil), topic...))
+	case 0:
+		for i, subscription := range peer.subscriptions {
+			if bytes.Equal(subscription, topic) {
+				peer.subscriptions = append(peer.subscriptions[:i], peer.subscriptions[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// recv returns the next message of a ROUTER socket, prefixed with the
+// identity of the peer that sent it
+func (s *socket) recv() ([][]byte, error) {
+	select {
+	case message := <-s.incoming:
+		return message, nil
+	case <-s.closed:
+		return nil, errSocketClosed
+	}
+}
+
+// send routes a message of a ROUTER socket to the peer named by its first
+// frame, or publishes a message of a PUB socket to the peers subscribed to its
+// first frame. Messages to unknown or unsubscribed peers are dropped, as in
+// ZeroMQ.
+func (s *socket) send(frames [][]byte) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("empty message")
+	}
+	var targets []*socketPeer
+	s.mu.Lock()
+	switch s.kind {
+	case socketRouter:
+		if peer, ok := s.peers[string(frames[0])]; ok {
+			targets = append(targets, peer)
+		}
+		frames = frames[1:]
+	case socketPub:
+		for _, peer := range s.peers {
+			for _, subscription := range peer.subscriptions {
+				if bytes.HasPrefix(frames[0], subscription) {
+					targets = append(targets, peer)
+					break
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, peer := range targets {
+		if err := peer.conn.send(frames); err != nil {
+			peer.conn.close()
+		}
+	}
+	return nil
+}
+
+func (s *socket) close() error {
+	s.once.Do(func() {
+		close(s.closed)
+		s.listener.Close()
+		s.mu.Lock()
+		for _, peer := range s.peers {
+			peer.conn.close()
+		}
+		s.mu.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,368 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// AuditLogRelPath is the workspace-relative location of the audit log.
+// It lives in a hidden directory so it never shows up in the workspace tree.
+const AuditLogRelPath = ".idensyra/mcp-audit.jsonl"
+
+const (
+	// maxAuditArgLength truncates long string arguments; file contents are captured as diffs instead
+	maxAuditArgLength = 2000
+	// maxAuditSummaryLength truncates result summaries
+	maxAuditSummaryLength = 500
+	// maxAuditDiffLength truncates a single file diff
+	maxAuditDiffLength = 64 * 1024
+)
+
+// AuditEntry is one tool call in the audit log
+type AuditEntry struct {
+	Time       time.Time         `json:"time"`
+	Source     string            `json:"source"`
+	Tool       string            `json:"tool"`
+	Arguments  map[string]any    `json:"arguments,omitempty"`
+	Decisions  []AuditDecision   `json:"decisions,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+	IsError    bool              `json:"is_error,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Summary    string            `json:"summary,omitempty"`
+	Files      []AuditFileChange `json:"files,omitempty"`
+	// Checkpoint is the ID of the checkpoint taken before the call, if it changed files
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// AuditDecision records how a permission check was resolved
+type AuditDecision struct {
+	Operation Operation `json:"operation"`
+	Paths     []string  `json:"paths,omitempty"`
+	Level     string    `json:"level"`
+	// Decision is "allowed" (no prompt), "approved", "rejected" or "denied"
+	Decision string `json:"decision"`
+}
+
+// AuditFileChange describes how a tool call changed a workspace file
+type AuditFileChange struct {
+	Path string `json:"path"`
+	// Change is "created", "modified" or "deleted"
+	Change string `json:"change"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// AuditQuery filters entries returned by AuditLog.Read
+type AuditQuery struct {
+	// Limit keeps only the most recent entries (0 returns all)
+	Limit int
+	// Tool keeps only entries for this tool
+	Tool string
+	// Since keeps only entries at or after this time
+	Since time.Time
+}
+
+// AuditLog is an append-only JSON Lines log of MCP tool calls
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog creates an audit log writing to path
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// AuditLogPath returns the audit log location for a workspace root
+func AuditLogPath(workspaceRoot string) string {
+	return filepath.Join(workspaceRoot, filepath.FromSlash(AuditLogRelPath))
+}
+
+// Path returns the file the log is written to
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// Append writes an entry to the end of the log
+func (l *AuditLog) Append(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	if err := ignoreStateDir(filepath.Dir(l.path)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns log entries in chronological order. Malformed lines are skipped.
+func (l *AuditLog) Read(query AuditQuery) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if query.Tool != "" && entry.Tool != query.Tool {
+			continue
+		}
+		if !query.Since.IsZero() && entry.Time.Before(query.Since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+	}
+	return entries, nil
+}
+
+// AuditRecorder collects permission decisions made while handling one tool call,
+// and the file changes of tools whose paths MutatedPaths cannot name
+type AuditRecorder struct {
+	mu         sync.Mutex
+	decisions  []AuditDecision
+	files      []AuditFileChange
+	checkpoint string
+}
+
+type auditRecorderKey struct{}
+
+// WithAuditRecorder returns a context that records permission decisions into a new recorder
+func WithAuditRecorder(ctx context.Context) (context.Context, *AuditRecorder) {
+	rec := &AuditRecorder{}
+	return context.WithValue(ctx, auditRecorderKey{}, rec), rec
+}
+
+// Decisions returns the recorded permission decisions
+func (r *AuditRecorder) Decisions() []AuditDecision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AuditDecision(nil), r.decisions...)
+}
+
+// Files returns the file changes reported with RecordFileChanges
+func (r *AuditRecorder) Files() []AuditFileChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AuditFileChange(nil), r.files...)
+}
+
+// Checkpoint returns the checkpoint reported with RecordFileChanges
+func (r *AuditRecorder) Checkpoint() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.checkpoint
+}
+
+// RecordFileChanges reports the changes a tool made to files that only it knows,
+// such as the matches of replace_in_files, and the checkpoint that undoes them
+func RecordFileChanges(ctx context.Context, changes []AuditFileChange, checkpoint string) {
+	if ctx == nil {
+		return
+	}
+	rec, ok := ctx.Value(auditRecorderKey{}).(*AuditRecorder)
+	if !ok {
+		return
+	}
+	rec.mu.Lock()
+	for _, change := range changes {
+		change.Diff = truncateAuditDiff(change.Diff)
+		rec.files = append(rec.files, change)
+	}
+	if checkpoint != "" {
+		rec.checkpoint = checkpoint
+	}
+	rec.mu.Unlock()
+}
+
+func recordDecision(ctx context.Context, decision AuditDecision) {
+	if ctx == nil {
+		return
+	}
+	rec, ok := ctx.Value(auditRecorderKey{}).(*AuditRecorder)
+	if !ok {
+		return
+	}
+	rec.mu.Lock()
+	rec.decisions = append(rec.decisions, decision)
+	rec.mu.Unlock()
+}
+
+// AuditArguments copies tool arguments for logging, truncating long strings
+func AuditArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok && len(s) > maxAuditArgLength {
+			v = fmt.Sprintf("%s… (%d bytes)", truncateUTF8(s, maxAuditArgLength), len(s))
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// AuditSummary shortens a tool result for the log
+func AuditSummary(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) > maxAuditSummaryLength {
+		return truncateUTF8(text, maxAuditSummaryLength) + "…"
+	}
+	return text
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func truncateAuditDiff(diff string) string {
+	if len(diff) <= maxAuditDiffLength {
+		return diff
+	}
+	return truncateUTF8(diff, maxAuditDiffLength) + "\n… (diff truncated)\n"
+}
+
+// MutatedPaths returns the workspace files a tool call may change, so their
+// contents can be captured before and after the call
+func MutatedPaths(tool string, args map[string]any) []string {
+	str := func(key string) string {
+		s, _ := args[key].(string)
+		return s
+	}
+	var paths []string
+	switch tool {
+	case "write_file", "create_file", "delete_file", "modify_cell", "insert_cell", "set_cell_metadata":
+		paths = []string{str("path")}
+	case "rename_file":
+		paths = []string{str("old_path"), str("new_path")}
+	case "convert_ipynb_to_igonb":
+		paths = []string{str("igonb_path")}
+	case "import_file_to_workspace":
+		// The import writes the source file's name into target_dir; replace_in_files
+		// and restore_checkpoint report their files with RecordFileChanges
+		if source := str("source_path"); source != "" {
+			paths = []string{path.Join(filepath.ToSlash(str("target_dir")), filepath.Base(source))}
+		}
+	}
+	cleaned := paths[:0]
+	for _, p := range paths {
+		if p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+	return cleaned
+}
+
+// FileChanges compares file contents captured before and after a tool call.
+// A nil pointer means the file did not exist (or could not be read as text).
+func FileChanges(paths []string, before, after map[string]*string) []AuditFileChange {
+	var changes []AuditFileChange
+	for _, p := range paths {
+		b, a := before[p], after[p]
+		var change AuditFileChange
+		switch {
+		case b == nil && a == nil:
+			continue
+		case b == nil:
+			change = AuditFileChange{Path: p, Change: "created", Diff: UnifiedDiff(p, "", *a)}
+		case a == nil:
+			change = AuditFileChange{Path: p, Change: "deleted", Diff: UnifiedDiff(p, *b, "")}
+		case *a == *b:
+			continue
+		default:
+			change = AuditFileChange{Path: p, Change: "modified", Diff: UnifiedDiff(p, *b, *a)}
+		}
+		change.Diff = truncateAuditDiff(change.Diff)
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// AuditLogInputSchema is the input schema of the get_audit_log tool
+func AuditLogInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"limit": map[string]interface{}{"type": "number", "description": "Return only the most recent N entries (default 50, 0 for all)"},
+			"tool":  map[string]interface{}{"type": "string", "description": "Only return calls of this tool (optional)"},
+			"since": map[string]interface{}{"type": "string", "description": "Only return calls at or after this RFC 3339 time (optional)"},
+		},
+		"required": []string{},
+	}
+}
+
+// ParseAuditQuery converts get_audit_log arguments into a query
+func ParseAuditQuery(args map[string]interface{}) (AuditQuery, error) {
+	query := AuditQuery{Limit: 50}
+	if limit, ok := args["limit"].(float64); ok {
+		query.Limit = int(limit)
+	}
+	query.Tool, _ = args["tool"].(string)
+	if since, _ := args["since"].(string); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return query, fmt.Errorf("invalid since time: %w", err)
+		}
+		query.Since = t
+	}
+	return query, nil
+}
+
+// FormatAuditEntries renders entries for the get_audit_log tool
+func FormatAuditEntries(entries []AuditEntry) string {
+	if len(entries) == 0 {
+		return "Audit log is empty"
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error encoding audit log: %v", err)
+	}
+	return string(data)
+}
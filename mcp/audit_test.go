@@ -0,0 +1,154 @@
+package mcp
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+	diff := UnifiedDiff("x.txt", before, after)
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+	if diff != want {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if UnifiedDiff("x.txt", before, before) != "" {
+		t.Fatalf("identical contents should produce no diff")
+	}
+	if !strings.Contains(UnifiedDiff("new.txt", "", "hello\n"), "@@ -0,0 +1 @@\n+hello") {
+		t.Fatalf("unexpected creation diff: %s", UnifiedDiff("new.txt", "", "hello\n"))
+	}
+}
+
+func TestHandleRequestWritesAuditEntry(t *testing.T) {
+	files := map[string]string{"notes.txt": "one\n"}
+	read := func(path string) (string, error) {
+		content, ok := files[path]
+		if !ok {
+			return "", context.Canceled
+		}
+		return content, nil
+	}
+	write := func(path, content string) error { files[path] = content; return nil }
+
+	cfg := DefaultConfig()
+	cfg.PathRules = []PathRule{{Pattern: "locked/**", Permission: PermissionDeny}}
+	confirm := func(string, string) bool { return true }
+	s := NewServer(cfg, ".", confirm, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		read, write, nil, nil, nil, nil)
+	auditLog := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	s.SetAuditLog(auditLog)
+
+	ctx := context.Background()
+	if _, err := s.HandleRequest(ctx, &ToolRequest{Name: "write_file", Arguments: map[string]interface{}{"path": "notes.txt", "content": "two\n"}}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := s.HandleRequest(ctx, &ToolRequest{Name: "write_file", Arguments: map[string]interface{}{"path": "locked/a.txt", "content": "x"}}); err == nil {
+		t.Fatalf("expected locked write to be denied")
+	}
+
+	entries, err := auditLog.Read(AuditQuery{})
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	first := entries[0]
+	if first.Tool != "write_file" || first.IsError || len(first.Decisions) != 1 || first.Decisions[0].Decision != "approved" {
+		t.Fatalf("unexpected first entry: %+v", first)
+	}
+	if len(first.Files) != 1 || first.Files[0].Change != "modified" || !strings.Contains(first.Files[0].Diff, "-one\n+two") {
+		t.Fatalf("unexpected file changes: %+v", first.Files)
+	}
+	if second := entries[1]; !second.IsError || second.Decisions[0].Decision != "denied" {
+		t.Fatalf("unexpected second entry: %+v", second)
+	}
+
+	res, err := s.HandleRequest(ctx, &ToolRequest{Name: "get_audit_log", Arguments: map[string]interface{}{"tool": "write_file", "limit": float64(1)}})
+	if err != nil || !strings.Contains(res.Content[0].Text, "locked/a.txt") {
+		t.Fatalf("unexpected get_audit_log result: %v %+v", err, res)
+	}
+}
+
+func TestAuditTruncatesOnRuneBoundary(t *testing.T) {
+	long := "a" + strings.Repeat("é", maxAuditArgLength)
+	arg, _ := AuditArguments(map[string]any{"content": long})["content"].(string)
+	if !utf8.ValidString(arg) || !strings.HasPrefix(arg, "aé") || !strings.HasSuffix(arg, "… (4001 bytes)") {
+		t.Errorf("truncated argument is %q...", arg[:20])
+	}
+	summary := AuditSummary("a" + strings.Repeat("日本", maxAuditSummaryLength))
+	if !utf8.ValidString(summary) || len(summary) > maxAuditSummaryLength+len("…") {
+		t.Errorf("truncated summary is %d bytes, valid UTF-8: %v", len(summary), utf8.ValidString(summary))
+	}
+}
+
+func TestMutatedPaths(t *testing.T) {
+	for _, tc := range []struct {
+		tool string
+		args map[string]any
+		want []string
+	}{
+		{"write_file", map[string]any{"path": "a.txt"}, []string{"a.txt"}},
+		{"rename_file", map[string]any{"old_path": "a.txt", "new_path": "b.txt"}, []string{"a.txt", "b.txt"}},
+		{"import_file_to_workspace", map[string]any{"source_path": "/tmp/in/data.csv", "target_dir": "raw"}, []string{"raw/data.csv"}},
+		{"import_file_to_workspace", map[string]any{"source_path": "/tmp/in/data.csv"}, []string{"data.csv"}},
+		{"import_file_to_workspace", map[string]any{"target_dir": "raw"}, []string{}},
+		{"read_file", map[string]any{"path": "a.txt"}, []string{}},
+	} {
+		if got := MutatedPaths(tc.tool, tc.args); len(got) != len(tc.want) || len(got) > 0 && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("MutatedPaths(%s, %v) = %q, want %q", tc.tool, tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestAuditEntriesOfReplaceAndRestore(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	fs.WriteFile("a.txt", []byte("total\n"))
+	fs.WriteFile("b.txt", []byte("total\n"))
+	cfg := DefaultConfig()
+	cfg.FileEdit = PermissionAlways
+	s := NewServer(cfg, root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	s.SetCheckpointStore(NewCheckpointStore(CheckpointStorePath(root)), fs)
+	auditLog := NewAuditLog(AuditLogPath(root))
+	s.SetAuditLog(auditLog)
+
+	ctx := context.Background()
+	if _, err := s.HandleRequest(ctx, &ToolRequest{Name: "replace_in_files", Arguments: map[string]interface{}{"query": "total", "replacement": "sum"}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := auditLog.Read(AuditQuery{})
+	replace := entries[0]
+	if replace.Checkpoint == "" || len(replace.Files) != 2 || !strings.Contains(replace.Files[0].Diff, "-total\n+sum") {
+		t.Fatalf("unexpected replace entry: %+v", replace)
+	}
+
+	if _, err := s.HandleRequest(ctx, &ToolRequest{Name: "restore_checkpoint", Arguments: map[string]interface{}{"id": replace.Checkpoint}}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = auditLog.Read(AuditQuery{})
+	restore := entries[1]
+	if restore.Checkpoint == "" || restore.Checkpoint == replace.Checkpoint || len(restore.Files) != 2 || !strings.Contains(restore.Files[1].Diff, "-sum\n+total") {
+		t.Fatalf("unexpected restore entry: %+v", restore)
+	}
+}
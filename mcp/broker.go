@@ -0,0 +1,258 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UI request error codes
+const (
+	UIErrTimeout     = "timeout"
+	UIErrCancelled   = "cancelled"
+	UIErrUnavailable = "ui_unavailable"
+	UIErrFailed      = "ui_error"
+)
+
+// maxFinishedRequests bounds how many completed request IDs are remembered to reject repeats
+const maxFinishedRequests = 1000
+
+// Errors returned by Broker.Deliver
+var (
+	ErrUnknownRequest  = errors.New("unknown request id")
+	ErrDuplicateResult = errors.New("result already delivered")
+	ErrLateResult      = errors.New("request already timed out or was cancelled")
+)
+
+// UIError is a structured error reported for a UI request
+type UIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *UIError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// UIRequest is an action dispatched to the frontend
+type UIRequest struct {
+	ID      string         `json:"id"`
+	Event   string         `json:"event"`
+	Action  string         `json:"action"`
+	Payload map[string]any `json:"payload"`
+	Created time.Time      `json:"created"`
+}
+
+// UIResult is the frontend's answer to a UIRequest
+type UIResult struct {
+	RequestID string   `json:"request_id"`
+	Result    string   `json:"result,omitempty"`
+	Error     *UIError `json:"error,omitempty"`
+}
+
+// UIFallback handles a request in the backend when no frontend is attached
+type UIFallback func(ctx context.Context, req UIRequest) (string, error)
+
+type pendingUIRequest struct {
+	req    UIRequest
+	result chan UIResult
+}
+
+// Broker correlates actions dispatched to the frontend with their results.
+// Requests stay queued until answered, so a frontend that reloads replays them
+// when it attaches again; without any attached frontend, registered fallbacks
+// handle the request in the backend.
+type Broker struct {
+	mu        sync.Mutex
+	emit      func(UIRequest)
+	token     string
+	attached  bool
+	pending   map[string]*pendingUIRequest
+	order     []string
+	finished  map[string]error
+	doneOrder []string
+	fallbacks map[string]UIFallback
+}
+
+// NewBroker creates a broker that sends requests to the frontend with emit
+func NewBroker(emit func(UIRequest)) *Broker {
+	return &Broker{
+		emit:      emit,
+		token:     NewRequestID(),
+		pending:   make(map[string]*pendingUIRequest),
+		finished:  make(map[string]error),
+		fallbacks: make(map[string]UIFallback),
+	}
+}
+
+// NewRequestID returns a random 128-bit identifier
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Token is the secret the frontend must present when posting results
+func (b *Broker) Token() string {
+	return b.token
+}
+
+// CheckToken reports whether token matches the broker secret
+func (b *Broker) CheckToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) == 1
+}
+
+// SetFallback registers a backend-only handler for an action
+func (b *Broker) SetFallback(action string, fn UIFallback) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fallbacks[action] = fn
+}
+
+// Attach marks the frontend as attached and returns the requests it should (re)handle
+func (b *Broker) Attach() []UIRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attached = true
+	return b.pendingLocked()
+}
+
+// Detach marks the frontend as gone; queued requests wait for the next Attach
+func (b *Broker) Detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attached = false
+}
+
+// Attached reports whether a frontend has attached
+func (b *Broker) Attached() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.attached
+}
+
+// Pending returns the unanswered requests in dispatch order
+func (b *Broker) Pending() []UIRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pendingLocked()
+}
+
+func (b *Broker) pendingLocked() []UIRequest {
+	requests := make([]UIRequest, 0, len(b.order))
+	for _, id := range b.order {
+		if p, ok := b.pending[id]; ok {
+			requests = append(requests, p.req)
+		}
+	}
+	return requests
+}
+
+// Dispatch sends an action to the frontend and waits for its result until ctx is done.
+// The payload is sent with "action" and "request_id" set.
+func (b *Broker) Dispatch(ctx context.Context, event, action string, payload map[string]any) (string, error) {
+	req := UIRequest{
+		ID:      NewRequestID(),
+		Event:   event,
+		Action:  action,
+		Payload: make(map[string]any, len(payload)+2),
+		Created: time.Now(),
+	}
+	for k, v := range payload {
+		req.Payload[k] = v
+	}
+	req.Payload["action"] = action
+	req.Payload["request_id"] = req.ID
+
+	b.mu.Lock()
+	if !b.attached {
+		fallback := b.fallbacks[action]
+		if fallback != nil {
+			b.mu.Unlock()
+			return fallback(ctx, req)
+		}
+	}
+	p := &pendingUIRequest{req: req, result: make(chan UIResult, 1)}
+	b.pending[req.ID] = p
+	b.order = append(b.order, req.ID)
+	attached := b.attached
+	b.mu.Unlock()
+
+	// Without a frontend the request stays queued and is replayed on Attach
+	if attached && b.emit != nil {
+		b.emit(req)
+	}
+
+	select {
+	case res := <-p.result:
+		if res.Error != nil {
+			return res.Result, res.Error
+		}
+		return res.Result, nil
+	case <-ctx.Done():
+		b.finish(req.ID, ErrLateResult)
+		code := UIErrCancelled
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			code = UIErrTimeout
+		}
+		if !attached {
+			code = UIErrUnavailable
+		}
+		return "", &UIError{Code: code, Message: fmt.Sprintf("%s: %v", action, ctx.Err())}
+	}
+}
+
+// Deliver hands a frontend result to the waiting request.
+// Unknown, repeated and late results are rejected.
+func (b *Broker) Deliver(res UIResult) error {
+	b.mu.Lock()
+	p, ok := b.pending[res.RequestID]
+	if !ok {
+		err, done := b.finished[res.RequestID]
+		b.mu.Unlock()
+		if done {
+			return err
+		}
+		return ErrUnknownRequest
+	}
+	b.finishLocked(res.RequestID, ErrDuplicateResult)
+	b.mu.Unlock()
+
+	p.result <- res
+	return nil
+}
+
+func (b *Broker) finish(id string, reason error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[id]; ok {
+		b.finishLocked(id, reason)
+	}
+}
+
+// finishLocked removes a pending request and remembers why later results are rejected
+func (b *Broker) finishLocked(id string, reason error) {
+	delete(b.pending, id)
+	for i, pendingID := range b.order {
+		if pendingID == id {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+	b.finished[id] = reason
+	b.doneOrder = append(b.doneOrder, id)
+	if len(b.doneOrder) > maxFinishedRequests {
+		delete(b.finished, b.doneOrder[0])
+		b.doneOrder = b.doneOrder[1:]
+	}
+}
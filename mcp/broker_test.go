@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBrokerDeliverRejectsDuplicateAndLateResults(t *testing.T) {
+	emitted := make(chan UIRequest, 4)
+	b := NewBroker(func(req UIRequest) { emitted <- req })
+	b.Attach()
+
+	done := make(chan error, 1)
+	go func() {
+		res, err := b.Dispatch(context.Background(), "mcp:ui_action", "read_file", map[string]any{"path": "a.txt"})
+		if err == nil && res != "content" {
+			err = errors.New("unexpected result " + res)
+		}
+		done <- err
+	}()
+
+	req := <-emitted
+	if req.Payload["request_id"] != req.ID || req.Payload["action"] != "read_file" || len(req.ID) != 32 {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	if err := b.Deliver(UIResult{RequestID: req.ID, Result: "content"}); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Deliver(UIResult{RequestID: req.ID, Result: "again"}); !errors.Is(err, ErrDuplicateResult) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+	if err := b.Deliver(UIResult{RequestID: "nope"}); !errors.Is(err, ErrUnknownRequest) {
+		t.Fatalf("expected unknown error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := b.Dispatch(ctx, "mcp:ui_action", "list_files", nil)
+	var uiErr *UIError
+	if !errors.As(err, &uiErr) || uiErr.Code != UIErrTimeout {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	late := <-emitted
+	if err := b.Deliver(UIResult{RequestID: late.ID, Result: "late"}); !errors.Is(err, ErrLateResult) {
+		t.Fatalf("expected late error, got %v", err)
+	}
+}
+
+func TestBrokerStructuredErrors(t *testing.T) {
+	emitted := make(chan UIRequest, 1)
+	b := NewBroker(func(req UIRequest) { emitted <- req })
+	b.Attach()
+	go func() {
+		req := <-emitted
+		b.Deliver(UIResult{RequestID: req.ID, Error: &UIError{Code: UIErrFailed, Message: "file not found"}})
+	}()
+	_, err := b.Dispatch(context.Background(), "mcp:ui_action", "read_file", nil)
+	var uiErr *UIError
+	if !errors.As(err, &uiErr) || uiErr.Code != UIErrFailed || uiErr.Message != "file not found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBrokerQueuesUntilAttachAndFallsBack(t *testing.T) {
+	b := NewBroker(func(UIRequest) { t.Errorf("nothing should be emitted before Attach") })
+	b.SetFallback("read_file", func(ctx context.Context, req UIRequest) (string, error) {
+		return "from backend " + req.Payload["path"].(string), nil
+	})
+
+	res, err := b.Dispatch(context.Background(), "mcp:ui_action", "read_file", map[string]any{"path": "a.txt"})
+	if err != nil || res != "from backend a.txt" {
+		t.Fatalf("fallback not used: %q %v", res, err)
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		res, _ := b.Dispatch(context.Background(), "mcp:ui_action", "save_all_files", nil)
+		done <- res
+	}()
+	var queued []UIRequest
+	for i := 0; i < 100 && len(queued) == 0; i++ {
+		time.Sleep(time.Millisecond)
+		queued = b.Pending()
+	}
+	if len(queued) != 1 || queued[0].Action != "save_all_files" {
+		t.Fatalf("expected one queued request, got %+v", queued)
+	}
+
+	// A frontend attaching (or reloading) replays the queue
+	replay := b.Attach()
+	if len(replay) != 1 || replay[0].ID != queued[0].ID {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+	if err := b.Deliver(UIResult{RequestID: replay[0].ID, Result: "saved"}); err != nil {
+		t.Fatal(err)
+	}
+	if res := <-done; res != "saved" {
+		t.Fatalf("unexpected result %q", res)
+	}
+	b.Detach()
+	if res, err := b.Dispatch(context.Background(), "mcp:ui_action", "read_file", map[string]any{"path": "b.txt"}); err != nil || res != "from backend b.txt" {
+		t.Fatalf("fallback not used after Detach: %q %v", res, err)
+	}
+	if !b.CheckToken(b.Token()) || b.CheckToken("guess") {
+		t.Fatalf("token check failed")
+	}
+}
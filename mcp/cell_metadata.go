@@ -0,0 +1,213 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CellMetadataRequest selects a notebook cell by ID or index and the metadata to set on it
+type CellMetadataRequest struct {
+	Path     string
+	Index    int
+	ID       string
+	Metadata map[string]any
+}
+
+// SetCellMetadataInputSchema is the input schema of the set_cell_metadata tool
+func SetCellMetadataInputSchema() map[string]interface{} {
+	flag := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": []string{"boolean", "null"}, "description": description}
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path":       map[string]interface{}{"type": "string", "description": "Path to the .igonb notebook"},
+			"cell_id":    map[string]interface{}{"type": "string", "description": "ID of the cell; takes precedence over cell_index"},
+			"cell_index": map[string]interface{}{"type": "number", "description": "Index of the cell (0-based)"},
+			"metadata": map[string]interface{}{
+				"type":        "object",
+				"description": "Metadata keys to set; null removes a key. Other keys are stored as given",
+				"properties": map[string]interface{}{
+					"skip":        flag("Never run the cell"),
+					"frozen":      flag("Keep the output and do not re-run the cell"),
+					"hide_code":   flag("Hide the source in exports"),
+					"hide_output": flag("Hide the output in exports"),
+					"tags": map[string]interface{}{
+						"type":        []string{"array", "null"},
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Cell tags; skip-execution skips the cell and raises-exception lets a run continue after it fails",
+					},
+					"timeout": map[string]interface{}{
+						"type":        []string{"number", "string", "null"},
+						"description": "Time limit in seconds or as a duration such as \"1m30s\"",
+					},
+				},
+				"additionalProperties": true,
+			},
+		},
+		"required": []string{"path", "metadata"},
+	}
+}
+
+// ParseCellMetadataArgs reads and validates the arguments of set_cell_metadata
+func ParseCellMetadataArgs(args map[string]interface{}) (*CellMetadataRequest, error) {
+	req := &CellMetadataRequest{Index: -1}
+	req.Path, _ = args["path"].(string)
+	if strings.TrimSpace(req.Path) == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	req.ID, _ = args["cell_id"].(string)
+	if index, ok := args["cell_index"].(float64); ok {
+		req.Index = int(index)
+	}
+	if req.ID == "" && req.Index < 0 {
+		return nil, fmt.Errorf("cell_id or cell_index is required")
+	}
+	metadata, ok := args["metadata"].(map[string]interface{})
+	if !ok || len(metadata) == 0 {
+		return nil, fmt.Errorf("metadata must be a non-empty object")
+	}
+	if err := validateCellMetadata(metadata); err != nil {
+		return nil, err
+	}
+	req.Metadata = metadata
+	return req, nil
+}
+
+// validateCellMetadata checks the types of the metadata keys the executor honors
+func validateCellMetadata(metadata map[string]any) error {
+	for key, value := range metadata {
+		if value == nil {
+			continue
+		}
+		switch key {
+		case "skip", "frozen", "hide_code", "hide_output":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("cell metadata %s must be a boolean", key)
+			}
+		case "tags":
+			tags, ok := value.([]interface{})
+			for _, tag := range tags {
+				if _, isString := tag.(string); !isString {
+					ok = false
+				}
+			}
+			if !ok {
+				return fmt.Errorf("cell metadata %s must be a list of strings", key)
+			}
+		case "timeout":
+			valid := false
+			switch v := value.(type) {
+			case float64:
+				valid = v > 0
+			case string:
+				d, err := time.ParseDuration(strings.TrimSpace(v))
+				valid = err == nil && d > 0
+			}
+			if !valid {
+				return fmt.Errorf("cell metadata %s must be a positive number of seconds or a duration", key)
+			}
+		}
+	}
+	return nil
+}
+
+// cellSkipReason returns "frozen" or "skip" when a code cell must not be run
+func cellSkipReason(cell NotebookCell) string {
+	if cell.Language == "markdown" {
+		return ""
+	}
+	if frozen, _ := cell.Metadata["frozen"].(bool); frozen {
+		return "frozen"
+	}
+	if skip, _ := cell.Metadata["skip"].(bool); skip {
+		return "skip"
+	}
+	tags, _ := cell.Metadata["tags"].([]interface{})
+	for _, tag := range tags {
+		if tag == "skip-execution" {
+			return "skip"
+		}
+	}
+	return ""
+}
+
+// SetCellMetadata sets or removes metadata keys of a notebook cell
+func (no *NotebookOperations) SetCellMetadata(ctx context.Context, req *CellMetadataRequest) (*ToolResponse, error) {
+	target := req.ID
+	if target == "" {
+		target = fmt.Sprintf("%d", req.Index)
+	}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookModify,
+		Paths:   []string{req.Path},
+		Subject: "Notebook modify",
+		Title:   "Notebook Modify",
+		Details: fmt.Sprintf("Set metadata of cell %s in: %s", target, req.Path),
+	}); resp != nil {
+		return resp, err
+	}
+
+	notebook, err := no.ReadNotebook(ctx, req.Path)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error reading notebook: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	index := req.Index
+	if req.ID != "" {
+		index = -1
+		for i, cell := range notebook.Cells {
+			if cell.ID == req.ID {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 || index >= len(notebook.Cells) {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Cell not found: %s", target)}},
+			IsError: true,
+		}, fmt.Errorf("cell not found: %s", target)
+	}
+
+	cell := &notebook.Cells[index]
+	for key, value := range req.Metadata {
+		if value == nil {
+			delete(cell.Metadata, key)
+			continue
+		}
+		if cell.Metadata == nil {
+			cell.Metadata = make(map[string]any)
+		}
+		cell.Metadata[key] = value
+	}
+	if len(cell.Metadata) == 0 {
+		cell.Metadata = nil
+	}
+	if err := no.WriteNotebook(ctx, req.Path, notebook); err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error writing notebook: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: FormatCellMetadata(index, cell.ID, cell.Metadata)}},
+	}, nil
+}
+
+// FormatCellMetadata describes the metadata of a cell after it was set
+func FormatCellMetadata(index int, id string, metadata map[string]any) string {
+	if len(metadata) == 0 {
+		return fmt.Sprintf("Cell %d (%s) has no metadata", index, id)
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Updated metadata of cell %d (%s)", index, id)
+	}
+	return fmt.Sprintf("Metadata of cell %d (%s):\n%s", index, id, data)
+}
@@ -0,0 +1,560 @@
+package mcp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CheckpointsRelPath is the workspace-relative directory holding checkpoints
+const CheckpointsRelPath = ".idensyra/checkpoints"
+
+// StateDirName is the hidden workspace directory holding checkpoints, the audit
+// log and the Python environment
+const StateDirName = ".idensyra"
+
+// MaxCheckpoints is the number of checkpoints kept per workspace; older ones are pruned
+const MaxCheckpoints = 200
+
+// Checkpoint is a snapshot of the files touched by one MCP tool call, taken before the call ran
+type Checkpoint struct {
+	ID     string           `json:"id"`
+	Time   time.Time        `json:"time"`
+	Source string           `json:"source"`
+	Tool   string           `json:"tool"`
+	Files  []CheckpointFile `json:"files"`
+}
+
+// CheckpointFile records the state of one file in a checkpoint
+type CheckpointFile struct {
+	Path string `json:"path"`
+	// Hash is the SHA-256 of the content; empty when the file did not exist
+	Hash string `json:"hash,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// CheckpointFS gives checkpoints access to workspace files
+type CheckpointFS interface {
+	// ReadFile returns an error matching os.ErrNotExist for missing files
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	DeleteFile(path string) error
+}
+
+// CheckpointStore keeps content-addressed checkpoints: file contents are stored
+// once under objects/ by hash and each checkpoint is a small JSON manifest
+type CheckpointStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewCheckpointStore creates a store rooted at dir
+func NewCheckpointStore(dir string) *CheckpointStore {
+	return &CheckpointStore{dir: dir}
+}
+
+// CheckpointStorePath returns the checkpoint directory for a workspace root
+func CheckpointStorePath(workspaceRoot string) string {
+	return filepath.Join(workspaceRoot, filepath.FromSlash(CheckpointsRelPath))
+}
+
+// Create snapshots paths through fs. Files that cannot be read for reasons other
+// than not existing abort the checkpoint, so a restore never deletes them by mistake.
+func (s *CheckpointStore) Create(source, tool string, paths []string, fs CheckpointFS) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	cp := &Checkpoint{Time: now, Source: source, Tool: tool}
+	seen := make(map[string]bool, len(paths))
+	idHash := sha256.New()
+	fmt.Fprintf(idHash, "%d", now.UnixNano())
+	for _, p := range paths {
+		cleanPath, err := safeCleanRelativePath(p)
+		if err != nil || seen[cleanPath] {
+			continue
+		}
+		seen[cleanPath] = true
+
+		file := CheckpointFile{Path: cleanPath}
+		data, err := fs.ReadFile(cleanPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", cleanPath, err)
+		default:
+			hash, err := s.putObject(data)
+			if err != nil {
+				return nil, err
+			}
+			file.Hash = hash
+			file.Size = int64(len(data))
+		}
+		fmt.Fprintf(idHash, "\x00%s\x00%s", file.Path, file.Hash)
+		cp.Files = append(cp.Files, file)
+	}
+	if len(cp.Files) == 0 {
+		return nil, fmt.Errorf("no files to checkpoint")
+	}
+	cp.ID = now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(idHash.Sum(nil))[:8]
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	if err := ignoreStateDir(filepath.Dir(s.dir)); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.manifestPath(cp.ID), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := s.pruneLocked(MaxCheckpoints); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// List returns all checkpoints, newest first
+func (s *CheckpointStore) List() ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+// Get loads a checkpoint by ID
+func (s *CheckpointStore) Get(id string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(id)
+}
+
+// Delete removes a checkpoint and any contents no other checkpoint references
+func (s *CheckpointStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.getLocked(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.manifestPath(id)); err != nil {
+		return err
+	}
+	return s.collectGarbageLocked()
+}
+
+// Diff compares a checkpoint with the current files. Diffs go from the
+// checkpoint to the current state; an empty result means nothing changed.
+// Only files listed in paths are compared when paths is not empty.
+func (s *CheckpointStore) Diff(id string, paths []string, fs CheckpointFS) ([]AuditFileChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, err := s.getLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filterCheckpointFiles(cp, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AuditFileChange
+	for _, file := range files {
+		var before []byte
+		if file.Hash != "" {
+			if before, err = s.readObject(file.Hash); err != nil {
+				return nil, err
+			}
+		}
+		current, err := fs.ReadFile(file.Path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+
+		var change AuditFileChange
+		switch {
+		case file.Hash == "" && !exists:
+			continue
+		case file.Hash == "":
+			change = AuditFileChange{Path: file.Path, Change: "created"}
+		case !exists:
+			change = AuditFileChange{Path: file.Path, Change: "deleted"}
+		case bytes.Equal(before, current):
+			continue
+		default:
+			change = AuditFileChange{Path: file.Path, Change: "modified"}
+		}
+		if isTextContent(before) && isTextContent(current) {
+			change.Diff = UnifiedDiff(file.Path, string(before), string(current))
+		} else {
+			change.Diff = fmt.Sprintf("Binary file %s changed (%d -> %d bytes)\n", file.Path, len(before), len(current))
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Restore writes the checkpointed contents back through fs, deleting files that
+// did not exist at checkpoint time. The current state is checkpointed first so the
+// restore can itself be undone; that checkpoint is returned.
+func (s *CheckpointStore) Restore(source, id string, paths []string, fs CheckpointFS) (*Checkpoint, error) {
+	cp, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filterCheckpointFiles(cp, paths)
+	if err != nil {
+		return nil, err
+	}
+	restorePaths := make([]string, 0, len(files))
+	for _, file := range files {
+		restorePaths = append(restorePaths, file.Path)
+	}
+	undo, err := s.Create(source, "restore_checkpoint", restorePaths, fs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkpoint current state: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, file := range files {
+		if file.Hash == "" {
+			if err := fs.DeleteFile(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return undo, fmt.Errorf("failed to delete %s: %w", file.Path, err)
+			}
+			continue
+		}
+		data, err := s.readObject(file.Hash)
+		if err != nil {
+			return undo, err
+		}
+		if err := fs.WriteFile(file.Path, data); err != nil {
+			return undo, fmt.Errorf("failed to restore %s: %w", file.Path, err)
+		}
+	}
+	return undo, nil
+}
+
+// RestorePaths returns the files a restore of paths (all files when empty)
+// writes back and those it deletes because they did not exist at checkpoint
+// time, so each group can be authorized with its own operation
+func (c *Checkpoint) RestorePaths(paths []string) (edited, deleted []string, err error) {
+	files, err := filterCheckpointFiles(c, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		if file.Hash == "" {
+			deleted = append(deleted, file.Path)
+		} else {
+			edited = append(edited, file.Path)
+		}
+	}
+	return edited, deleted, nil
+}
+
+func filterCheckpointFiles(cp *Checkpoint, paths []string) ([]CheckpointFile, error) {
+	if len(paths) == 0 {
+		return cp.Files, nil
+	}
+	var files []CheckpointFile
+	for _, p := range paths {
+		cleanPath, err := safeCleanRelativePath(p)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, file := range cp.Files {
+			if file.Path == cleanPath {
+				files = append(files, file)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("file not in checkpoint %s: %s", cp.ID, cleanPath)
+		}
+	}
+	return files, nil
+}
+
+// ignoreStateDir keeps dir out of git when it is the workspace state directory,
+// so git_status and git_stage never pick up checkpoints or the audit log
+func ignoreStateDir(dir string) error {
+	if filepath.Base(dir) != StateDirName {
+		return nil
+	}
+	gitignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(gitignore); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(gitignore, []byte("*\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s/.gitignore: %w", StateDirName, err)
+	}
+	return nil
+}
+
+func (s *CheckpointStore) manifestPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *CheckpointStore) objectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash[2:])
+}
+
+func (s *CheckpointStore) putObject(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	// Write through a temp file so a crash never leaves a truncated object behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return hash, nil
+}
+
+func (s *CheckpointStore) readObject(hash string) ([]byte, error) {
+	if len(hash) < 3 {
+		return nil, fmt.Errorf("invalid object hash: %s", hash)
+	}
+	data, err := os.ReadFile(s.objectPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint content missing: %w", err)
+	}
+	return data, nil
+}
+
+func (s *CheckpointStore) getLocked(id string) (*Checkpoint, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, fmt.Errorf("invalid checkpoint id: %q", id)
+	}
+	data, err := os.ReadFile(s.manifestPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("checkpoint not found: %s", id)
+		}
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", id, err)
+	}
+	return &cp, nil
+}
+
+func (s *CheckpointStore) listLocked() ([]Checkpoint, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Checkpoint{}, nil
+		}
+		return nil, err
+	}
+	checkpoints := []Checkpoint{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		cp, err := s.getLocked(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		checkpoints = append(checkpoints, *cp)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Time.After(checkpoints[j].Time)
+	})
+	return checkpoints, nil
+}
+
+// pruneLocked drops the oldest checkpoints beyond keep and their unreferenced contents
+func (s *CheckpointStore) pruneLocked(keep int) error {
+	checkpoints, err := s.listLocked()
+	if err != nil || len(checkpoints) <= keep {
+		return err
+	}
+	for _, cp := range checkpoints[keep:] {
+		if err := os.Remove(s.manifestPath(cp.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.collectGarbageLocked()
+}
+
+func (s *CheckpointStore) collectGarbageLocked() error {
+	checkpoints, err := s.listLocked()
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	for _, cp := range checkpoints {
+		for _, file := range cp.Files {
+			if file.Hash != "" {
+				referenced[file.Hash] = true
+			}
+		}
+	}
+	objectsDir := filepath.Join(s.dir, "objects")
+	return filepath.WalkDir(objectsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		hash := filepath.Base(filepath.Dir(path)) + d.Name()
+		if !referenced[hash] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+func isTextContent(data []byte) bool {
+	return !bytes.ContainsRune(data, 0) && utf8.Valid(data)
+}
+
+// DirCheckpointFS accesses workspace files directly on disk
+type DirCheckpointFS struct {
+	Root string
+}
+
+func (d DirCheckpointFS) resolve(path string) (string, error) {
+	cleanPath, err := safeCleanRelativePath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(cleanPath)), nil
+}
+
+// ReadFile reads a file relative to the workspace root
+func (d DirCheckpointFS) ReadFile(path string) ([]byte, error) {
+	full, err := d.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(full)
+}
+
+// WriteFile writes a file relative to the workspace root, creating parent directories
+func (d DirCheckpointFS) WriteFile(path string, data []byte) error {
+	full, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(full, data, 0644)
+}
+
+// DeleteFile removes a file relative to the workspace root
+func (d DirCheckpointFS) DeleteFile(path string) error {
+	full, err := d.resolve(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(full)
+}
+
+// ListCheckpointsInputSchema is the input schema of the list_checkpoints tool
+func ListCheckpointsInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"limit": map[string]interface{}{"type": "number", "description": "Return only the most recent N checkpoints (default 20, 0 for all)"},
+		},
+		"required": []string{},
+	}
+}
+
+// CheckpointInputSchema is the input schema of the diff_checkpoint and restore_checkpoint tools
+func CheckpointInputSchema(action string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":    map[string]interface{}{"type": "string", "description": "Checkpoint ID from list_checkpoints"},
+			"paths": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Only " + action + " these files (optional, default all files in the checkpoint)"},
+		},
+		"required": []string{"id"},
+	}
+}
+
+// ParseCheckpointArgs reads the id and optional paths arguments of checkpoint tools
+func ParseCheckpointArgs(args map[string]interface{}) (string, []string, error) {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return "", nil, fmt.Errorf("id is required")
+	}
+	var paths []string
+	if raw, ok := args["paths"].([]interface{}); ok {
+		for _, p := range raw {
+			if s, ok := p.(string); ok && s != "" {
+				paths = append(paths, s)
+			}
+		}
+	}
+	return id, paths, nil
+}
+
+// FormatCheckpoints renders checkpoints for the list_checkpoints tool
+func FormatCheckpoints(checkpoints []Checkpoint, limit int) string {
+	if len(checkpoints) == 0 {
+		return "No checkpoints"
+	}
+	if limit > 0 && len(checkpoints) > limit {
+		checkpoints = checkpoints[:limit]
+	}
+	var sb strings.Builder
+	for _, cp := range checkpoints {
+		fmt.Fprintf(&sb, "%s  %s  %s (%s)\n", cp.ID, cp.Time.Format(time.RFC3339), cp.Tool, cp.Source)
+		for _, file := range cp.Files {
+			if file.Hash == "" {
+				fmt.Fprintf(&sb, "  %s (did not exist)\n", file.Path)
+			} else {
+				fmt.Fprintf(&sb, "  %s (%d bytes)\n", file.Path, file.Size)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// FormatCheckpointDiff renders the changes since a checkpoint for the diff_checkpoint tool
+func FormatCheckpointDiff(id string, changes []AuditFileChange) string {
+	if len(changes) == 0 {
+		return fmt.Sprintf("No changes since checkpoint %s", id)
+	}
+	var sb strings.Builder
+	for _, change := range changes {
+		fmt.Fprintf(&sb, "%s: %s\n%s", change.Change, change.Path, change.Diff)
+	}
+	return sb.String()
+}
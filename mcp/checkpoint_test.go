@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointCreateDiffRestore(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	store := NewCheckpointStore(filepath.Join(root, ".idensyra", "checkpoints"))
+
+	if err := fs.WriteFile("a.txt", []byte("one\n")); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Create("test", "write_file", []string{"a.txt", "new.txt", "a.txt"}, fs)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(cp.Files) != 2 || cp.Files[0].Hash == "" || cp.Files[1].Hash != "" {
+		t.Fatalf("unexpected checkpoint files: %+v", cp.Files)
+	}
+
+	if changes, err := store.Diff(cp.ID, nil, fs); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v (%v)", changes, err)
+	}
+
+	fs.WriteFile("a.txt", []byte("two\n"))
+	fs.WriteFile("new.txt", []byte("created\n"))
+	changes, err := store.Diff(cp.ID, nil, fs)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Change != "modified" || changes[1].Change != "created" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if !strings.Contains(changes[0].Diff, "-one\n+two") {
+		t.Fatalf("unexpected diff: %s", changes[0].Diff)
+	}
+
+	undo, err := store.Restore("test", cp.ID, nil, fs)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := fs.ReadFile("a.txt"); string(data) != "one\n" {
+		t.Fatalf("a.txt not restored: %q", data)
+	}
+	if _, err := fs.ReadFile("new.txt"); !os.IsNotExist(err) {
+		t.Fatalf("new.txt should have been deleted, got %v", err)
+	}
+
+	// The restore itself can be undone
+	if _, err := store.Restore("test", undo.ID, []string{"new.txt"}, fs); err != nil {
+		t.Fatalf("Restore undo failed: %v", err)
+	}
+	if data, _ := fs.ReadFile("new.txt"); string(data) != "created\n" {
+		t.Fatalf("new.txt not restored: %q", data)
+	}
+
+	list, err := store.List()
+	if err != nil || len(list) != 3 {
+		t.Fatalf("expected 3 checkpoints, got %d (%v)", len(list), err)
+	}
+	if _, err := store.Restore("test", cp.ID, []string{"other.txt"}, fs); err == nil {
+		t.Fatalf("expected error for a file outside the checkpoint")
+	}
+}
+
+func TestCheckpointDeleteCollectsObjects(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	dir := filepath.Join(root, "checkpoints")
+	store := NewCheckpointStore(dir)
+
+	fs.WriteFile("a.txt", []byte("shared"))
+	first, _ := store.Create("test", "write_file", []string{"a.txt"}, fs)
+	second, _ := store.Create("test", "write_file", []string{"a.txt"}, fs)
+	if first == nil || second == nil || first.ID == second.ID {
+		t.Fatalf("expected two distinct checkpoints")
+	}
+
+	object := store.objectPath(first.Files[0].Hash)
+	if err := store.Delete(first.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := os.Stat(object); err != nil {
+		t.Fatalf("shared object removed while still referenced: %v", err)
+	}
+	if err := store.Delete(second.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := os.Stat(object); !os.IsNotExist(err) {
+		t.Fatalf("unreferenced object kept: %v", err)
+	}
+}
+
+func TestHandleRequestTakesCheckpoint(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	fs.WriteFile("notes.txt", []byte("one\n"))
+	read := func(path string) (string, error) {
+		data, err := fs.ReadFile(path)
+		return string(data), err
+	}
+	write := func(path, content string) error { return fs.WriteFile(path, []byte(content)) }
+
+	cfg := DefaultConfig()
+	cfg.FileEdit = PermissionAlways
+	s := NewServer(cfg, root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		read, write, nil, nil, nil, nil)
+	store := NewCheckpointStore(CheckpointStorePath(root))
+	s.SetCheckpointStore(store, fs)
+	auditLog := NewAuditLog(AuditLogPath(root))
+	s.SetAuditLog(auditLog)
+
+	ctx := context.Background()
+	write1 := &ToolRequest{Name: "write_file", Arguments: map[string]interface{}{"path": "notes.txt", "content": "two\n"}}
+	if _, err := s.HandleRequest(ctx, write1); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+	// Writing identical content changes nothing, so no checkpoint is kept
+	if _, err := s.HandleRequest(ctx, write1); err != nil {
+		t.Fatalf("write_file failed: %v", err)
+	}
+
+	list, _ := store.List()
+	if len(list) != 1 {
+		t.Fatalf("expected 1 checkpoint, got %d", len(list))
+	}
+	entries, _ := auditLog.Read(AuditQuery{})
+	if len(entries) != 2 || entries[0].Checkpoint != list[0].ID || entries[1].Checkpoint != "" {
+		t.Fatalf("unexpected audit checkpoints: %+v", entries)
+	}
+
+	resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "restore_checkpoint", Arguments: map[string]interface{}{"id": list[0].ID}})
+	if err != nil {
+		t.Fatalf("restore_checkpoint failed: %v (%+v)", err, resp)
+	}
+	if data, _ := fs.ReadFile("notes.txt"); string(data) != "one\n" {
+		t.Fatalf("notes.txt not restored: %q", data)
+	}
+}
+
+func TestRestoreCheckpointNeedsDeletePermission(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	fs.WriteFile("kept.txt", []byte("one\n"))
+	store := NewCheckpointStore(CheckpointStorePath(root))
+	cp, err := store.Create("test", "write_file", []string{"kept.txt", "created.txt"}, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.WriteFile("kept.txt", []byte("two\n"))
+	fs.WriteFile("created.txt", []byte("new\n"))
+
+	edited, deleted, err := cp.RestorePaths(nil)
+	if err != nil || len(edited) != 1 || edited[0] != "kept.txt" || len(deleted) != 1 || deleted[0] != "created.txt" {
+		t.Fatalf("RestorePaths = %v, %v, %v", edited, deleted, err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.FileEdit = PermissionAlways
+	cfg.FileDelete = PermissionDeny
+	s := NewServer(cfg, root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil)
+	s.SetCheckpointStore(store, fs)
+
+	ctx := context.Background()
+	resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "restore_checkpoint", Arguments: map[string]interface{}{"id": cp.ID}})
+	if err == nil || resp == nil || !resp.IsError {
+		t.Fatalf("expected the restore to be denied, got %+v (%v)", resp, err)
+	}
+	if data, _ := fs.ReadFile("created.txt"); string(data) != "new\n" {
+		t.Fatalf("created.txt changed by a denied restore: %q", data)
+	}
+	if data, _ := fs.ReadFile("kept.txt"); string(data) != "two\n" {
+		t.Fatalf("kept.txt changed by a denied restore: %q", data)
+	}
+
+	// Restoring only the edited file needs no delete permission
+	if _, err := s.HandleRequest(ctx, &ToolRequest{Name: "restore_checkpoint", Arguments: map[string]interface{}{"id": cp.ID, "paths": []interface{}{"kept.txt"}}}); err != nil {
+		t.Fatalf("restore of kept.txt failed: %v", err)
+	}
+	if data, _ := fs.ReadFile("kept.txt"); string(data) != "one\n" {
+		t.Fatalf("kept.txt not restored: %q", data)
+	}
+}
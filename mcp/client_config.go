@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Transport names supported by the integrated HTTP server
+const (
+	// TransportSSE serves the legacy HTTP+SSE transport
+	TransportSSE = "sse"
+	// TransportStreamableHTTP serves the Streamable HTTP transport
+	TransportStreamableHTTP = "streamable-http"
+)
+
+// DefaultHTTPPort is the port used by the integrated HTTP server unless configured otherwise
+const DefaultHTTPPort = 14320
+
+// NormalizeTransport returns the canonical transport name, or an error for unknown values.
+// An empty value selects SSE to keep existing client configurations working.
+func NormalizeTransport(transport string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(transport)) {
+	case "", TransportSSE:
+		return TransportSSE, nil
+	case TransportStreamableHTTP, "streamable", "http":
+		return TransportStreamableHTTP, nil
+	default:
+		return "", fmt.Errorf("unsupported transport: %s", transport)
+	}
+}
+
+// EndpointPath returns the HTTP path clients should connect to for the given transport.
+// SSE stays on the root path so configurations written for earlier versions keep working.
+func EndpointPath(transport string) string {
+	if transport == TransportStreamableHTTP {
+		return "/mcp"
+	}
+	return "/"
+}
+
+// ClientConfigSnippet is a ready-to-paste client configuration
+type ClientConfigSnippet struct {
+	Client string `json:"client"`
+	File   string `json:"file"`
+	Config string `json:"config"`
+}
+
+// ClientConfigSnippets generates client configuration snippets for an endpoint URL.
+// Clients without native HTTP support are bridged through mcp-remote.
+func ClientConfigSnippets(url string, transport string) []ClientConfigSnippet {
+	vscodeType := "sse"
+	remoteTransport := "sse-only"
+	if transport == TransportStreamableHTTP {
+		vscodeType = "http"
+		remoteTransport = "http-only"
+	}
+
+	snippets := []ClientConfigSnippet{
+		{
+			Client: "Claude Desktop",
+			File:   "claude_desktop_config.json",
+			Config: marshalSnippet(map[string]any{
+				"mcpServers": map[string]any{
+					"idensyra": map[string]any{
+						"command": "npx",
+						"args":    []string{"-y", "mcp-remote", url, "--transport", remoteTransport},
+					},
+				},
+			}),
+		},
+		{
+			Client: "VS Code",
+			File:   ".vscode/mcp.json",
+			Config: marshalSnippet(map[string]any{
+				"servers": map[string]any{
+					"idensyra": map[string]any{
+						"type": vscodeType,
+						"url":  url,
+					},
+				},
+			}),
+		},
+		{
+			Client: "Cursor",
+			File:   "~/.cursor/mcp.json",
+			Config: marshalSnippet(map[string]any{
+				"mcpServers": map[string]any{
+					"idensyra": map[string]any{
+						"url": url,
+					},
+				},
+			}),
+		},
+	}
+	return snippets
+}
+
+func marshalSnippet(v any) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeTransport(t *testing.T) {
+	cases := map[string]string{
+		"":                "sse",
+		"SSE":             "sse",
+		"streamable-http": "streamable-http",
+		"http":            "streamable-http",
+	}
+	for in, want := range cases {
+		got, err := NormalizeTransport(in)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("NormalizeTransport(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := NormalizeTransport("websocket"); err == nil {
+		t.Fatalf("expected error for unsupported transport")
+	}
+}
+
+func TestClientConfigSnippetsAreValidJSON(t *testing.T) {
+	url := "http://127.0.0.1:15000/mcp"
+	snippets := ClientConfigSnippets(url, TransportStreamableHTTP)
+	if len(snippets) == 0 {
+		t.Fatalf("expected snippets")
+	}
+	for _, s := range snippets {
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(s.Config), &parsed); err != nil {
+			t.Fatalf("%s snippet is not valid JSON: %v", s.Client, err)
+		}
+	}
+
+	var vscode struct {
+		Servers map[string]struct {
+			Type string `json:"type"`
+			URL  string `json:"url"`
+		} `json:"servers"`
+	}
+	for _, s := range snippets {
+		if s.Client == "VS Code" {
+			_ = json.Unmarshal([]byte(s.Config), &vscode)
+		}
+	}
+	entry := vscode.Servers["idensyra"]
+	if entry.Type != "http" || entry.URL != url {
+		t.Fatalf("unexpected VS Code entry: %+v", entry)
+	}
+}
@@ -45,20 +45,14 @@ func NewCodeExecution(
 
 // ExecuteGoFile executes a Go file using the Yaegi interpreter
 func (ce *CodeExecution) ExecuteGoFile(ctx context.Context, path string) (*ToolResponse, error) {
-	if ce.config.ExecuteGo == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Go execution permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if ce.config.ExecuteGo == PermissionAsk && ce.confirmFunc != nil {
-		if !ce.confirmFunc("Execute Go", fmt.Sprintf("Execute Go file: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Go execution cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, ce.config, ce.confirmFunc, permissionPrompt{
+		Op:      OpExecuteGo,
+		Paths:   []string{path},
+		Subject: "Go execution",
+		Title:   "Execute Go",
+		Details: fmt.Sprintf("Execute Go file: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Require read backend to fetch file content; do not depend on workspace being created
@@ -109,20 +103,13 @@ func (ce *CodeExecution) ExecuteGoFile(ctx context.Context, path string) (*ToolR
 
 // ExecuteGoCode executes Go code directly
 func (ce *CodeExecution) ExecuteGoCode(ctx context.Context, code string) (*ToolResponse, error) {
-	if ce.config.ExecuteGo == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Go execution permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if ce.config.ExecuteGo == PermissionAsk && ce.confirmFunc != nil {
-		if !ce.confirmFunc("Execute Go", "Execute Go code") {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Go execution cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, ce.config, ce.confirmFunc, permissionPrompt{
+		Op:      OpExecuteGo,
+		Subject: "Go execution",
+		Title:   "Execute Go",
+		Details: "Execute Go code",
+	}); resp != nil {
+		return resp, err
 	}
 
 	if ce.executeGoFunc == nil {
@@ -141,20 +128,14 @@ func (ce *CodeExecution) ExecuteGoCode(ctx context.Context, code string) (*ToolR
 
 // ExecutePythonFile executes a Python file
 func (ce *CodeExecution) ExecutePythonFile(ctx context.Context, path string) (*ToolResponse, error) {
-	if ce.config.ExecutePython == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Python execution permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if ce.config.ExecutePython == PermissionAsk && ce.confirmFunc != nil {
-		if !ce.confirmFunc("Execute Python", fmt.Sprintf("Execute Python file: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Python execution cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, ce.config, ce.confirmFunc, permissionPrompt{
+		Op:      OpExecutePython,
+		Paths:   []string{path},
+		Subject: "Python execution",
+		Title:   "Execute Python",
+		Details: fmt.Sprintf("Execute Python file: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Prefer content-based execution via callback (does not require workspace)
@@ -217,20 +198,13 @@ func (ce *CodeExecution) ExecutePythonFile(ctx context.Context, path string) (*T
 
 // ExecutePythonCode executes Python code directly
 func (ce *CodeExecution) ExecutePythonCode(ctx context.Context, code string) (*ToolResponse, error) {
-	if ce.config.ExecutePython == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Python execution permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if ce.config.ExecutePython == PermissionAsk && ce.confirmFunc != nil {
-		if !ce.confirmFunc("Execute Python", "Execute Python code") {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Python execution cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, ce.config, ce.confirmFunc, permissionPrompt{
+		Op:      OpExecutePython,
+		Subject: "Python execution",
+		Title:   "Execute Python",
+		Details: "Execute Python code",
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Prefer content-based executor callback
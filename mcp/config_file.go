@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultConfigFileName is the name of the per-user MCP permission file
+const DefaultConfigFileName = "mcp_config.yaml"
+
+// DefaultConfigPath returns the per-user permission file shared by the GUI and cmd/mcp-server
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "idensyra", DefaultConfigFileName), nil
+}
+
+// LoadConfigFile reads a YAML or JSON permission configuration.
+// The format is chosen by extension (.yaml/.yml, otherwise JSON); levels that
+// are not set in the file keep their DefaultConfig value.
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(data, isYAMLPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes a YAML or JSON permission configuration
+func ParseConfig(data []byte, isYAML bool) (*Config, error) {
+	if isYAML {
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml: %w", err)
+		}
+		data = converted
+	}
+
+	config := DefaultConfig()
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && !bytes.Equal(data, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SaveConfigFile writes the configuration as YAML or JSON depending on the extension
+func SaveConfigFile(path string, config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	data, err := MarshalConfig(config, isYAMLPath(path))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// MarshalConfig encodes the configuration as YAML or indented JSON
+func MarshalConfig(config *Config, asYAML bool) ([]byte, error) {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if !asYAML {
+		return data, nil
+	}
+	return yaml.JSONToYAML(data)
+}
+
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
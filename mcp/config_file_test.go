@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfigYAML(t *testing.T) {
+	data := []byte(`
+file_edit: always
+execute_python: deny
+path_rules:
+  - pattern: data/raw/**
+    operations: [file_edit, file_delete]
+    permission: deny
+`)
+	cfg, err := ParseConfig(data, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FileEdit != PermissionAlways || cfg.ExecutePython != PermissionDeny {
+		t.Fatalf("levels not applied: %+v", cfg)
+	}
+	if cfg.FileDelete != PermissionAsk {
+		t.Fatalf("unset levels should keep defaults, got %v", cfg.FileDelete)
+	}
+	if len(cfg.PathRules) != 1 || cfg.PathRules[0].Permission != PermissionDeny {
+		t.Fatalf("unexpected rules: %+v", cfg.PathRules)
+	}
+}
+
+func TestParseConfigRejectsInvalidValues(t *testing.T) {
+	bad := []string{
+		`{"file_edit": "sometimes"}`,
+		`{"file_editt": "ask"}`,
+		`{"path_rules": [{"pattern": "a/**", "operations": ["fly"], "permission": "deny"}]}`,
+		`{"path_rules": [{"pattern": "", "permission": "deny"}]}`,
+	}
+	for _, input := range bad {
+		if _, err := ParseConfig([]byte(input), false); err == nil {
+			t.Errorf("expected error for %s", input)
+		}
+	}
+}
+
+func TestSaveConfigFileRoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ExecuteGo = PermissionDeny
+	cfg.PathRules = []PathRule{{Pattern: "scratch/**", Permission: PermissionAlways}}
+
+	for _, name := range []string{"mcp.yaml", "mcp.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := SaveConfigFile(path, cfg); err != nil {
+			t.Fatalf("save %s: %v", name, err)
+		}
+		loaded, err := LoadConfigFile(path)
+		if err != nil {
+			data, _ := os.ReadFile(path)
+			t.Fatalf("load %s: %v\n%s", name, err, data)
+		}
+		if loaded.ExecuteGo != PermissionDeny || len(loaded.PathRules) != 1 || loaded.PathRules[0].Pattern != "scratch/**" {
+			t.Fatalf("round trip mismatch for %s: %+v", name, loaded)
+		}
+	}
+}
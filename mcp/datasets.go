@@ -0,0 +1,748 @@
+package mcp
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Dataset formats
+const (
+	DatasetCSV     = "csv"
+	DatasetTSV     = "tsv"
+	DatasetExcel   = "excel"
+	DatasetParquet = "parquet"
+	DatasetTable   = "datatable"
+	DatasetList    = "datalist"
+)
+
+// Column types reported by describe_dataset
+const (
+	ColumnNumber   = "number"
+	ColumnBoolean  = "boolean"
+	ColumnDatetime = "datetime"
+	ColumnString   = "string"
+	ColumnEmpty    = "empty"
+	ColumnMixed    = "mixed"
+)
+
+const (
+	// DefaultDatasetPageSize is the number of rows returned when no limit is given
+	DefaultDatasetPageSize = 50
+	// MaxDatasetPageSize bounds the rows returned by a single page
+	MaxDatasetPageSize = 500
+	// MaxDatasetColumns bounds the columns described or returned
+	MaxDatasetColumns = 200
+	// maxDatasetCellLength bounds string cells in pages
+	maxDatasetCellLength = 200
+	// maxDistinctValues bounds the distinct values counted per column
+	maxDistinctValues = 1000
+	// maxColumnExamples is the number of example values reported per column
+	maxColumnExamples = 3
+	// datasetCancelCheck is how many rows are scanned between cancellation checks
+	datasetCancelCheck = 1024
+)
+
+// nullStrings are text values treated as missing
+var nullStrings = map[string]bool{"": true, "na": true, "n/a": true, "nan": true, "null": true, "none": true, "#n/a": true}
+
+var datetimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02", "2006/01/02"}
+
+// DatasetFormat returns the dataset format of a file name, or "" if unsupported
+func DatasetFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return DatasetCSV
+	case ".tsv", ".tab":
+		return DatasetTSV
+	case ".xlsx", ".xlsm", ".xltx", ".xltm":
+		return DatasetExcel
+	case ".parquet":
+		return DatasetParquet
+	}
+	return ""
+}
+
+// ColumnSummary describes one column of a dataset
+type ColumnSummary struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	Count          int      `json:"count"`
+	Nulls          int      `json:"nulls"`
+	Distinct       int      `json:"distinct"`
+	DistinctCapped bool     `json:"distinct_capped,omitempty"`
+	Min            *float64 `json:"min,omitempty"`
+	Max            *float64 `json:"max,omitempty"`
+	Mean           *float64 `json:"mean,omitempty"`
+	Stdev          *float64 `json:"stdev,omitempty"`
+	Examples       []string `json:"examples,omitempty"`
+}
+
+// DatasetDescription is the result of describe_dataset
+type DatasetDescription struct {
+	Path             string          `json:"path,omitempty"`
+	Variable         string          `json:"variable,omitempty"`
+	Format           string          `json:"format"`
+	Sheet            string          `json:"sheet,omitempty"`
+	Sheets           []string        `json:"sheets,omitempty"`
+	Rows             int             `json:"rows"`
+	TotalColumns     int             `json:"total_columns"`
+	ColumnsTruncated bool            `json:"columns_truncated,omitempty"`
+	Columns          []ColumnSummary `json:"columns"`
+}
+
+// DatasetPage is a page of rows returned by preview_dataset and query_variable
+type DatasetPage struct {
+	Path             string   `json:"path,omitempty"`
+	Variable         string   `json:"variable,omitempty"`
+	Format           string   `json:"format"`
+	Sheet            string   `json:"sheet,omitempty"`
+	Sheets           []string `json:"sheets,omitempty"`
+	Offset           int      `json:"offset"`
+	Limit            int      `json:"limit"`
+	TotalRows        int      `json:"total_rows"`
+	HasMore          bool     `json:"has_more"`
+	TotalColumns     int      `json:"total_columns"`
+	ColumnsTruncated bool     `json:"columns_truncated,omitempty"`
+	Columns          []string `json:"columns"`
+	Rows             [][]any  `json:"rows"`
+}
+
+// DatasetVisitor receives the column names of a dataset and then each of its rows
+type DatasetVisitor interface {
+	SetColumns(columns []string)
+	AddRow(row []any)
+}
+
+// Table is the part of insyra.IDataTable needed to scan a DataTable
+type Table interface {
+	Size() (numRows int, numCols int)
+	ColNames() []string
+	GetColIndexByNumber(number int) string
+	GetElementByNumberIndex(rowIndex int, columnIndex int) any
+}
+
+// List is the part of insyra.IDataList needed to scan a DataList
+type List interface {
+	Len() int
+	Data() []any
+	GetName() string
+}
+
+// DatasetSummarizer accumulates column statistics row by row
+type DatasetSummarizer struct {
+	columns      []*columnStats
+	totalColumns int
+	rows         int
+}
+
+type columnStats struct {
+	name     string
+	kinds    map[string]bool
+	count    int
+	nulls    int
+	distinct map[string]bool
+	capped   bool
+	examples []string
+	numbers  int
+	mean, m2 float64
+	min, max float64
+}
+
+// SetColumns starts the summary of a dataset with the given columns
+func (s *DatasetSummarizer) SetColumns(columns []string) {
+	s.totalColumns = len(columns)
+	if len(columns) > MaxDatasetColumns {
+		columns = columns[:MaxDatasetColumns]
+	}
+	s.columns = make([]*columnStats, len(columns))
+	for i, name := range columns {
+		s.columns[i] = &columnStats{name: name, kinds: make(map[string]bool), distinct: make(map[string]bool)}
+	}
+}
+
+// AddRow adds a row; missing cells count as nulls
+func (s *DatasetSummarizer) AddRow(row []any) {
+	s.rows++
+	for i, col := range s.columns {
+		var value any
+		if i < len(row) {
+			value = row[i]
+		}
+		col.add(value)
+	}
+}
+
+func (c *columnStats) add(value any) {
+	kind, num, text := classifyValue(value)
+	if kind == "" {
+		c.nulls++
+		return
+	}
+	c.count++
+	c.kinds[kind] = true
+	if !c.distinct[text] {
+		if len(c.distinct) < maxDistinctValues {
+			c.distinct[text] = true
+			if len(c.examples) < maxColumnExamples {
+				c.examples = append(c.examples, truncateCell(text, 50))
+			}
+		} else {
+			c.capped = true
+		}
+	}
+	if kind != ColumnNumber {
+		return
+	}
+	// Welford's online mean and variance
+	c.numbers++
+	if c.numbers == 1 {
+		c.min, c.max = num, num
+	} else {
+		c.min = math.Min(c.min, num)
+		c.max = math.Max(c.max, num)
+	}
+	delta := num - c.mean
+	c.mean += delta / float64(c.numbers)
+	c.m2 += delta * (num - c.mean)
+}
+
+// Describe returns the summary of the rows added so far
+func (s *DatasetSummarizer) Describe() *DatasetDescription {
+	desc := &DatasetDescription{
+		Rows:             s.rows,
+		TotalColumns:     s.totalColumns,
+		ColumnsTruncated: s.totalColumns > len(s.columns),
+		Columns:          make([]ColumnSummary, 0, len(s.columns)),
+	}
+	for _, c := range s.columns {
+		summary := ColumnSummary{
+			Name:           c.name,
+			Type:           columnType(c.kinds),
+			Count:          c.count,
+			Nulls:          c.nulls,
+			Distinct:       len(c.distinct),
+			DistinctCapped: c.capped,
+			Examples:       c.examples,
+		}
+		if c.numbers > 0 {
+			minimum, maximum, mean := c.min, c.max, c.mean
+			summary.Min, summary.Max, summary.Mean = &minimum, &maximum, &mean
+			if c.numbers > 1 {
+				stdev := math.Sqrt(c.m2 / float64(c.numbers-1))
+				summary.Stdev = &stdev
+			}
+		}
+		desc.Columns = append(desc.Columns, summary)
+	}
+	return desc
+}
+
+func columnType(kinds map[string]bool) string {
+	switch len(kinds) {
+	case 0:
+		return ColumnEmpty
+	case 1:
+		for kind := range kinds {
+			return kind
+		}
+	}
+	return ColumnMixed
+}
+
+// DatasetPager collects one page of rows while counting all of them
+type DatasetPager struct {
+	page     DatasetPage
+	keep     int
+	rowIndex int
+}
+
+// NewDatasetPager creates a pager for rows [offset, offset+limit); limits are clamped
+func NewDatasetPager(offset, limit int) *DatasetPager {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = DefaultDatasetPageSize
+	}
+	if limit > MaxDatasetPageSize {
+		limit = MaxDatasetPageSize
+	}
+	return &DatasetPager{page: DatasetPage{Offset: offset, Limit: limit, Rows: [][]any{}}}
+}
+
+// SetColumns records the column names of the page
+func (p *DatasetPager) SetColumns(columns []string) {
+	p.page.TotalColumns = len(columns)
+	p.keep = len(columns)
+	if p.keep > MaxDatasetColumns {
+		p.keep = MaxDatasetColumns
+		p.page.ColumnsTruncated = true
+	}
+	p.page.Columns = columns[:p.keep]
+}
+
+// AddRow keeps the row if it falls inside the page
+func (p *DatasetPager) AddRow(row []any) {
+	index := p.rowIndex
+	p.rowIndex++
+	if index < p.page.Offset || index >= p.page.Offset+p.page.Limit {
+		return
+	}
+	out := make([]any, p.keep)
+	for i := range out {
+		if i < len(row) {
+			out[i] = pageValue(row[i])
+		}
+	}
+	p.page.Rows = append(p.page.Rows, out)
+}
+
+// Page returns the collected page
+func (p *DatasetPager) Page() *DatasetPage {
+	page := p.page
+	page.TotalRows = p.rowIndex
+	page.HasMore = page.Offset+len(page.Rows) < page.TotalRows
+	return &page
+}
+
+// classifyValue returns the kind of a non-missing value ("" for missing),
+// its numeric value for numbers and its text form
+func classifyValue(value any) (kind string, num float64, text string) {
+	switch v := value.(type) {
+	case nil:
+		return "", 0, ""
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if nullStrings[strings.ToLower(trimmed)] {
+			return "", 0, ""
+		}
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return ColumnNumber, f, trimmed
+		}
+		if lower := strings.ToLower(trimmed); lower == "true" || lower == "false" {
+			return ColumnBoolean, 0, lower
+		}
+		for _, layout := range datetimeLayouts {
+			if _, err := time.Parse(layout, trimmed); err == nil {
+				return ColumnDatetime, 0, trimmed
+			}
+		}
+		return ColumnString, 0, v
+	case bool:
+		return ColumnBoolean, 0, strconv.FormatBool(v)
+	case time.Time:
+		return ColumnDatetime, 0, v.Format(time.RFC3339)
+	}
+	if f, ok := toFloat(value); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", 0, ""
+		}
+		return ColumnNumber, f, strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return ColumnString, 0, fmt.Sprint(value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// pageValue converts a cell to a bounded JSON value
+func pageValue(value any) any {
+	switch v := value.(type) {
+	case nil, bool:
+		return v
+	case string:
+		return truncateCell(v, maxDatasetCellLength)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	if f, ok := toFloat(value); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return value
+	}
+	return truncateCell(fmt.Sprint(value), maxDatasetCellLength)
+}
+
+func truncateCell(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// ScanCSV reads a delimited file whose first record holds the column names
+func ScanCSV(ctx context.Context, r io.Reader, comma rune, v DatasetVisitor) error {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		v.SetColumns([]string{})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	v.SetColumns(columnNames(header))
+	reader.ReuseRecord = true
+	for n := 0; ; n++ {
+		if n%datasetCancelCheck == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading row %d: %w", n+1, err)
+		}
+		row := make([]any, len(record))
+		for i, cell := range record {
+			row[i] = cell
+		}
+		v.AddRow(row)
+	}
+}
+
+// ScanExcel reads a worksheet whose first row holds the column names.
+// An empty sheet name selects the first sheet; the sheet used and all sheet names are returned.
+func ScanExcel(ctx context.Context, r io.Reader, sheet string, v DatasetVisitor) (string, []string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("error opening workbook: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if sheet == "" {
+		if len(sheets) == 0 {
+			return "", sheets, fmt.Errorf("workbook has no sheets")
+		}
+		sheet = sheets[0]
+	}
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return sheet, sheets, fmt.Errorf("error reading sheet %q: %w", sheet, err)
+	}
+	defer rows.Close()
+
+	header := true
+	for n := 0; rows.Next(); n++ {
+		if n%datasetCancelCheck == 0 && ctx.Err() != nil {
+			return sheet, sheets, ctx.Err()
+		}
+		cells, err := rows.Columns()
+		if err != nil {
+			return sheet, sheets, fmt.Errorf("error reading row %d: %w", n+1, err)
+		}
+		if header {
+			v.SetColumns(columnNames(cells))
+			header = false
+			continue
+		}
+		row := make([]any, len(cells))
+		for i, cell := range cells {
+			row[i] = cell
+		}
+		v.AddRow(row)
+	}
+	if header {
+		v.SetColumns([]string{})
+	}
+	return sheet, sheets, rows.Error()
+}
+
+// ScanTable passes the columns and rows of a DataTable to v
+func ScanTable(ctx context.Context, t Table, v DatasetVisitor) error {
+	numRows, numCols := t.Size()
+	names := t.ColNames()
+	columns := make([]string, numCols)
+	for i := range columns {
+		if i < len(names) && names[i] != "" {
+			columns[i] = names[i]
+		} else {
+			columns[i] = t.GetColIndexByNumber(i)
+		}
+	}
+	v.SetColumns(columns)
+	if numCols > MaxDatasetColumns {
+		numCols = MaxDatasetColumns
+	}
+	for r := 0; r < numRows; r++ {
+		if r%datasetCancelCheck == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		row := make([]any, numCols)
+		for c := range row {
+			row[c] = t.GetElementByNumberIndex(r, c)
+		}
+		v.AddRow(row)
+	}
+	return nil
+}
+
+// ScanList passes a DataList to v as a single column
+func ScanList(ctx context.Context, l List, v DatasetVisitor) error {
+	name := l.GetName()
+	if name == "" {
+		name = "value"
+	}
+	v.SetColumns([]string{name})
+	for i, value := range l.Data() {
+		if i%datasetCancelCheck == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		v.AddRow([]any{value})
+	}
+	return nil
+}
+
+// columnNames names unnamed header cells after their position
+func columnNames(header []string) []string {
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		columns[i] = name
+	}
+	return columns
+}
+
+// DatasetQuery holds the arguments of describe_dataset, preview_dataset and query_variable
+type DatasetQuery struct {
+	Path     string
+	Variable string
+	Notebook string
+	Sheet    string
+	Offset   int
+	Limit    int
+	Describe bool
+}
+
+// ParseDatasetQuery reads dataset tool arguments
+func ParseDatasetQuery(args map[string]interface{}) (DatasetQuery, error) {
+	q := DatasetQuery{}
+	q.Path, _ = args["path"].(string)
+	q.Variable, _ = args["name"].(string)
+	q.Notebook, _ = args["notebook"].(string)
+	q.Sheet, _ = args["sheet"].(string)
+	q.Describe, _ = args["describe"].(bool)
+	for key, target := range map[string]*int{"offset": &q.Offset, "limit": &q.Limit} {
+		if value, ok := args[key]; ok {
+			n, ok := value.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return q, fmt.Errorf("%s must be a non-negative integer", key)
+			}
+			*target = int(n)
+		}
+	}
+	return q, nil
+}
+
+// DatasetStatsNote tells describe_dataset callers how its statistics are made:
+// a single streaming pass with bounded memory instead of loading the file into
+// an insyra DataTable, so it is a reduced implementation of insyra's statistics
+const DatasetStatsNote = "Statistics come from a single streaming pass over the file (count, mean, sample standard deviation, min and max, with types inferred from the values) rather than from insyra, so large files are never loaded whole"
+
+// ErrParquetUnsupported is returned when no Parquet reader is configured
+var ErrParquetUnsupported = errors.New("parquet files are not supported by this server")
+
+// DatasetSource reads workspace files for describe_dataset and preview_dataset
+type DatasetSource struct {
+	// Open returns the content of a workspace file (CSV, TSV or Excel)
+	Open func(path string) (io.ReadCloser, error)
+	// ReadParquet loads a workspace Parquet file; nil disables Parquet support
+	ReadParquet func(ctx context.Context, path string) (Table, error)
+}
+
+// Describe summarizes every column of a workspace dataset
+func (s DatasetSource) Describe(ctx context.Context, q DatasetQuery) (*DatasetDescription, error) {
+	summarizer := &DatasetSummarizer{}
+	format, sheet, sheets, err := s.scan(ctx, q, summarizer)
+	if err != nil {
+		return nil, err
+	}
+	desc := summarizer.Describe()
+	desc.Path, desc.Format, desc.Sheet, desc.Sheets = q.Path, format, sheet, sheets
+	return desc, nil
+}
+
+// Preview returns a page of rows of a workspace dataset
+func (s DatasetSource) Preview(ctx context.Context, q DatasetQuery) (*DatasetPage, error) {
+	pager := NewDatasetPager(q.Offset, q.Limit)
+	format, sheet, sheets, err := s.scan(ctx, q, pager)
+	if err != nil {
+		return nil, err
+	}
+	page := pager.Page()
+	page.Path, page.Format, page.Sheet, page.Sheets = q.Path, format, sheet, sheets
+	return page, nil
+}
+
+func (s DatasetSource) scan(ctx context.Context, q DatasetQuery, v DatasetVisitor) (format, sheet string, sheets []string, err error) {
+	cleanPath, err := safeCleanRelativePath(q.Path)
+	if err != nil {
+		return "", "", nil, err
+	}
+	format = DatasetFormat(cleanPath)
+	switch format {
+	case "":
+		return "", "", nil, fmt.Errorf("unsupported dataset format: %s (use .csv, .tsv, .xlsx or .parquet)", cleanPath)
+	case DatasetParquet:
+		if s.ReadParquet == nil {
+			return format, "", nil, ErrParquetUnsupported
+		}
+		table, err := s.ReadParquet(ctx, cleanPath)
+		if err != nil {
+			return format, "", nil, fmt.Errorf("error reading parquet file: %w", err)
+		}
+		return format, "", nil, ScanTable(ctx, table, v)
+	}
+
+	if s.Open == nil {
+		return format, "", nil, fmt.Errorf("read backend not available")
+	}
+	r, err := s.Open(cleanPath)
+	if err != nil {
+		return format, "", nil, err
+	}
+	defer r.Close()
+	switch format {
+	case DatasetExcel:
+		sheet, sheets, err = ScanExcel(ctx, r, q.Sheet, v)
+	case DatasetTSV:
+		err = ScanCSV(ctx, r, '\t', v)
+	default:
+		err = ScanCSV(ctx, r, ',', v)
+	}
+	return format, sheet, sheets, err
+}
+
+// ScanVariable passes a DataTable or DataList session variable to v
+func ScanVariable(ctx context.Context, value any, v DatasetVisitor) (string, error) {
+	switch data := value.(type) {
+	case Table:
+		return DatasetTable, ScanTable(ctx, data, v)
+	case List:
+		return DatasetList, ScanList(ctx, data, v)
+	}
+	return "", fmt.Errorf("variable of type %T is not a DataTable or DataList", value)
+}
+
+// QueryVariable describes or pages through a DataTable or DataList session variable
+func QueryVariable(ctx context.Context, q DatasetQuery, value any) (any, error) {
+	if q.Describe {
+		summarizer := &DatasetSummarizer{}
+		format, err := ScanVariable(ctx, value, summarizer)
+		if err != nil {
+			return nil, err
+		}
+		desc := summarizer.Describe()
+		desc.Variable, desc.Format = q.Variable, format
+		return desc, nil
+	}
+	pager := NewDatasetPager(q.Offset, q.Limit)
+	format, err := ScanVariable(ctx, value, pager)
+	if err != nil {
+		return nil, err
+	}
+	page := pager.Page()
+	page.Variable, page.Format = q.Variable, format
+	return page, nil
+}
+
+// FormatDataset renders a dataset description or page as indented JSON
+func FormatDataset(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DescribeDatasetInputSchema is the input schema of describe_dataset
+func DescribeDatasetInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path":  map[string]interface{}{"type": "string", "description": "Path to a .csv, .tsv, .xlsx or .parquet file relative to workspace root"},
+			"sheet": map[string]interface{}{"type": "string", "description": "Excel sheet name (default: first sheet)"},
+		},
+		"required": []string{"path"},
+	}
+}
+
+// PreviewDatasetInputSchema is the input schema of preview_dataset
+func PreviewDatasetInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path":   map[string]interface{}{"type": "string", "description": "Path to a .csv, .tsv, .xlsx or .parquet file relative to workspace root"},
+			"sheet":  map[string]interface{}{"type": "string", "description": "Excel sheet name (default: first sheet)"},
+			"offset": map[string]interface{}{"type": "integer", "description": "Index of the first row to return (default 0)"},
+			"limit":  map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Number of rows to return (default %d, max %d)", DefaultDatasetPageSize, MaxDatasetPageSize)},
+		},
+		"required": []string{"path"},
+	}
+}
+
+// QueryVariableInputSchema is the input schema of query_variable
+func QueryVariableInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":     map[string]interface{}{"type": "string", "description": "Name of a DataTable or DataList variable in the notebook session"},
+			"notebook": map[string]interface{}{"type": "string", "description": "Notebook whose session holds the variable (default: the active notebook)"},
+			"offset":   map[string]interface{}{"type": "integer", "description": "Index of the first row to return (default 0)"},
+			"limit":    map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Number of rows to return (default %d, max %d)", DefaultDatasetPageSize, MaxDatasetPageSize)},
+			"describe": map[string]interface{}{"type": "boolean", "description": "Return column types and summary statistics instead of rows"},
+		},
+		"required": []string{"name"},
+	}
+}
@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const testCSV = "\ufeffname,score,passed,joined\n" +
+	"amy,90.5,true,2024-01-02\n" +
+	"bob,70,false,2024-02-03\n" +
+	"cat,NA,true,\n" +
+	"dan,80.5,false,2024-03-04\n"
+
+func TestDescribeCSV(t *testing.T) {
+	summarizer := &DatasetSummarizer{}
+	if err := ScanCSV(context.Background(), strings.NewReader(testCSV), ',', summarizer); err != nil {
+		t.Fatal(err)
+	}
+	desc := summarizer.Describe()
+	if desc.Rows != 4 || len(desc.Columns) != 4 {
+		t.Fatalf("unexpected shape: %+v", desc)
+	}
+	name, score, passed, joined := desc.Columns[0], desc.Columns[1], desc.Columns[2], desc.Columns[3]
+	if name.Name != "name" || name.Type != ColumnString || name.Distinct != 4 {
+		t.Errorf("unexpected name column: %+v", name)
+	}
+	if score.Type != ColumnNumber || score.Count != 3 || score.Nulls != 1 {
+		t.Errorf("unexpected score column: %+v", score)
+	}
+	if *score.Min != 70 || *score.Max != 90.5 || *score.Mean != 80.33333333333333 || score.Stdev == nil {
+		t.Errorf("unexpected score stats: min=%v max=%v mean=%v", *score.Min, *score.Max, *score.Mean)
+	}
+	if passed.Type != ColumnBoolean || passed.Distinct != 2 {
+		t.Errorf("unexpected passed column: %+v", passed)
+	}
+	if joined.Type != ColumnDatetime || joined.Nulls != 1 {
+		t.Errorf("unexpected joined column: %+v", joined)
+	}
+}
+
+func TestPreviewPagesAndBoundsCells(t *testing.T) {
+	pager := NewDatasetPager(1, 2)
+	csv := testCSV + "eve," + strings.Repeat("x", 300) + ",true,2024-05-06\n"
+	if err := ScanCSV(context.Background(), strings.NewReader(csv), ',', pager); err != nil {
+		t.Fatal(err)
+	}
+	page := pager.Page()
+	if page.TotalRows != 5 || len(page.Rows) != 2 || !page.HasMore {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if page.Rows[0][0] != "bob" || page.Rows[1][1] != "NA" {
+		t.Errorf("unexpected rows: %v", page.Rows)
+	}
+
+	last := NewDatasetPager(4, 10)
+	ScanCSV(context.Background(), strings.NewReader(csv), ',', last)
+	cell := last.Page().Rows[0][1].(string)
+	if len([]rune(cell)) != maxDatasetCellLength+1 || last.Page().HasMore {
+		t.Errorf("long cell not truncated: %d runes", len([]rune(cell)))
+	}
+	if p := NewDatasetPager(0, 10000); p.page.Limit != MaxDatasetPageSize {
+		t.Errorf("limit not clamped: %d", p.page.Limit)
+	}
+}
+
+func TestScanExcelSheet(t *testing.T) {
+	f := excelize.NewFile()
+	f.NewSheet("Data")
+	f.SetSheetRow("Data", "A1", &[]any{"city", "population"})
+	f.SetSheetRow("Data", "A2", &[]any{"Taipei", 2500000})
+	f.SetSheetRow("Data", "A3", &[]any{"Tainan", 1850000})
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	summarizer := &DatasetSummarizer{}
+	sheet, sheets, err := ScanExcel(context.Background(), &buf, "Data", summarizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := summarizer.Describe()
+	if sheet != "Data" || len(sheets) != 2 || desc.Rows != 2 || desc.Columns[1].Type != ColumnNumber {
+		t.Fatalf("unexpected excel description: %s %v %+v", sheet, sheets, desc)
+	}
+}
+
+type fakeTable struct {
+	names []string
+	rows  [][]any
+}
+
+func (f fakeTable) Size() (int, int)                      { return len(f.rows), len(f.names) }
+func (f fakeTable) ColNames() []string                    { return f.names }
+func (f fakeTable) GetColIndexByNumber(number int) string { return string(rune('A' + number)) }
+func (f fakeTable) GetElementByNumberIndex(row, col int) any {
+	return f.rows[row][col]
+}
+
+func TestQueryVariable(t *testing.T) {
+	table := fakeTable{names: []string{"x", ""}, rows: [][]any{{1, "a"}, {2.5, nil}, {3, "c"}}}
+	result, err := QueryVariable(context.Background(), DatasetQuery{Variable: "dt", Limit: 2}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := result.(*DatasetPage)
+	if page.Format != DatasetTable || page.Columns[1] != "B" || page.TotalRows != 3 || len(page.Rows) != 2 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	result, err = QueryVariable(context.Background(), DatasetQuery{Variable: "dt", Describe: true}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := result.(*DatasetDescription)
+	if desc.Columns[0].Type != ColumnNumber || *desc.Columns[0].Mean != 6.5/3 || desc.Columns[1].Nulls != 1 {
+		t.Fatalf("unexpected description: %+v", desc.Columns)
+	}
+	if _, err := QueryVariable(context.Background(), DatasetQuery{Variable: "n"}, 42); err == nil {
+		t.Fatal("expected error for non-tabular variable")
+	}
+}
+
+func TestServerDescribeDataset(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "scores.csv"), []byte(testCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(DefaultConfig(), root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	resp, err := s.HandleRequest(context.Background(), &ToolRequest{Name: "describe_dataset", Arguments: map[string]interface{}{"path": "scores.csv"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var desc DatasetDescription
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &desc); err != nil {
+		t.Fatal(err)
+	}
+	if desc.Format != DatasetCSV || desc.Rows != 4 {
+		t.Fatalf("unexpected description: %+v", desc)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"path": "../scores.csv"},
+		{"path": "scores.txt"},
+		{"path": "data.parquet"},
+		{"path": "scores.csv", "offset": -1.0},
+	} {
+		if resp, err := s.HandleRequest(context.Background(), &ToolRequest{Name: "preview_dataset", Arguments: args}); err == nil || !resp.IsError {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+	// Without a Parquet reader the tools do not offer Parquet files
+	describes := func() string {
+		for _, tool := range s.ListTools() {
+			if tool.Name == "describe_dataset" {
+				return tool.Description
+			}
+		}
+		return ""
+	}
+	if desc := describes(); strings.Contains(desc, "Parquet") || !strings.Contains(desc, "rather than from insyra") {
+		t.Errorf("describe_dataset description = %q", desc)
+	}
+	s.SetParquetReader(func(ctx context.Context, path string) (Table, error) { return nil, ErrParquetUnsupported })
+	if desc := describes(); !strings.Contains(desc, "Parquet") {
+		t.Errorf("describe_dataset description without Parquet: %q", desc)
+	}
+}
@@ -95,20 +95,14 @@ func (fo *FileOperations) ReadFile(ctx context.Context, path string) (*ToolRespo
 
 // WriteFile writes content to a file
 func (fo *FileOperations) WriteFile(ctx context.Context, path string, content string) (*ToolResponse, error) {
-	if fo.config.FileEdit == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "File edit permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if fo.config.FileEdit == PermissionAsk && fo.confirmFunc != nil {
-		if !fo.confirmFunc("File Edit", fmt.Sprintf("Edit file: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "File edit cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, fo.config, fo.confirmFunc, permissionPrompt{
+		Op:      OpFileEdit,
+		Paths:   []string{path},
+		Subject: "File edit",
+		Title:   "File Edit",
+		Details: fmt.Sprintf("Edit file: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	cleanPath, err := safeCleanRelativePath(path)
@@ -134,20 +128,14 @@ func (fo *FileOperations) WriteFile(ctx context.Context, path string, content st
 
 // CreateFile creates a new file
 func (fo *FileOperations) CreateFile(ctx context.Context, path string, content string) (*ToolResponse, error) {
-	if fo.config.FileCreate == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "File create permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if fo.config.FileCreate == PermissionAsk && fo.confirmFunc != nil {
-		if !fo.confirmFunc("File Create", fmt.Sprintf("Create file: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "File create cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, fo.config, fo.confirmFunc, permissionPrompt{
+		Op:      OpFileCreate,
+		Paths:   []string{path},
+		Subject: "File create",
+		Title:   "File Create",
+		Details: fmt.Sprintf("Create file: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	cleanPath, err := safeCleanRelativePath(path)
@@ -173,20 +161,14 @@ func (fo *FileOperations) CreateFile(ctx context.Context, path string, content s
 
 // DeleteFile deletes a file
 func (fo *FileOperations) DeleteFile(ctx context.Context, path string) (*ToolResponse, error) {
-	if fo.config.FileDelete == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "File delete permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if fo.config.FileDelete == PermissionAsk && fo.confirmFunc != nil {
-		if !fo.confirmFunc("File Delete", fmt.Sprintf("Delete file: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "File delete cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, fo.config, fo.confirmFunc, permissionPrompt{
+		Op:      OpFileDelete,
+		Paths:   []string{path},
+		Subject: "File delete",
+		Title:   "File Delete",
+		Details: fmt.Sprintf("Delete file: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	cleanPath, err := safeCleanRelativePath(path)
@@ -208,20 +190,14 @@ func (fo *FileOperations) DeleteFile(ctx context.Context, path string) (*ToolRes
 
 // RenameFile renames a file
 func (fo *FileOperations) RenameFile(ctx context.Context, oldPath string, newPath string) (*ToolResponse, error) {
-	if fo.config.FileRename == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "File rename permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if fo.config.FileRename == PermissionAsk && fo.confirmFunc != nil {
-		if !fo.confirmFunc("File Rename", fmt.Sprintf("Rename file: %s -> %s", oldPath, newPath)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "File rename cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, fo.config, fo.confirmFunc, permissionPrompt{
+		Op:      OpFileRename,
+		Paths:   []string{oldPath, newPath},
+		Subject: "File rename",
+		Title:   "File Rename",
+		Details: fmt.Sprintf("Rename file: %s -> %s", oldPath, newPath),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Validate paths
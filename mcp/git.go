@@ -0,0 +1,628 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary statuses of a file in a git work tree
+const (
+	GitUntracked  = "untracked"
+	GitModified   = "modified"
+	GitDeleted    = "deleted"
+	GitStaged     = "staged"
+	GitConflicted = "conflicted"
+)
+
+const (
+	// DefaultGitLogLimit is the number of commits returned when no limit is given
+	DefaultGitLogLimit = 20
+	// MaxGitLogLimit bounds the commits returned by git_log
+	MaxGitLogLimit = 500
+)
+
+// ErrNotGitRepo is returned when the workspace is not inside a git work tree
+var ErrNotGitRepo = errors.New("not a git repository")
+
+// GitRepo runs the git CLI for a workspace. Dir may be a subdirectory of the work
+// tree; paths passed in and returned are relative to Dir.
+type GitRepo struct {
+	Dir string
+}
+
+// GitFileStatus is the status of one changed file
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	// Index and Worktree are the porcelain status letters, e.g. "M", "A", "?"
+	Index    string `json:"index"`
+	Worktree string `json:"worktree"`
+	// Status summarizes the letters as one of the Git* statuses
+	Status string `json:"status"`
+	Staged bool   `json:"staged"`
+}
+
+// GitCommit is one entry of the history
+type GitCommit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// run executes git in Dir and returns its standard output
+func (g GitRepo) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.Dir
+	// Never prompt for credentials or an editor, and keep messages parseable
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "LC_ALL=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("git is not installed: %w", err)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if strings.Contains(msg, "not a git repository") {
+			return nil, ErrNotGitRepo
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// prefix returns the path of Dir inside the work tree, with a trailing slash
+func (g GitRepo) prefix(ctx context.Context) (string, error) {
+	out, err := g.run(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsRepo reports whether Dir is inside a git work tree
+func (g GitRepo) IsRepo(ctx context.Context) bool {
+	out, err := g.run(ctx, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+func (g GitRepo) hasHead(ctx context.Context) bool {
+	_, err := g.run(ctx, "rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+// Status lists the changed files below Dir
+func (g GitRepo) Status(ctx context.Context) ([]GitFileStatus, error) {
+	prefix, err := g.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.run(ctx, "status", "--porcelain=v1", "-z", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	return parseGitStatus(out, prefix), nil
+}
+
+// parseGitStatus parses "git status --porcelain=v1 -z" output. Porcelain paths are
+// relative to the work tree root, so prefix is stripped from them.
+func parseGitStatus(out []byte, prefix string) []GitFileStatus {
+	fields := strings.Split(string(out), "\x00")
+	statuses := make([]GitFileStatus, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		st := GitFileStatus{
+			Index:    string(entry[0]),
+			Worktree: string(entry[1]),
+			Path:     strings.TrimPrefix(entry[3:], prefix),
+		}
+		if st.Index == "R" || st.Index == "C" {
+			// The source of a rename or copy follows as its own field
+			if i+1 < len(fields) {
+				i++
+				st.OrigPath = strings.TrimPrefix(fields[i], prefix)
+			}
+		}
+		st.Status, st.Staged = gitSummary(entry[0], entry[1])
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses
+}
+
+// gitSummary reduces porcelain status letters to a summary status
+func gitSummary(x, y byte) (string, bool) {
+	switch {
+	case x == '?' && y == '?':
+		return GitUntracked, false
+	case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+		return GitConflicted, false
+	case y == 'D':
+		return GitDeleted, x != ' '
+	case y != ' ':
+		return GitModified, x != ' '
+	case x == 'D':
+		return GitDeleted, true
+	default:
+		return GitStaged, true
+	}
+}
+
+// headContent returns a file as committed in HEAD; ok is false when the file is not in HEAD
+func (g GitRepo) headContent(ctx context.Context, prefix string, name string) ([]byte, bool, error) {
+	if !g.hasHead(ctx) {
+		return nil, false, nil
+	}
+	spec := "HEAD:" + prefix + name
+	if _, err := g.run(ctx, "cat-file", "-e", spec); err != nil {
+		return nil, false, nil
+	}
+	out, err := g.run(ctx, "show", spec)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// ConflictVersions returns the base, ours and theirs versions of a file with merge
+// conflicts from the index; a version is nil when the file did not exist on that side
+func (g GitRepo) ConflictVersions(ctx context.Context, name string) (base, ours, theirs []byte, err error) {
+	prefix, err := g.prefix(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	out, err := g.run(ctx, "ls-files", "-u", "-z", "--", name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil, nil, fmt.Errorf("%s has no merge conflict", name)
+	}
+	versions := make([][]byte, 3)
+	for stage := 1; stage <= 3; stage++ {
+		spec := fmt.Sprintf(":%d:%s%s", stage, prefix, name)
+		if _, err := g.run(ctx, "cat-file", "-e", spec); err != nil {
+			continue
+		}
+		if versions[stage-1], err = g.run(ctx, "show", spec); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return versions[0], versions[1], versions[2], nil
+}
+
+// Diff returns the changes of a file against HEAD, staged or not, as a unified diff.
+// Notebooks are compared cell by cell. An empty name diffs every changed file.
+func (g GitRepo) Diff(ctx context.Context, name string) (string, error) {
+	prefix, err := g.prefix(ctx)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	if name == "" {
+		statuses, err := g.Status(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, st := range statuses {
+			names = append(names, st.Path)
+		}
+	} else {
+		clean := path.Clean(filepath.ToSlash(name))
+		if clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+			return "", fmt.Errorf("path outside the workspace: %s", name)
+		}
+		names = []string{clean}
+	}
+
+	var sb strings.Builder
+	for _, n := range names {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		before, _, err := g.headContent(ctx, prefix, n)
+		if err != nil {
+			return "", err
+		}
+		after, err := os.ReadFile(filepath.Join(g.Dir, filepath.FromSlash(n)))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		sb.WriteString(diffVersions(n, before, after))
+	}
+	return sb.String(), nil
+}
+
+// diffVersions diffs two versions of a file, per cell for notebooks
+func diffVersions(name string, before, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+	if bytes.IndexByte(before, 0) >= 0 || bytes.IndexByte(after, 0) >= 0 {
+		return fmt.Sprintf("Binary file %s differs\n", name)
+	}
+	if isNotebookPath(name) {
+		if diff, err := NotebookDiff(name, before, after); err == nil {
+			return diff
+		}
+	}
+	return UnifiedDiff(name, string(before), string(after))
+}
+
+func isNotebookPath(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".igonb" || ext == ".ipynb"
+}
+
+// notebookDiffCell is the part of a cell that is compared
+type notebookDiffCell struct {
+	id       string
+	language string
+	source   string
+}
+
+// key identifies a cell across versions: by ID when it has one, otherwise by content
+func (c notebookDiffCell) key() string {
+	if c.id != "" {
+		return "id:" + c.id
+	}
+	return "src:" + c.language + "\x00" + c.source
+}
+
+// parseNotebookDiffCells reads the cells of an igonb or ipynb document; an empty
+// document has no cells
+func parseNotebookDiffCells(data []byte) ([]notebookDiffCell, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var nb struct {
+		Cells []struct {
+			ID       string          `json:"id"`
+			Language string          `json:"language"`
+			CellType string          `json:"cell_type"`
+			Source   json.RawMessage `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, err
+	}
+	cells := make([]notebookDiffCell, len(nb.Cells))
+	for i, c := range nb.Cells {
+		cell := notebookDiffCell{id: c.ID, language: c.Language}
+		if cell.language == "" {
+			cell.language = c.CellType
+		}
+		// ipynb stores the source as a string or a list of lines
+		var lines []string
+		if err := json.Unmarshal(c.Source, &cell.source); err != nil {
+			if err := json.Unmarshal(c.Source, &lines); err != nil {
+				return nil, fmt.Errorf("cell %d: invalid source", i+1)
+			}
+			cell.source = strings.Join(lines, "")
+		}
+		cells[i] = cell
+	}
+	return cells, nil
+}
+
+// NotebookDiff compares the cells of two notebook versions. Unchanged cells are
+// skipped and changed cells show a diff of their source; outputs are ignored.
+func NotebookDiff(name string, before, after []byte) (string, error) {
+	oldCells, err := parseNotebookDiffCells(before)
+	if err != nil {
+		return "", err
+	}
+	newCells, err := parseNotebookDiffCells(after)
+	if err != nil {
+		return "", err
+	}
+	oldKeys := make([]string, len(oldCells))
+	for i, c := range oldCells {
+		oldKeys[i] = c.key()
+	}
+	newKeys := make([]string, len(newCells))
+	for i, c := range newCells {
+		newKeys[i] = c.key()
+	}
+
+	var sb strings.Builder
+	changes := 0
+	writeCell := func(label string, index int, cell notebookDiffCell, body string) {
+		fmt.Fprintf(&sb, "## cell %d [%s] %s\n", index+1, cell.language, label)
+		sb.WriteString(body)
+		changes++
+	}
+	sourceDiff := func(oldSrc, newSrc string) string {
+		diff := UnifiedDiff("cell", oldSrc, newSrc)
+		// Drop the file header; the cell heading replaces it
+		if _, rest, ok := strings.Cut(diff, "\n+++ b/cell\n"); ok {
+			return rest
+		}
+		return diff
+	}
+
+	ops := diffLines(oldKeys, newKeys)
+	oi, ni := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			if oldCells[oi].source != newCells[ni].source || oldCells[oi].language != newCells[ni].language {
+				writeCell("modified", ni, newCells[ni], sourceDiff(oldCells[oi].source, newCells[ni].source))
+			}
+			oi++
+			ni++
+			k++
+			continue
+		}
+		// Pair removed and added cells of a changed run as modifications
+		var removed, added []int
+		for ; k < len(ops) && ops[k].kind != ' '; k++ {
+			if ops[k].kind == '-' {
+				removed = append(removed, oi)
+				oi++
+			} else {
+				added = append(added, ni)
+				ni++
+			}
+		}
+		// A cell without an ID may be the same cell after an ID was assigned to it
+		for len(removed) > 0 && len(added) > 0 && (oldCells[removed[0]].id == "" || newCells[added[0]].id == "") &&
+			oldCells[removed[0]].language == newCells[added[0]].language {
+			if oldSrc, newSrc := oldCells[removed[0]].source, newCells[added[0]].source; oldSrc != newSrc {
+				writeCell("modified", added[0], newCells[added[0]], sourceDiff(oldSrc, newSrc))
+			}
+			removed, added = removed[1:], added[1:]
+		}
+		for _, i := range removed {
+			writeCell("removed", i, oldCells[i], sourceDiff(oldCells[i].source, ""))
+		}
+		for _, i := range added {
+			writeCell("added", i, newCells[i], sourceDiff("", newCells[i].source))
+		}
+	}
+	if changes == 0 {
+		if bytes.Equal(before, after) {
+			return "", nil
+		}
+		return fmt.Sprintf("--- a/%s\n+++ b/%s\n(only outputs or metadata changed)\n", name, name), nil
+	}
+	return fmt.Sprintf("--- a/%s\n+++ b/%s\n", name, name) + sb.String(), nil
+}
+
+// Stage adds the current content of paths, including deletions, to the index
+func (g GitRepo) Stage(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to stage")
+	}
+	_, err := g.run(ctx, append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes the staged changes of paths from the index, keeping the files
+func (g GitRepo) Unstage(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to unstage")
+	}
+	if !g.hasHead(ctx) {
+		_, err := g.run(ctx, append([]string{"rm", "--cached", "-r", "-q", "--"}, paths...)...)
+		return err
+	}
+	_, err := g.run(ctx, append([]string{"reset", "-q", "HEAD", "--"}, paths...)...)
+	return err
+}
+
+// Commit records the staged changes and returns the new commit
+func (g GitRepo) Commit(ctx context.Context, message string) (*GitCommit, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("commit message cannot be empty")
+	}
+	if _, err := g.run(ctx, "commit", "-q", "-m", message); err != nil {
+		return nil, err
+	}
+	commits, err := g.Log(ctx, "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit not found after committing")
+	}
+	return &commits[0], nil
+}
+
+// Log returns up to limit commits, newest first, touching name (following renames)
+// or the whole repository when name is empty
+func (g GitRepo) Log(ctx context.Context, name string, limit int) ([]GitCommit, error) {
+	if limit <= 0 {
+		limit = DefaultGitLogLimit
+	}
+	limit = min(limit, MaxGitLogLimit)
+	if !g.hasHead(ctx) {
+		if !g.IsRepo(ctx) {
+			return nil, ErrNotGitRepo
+		}
+		return []GitCommit{}, nil
+	}
+	args := []string{"log", "-n", fmt.Sprint(limit), "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e"}
+	if name != "" {
+		args = append(args, "--follow", "--", name)
+	}
+	out, err := g.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	commits := []GitCommit{}
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[3])
+		commits = append(commits, GitCommit{Hash: fields[0], Author: fields[1], Email: fields[2], Date: date, Subject: fields[4]})
+	}
+	return commits, nil
+}
+
+// FormatGitStatus renders a status list for tool output
+func FormatGitStatus(statuses []GitFileStatus) string {
+	if len(statuses) == 0 {
+		return "Working tree clean"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d changed files:\n", len(statuses))
+	for _, st := range statuses {
+		staged := ""
+		if st.Staged {
+			staged = " (staged)"
+		}
+		fmt.Fprintf(&sb, "%s%s %s%s", st.Index, st.Worktree, st.Path, staged)
+		if st.OrigPath != "" {
+			fmt.Fprintf(&sb, " (from %s)", st.OrigPath)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// FormatGitLog renders commits for tool output
+func FormatGitLog(commits []GitCommit) string {
+	if len(commits) == 0 {
+		return "No commits"
+	}
+	var sb strings.Builder
+	for _, c := range commits {
+		hash := c.Hash
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(&sb, "%s %s %s: %s\n", hash, c.Date.Format("2006-01-02 15:04"), c.Author, c.Subject)
+	}
+	return sb.String()
+}
+
+// ParseGitPaths reads the workspace-relative "paths" argument of git_stage,
+// git_unstage and git_commit
+func ParseGitPaths(args map[string]interface{}) ([]string, error) {
+	paths, err := stringListArg(args, "paths")
+	if err != nil {
+		return nil, err
+	}
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		clean, err := safeCleanRelativePath(p)
+		if err != nil {
+			return nil, err
+		}
+		cleaned = append(cleaned, clean)
+	}
+	return cleaned, nil
+}
+
+// ParseGitLogArgs reads the optional path and limit arguments of git_log
+func ParseGitLogArgs(args map[string]interface{}) (string, int, error) {
+	name, _ := args["path"].(string)
+	limit := DefaultGitLogLimit
+	if v, ok := args["limit"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return "", 0, fmt.Errorf("limit must be a positive integer")
+		}
+		limit = int(n)
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", limit, nil
+	}
+	clean, err := safeCleanRelativePath(name)
+	if err != nil {
+		return "", 0, err
+	}
+	return clean, limit, nil
+}
+
+func gitPathsProperty(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": description,
+	}
+}
+
+// GitDiffInputSchema returns the input schema of the git_diff tool
+func GitDiffInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "File to diff against HEAD; omit to diff every changed file",
+			},
+		},
+		"required": []string{},
+	}
+}
+
+// GitStageInputSchema returns the input schema of the git_stage and git_unstage tools
+func GitStageInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"paths": gitPathsProperty("Files or folders relative to the workspace root"),
+		},
+		"required": []string{"paths"},
+	}
+}
+
+// GitCommitInputSchema returns the input schema of the git_commit tool
+func GitCommitInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "Commit message",
+			},
+			"paths": gitPathsProperty("Files or folders to stage before committing; omit to commit what is already staged"),
+		},
+		"required": []string{"message"},
+	}
+}
+
+// GitLogInputSchema returns the input schema of the git_log tool
+func GitLogInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Only commits touching this file, following renames; omit for the whole history",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of commits (default %d, max %d)", DefaultGitLogLimit, MaxGitLogLimit),
+			},
+		},
+		"required": []string{},
+	}
+}
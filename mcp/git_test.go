@@ -0,0 +1,305 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initGitWorkspace creates a repository with the workspace in a subdirectory
+func initGitWorkspace(t *testing.T) (string, GitRepo) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "Tester")
+	t.Setenv("GIT_AUTHOR_EMAIL", "tester@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Tester")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tester@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	if out, err := exec.Command("git", "-C", root, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	dir := filepath.Join(root, "work")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, GitRepo{Dir: dir}
+}
+
+func writeGitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitStatusStageCommitLog(t *testing.T) {
+	ctx := context.Background()
+	dir, repo := initGitWorkspace(t)
+	if !repo.IsRepo(ctx) {
+		t.Fatal("expected a git repository")
+	}
+	writeGitFile(t, dir, "main.go", "package main\n")
+	writeGitFile(t, dir, "lib/util.go", "package lib\n")
+
+	// Logs and unstaging work before the first commit
+	if commits, err := repo.Log(ctx, "", 0); err != nil || len(commits) != 0 {
+		t.Fatalf("log before first commit = %v, %v", commits, err)
+	}
+	statuses, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Path != "lib/util.go" || statuses[0].Status != GitUntracked {
+		t.Fatalf("status should list untracked files relative to the workspace: %+v", statuses)
+	}
+	if err := repo.Stage(ctx, []string{"main.go", "lib"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Unstage(ctx, []string{"lib"}); err != nil {
+		t.Fatal(err)
+	}
+	statuses, _ = repo.Status(ctx)
+	if statuses[0].Status != GitUntracked || statuses[1].Path != "main.go" || statuses[1].Status != GitStaged || !statuses[1].Staged {
+		t.Fatalf("unexpected status after staging: %+v", statuses)
+	}
+
+	commit, err := repo.Commit(ctx, "Add main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Subject != "Add main" || commit.Author != "Tester" || len(commit.Hash) != 40 {
+		t.Fatalf("unexpected commit: %+v", commit)
+	}
+	if _, err := repo.Commit(ctx, " "); err == nil {
+		t.Fatal("expected an error for an empty message")
+	}
+
+	writeGitFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	statuses, _ = repo.Status(ctx)
+	if statuses[1].Path != "main.go" || statuses[1].Status != GitModified || statuses[1].Staged {
+		t.Fatalf("expected an unstaged modification: %+v", statuses)
+	}
+	diff, err := repo.Diff(ctx, "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+func main() {}") {
+		t.Fatalf("diff should show the added line:\n%s", diff)
+	}
+	if all, _ := repo.Diff(ctx, ""); !strings.Contains(all, "+func main() {}") || !strings.Contains(all, "+package lib") {
+		t.Fatalf("diff of all files should include every change:\n%s", all)
+	}
+
+	if err := repo.Stage(ctx, []string{"main.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit(ctx, "Add func"); err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(ctx, "main.go", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "Add func" || commits[1].Subject != "Add main" {
+		t.Fatalf("unexpected history: %+v", commits)
+	}
+	if commits, _ := repo.Log(ctx, "", 1); len(commits) != 1 {
+		t.Fatalf("limit should bound the history: %+v", commits)
+	}
+}
+
+func TestGitIgnoresStateDir(t *testing.T) {
+	ctx := context.Background()
+	dir, repo := initGitWorkspace(t)
+	writeGitFile(t, dir, "notes.txt", "one\n")
+	fs := DirCheckpointFS{Root: dir}
+	if _, err := NewCheckpointStore(CheckpointStorePath(dir)).Create("test", "write_file", []string{"notes.txt"}, fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAuditLog(AuditLogPath(dir)).Append(AuditEntry{Tool: "write_file"}); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Path != "notes.txt" {
+		t.Fatalf("status lists the state directory: %+v", statuses)
+	}
+	if err := repo.Stage(ctx, []string{"."}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := repo.run(ctx, "diff", "--cached", "--name-only")
+	if err != nil || strings.TrimSpace(string(out)) != "work/notes.txt" {
+		t.Fatalf("staged %q, %v", out, err)
+	}
+}
+
+func TestGitNotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	repo := GitRepo{Dir: t.TempDir()}
+	if repo.IsRepo(context.Background()) {
+		t.Fatal("temp dir should not be a repository")
+	}
+	if _, err := repo.Status(context.Background()); err != ErrNotGitRepo {
+		t.Fatalf("expected ErrNotGitRepo, got %v", err)
+	}
+}
+
+func TestNotebookDiffByCell(t *testing.T) {
+	before := `{"version":1,"cells":[
+		{"id":"a","language":"markdown","source":"# Title"},
+		{"id":"b","language":"go","source":"x := 1\nfmt.Println(x)","output":"1"},
+		{"id":"c","language":"python","source":"print(2)"}]}`
+	after := `{"version":1,"cells":[
+		{"id":"a","language":"markdown","source":"# Title"},
+		{"id":"b","language":"go","source":"x := 2\nfmt.Println(x)","output":"2"},
+		{"id":"d","language":"go","source":"y := 3"}]}`
+
+	diff, err := NotebookDiff("nb.igonb", []byte(before), []byte(after))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## cell 2 [go] modified\n",
+		"-x := 1\n+x := 2\n",
+		"## cell 3 [python] removed\n",
+		"## cell 3 [go] added\n",
+		"+y := 3",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "Title") || strings.Contains(diff, "output") {
+		t.Errorf("unchanged cells and outputs should be skipped:\n%s", diff)
+	}
+
+	// ipynb sources are line lists and outputs alone do not count as cell changes
+	ipynbBefore := `{"cells":[{"cell_type":"code","source":["a = 1\n","a"],"outputs":[]}]}`
+	ipynbAfter := `{"cells":[{"cell_type":"code","source":["a = 1\n","a"],"outputs":[{"text":"1"}]}]}`
+	diff, err = NotebookDiff("nb.ipynb", []byte(ipynbBefore), []byte(ipynbAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "only outputs or metadata changed") {
+		t.Fatalf("expected an outputs-only note:\n%s", diff)
+	}
+
+	// Cells that gained IDs when a version 1 notebook was upgraded are the same cells
+	v1 := `{"version":1,"cells":[{"language":"go","source":"a := 1"},{"language":"go","source":"b := 2"}]}`
+	v2 := `{"version":2,"cells":[{"id":"x1","language":"go","source":"a := 1"},{"id":"x2","language":"go","source":"b := 3"}]}`
+	diff, err = NotebookDiff("nb.igonb", []byte(v1), []byte(v2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "## cell 2 [go] modified\n") || strings.Contains(diff, "a := 1") ||
+		strings.Contains(diff, "added") || strings.Contains(diff, "removed") {
+		t.Fatalf("unexpected diff after upgrade:\n%s", diff)
+	}
+}
+
+func TestServerGitTools(t *testing.T) {
+	dir, _ := initGitWorkspace(t)
+	writeGitFile(t, dir, "report.igonb", `{"version":1,"cells":[{"language":"go","source":"1"}]}`)
+
+	cfg := DefaultConfig()
+	var prompts []string
+	confirm := func(operation, details string) bool {
+		prompts = append(prompts, operation)
+		return false
+	}
+	s := NewServer(cfg, dir, confirm, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ctx := context.Background()
+
+	resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "git_status"})
+	if err != nil || !strings.Contains(resp.Content[0].Text, "?? report.igonb") {
+		t.Fatalf("git_status = %+v, %v", resp, err)
+	}
+
+	// Committing asks for permission by default
+	commitArgs := map[string]interface{}{"message": "Add report", "paths": []interface{}{"report.igonb"}}
+	resp, err = s.HandleRequest(ctx, &ToolRequest{Name: "git_commit", Arguments: commitArgs})
+	if err == nil || !resp.IsError || len(prompts) != 1 {
+		t.Fatalf("expected the commit to be cancelled after one prompt: %+v, %v, %v", resp, err, prompts)
+	}
+
+	cfg.GitCommit = PermissionAlways
+	resp, err = s.HandleRequest(ctx, &ToolRequest{Name: "git_commit", Arguments: commitArgs})
+	if err != nil || !strings.Contains(resp.Content[0].Text, "Add report") {
+		t.Fatalf("git_commit = %+v, %v", resp, err)
+	}
+
+	writeGitFile(t, dir, "report.igonb", `{"version":1,"cells":[{"language":"go","source":"2"}]}`)
+	resp, err = s.HandleRequest(ctx, &ToolRequest{Name: "git_diff", Arguments: map[string]interface{}{"path": "report.igonb"}})
+	if err != nil || !strings.Contains(resp.Content[0].Text, "## cell 1 [go] modified") {
+		t.Fatalf("git_diff = %+v, %v", resp, err)
+	}
+	resp, err = s.HandleRequest(ctx, &ToolRequest{Name: "git_log", Arguments: map[string]interface{}{"path": "report.igonb", "limit": 5.0}})
+	if err != nil || !strings.Contains(resp.Content[0].Text, "Tester: Add report") {
+		t.Fatalf("git_log = %+v, %v", resp, err)
+	}
+
+	cfg.GitCommit = PermissionDeny
+	if resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "git_stage", Arguments: map[string]interface{}{"paths": []interface{}{"report.igonb"}}}); err == nil || !resp.IsError {
+		t.Fatalf("staging should be denied: %+v", resp)
+	}
+	if resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "git_diff", Arguments: map[string]interface{}{"path": "../outside"}}); err == nil || !resp.IsError {
+		t.Fatalf("paths outside the workspace should be rejected: %+v", resp)
+	}
+}
+
+func TestGitConflictVersions(t *testing.T) {
+	ctx := context.Background()
+	dir, repo := initGitWorkspace(t)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		// A failing merge is expected; other commands must succeed
+		if out, err := cmd.CombinedOutput(); err != nil && args[0] != "merge" {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	writeGitFile(t, dir, "nb.igonb", "base\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "theirs")
+	writeGitFile(t, dir, "nb.igonb", "theirs\n")
+	git("commit", "-q", "-am", "theirs")
+	git("checkout", "-q", "-")
+	writeGitFile(t, dir, "nb.igonb", "ours\n")
+	git("commit", "-q", "-am", "ours")
+
+	if _, _, _, err := repo.ConflictVersions(ctx, "nb.igonb"); err == nil {
+		t.Fatal("expected an error for a file without conflicts")
+	}
+	git("merge", "-q", "theirs")
+
+	statuses, _ := repo.Status(ctx)
+	if len(statuses) != 1 || statuses[0].Status != GitConflicted {
+		t.Fatalf("expected a conflicted file: %+v", statuses)
+	}
+	base, ours, theirs, err := repo.ConflictVersions(ctx, "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(base) != "base\n" || string(ours) != "ours\n" || string(theirs) != "theirs\n" {
+		t.Fatalf("unexpected versions: %q %q %q", base, ours, theirs)
+	}
+}
@@ -2,10 +2,13 @@ package mcp
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -35,10 +38,31 @@ func NewNotebookOperations(
 	}
 }
 
+// NotebookVersion is the igonb format version written by the notebook tools
+const NotebookVersion = 2
+
 // Notebook represents a notebook structure
 type Notebook struct {
-	Version int            `json:"version"`
-	Cells   []NotebookCell `json:"cells"`
+	Version  int            `json:"version"`
+	Cells    []NotebookCell `json:"cells"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ensureCellIDs gives cells without an ID, or with the ID of an earlier cell, the
+// ID the igonb package would assign, derived from the language and source
+func (n *Notebook) ensureCellIDs() {
+	used := make(map[string]bool, len(n.Cells))
+	for i := range n.Cells {
+		id := n.Cells[i].ID
+		if id == "" || used[id] {
+			for attempt := 0; id == "" || used[id]; attempt++ {
+				sum := sha256.Sum256([]byte(n.Cells[i].Language + "\x00" + n.Cells[i].Source + "\x00" + strconv.Itoa(attempt)))
+				id = hex.EncodeToString(sum[:4])
+			}
+			n.Cells[i].ID = id
+		}
+		used[id] = true
+	}
 }
 
 // ReadNotebook reads and parses a notebook file
@@ -54,20 +78,19 @@ func (no *NotebookOperations) ReadNotebook(ctx context.Context, path string) (*N
 	if err := json.Unmarshal(content, &notebook); err != nil {
 		return nil, fmt.Errorf("error parsing notebook: %v", err)
 	}
+	if notebook.Version > NotebookVersion {
+		return nil, fmt.Errorf("unsupported notebook version: %d", notebook.Version)
+	}
+	notebook.Version = NotebookVersion
+	notebook.ensureCellIDs()
 
 	return &notebook, nil
 }
 
 // WriteNotebook writes a notebook to file
 func (no *NotebookOperations) WriteNotebook(ctx context.Context, path string, notebook *Notebook) error {
-	if no.config.NotebookModify == PermissionDeny {
-		return fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookModify == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Modify", fmt.Sprintf("Save notebook: %s", path)) {
-			return fmt.Errorf("cancelled by user")
-		}
+	if err := no.config.Check(ctx, OpNotebookModify, no.confirmFunc, "Notebook Modify", fmt.Sprintf("Save notebook: %s", path), path); err != nil {
+		return err
 	}
 
 	fullPath := filepath.Join(no.workspaceRoot, path)
@@ -86,20 +109,14 @@ func (no *NotebookOperations) WriteNotebook(ctx context.Context, path string, no
 
 // ModifyCell modifies a specific cell in a notebook
 func (no *NotebookOperations) ModifyCell(ctx context.Context, path string, cellIndex int, newSource string, newLanguage string) (*ToolResponse, error) {
-	if no.config.NotebookModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook modify permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookModify == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Modify", fmt.Sprintf("Modify cell %d in: %s", cellIndex, path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook modify cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookModify,
+		Paths:   []string{path},
+		Subject: "Notebook modify",
+		Title:   "Notebook Modify",
+		Details: fmt.Sprintf("Modify cell %d in: %s", cellIndex, path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -141,20 +158,14 @@ func (no *NotebookOperations) ModifyCell(ctx context.Context, path string, cellI
 
 // InsertCell inserts a new cell at the specified position
 func (no *NotebookOperations) InsertCell(ctx context.Context, path string, position int, language string, source string) (*ToolResponse, error) {
-	if no.config.NotebookModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook modify permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookModify == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Modify", fmt.Sprintf("Insert cell at position %d in: %s", position, path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook modify cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookModify,
+		Paths:   []string{path},
+		Subject: "Notebook modify",
+		Title:   "Notebook Modify",
+		Details: fmt.Sprintf("Insert cell at position %d in: %s", position, path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -179,6 +190,7 @@ func (no *NotebookOperations) InsertCell(ctx context.Context, path string, posit
 
 	// Insert cell at position
 	notebook.Cells = append(notebook.Cells[:position], append([]NotebookCell{newCell}, notebook.Cells[position:]...)...)
+	notebook.ensureCellIDs()
 
 	if err := no.WriteNotebook(ctx, path, notebook); err != nil {
 		return &ToolResponse{
@@ -199,20 +211,14 @@ func (no *NotebookOperations) InsertCell(ctx context.Context, path string, posit
 
 // ExecuteCell executes a specific cell
 func (no *NotebookOperations) ExecuteCell(ctx context.Context, path string, cellIndex int) (*ToolResponse, error) {
-	if no.config.NotebookExecute == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook execute permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookExecute == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Execute", fmt.Sprintf("Execute cell %d in: %s", cellIndex, path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook execute cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookExecute,
+		Paths:   []string{path},
+		Subject: "Notebook execute",
+		Title:   "Notebook Execute",
+		Details: fmt.Sprintf("Execute cell %d in: %s", cellIndex, path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -231,6 +237,14 @@ func (no *NotebookOperations) ExecuteCell(ctx context.Context, path string, cell
 	}
 
 	cell := notebook.Cells[cellIndex]
+	if reason := cellSkipReason(cell); reason != "" {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Cell %d not run (%s)", cellIndex, reason)}},
+		}, nil
+	}
+	if resp, err := no.authorizeShellCells(ctx, path, notebook.Cells, cellIndex, cellIndex); resp != nil {
+		return resp, err
+	}
 
 	if no.executeCellFunc == nil {
 		return &ToolResponse{
@@ -259,20 +273,14 @@ func (no *NotebookOperations) ExecuteCell(ctx context.Context, path string, cell
 
 // ExecuteCellAndAfter executes a cell and all subsequent cells
 func (no *NotebookOperations) ExecuteCellAndAfter(ctx context.Context, path string, startIndex int) (*ToolResponse, error) {
-	if no.config.NotebookExecute == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook execute permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookExecute == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Execute", fmt.Sprintf("Execute cell %d and after in: %s", startIndex, path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook execute cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookExecute,
+		Paths:   []string{path},
+		Subject: "Notebook execute",
+		Title:   "Notebook Execute",
+		Details: fmt.Sprintf("Execute cell %d and after in: %s", startIndex, path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -295,38 +303,19 @@ func (no *NotebookOperations) ExecuteCellAndAfter(ctx context.Context, path stri
 		_ = no.setActiveFileFunc(path)
 	}
 
-	var outputs []string
-	for i := startIndex; i < len(notebook.Cells); i++ {
-		cell := notebook.Cells[i]
-		output, err := no.executeCellFunc(cell.Language, cell.Source)
-		if err != nil {
-			outputs = append(outputs, fmt.Sprintf("Cell %d error: %v\n%s", i, err, output))
-		} else {
-			outputs = append(outputs, fmt.Sprintf("Cell %d output:\n%s", i, output))
-		}
-	}
-
-	return &ToolResponse{
-		Content: []ContentBlock{{Type: "text", Text: strings.Join(outputs, "\n\n")}},
-	}, nil
+	return no.runCells(ctx, path, notebook.Cells, startIndex, len(notebook.Cells)-1)
 }
 
 // ExecuteBeforeAndCell executes all cells before and including the specified cell
 func (no *NotebookOperations) ExecuteBeforeAndCell(ctx context.Context, path string, endIndex int) (*ToolResponse, error) {
-	if no.config.NotebookExecute == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook execute permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookExecute == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Execute", fmt.Sprintf("Execute cells up to %d in: %s", endIndex, path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook execute cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookExecute,
+		Paths:   []string{path},
+		Subject: "Notebook execute",
+		Title:   "Notebook Execute",
+		Details: fmt.Sprintf("Execute cells up to %d in: %s", endIndex, path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -349,15 +338,47 @@ func (no *NotebookOperations) ExecuteBeforeAndCell(ctx context.Context, path str
 		_ = no.setActiveFileFunc(path)
 	}
 
+	return no.runCells(ctx, path, notebook.Cells, 0, endIndex)
+}
+
+// runCells executes cells start..end, reporting progress after each cell.
+// Cancellation and timeouts are honored between cells.
+func (no *NotebookOperations) runCells(ctx context.Context, path string, cells []NotebookCell, start, end int) (*ToolResponse, error) {
+	if no.executeCellFunc == nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: "Cell execution function not available"}},
+			IsError: true,
+		}, fmt.Errorf("execution function not available")
+	}
+	if resp, err := no.authorizeShellCells(ctx, path, cells, start, end); resp != nil {
+		return resp, err
+	}
+
 	var outputs []string
-	for i := 0; i <= endIndex; i++ {
-		cell := notebook.Cells[i]
+	total := end - start + 1
+	for i := start; i <= end; i++ {
+		if err := ctx.Err(); err != nil {
+			outputs = append(outputs, fmt.Sprintf("Execution stopped before cell %d: %v", i, err))
+			return &ToolResponse{
+				Content: []ContentBlock{{Type: "text", Text: strings.Join(outputs, "\n\n")}},
+				IsError: true,
+			}, err
+		}
+		cell := cells[i]
+		if reason := cellSkipReason(cell); reason != "" {
+			outputs = append(outputs, fmt.Sprintf("Cell %d not run (%s)", i, reason))
+			ReportProgress(ctx, CellProgress(i, i-start+1, total, cell.Language, cell.Output, cell.Error))
+			continue
+		}
 		output, err := no.executeCellFunc(cell.Language, cell.Source)
+		errText := ""
 		if err != nil {
+			errText = err.Error()
 			outputs = append(outputs, fmt.Sprintf("Cell %d error: %v\n%s", i, err, output))
 		} else {
 			outputs = append(outputs, fmt.Sprintf("Cell %d output:\n%s", i, output))
 		}
+		ReportProgress(ctx, CellProgress(i, i-start+1, total, cell.Language, output, errText))
 	}
 
 	return &ToolResponse{
@@ -365,22 +386,62 @@ func (no *NotebookOperations) ExecuteBeforeAndCell(ctx context.Context, path str
 	}, nil
 }
 
-// ExecuteAllCells executes all cells in a notebook
-func (no *NotebookOperations) ExecuteAllCells(ctx context.Context, path string) (*ToolResponse, error) {
-	if no.config.NotebookExecute == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook execute permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
+// authorizeShellCells asks for the execute_shell permission when cells
+// start..end include shell cells that will run, in addition to notebook_execute
+func (no *NotebookOperations) authorizeShellCells(ctx context.Context, path string, cells []NotebookCell, start, end int) (*ToolResponse, error) {
+	var indices []int
+	for i := start; i <= end; i++ {
+		if IsShellLanguage(cells[i].Language) && cellSkipReason(cells[i]) == "" {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return nil, nil
 	}
+	return authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpExecuteShell,
+		Paths:   []string{path},
+		Subject: "Shell execution",
+		Title:   "Execute Shell",
+		Details: fmt.Sprintf("Run shell %s in: %s", FormatCellIndices(indices), path),
+	})
+}
 
-	if no.config.NotebookExecute == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Execute", fmt.Sprintf("Execute all cells in: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook execute cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+// FormatCellIndices describes cells by index as "cell 1" or "cells 1, 3", or
+// returns "" when there are none
+func FormatCellIndices(indices []int) string {
+	parts := make([]string, len(indices))
+	for i, index := range indices {
+		parts[i] = strconv.Itoa(index)
+	}
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return "cell " + parts[0]
+	}
+	return "cells " + strings.Join(parts, ", ")
+}
+
+// IsShellLanguage reports whether a cell language names a shell cell
+func IsShellLanguage(language string) bool {
+	switch strings.ToLower(strings.TrimSpace(language)) {
+	case "shell", "sh", "bash":
+		return true
+	}
+	return false
+}
+
+// ExecuteAllCells executes all cells in a notebook
+func (no *NotebookOperations) ExecuteAllCells(ctx context.Context, path string) (*ToolResponse, error) {
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookExecute,
+		Paths:   []string{path},
+		Subject: "Notebook execute",
+		Title:   "Notebook Execute",
+		Details: fmt.Sprintf("Execute all cells in: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	notebook, err := no.ReadNotebook(ctx, path)
@@ -396,37 +457,19 @@ func (no *NotebookOperations) ExecuteAllCells(ctx context.Context, path string)
 		_ = no.setActiveFileFunc(path)
 	}
 
-	var outputs []string
-	for i, cell := range notebook.Cells {
-		output, err := no.executeCellFunc(cell.Language, cell.Source)
-		if err != nil {
-			outputs = append(outputs, fmt.Sprintf("Cell %d error: %v\n%s", i, err, output))
-		} else {
-			outputs = append(outputs, fmt.Sprintf("Cell %d output:\n%s", i, output))
-		}
-	}
-
-	return &ToolResponse{
-		Content: []ContentBlock{{Type: "text", Text: strings.Join(outputs, "\n\n")}},
-	}, nil
+	return no.runCells(ctx, path, notebook.Cells, 0, len(notebook.Cells)-1)
 }
 
 // ConvertIPyNBToIgonb converts an ipynb file to igonb format
 func (no *NotebookOperations) ConvertIPyNBToIgonb(ctx context.Context, ipynbPath string, igonbPath string) (*ToolResponse, error) {
-	if no.config.NotebookModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Notebook convert permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if no.config.NotebookModify == PermissionAsk && no.confirmFunc != nil {
-		if !no.confirmFunc("Notebook Convert", fmt.Sprintf("Convert %s to %s", ipynbPath, igonbPath)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Notebook convert cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, no.config, no.confirmFunc, permissionPrompt{
+		Op:      OpNotebookModify,
+		Paths:   []string{ipynbPath, igonbPath},
+		Subject: "Notebook convert",
+		Title:   "Notebook Convert",
+		Details: fmt.Sprintf("Convert %s to %s", ipynbPath, igonbPath),
+	}); resp != nil {
+		return resp, err
 	}
 
 	fullIPyNBPath := filepath.Join(no.workspaceRoot, ipynbPath)
@@ -444,6 +487,7 @@ func (no *NotebookOperations) ConvertIPyNBToIgonb(ctx context.Context, ipynbPath
 	// Parse ipynb
 	var ipynb struct {
 		Cells []struct {
+			ID       string   `json:"id"`
 			CellType string   `json:"cell_type"`
 			Source   []string `json:"source"`
 		} `json:"cells"`
@@ -457,7 +501,7 @@ func (no *NotebookOperations) ConvertIPyNBToIgonb(ctx context.Context, ipynbPath
 
 	// Convert to igonb format
 	igonb := Notebook{
-		Version: 1,
+		Version: NotebookVersion,
 		Cells:   make([]NotebookCell, 0),
 	}
 
@@ -474,10 +518,12 @@ func (no *NotebookOperations) ConvertIPyNBToIgonb(ctx context.Context, ipynbPath
 
 		source := strings.Join(cell.Source, "")
 		igonb.Cells = append(igonb.Cells, NotebookCell{
+			ID:       cell.ID,
 			Language: language,
 			Source:   source,
 		})
 	}
+	igonb.ensureCellIDs()
 
 	// Write igonb file
 	igonbContent, err := json.MarshalIndent(igonb, "", "  ")
@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotebookCellIDsAndMigration(t *testing.T) {
+	root := t.TempDir()
+	v1 := `{"version":1,"cells":[
+		{"language":"go","source":"a"},
+		{"id":"keep","language":"python","source":"b","execution":{"count":3,"status":"ok"}},
+		{"id":"keep","language":"go","source":"c"}
+	],"metadata":{"createdAt":"2026-01-01T00:00:00Z"}}`
+	if err := os.WriteFile(filepath.Join(root, "nb.igonb"), []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.NotebookModify = PermissionAlways
+	ops := NewNotebookOperations(cfg, root, nil, nil, nil)
+
+	first, err := ops.ReadNotebook(context.Background(), "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != NotebookVersion {
+		t.Fatalf("expected version %d, got %d", NotebookVersion, first.Version)
+	}
+	ids := map[string]bool{}
+	for _, cell := range first.Cells {
+		if cell.ID == "" || ids[cell.ID] {
+			t.Fatalf("cell ids are not unique: %+v", first.Cells)
+		}
+		ids[cell.ID] = true
+	}
+	if first.Cells[1].ID != "keep" {
+		t.Fatalf("existing id was replaced: %q", first.Cells[1].ID)
+	}
+	second, err := ops.ReadNotebook(context.Background(), "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first.Cells {
+		if first.Cells[i].ID != second.Cells[i].ID {
+			t.Fatalf("generated ids are not stable: %q and %q", first.Cells[i].ID, second.Cells[i].ID)
+		}
+	}
+
+	if _, err := ops.InsertCell(context.Background(), "nb.igonb", 0, "markdown", "# title"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "nb.igonb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"version": 2`, `"id": "` + first.Cells[0].ID + `"`, `"count": 3`, `"createdAt"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("saved notebook lacks %s:\n%s", want, data)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "new.igonb"), []byte(`{"version":99,"cells":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.ReadNotebook(context.Background(), "new.igonb"); err == nil {
+		t.Fatal("expected an error for a newer notebook version")
+	}
+}
+
+func TestSetCellMetadataSkipsCells(t *testing.T) {
+	root := t.TempDir()
+	writeTestNotebook(t, root)
+	cfg := DefaultConfig()
+	cfg.NotebookModify = PermissionAlways
+	cfg.NotebookExecute = PermissionAlways
+	var ran []string
+	executeCell := func(language, code string) (string, error) {
+		ran = append(ran, code)
+		return "", nil
+	}
+	ops := NewNotebookOperations(cfg, root, nil, executeCell, nil)
+	ctx := context.Background()
+
+	if _, err := ParseCellMetadataArgs(map[string]interface{}{"path": "nb.igonb", "cell_index": float64(0), "metadata": map[string]interface{}{"skip": "yes"}}); err == nil {
+		t.Fatal("expected an error for a non-boolean skip")
+	}
+	if _, err := ParseCellMetadataArgs(map[string]interface{}{"path": "nb.igonb", "metadata": map[string]interface{}{"skip": true}}); err == nil {
+		t.Fatal("expected an error without a cell")
+	}
+
+	req, err := ParseCellMetadataArgs(map[string]interface{}{"path": "nb.igonb", "cell_index": float64(0), "metadata": map[string]interface{}{"skip": true, "timeout": "30s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.SetCellMetadata(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	nb, err := ops.ReadNotebook(ctx, "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = ParseCellMetadataArgs(map[string]interface{}{"path": "nb.igonb", "cell_id": nb.Cells[2].ID, "metadata": map[string]interface{}{"tags": []interface{}{"skip-execution"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ops.SetCellMetadata(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Content[0].Text, "skip-execution") {
+		t.Fatalf("unexpected response: %s", resp.Content[0].Text)
+	}
+
+	resp, err = ops.ExecuteAllCells(ctx, "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 || ran[0] != "b" {
+		t.Fatalf("expected only cell 1 to run, ran %v", ran)
+	}
+	if !strings.Contains(resp.Content[0].Text, "Cell 0 not run (skip)") {
+		t.Fatalf("skipped cell not reported:\n%s", resp.Content[0].Text)
+	}
+
+	// null removes a key
+	req, err = ParseCellMetadataArgs(map[string]interface{}{"path": "nb.igonb", "cell_index": float64(0), "metadata": map[string]interface{}{"skip": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.SetCellMetadata(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	nb, err = ops.ReadNotebook(ctx, "nb.igonb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := nb.Cells[0].Metadata["skip"]; ok || nb.Cells[0].Metadata["timeout"] != "30s" {
+		t.Fatalf("unexpected metadata: %v", nb.Cells[0].Metadata)
+	}
+}
+
+func TestShellCellsNeedExecuteShell(t *testing.T) {
+	root := t.TempDir()
+	nb := `{"version":2,"cells":[
+		{"id":"a","language":"go","source":"a"},
+		{"id":"b","language":"shell","source":"ls data/"},
+		{"id":"c","language":"shell","source":"rm -rf data/","metadata":{"skip":true}}
+	]}`
+	if err := os.WriteFile(filepath.Join(root, "nb.igonb"), []byte(nb), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.NotebookExecute = PermissionAlways
+	cfg.ExecuteShell = PermissionDeny
+	var ran []string
+	executeCell := func(language, code string) (string, error) {
+		ran = append(ran, code)
+		return "", nil
+	}
+	ops := NewNotebookOperations(cfg, root, nil, executeCell, nil)
+	ctx := context.Background()
+
+	if _, err := ops.ExecuteCell(ctx, "nb.igonb", 0); err != nil {
+		t.Fatalf("go cell should run without execute_shell: %v", err)
+	}
+	resp, err := ops.ExecuteCell(ctx, "nb.igonb", 1)
+	if err == nil || !resp.IsError || !strings.Contains(resp.Content[0].Text, "Shell execution permission denied") {
+		t.Fatalf("expected the shell cell to be denied, got %v", err)
+	}
+	if _, err := ops.ExecuteAllCells(ctx, "nb.igonb"); err == nil {
+		t.Fatal("expected running all cells to be denied")
+	}
+	if len(ran) != 1 {
+		t.Fatalf("no cell may run once a shell cell is denied, ran %v", ran)
+	}
+
+	// the skipped shell cell does not need the permission
+	var prompts []string
+	cfg.ExecuteShell = PermissionAsk
+	ops = NewNotebookOperations(cfg, root, func(operation, details string) bool {
+		prompts = append(prompts, details)
+		return true
+	}, executeCell, nil)
+	if _, err := ops.ExecuteAllCells(ctx, "nb.igonb"); err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || prompts[0] != "Run shell cell 1 in: nb.igonb" {
+		t.Fatalf("unexpected prompts %q", prompts)
+	}
+	if len(ran) != 3 || ran[2] != "ls data/" {
+		t.Fatalf("unexpected cells run %v", ran)
+	}
+}
+
+func TestFormatCellIndices(t *testing.T) {
+	for _, tc := range []struct {
+		indices []int
+		want    string
+	}{
+		{nil, ""},
+		{[]int{0}, "cell 0"},
+		{[]int{1, 3, 4}, "cells 1, 3, 4"},
+	} {
+		if got := FormatCellIndices(tc.indices); got != tc.want {
+			t.Errorf("FormatCellIndices(%v) = %q, want %q", tc.indices, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Operation identifies a permission-guarded MCP operation.
+// The names match the keys used in configuration files.
+type Operation string
+
+const (
+	OpFileEdit        Operation = "file_edit"
+	OpFileRename      Operation = "file_rename"
+	OpFileCreate      Operation = "file_create"
+	OpFileDelete      Operation = "file_delete"
+	OpExecuteGo       Operation = "execute_go"
+	OpExecutePython   Operation = "execute_python"
+	OpExecuteShell    Operation = "execute_shell"
+	OpNotebookModify  Operation = "notebook_modify"
+	OpNotebookExecute Operation = "notebook_execute"
+	OpWorkspaceOpen   Operation = "workspace_open"
+	OpWorkspaceSave   Operation = "workspace_save"
+	OpWorkspaceModify Operation = "workspace_modify"
+	OpGitCommit       Operation = "git_commit"
+)
+
+// Operations lists every permission-guarded operation
+var Operations = []Operation{
+	OpFileEdit, OpFileRename, OpFileCreate, OpFileDelete,
+	OpExecuteGo, OpExecutePython, OpExecuteShell,
+	OpNotebookModify, OpNotebookExecute,
+	OpWorkspaceOpen, OpWorkspaceSave, OpWorkspaceModify,
+	OpGitCommit,
+}
+
+// PathRule overrides the permission level of operations on paths matching Pattern.
+// Patterns are slash-separated globs relative to the workspace root; "**" matches
+// any number of directories. An empty Operations list applies the rule to every operation.
+type PathRule struct {
+	Pattern    string          `json:"pattern" yaml:"pattern"`
+	Operations []Operation     `json:"operations,omitempty" yaml:"operations,omitempty"`
+	Permission PermissionLevel `json:"permission" yaml:"permission"`
+}
+
+// String returns the configuration file name of the permission level
+func (p PermissionLevel) String() string {
+	switch p {
+	case PermissionAlways:
+		return "always"
+	case PermissionAsk:
+		return "ask"
+	case PermissionDeny:
+		return "deny"
+	default:
+		return fmt.Sprintf("PermissionLevel(%d)", int(p))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p PermissionLevel) MarshalText() ([]byte, error) {
+	switch p {
+	case PermissionAlways, PermissionAsk, PermissionDeny:
+		return []byte(p.String()), nil
+	default:
+		return nil, fmt.Errorf("invalid permission level: %d", int(p))
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *PermissionLevel) UnmarshalText(text []byte) error {
+	level, err := ParsePermissionLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*p = level
+	return nil
+}
+
+// ParsePermissionLevel parses "always", "ask" or "deny" (case-insensitive)
+func ParsePermissionLevel(s string) (PermissionLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "always", "allow":
+		return PermissionAlways, nil
+	case "ask":
+		return PermissionAsk, nil
+	case "deny":
+		return PermissionDeny, nil
+	default:
+		return PermissionAsk, fmt.Errorf("invalid permission level: %q", s)
+	}
+}
+
+// Level returns the base permission level configured for an operation
+func (c *Config) Level(op Operation) PermissionLevel {
+	if field := c.field(op); field != nil {
+		return *field
+	}
+	return PermissionDeny
+}
+
+// SetLevel sets the base permission level of an operation
+func (c *Config) SetLevel(op Operation, level PermissionLevel) error {
+	field := c.field(op)
+	if field == nil {
+		return fmt.Errorf("unknown operation: %s", op)
+	}
+	*field = level
+	return nil
+}
+
+func (c *Config) field(op Operation) *PermissionLevel {
+	switch op {
+	case OpFileEdit:
+		return &c.FileEdit
+	case OpFileRename:
+		return &c.FileRename
+	case OpFileCreate:
+		return &c.FileCreate
+	case OpFileDelete:
+		return &c.FileDelete
+	case OpExecuteGo:
+		return &c.ExecuteGo
+	case OpExecutePython:
+		return &c.ExecutePython
+	case OpExecuteShell:
+		return &c.ExecuteShell
+	case OpNotebookModify:
+		return &c.NotebookModify
+	case OpNotebookExecute:
+		return &c.NotebookExecute
+	case OpWorkspaceOpen:
+		return &c.WorkspaceOpen
+	case OpWorkspaceSave:
+		return &c.WorkspaceSave
+	case OpWorkspaceModify:
+		return &c.WorkspaceModify
+	case OpGitCommit:
+		return &c.GitCommit
+	default:
+		return nil
+	}
+}
+
+// PermissionFor resolves the effective permission level of op on the given paths.
+// For each path the first matching rule wins; across paths the most restrictive level applies.
+func (c *Config) PermissionFor(op Operation, paths ...string) PermissionLevel {
+	base := c.Level(op)
+	if len(paths) == 0 || len(c.PathRules) == 0 {
+		return base
+	}
+	result := PermissionAlways
+	for _, p := range paths {
+		level := base
+		for _, rule := range c.PathRules {
+			if rule.appliesTo(op) && MatchPathPattern(rule.Pattern, p) {
+				level = rule.Permission
+				break
+			}
+		}
+		if level > result {
+			result = level
+		}
+	}
+	return result
+}
+
+func (r PathRule) appliesTo(op Operation) bool {
+	if len(r.Operations) == 0 {
+		return true
+	}
+	for _, o := range r.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that rule patterns are well-formed and operations are known
+func (c *Config) Validate() error {
+	for i, rule := range c.PathRules {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("path rule %d: empty pattern", i+1)
+		}
+		if err := checkPathPattern(rule.Pattern); err != nil {
+			return fmt.Errorf("path rule %d: invalid pattern %q: %v", i+1, rule.Pattern, err)
+		}
+		for _, op := range rule.Operations {
+			if c.field(op) == nil {
+				return fmt.Errorf("path rule %d: unknown operation %q", i+1, op)
+			}
+		}
+		if _, err := rule.Permission.MarshalText(); err != nil {
+			return fmt.Errorf("path rule %d: %v", i+1, err)
+		}
+	}
+	return validateTimeouts(c.Timeouts)
+}
+
+// MatchPathPattern reports whether a workspace-relative path matches a glob pattern.
+// "*" and "?" match within a single path segment, "**" matches zero or more segments.
+// The whole path must match, so "*.go" only matches files at the top level.
+// MatchGlob uses the same matcher for search include and exclude globs.
+func MatchPathPattern(pattern, name string) bool {
+	patternSegs := strings.Split(normalizeRulePath(pattern), "/")
+	nameSegs := strings.Split(normalizeRulePath(name), "/")
+	return matchSegments(patternSegs, nameSegs)
+}
+
+// checkPathPattern reports a malformed segment of a glob pattern
+func checkPathPattern(pattern string) error {
+	for _, seg := range strings.Split(normalizeRulePath(pattern), "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+func normalizeRulePath(p string) string {
+	p = strings.ReplaceAll(strings.TrimSpace(p), "\\", "/")
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// permissionPrompt describes a guarded operation for deny/confirm handling
+type permissionPrompt struct {
+	Op    Operation
+	Paths []string
+	// Subject prefixes the denial messages, e.g. "File edit"
+	Subject string
+	// Title and Details are passed to the confirmation callback
+	Title   string
+	Details string
+}
+
+// Errors returned by Check
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrCancelledByUser  = errors.New("cancelled by user")
+)
+
+// Check resolves the permission of op on paths and asks confirmFunc when the level is Ask.
+// It returns nil when the operation may proceed. A nil confirmFunc approves Ask operations.
+// The outcome is recorded in the audit recorder attached to ctx, if any.
+func (c *Config) Check(ctx context.Context, op Operation, confirmFunc func(operation, details string) bool, title, details string, paths ...string) error {
+	level := c.PermissionFor(op, paths...)
+	decision := AuditDecision{Operation: op, Paths: paths, Level: level.String(), Decision: "allowed"}
+	defer func() { recordDecision(ctx, decision) }()
+
+	switch level {
+	case PermissionDeny:
+		decision.Decision = "denied"
+		return ErrPermissionDenied
+	case PermissionAsk:
+		if confirmFunc != nil {
+			if !confirmFunc(title, details) {
+				decision.Decision = "rejected"
+				return ErrCancelledByUser
+			}
+			decision.Decision = "approved"
+		}
+	}
+	return nil
+}
+
+// authorize applies the effective permission level of a prompt, asking for
+// confirmation when required. A non-nil response means the operation must not run.
+func authorize(ctx context.Context, config *Config, confirmFunc func(operation, details string) bool, p permissionPrompt) (*ToolResponse, error) {
+	err := config.Check(ctx, p.Op, confirmFunc, p.Title, p.Details, p.Paths...)
+	switch {
+	case err == nil:
+		return nil, nil
+	case errors.Is(err, ErrPermissionDenied):
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: p.Subject + " permission denied"}},
+			IsError: true,
+		}, err
+	default:
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: p.Subject + " cancelled by user"}},
+			IsError: true,
+		}, err
+	}
+}
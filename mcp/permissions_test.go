@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchPathPattern(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"data/raw/**", "data/raw/a.csv", true},
+		{"data/raw/**", "data/raw/2024/01/a.csv", true},
+		{"data/raw/**", "data/raw", true},
+		{"data/raw/**", "data/clean/a.csv", false},
+		{"scratch/**", "./scratch/x.go", true},
+		{"**/*.ipynb", "notebooks/a/b.ipynb", true},
+		{"**/*.ipynb", "b.ipynb", true},
+		{"*.go", "sub/main.go", false},
+		{"src/*/main.go", "src/app/main.go", true},
+	}
+	for _, c := range cases {
+		if got := MatchPathPattern(c.pattern, c.name); got != c.want {
+			t.Errorf("MatchPathPattern(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestPermissionForPathRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PathRules = []PathRule{
+		{Pattern: "data/raw/**", Operations: []Operation{OpFileEdit, OpFileDelete}, Permission: PermissionDeny},
+		{Pattern: "scratch/**", Permission: PermissionAlways},
+	}
+
+	if got := cfg.PermissionFor(OpFileEdit, "data/raw/a.csv"); got != PermissionDeny {
+		t.Fatalf("expected deny, got %v", got)
+	}
+	if got := cfg.PermissionFor(OpFileCreate, "data/raw/a.csv"); got != PermissionAsk {
+		t.Fatalf("rule should not apply to create, got %v", got)
+	}
+	if got := cfg.PermissionFor(OpFileDelete, "scratch/tmp.txt"); got != PermissionAlways {
+		t.Fatalf("expected always, got %v", got)
+	}
+	// Renaming out of scratch into raw data is as restrictive as its strictest path
+	if got := cfg.PermissionFor(OpFileEdit, "scratch/a.csv", "data/raw/a.csv"); got != PermissionDeny {
+		t.Fatalf("expected deny across paths, got %v", got)
+	}
+}
+
+func TestWriteFileHonorsPathRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PathRules = []PathRule{{Pattern: "data/raw/**", Permission: PermissionDeny}}
+	written := false
+	write := func(path, content string) error { written = true; return nil }
+	fo := NewFileOperations(cfg, ".", func(string, string) bool { return true }, nil, nil, write, nil, nil, nil, nil)
+
+	res, err := fo.WriteFile(context.Background(), "data/raw/a.csv", "x")
+	if err == nil || !res.IsError || written {
+		t.Fatalf("expected write to be denied")
+	}
+	if _, err := fo.WriteFile(context.Background(), "notes.txt", "x"); err != nil || !written {
+		t.Fatalf("expected write outside rule to succeed: %v", err)
+	}
+}
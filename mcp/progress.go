@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Progress is a progress update of a long-running tool call
+type Progress struct {
+	Progress float64 `json:"progress"`
+	Total    float64 `json:"total,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// ProgressNotification is written to the stdio stream before the response of a
+// request that carries a progress token
+type ProgressNotification struct {
+	ProgressToken any `json:"progressToken"`
+	Progress
+}
+
+// CancelledMethod is the notification that cancels a running stdio request,
+// as in {"method": "notifications/cancelled", "params": {"requestId": 1}}
+const CancelledMethod = "notifications/cancelled"
+
+// stdioMessage is a line of the stdio stream: a tool request, or a
+// notification when Method is set
+type stdioMessage struct {
+	ToolRequest
+	Method string `json:"method,omitempty"`
+	Params struct {
+		RequestID any    `json:"requestId"`
+		Reason    string `json:"reason,omitempty"`
+	} `json:"params"`
+}
+
+// stdioResponse is a tool response with the ID of its request
+type stdioResponse struct {
+	ID any `json:"id,omitempty"`
+	*ToolResponse
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls report progress to fn
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress sends a progress update if the caller asked for progress
+func ReportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok && fn != nil {
+		fn(p)
+	}
+}
+
+// maxProgressOutput limits the partial output included in progress messages
+const maxProgressOutput = 500
+
+// CellProgress describes the done-th of total finished notebook cells, including the tail of its output
+func CellProgress(index, done, total int, language, output, errText string) Progress {
+	status := "done"
+	if errText != "" {
+		status = "failed"
+		output = strings.TrimSpace(output + "\n" + errText)
+	}
+	msg := fmt.Sprintf("Cell %d (%d/%d, %s) %s", index, done, total, language, status)
+	if output = strings.TrimSpace(output); output != "" {
+		if len(output) > maxProgressOutput {
+			output = "..." + output[len(output)-maxProgressOutput:]
+		}
+		msg += ":\n" + output
+	}
+	return Progress{Progress: float64(done), Total: float64(total), Message: msg}
+}
+
+// DefaultToolTimeoutKey is the Timeouts key applied to tools without their own entry
+const DefaultToolTimeoutKey = "default"
+
+// DefaultToolTimeout is the built-in timeout of a tool; code execution and
+// package installs may run much longer than file operations
+func DefaultToolTimeout(tool string) time.Duration {
+	switch {
+	case strings.HasPrefix(tool, "execute_"), tool == "pip_install":
+		return 10 * time.Minute
+	case tool == "open_workspace", tool == "save_workspace", tool == "save_all_files",
+		tool == "save_temp_workspace", tool == "save_changes", tool == "import_file_to_workspace":
+		return time.Minute
+	}
+	return 30 * time.Second
+}
+
+// ToolTimeout returns how long a tool call may run. Entries of Timeouts override the
+// built-in defaults per tool name, with "default" applying to the other tools; a
+// duration of 0 disables the timeout.
+func (c *Config) ToolTimeout(tool string) time.Duration {
+	if c != nil {
+		for _, key := range []string{tool, DefaultToolTimeoutKey} {
+			if value, ok := c.Timeouts[key]; ok {
+				if d, err := time.ParseDuration(value); err == nil {
+					return d
+				}
+			}
+		}
+	}
+	return DefaultToolTimeout(tool)
+}
+
+// WithToolTimeout bounds ctx by the timeout of a tool
+func (c *Config) WithToolTimeout(ctx context.Context, tool string) (context.Context, context.CancelFunc) {
+	if d := c.ToolTimeout(tool); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+func validateTimeouts(timeouts map[string]string) error {
+	for tool, value := range timeouts {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("timeout of %s: invalid duration %q", tool, value)
+		}
+		if d < 0 {
+			return fmt.Errorf("timeout of %s: negative duration %q", tool, value)
+		}
+	}
+	return nil
+}
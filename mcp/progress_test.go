@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolTimeout(t *testing.T) {
+	cfg, err := ParseConfig([]byte("timeouts:\n  execute_all_cells: 45m\n  default: 90s\n"), true)
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if d := cfg.ToolTimeout("execute_all_cells"); d != 45*time.Minute {
+		t.Fatalf("execute_all_cells timeout = %s", d)
+	}
+	if d := cfg.ToolTimeout("read_file"); d != 90*time.Second {
+		t.Fatalf("read_file timeout = %s", d)
+	}
+	if d := DefaultConfig().ToolTimeout("execute_go_file"); d != 10*time.Minute {
+		t.Fatalf("default execute_go_file timeout = %s", d)
+	}
+	if d := DefaultConfig().ToolTimeout("read_file"); d != 30*time.Second {
+		t.Fatalf("default read_file timeout = %s", d)
+	}
+
+	cfg.Timeouts = map[string]string{"execute_go_file": "0"}
+	ctx, cancel := cfg.WithToolTimeout(context.Background(), "execute_go_file")
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatalf("a zero timeout should disable the deadline")
+	}
+
+	for _, bad := range []string{"timeouts:\n  read_file: soon\n", "timeouts:\n  read_file: -5s\n"} {
+		if _, err := ParseConfig([]byte(bad), true); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func writeTestNotebook(t *testing.T, root string) {
+	t.Helper()
+	nb := Notebook{Version: 1, Cells: []NotebookCell{
+		{Language: "go", Source: "a"},
+		{Language: "python", Source: "b"},
+		{Language: "go", Source: "c"},
+	}}
+	data, _ := json.Marshal(nb)
+	if err := os.WriteFile(filepath.Join(root, "nb.igonb"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeReportsCellProgress(t *testing.T) {
+	root := t.TempDir()
+	writeTestNotebook(t, root)
+	cfg := DefaultConfig()
+	cfg.NotebookExecute = PermissionAlways
+	executeCell := func(language, code string) (string, error) { return "out " + code, nil }
+	s := NewServer(cfg, root, nil, nil, nil, nil, executeCell, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil)
+
+	input := `{"name":"execute_all_cells","arguments":{"path":"nb.igonb"},"progressToken":"tok"}` + "\n"
+	var output bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 3 progress lines and a response, got %d:\n%s", len(lines), output.String())
+	}
+	var progress ProgressNotification
+	if err := json.Unmarshal([]byte(lines[1]), &progress); err != nil {
+		t.Fatal(err)
+	}
+	if progress.ProgressToken != "tok" || progress.Progress.Progress != 2 || progress.Total != 3 || !strings.Contains(progress.Message, "out b") {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(lines[3]), &resp); err != nil || resp.IsError {
+		t.Fatalf("unexpected response: %s (%v)", lines[3], err)
+	}
+}
+
+func TestExecuteAllCellsStopsWhenCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeTestNotebook(t, root)
+	cfg := DefaultConfig()
+	cfg.NotebookExecute = PermissionAlways
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := 0
+	executeCell := func(language, code string) (string, error) {
+		ran++
+		cancel()
+		return "", nil
+	}
+	s := NewServer(cfg, root, nil, nil, nil, nil, executeCell, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil)
+
+	resp, err := s.HandleRequest(ctx, &ToolRequest{Name: "execute_all_cells", Arguments: map[string]interface{}{"path": "nb.igonb"}})
+	if err == nil || resp == nil || !resp.IsError {
+		t.Fatalf("expected a cancelled response, got %+v (%v)", resp, err)
+	}
+	if ran != 1 || !strings.Contains(resp.Content[0].Text, "Execution stopped before cell 1") {
+		t.Fatalf("unexpected run: ran=%d text=%s", ran, resp.Content[0].Text)
+	}
+}
+
+func TestServeCancelsRequestByID(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ExecuteGo = PermissionAlways
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	executeGo := func(code string, colorBG string) string {
+		if code == "block" {
+			close(started)
+			<-stopped
+			return "stopped"
+		}
+		return "ran " + code
+	}
+	s := NewServer(cfg, t.TempDir(), nil, executeGo, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil)
+	var cancelled []string
+	s.SetCancelFunc(func(tool string) {
+		cancelled = append(cancelled, tool)
+		close(stopped)
+	})
+
+	input, writer := io.Pipe()
+	var output bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background(), input, &output) }()
+
+	writer.Write([]byte(`{"id":1,"name":"execute_go_code","arguments":{"code":"block"}}` + "\n"))
+	<-started
+	// The blocked call does not keep other requests from being read and answered
+	writer.Write([]byte(`{"id":"two","name":"execute_go_code","arguments":{"code":"x"}}` + "\n"))
+	writer.Write([]byte(`{"method":"notifications/cancelled","params":{"requestId":1}}` + "\n"))
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation did not reach the running call")
+	}
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	responses := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var resp struct {
+			ID any `json:"id"`
+			ToolResponse
+		}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatal(err)
+		}
+		responses[fmt.Sprint(resp.ID)] = resp.Content[0].Text
+	}
+	if len(responses) != 2 || !strings.Contains(responses["two"], "ran x") || !strings.Contains(responses["1"], "stopped") {
+		t.Fatalf("unexpected responses: %v", responses)
+	}
+	if len(cancelled) != 1 || cancelled[0] != "execute_go_code" {
+		t.Fatalf("cancelled = %v", cancelled)
+	}
+}
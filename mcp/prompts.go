@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PromptArgument describes an argument of a prompt template
+type PromptArgument struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// PromptTemplate is a reusable prompt offered to MCP clients
+type PromptTemplate struct {
+	Name        string
+	Title       string
+	Description string
+	Arguments   []PromptArgument
+	// ResourceArg names the argument holding a workspace path whose resource is attached to the prompt
+	ResourceArg string
+	// ResourceKind is the kind of resource attached for ResourceArg
+	ResourceKind string
+	render       func(args map[string]string) string
+}
+
+// Prompts returns the prompt templates offered by Idensyra
+func Prompts() []PromptTemplate {
+	return []PromptTemplate{
+		{
+			Name:        "analyze_csv",
+			Title:       "Analyze a CSV file with insyra",
+			Description: "Explore a CSV file in the workspace with insyra in a Go notebook cell",
+			Arguments: []PromptArgument{
+				{Name: "path", Description: "Workspace path of the CSV file", Required: true},
+				{Name: "question", Description: "What you want to learn from the data (optional)"},
+			},
+			ResourceArg:  "path",
+			ResourceKind: ResourceKindFile,
+			render: func(args map[string]string) string {
+				var sb strings.Builder
+				fmt.Fprintf(&sb, "Analyze the CSV file `%s` from the Idensyra workspace using the insyra library in Go.\n\n", args["path"])
+				sb.WriteString("1. Load it with `insyra.ReadCSV_File` into a DataTable and report its shape, column names and types.\n")
+				sb.WriteString("2. Summarize each numeric column (count, mean, standard deviation, min, max) and note missing values.\n")
+				sb.WriteString("3. Point out anything unusual, such as outliers, constant columns or inconsistent formats.\n")
+				if q := strings.TrimSpace(args["question"]); q != "" {
+					fmt.Fprintf(&sb, "4. Answer this question about the data: %s\n", q)
+				}
+				sb.WriteString("\nPut the code in cells of an .igonb notebook (insert_cell, then execute_cell) so the user can rerun it. The file contents are attached.")
+				return sb.String()
+			},
+		},
+		{
+			Name:        "explain_notebook",
+			Title:       "Explain a notebook",
+			Description: "Walk through a notebook cell by cell, including its latest outputs",
+			Arguments: []PromptArgument{
+				{Name: "path", Description: "Workspace path of the .igonb or .ipynb notebook", Required: true},
+			},
+			ResourceArg:  "path",
+			ResourceKind: ResourceKindNotebook,
+			render: func(args map[string]string) string {
+				return fmt.Sprintf("Explain the notebook `%s` from the Idensyra workspace. "+
+					"Describe what each cell does, how data flows between Go and Python cells, and what the latest outputs show. "+
+					"Flag cells whose outputs look stale or failed. The cells and their outputs are attached.", args["path"])
+			},
+		},
+		{
+			Name:        "fix_cell_error",
+			Title:       "Fix a failing notebook cell",
+			Description: "Diagnose the error of a notebook cell and propose a fix",
+			Arguments: []PromptArgument{
+				{Name: "path", Description: "Workspace path of the notebook", Required: true},
+				{Name: "cell_index", Description: "Index of the failing cell (0-based)", Required: true},
+			},
+			ResourceArg:  "path",
+			ResourceKind: ResourceKindNotebook,
+			render: func(args map[string]string) string {
+				return fmt.Sprintf("Cell %s of the notebook `%s` fails. Using the attached cells and outputs, explain the cause of the error "+
+					"and fix the cell with modify_cell, then run it again with execute_cell to confirm the fix.", args["cell_index"], args["path"])
+			},
+		},
+	}
+}
+
+// FindPrompt returns the prompt template with the given name
+func FindPrompt(name string) (PromptTemplate, bool) {
+	for _, p := range Prompts() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PromptTemplate{}, false
+}
+
+// Render fills in the template after checking required arguments
+func (p PromptTemplate) Render(args map[string]string) (string, error) {
+	for _, arg := range p.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			return "", fmt.Errorf("prompt %s requires argument %q", p.Name, arg.Name)
+		}
+	}
+	if p.ResourceArg != "" {
+		if _, err := safeCleanRelativePath(args[p.ResourceArg]); err != nil {
+			return "", err
+		}
+	}
+	return p.render(args), nil
+}
@@ -0,0 +1,263 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PythonEnvRelPath is the workspace-relative directory of the workspace Python
+// environment, a virtual environment used by .py files and igonb Python cells
+const PythonEnvRelPath = ".idensyra/venv"
+
+// PythonLockFile is the workspace file that pins the package versions of the
+// environment; when it exists the environment is created from it
+const PythonLockFile = "requirements.lock"
+
+// Files the packages of a workspace environment are read from, in order of preference
+const (
+	PythonRequirementsFile = "requirements.txt"
+	PythonProjectFile      = "pyproject.toml"
+)
+
+// PythonEnv manages the virtual environment of a workspace with the python and
+// pip inside it
+type PythonEnv struct {
+	// Root is the workspace directory
+	Root string
+}
+
+// PythonInstallOptions controls where pip installs packages from
+type PythonInstallOptions struct {
+	// WheelDir installs offline from the wheels in this directory instead of
+	// the package index
+	WheelDir string
+}
+
+// Dir returns the directory of the environment
+func (p PythonEnv) Dir() string {
+	return filepath.Join(p.Root, filepath.FromSlash(PythonEnvRelPath))
+}
+
+// Python returns the interpreter of the environment
+func (p PythonEnv) Python() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(p.Dir(), "Scripts", "python.exe")
+	}
+	return filepath.Join(p.Dir(), "bin", "python")
+}
+
+// Exists reports whether the environment has been created
+func (p PythonEnv) Exists() bool {
+	info, err := os.Stat(p.Python())
+	return err == nil && !info.IsDir()
+}
+
+// RequirementsFile returns the workspace file the environment is created from:
+// PythonLockFile, PythonRequirementsFile or PythonProjectFile, or "" when the
+// workspace has none of them
+func (p PythonEnv) RequirementsFile() string {
+	for _, name := range []string{PythonLockFile, PythonRequirementsFile, PythonProjectFile} {
+		if info, err := os.Stat(filepath.Join(p.Root, name)); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
+
+// Create creates the environment with the base interpreter if it does not
+// exist yet and installs the requirements of the workspace into it
+func (p PythonEnv) Create(ctx context.Context, base string, opts PythonInstallOptions) (string, error) {
+	var log strings.Builder
+	if !p.Exists() {
+		if base == "" {
+			return "", errors.New("no Python interpreter to create the environment with")
+		}
+		if err := os.MkdirAll(filepath.Dir(p.Dir()), 0755); err != nil {
+			return "", fmt.Errorf("failed to create environment directory: %w", err)
+		}
+		out, err := p.command(ctx, base, "-m", "venv", p.Dir())
+		log.Write(out)
+		if err != nil {
+			return log.String(), fmt.Errorf("failed to create environment: %w", err)
+		}
+		// Keep the environment out of git, as python 3.13 does by itself
+		if err := os.WriteFile(filepath.Join(p.Dir(), ".gitignore"), []byte("*\n"), 0644); err != nil {
+			return log.String(), fmt.Errorf("failed to write environment .gitignore: %w", err)
+		}
+		fmt.Fprintf(&log, "Created %s\n", PythonEnvRelPath)
+	}
+	out, err := p.Sync(ctx, opts)
+	log.WriteString(out)
+	return log.String(), err
+}
+
+// Sync installs the packages listed in the requirements file of the workspace
+func (p PythonEnv) Sync(ctx context.Context, opts PythonInstallOptions) (string, error) {
+	switch name := p.RequirementsFile(); name {
+	case "":
+		return "No " + PythonRequirementsFile + " or " + PythonProjectFile + " to install\n", nil
+	case PythonProjectFile:
+		packages, err := p.projectDependencies(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(packages) == 0 {
+			return "No dependencies in " + PythonProjectFile + "\n", nil
+		}
+		return p.Install(ctx, packages, opts)
+	default:
+		return p.pip(ctx, append([]string{"install"}, append(opts.args(), "-r", name)...)...)
+	}
+}
+
+// projectDependencies reads the [project] dependencies of pyproject.toml with
+// the tomllib module of the environment
+func (p PythonEnv) projectDependencies(ctx context.Context) ([]string, error) {
+	const script = `import json, sys
+try:
+    import tomllib
+except ImportError:
+    sys.exit("reading pyproject.toml needs Python 3.11 or later")
+with open("pyproject.toml", "rb") as f:
+    project = tomllib.load(f).get("project", {})
+print(json.dumps(project.get("dependencies", [])))
+`
+	out, err := p.command(ctx, p.Python(), "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", PythonProjectFile, err)
+	}
+	var packages []string
+	if err := json.Unmarshal(out, &packages); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", PythonProjectFile, err)
+	}
+	return packages, nil
+}
+
+// Install installs packages into the environment
+func (p PythonEnv) Install(ctx context.Context, packages []string, opts PythonInstallOptions) (string, error) {
+	if len(packages) == 0 {
+		return "", errors.New("no packages given")
+	}
+	return p.pip(ctx, append(append([]string{"install"}, opts.args()...), packages...)...)
+}
+
+// Uninstall removes packages from the environment
+func (p PythonEnv) Uninstall(ctx context.Context, packages ...string) (string, error) {
+	if len(packages) == 0 {
+		return "", errors.New("no packages given")
+	}
+	return p.pip(ctx, append([]string{"uninstall", "-y"}, packages...)...)
+}
+
+// List returns the installed packages of the environment by name with their versions
+func (p PythonEnv) List(ctx context.Context) (map[string]string, error) {
+	out, err := p.pip(ctx, "list", "--format=json")
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pip list: %w", err)
+	}
+	packages := make(map[string]string, len(list))
+	for _, pkg := range list {
+		packages[pkg.Name] = pkg.Version
+	}
+	return packages, nil
+}
+
+// Freeze returns the installed packages of the environment pinned to their
+// versions, one requirement per line in name order
+func (p PythonEnv) Freeze(ctx context.Context) (string, error) {
+	out, err := p.pip(ctx, "freeze", "--exclude-editable")
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return strings.ToLower(lines[i]) < strings.ToLower(lines[j]) })
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Lock writes the installed packages of the environment to PythonLockFile and
+// returns its path
+func (p PythonEnv) Lock(ctx context.Context) (string, error) {
+	frozen, err := p.Freeze(ctx)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(p.Root, PythonLockFile)
+	content := "# Installed packages of " + PythonEnvRelPath + ", written by Idensyra\n" + frozen
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", PythonLockFile, err)
+	}
+	return path, nil
+}
+
+// Remove deletes the environment
+func (p PythonEnv) Remove() error {
+	if err := os.RemoveAll(p.Dir()); err != nil {
+		return fmt.Errorf("failed to remove environment: %w", err)
+	}
+	return nil
+}
+
+func (o PythonInstallOptions) args() []string {
+	if o.WheelDir == "" {
+		return nil
+	}
+	return []string{"--no-index", "--find-links", o.WheelDir}
+}
+
+// pip runs the pip of the environment in the workspace directory
+func (p PythonEnv) pip(ctx context.Context, args ...string) (string, error) {
+	if !p.Exists() {
+		return "", fmt.Errorf("no Python environment in %s", PythonEnvRelPath)
+	}
+	out, err := p.command(ctx, p.Python(), append([]string{"-m", "pip"}, args...)...)
+	if err != nil {
+		return string(out), fmt.Errorf("pip %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// command runs a program in the workspace directory and returns its standard
+// output, or its last error lines as the error
+func (p PythonEnv) command(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = p.Root
+	cmd.Env = append(os.Environ(), "PIP_DISABLE_PIP_VERSION_CHECK=1", "PIP_NO_INPUT=1", "PYTHONUTF8=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return stdout.Bytes(), ctxErr
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if lines := strings.Split(msg, "\n"); len(lines) > 5 {
+			msg = strings.Join(lines[len(lines)-5:], "\n")
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.Bytes(), errors.New(msg)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newPythonEnvWorkspace returns a workspace whose environment is created with
+// the python3 on PATH, skipping the test when there is none
+func newPythonEnvWorkspace(t *testing.T) (string, string) {
+	t.Helper()
+	base, err := exec.LookPath("python3")
+	if err != nil {
+		if base, err = exec.LookPath("python"); err != nil {
+			t.Skip("python is not installed")
+		}
+	}
+	if out, err := exec.Command(base, "-c", "import venv, ensurepip").CombinedOutput(); err != nil {
+		t.Skipf("python cannot create environments: %s", out)
+	}
+	return t.TempDir(), base
+}
+
+// writeWheel writes a wheel of a one-module package to dir
+func writeWheel(t *testing.T, dir, name, version string) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, name+"-"+version+"-py3-none-any.whl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	info := name + "-" + version + ".dist-info/"
+	for path, content := range map[string]string{
+		name + ".py":      "VERSION = \"" + version + "\"\n",
+		info + "METADATA": "Metadata-Version: 2.1\nName: " + name + "\nVersion: " + version + "\n",
+		info + "WHEEL":    "Wheel-Version: 1.0\nGenerator: test\nRoot-Is-Purelib: true\nTag: py3-none-any\n",
+		info + "RECORD":   name + ".py,,\n" + info + "METADATA,,\n" + info + "WHEEL,,\n" + info + "RECORD,,\n",
+	} {
+		fw, err := w.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPythonEnvOfflineRequirementsAndLock(t *testing.T) {
+	root, base := newPythonEnvWorkspace(t)
+	wheels := t.TempDir()
+	writeWheel(t, wheels, "idensyra_demo", "0.1.0")
+	if err := os.WriteFile(filepath.Join(root, PythonRequirementsFile), []byte("idensyra_demo==0.1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	env := PythonEnv{Root: root}
+	if env.Exists() {
+		t.Fatal("environment exists before it was created")
+	}
+	if _, err := env.Create(ctx, base, PythonInstallOptions{WheelDir: wheels}); err != nil {
+		t.Fatal(err)
+	}
+	if !env.Exists() {
+		t.Fatal("environment was not created")
+	}
+	if data, err := os.ReadFile(filepath.Join(env.Dir(), ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf(".gitignore = %q, %v", data, err)
+	}
+
+	packages, err := env.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packages["idensyra_demo"] != "0.1.0" && packages["idensyra-demo"] != "0.1.0" {
+		t.Errorf("packages = %v, want idensyra_demo 0.1.0", packages)
+	}
+
+	path, err := env.Lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.ToLower(string(data)), "idensyra") || !strings.Contains(string(data), "==0.1.0") {
+		t.Errorf("lock file = %q", data)
+	}
+	if got := env.RequirementsFile(); got != PythonLockFile {
+		t.Errorf("requirements file = %q, want the lock file", got)
+	}
+
+	if _, err := env.Uninstall(ctx, "idensyra_demo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Remove(); err != nil || env.Exists() {
+		t.Fatalf("remove: %v, exists %v", err, env.Exists())
+	}
+}
+
+func TestPythonEnvProjectDependencies(t *testing.T) {
+	root, base := newPythonEnvWorkspace(t)
+	if out, err := exec.Command(base, "-c", "import tomllib").CombinedOutput(); err != nil {
+		t.Skipf("python has no tomllib: %s", out)
+	}
+	wheels := t.TempDir()
+	writeWheel(t, wheels, "idensyra_demo", "0.2.0")
+	project := "[project]\nname = \"demo\"\ndependencies = [\"idensyra_demo>=0.2\"]\n"
+	if err := os.WriteFile(filepath.Join(root, PythonProjectFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	env := PythonEnv{Root: root}
+	if _, err := env.Create(ctx, base, PythonInstallOptions{WheelDir: wheels}); err != nil {
+		t.Fatal(err)
+	}
+	packages, err := env.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packages["idensyra_demo"] != "0.2.0" && packages["idensyra-demo"] != "0.2.0" {
+		t.Errorf("packages = %v, want idensyra_demo 0.2.0", packages)
+	}
+}
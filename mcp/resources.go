@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ResourceScheme is the URI scheme of Idensyra MCP resources
+const ResourceScheme = "idensyra"
+
+// Resource kinds, used as the URI host
+const (
+	ResourceKindFile      = "file"
+	ResourceKindNotebook  = "notebook"
+	ResourceKindVariables = "variables"
+	ResourceKindWorkspace = "workspace"
+	ResourceKindSession   = "session"
+)
+
+// Fixed resource URIs
+const (
+	// WorkspaceFilesURI lists the workspace files with their resource URIs
+	WorkspaceFilesURI = "idensyra://workspace/files"
+	// SessionVariablesURI lists the live variables of the active notebook session
+	SessionVariablesURI = "idensyra://session/variables"
+)
+
+// Resource URI templates (RFC 6570 reserved expansion keeps "/" in paths)
+const (
+	FileResourceTemplate      = "idensyra://file/{+path}"
+	NotebookResourceTemplate  = "idensyra://notebook/{+path}"
+	VariablesResourceTemplate = "idensyra://variables/{+path}"
+)
+
+// ResourceURI builds the URI of a workspace path for a resource kind
+func ResourceURI(kind, relPath string) string {
+	parts := strings.Split(strings.Trim(relPath, "/"), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return ResourceScheme + "://" + kind + "/" + strings.Join(parts, "/")
+}
+
+// ParseResourceURI splits an idensyra:// URI into its kind and workspace path
+func ParseResourceURI(uri string) (string, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid resource uri: %w", err)
+	}
+	if u.Scheme != ResourceScheme {
+		return "", "", fmt.Errorf("unsupported resource scheme: %s", u.Scheme)
+	}
+	relPath := strings.TrimPrefix(u.Path, "/")
+	if relPath != "" {
+		if relPath, err = safeCleanRelativePath(relPath); err != nil {
+			return "", "", err
+		}
+	}
+	return u.Host, relPath, nil
+}
+
+// IsNotebookPath reports whether a workspace path is a notebook
+func IsNotebookPath(relPath string) bool {
+	ext := strings.ToLower(path.Ext(relPath))
+	return ext == ".igonb" || ext == ".ipynb"
+}
+
+// ResourceMIMEType guesses the MIME type of a workspace file
+func ResourceMIMEType(relPath string) string {
+	switch strings.ToLower(path.Ext(relPath)) {
+	case ".go":
+		return "text/x-go"
+	case ".py":
+		return "text/x-python"
+	case ".md":
+		return "text/markdown"
+	case ".csv":
+		return "text/csv"
+	case ".igonb", ".ipynb", ".json":
+		return "application/json"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	}
+	if t := mime.TypeByExtension(path.Ext(relPath)); t != "" {
+		return t
+	}
+	return "text/plain"
+}
+
+// ResourceFile is one entry of the workspace files resource
+type ResourceFile struct {
+	Path        string `json:"path"`
+	URI         string `json:"uri"`
+	NotebookURI string `json:"notebook_uri,omitempty"`
+	Size        int64  `json:"size"`
+	Modified    bool   `json:"modified,omitempty"`
+	Binary      bool   `json:"binary,omitempty"`
+}
+
+// NewResourceFile describes a workspace file for the workspace files resource
+func NewResourceFile(relPath string, size int64, modified, binary bool) ResourceFile {
+	file := ResourceFile{
+		Path:     relPath,
+		URI:      ResourceURI(ResourceKindFile, relPath),
+		Size:     size,
+		Modified: modified,
+		Binary:   binary,
+	}
+	if IsNotebookPath(relPath) {
+		file.NotebookURI = ResourceURI(ResourceKindNotebook, relPath)
+	}
+	return file
+}
+
+// notebookResourceCell is a notebook cell as exposed through notebook resources
+type notebookResourceCell struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Language string `json:"language"`
+	Source   string `json:"source"`
+	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NotebookResourceText renders igonb content as the cells with their latest outputs
+func NotebookResourceText(relPath string, igonbContent []byte) (string, error) {
+	var nb Notebook
+	if err := json.Unmarshal(igonbContent, &nb); err != nil {
+		return "", fmt.Errorf("invalid notebook %s: %w", relPath, err)
+	}
+	nb.ensureCellIDs()
+	cells := make([]notebookResourceCell, len(nb.Cells))
+	for i, cell := range nb.Cells {
+		cells[i] = notebookResourceCell{
+			Index:    i,
+			ID:       cell.ID,
+			Language: cell.Language,
+			Source:   cell.Source,
+			Output:   cell.Output,
+			Error:    cell.Error,
+		}
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"path":  relPath,
+		"cells": cells,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ResourceVariable is a live session variable as exposed through variable resources
+type ResourceVariable struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Preview string `json:"preview"`
+}
+
+// VariablesResourceText renders the variables of a notebook session
+func VariablesResourceText(notebook string, variables []ResourceVariable) (string, error) {
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	if variables == nil {
+		variables = []ResourceVariable{}
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"notebook":  notebook,
+		"variables": variables,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FormatResourceFiles renders the workspace files resource
+func FormatResourceFiles(files []ResourceFile) (string, error) {
+	if files == nil {
+		files = []ResourceFile{}
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"files": files,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResourceURIRoundTrip(t *testing.T) {
+	uri := ResourceURI(ResourceKindFile, "data/my file#1.csv")
+	if uri != "idensyra://file/data/my%20file%231.csv" {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+	kind, relPath, err := ParseResourceURI(uri)
+	if err != nil || kind != ResourceKindFile || relPath != "data/my file#1.csv" {
+		t.Fatalf("unexpected parse result: %q %q %v", kind, relPath, err)
+	}
+
+	kind, relPath, err = ParseResourceURI(SessionVariablesURI)
+	if err != nil || kind != ResourceKindSession || relPath != "variables" {
+		t.Fatalf("unexpected parse result: %q %q %v", kind, relPath, err)
+	}
+
+	for _, bad := range []string{"file:///etc/passwd", "idensyra://file/../secret.txt", "idensyra://file/a/../../b"} {
+		if _, _, err := ParseResourceURI(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestNotebookResourceText(t *testing.T) {
+	content := `{"version":1,"cells":[{"id":"c1","language":"go","source":"fmt.Println(1)","output":"1\n"},{"language":"python","source":"x","error":"NameError"}]}`
+	text, err := NotebookResourceText("nb.igonb", []byte(content))
+	if err != nil {
+		t.Fatalf("NotebookResourceText failed: %v", err)
+	}
+	var decoded struct {
+		Path  string                 `json:"path"`
+		Cells []notebookResourceCell `json:"cells"`
+	}
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Path != "nb.igonb" || len(decoded.Cells) != 2 {
+		t.Fatalf("unexpected notebook resource: %s", text)
+	}
+	if decoded.Cells[0].ID != "c1" || decoded.Cells[0].Output != "1\n" || decoded.Cells[1].Index != 1 || decoded.Cells[1].Error != "NameError" {
+		t.Fatalf("unexpected cells: %+v", decoded.Cells)
+	}
+	if _, err := NotebookResourceText("bad.igonb", []byte("{")); err == nil {
+		t.Fatalf("expected error for invalid notebook")
+	}
+}
+
+func TestPromptRender(t *testing.T) {
+	prompt, ok := FindPrompt("analyze_csv")
+	if !ok {
+		t.Fatalf("analyze_csv prompt missing")
+	}
+	if _, err := prompt.Render(map[string]string{}); err == nil {
+		t.Fatalf("expected error for missing path")
+	}
+	if _, err := prompt.Render(map[string]string{"path": "../outside.csv"}); err == nil {
+		t.Fatalf("expected error for path outside the workspace")
+	}
+	text, err := prompt.Render(map[string]string{"path": "sales.csv", "question": "Which month sold most?"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(text, "`sales.csv`") || !strings.Contains(text, "Which month sold most?") {
+		t.Fatalf("unexpected prompt text: %s", text)
+	}
+	if _, ok := FindPrompt("missing"); ok {
+		t.Fatalf("unexpected prompt found")
+	}
+}
@@ -0,0 +1,888 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Search limits
+const (
+	DefaultSearchMaxResults = 1000
+	MaxSearchMaxResults     = 10000
+	MaxSearchContextLines   = 10
+	// maxSearchLineLength bounds the line text returned with a match
+	maxSearchLineLength = 500
+	// searchBinarySniffBytes is how much of a file is checked for NUL bytes
+	searchBinarySniffBytes = 8000
+)
+
+// SearchOptions describes a workspace search. Matches never span lines.
+type SearchOptions struct {
+	Query         string   `json:"query"`
+	Regex         bool     `json:"regex,omitempty"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty"`
+	Include       []string `json:"include,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Context       int      `json:"context,omitempty"`
+	MaxResults    int      `json:"max_results,omitempty"`
+	// Paths, when not nil, keeps only these exact workspace paths. Unlike
+	// Include they are not globs, so names with [, * or ? select themselves.
+	Paths []string `json:"-"`
+}
+
+// SearchMatch is one match. Cell is the 1-based cell number for igonb notebooks,
+// in which case Line counts from the start of the cell source.
+type SearchMatch struct {
+	Path   string   `json:"path"`
+	Cell   int      `json:"cell,omitempty"`
+	CellID string   `json:"cell_id,omitempty"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Length int      `json:"length"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// SearchResult summarizes a finished search
+type SearchResult struct {
+	Matches       []SearchMatch `json:"matches"`
+	FilesSearched int           `json:"files_searched"`
+	FilesMatched  int           `json:"files_matched"`
+	Truncated     bool          `json:"truncated"`
+}
+
+// ReplaceFileResult reports the replacements made in one file
+type ReplaceFileResult struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ReplaceResult summarizes a replace across files
+type ReplaceResult struct {
+	Files        []ReplaceFileResult `json:"files"`
+	Replacements int                 `json:"replacements"`
+	DryRun       bool                `json:"dry_run,omitempty"`
+	// Checkpoint is the ID of the checkpoint that undoes the replace, if one was kept
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// SearchFS gives search access to workspace files
+type SearchFS interface {
+	// ListFiles returns the workspace-relative paths of all files
+	ListFiles() ([]string, error)
+	Open(path string) (io.ReadCloser, error)
+	WriteFile(path string, data []byte) error
+}
+
+// DirSearchFS searches the files of a directory on disk, skipping hidden entries
+type DirSearchFS struct {
+	Root string
+}
+
+// ListFiles walks the directory
+func (d DirSearchFS) ListFiles() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(d.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == d.Root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Root, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// Open opens a file for reading
+func (d DirSearchFS) Open(path string) (io.ReadCloser, error) {
+	cleanPath, err := safeCleanRelativePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(d.Root, filepath.FromSlash(cleanPath)))
+}
+
+// WriteFile replaces the content of a file, keeping its permissions
+func (d DirSearchFS) WriteFile(path string, data []byte) error {
+	cleanPath, err := safeCleanRelativePath(path)
+	if err != nil {
+		return err
+	}
+	fullPath := filepath.Join(d.Root, filepath.FromSlash(cleanPath))
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fullPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(fullPath, data, mode)
+}
+
+// compileSearch builds the line matcher for opts
+func compileSearch(opts SearchOptions) (*regexp.Regexp, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	pattern := opts.Query
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !opts.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// searchFiles lists the files selected by the include and exclude globs, sorted
+func searchFiles(fsys SearchFS, opts SearchOptions) ([]string, error) {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if strings.TrimSpace(pattern) == "" {
+			return nil, fmt.Errorf("glob pattern cannot be empty")
+		}
+		if err := checkPathPattern(pattern); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	all, err := fsys.ListFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	var only map[string]bool
+	if opts.Paths != nil {
+		only = make(map[string]bool, len(opts.Paths))
+		for _, p := range opts.Paths {
+			only[p] = true
+		}
+	}
+	var files []string
+	for _, p := range all {
+		if only != nil && !only[p] {
+			continue
+		}
+		if len(opts.Include) > 0 && !matchAnyGlob(opts.Include, p) {
+			continue
+		}
+		if matchAnyGlob(opts.Exclude, p) {
+			continue
+		}
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func matchAnyGlob(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether a workspace-relative path matches a search glob.
+// It is MatchPathPattern, the matcher of permission path rules, with two
+// conveniences for searching: a pattern also matches everything below a
+// directory it names, and a pattern without "/" may match at any depth. So
+// "*.go" matches "a/b.go" here but not as a path rule, where "**/*.go" is needed.
+func MatchGlob(pattern, path string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(normalizeRulePath(pattern), "/") {
+		pattern = "**/" + pattern
+	}
+	return MatchPathPattern(pattern+"/**", path)
+}
+
+// Search finds matches of opts in the files of fsys. onMatch, when set, is called
+// for each match as soon as its context lines are known, so callers can stream
+// results; the returned result holds all matches either way.
+func Search(ctx context.Context, fsys SearchFS, opts SearchOptions, onMatch func(SearchMatch)) (*SearchResult, error) {
+	re, err := compileSearch(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := searchFiles(fsys, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = min(max(opts.Context, 0), MaxSearchContextLines)
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultSearchMaxResults
+	}
+	opts.MaxResults = min(opts.MaxResults, MaxSearchMaxResults)
+
+	result := &SearchResult{Matches: []SearchMatch{}}
+	emit := func(m SearchMatch) bool {
+		if len(result.Matches) >= opts.MaxResults {
+			result.Truncated = true
+			return false
+		}
+		result.Matches = append(result.Matches, m)
+		if onMatch != nil {
+			onMatch(m)
+		}
+		return true
+	}
+
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		before := len(result.Matches)
+		searched, err := searchFile(ctx, fsys, path, re, opts.Context, emit)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return result, err
+			}
+			// Unreadable files are skipped like binary ones
+			continue
+		}
+		if searched {
+			result.FilesSearched++
+		}
+		if len(result.Matches) > before {
+			result.FilesMatched++
+		}
+		if result.Truncated {
+			break
+		}
+	}
+	return result, nil
+}
+
+// searchFile scans one file, reporting whether it was text
+func searchFile(ctx context.Context, fsys SearchFS, path string, re *regexp.Regexp, contextLines int, emit func(SearchMatch) bool) (bool, error) {
+	rc, err := fsys.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	reader := bufio.NewReaderSize(rc, 64*1024)
+	head, _ := reader.Peek(searchBinarySniffBytes)
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(trimPartialRune(head)) {
+		return false, nil
+	}
+
+	if isIgonbPath(path) {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return false, err
+		}
+		if cells, err := parseSearchCells(data); err == nil {
+			for i, cell := range cells {
+				scanner := newLineMatcher(path, re, contextLines, emit)
+				scanner.cell, scanner.cellID = i+1, cell.id
+				for _, line := range strings.SplitAfter(cell.source, "\n") {
+					if line == "" {
+						continue
+					}
+					if !scanner.line(line) {
+						return true, nil
+					}
+				}
+				scanner.flush()
+			}
+			return true, ctx.Err()
+		}
+		// Invalid notebooks are searched as plain text
+		reader = bufio.NewReader(bytes.NewReader(data))
+	}
+
+	scanner := newLineMatcher(path, re, contextLines, emit)
+	for n := 0; ; n++ {
+		line, err := reader.ReadString('\n')
+		if line != "" && !scanner.line(line) {
+			return true, nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return true, err
+		}
+		if n%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return true, err
+			}
+		}
+	}
+	scanner.flush()
+	return true, nil
+}
+
+// trimPartialRune drops a rune cut off at the end of a sniffed prefix
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+			return b
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// lineMatcher matches lines one at a time, holding matches back until their
+// trailing context is complete
+type lineMatcher struct {
+	path         string
+	cell         int
+	cellID       string
+	re           *regexp.Regexp
+	contextLines int
+	emit         func(SearchMatch) bool
+	lineNo       int
+	previous     []string
+	waiting      []*SearchMatch
+}
+
+func newLineMatcher(path string, re *regexp.Regexp, contextLines int, emit func(SearchMatch) bool) *lineMatcher {
+	return &lineMatcher{path: path, re: re, contextLines: contextLines, emit: emit}
+}
+
+// line processes the next line and reports whether the search should continue
+func (l *lineMatcher) line(raw string) bool {
+	l.lineNo++
+	text := strings.TrimRight(raw, "\r\n")
+
+	for _, m := range l.waiting {
+		m.After = append(m.After, truncateSearchLine(text))
+	}
+	if !l.release(false) {
+		return false
+	}
+
+	for _, loc := range l.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		m := &SearchMatch{
+			Path:   l.path,
+			Cell:   l.cell,
+			CellID: l.cellID,
+			Line:   l.lineNo,
+			Column: utf8.RuneCountInString(text[:loc[0]]) + 1,
+			Length: utf8.RuneCountInString(text[loc[0]:loc[1]]),
+			Text:   truncateSearchLine(text),
+			Before: append([]string(nil), l.previous...),
+		}
+		l.waiting = append(l.waiting, m)
+	}
+	if !l.release(false) {
+		return false
+	}
+
+	if l.contextLines > 0 {
+		l.previous = append(l.previous, truncateSearchLine(text))
+		if len(l.previous) > l.contextLines {
+			l.previous = l.previous[1:]
+		}
+	}
+	return true
+}
+
+// flush emits matches still waiting for context at the end of the input
+func (l *lineMatcher) flush() {
+	l.release(true)
+}
+
+func (l *lineMatcher) release(all bool) bool {
+	for len(l.waiting) > 0 && (all || len(l.waiting[0].After) >= l.contextLines) {
+		m := l.waiting[0]
+		l.waiting = l.waiting[1:]
+		if !l.emit(*m) {
+			l.waiting = nil
+			return false
+		}
+	}
+	return true
+}
+
+func truncateSearchLine(text string) string {
+	if utf8.RuneCountInString(text) <= maxSearchLineLength {
+		return text
+	}
+	return string([]rune(text)[:maxSearchLineLength]) + "…"
+}
+
+// Replace replaces the matches of opts with replacement in every selected file.
+// In regex mode replacement may reference groups as $1 or ${name}. Replacements
+// are made line by line so they agree with Search; notebooks only change cell
+// sources. With dryRun nothing is written.
+func Replace(ctx context.Context, fsys SearchFS, opts SearchOptions, replacement string, dryRun bool) (*ReplaceResult, error) {
+	re, err := compileSearch(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := searchFiles(fsys, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplaceResult{Files: []ReplaceFileResult{}, DryRun: dryRun}
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		data, err := readSearchFile(fsys, path)
+		if err != nil || data == nil {
+			continue
+		}
+		updated, count, err := replaceContent(path, data, re, replacement, opts.Regex)
+		if err != nil {
+			result.Files = append(result.Files, ReplaceFileResult{Path: path, Error: err.Error()})
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		fileResult := ReplaceFileResult{Path: path, Replacements: count}
+		if !dryRun {
+			if err := fsys.WriteFile(path, updated); err != nil {
+				fileResult.Replacements = 0
+				fileResult.Error = err.Error()
+			}
+		}
+		result.Files = append(result.Files, fileResult)
+		result.Replacements += fileResult.Replacements
+	}
+	return result, nil
+}
+
+// readSearchFile reads a file, returning nil content for binary files
+func readSearchFile(fsys SearchFS, path string) ([]byte, error) {
+	rc, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func replaceContent(path string, data []byte, re *regexp.Regexp, replacement string, expand bool) ([]byte, int, error) {
+	if !isIgonbPath(path) {
+		text, count := replaceLines(string(data), re, replacement, expand)
+		return []byte(text), count, nil
+	}
+
+	var nb orderedJSONObject
+	if err := json.Unmarshal(data, &nb); err != nil {
+		// Invalid notebooks are handled as plain text
+		text, count := replaceLines(string(data), re, replacement, expand)
+		return []byte(text), count, nil
+	}
+	rawCells, ok := nb.get("cells")
+	if !ok {
+		return data, 0, nil
+	}
+	var cells []orderedJSONObject
+	if err := json.Unmarshal(rawCells, &cells); err != nil {
+		return nil, 0, fmt.Errorf("invalid notebook cells: %w", err)
+	}
+	total := 0
+	for i := range cells {
+		rawSource, ok := cells[i].get("source")
+		if !ok {
+			continue
+		}
+		var source string
+		if err := json.Unmarshal(rawSource, &source); err != nil {
+			return nil, 0, fmt.Errorf("invalid source in cell %d: %w", i+1, err)
+		}
+		updated, count := replaceLines(source, re, replacement, expand)
+		if count == 0 {
+			continue
+		}
+		encoded, err := json.Marshal(updated)
+		if err != nil {
+			return nil, 0, err
+		}
+		cells[i].set("source", encoded)
+		total += count
+	}
+	if total == 0 {
+		return data, 0, nil
+	}
+	encodedCells, err := json.Marshal(cells)
+	if err != nil {
+		return nil, 0, err
+	}
+	nb.set("cells", encodedCells)
+	out, err := json.MarshalIndent(nb, "", "  ")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode notebook: %w", err)
+	}
+	return out, total, nil
+}
+
+// replaceLines replaces matches within each line, keeping line endings
+func replaceLines(text string, re *regexp.Regexp, replacement string, expand bool) (string, int) {
+	var b strings.Builder
+	total := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		ending := line[len(body):]
+		count := 0
+		for _, loc := range re.FindAllStringIndex(body, -1) {
+			if loc[0] != loc[1] {
+				count++
+			}
+		}
+		if count > 0 {
+			if expand {
+				body = re.ReplaceAllStringFunc(body, func(match string) string {
+					return expandMatch(re, match, replacement)
+				})
+			} else {
+				body = re.ReplaceAllLiteralString(body, replacement)
+			}
+			total += count
+		}
+		b.WriteString(body)
+		b.WriteString(ending)
+	}
+	return b.String(), total
+}
+
+// expandMatch expands $n references of replacement against a single match
+func expandMatch(re *regexp.Regexp, match, replacement string) string {
+	submatches := re.FindStringSubmatchIndex(match)
+	if submatches == nil {
+		return match
+	}
+	return string(re.ExpandString(nil, replacement, match, submatches))
+}
+
+func isIgonbPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".igonb")
+}
+
+type searchCell struct {
+	id     string
+	source string
+}
+
+func parseSearchCells(data []byte) ([]searchCell, error) {
+	var nb struct {
+		Cells []struct {
+			ID     string `json:"id"`
+			Source string `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, err
+	}
+	cells := make([]searchCell, len(nb.Cells))
+	for i, cell := range nb.Cells {
+		cells[i] = searchCell{id: cell.ID, source: cell.Source}
+	}
+	return cells, nil
+}
+
+// orderedJSONObject is a JSON object that keeps its keys in order, so rewriting
+// one field leaves the rest of a document untouched
+type orderedJSONObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *orderedJSONObject) get(key string) (json.RawMessage, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+func (o *orderedJSONObject) set(key string, value json.RawMessage) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedJSONObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected a JSON object")
+	}
+	o.keys = nil
+	o.values = make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected an object key")
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		o.set(key, value)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func (o orderedJSONObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(encodedKey)
+		b.WriteByte(':')
+		b.Write(o.values[key])
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// ParseSearchOptions reads search arguments of the search_files and replace_in_files tools
+func ParseSearchOptions(args map[string]interface{}) (SearchOptions, error) {
+	var opts SearchOptions
+	opts.Query, _ = args["query"].(string)
+	if opts.Query == "" {
+		return opts, fmt.Errorf("missing query")
+	}
+	opts.Regex, _ = args["regex"].(bool)
+	opts.CaseSensitive, _ = args["case_sensitive"].(bool)
+	var err error
+	if opts.Include, err = stringListArg(args, "include"); err != nil {
+		return opts, err
+	}
+	if opts.Exclude, err = stringListArg(args, "exclude"); err != nil {
+		return opts, err
+	}
+	for _, field := range []struct {
+		name string
+		dst  *int
+	}{{"context", &opts.Context}, {"max_results", &opts.MaxResults}} {
+		if v, ok := args[field.name]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != float64(int(n)) {
+				return opts, fmt.Errorf("%s must be a non-negative integer", field.name)
+			}
+			*field.dst = int(n)
+		}
+	}
+	return opts, nil
+}
+
+// stringListArg accepts a string array or a comma-separated string
+func stringListArg(args map[string]interface{}, name string) ([]string, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings", name)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	case []string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s must be a list of strings", name)
+}
+
+func searchInputProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"type":        "string",
+			"description": "Text to find, or a Go regular expression when regex is true. Matches never span lines.",
+		},
+		"regex": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Treat query as a regular expression (default false)",
+		},
+		"case_sensitive": map[string]interface{}{
+			"type":        "boolean",
+			"description": "Match case (default false)",
+		},
+		"include": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Only files matching one of these globs, e.g. [\"*.go\", \"src/**\"]",
+		},
+		"exclude": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Skip files matching one of these globs",
+		},
+	}
+}
+
+// SearchFilesInputSchema returns the input schema of the search_files tool
+func SearchFilesInputSchema() map[string]interface{} {
+	props := searchInputProperties()
+	props["context"] = map[string]interface{}{
+		"type":        "integer",
+		"description": fmt.Sprintf("Lines of context before and after each match (max %d)", MaxSearchContextLines),
+	}
+	props["max_results"] = map[string]interface{}{
+		"type":        "integer",
+		"description": fmt.Sprintf("Maximum number of matches (default %d, max %d)", DefaultSearchMaxResults, MaxSearchMaxResults),
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"query"},
+	}
+}
+
+// ReplaceInFilesInputSchema returns the input schema of the replace_in_files tool
+func ReplaceInFilesInputSchema() map[string]interface{} {
+	props := searchInputProperties()
+	props["replacement"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Replacement text; in regex mode $1 or ${name} insert capture groups",
+	}
+	props["dry_run"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Only count the replacements without changing files",
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"query", "replacement"},
+	}
+}
+
+// ParseReplaceArgs reads the replacement and dry_run arguments of replace_in_files
+func ParseReplaceArgs(args map[string]interface{}) (string, bool, error) {
+	replacement, ok := args["replacement"].(string)
+	if !ok {
+		return "", false, fmt.Errorf("missing replacement")
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	return replacement, dryRun, nil
+}
+
+// FormatSearchResult renders matches grouped by file
+func FormatSearchResult(result *SearchResult) string {
+	if len(result.Matches) == 0 {
+		return fmt.Sprintf("No matches (%d files searched)", result.FilesSearched)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d matches in %d files (%d files searched)", len(result.Matches), result.FilesMatched, result.FilesSearched)
+	if result.Truncated {
+		b.WriteString(", results truncated")
+	}
+	b.WriteString("\n")
+	lastFile := ""
+	for _, m := range result.Matches {
+		if m.Path != lastFile {
+			fmt.Fprintf(&b, "\n%s\n", m.Path)
+			lastFile = m.Path
+		}
+		location := fmt.Sprintf("%d:%d", m.Line, m.Column)
+		if m.Cell > 0 {
+			location = fmt.Sprintf("cell %d %s", m.Cell, location)
+		}
+		for i, line := range m.Before {
+			fmt.Fprintf(&b, "  %d- %s\n", m.Line-len(m.Before)+i, line)
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", location, m.Text)
+		for i, line := range m.After {
+			fmt.Fprintf(&b, "  %d- %s\n", m.Line+i+1, line)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// FormatReplaceResult renders the replacements per file
+func FormatReplaceResult(result *ReplaceResult) string {
+	verb := "Replaced"
+	if result.DryRun {
+		verb = "Would replace"
+	}
+	changed := 0
+	for _, f := range result.Files {
+		if f.Replacements > 0 {
+			changed++
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d occurrences in %d files", verb, result.Replacements, changed)
+	for _, f := range result.Files {
+		if f.Error != "" {
+			fmt.Fprintf(&b, "\n- %s: error: %s", f.Path, f.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "\n- %s: %d", f.Path, f.Replacements)
+	}
+	if result.Checkpoint != "" {
+		fmt.Fprintf(&b, "\nPrevious state saved as checkpoint %s; restore_checkpoint undoes the replace", result.Checkpoint)
+	}
+	return b.String()
+}
+
+// ReplacedPaths returns the paths a replace changed or would change
+func (r *ReplaceResult) ReplacedPaths() []string {
+	var paths []string
+	for _, f := range r.Files {
+		if f.Replacements > 0 {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,248 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+  "version": 1,
+  "cells": [
+    {
+      "id": "intro",
+      "language": "markdown",
+      "source": "# Total report"
+    },
+    {
+      "id": "calc",
+      "language": "go",
+      "source": "total := 1\nfmt.Println(total)",
+      "output": "1"
+    }
+  ]
+}`
+
+func writeSearchTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":          "package main\n\nfunc main() {\n\tTotal := 2\n\tprintln(Total)\n}\n",
+		"lib/util.go":      "package lib\n\n// total sums values\nfunc Sum() {}\n",
+		"notes.txt":        "nothing here\r\ntotal: 3\r\n",
+		"report.igonb":     testNotebook,
+		"data.bin":         "total\x00\x01\x02",
+		".hidden/x.go":     "total",
+		"testdata/a.go":    "total",
+		"lib/util_test.go": "total",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSearchContextGlobsAndCells(t *testing.T) {
+	fsys := DirSearchFS{Root: writeSearchTree(t)}
+	var streamed []SearchMatch
+	result, err := Search(context.Background(), fsys, SearchOptions{
+		Query:   "total",
+		Exclude: []string{"testdata", "*_test.go"},
+		Context: 1,
+	}, func(m SearchMatch) { streamed = append(streamed, m) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != len(result.Matches) {
+		t.Fatalf("streamed %d of %d matches", len(streamed), len(result.Matches))
+	}
+	byFile := map[string][]SearchMatch{}
+	for _, m := range result.Matches {
+		byFile[m.Path] = append(byFile[m.Path], m)
+	}
+	if len(byFile) != 4 || result.FilesSearched != 4 || result.FilesMatched != 4 {
+		t.Fatalf("unexpected files: %v (searched %d)", byFile, result.FilesSearched)
+	}
+
+	main := byFile["main.go"][0]
+	if main.Line != 4 || main.Column != 2 || main.Length != 5 || main.Before[0] != "func main() {" || main.After[0] != "\tprintln(Total)" {
+		t.Errorf("unexpected main.go match: %+v", main)
+	}
+	if notes := byFile["notes.txt"][0]; notes.Text != "total: 3" || len(notes.After) != 0 {
+		t.Errorf("unexpected notes.txt match: %+v", notes)
+	}
+	cells := byFile["report.igonb"]
+	if len(cells) != 3 || cells[0].Cell != 1 || cells[0].CellID != "intro" || cells[2].Cell != 2 || cells[2].Line != 2 || cells[2].Column != 13 {
+		t.Errorf("unexpected notebook matches: %+v", cells)
+	}
+	if len(cells[0].After) != 0 || cells[1].After[0] != "fmt.Println(total)" {
+		t.Errorf("context leaked across cells: %+v", cells)
+	}
+
+	result, err = Search(context.Background(), fsys, SearchOptions{Query: `T\w+ :=`, Regex: true, CaseSensitive: true, Include: []string{"**/*.go"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Path != "main.go" {
+		t.Errorf("unexpected regex matches: %+v", result.Matches)
+	}
+
+	result, _ = Search(context.Background(), fsys, SearchOptions{Query: "total", MaxResults: 2}, nil)
+	if len(result.Matches) != 2 || !result.Truncated {
+		t.Errorf("results not truncated: %+v", result)
+	}
+	if _, err := Search(context.Background(), fsys, SearchOptions{Query: "(", Regex: true}, nil); err == nil {
+		t.Error("expected invalid regex error")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "a/b/c.go", true},
+		{"*.go", "a/b/c.txt", false},
+		{"lib", "lib/util.go", true},
+		{"lib/*.go", "lib/util.go", true},
+		{"lib/*.go", "lib/sub/util.go", false},
+		{"lib/**", "lib/sub/util.go", true},
+		{"**/sub/*.go", "lib/sub/util.go", true},
+		{"**/*.go", "main.go", true},
+		{"ma?n.go", "main.go", true},
+		{"./lib/*.go", "lib/util.go", true},
+		{"[ab].go", "sub/a.go", true},
+		{"lib", "mylib/util.go", false},
+	} {
+		if got := MatchGlob(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestMatchGlobAgreesWithPathRules(t *testing.T) {
+	paths := []string{"main.go", "lib/util.go", "lib/sub/util.go", "data/raw/a.csv", "notebooks/a/b.ipynb"}
+	// Patterns with "/" select the same files in a search and in a path rule
+	for _, pattern := range []string{"lib/*.go", "lib/**", "**/*.go", "data/raw/**", "src/*/main.go", "**/*.ipynb"} {
+		for _, p := range paths {
+			if got, want := MatchGlob(pattern, p), MatchPathPattern(pattern, p); got != want {
+				t.Errorf("MatchGlob(%q, %q) = %v, MatchPathPattern = %v", pattern, p, got, want)
+			}
+		}
+	}
+	// A pattern without "/" is anchored in a path rule but matches at any depth in a search
+	for _, pattern := range []string{"*.go", "util.go"} {
+		for _, p := range paths {
+			if got, want := MatchGlob(pattern, p), MatchPathPattern("**/"+pattern, p); got != want {
+				t.Errorf("MatchGlob(%q, %q) = %v, MatchPathPattern(**/%s) = %v", pattern, p, got, pattern, want)
+			}
+		}
+	}
+	if MatchPathPattern("*.go", "lib/util.go") {
+		t.Error(`path rule "*.go" should not match lib/util.go`)
+	}
+}
+
+func TestReplaceKeepsNotebookLayout(t *testing.T) {
+	root := writeSearchTree(t)
+	fsys := DirSearchFS{Root: root}
+	opts := SearchOptions{Query: `total(\W)`, Regex: true, CaseSensitive: true, Include: []string{"*.igonb", "notes.txt"}}
+
+	preview, err := Replace(context.Background(), fsys, opts, "sum$1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Replacements != 3 || len(preview.ReplacedPaths()) != 2 {
+		t.Fatalf("unexpected dry run: %+v", preview)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "notes.txt")); !strings.Contains(string(data), "total: 3") {
+		t.Fatal("dry run changed a file")
+	}
+
+	if _, err := Replace(context.Background(), fsys, opts, "sum$1", false); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, "report.igonb"))
+	want := strings.Replace(testNotebook, `total := 1\nfmt.Println(total)`, `sum := 1\nfmt.Println(sum)`, 1)
+	if string(data) != want {
+		t.Errorf("notebook not rewritten in place:\n%s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "notes.txt")); string(data) != "nothing here\r\nsum: 3\r\n" {
+		t.Errorf("unexpected notes.txt: %q", data)
+	}
+}
+
+func TestServerSearchAndReplace(t *testing.T) {
+	root := writeSearchTree(t)
+	config := DefaultConfig()
+	config.FileEdit = PermissionAlways
+	s := NewServer(config, root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	resp, err := s.HandleRequest(context.Background(), &ToolRequest{Name: "search_files", Arguments: map[string]interface{}{
+		"query": "println", "include": []interface{}{"*.go"}, "context": 1.0,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := resp.Content[0].Text; !strings.HasPrefix(text, "1 matches in 1 files") || !strings.Contains(text, "main.go\n  4- \tTotal := 2\n  5:2: \tprintln(Total)") {
+		t.Errorf("unexpected search output:\n%s", text)
+	}
+
+	resp, err = s.HandleRequest(context.Background(), &ToolRequest{Name: "replace_in_files", Arguments: map[string]interface{}{
+		"query": "Total", "case_sensitive": true, "replacement": "Count",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.Content[0].Text, "Replaced 3 occurrences in 2 files") {
+		t.Errorf("unexpected replace output: %s", resp.Content[0].Text)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "main.go")); !strings.Contains(string(data), "println(Count)") {
+		t.Errorf("main.go not changed: %s", data)
+	}
+
+	if resp, err := s.HandleRequest(context.Background(), &ToolRequest{Name: "search_files", Arguments: map[string]interface{}{"query": "x", "context": -1.0}}); err == nil || !resp.IsError {
+		t.Error("expected error for negative context")
+	}
+}
+
+func TestServerReplaceReportsCheckpoint(t *testing.T) {
+	root := t.TempDir()
+	fs := DirCheckpointFS{Root: root}
+	fs.WriteFile("data[1].csv", []byte("a,total\n"))
+	fs.WriteFile("data1.csv", []byte("b,total\n"))
+	config := DefaultConfig()
+	config.FileEdit = PermissionAlways
+	s := NewServer(config, root, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	store := NewCheckpointStore(CheckpointStorePath(root))
+	s.SetCheckpointStore(store, fs)
+
+	resp, err := s.HandleRequest(context.Background(), &ToolRequest{Name: "replace_in_files", Arguments: map[string]interface{}{
+		"query": "total", "replacement": "sum", "include": []interface{}{"data[[]1].csv"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The glob metacharacters in the name do not keep the file out of the replace
+	if data, _ := fs.ReadFile("data[1].csv"); string(data) != "a,sum\n" {
+		t.Errorf("data[1].csv = %q", data)
+	}
+	if data, _ := fs.ReadFile("data1.csv"); string(data) != "b,total\n" {
+		t.Errorf("data1.csv changed to %q", data)
+	}
+	list, _ := store.List()
+	if len(list) != 1 {
+		t.Fatalf("expected 1 checkpoint, got %d", len(list))
+	}
+	if text := resp.Content[0].Text; !strings.Contains(text, "checkpoint "+list[0].ID) {
+		t.Errorf("replace output does not name the checkpoint:\n%s", text)
+	}
+}
@@ -6,6 +6,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Server represents the MCP server
@@ -15,6 +20,13 @@ type Server struct {
 	codeExec            *CodeExecution
 	notebookOps         *NotebookOperations
 	workspaceManagement *WorkspaceManagement
+	auditLog            *AuditLog
+	checkpoints         *CheckpointStore
+	checkpointFS        CheckpointFS
+	datasets            DatasetSource
+	search              SearchFS
+	git                 GitRepo
+	cancelExecution     func(tool string)
 }
 
 // NewServer creates a new MCP server instance
@@ -46,11 +58,148 @@ func NewServer(
 		codeExec:            NewCodeExecution(config, workspaceRoot, confirmFunc, executeGoFunc, executePyFunc, executePyContentFunc, readFileFunc, setActiveFileFunc),
 		notebookOps:         NewNotebookOperations(config, workspaceRoot, confirmFunc, executeCellFunc, setActiveFileFunc),
 		workspaceManagement: NewWorkspaceManagement(config, confirmFunc, openWorkspaceFunc, saveWorkspaceFunc, saveChangesFunc, importFileFunc),
+		datasets: DatasetSource{
+			Open: func(path string) (io.ReadCloser, error) {
+				return os.Open(filepath.Join(workspaceRoot, filepath.FromSlash(path)))
+			},
+		},
+		search: DirSearchFS{Root: workspaceRoot},
+		git:    GitRepo{Dir: workspaceRoot},
 	}
 }
 
-// HandleRequest handles an incoming MCP tool request
+// SetAuditLog enables recording of every handled tool call
+func (s *Server) SetAuditLog(auditLog *AuditLog) {
+	s.auditLog = auditLog
+}
+
+// SetCheckpointStore enables automatic checkpoints before tool calls that change files
+func (s *Server) SetCheckpointStore(store *CheckpointStore, fs CheckpointFS) {
+	s.checkpoints = store
+	s.checkpointFS = fs
+}
+
+// SetParquetReader enables Parquet files in describe_dataset and preview_dataset
+func (s *Server) SetParquetReader(readParquet func(ctx context.Context, path string) (Table, error)) {
+	s.datasets.ReadParquet = readParquet
+}
+
+// SetCancelFunc sets the function that stops the code a tool call runs when
+// the call is cancelled or times out; the execution callbacks take no context
+func (s *Server) SetCancelFunc(cancelExecution func(tool string)) {
+	s.cancelExecution = cancelExecution
+}
+
+// HandleRequest handles an incoming MCP tool request, taking a checkpoint of the files
+// it may change and recording it in the audit log if enabled
 func (s *Server) HandleRequest(ctx context.Context, req *ToolRequest) (*ToolResponse, error) {
+	ctx, cancel := s.config.WithToolTimeout(ctx, req.Name)
+	defer cancel()
+	if s.cancelExecution != nil {
+		stop := context.AfterFunc(ctx, func() { s.cancelExecution(req.Name) })
+		defer stop()
+	}
+
+	paths := MutatedPaths(req.Name, req.Arguments)
+	checkpoint := s.createCheckpoint(req.Name, paths)
+	if s.auditLog == nil {
+		resp, err := s.handleTool(ctx, req)
+		s.finishCheckpoint(checkpoint)
+		return resp, err
+	}
+
+	ctx, recorder := WithAuditRecorder(ctx)
+	before := s.snapshotFiles(paths)
+	start := time.Now()
+
+	resp, err := s.handleTool(ctx, req)
+
+	entry := AuditEntry{
+		Time:       start,
+		Source:     "stdio",
+		Tool:       req.Name,
+		Arguments:  AuditArguments(req.Arguments),
+		Decisions:  recorder.Decisions(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Files:      append(FileChanges(paths, before, s.snapshotFiles(paths)), recorder.Files()...),
+		Checkpoint: s.finishCheckpoint(checkpoint),
+	}
+	if entry.Checkpoint == "" {
+		entry.Checkpoint = recorder.Checkpoint()
+	}
+	if resp != nil {
+		entry.IsError = resp.IsError
+		if len(resp.Content) > 0 {
+			entry.Summary = AuditSummary(resp.Content[0].Text)
+		}
+	}
+	if err != nil {
+		entry.IsError = true
+		entry.Error = err.Error()
+	}
+	if logErr := s.auditLog.Append(entry); logErr != nil {
+		log.Printf("Error writing audit log: %v", logErr)
+	}
+	return resp, err
+}
+
+// createCheckpoint snapshots the files a tool call may change, returning nil when disabled
+func (s *Server) createCheckpoint(tool string, paths []string) *Checkpoint {
+	if s.checkpoints == nil || len(paths) == 0 {
+		return nil
+	}
+	checkpoint, err := s.checkpoints.Create("stdio", tool, paths, s.checkpointFS)
+	if err != nil {
+		log.Printf("Error creating checkpoint: %v", err)
+		return nil
+	}
+	return checkpoint
+}
+
+// finishCheckpoint drops a checkpoint when the call changed nothing and returns the ID kept
+func (s *Server) finishCheckpoint(checkpoint *Checkpoint) string {
+	if checkpoint == nil {
+		return ""
+	}
+	changes, err := s.checkpoints.Diff(checkpoint.ID, nil, s.checkpointFS)
+	if err == nil && len(changes) == 0 {
+		if err := s.checkpoints.Delete(checkpoint.ID); err != nil {
+			log.Printf("Error deleting checkpoint: %v", err)
+		}
+		return ""
+	}
+	return checkpoint.ID
+}
+
+// recordCheckpointChanges reports the changes made since a checkpoint to the audit log
+func (s *Server) recordCheckpointChanges(ctx context.Context, id string) {
+	if id == "" {
+		return
+	}
+	changes, err := s.checkpoints.Diff(id, nil, s.checkpointFS)
+	if err != nil {
+		log.Printf("Error diffing checkpoint: %v", err)
+		return
+	}
+	RecordFileChanges(ctx, changes, id)
+}
+
+// snapshotFiles reads the current contents of paths through the file backend
+func (s *Server) snapshotFiles(paths []string) map[string]*string {
+	snapshot := make(map[string]*string, len(paths))
+	if s.fileOps == nil || s.fileOps.readFileFunc == nil {
+		return snapshot
+	}
+	for _, p := range paths {
+		if content, err := s.fileOps.readFileFunc(p); err == nil {
+			snapshot[p] = &content
+		}
+	}
+	return snapshot
+}
+
+// handleTool dispatches a tool request to its implementation
+func (s *Server) handleTool(ctx context.Context, req *ToolRequest) (*ToolResponse, error) {
 	switch req.Name {
 	// File operations
 	case "read_file":
@@ -102,6 +251,15 @@ func (s *Server) HandleRequest(ctx context.Context, req *ToolRequest) (*ToolResp
 		language, _ := req.Arguments["language"].(string)
 		source, _ := req.Arguments["source"].(string)
 		return s.notebookOps.InsertCell(ctx, path, int(position), language, source)
+	case "set_cell_metadata":
+		metaReq, err := ParseCellMetadataArgs(req.Arguments)
+		if err != nil {
+			return &ToolResponse{
+				Content: []ContentBlock{{Type: "text", Text: err.Error()}},
+				IsError: true,
+			}, err
+		}
+		return s.notebookOps.SetCellMetadata(ctx, metaReq)
 	case "execute_cell":
 		path, _ := req.Arguments["path"].(string)
 		cellIndex, _ := req.Arguments["cell_index"].(float64)
@@ -141,6 +299,38 @@ func (s *Server) HandleRequest(ctx context.Context, req *ToolRequest) (*ToolResp
 		targetDir, _ := req.Arguments["target_dir"].(string)
 		return s.workspaceManagement.ImportFileToWorkspace(ctx, sourcePath, targetDir)
 
+	// Audit log
+	case "get_audit_log":
+		return s.getAuditLog(req.Arguments)
+
+	// Checkpoints
+	case "list_checkpoints":
+		return s.listCheckpoints(req.Arguments)
+	case "diff_checkpoint":
+		return s.diffCheckpoint(req.Arguments)
+	case "restore_checkpoint":
+		return s.restoreCheckpoint(ctx, req.Arguments)
+
+	// Datasets
+	case "describe_dataset", "preview_dataset":
+		return s.queryDataset(ctx, req.Name, req.Arguments)
+
+	// Search
+	case "search_files":
+		return s.searchFiles(ctx, req.Arguments)
+	case "replace_in_files":
+		return s.replaceInFiles(ctx, req.Arguments)
+
+	// Git
+	case "git_status":
+		return s.gitStatus(ctx)
+	case "git_diff":
+		return s.gitDiff(ctx, req.Arguments)
+	case "git_log":
+		return s.gitLog(ctx, req.Arguments)
+	case "git_stage", "git_unstage", "git_commit":
+		return s.gitChange(ctx, req.Name, req.Arguments)
+
 	default:
 		return &ToolResponse{
 			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Unknown tool: %s", req.Name)}},
@@ -151,6 +341,11 @@ func (s *Server) HandleRequest(ctx context.Context, req *ToolRequest) (*ToolResp
 
 // ListTools returns a list of available tools
 func (s *Server) ListTools() []ToolInfo {
+	// Parquet files are only readable with a configured Parquet reader
+	datasetFormats := "CSV, TSV or Excel"
+	if s.datasets.ReadParquet != nil {
+		datasetFormats = "CSV, TSV, Excel or Parquet"
+	}
 	tools := []ToolInfo{
 		// File operations
 		{
@@ -304,12 +499,18 @@ func (s *Server) ListTools() []ToolInfo {
 				"properties": map[string]interface{}{
 					"path":     map[string]interface{}{"type": "string", "description": "Path to the notebook"},
 					"position": map[string]interface{}{"type": "number", "description": "Position to insert at (0-based)"},
-					"language": map[string]interface{}{"type": "string", "description": "Cell language (go, python, markdown)"},
+					"language": map[string]interface{}{"type": "string", "description": "Cell language (go, python, shell, sql, markdown)"},
 					"source":   map[string]interface{}{"type": "string", "description": "Cell source code"},
 				},
 				"required": []string{"path", "position", "language", "source"},
 			},
 		},
+		{
+			Name:        "set_cell_metadata",
+			Description: "Set or remove metadata of a notebook cell, such as skip, frozen, tags, timeout, hide_code and hide_output",
+			Target:      "idensyra",
+			InputSchema: SetCellMetadataInputSchema(),
+		},
 		{
 			Name:        "execute_cell",
 			Description: "Execute a specific cell in a notebook",
@@ -449,6 +650,113 @@ func (s *Server) ListTools() []ToolInfo {
 		},
 	}
 
+	// Audit log
+	tools = append(tools, ToolInfo{
+		Name:        "get_audit_log",
+		Description: "Operates on Idensyra workspace - Read the audit log of MCP tool calls (tool, arguments, permission decisions, duration, result summary, file diffs)",
+		Target:      "idensyra",
+		InputSchema: AuditLogInputSchema(),
+	})
+
+	// Checkpoints
+	tools = append(tools,
+		ToolInfo{
+			Name:        "list_checkpoints",
+			Description: "Operates on Idensyra workspace - List checkpoints taken automatically before MCP tool calls changed files",
+			Target:      "idensyra",
+			InputSchema: ListCheckpointsInputSchema(),
+		},
+		ToolInfo{
+			Name:        "diff_checkpoint",
+			Description: "Operates on Idensyra workspace - Show how files changed since a checkpoint",
+			Target:      "idensyra",
+			InputSchema: CheckpointInputSchema("diff"),
+		},
+		ToolInfo{
+			Name:        "restore_checkpoint",
+			Description: "Operates on Idensyra workspace - Restore files to their state at a checkpoint (the current state is checkpointed first)",
+			Target:      "idensyra",
+			InputSchema: CheckpointInputSchema("restore"),
+		},
+	)
+
+	// Datasets
+	tools = append(tools,
+		ToolInfo{
+			Name:        "describe_dataset",
+			Description: "Operates on Idensyra workspace - Describe a " + datasetFormats + " file as JSON: row count, column types, null and distinct counts and numeric summary statistics. " + DatasetStatsNote,
+			Target:      "idensyra",
+			InputSchema: DescribeDatasetInputSchema(),
+		},
+		ToolInfo{
+			Name:        "preview_dataset",
+			Description: "Operates on Idensyra workspace - Return a page of rows of a " + datasetFormats + " file as JSON",
+			Target:      "idensyra",
+			InputSchema: PreviewDatasetInputSchema(),
+		},
+	)
+
+	// Search
+	tools = append(tools,
+		ToolInfo{
+			Name:        "search_files",
+			Description: "Operates on Idensyra workspace - Search all workspace files for text or a regular expression, with include/exclude globs and context lines; igonb notebooks are searched per cell",
+			Target:      "idensyra",
+			InputSchema: SearchFilesInputSchema(),
+		},
+		ToolInfo{
+			Name:        "replace_in_files",
+			Description: "Operates on Idensyra workspace - Replace text or regular expression matches across workspace files; igonb notebooks only change cell sources",
+			Target:      "idensyra",
+			InputSchema: ReplaceInFilesInputSchema(),
+		},
+	)
+
+	// Git
+	tools = append(tools,
+		ToolInfo{
+			Name:        "git_status",
+			Description: "Operates on Idensyra workspace - List files that are modified, untracked, deleted or staged in the git repository containing the workspace",
+			Target:      "idensyra",
+			InputSchema: map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{},
+				"required":             []string{},
+				"additionalProperties": true,
+			},
+		},
+		ToolInfo{
+			Name:        "git_diff",
+			Description: "Operates on Idensyra workspace - Show the changes of a file, or of every changed file, against HEAD; notebooks are compared cell by cell",
+			Target:      "idensyra",
+			InputSchema: GitDiffInputSchema(),
+		},
+		ToolInfo{
+			Name:        "git_log",
+			Description: "Operates on Idensyra workspace - List recent commits of the repository or of one file",
+			Target:      "idensyra",
+			InputSchema: GitLogInputSchema(),
+		},
+		ToolInfo{
+			Name:        "git_stage",
+			Description: "Operates on Idensyra workspace - Stage files or folders, including deletions, for the next commit",
+			Target:      "idensyra",
+			InputSchema: GitStageInputSchema(),
+		},
+		ToolInfo{
+			Name:        "git_unstage",
+			Description: "Operates on Idensyra workspace - Remove staged changes of files or folders from the next commit, keeping the files",
+			Target:      "idensyra",
+			InputSchema: GitStageInputSchema(),
+		},
+		ToolInfo{
+			Name:        "git_commit",
+			Description: "Operates on Idensyra workspace - Commit the staged changes, optionally staging the given paths first",
+			Target:      "idensyra",
+			InputSchema: GitCommitInputSchema(),
+		},
+	)
+
 	// Ensure every tool has a complete inputSchema
 	for i := range tools {
 		if tools[i].InputSchema == nil {
@@ -478,6 +786,333 @@ func (s *Server) ListTools() []ToolInfo {
 	return tools
 }
 
+func (s *Server) getAuditLog(args map[string]interface{}) (*ToolResponse, error) {
+	if s.auditLog == nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: "Audit log is not enabled"}},
+			IsError: true,
+		}, fmt.Errorf("audit log not enabled")
+	}
+	query, err := ParseAuditQuery(args)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}, err
+	}
+	entries, err := s.auditLog.Read(query)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error reading audit log: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: FormatAuditEntries(entries)}},
+	}, nil
+}
+
+// queryDataset runs describe_dataset or preview_dataset
+func (s *Server) queryDataset(ctx context.Context, tool string, args map[string]interface{}) (*ToolResponse, error) {
+	fail := func(err error) (*ToolResponse, error) {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error reading dataset: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	query, err := ParseDatasetQuery(args)
+	if err != nil {
+		return fail(err)
+	}
+	var result any
+	if tool == "describe_dataset" {
+		result, err = s.datasets.Describe(ctx, query)
+	} else {
+		result, err = s.datasets.Preview(ctx, query)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	text, err := FormatDataset(result)
+	if err != nil {
+		return fail(err)
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: text}}}, nil
+}
+
+// searchFiles runs search_files
+func (s *Server) searchFiles(ctx context.Context, args map[string]interface{}) (*ToolResponse, error) {
+	opts, err := ParseSearchOptions(args)
+	if err != nil {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: err.Error()}}, IsError: true}, err
+	}
+	result, err := Search(ctx, s.search, opts, nil)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error searching files: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: FormatSearchResult(result)}}}, nil
+}
+
+// replaceInFiles runs replace_in_files. The affected files are found with a dry
+// run first so they can be authorized and checkpointed before anything changes.
+func (s *Server) replaceInFiles(ctx context.Context, args map[string]interface{}) (*ToolResponse, error) {
+	fail := func(err error) (*ToolResponse, error) {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error replacing in files: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	opts, err := ParseSearchOptions(args)
+	if err != nil {
+		return fail(err)
+	}
+	replacement, dryRun, err := ParseReplaceArgs(args)
+	if err != nil {
+		return fail(err)
+	}
+	preview, err := Replace(ctx, s.search, opts, replacement, true)
+	if err != nil {
+		return fail(err)
+	}
+	paths := preview.ReplacedPaths()
+	if dryRun || len(paths) == 0 {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: FormatReplaceResult(preview)}}}, nil
+	}
+
+	if resp, err := authorize(ctx, s.config, s.fileOps.confirmFunc, permissionPrompt{
+		Op:      OpFileEdit,
+		Paths:   paths,
+		Subject: "File edit",
+		Title:   "Replace In Files",
+		Details: fmt.Sprintf("Replace %q with %q in %s", opts.Query, replacement, strings.Join(paths, ", ")),
+	}); resp != nil {
+		return resp, err
+	}
+
+	checkpoint := s.createCheckpoint("replace_in_files", paths)
+	opts.Paths = paths
+	result, err := Replace(ctx, s.search, opts, replacement, false)
+	checkpointID := s.finishCheckpoint(checkpoint)
+	s.recordCheckpointChanges(ctx, checkpointID)
+	if err != nil {
+		return fail(err)
+	}
+	result.Checkpoint = checkpointID
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: FormatReplaceResult(result)}}}, nil
+}
+
+// gitStatus runs git_status
+func (s *Server) gitStatus(ctx context.Context) (*ToolResponse, error) {
+	statuses, err := s.git.Status(ctx)
+	if err != nil {
+		return gitError("reading git status", err)
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: FormatGitStatus(statuses)}}}, nil
+}
+
+// gitDiff runs git_diff
+func (s *Server) gitDiff(ctx context.Context, args map[string]interface{}) (*ToolResponse, error) {
+	name, _ := args["path"].(string)
+	if strings.TrimSpace(name) != "" {
+		clean, err := safeCleanRelativePath(name)
+		if err != nil {
+			return gitError("diffing", err)
+		}
+		name = clean
+	}
+	diff, err := s.git.Diff(ctx, name)
+	if err != nil {
+		return gitError("diffing", err)
+	}
+	if diff == "" {
+		diff = "No changes"
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: diff}}}, nil
+}
+
+// gitLog runs git_log
+func (s *Server) gitLog(ctx context.Context, args map[string]interface{}) (*ToolResponse, error) {
+	name, limit, err := ParseGitLogArgs(args)
+	if err != nil {
+		return gitError("reading git log", err)
+	}
+	commits, err := s.git.Log(ctx, name, limit)
+	if err != nil {
+		return gitError("reading git log", err)
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: FormatGitLog(commits)}}}, nil
+}
+
+// gitChange runs git_stage, git_unstage and git_commit, which change the repository
+// and so need the git_commit permission
+func (s *Server) gitChange(ctx context.Context, tool string, args map[string]interface{}) (*ToolResponse, error) {
+	paths, err := ParseGitPaths(args)
+	if err != nil {
+		return gitError("updating git", err)
+	}
+	message, _ := args["message"].(string)
+
+	var title, details string
+	switch tool {
+	case "git_stage":
+		title, details = "Git Stage", fmt.Sprintf("Stage %s", strings.Join(paths, ", "))
+	case "git_unstage":
+		title, details = "Git Unstage", fmt.Sprintf("Unstage %s", strings.Join(paths, ", "))
+	default:
+		if strings.TrimSpace(message) == "" {
+			return gitError("committing", fmt.Errorf("commit message cannot be empty"))
+		}
+		title, details = "Git Commit", fmt.Sprintf("Commit with message %q", message)
+		if len(paths) > 0 {
+			details += fmt.Sprintf(" after staging %s", strings.Join(paths, ", "))
+		}
+	}
+	if resp, err := authorize(ctx, s.config, s.fileOps.confirmFunc, permissionPrompt{
+		Op:      OpGitCommit,
+		Paths:   paths,
+		Subject: "Git commit",
+		Title:   title,
+		Details: details,
+	}); resp != nil {
+		return resp, err
+	}
+
+	switch tool {
+	case "git_stage":
+		if err := s.git.Stage(ctx, paths); err != nil {
+			return gitError("staging", err)
+		}
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Staged %s", strings.Join(paths, ", "))}}}, nil
+	case "git_unstage":
+		if err := s.git.Unstage(ctx, paths); err != nil {
+			return gitError("unstaging", err)
+		}
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Unstaged %s", strings.Join(paths, ", "))}}}, nil
+	}
+	if len(paths) > 0 {
+		if err := s.git.Stage(ctx, paths); err != nil {
+			return gitError("staging", err)
+		}
+	}
+	commit, err := s.git.Commit(ctx, message)
+	if err != nil {
+		return gitError("committing", err)
+	}
+	return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: "Committed " + FormatGitLog([]GitCommit{*commit})}}}, nil
+}
+
+func gitError(action string, err error) (*ToolResponse, error) {
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error %s: %v", action, err)}},
+		IsError: true,
+	}, err
+}
+
+func (s *Server) checkpointsUnavailable() (*ToolResponse, error) {
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: "Checkpoints are not enabled"}},
+		IsError: true,
+	}, fmt.Errorf("checkpoints not enabled")
+}
+
+func (s *Server) listCheckpoints(args map[string]interface{}) (*ToolResponse, error) {
+	if s.checkpoints == nil {
+		return s.checkpointsUnavailable()
+	}
+	limit := 20
+	if l, ok := args["limit"].(float64); ok {
+		limit = int(l)
+	}
+	checkpoints, err := s.checkpoints.List()
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error listing checkpoints: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: FormatCheckpoints(checkpoints, limit)}},
+	}, nil
+}
+
+func (s *Server) diffCheckpoint(args map[string]interface{}) (*ToolResponse, error) {
+	if s.checkpoints == nil {
+		return s.checkpointsUnavailable()
+	}
+	id, paths, err := ParseCheckpointArgs(args)
+	if err != nil {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: err.Error()}}, IsError: true}, err
+	}
+	changes, err := s.checkpoints.Diff(id, paths, s.checkpointFS)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error diffing checkpoint: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: FormatCheckpointDiff(id, changes)}},
+	}, nil
+}
+
+func (s *Server) restoreCheckpoint(ctx context.Context, args map[string]interface{}) (*ToolResponse, error) {
+	if s.checkpoints == nil {
+		return s.checkpointsUnavailable()
+	}
+	id, paths, err := ParseCheckpointArgs(args)
+	if err != nil {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: err.Error()}}, IsError: true}, err
+	}
+	checkpoint, err := s.checkpoints.Get(id)
+	if err != nil {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: err.Error()}}, IsError: true}, err
+	}
+	edited, deleted, err := checkpoint.RestorePaths(paths)
+	if err != nil {
+		return &ToolResponse{Content: []ContentBlock{{Type: "text", Text: err.Error()}}, IsError: true}, err
+	}
+	// Files created after the checkpoint are deleted by the restore, so they
+	// need the delete permission rather than the edit permission
+	if len(edited) > 0 {
+		if resp, err := authorize(ctx, s.config, s.fileOps.confirmFunc, permissionPrompt{
+			Op:      OpFileEdit,
+			Paths:   edited,
+			Subject: "Checkpoint restore",
+			Title:   "Restore Checkpoint",
+			Details: fmt.Sprintf("Restore %s from checkpoint %s", strings.Join(edited, ", "), id),
+		}); resp != nil {
+			return resp, err
+		}
+	}
+	if len(deleted) > 0 {
+		if resp, err := authorize(ctx, s.config, s.fileOps.confirmFunc, permissionPrompt{
+			Op:      OpFileDelete,
+			Paths:   deleted,
+			Subject: "Checkpoint restore",
+			Title:   "Restore Checkpoint",
+			Details: fmt.Sprintf("Delete %s, which did not exist at checkpoint %s", strings.Join(deleted, ", "), id),
+		}); resp != nil {
+			return resp, err
+		}
+	}
+	paths = append(edited, deleted...)
+
+	undo, err := s.checkpoints.Restore("stdio", id, paths, s.checkpointFS)
+	if err != nil {
+		return &ToolResponse{
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Error restoring checkpoint: %v", err)}},
+			IsError: true,
+		}, err
+	}
+	s.recordCheckpointChanges(ctx, undo.ID)
+	return &ToolResponse{
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Restored %d file(s) from checkpoint %s; previous state saved as checkpoint %s", len(paths), id, undo.ID)}},
+	}, nil
+}
+
 // ToolInfo represents information about a tool
 type ToolInfo struct {
 	Name        string                 `json:"name"`
@@ -486,20 +1121,99 @@ type ToolInfo struct {
 	InputSchema map[string]interface{} `json:"inputSchema"`
 }
 
-// Serve starts the MCP server and handles stdin/stdout communication
+// Serve starts the MCP server and handles stdin/stdout communication. Requests
+// with an ID run concurrently and are cancelled by a CancelledMethod
+// notification naming that ID; requests without one run in order.
 func (s *Server) Serve(ctx context.Context, input io.Reader, output io.Writer) error {
+	ctx, cancelAll := context.WithCancel(ctx)
+	defer cancelAll()
 	decoder := json.NewDecoder(input)
 	encoder := json.NewEncoder(output)
+	var writeMu sync.Mutex
+	write := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return encoder.Encode(v)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		inFlight = make(map[string]context.CancelFunc)
+		// failed holds the error of a response that could not be written
+		failed = make(chan error, 1)
+	)
+	handle := func(req ToolRequest) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		key := ""
+		if req.ID != nil {
+			key = fmt.Sprint(req.ID)
+			mu.Lock()
+			inFlight[key] = cancel
+			mu.Unlock()
+		}
+		defer func() {
+			if key != "" {
+				mu.Lock()
+				delete(inFlight, key)
+				mu.Unlock()
+			}
+			cancel()
+		}()
+
+		if req.ProgressToken != nil {
+			token := req.ProgressToken
+			reqCtx = WithProgress(reqCtx, func(p Progress) {
+				if err := write(ProgressNotification{ProgressToken: token, Progress: p}); err != nil {
+					log.Printf("Error encoding progress: %v", err)
+				}
+			})
+		}
+
+		resp, err := s.HandleRequest(reqCtx, &req)
+		if err != nil {
+			log.Printf("Error handling request: %v", err)
+		}
+		var out any = resp
+		if req.ID != nil {
+			out = stdioResponse{ID: req.ID, ToolResponse: resp}
+		}
+		if err := write(out); err != nil {
+			log.Printf("Error encoding response: %v", err)
+			select {
+			case failed <- err:
+			default:
+			}
+			cancelAll()
+		}
+	}
+
+	// Requests without an ID keep their order, as their responses can only be
+	// told apart by it
+	ordered := make(chan ToolRequest, 16)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for req := range ordered {
+			handle(req)
+		}
+	}()
+	defer func() {
+		close(ordered)
+		wg.Wait()
+	}()
 
 	for {
 		select {
+		case err := <-failed:
+			return err
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		var req ToolRequest
-		if err := decoder.Decode(&req); err != nil {
+		var msg stdioMessage
+		if err := decoder.Decode(&msg); err != nil {
 			if err == io.EOF {
 				return nil
 			}
@@ -507,14 +1221,28 @@ func (s *Server) Serve(ctx context.Context, input io.Reader, output io.Writer) e
 			continue
 		}
 
-		resp, err := s.HandleRequest(ctx, &req)
-		if err != nil {
-			log.Printf("Error handling request: %v", err)
-		}
-
-		if err := encoder.Encode(resp); err != nil {
-			log.Printf("Error encoding response: %v", err)
-			return err
+		switch {
+		case msg.Method == CancelledMethod:
+			mu.Lock()
+			cancel, ok := inFlight[fmt.Sprint(msg.Params.RequestID)]
+			mu.Unlock()
+			if ok {
+				cancel()
+			}
+		case msg.Method != "":
+			log.Printf("Ignoring notification %s", msg.Method)
+		case msg.ID == nil:
+			select {
+			case ordered <- msg.ToolRequest:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			wg.Add(1)
+			go func(req ToolRequest) {
+				defer wg.Done()
+				handle(req)
+			}(msg.ToolRequest)
 		}
 	}
 }
@@ -1,5 +1,7 @@
 package mcp
 
+import "encoding/json"
+
 // PermissionLevel defines the permission level for MCP operations
 type PermissionLevel int
 
@@ -15,23 +17,33 @@ const (
 // Config holds the MCP server configuration
 type Config struct {
 	// File operation permissions
-	FileEdit   PermissionLevel
-	FileRename PermissionLevel
-	FileCreate PermissionLevel
-	FileDelete PermissionLevel
+	FileEdit   PermissionLevel `json:"file_edit" yaml:"file_edit"`
+	FileRename PermissionLevel `json:"file_rename" yaml:"file_rename"`
+	FileCreate PermissionLevel `json:"file_create" yaml:"file_create"`
+	FileDelete PermissionLevel `json:"file_delete" yaml:"file_delete"`
 
 	// Execution permissions
-	ExecuteGo     PermissionLevel
-	ExecutePython PermissionLevel
+	ExecuteGo     PermissionLevel `json:"execute_go" yaml:"execute_go"`
+	ExecutePython PermissionLevel `json:"execute_python" yaml:"execute_python"`
+	ExecuteShell  PermissionLevel `json:"execute_shell" yaml:"execute_shell"`
 
 	// Notebook permissions
-	NotebookModify  PermissionLevel
-	NotebookExecute PermissionLevel
+	NotebookModify  PermissionLevel `json:"notebook_modify" yaml:"notebook_modify"`
+	NotebookExecute PermissionLevel `json:"notebook_execute" yaml:"notebook_execute"`
 
 	// Workspace permissions
-	WorkspaceOpen   PermissionLevel
-	WorkspaceSave   PermissionLevel
-	WorkspaceModify PermissionLevel
+	WorkspaceOpen   PermissionLevel `json:"workspace_open" yaml:"workspace_open"`
+	WorkspaceSave   PermissionLevel `json:"workspace_save" yaml:"workspace_save"`
+	WorkspaceModify PermissionLevel `json:"workspace_modify" yaml:"workspace_modify"`
+
+	// Git permissions (staging and committing)
+	GitCommit PermissionLevel `json:"git_commit" yaml:"git_commit"`
+
+	// Path rules override the levels above for matching workspace paths
+	PathRules []PathRule `json:"path_rules,omitempty" yaml:"path_rules,omitempty"`
+
+	// Timeouts maps tool names (or "default") to durations such as "90s" or "15m"
+	Timeouts map[string]string `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
 }
 
 // DefaultConfig returns a default configuration with all permissions set to ask
@@ -43,11 +55,13 @@ func DefaultConfig() *Config {
 		FileDelete:      PermissionAsk,
 		ExecuteGo:       PermissionAsk,
 		ExecutePython:   PermissionAsk,
+		ExecuteShell:    PermissionAsk,
 		NotebookModify:  PermissionAsk,
 		NotebookExecute: PermissionAsk,
 		WorkspaceOpen:   PermissionAsk,
 		WorkspaceSave:   PermissionAsk,
 		WorkspaceModify: PermissionAsk,
+		GitCommit:       PermissionAsk,
 	}
 }
 
@@ -55,6 +69,11 @@ func DefaultConfig() *Config {
 type ToolRequest struct {
 	Name      string                 `json:"name"`
 	Arguments map[string]interface{} `json:"arguments"`
+	// ProgressToken asks for progress notifications tagged with this token
+	ProgressToken any `json:"progressToken,omitempty"`
+	// ID identifies the request on the stdio stream; requests with an ID run
+	// concurrently, can be cancelled and get it back in their response
+	ID any `json:"id,omitempty"`
 }
 
 // ToolResponse represents a generic MCP tool response
@@ -71,7 +90,16 @@ type ContentBlock struct {
 
 // NotebookCell represents a cell in a notebook
 type NotebookCell struct {
+	ID       string `json:"id"`
 	Language string `json:"language"`
 	Source   string `json:"source"`
 	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Execution is the record of the cell's last run, kept as is
+	Execution json.RawMessage `json:"execution,omitempty"`
+	// Metadata holds per-cell settings such as skip, frozen, tags and timeout
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// Outputs and Attachments are the Jupyter data kept from an .ipynb import
+	Outputs     json.RawMessage `json:"outputs,omitempty"`
+	Attachments json.RawMessage `json:"attachments,omitempty"`
 }
@@ -39,20 +39,13 @@ func NewWorkspaceManagement(
 
 // OpenWorkspace opens a workspace directory
 func (wm *WorkspaceManagement) OpenWorkspace(ctx context.Context, path string) (*ToolResponse, error) {
-	if wm.config.WorkspaceOpen == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Workspace open permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if wm.config.WorkspaceOpen == PermissionAsk && wm.confirmFunc != nil {
-		if !wm.confirmFunc("Workspace Open", fmt.Sprintf("Open workspace: %s", path)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Workspace open cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, wm.config, wm.confirmFunc, permissionPrompt{
+		Op:      OpWorkspaceOpen,
+		Subject: "Workspace open",
+		Title:   "Workspace Open",
+		Details: fmt.Sprintf("Open workspace: %s", path),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Check if directory exists
@@ -90,20 +83,13 @@ func (wm *WorkspaceManagement) OpenWorkspace(ctx context.Context, path string) (
 
 // SaveTempWorkspace saves the temporary workspace to a specified path
 func (wm *WorkspaceManagement) SaveTempWorkspace(ctx context.Context, targetPath string) (*ToolResponse, error) {
-	if wm.config.WorkspaceSave == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Workspace save permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if wm.config.WorkspaceSave == PermissionAsk && wm.confirmFunc != nil {
-		if !wm.confirmFunc("Workspace Save", fmt.Sprintf("Save workspace to: %s", targetPath)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Workspace save cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, wm.config, wm.confirmFunc, permissionPrompt{
+		Op:      OpWorkspaceSave,
+		Subject: "Workspace save",
+		Title:   "Workspace Save",
+		Details: fmt.Sprintf("Save workspace to: %s", targetPath),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Create the target directory if it doesn't exist
@@ -133,20 +119,13 @@ func (wm *WorkspaceManagement) SaveTempWorkspace(ctx context.Context, targetPath
 
 // SaveChanges saves all unsaved changes in the current workspace
 func (wm *WorkspaceManagement) SaveChanges(ctx context.Context) (*ToolResponse, error) {
-	if wm.config.WorkspaceModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Workspace save changes permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if wm.config.WorkspaceModify == PermissionAsk && wm.confirmFunc != nil {
-		if !wm.confirmFunc("Save Changes", "Save all unsaved changes in the workspace") {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Save changes cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, wm.config, wm.confirmFunc, permissionPrompt{
+		Op:      OpWorkspaceModify,
+		Subject: "Save changes",
+		Title:   "Save Changes",
+		Details: "Save all unsaved changes in the workspace",
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Use the provided save changes function if available
@@ -201,20 +180,14 @@ func (wm *WorkspaceManagement) GetWorkspaceInfo(ctx context.Context) (*ToolRespo
 
 // CreateWorkspaceDirectory creates a new directory in the workspace
 func (wm *WorkspaceManagement) CreateWorkspaceDirectory(ctx context.Context, relativePath string) (*ToolResponse, error) {
-	if wm.config.WorkspaceModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Workspace modify permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if wm.config.WorkspaceModify == PermissionAsk && wm.confirmFunc != nil {
-		if !wm.confirmFunc("Create Directory", fmt.Sprintf("Create directory: %s", relativePath)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Create directory cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, wm.config, wm.confirmFunc, permissionPrompt{
+		Op:      OpWorkspaceModify,
+		Paths:   []string{relativePath},
+		Subject: "Create directory",
+		Title:   "Create Directory",
+		Details: fmt.Sprintf("Create directory: %s", relativePath),
+	}); resp != nil {
+		return resp, err
 	}
 
 	if wm.currentWorkspace == "" {
@@ -240,20 +213,14 @@ func (wm *WorkspaceManagement) CreateWorkspaceDirectory(ctx context.Context, rel
 
 // ImportFileToWorkspace imports an external file into the workspace
 func (wm *WorkspaceManagement) ImportFileToWorkspace(ctx context.Context, sourcePath, targetDir string) (*ToolResponse, error) {
-	if wm.config.WorkspaceModify == PermissionDeny {
-		return &ToolResponse{
-			Content: []ContentBlock{{Type: "text", Text: "Workspace modify permission denied"}},
-			IsError: true,
-		}, fmt.Errorf("permission denied")
-	}
-
-	if wm.config.WorkspaceModify == PermissionAsk && wm.confirmFunc != nil {
-		if !wm.confirmFunc("Import File", fmt.Sprintf("Import file: %s to directory: %s", sourcePath, targetDir)) {
-			return &ToolResponse{
-				Content: []ContentBlock{{Type: "text", Text: "Import file cancelled by user"}},
-				IsError: true,
-			}, fmt.Errorf("cancelled by user")
-		}
+	if resp, err := authorize(ctx, wm.config, wm.confirmFunc, permissionPrompt{
+		Op:      OpWorkspaceModify,
+		Paths:   []string{targetDir},
+		Subject: "Import file",
+		Title:   "Import File",
+		Details: fmt.Sprintf("Import file: %s to directory: %s", sourcePath, targetDir),
+	}); resp != nil {
+		return resp, err
 	}
 
 	// Check if source file exists
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// mcpToolHandler is the handler signature shared by all SDK tools
+type mcpToolHandler func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error)
+
+// addTool registers a tool whose calls are checkpointed, recorded in the workspace audit log
+// and may report progress
+func (m *MCPServer) addTool(tool *sdk.Tool, handler mcpToolHandler) {
+	sdk.AddTool(m.server, tool, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		ctx = withProgress(ctx, tool.Name, req)
+		ctx, recorder := mcp.WithAuditRecorder(ctx)
+		paths := mcp.MutatedPaths(tool.Name, args)
+		store, checkpoint := m.createCheckpoint(tool.Name, paths)
+		before := snapshotWorkspaceTextFiles(paths)
+		start := time.Now()
+
+		res, out, err := handler(ctx, req, args)
+
+		entry := mcp.AuditEntry{
+			Time:       start,
+			Source:     "gui",
+			Tool:       tool.Name,
+			Arguments:  mcp.AuditArguments(args),
+			Decisions:  recorder.Decisions(),
+			DurationMs: time.Since(start).Milliseconds(),
+			Files:      append(mcp.FileChanges(paths, before, snapshotWorkspaceTextFiles(paths)), recorder.Files()...),
+			Checkpoint: m.finishCheckpoint(store, checkpoint),
+		}
+		if entry.Checkpoint == "" {
+			entry.Checkpoint = recorder.Checkpoint()
+		}
+		if res != nil {
+			entry.IsError = res.IsError
+			for _, content := range res.Content {
+				if text, ok := content.(*sdk.TextContent); ok {
+					entry.Summary = mcp.AuditSummary(text.Text)
+					break
+				}
+			}
+		}
+		if err != nil {
+			entry.IsError = true
+			entry.Error = err.Error()
+		}
+		m.appendAuditEntry(entry)
+
+		return res, out, err
+	})
+}
+
+// auditLog returns the audit log of the current workspace, or nil without one
+func (m *MCPServer) auditLog() *mcp.AuditLog {
+	if globalWorkspace == nil {
+		return nil
+	}
+	globalWorkspace.mu.RLock()
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+	if workDir == "" {
+		return nil
+	}
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.auditLogs == nil {
+		m.auditLogs = make(map[string]*mcp.AuditLog)
+	}
+	auditLog, ok := m.auditLogs[workDir]
+	if !ok {
+		auditLog = mcp.NewAuditLog(mcp.AuditLogPath(workDir))
+		m.auditLogs[workDir] = auditLog
+	}
+	return auditLog
+}
+
+func (m *MCPServer) appendAuditEntry(entry mcp.AuditEntry) {
+	auditLog := m.auditLog()
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Append(entry); err != nil {
+		log.Printf("[MCP] Error writing audit log: %v", err)
+		return
+	}
+	if m.app != nil && m.app.ctx != nil {
+		runtime.EventsEmit(m.app.ctx, "mcp:audit", entry)
+	}
+}
+
+// snapshotWorkspaceTextFiles captures the in-memory contents of text files for diffing.
+// Missing, binary and too-large files are left out.
+func snapshotWorkspaceTextFiles(paths []string) map[string]*string {
+	snapshot := make(map[string]*string, len(paths))
+	if globalWorkspace == nil || len(paths) == 0 {
+		return snapshot
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	for _, p := range paths {
+		cleanName, err := cleanRelativePath(p)
+		if err != nil {
+			continue
+		}
+		file, ok := globalWorkspace.files[cleanName]
+		if !ok || file.IsDir || file.IsBinary || file.TooLarge {
+			continue
+		}
+		content, err := workspaceFileContentLocked(cleanName, file)
+		if err != nil {
+			continue
+		}
+		snapshot[p] = &content
+	}
+	return snapshot
+}
+
+// registerAuditTools registers the get_audit_log tool
+func (m *MCPServer) registerAuditTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "get_audit_log",
+		Description: "Operates on Idensyra workspace - Read the audit log of MCP tool calls (tool, arguments, permission decisions, duration, result summary, file diffs)",
+		InputSchema: mcp.AuditLogInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		auditLog := m.auditLog()
+		if auditLog == nil {
+			return nil, nil, fmt.Errorf("workspace not initialized")
+		}
+		query, err := mcp.ParseAuditQuery(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries, err := auditLog.Read(query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: mcp.FormatAuditEntries(entries)},
+			},
+		}, nil, nil
+	})
+}
+
+// GetMCPAuditLog returns the most recent MCP audit entries of the current workspace
+func (a *App) GetMCPAuditLog(limit int, tool string) ([]mcp.AuditEntry, error) {
+	if a.mcpServer == nil {
+		return []mcp.AuditEntry{}, nil
+	}
+	auditLog := a.mcpServer.auditLog()
+	if auditLog == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	return auditLog.Read(mcp.AuditQuery{Limit: limit, Tool: tool})
+}
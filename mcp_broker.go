@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+)
+
+// MCPAttachment is returned to a frontend that attaches to the request broker
+type MCPAttachment struct {
+	Token   string          `json:"token"`
+	Pending []mcp.UIRequest `json:"pending"`
+}
+
+// MCPAttachUI is called by the frontend once its MCP handlers are registered.
+// It returns the token for posting results and the requests to (re)handle after a reload.
+func (a *App) MCPAttachUI() MCPAttachment {
+	if a.mcpServer == nil {
+		return MCPAttachment{Pending: []mcp.UIRequest{}}
+	}
+	return MCPAttachment{
+		Token:   a.mcpServer.broker.Token(),
+		Pending: a.mcpServer.broker.Attach(),
+	}
+}
+
+// MCPDetachUI is called by the frontend before it unloads so requests use backend fallbacks
+func (a *App) MCPDetachUI() {
+	if a.mcpServer != nil {
+		a.mcpServer.broker.Detach()
+	}
+}
+
+// MCPExecutionResult is called by frontend to send back results for MCP requests
+func (a *App) MCPExecutionResult(result mcp.UIResult) error {
+	if a.mcpServer == nil {
+		return fmt.Errorf("mcp server not running")
+	}
+	return a.mcpServer.deliverExecutionResult(result)
+}
+
+// registerUIFallbacks installs backend-only handlers used while no frontend is attached
+func (m *MCPServer) registerUIFallbacks() {
+	app := m.app
+	text := func(fn func(req mcp.UIRequest) (string, error)) mcp.UIFallback {
+		return func(ctx context.Context, req mcp.UIRequest) (string, error) {
+			return fn(req)
+		}
+	}
+
+	m.broker.SetFallback("read_file", text(func(req mcp.UIRequest) (string, error) {
+		return app.GetFileContent(payloadString(req, "path"))
+	}))
+	m.broker.SetFallback("write_file", text(func(req mcp.UIRequest) (string, error) {
+		path := payloadString(req, "path")
+		if err := app.UpdateFileContent(path, payloadString(req, "content")); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("File updated in workspace (unsaved): %s", path), nil
+	}))
+	m.broker.SetFallback("create_file", text(func(req mcp.UIRequest) (string, error) {
+		path := payloadString(req, "path")
+		if err := app.CreateNewFile(path); err != nil {
+			return "", err
+		}
+		if content := payloadString(req, "content"); content != "" {
+			if err := app.UpdateFileContent(path, content); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("File created successfully: %s", path), nil
+	}))
+	m.broker.SetFallback("delete_file", text(func(req mcp.UIRequest) (string, error) {
+		path := payloadString(req, "path")
+		if err := app.DeleteFile(path); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("File deleted successfully: %s", path), nil
+	}))
+	m.broker.SetFallback("rename_file", text(func(req mcp.UIRequest) (string, error) {
+		oldPath := payloadString(req, "old_path")
+		newPath := strings.TrimSpace(payloadString(req, "new_path"))
+		if oldPath == newPath {
+			return fmt.Sprintf("File name unchanged: %s", oldPath), nil
+		}
+		if err := app.RenameFile(oldPath, newPath); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("File renamed successfully: %s -> %s", oldPath, newPath), nil
+	}))
+	m.broker.SetFallback("list_files", text(func(req mcp.UIRequest) (string, error) {
+		return listWorkspaceDir(app.GetWorkspaceFiles(), payloadString(req, "dir_path")), nil
+	}))
+	m.broker.SetFallback("import_file_to_workspace", text(func(req mcp.UIRequest) (string, error) {
+		sourcePath := payloadString(req, "source_path")
+		if err := app.ImportSpecificFileToWorkspace(sourcePath, payloadString(req, "target_dir")); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("File imported successfully from %s", sourcePath), nil
+	}))
+	m.broker.SetFallback("open_workspace", text(func(req mcp.UIRequest) (string, error) {
+		if app.IsWorkspaceModified() {
+			return "", fmt.Errorf("workspace has unsaved changes")
+		}
+		workspacePath, err := app.OpenWorkspaceAt(payloadString(req, "path"))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Workspace opened: %s", workspacePath), nil
+	}))
+	m.broker.SetFallback("save_workspace", text(func(req mcp.UIRequest) (string, error) {
+		workspacePath, err := app.CreateWorkspaceAt(payloadString(req, "path"))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Workspace saved to: %s", workspacePath), nil
+	}))
+	m.broker.SetFallback("save_all_files", text(func(req mcp.UIRequest) (string, error) {
+		if err := app.SaveAllFiles(); err != nil {
+			return "", err
+		}
+		return "All files saved successfully", nil
+	}))
+	m.broker.SetFallback("get_workspace_info", text(func(req mcp.UIRequest) (string, error) {
+		data, err := json.Marshal(app.GetWorkspaceInfo())
+		return string(data), err
+	}))
+
+	// Code runs in the backend interpreter or Python; cancelling the tool call stops it
+	runGo := func(ctx context.Context, code string) string {
+		stop := context.AfterFunc(ctx, stopGoExecution)
+		defer stop()
+		return executeGoCode(code, "dark")
+	}
+	m.broker.SetFallback("execute_go_file", func(ctx context.Context, req mcp.UIRequest) (string, error) {
+		return runGo(ctx, payloadString(req, "content")), nil
+	})
+	m.broker.SetFallback("execute_go_code", func(ctx context.Context, req mcp.UIRequest) (string, error) {
+		return runGo(ctx, payloadString(req, "code")), nil
+	})
+	m.broker.SetFallback("execute_python_file", func(ctx context.Context, req mcp.UIRequest) (string, error) {
+		stop := context.AfterFunc(ctx, stopGoExecution)
+		defer stop()
+		return app.ExecutePythonFileContent(payloadString(req, "path"), payloadString(req, "content"))
+	})
+	m.broker.SetFallback("execute_python_code", func(ctx context.Context, req mcp.UIRequest) (string, error) {
+		stop := context.AfterFunc(ctx, stopGoExecution)
+		defer stop()
+		return executePythonCode(pythonEncodingSetup+withPythonEnvPrelude(payloadString(req, "code")), "dark"), nil
+	})
+}
+
+func payloadString(req mcp.UIRequest, key string) string {
+	value, _ := req.Payload[key].(string)
+	return value
+}
+
+// listWorkspaceDir formats the direct children of dir the same way the frontend does
+func listWorkspaceDir(files []WorkspaceFile, dir string) string {
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	var b strings.Builder
+	for _, f := range files {
+		name := f.Name
+		if dir != "" {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			name = name[len(dir)+1:]
+		}
+		if strings.Contains(name, "/") {
+			continue
+		}
+		if f.IsDir {
+			fmt.Fprintf(&b, "[DIR]  %s\n", name)
+		} else {
+			fmt.Fprintf(&b, "[FILE] %s (%d bytes)\n", name, f.Size)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// workspaceCheckpointFS snapshots files as the user sees them, including unsaved edits.
+// Binary and too-large files are read from and restored to disk.
+type workspaceCheckpointFS struct {
+	app *App
+}
+
+func (w workspaceCheckpointFS) lookup(path string) (string, *WorkspaceFile, string, error) {
+	if globalWorkspace == nil {
+		return "", nil, "", fmt.Errorf("workspace not initialized")
+	}
+	cleanName, err := cleanRelativePath(path)
+	if err != nil {
+		return "", nil, "", err
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	file, ok := globalWorkspace.files[cleanName]
+	if !ok {
+		return cleanName, nil, globalWorkspace.workDir, nil
+	}
+	copied := *file
+	return cleanName, &copied, globalWorkspace.workDir, nil
+}
+
+// ReadFile returns the in-memory content of loaded text files and the disk content otherwise
+func (w workspaceCheckpointFS) ReadFile(path string) ([]byte, error) {
+	cleanName, file, workDir, err := w.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("file not found: %s: %w", cleanName, os.ErrNotExist)
+	}
+	if file.IsDir {
+		return nil, fmt.Errorf("path is a directory: %s", cleanName)
+	}
+	fullPath := filepath.Join(workDir, filepath.FromSlash(cleanName))
+	if file.IsBinary || file.TooLarge {
+		return os.ReadFile(fullPath)
+	}
+	if !file.Loaded {
+		content, _, _, err := readWorkspaceDiskFile(cleanName, fullPath, file.Size)
+		return []byte(content), err
+	}
+	return []byte(file.Content), nil
+}
+
+// WriteFile restores a file. Existing text files become unsaved edits like write_file;
+// files that no longer exist are recreated on disk.
+func (w workspaceCheckpointFS) WriteFile(path string, data []byte) error {
+	cleanName, file, workDir, err := w.lookup(path)
+	if err != nil {
+		return err
+	}
+	if file != nil && file.IsDir {
+		return fmt.Errorf("path is a directory: %s", cleanName)
+	}
+
+	if shouldTreatAsBinary(cleanName, data) || isFileTooLarge(int64(len(data))) || (file != nil && (file.IsBinary || file.TooLarge)) {
+		fullPath := filepath.Join(workDir, filepath.FromSlash(cleanName))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if err := os.WriteFile(fullPath, data, 0644); err != nil {
+			return err
+		}
+		globalWorkspace.mu.Lock()
+		refreshWorkspaceFromDiskLocked()
+		globalWorkspace.mu.Unlock()
+		return nil
+	}
+
+	if file == nil {
+		if err := w.app.CreateNewFile(cleanName); err != nil {
+			return err
+		}
+		if err := w.app.UpdateFileContent(cleanName, string(data)); err != nil {
+			return err
+		}
+		return w.app.SaveFile(cleanName)
+	}
+	return w.app.UpdateFileContent(cleanName, string(data))
+}
+
+// DeleteFile removes a file from the workspace and disk
+func (w workspaceCheckpointFS) DeleteFile(path string) error {
+	cleanName, file, _, err := w.lookup(path)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return fmt.Errorf("file not found: %s: %w", cleanName, os.ErrNotExist)
+	}
+	return w.app.DeleteFile(cleanName)
+}
+
+// checkpointStore returns the checkpoint store of the current workspace, or nil without one
+func (m *MCPServer) checkpointStore() *mcp.CheckpointStore {
+	if globalWorkspace == nil {
+		return nil
+	}
+	globalWorkspace.mu.RLock()
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+	if workDir == "" {
+		return nil
+	}
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.checkpointStores == nil {
+		m.checkpointStores = make(map[string]*mcp.CheckpointStore)
+	}
+	store, ok := m.checkpointStores[workDir]
+	if !ok {
+		store = mcp.NewCheckpointStore(mcp.CheckpointStorePath(workDir))
+		m.checkpointStores[workDir] = store
+	}
+	return store
+}
+
+// createCheckpoint snapshots the files a tool call may change
+func (m *MCPServer) createCheckpoint(tool string, paths []string) (*mcp.CheckpointStore, *mcp.Checkpoint) {
+	store := m.checkpointStore()
+	if store == nil || len(paths) == 0 {
+		return nil, nil
+	}
+	checkpoint, err := store.Create("gui", tool, paths, workspaceCheckpointFS{app: m.app})
+	if err != nil {
+		log.Printf("[MCP] Error creating checkpoint: %v", err)
+		return nil, nil
+	}
+	return store, checkpoint
+}
+
+// finishCheckpoint drops a checkpoint when the call changed nothing and returns the ID kept
+func (m *MCPServer) finishCheckpoint(store *mcp.CheckpointStore, checkpoint *mcp.Checkpoint) string {
+	if checkpoint == nil {
+		return ""
+	}
+	changes, err := store.Diff(checkpoint.ID, nil, workspaceCheckpointFS{app: m.app})
+	if err == nil && len(changes) == 0 {
+		if err := store.Delete(checkpoint.ID); err != nil {
+			log.Printf("[MCP] Error deleting checkpoint: %v", err)
+		}
+		return ""
+	}
+	return checkpoint.ID
+}
+
+// recordCheckpointChanges reports the changes made since a checkpoint to the audit log
+func (m *MCPServer) recordCheckpointChanges(ctx context.Context, store *mcp.CheckpointStore, id string) {
+	if store == nil || id == "" {
+		return
+	}
+	changes, err := store.Diff(id, nil, workspaceCheckpointFS{app: m.app})
+	if err != nil {
+		log.Printf("[MCP] Error diffing checkpoint: %v", err)
+		return
+	}
+	mcp.RecordFileChanges(ctx, changes, id)
+}
+
+// registerCheckpointTools registers the list/diff/restore checkpoint tools
+func (m *MCPServer) registerCheckpointTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "list_checkpoints",
+		Description: "Operates on Idensyra workspace - List checkpoints taken automatically before MCP tool calls changed files",
+		InputSchema: mcp.ListCheckpointsInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		store := m.checkpointStore()
+		if store == nil {
+			return nil, nil, fmt.Errorf("workspace not initialized")
+		}
+		limit := 20
+		if l, ok := args["limit"].(float64); ok {
+			limit = int(l)
+		}
+		checkpoints, err := store.List()
+		if err != nil {
+			return nil, nil, err
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: mcp.FormatCheckpoints(checkpoints, limit)},
+			},
+		}, nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "diff_checkpoint",
+		Description: "Operates on Idensyra workspace - Show how files changed since a checkpoint",
+		InputSchema: mcp.CheckpointInputSchema("diff"),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		id, paths, err := mcp.ParseCheckpointArgs(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes, err := m.app.DiffMCPCheckpoint(id, paths)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: mcp.FormatCheckpointDiff(id, changes)},
+			},
+		}, nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "restore_checkpoint",
+		Description: "Operates on Idensyra workspace - Restore files to their state at a checkpoint (the current state is checkpointed first)",
+		InputSchema: mcp.CheckpointInputSchema("restore"),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		id, paths, err := mcp.ParseCheckpointArgs(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		store := m.checkpointStore()
+		if store == nil {
+			return nil, nil, fmt.Errorf("workspace not initialized")
+		}
+		checkpoint, err := store.Get(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		edited, deleted, err := checkpoint.RestorePaths(paths)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Files created after the checkpoint are deleted by the restore, so they
+		// need the delete permission rather than the edit permission
+		if len(edited) > 0 {
+			if err := m.authorize(ctx, mcp.OpFileEdit, "Restore Checkpoint", fmt.Sprintf("Restore %s from checkpoint %s", strings.Join(edited, ", "), id), edited...); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(deleted) > 0 {
+			if err := m.authorize(ctx, mcp.OpFileDelete, "Restore Checkpoint", fmt.Sprintf("Delete %s, which did not exist at checkpoint %s", strings.Join(deleted, ", "), id), deleted...); err != nil {
+				return nil, nil, err
+			}
+		}
+		paths = append(edited, deleted...)
+
+		undoID, err := m.app.RestoreMCPCheckpoint(id, paths)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.recordCheckpointChanges(ctx, store, undoID)
+		if m.app.ctx != nil {
+			runtime.EventsEmit(m.app.ctx, "mcp:checkpoint_restored", paths)
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: fmt.Sprintf("Restored %d file(s) from checkpoint %s; previous state saved as checkpoint %s", len(paths), id, undoID)},
+			},
+		}, nil, nil
+	})
+}
+
+// ListMCPCheckpoints returns the checkpoints of the current workspace, newest first
+func (a *App) ListMCPCheckpoints() ([]mcp.Checkpoint, error) {
+	if a.mcpServer == nil {
+		return []mcp.Checkpoint{}, nil
+	}
+	store := a.mcpServer.checkpointStore()
+	if store == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	return store.List()
+}
+
+// DiffMCPCheckpoint returns how files changed since a checkpoint (all files when paths is empty)
+func (a *App) DiffMCPCheckpoint(id string, paths []string) ([]mcp.AuditFileChange, error) {
+	if a.mcpServer == nil {
+		return nil, fmt.Errorf("mcp server not available")
+	}
+	store := a.mcpServer.checkpointStore()
+	if store == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	return store.Diff(id, paths, workspaceCheckpointFS{app: a})
+}
+
+// RestoreMCPCheckpoint restores files from a checkpoint and returns the ID of the
+// checkpoint holding the state before the restore
+func (a *App) RestoreMCPCheckpoint(id string, paths []string) (string, error) {
+	if a.mcpServer == nil {
+		return "", fmt.Errorf("mcp server not available")
+	}
+	store := a.mcpServer.checkpointStore()
+	if store == nil {
+		return "", fmt.Errorf("workspace not initialized")
+	}
+	undo, err := store.Restore("gui", id, paths, workspaceCheckpointFS{app: a})
+	if err != nil {
+		return "", err
+	}
+	return undo.ID, nil
+}
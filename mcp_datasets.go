@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/HazelnutParadise/insyra/parquet"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// workspaceDatasets reads datasets from the workspace, including unsaved edits
+var workspaceDatasets = mcp.DatasetSource{
+	Open:        openWorkspaceDataset,
+	ReadParquet: readWorkspaceParquet,
+}
+
+// openWorkspaceDataset returns the in-memory content of a workspace file,
+// streaming it from disk when it was too large to load
+func openWorkspaceDataset(path string) (io.ReadCloser, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	file, exists := globalWorkspace.files[path]
+	var content string
+	var isDir, isBinary, onDisk bool
+	if exists {
+		content, isDir, isBinary = file.Content, file.IsDir, file.IsBinary
+		onDisk = file.TooLarge || !file.Loaded
+	}
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+
+	switch {
+	case !exists:
+		return nil, fmt.Errorf("file not found: %s", path)
+	case isDir:
+		return nil, fmt.Errorf("path is a directory: %s", path)
+	case onDisk:
+		return os.Open(filepath.Join(workDir, filepath.FromSlash(path)))
+	case isBinary:
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode file content: %w", err)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func readWorkspaceParquet(ctx context.Context, path string) (mcp.Table, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+	dt, err := parquet.Read(ctx, filepath.Join(workDir, filepath.FromSlash(path)), parquet.ReadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return dt, nil
+}
+
+// registerDatasetTools registers describe_dataset, preview_dataset and query_variable
+func (m *MCPServer) registerDatasetTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "describe_dataset",
+		Description: "Operates on Idensyra workspace - Describe a CSV, TSV, Excel or Parquet file as JSON: row count, column types, null and distinct counts and numeric summary statistics. " + mcp.DatasetStatsNote,
+		InputSchema: mcp.DescribeDatasetInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		query, err := mcp.ParseDatasetQuery(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		desc, err := workspaceDatasets.Describe(ctx, query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading dataset: %w", err)
+		}
+		return datasetResult(desc)
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "preview_dataset",
+		Description: "Operates on Idensyra workspace - Return a page of rows of a CSV, TSV, Excel or Parquet file as JSON",
+		InputSchema: mcp.PreviewDatasetInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		query, err := mcp.ParseDatasetQuery(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		page, err := workspaceDatasets.Preview(ctx, query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading dataset: %w", err)
+		}
+		return datasetResult(page)
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "query_variable",
+		Description: "Operates on Idensyra workspace - Page through a DataTable or DataList variable of a live notebook session as JSON, or describe its columns",
+		InputSchema: mcp.QueryVariableInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		query, err := mcp.ParseDatasetQuery(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if query.Variable == "" {
+			return nil, nil, fmt.Errorf("missing variable name")
+		}
+		key := getIgonbExecutorKey()
+		if query.Notebook != "" && globalWorkspace != nil {
+			notebook, err := cleanRelativePath(query.Notebook)
+			if err != nil {
+				return nil, nil, err
+			}
+			globalWorkspace.mu.RLock()
+			key = filepath.Join(globalWorkspace.workDir, filepath.FromSlash(notebook))
+			globalWorkspace.mu.RUnlock()
+		}
+		value, ok := igonbRunner.Variable(key, query.Variable)
+		if !ok {
+			return nil, nil, fmt.Errorf("variable %q not found in the session of %s", query.Variable, workspaceRelativePath(key))
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		result, err := mcp.QueryVariable(ctx, query, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		return datasetResult(result)
+	})
+}
+
+func datasetResult(v any) (*sdk.CallToolResult, any, error) {
+	text, err := mcp.FormatDataset(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &sdk.CallToolResult{
+		Content: []sdk.Content{
+			&sdk.TextContent{Text: text},
+		},
+	}, nil, nil
+}
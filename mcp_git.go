@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerGitTools registers git_status, git_diff, git_log, git_stage, git_unstage and git_commit
+func (m *MCPServer) registerGitTools() {
+	text := func(s string) *sdk.CallToolResult {
+		return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: s}}}
+	}
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_status",
+		Description: "Operates on Idensyra workspace - List files that are modified, untracked, deleted or staged in the git repository containing the workspace",
+		InputSchema: map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{},
+			"required":             []string{},
+			"additionalProperties": true,
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		repo, err := workspaceGitRepo()
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		statuses, err := repo.Status(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading git status: %w", err)
+		}
+		return text(mcp.FormatGitStatus(statuses)), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_diff",
+		Description: "Operates on Idensyra workspace - Show the saved changes of a file, or of every changed file, against HEAD; notebooks are compared cell by cell",
+		InputSchema: mcp.GitDiffInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		name, _ := args["path"].(string)
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		repo, err := workspaceGitRepo()
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.TrimSpace(name) != "" {
+			if name, err = cleanRelativePath(name); err != nil {
+				return nil, nil, err
+			}
+		}
+		diff, err := repo.Diff(ctx, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error diffing: %w", err)
+		}
+		if diff == "" {
+			diff = "No changes"
+		}
+		return text(diff), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_log",
+		Description: "Operates on Idensyra workspace - List recent commits of the repository or of one file",
+		InputSchema: mcp.GitLogInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		name, limit, err := mcp.ParseGitLogArgs(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		repo, err := workspaceGitRepo()
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		commits, err := repo.Log(ctx, name, limit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading git log: %w", err)
+		}
+		return text(mcp.FormatGitLog(commits)), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_stage",
+		Description: "Operates on Idensyra workspace - Save and stage files or folders, including deletions, for the next commit",
+		InputSchema: mcp.GitStageInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		paths, err := mcp.ParseGitPaths(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.authorize(ctx, mcp.OpGitCommit, "Git Stage", fmt.Sprintf("Stage %s", strings.Join(paths, ", ")), paths...); err != nil {
+			return nil, nil, err
+		}
+		if err := m.app.GitStage(paths); err != nil {
+			return nil, nil, fmt.Errorf("error staging: %w", err)
+		}
+		return text(fmt.Sprintf("Staged %s", strings.Join(paths, ", "))), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_unstage",
+		Description: "Operates on Idensyra workspace - Remove staged changes of files or folders from the next commit, keeping the files",
+		InputSchema: mcp.GitStageInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		paths, err := mcp.ParseGitPaths(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.authorize(ctx, mcp.OpGitCommit, "Git Unstage", fmt.Sprintf("Unstage %s", strings.Join(paths, ", ")), paths...); err != nil {
+			return nil, nil, err
+		}
+		if err := m.app.GitUnstage(paths); err != nil {
+			return nil, nil, fmt.Errorf("error unstaging: %w", err)
+		}
+		return text(fmt.Sprintf("Unstaged %s", strings.Join(paths, ", "))), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "git_commit",
+		Description: "Operates on Idensyra workspace - Commit the staged changes, optionally saving and staging the given paths first",
+		InputSchema: mcp.GitCommitInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		paths, err := mcp.ParseGitPaths(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		message, _ := args["message"].(string)
+		if strings.TrimSpace(message) == "" {
+			return nil, nil, fmt.Errorf("commit message cannot be empty")
+		}
+		details := fmt.Sprintf("Commit with message %q", message)
+		if len(paths) > 0 {
+			details += fmt.Sprintf(" after staging %s", strings.Join(paths, ", "))
+		}
+		if err := m.authorize(ctx, mcp.OpGitCommit, "Git Commit", details, paths...); err != nil {
+			return nil, nil, err
+		}
+		if len(paths) > 0 {
+			if err := m.app.GitStage(paths); err != nil {
+				return nil, nil, fmt.Errorf("error staging: %w", err)
+			}
+		}
+		commit, err := m.app.GitCommit(message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error committing: %w", err)
+		}
+		return text("Committed " + mcp.FormatGitLog([]mcp.GitCommit{*commit})), nil, nil
+	})
+}
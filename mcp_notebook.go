@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/igonb"
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerNotebookTools registers set_cell_metadata
+func (m *MCPServer) registerNotebookTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "set_cell_metadata",
+		Description: "Operates on Idensyra workspace - Set or remove metadata of a notebook cell, such as skip, frozen, tags, timeout, hide_code and hide_output",
+		InputSchema: mcp.SetCellMetadataInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		metaReq, err := mcp.ParseCellMetadataArgs(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanPath, err := cleanRelativePath(metaReq.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !strings.HasSuffix(strings.ToLower(cleanPath), ".igonb") {
+			return nil, nil, fmt.Errorf("not an igonb notebook: %s", cleanPath)
+		}
+		target := metaReq.ID
+		if target == "" {
+			target = fmt.Sprintf("%d", metaReq.Index)
+		}
+		if err := m.authorize(ctx, mcp.OpNotebookModify, "Notebook Modify", fmt.Sprintf("Set metadata of cell %s in: %s", target, cleanPath), cleanPath); err != nil {
+			return nil, nil, err
+		}
+
+		content, err := m.app.GetFileContent(cleanPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading notebook: %w", err)
+		}
+		nb, err := igonb.Parse([]byte(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		index := metaReq.Index
+		if metaReq.ID != "" {
+			index = nb.CellIndex(metaReq.ID)
+		}
+		if index < 0 || index >= len(nb.Cells) {
+			return nil, nil, fmt.Errorf("cell not found: %s", target)
+		}
+		cell := &nb.Cells[index]
+		if err := cell.SetMetadata(metaReq.Metadata); err != nil {
+			return nil, nil, err
+		}
+		data, err := json.MarshalIndent(nb, "", "  ")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal igonb: %w", err)
+		}
+		if _, err := m.dispatchUIAction(ctx, "write_file", map[string]any{"path": cleanPath, "content": string(data)}); err != nil {
+			return nil, nil, fmt.Errorf("error updating file via UI: %w", err)
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{&sdk.TextContent{Text: mcp.FormatCellMetadata(index, cell.ID, cell.Metadata)}},
+		}, nil, nil
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// MCPPermissionConfig is the permission configuration shown in the MCP dialog
+type MCPPermissionConfig struct {
+	Path   string      `json:"path"`
+	Config *mcp.Config `json:"config"`
+}
+
+// loadMCPPermissionConfig reads the permission file shared with cmd/mcp-server.
+// A missing file yields the default configuration.
+func loadMCPPermissionConfig() (*mcp.Config, string, error) {
+	path, err := mcp.DefaultConfigPath()
+	if err != nil {
+		return mcp.DefaultConfig(), "", err
+	}
+	config, err := mcp.LoadConfigFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mcp.DefaultConfig(), path, nil
+		}
+		return mcp.DefaultConfig(), path, err
+	}
+	return config, path, nil
+}
+
+// permissionConfig returns the permission configuration currently enforced
+func (m *MCPServer) permissionConfig() *mcp.Config {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.config == nil {
+		return mcp.DefaultConfig()
+	}
+	return m.config
+}
+
+func (m *MCPServer) setPermissionConfig(config *mcp.Config) {
+	m.stateMu.Lock()
+	m.config = config
+	m.stateMu.Unlock()
+}
+
+// reloadPermissionConfig re-reads the permission file, falling back to asking for everything
+func (m *MCPServer) reloadPermissionConfig() {
+	config, path, err := loadMCPPermissionConfig()
+	if err != nil {
+		log.Printf("[MCP] Invalid permission config %s, asking for every operation: %v", path, err)
+	}
+	m.setPermissionConfig(config)
+}
+
+// authorize checks the permission of a tool call, showing a dialog for Ask operations
+func (m *MCPServer) authorize(ctx context.Context, op mcp.Operation, title, details string, paths ...string) error {
+	err := m.permissionConfig().Check(ctx, op, m.confirm, title, details, paths...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", title, err)
+	}
+	return nil
+}
+
+// confirm asks the user to approve an MCP operation
+func (m *MCPServer) confirm(operation, details string) bool {
+	if m.app == nil || m.app.ctx == nil {
+		return false
+	}
+	result, err := runtime.MessageDialog(m.app.ctx, runtime.MessageDialogOptions{
+		Type:          runtime.QuestionDialog,
+		Title:         "MCP: " + operation,
+		Message:       details + "\n\nAllow this operation?",
+		Buttons:       []string{"Allow", "Deny"},
+		DefaultButton: "Deny",
+		CancelButton:  "Deny",
+	})
+	if err != nil {
+		log.Printf("[MCP] Confirmation dialog failed: %v", err)
+		return false
+	}
+	// Platforms without custom buttons answer Yes/No
+	return result == "Allow" || result == "Yes" || result == "Ok"
+}
+
+// GetMCPPermissions returns the MCP permission configuration and the file it is stored in
+func (a *App) GetMCPPermissions() (MCPPermissionConfig, error) {
+	config, path, err := loadMCPPermissionConfig()
+	return MCPPermissionConfig{Path: path, Config: config}, err
+}
+
+// UpdateMCPPermissions validates and saves the permission configuration and applies it immediately
+func (a *App) UpdateMCPPermissions(config mcp.Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	path, err := mcp.DefaultConfigPath()
+	if err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	if err := mcp.SaveConfigFile(path, &config); err != nil {
+		return err
+	}
+	if a.mcpServer != nil {
+		a.mcpServer.setPermissionConfig(&config)
+	}
+	return nil
+}
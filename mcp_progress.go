@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/igonb"
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// heartbeatInterval is how often progress is reported while waiting without other updates
+const heartbeatInterval = 5 * time.Second
+
+type mcpToolNameKey struct{}
+
+// withProgress tags ctx with the tool name and forwards progress to the client
+// when the request carries a progress token
+func withProgress(ctx context.Context, toolName string, req *sdk.CallToolRequest) context.Context {
+	ctx = context.WithValue(ctx, mcpToolNameKey{}, toolName)
+	if req == nil || req.Params == nil || req.Session == nil {
+		return ctx
+	}
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return ctx
+	}
+	session := req.Session
+	return mcp.WithProgress(ctx, func(p mcp.Progress) {
+		err := session.NotifyProgress(ctx, &sdk.ProgressNotificationParams{
+			ProgressToken: token,
+			Progress:      p.Progress,
+			Total:         p.Total,
+			Message:       p.Message,
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[MCP] Error sending progress for %s: %v", toolName, err)
+		}
+	})
+}
+
+// mcpToolName returns the name of the tool handling ctx
+func mcpToolName(ctx context.Context) string {
+	name, _ := ctx.Value(mcpToolNameKey{}).(string)
+	return name
+}
+
+// withToolTimeout bounds ctx by the configured timeout of the tool handling it
+func (m *MCPServer) withToolTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return m.permissionConfig().WithToolTimeout(ctx, mcpToolName(ctx))
+}
+
+// toolContextError explains why a tool call stopped
+func toolContextError(ctx context.Context) error {
+	name := mcpToolName(ctx)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out (configure \"timeouts\" in the MCP permission file to allow longer runs): %w", name, ctx.Err())
+	}
+	return fmt.Errorf("%s cancelled: %w", name, ctx.Err())
+}
+
+// reportHeartbeat reports elapsed time periodically until the returned stop function is called
+func reportHeartbeat(ctx context.Context, message string) func() {
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				elapsed := time.Since(start).Round(time.Second)
+				mcp.ReportProgress(ctx, mcp.Progress{
+					Progress: elapsed.Seconds(),
+					Message:  fmt.Sprintf("%s (%s elapsed)", message, elapsed),
+				})
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// cancelExecution stops the code run behind a cancelled or timed out frontend
+// request: Go code and Python processes, and a notebook the frontend runs for it
+func (m *MCPServer) cancelExecution(ctx context.Context) {
+	if strings.HasPrefix(mcpToolName(ctx), "execute_") {
+		stopGoExecution()
+		igonbRunner.Cancel(getIgonbExecutorKey())
+	}
+}
+
+// igonbShellCells lists the shell cells of nb that would run, as "cell 1" or
+// "cells 1, 3", or returns "" when there are none
+func igonbShellCells(nb *igonb.Notebook) string {
+	var indices []int
+	for i, cell := range nb.Cells {
+		if igonb.NormalizeLanguage(cell.Language) == "shell" && !cell.Skipped() && !cell.Frozen() {
+			indices = append(indices, i)
+		}
+	}
+	return mcp.FormatCellIndices(indices)
+}
+
+// registerLongRunningTools registers tools that run in the backend and report progress
+func (m *MCPServer) registerLongRunningTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "execute_all_cells",
+		Description: "Operates on Idensyra workspace - Execute all cells of an .igonb or .ipynb notebook, reporting progress after each cell. Cancelling the call stops the run",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path to the notebook relative to workspace root",
+				},
+			},
+			"required": []string{"path"},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		path, _ := args["path"].(string)
+		cleanPath, err := cleanRelativePath(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !mcp.IsNotebookPath(cleanPath) {
+			return nil, nil, fmt.Errorf("not a notebook: %s", cleanPath)
+		}
+		if err := m.authorize(ctx, mcp.OpNotebookExecute, "Notebook Execute", fmt.Sprintf("Execute all cells in: %s", cleanPath), cleanPath); err != nil {
+			return nil, nil, err
+		}
+
+		var content string
+		if strings.HasSuffix(strings.ToLower(cleanPath), ".ipynb") {
+			content, err = m.app.GetIPyNBAsIgonbContent(cleanPath)
+		} else {
+			content, err = m.app.GetFileContent(cleanPath)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading notebook: %w", err)
+		}
+		nb, err := igonb.Parse([]byte(content))
+		if err != nil {
+			return nil, nil, err
+		}
+		if shellCells := igonbShellCells(nb); shellCells != "" {
+			if err := m.authorize(ctx, mcp.OpExecuteShell, "Execute Shell", fmt.Sprintf("Run shell %s in: %s", shellCells, cleanPath), cleanPath); err != nil {
+				return nil, nil, err
+			}
+		}
+		_ = m.app.SetActiveFile(cleanPath)
+
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+
+		globalWorkspace.mu.RLock()
+		key := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(cleanPath))
+		globalWorkspace.mu.RUnlock()
+
+		total := len(nb.Cells)
+		done := 0
+		mcp.ReportProgress(ctx, mcp.Progress{Progress: 0, Total: float64(total), Message: fmt.Sprintf("Running %d cells of %s", total, cleanPath)})
+		results, runErr := runIgonbInWorkspace(func() ([]igonb.CellResult, error) {
+			return igonbRunner.ExecuteNotebook(nb, igonb.RunOptions{
+				Key:       key,
+				Mode:      igonb.RunAll,
+				Index:     -1,
+				Context:   ctx,
+				PythonEnv: workspacePythonEnvDir(),
+				OnResult: func(result igonb.CellResult) {
+					done++
+					mcp.ReportProgress(ctx, mcp.CellProgress(result.Index, done, total, result.Language, result.Output, result.Error))
+					if m.app.ctx != nil {
+						runtime.EventsEmit(m.app.ctx, "mcp:notebook_cell_result", map[string]any{
+							"path":   cleanPath,
+							"result": igonb.FormatResult(result, formatIgonbOutput),
+						})
+					}
+				},
+			})
+		})
+		m.app.notifyMCPVariablesChanged(key)
+
+		var outputs []string
+		for _, result := range results {
+			if result.Error != "" {
+				outputs = append(outputs, fmt.Sprintf("Cell %d error: %s\n%s", result.Index, result.Error, result.Output))
+			} else {
+				outputs = append(outputs, fmt.Sprintf("Cell %d output:\n%s", result.Index, result.Output))
+			}
+		}
+		isError := false
+		if ctx.Err() != nil {
+			isError = true
+			outputs = append(outputs, fmt.Sprintf("Execution stopped after %d of %d cells: %v", len(results), total, toolContextError(ctx)))
+		} else if runErr != nil && !errors.Is(runErr, igonb.ErrExecutionStopped) {
+			isError = true
+			outputs = append(outputs, fmt.Sprintf("Execution failed: %v", runErr))
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: strings.Join(outputs, "\n\n")},
+			},
+			IsError: isError,
+		}, nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "pip_install",
+		Description: "Install Python packages into the workspace Python environment (.idensyra/venv) when it exists, otherwise into the Idensyra Python environment, reporting progress per package. Cancelling the call skips the remaining packages",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"packages": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Package specifiers, e.g. [\"pandas\", \"scikit-learn==1.5.2\"]",
+				},
+			},
+			"required": []string{"packages"},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		var packages []string
+		if list, ok := args["packages"].([]interface{}); ok {
+			for _, item := range list {
+				if name, ok := item.(string); ok && strings.TrimSpace(name) != "" {
+					packages = append(packages, strings.TrimSpace(name))
+				}
+			}
+		}
+		if len(packages) == 0 {
+			return nil, nil, fmt.Errorf("no packages given")
+		}
+		if err := m.authorize(ctx, mcp.OpExecutePython, "Install Python Packages", fmt.Sprintf("pip install %s", strings.Join(packages, " "))); err != nil {
+			return nil, nil, err
+		}
+
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+
+		var lines []string
+		failed := false
+		for i, name := range packages {
+			if ctx.Err() != nil {
+				failed = true
+				lines = append(lines, fmt.Sprintf("Skipped %s: %v", strings.Join(packages[i:], ", "), toolContextError(ctx)))
+				break
+			}
+			mcp.ReportProgress(ctx, mcp.Progress{Progress: float64(i), Total: float64(len(packages)), Message: "Installing " + name})
+			stopHeartbeat := reportHeartbeat(ctx, "Installing "+name)
+			err := pipInstallContext(ctx, name)
+			stopHeartbeat()
+			if err != nil && ctx.Err() != nil {
+				failed = true
+				lines = append(lines, fmt.Sprintf("Stopped installing %s: %v", name, toolContextError(ctx)))
+				if i+1 < len(packages) {
+					lines = append(lines, fmt.Sprintf("Skipped %s", strings.Join(packages[i+1:], ", ")))
+				}
+				break
+			}
+			if err != nil {
+				failed = true
+				lines = append(lines, fmt.Sprintf("Failed to install %s: %v", name, err))
+			} else {
+				lines = append(lines, "Installed "+name)
+			}
+		}
+		mcp.ReportProgress(ctx, mcp.Progress{Progress: float64(len(packages)), Total: float64(len(packages)), Message: "Done"})
+		if m.app.ctx != nil {
+			runtime.EventsEmit(m.app.ctx, "python:packages_changed")
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: strings.Join(lines, "\n")},
+			},
+			IsError: failed,
+		}, nil, nil
+	})
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerResources exposes workspace files, notebooks and session variables as MCP resources
+func (m *MCPServer) registerResources() {
+	m.server.AddResource(&sdk.Resource{
+		URI:         mcp.WorkspaceFilesURI,
+		Name:        "workspace-files",
+		Title:       "Workspace files",
+		Description: "Files in the Idensyra workspace with their resource URIs",
+		MIMEType:    "application/json",
+	}, m.readResource)
+	m.server.AddResource(&sdk.Resource{
+		URI:         mcp.SessionVariablesURI,
+		Name:        "session-variables",
+		Title:       "Session variables",
+		Description: "Live variables shared between Go and Python cells of the active notebook",
+		MIMEType:    "application/json",
+	}, m.readResource)
+
+	m.server.AddResourceTemplate(&sdk.ResourceTemplate{
+		URITemplate: mcp.FileResourceTemplate,
+		Name:        "file",
+		Title:       "Workspace file",
+		Description: "Content of a workspace file, including unsaved edits",
+	}, m.readResource)
+	m.server.AddResourceTemplate(&sdk.ResourceTemplate{
+		URITemplate: mcp.NotebookResourceTemplate,
+		Name:        "notebook",
+		Title:       "Notebook cells",
+		Description: "Cells of an .igonb or .ipynb notebook with their latest outputs",
+		MIMEType:    "application/json",
+	}, m.readResource)
+	m.server.AddResourceTemplate(&sdk.ResourceTemplate{
+		URITemplate: mcp.VariablesResourceTemplate,
+		Name:        "variables",
+		Title:       "Notebook variables",
+		Description: "Live variables of the session of a notebook",
+		MIMEType:    "application/json",
+	}, m.readResource)
+}
+
+// readResource serves every idensyra:// resource
+func (m *MCPServer) readResource(ctx context.Context, req *sdk.ReadResourceRequest) (*sdk.ReadResourceResult, error) {
+	uri := req.Params.URI
+	contents, err := m.resourceContents(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &sdk.ReadResourceResult{Contents: []*sdk.ResourceContents{contents}}, nil
+}
+
+func (m *MCPServer) resourceContents(uri string) (*sdk.ResourceContents, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	kind, relPath, err := mcp.ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case uri == mcp.WorkspaceFilesURI:
+		text, err := workspaceFilesResourceText()
+		if err != nil {
+			return nil, err
+		}
+		return &sdk.ResourceContents{URI: uri, MIMEType: "application/json", Text: text}, nil
+
+	case uri == mcp.SessionVariablesURI:
+		return variablesResourceContents(uri, getIgonbExecutorKey())
+
+	case kind == mcp.ResourceKindVariables && relPath != "":
+		globalWorkspace.mu.RLock()
+		workDir := globalWorkspace.workDir
+		globalWorkspace.mu.RUnlock()
+		return variablesResourceContents(uri, filepath.Join(workDir, filepath.FromSlash(relPath)))
+
+	case kind == mcp.ResourceKindNotebook && relPath != "":
+		if !mcp.IsNotebookPath(relPath) {
+			return nil, fmt.Errorf("not a notebook: %s", relPath)
+		}
+		var content string
+		if strings.HasSuffix(strings.ToLower(relPath), ".ipynb") {
+			content, err = m.app.GetIPyNBAsIgonbContent(relPath)
+		} else {
+			content, err = m.app.GetFileContent(relPath)
+		}
+		if err != nil {
+			return nil, sdk.ResourceNotFoundError(uri)
+		}
+		text, err := mcp.NotebookResourceText(relPath, []byte(content))
+		if err != nil {
+			return nil, err
+		}
+		return &sdk.ResourceContents{URI: uri, MIMEType: "application/json", Text: text}, nil
+
+	case kind == mcp.ResourceKindFile && relPath != "":
+		globalWorkspace.mu.RLock()
+		file, ok := globalWorkspace.files[relPath]
+		var content string
+		var isBinary, tooLarge bool
+		var readErr error
+		if ok {
+			isBinary, tooLarge = file.IsBinary, file.TooLarge
+			ok = !file.IsDir
+			if ok && !tooLarge {
+				content, readErr = workspaceFileContentLocked(relPath, file)
+			}
+		}
+		workDir := globalWorkspace.workDir
+		globalWorkspace.mu.RUnlock()
+		if !ok {
+			return nil, sdk.ResourceNotFoundError(uri)
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+		if tooLarge {
+			return nil, fmt.Errorf("file too large to read as a resource: %s", relPath)
+		}
+		mimeType := mcp.ResourceMIMEType(relPath)
+		if isBinary {
+			data, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(relPath)))
+			if err != nil {
+				return nil, err
+			}
+			return &sdk.ResourceContents{URI: uri, MIMEType: mimeType, Blob: data}, nil
+		}
+		return &sdk.ResourceContents{URI: uri, MIMEType: mimeType, Text: content}, nil
+	}
+	return nil, sdk.ResourceNotFoundError(uri)
+}
+
+func workspaceFilesResourceText() (string, error) {
+	globalWorkspace.mu.RLock()
+	files := make([]mcp.ResourceFile, 0, len(globalWorkspace.files))
+	for name, file := range globalWorkspace.files {
+		if file.IsDir {
+			continue
+		}
+		files = append(files, mcp.NewResourceFile(name, file.Size, file.Modified, file.IsBinary))
+	}
+	globalWorkspace.mu.RUnlock()
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return mcp.FormatResourceFiles(files)
+}
+
+func variablesResourceContents(uri, executorKey string) (*sdk.ResourceContents, error) {
+	var variables []mcp.ResourceVariable
+	for _, v := range igonbRunner.Variables(executorKey) {
+		variables = append(variables, mcp.ResourceVariable{Name: v.Name, Type: v.Type, Preview: v.Preview})
+	}
+	text, err := mcp.VariablesResourceText(workspaceRelativePath(executorKey), variables)
+	if err != nil {
+		return nil, err
+	}
+	return &sdk.ResourceContents{URI: uri, MIMEType: "application/json", Text: text}, nil
+}
+
+// workspaceRelativePath converts an executor key back to a workspace path when possible
+func workspaceRelativePath(absPath string) string {
+	if globalWorkspace == nil {
+		return absPath
+	}
+	globalWorkspace.mu.RLock()
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+	if workDir == "" {
+		return absPath
+	}
+	rel, err := filepath.Rel(workDir, absPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return absPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// registerPrompts adds the prompt templates, attaching the referenced file or notebook
+func (m *MCPServer) registerPrompts() {
+	for _, tmpl := range mcp.Prompts() {
+		tmpl := tmpl
+		prompt := &sdk.Prompt{
+			Name:        tmpl.Name,
+			Title:       tmpl.Title,
+			Description: tmpl.Description,
+		}
+		for _, arg := range tmpl.Arguments {
+			prompt.Arguments = append(prompt.Arguments, &sdk.PromptArgument{
+				Name:        arg.Name,
+				Description: arg.Description,
+				Required:    arg.Required,
+			})
+		}
+		m.server.AddPrompt(prompt, func(ctx context.Context, req *sdk.GetPromptRequest) (*sdk.GetPromptResult, error) {
+			text, err := tmpl.Render(req.Params.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			messages := []*sdk.PromptMessage{
+				{Role: "user", Content: &sdk.TextContent{Text: text}},
+			}
+			if tmpl.ResourceArg != "" {
+				uri := mcp.ResourceURI(tmpl.ResourceKind, req.Params.Arguments[tmpl.ResourceArg])
+				contents, err := m.resourceContents(uri)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, &sdk.PromptMessage{
+					Role:    "user",
+					Content: &sdk.EmbeddedResource{Resource: contents},
+				})
+			}
+			return &sdk.GetPromptResult{Description: tmpl.Description, Messages: messages}, nil
+		})
+	}
+}
+
+// subscribeResource accepts subscriptions to any idensyra:// resource
+func (m *MCPServer) subscribeResource(ctx context.Context, req *sdk.SubscribeRequest) error {
+	_, _, err := mcp.ParseResourceURI(req.Params.URI)
+	return err
+}
+
+func (m *MCPServer) unsubscribeResource(ctx context.Context, req *sdk.UnsubscribeRequest) error {
+	return nil
+}
+
+// notifyResourcesUpdated tells subscribed clients that resources changed.
+// Notifications are sent asynchronously so UI callers never wait on the network.
+func (m *MCPServer) notifyResourcesUpdated(uris ...string) {
+	m.stateMu.Lock()
+	server := m.server
+	running := m.status.Running
+	m.stateMu.Unlock()
+	if server == nil || !running || len(uris) == 0 {
+		return
+	}
+	go func() {
+		for _, uri := range uris {
+			if err := server.ResourceUpdated(context.Background(), &sdk.ResourceUpdatedNotificationParams{URI: uri}); err != nil {
+				log.Printf("[MCP] Error sending resource update for %s: %v", uri, err)
+			}
+		}
+	}()
+}
+
+// notifyMCPFilesChanged notifies subscribers of the file, notebook and workspace resources
+func (a *App) notifyMCPFilesChanged(paths ...string) {
+	if a == nil || a.mcpServer == nil {
+		return
+	}
+	uris := []string{mcp.WorkspaceFilesURI}
+	for _, p := range paths {
+		uris = append(uris, mcp.ResourceURI(mcp.ResourceKindFile, p))
+		if mcp.IsNotebookPath(p) {
+			uris = append(uris, mcp.ResourceURI(mcp.ResourceKindNotebook, p))
+		}
+	}
+	a.mcpServer.notifyResourcesUpdated(uris...)
+}
+
+// notifyMCPVariablesChanged notifies subscribers of the variables of a notebook session
+func (a *App) notifyMCPVariablesChanged(executorKey string) {
+	if a == nil || a.mcpServer == nil {
+		return
+	}
+	uris := []string{mcp.SessionVariablesURI}
+	if rel := workspaceRelativePath(executorKey); rel != executorKey {
+		uris = append(uris, mcp.ResourceURI(mcp.ResourceKindVariables, rel))
+	}
+	a.mcpServer.notifyResourcesUpdated(uris...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// registerSearchTools registers search_files and replace_in_files
+func (m *MCPServer) registerSearchTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "search_files",
+		Description: "Operates on Idensyra workspace - Search all workspace files, including unsaved edits, for text or a regular expression, with include/exclude globs and context lines; igonb notebooks are searched per cell",
+		InputSchema: mcp.SearchFilesInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		opts, err := mcp.ParseSearchOptions(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+		result, err := mcp.Search(ctx, workspaceSearchFS{app: m.app}, opts, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error searching files: %w", err)
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: mcp.FormatSearchResult(result)},
+			},
+		}, nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "replace_in_files",
+		Description: "Operates on Idensyra workspace - Replace text or regular expression matches across workspace files as unsaved edits; igonb notebooks only change cell sources",
+		InputSchema: mcp.ReplaceInFilesInputSchema(),
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		opts, err := mcp.ParseSearchOptions(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		replacement, dryRun, err := mcp.ParseReplaceArgs(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx, cancel := m.withToolTimeout(ctx)
+		defer cancel()
+
+		// A dry run finds the files to authorize and checkpoint before anything changes
+		fsys := workspaceSearchFS{app: m.app}
+		preview, err := mcp.Replace(ctx, fsys, opts, replacement, true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error replacing in files: %w", err)
+		}
+		paths := preview.ReplacedPaths()
+		if dryRun || len(paths) == 0 {
+			return &sdk.CallToolResult{
+				Content: []sdk.Content{
+					&sdk.TextContent{Text: mcp.FormatReplaceResult(preview)},
+				},
+			}, nil, nil
+		}
+		if err := m.authorize(ctx, mcp.OpFileEdit, "Replace In Files", fmt.Sprintf("Replace %q with %q in %s", opts.Query, replacement, strings.Join(paths, ", ")), paths...); err != nil {
+			return nil, nil, err
+		}
+
+		store, checkpoint := m.createCheckpoint("replace_in_files", paths)
+		opts.Paths = paths
+		result, err := mcp.Replace(ctx, fsys, opts, replacement, false)
+		checkpointID := m.finishCheckpoint(store, checkpoint)
+		m.recordCheckpointChanges(ctx, store, checkpointID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error replacing in files: %w", err)
+		}
+		result.Checkpoint = checkpointID
+		if m.app.ctx != nil {
+			runtime.EventsEmit(m.app.ctx, "workspace:files-replaced", result.ReplacedPaths())
+		}
+		return &sdk.CallToolResult{
+			Content: []sdk.Content{
+				&sdk.TextContent{Text: mcp.FormatReplaceResult(result)},
+			},
+		}, nil, nil
+	})
+}
@@ -3,88 +3,137 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/HazelnutParadise/idensyra/mcp"
 	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-// MCPServer wraps the MCP SDK server for HTTP access via SSE
+// MCPServer wraps the MCP SDK server for HTTP access via SSE or Streamable HTTP
 type MCPServer struct {
 	server     *sdk.Server
 	httpServer *http.Server
 	app        *App
 
-	pendingResults map[string]chan string
-	pendingMu      sync.Mutex
+	broker *mcp.Broker
+
+	stateMu  sync.Mutex
+	settings MCPSettings
+	status   MCPStatus
+	config   *mcp.Config
+
+	auditLogs        map[string]*mcp.AuditLog
+	checkpointStores map[string]*mcp.CheckpointStore
 }
 
 // NewMCPServer creates a new MCP server using the official SDK
 func NewMCPServer(app *App) *MCPServer {
-	return &MCPServer{
-		app:            app,
-		pendingResults: make(map[string]chan string),
-	}
+	m := &MCPServer{app: app}
+	m.broker = mcp.NewBroker(func(req mcp.UIRequest) {
+		if app.ctx != nil {
+			// Emit asynchronously to avoid blocking the MCP handler if the UI event loop stalls.
+			go runtime.EventsEmit(app.ctx, req.Event, req.Payload)
+		}
+	})
+	m.registerUIFallbacks()
+	return m
 }
 
-// waitForExecutionResult waits for a result published by frontend for given requestId
-func (m *MCPServer) waitForExecutionResult(requestId string, timeout time.Duration) (string, error) {
-	m.pendingMu.Lock()
-	ch := make(chan string, 1)
-	m.pendingResults[requestId] = ch
-	m.pendingMu.Unlock()
-
-	select {
-	case res := <-ch:
-		// cleanup
-		m.pendingMu.Lock()
-		delete(m.pendingResults, requestId)
-		m.pendingMu.Unlock()
-		return res, nil
-	case <-time.After(timeout):
-		m.pendingMu.Lock()
-		delete(m.pendingResults, requestId)
-		m.pendingMu.Unlock()
-		return "", fmt.Errorf("timeout waiting for execution result")
+// dispatchUIEvent sends a request to the frontend through the broker and waits for its result.
+// The wait ends early when the tool call is cancelled or exceeds its configured timeout.
+func (m *MCPServer) dispatchUIEvent(ctx context.Context, event, action string, payload map[string]any) (string, error) {
+	ctx, cancel := m.withToolTimeout(ctx)
+	defer cancel()
+	stopHeartbeat := reportHeartbeat(ctx, "Waiting for "+mcpToolName(ctx))
+	defer stopHeartbeat()
+
+	res, err := m.broker.Dispatch(ctx, event, action, payload)
+	if err != nil && ctx.Err() != nil {
+		m.cancelExecution(ctx)
+		code := mcp.UIErrCancelled
+		var uiErr *mcp.UIError
+		if errors.As(err, &uiErr) {
+			code = uiErr.Code
+		}
+		return "", &mcp.UIError{Code: code, Message: toolContextError(ctx).Error()}
 	}
+	return res, err
 }
 
 // dispatchUIAction sends an MCP action to the frontend and waits for a response.
-func (m *MCPServer) dispatchUIAction(action string, payload map[string]any, timeout time.Duration) (string, error) {
-	requestId := fmt.Sprintf("req-%d", time.Now().UnixNano())
-	if payload == nil {
-		payload = map[string]any{}
+func (m *MCPServer) dispatchUIAction(ctx context.Context, action string, payload map[string]any) (string, error) {
+	return m.dispatchUIEvent(ctx, "mcp:ui_action", action, payload)
+}
+
+// deliverExecutionResult is called by App when frontend finishes executing and posts result
+func (m *MCPServer) deliverExecutionResult(result mcp.UIResult) error {
+	if err := m.broker.Deliver(result); err != nil {
+		log.Printf("[MCP] Rejected result for %s: %v", result.RequestID, err)
+		return err
 	}
-	payload["action"] = action
-	payload["request_id"] = requestId
+	return nil
+}
 
-	// Emit asynchronously to avoid blocking the MCP handler if the UI event loop stalls.
-	go runtime.EventsEmit(m.app.ctx, "mcp:ui_action", payload)
-	return m.waitForExecutionResult(requestId, timeout)
+// MCPStatus describes the state of the integrated MCP HTTP server
+type MCPStatus struct {
+	Running   bool   `json:"running"`
+	Address   string `json:"address"`
+	URL       string `json:"url"`
+	ResultURL string `json:"resultUrl"`
+	Transport string `json:"transport"`
+	Error     string `json:"error,omitempty"`
 }
 
-// deliverExecutionResult is called by App when frontend finishes executing and posts result
-func (m *MCPServer) deliverExecutionResult(requestId string, result string) {
-	m.pendingMu.Lock()
-	ch, ok := m.pendingResults[requestId]
-	m.pendingMu.Unlock()
-	if ok {
-		select {
-		case ch <- result:
-		default:
-		}
+// Settings returns the settings the server was last started with
+func (m *MCPServer) Settings() MCPSettings {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.settings
+}
+
+// Status returns a snapshot of the server state
+func (m *MCPServer) Status() MCPStatus {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	return m.status
+}
+
+func (m *MCPServer) setStatus(status MCPStatus) {
+	m.stateMu.Lock()
+	m.status = status
+	m.stateMu.Unlock()
+	if m.app != nil && m.app.ctx != nil {
+		runtime.EventsEmit(m.app.ctx, "mcp:status", status)
 	}
 }
 
-// Start initializes and starts the MCP server with SSE HTTP transport
-func (m *MCPServer) Start(port int) error {
+// Start initializes and starts the MCP server with the configured HTTP transport.
+// Listen errors (for example a port already in use) are returned to the caller.
+func (m *MCPServer) Start(settings MCPSettings) error {
+	settings, err := settings.normalize()
+	if err != nil {
+		return err
+	}
+	m.stateMu.Lock()
+	m.settings = settings
+	m.stateMu.Unlock()
+
+	if !settings.Enabled {
+		m.setStatus(MCPStatus{Transport: settings.Transport})
+		return nil
+	}
+
 	// Get workspace root
 	workspaceRoot := "."
 	if globalWorkspace != nil {
@@ -106,36 +155,71 @@ func (m *MCPServer) Start(port int) error {
 	}
 
 	opts := &sdk.ServerOptions{
-		Instructions: "Idensyra MCP Server - AI agent workspace interaction with file operations, code execution, and notebook management",
+		Instructions:       "Idensyra MCP Server - AI agent workspace interaction with file operations, code execution, and notebook management",
+		SubscribeHandler:   m.subscribeResource,
+		UnsubscribeHandler: m.unsubscribeResource,
 	}
 
 	m.server = sdk.NewServer(impl, opts)
+	m.reloadPermissionConfig()
 
 	// Register all tools
 	m.registerFileTools(absWorkspace)
 	m.registerCodeExecutionTools(absWorkspace)
 	m.registerWorkspaceTools(absWorkspace)
-
-	// Create SSE handler
-	handler := sdk.NewSSEHandler(func(*http.Request) *sdk.Server {
+	m.registerAuditTools()
+	m.registerCheckpointTools()
+	m.registerLongRunningTools()
+	m.registerDatasetTools()
+	m.registerSearchTools()
+	m.registerGitTools()
+	m.registerTerminalTools()
+	m.registerNotebookTools()
+	m.registerResources()
+	m.registerPrompts()
+
+	getServer := func(*http.Request) *sdk.Server {
 		return m.server
-	}, nil)
+	}
+	var handler http.Handler
+	if settings.Transport == mcp.TransportStreamableHTTP {
+		handler = sdk.NewStreamableHTTPHandler(getServer, nil)
+	} else {
+		handler = sdk.NewSSEHandler(getServer, nil)
+	}
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 	mux.Handle("/", handler)
 	mux.HandleFunc("/mcp/result", m.handleMCPResult)
 
-	m.httpServer = &http.Server{
-		Addr:    fmt.Sprintf("localhost:%d", port),
-		Handler: mux,
+	listener, err := net.Listen("tcp", net.JoinHostPort(settings.BindAddress, strconv.Itoa(settings.Port)))
+	if err != nil {
+		err = fmt.Errorf("failed to listen on %s:%d: %w", settings.BindAddress, settings.Port, err)
+		m.setStatus(MCPStatus{Transport: settings.Transport, Error: err.Error()})
+		return err
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	hostPort := net.JoinHostPort(clientHost(settings.BindAddress), strconv.Itoa(addr.Port))
+	status := MCPStatus{
+		Running:   true,
+		Address:   listener.Addr().String(),
+		URL:       "http://" + hostPort + mcp.EndpointPath(settings.Transport),
+		ResultURL: "http://" + hostPort + "/mcp/result",
+		Transport: settings.Transport,
 	}
 
-	// Start server in background
+	httpServer := &http.Server{Handler: mux}
+	m.httpServer = httpServer
+	m.setStatus(status)
+
+	// Serve in background; unexpected failures are surfaced through the status
 	go func() {
-		log.Printf("[MCP] Starting MCP server on http://localhost:%d", port)
-		if err := m.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("[MCP] Starting MCP server (%s) on %s", status.Transport, status.URL)
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Printf("[MCP] HTTP server error: %v", err)
+			m.setStatus(MCPStatus{Transport: status.Transport, Error: err.Error()})
 		}
 	}()
 
@@ -144,24 +228,33 @@ func (m *MCPServer) Start(port int) error {
 
 // Stop gracefully stops the HTTP server
 func (m *MCPServer) Stop() error {
-	if m.httpServer != nil {
-		log.Println("[MCP] Stopping MCP server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 5)
-		defer cancel()
-		return m.httpServer.Shutdown(ctx)
+	if m.httpServer == nil {
+		return nil
 	}
-	return nil
+	log.Println("[MCP] Stopping MCP server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.httpServer.Shutdown(ctx)
+	m.httpServer = nil
+	m.setStatus(MCPStatus{Transport: m.Settings().Transport})
+	return err
 }
 
-type mcpResultPayload struct {
-	RequestID string `json:"request_id"`
-	Result    string `json:"result"`
+// Restart stops the running server and starts it again with new settings
+func (m *MCPServer) Restart(settings MCPSettings) error {
+	if err := m.Stop(); err != nil {
+		log.Printf("[MCP] Error stopping MCP server: %v", err)
+	}
+	return m.Start(settings)
 }
 
+// mcpResultTokenHeader carries the broker token on results posted by the frontend
+const mcpResultTokenHeader = "X-Idensyra-Token"
+
 func (m *MCPServer) handleMCPResult(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+mcpResultTokenHeader)
 
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusNoContent)
@@ -171,6 +264,10 @@ func (m *MCPServer) handleMCPResult(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if !m.broker.CheckToken(r.Header.Get(mcpResultTokenHeader)) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -178,7 +275,7 @@ func (m *MCPServer) handleMCPResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var payload mcpResultPayload
+	var payload mcp.UIResult
 	if err := json.Unmarshal(body, &payload); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
@@ -188,14 +285,20 @@ func (m *MCPServer) handleMCPResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m.deliverExecutionResult(payload.RequestID, payload.Result)
-	w.WriteHeader(http.StatusNoContent)
+	switch err := m.deliverExecutionResult(payload); {
+	case errors.Is(err, mcp.ErrUnknownRequest):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
 
 // registerFileTools registers file operation tools
 func (m *MCPServer) registerFileTools(workspace string) {
 	// read_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "read_file",
 		Description: "Operates on Idensyra workspace - Read the content of a file in the workspace",
 		InputSchema: map[string]interface{}{
@@ -211,9 +314,9 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
-		content, err := m.dispatchUIAction("read_file", map[string]any{"path": path}, 30*time.Second)
+		content, err := m.dispatchUIAction(ctx, "read_file", map[string]any{"path": path})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error reading file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error reading file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -224,7 +327,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// write_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "write_file",
 		Description: "Operates on Idensyra workspace - Write content to a file in the workspace",
 		InputSchema: map[string]interface{}{
@@ -245,9 +348,13 @@ func (m *MCPServer) registerFileTools(workspace string) {
 		path := args["path"].(string)
 		content := args["content"].(string)
 
-		res, err := m.dispatchUIAction("write_file", map[string]any{"path": path, "content": content}, 30*time.Second)
+		if err := m.authorize(ctx, mcp.OpFileEdit, "File Edit", fmt.Sprintf("Edit file: %s", path), path); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "write_file", map[string]any{"path": path, "content": content})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error updating file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error updating file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -258,7 +365,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// create_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "create_file",
 		Description: "Create a new file in the workspace",
 		InputSchema: map[string]interface{}{
@@ -279,9 +386,13 @@ func (m *MCPServer) registerFileTools(workspace string) {
 		path := args["path"].(string)
 		content := args["content"].(string)
 
-		res, err := m.dispatchUIAction("create_file", map[string]any{"path": path, "content": content}, 30*time.Second)
+		if err := m.authorize(ctx, mcp.OpFileCreate, "File Create", fmt.Sprintf("Create file: %s", path), path); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "create_file", map[string]any{"path": path, "content": content})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error creating file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error creating file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -292,7 +403,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// delete_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "delete_file",
 		Description: "Delete a file from the workspace",
 		InputSchema: map[string]interface{}{
@@ -308,9 +419,13 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
-		res, err := m.dispatchUIAction("delete_file", map[string]any{"path": path}, 30*time.Second)
+		if err := m.authorize(ctx, mcp.OpFileDelete, "File Delete", fmt.Sprintf("Delete file: %s", path), path); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "delete_file", map[string]any{"path": path})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error deleting file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error deleting file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -321,7 +436,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// rename_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "rename_file",
 		Description: "Rename or move a file in the workspace",
 		InputSchema: map[string]interface{}{
@@ -342,9 +457,13 @@ func (m *MCPServer) registerFileTools(workspace string) {
 		oldPath := args["old_path"].(string)
 		newPath := args["new_path"].(string)
 
-		res, err := m.dispatchUIAction("rename_file", map[string]any{"old_path": oldPath, "new_path": newPath}, 30*time.Second)
+		if err := m.authorize(ctx, mcp.OpFileRename, "File Rename", fmt.Sprintf("Rename file: %s -> %s", oldPath, newPath), oldPath, newPath); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "rename_file", map[string]any{"old_path": oldPath, "new_path": newPath})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error renaming file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error renaming file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -355,7 +474,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// list_files tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "list_files",
 		Description: "Operates on Idensyra workspace - List files in a directory in the workspace",
 		InputSchema: map[string]interface{}{
@@ -374,9 +493,9 @@ func (m *MCPServer) registerFileTools(workspace string) {
 			dir = p
 		}
 
-		res, err := m.dispatchUIAction("list_files", map[string]any{"dir_path": dir}, 30*time.Second)
+		res, err := m.dispatchUIAction(ctx, "list_files", map[string]any{"dir_path": dir})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error listing files via UI: %v", err)
+			return nil, nil, fmt.Errorf("error listing files via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -387,7 +506,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 	})
 
 	// import_file_to_workspace tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "import_file_to_workspace",
 		Description: "Import a specific file from the computer into the workspace",
 		InputSchema: map[string]interface{}{
@@ -411,9 +530,13 @@ func (m *MCPServer) registerFileTools(workspace string) {
 			targetDir = td
 		}
 
-		res, err := m.dispatchUIAction("import_file_to_workspace", map[string]any{"source_path": sourcePath, "target_dir": targetDir}, 60*time.Second)
+		if err := m.authorize(ctx, mcp.OpWorkspaceModify, "Import File", fmt.Sprintf("Import file: %s to directory: %s", sourcePath, targetDir), targetDir); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "import_file_to_workspace", map[string]any{"source_path": sourcePath, "target_dir": targetDir})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error importing file via UI: %v", err)
+			return nil, nil, fmt.Errorf("error importing file via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -427,7 +550,7 @@ func (m *MCPServer) registerFileTools(workspace string) {
 // registerCodeExecutionTools registers code execution tools
 func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	// execute_go_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "execute_go_file",
 		Description: "Execute a Go file in the workspace using Yaegi interpreter",
 		InputSchema: map[string]interface{}{
@@ -443,6 +566,10 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
+		if err := m.authorize(ctx, mcp.OpExecuteGo, "Execute Go", fmt.Sprintf("Execute Go file: %s", path), path); err != nil {
+			return nil, nil, err
+		}
+
 		// Switch to this file in UI (best-effort)
 		_ = m.app.SetActiveFile(path)
 
@@ -452,15 +579,9 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 		}
 
 		// Dispatch to frontend to run as if user pressed the Run button
-		requestId := fmt.Sprintf("req-%d", time.Now().UnixNano())
-		runtime.EventsEmit(m.app.ctx, "mcp:execute_go_file", map[string]any{"request_id": requestId, "path": path, "content": content})
-		res, err := m.waitForExecutionResult(requestId, 30*time.Second)
+		res, err := m.dispatchUIEvent(ctx, "mcp:execute_go_file", "execute_go_file", map[string]any{"path": path, "content": content})
 		if err != nil {
-			return &sdk.CallToolResult{
-				Content: []sdk.Content{
-					&sdk.TextContent{Text: fmt.Sprintf("Dispatched execute_go_file for %s to the frontend (no result): %v", path, err)},
-				},
-			}, nil, nil
+			return nil, nil, fmt.Errorf("error running execute_go_file: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -471,7 +592,7 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	})
 
 	// execute_python_file tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "execute_python_file",
 		Description: "Execute a Python file in the workspace",
 		InputSchema: map[string]interface{}{
@@ -487,6 +608,10 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
+		if err := m.authorize(ctx, mcp.OpExecutePython, "Execute Python", fmt.Sprintf("Execute Python file: %s", path), path); err != nil {
+			return nil, nil, err
+		}
+
 		// Switch to this file in UI
 		_ = m.app.SetActiveFile(path)
 
@@ -496,15 +621,9 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 		}
 
 		// Dispatch to frontend to run as if user pressed the Run button
-		requestId := fmt.Sprintf("req-%d", time.Now().UnixNano())
-		runtime.EventsEmit(m.app.ctx, "mcp:execute_python_file", map[string]any{"request_id": requestId, "path": path, "content": content})
-		res, err := m.waitForExecutionResult(requestId, 30*time.Second)
+		res, err := m.dispatchUIEvent(ctx, "mcp:execute_python_file", "execute_python_file", map[string]any{"path": path, "content": content})
 		if err != nil {
-			return &sdk.CallToolResult{
-				Content: []sdk.Content{
-					&sdk.TextContent{Text: fmt.Sprintf("Dispatched execute_python_file for %s to the frontend (no result): %v", path, err)},
-				},
-			}, nil, nil
+			return nil, nil, fmt.Errorf("error running execute_python_file: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -515,7 +634,7 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	})
 
 	// execute_go_code tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "execute_go_code",
 		Description: "Execute Go code directly without a file",
 		InputSchema: map[string]interface{}{
@@ -531,16 +650,14 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		code := args["code"].(string)
 
+		if err := m.authorize(ctx, mcp.OpExecuteGo, "Execute Go", "Execute Go code"); err != nil {
+			return nil, nil, err
+		}
+
 		// Dispatch to frontend to run as if user pressed the Run button
-		requestId := fmt.Sprintf("req-%d", time.Now().UnixNano())
-		runtime.EventsEmit(m.app.ctx, "mcp:execute_go_code", map[string]any{"request_id": requestId, "code": code})
-		res, err := m.waitForExecutionResult(requestId, 30*time.Second)
+		res, err := m.dispatchUIEvent(ctx, "mcp:execute_go_code", "execute_go_code", map[string]any{"code": code})
 		if err != nil {
-			return &sdk.CallToolResult{
-				Content: []sdk.Content{
-					&sdk.TextContent{Text: fmt.Sprintf("Dispatched execute_go_code to frontend (no result): %v", err)},
-				},
-			}, nil, nil
+			return nil, nil, fmt.Errorf("error running execute_go_code: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -551,7 +668,7 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	})
 
 	// execute_python_code tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "execute_python_code",
 		Description: "Execute Python code directly without a file",
 		InputSchema: map[string]interface{}{
@@ -567,17 +684,15 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		code := args["code"].(string)
 
+		if err := m.authorize(ctx, mcp.OpExecutePython, "Execute Python", "Execute Python code"); err != nil {
+			return nil, nil, err
+		}
+
 		// Use an in-memory temp name and dispatch to frontend for execution
 		tmpFile := filepath.Join(workspace, fmt.Sprintf(".tmp_mcp_py_%d.py", os.Getpid()))
-		requestId := fmt.Sprintf("req-%d", time.Now().UnixNano())
-		runtime.EventsEmit(m.app.ctx, "mcp:execute_python_code", map[string]any{"request_id": requestId, "tmp_file": tmpFile, "code": code})
-		res, err := m.waitForExecutionResult(requestId, 30*time.Second)
+		res, err := m.dispatchUIEvent(ctx, "mcp:execute_python_code", "execute_python_code", map[string]any{"tmp_file": tmpFile, "code": code})
 		if err != nil {
-			return &sdk.CallToolResult{
-				Content: []sdk.Content{
-					&sdk.TextContent{Text: fmt.Sprintf("Dispatched execute_python_code to frontend (no result): %v", err)},
-				},
-			}, nil, nil
+			return nil, nil, fmt.Errorf("error running execute_python_code: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -591,7 +706,7 @@ func (m *MCPServer) registerCodeExecutionTools(workspace string) {
 // registerWorkspaceTools registers workspace management tools
 func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	// open_workspace tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "open_workspace",
 		Description: "Open a workspace directory",
 		InputSchema: map[string]interface{}{
@@ -607,9 +722,13 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
-		res, err := m.dispatchUIAction("open_workspace", map[string]any{"path": path}, 60*time.Second)
+		if err := m.authorize(ctx, mcp.OpWorkspaceOpen, "Workspace Open", fmt.Sprintf("Open workspace: %s", path)); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "open_workspace", map[string]any{"path": path})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error opening workspace via UI: %v", err)
+			return nil, nil, fmt.Errorf("error opening workspace via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -620,7 +739,7 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	})
 
 	// save_workspace tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "save_workspace",
 		Description: "Save the current temporary workspace to a specified path",
 		InputSchema: map[string]interface{}{
@@ -636,9 +755,13 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
 		path := args["path"].(string)
 
-		res, err := m.dispatchUIAction("save_workspace", map[string]any{"path": path}, 60*time.Second)
+		if err := m.authorize(ctx, mcp.OpWorkspaceSave, "Workspace Save", fmt.Sprintf("Save workspace to: %s", path)); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "save_workspace", map[string]any{"path": path})
 		if err != nil {
-			return nil, nil, fmt.Errorf("error saving workspace via UI: %v", err)
+			return nil, nil, fmt.Errorf("error saving workspace via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -649,7 +772,7 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	})
 
 	// save_all_files tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "save_all_files",
 		Description: "Save all unsaved changes in the workspace",
 		InputSchema: map[string]interface{}{
@@ -659,9 +782,13 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 			"additionalProperties": true,
 		},
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
-		res, err := m.dispatchUIAction("save_all_files", nil, 60*time.Second)
+		if err := m.authorize(ctx, mcp.OpWorkspaceModify, "Save Changes", "Save all unsaved changes in the workspace"); err != nil {
+			return nil, nil, err
+		}
+
+		res, err := m.dispatchUIAction(ctx, "save_all_files", nil)
 		if err != nil {
-			return nil, nil, fmt.Errorf("error saving files via UI: %v", err)
+			return nil, nil, fmt.Errorf("error saving files via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
@@ -672,7 +799,7 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 	})
 
 	// get_workspace_info tool
-	sdk.AddTool(m.server, &sdk.Tool{
+	m.addTool(&sdk.Tool{
 		Name:        "get_workspace_info",
 		Description: "Operates on Idensyra workspace - Get information about the current workspace",
 		InputSchema: map[string]interface{}{
@@ -682,9 +809,9 @@ func (m *MCPServer) registerWorkspaceTools(workspace string) {
 			"additionalProperties": true,
 		},
 	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
-		res, err := m.dispatchUIAction("get_workspace_info", nil, 30*time.Second)
+		res, err := m.dispatchUIAction(ctx, "get_workspace_info", nil)
 		if err != nil {
-			return nil, nil, fmt.Errorf("error getting workspace info via UI: %v", err)
+			return nil, nil, fmt.Errorf("error getting workspace info via UI: %w", err)
 		}
 
 		return &sdk.CallToolResult{
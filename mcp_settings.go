@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+)
+
+// MCPSettings holds the user-configurable options of the integrated MCP server
+type MCPSettings struct {
+	Enabled     bool   `json:"enabled"`
+	BindAddress string `json:"bindAddress"`
+	// Port 0 lets the operating system pick a free port
+	Port      int    `json:"port"`
+	Transport string `json:"transport"`
+}
+
+const mcpSettingsFileName = "mcp_settings.json"
+
+func defaultMCPSettings() MCPSettings {
+	return MCPSettings{
+		Enabled:     true,
+		BindAddress: "127.0.0.1",
+		Port:        mcp.DefaultHTTPPort,
+		Transport:   mcp.TransportSSE,
+	}
+}
+
+// normalize validates the settings and fills in defaults for empty fields
+func (s MCPSettings) normalize() (MCPSettings, error) {
+	s.BindAddress = strings.TrimSpace(s.BindAddress)
+	if s.BindAddress == "" {
+		s.BindAddress = "127.0.0.1"
+	}
+	if strings.ContainsAny(s.BindAddress, " /") {
+		return s, fmt.Errorf("invalid bind address: %s", s.BindAddress)
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return s, fmt.Errorf("invalid port: %d", s.Port)
+	}
+	transport, err := mcp.NormalizeTransport(s.Transport)
+	if err != nil {
+		return s, err
+	}
+	s.Transport = transport
+	return s, nil
+}
+
+func mcpSettingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "idensyra", mcpSettingsFileName), nil
+}
+
+// loadMCPSettings reads persisted settings, falling back to defaults on any error
+func loadMCPSettings() MCPSettings {
+	settings := defaultMCPSettings()
+	path, err := mcpSettingsPath()
+	if err != nil {
+		return settings
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return settings
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		fmt.Printf("Ignoring invalid MCP settings in %s: %v\n", path, err)
+		return defaultMCPSettings()
+	}
+	normalized, err := settings.normalize()
+	if err != nil {
+		fmt.Printf("Ignoring invalid MCP settings in %s: %v\n", path, err)
+		return defaultMCPSettings()
+	}
+	return normalized
+}
+
+func saveMCPSettings(settings MCPSettings) error {
+	path, err := mcpSettingsPath()
+	if err != nil {
+		return fmt.Errorf("failed to resolve settings path: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create settings directory: %w", err)
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// clientHost returns the host clients should dial for a listener bound to host
+func clientHost(host string) string {
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		return "127.0.0.1"
+	}
+	return host
+}
+
+// GetMCPSettings returns the current MCP server settings
+func (a *App) GetMCPSettings() MCPSettings {
+	if a.mcpServer == nil {
+		return loadMCPSettings()
+	}
+	return a.mcpServer.Settings()
+}
+
+// UpdateMCPSettings persists new MCP settings and restarts the server with them
+func (a *App) UpdateMCPSettings(settings MCPSettings) (MCPStatus, error) {
+	normalized, err := settings.normalize()
+	if err != nil {
+		return MCPStatus{}, err
+	}
+	if err := saveMCPSettings(normalized); err != nil {
+		return MCPStatus{}, err
+	}
+	if a.mcpServer == nil {
+		a.mcpServer = NewMCPServer(a)
+	}
+	if err := a.mcpServer.Restart(normalized); err != nil {
+		return a.mcpServer.Status(), err
+	}
+	return a.mcpServer.Status(), nil
+}
+
+// GetMCPStatus reports whether the MCP server is listening and where
+func (a *App) GetMCPStatus() MCPStatus {
+	if a.mcpServer == nil {
+		return MCPStatus{}
+	}
+	return a.mcpServer.Status()
+}
+
+// GetMCPClientConfigs returns ready-to-paste client configurations for the running server
+func (a *App) GetMCPClientConfigs() ([]mcp.ClientConfigSnippet, error) {
+	status := a.GetMCPStatus()
+	if !status.Running {
+		return nil, fmt.Errorf("MCP server is not running")
+	}
+	return mcp.ClientConfigSnippets(status.URL, status.Transport), nil
+}
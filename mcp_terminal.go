@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/HazelnutParadise/idensyra/terminal"
+	sdk "github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// terminalQuietPeriod is how long a terminal has to stay silent before
+	// terminal_write returns its output
+	terminalQuietPeriod = 300 * time.Millisecond
+	terminalDefaultWait = 2 * time.Second
+	terminalMaxWait     = 60 * time.Second
+	// terminalMaxToolOutput limits the output a terminal tool returns
+	terminalMaxToolOutput = 16 * 1024
+)
+
+// registerTerminalTools registers terminal_open, terminal_write, terminal_read,
+// terminal_list and terminal_close, which share the sessions of the terminal panel
+func (m *MCPServer) registerTerminalTools() {
+	m.addTool(&sdk.Tool{
+		Name:        "terminal_open",
+		Description: "Operates on Idensyra workspace - Open a terminal session running the user's shell in the workspace directory, optionally running a command in it. The session shows in the terminal panel",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"command": map[string]interface{}{
+					"type":        "string",
+					"description": "Command to run once the shell starts",
+				},
+				"wait_ms": terminalWaitSchema(),
+			},
+			"required": []string{},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		command, _ := args["command"].(string)
+		wait, err := parseTerminalWait(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		details := "Open a terminal in the workspace"
+		if strings.TrimSpace(command) != "" {
+			details += fmt.Sprintf(" and run: %s", command)
+		}
+		if err := m.authorize(ctx, mcp.OpExecuteShell, "Open Terminal", details); err != nil {
+			return nil, nil, err
+		}
+		info, err := m.app.CreateTerminal(0, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.TrimSpace(command) != "" {
+			if err := m.app.WriteTerminal(info.ID, command+"\n"); err != nil {
+				return nil, nil, err
+			}
+		}
+		return m.terminalResult(ctx, info.ID, 0, wait)
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "terminal_write",
+		Description: "Operates on Idensyra workspace - Send input to a terminal session, such as a command ending in a newline, then return the output it produces until it is quiet for a moment or wait_ms passes",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "Terminal session ID",
+				},
+				"input": map[string]interface{}{
+					"type":        "string",
+					"description": "Text to type; end a command with \"\\n\", send \"\\u0003\" for Ctrl+C",
+				},
+				"wait_ms": terminalWaitSchema(),
+			},
+			"required": []string{"id", "input"},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		id, _ := args["id"].(string)
+		input, _ := args["input"].(string)
+		wait, err := parseTerminalWait(args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.authorize(ctx, mcp.OpExecuteShell, "Terminal Input", fmt.Sprintf("Send to terminal %s: %q", id, input)); err != nil {
+			return nil, nil, err
+		}
+		_, offset, err := m.app.terminals.Output(id, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.app.WriteTerminal(id, input); err != nil {
+			return nil, nil, err
+		}
+		return m.terminalResult(ctx, id, offset, wait)
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "terminal_read",
+		Description: "Operates on Idensyra workspace - Read the output of a terminal session from an offset returned by an earlier terminal call, or its recent output",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "Terminal session ID",
+				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Output offset to read from (default 0, the oldest output kept)",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		id, _ := args["id"].(string)
+		var offset int64
+		if v, ok := args["offset"]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != float64(int64(n)) {
+				return nil, nil, fmt.Errorf("offset must be a non-negative integer")
+			}
+			offset = int64(n)
+		}
+		return m.terminalResult(ctx, id, offset, 0)
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "terminal_list",
+		Description: "Operates on Idensyra workspace - List the open terminal sessions",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+			"required":   []string{},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		infos := m.app.ListTerminals()
+		if len(infos) == 0 {
+			return terminalText("No terminal sessions"), nil, nil
+		}
+		lines := make([]string, 0, len(infos))
+		for _, info := range infos {
+			line := fmt.Sprintf("%s: %s in %s (%dx%d)", info.ID, info.Shell, info.Dir, info.Cols, info.Rows)
+			if info.Exited {
+				line += fmt.Sprintf(", exited with code %d", info.ExitCode)
+			}
+			lines = append(lines, line)
+		}
+		return terminalText(strings.Join(lines, "\n")), nil, nil
+	})
+
+	m.addTool(&sdk.Tool{
+		Name:        "terminal_close",
+		Description: "Operates on Idensyra workspace - Close a terminal session, killing its shell",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "Terminal session ID",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}, func(ctx context.Context, req *sdk.CallToolRequest, args map[string]interface{}) (*sdk.CallToolResult, any, error) {
+		id, _ := args["id"].(string)
+		if err := m.authorize(ctx, mcp.OpExecuteShell, "Close Terminal", fmt.Sprintf("Close terminal %s", id)); err != nil {
+			return nil, nil, err
+		}
+		if err := m.app.CloseTerminal(id); err != nil {
+			return nil, nil, err
+		}
+		return terminalText(fmt.Sprintf("Closed terminal %s", id)), nil, nil
+	})
+}
+
+func terminalText(s string) *sdk.CallToolResult {
+	return &sdk.CallToolResult{Content: []sdk.Content{&sdk.TextContent{Text: s}}}
+}
+
+func terminalWaitSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "integer",
+		"description": fmt.Sprintf("Longest time to wait for output in milliseconds (default %d, max %d)", terminalDefaultWait.Milliseconds(), terminalMaxWait.Milliseconds()),
+	}
+}
+
+func parseTerminalWait(args map[string]interface{}) (time.Duration, error) {
+	v, ok := args["wait_ms"]
+	if !ok {
+		return terminalDefaultWait, nil
+	}
+	n, ok := v.(float64)
+	if !ok || n < 0 || n != float64(int(n)) {
+		return 0, fmt.Errorf("wait_ms must be a non-negative integer")
+	}
+	return min(time.Duration(n)*time.Millisecond, terminalMaxWait), nil
+}
+
+// terminalResult waits for a terminal to produce output after offset, until it
+// is quiet, exits or wait passes, and returns that output as plain text
+func (m *MCPServer) terminalResult(ctx context.Context, id string, offset int64, wait time.Duration) (*sdk.CallToolResult, any, error) {
+	done, err := m.app.terminals.Done(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline := time.Now().Add(wait)
+	_, end, _ := m.app.terminals.Output(id, offset)
+	lastChange := time.Now()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+wait:
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-done:
+			break wait
+		case <-ticker.C:
+		}
+		_, current, err := m.app.terminals.Output(id, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current != end {
+			end = current
+			lastChange = time.Now()
+		} else if end > offset && time.Since(lastChange) >= terminalQuietPeriod {
+			break
+		}
+	}
+
+	output, end, err := m.app.terminals.Output(id, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := terminal.PlainText(output)
+	if len(text) > terminalMaxToolOutput {
+		text = "...(earlier output truncated)\n" + text[len(text)-terminalMaxToolOutput:]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Terminal %s, output offset %d", id, end)
+	for _, info := range m.app.ListTerminals() {
+		if info.ID == id && info.Exited {
+			fmt.Fprintf(&b, ", exited with code %d", info.ExitCode)
+		}
+	}
+	b.WriteString(":\n")
+	b.WriteString(text)
+	return terminalText(b.String()), nil, nil
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"io"
 	"strings"
+
+	"github.com/HazelnutParadise/insyra/py"
 )
 
 // ExecutePythonFile runs a workspace Python file via py.RunFile and returns HTML output.
@@ -50,9 +53,8 @@ func (a *App) ExecutePythonFileContent(filename string, content string) (string,
 	}
 
 	// Execute python content directly; insyra will handle temp file concerns internally.
-	fullContent := pythonEncodingSetup + content
-	code := buildPythonFileRunnerFromContent(fullContent)
-	return executeGoCode(code, "dark"), nil
+	fullContent := pythonEncodingSetup + withPythonEnvPrelude(content)
+	return executePythonCode(fullContent, "dark"), nil
 }
 
 // pythonEncodingSetup is prepended to Python files to ensure UTF-8 output on Windows
@@ -66,18 +68,10 @@ if sys.platform == 'win32':
 # End of encoding setup
 `
 
-// createTempPythonFile creates a temporary Python file with encoding setup
-// without modifying the original file
-func buildPythonFileRunnerFromContent(content string) string {
-	quoted := strconv.Quote(content)
-	return fmt.Sprintf(`import (
-	"fmt"
-	"github.com/HazelnutParadise/insyra/py"
-)
-
-func main() {
-	if err := py.RunCode(nil, %s); err != nil {
-		fmt.Println(err)
-	}
-}`, quoted)
+// executePythonCode runs Python code in the Insyra environment and captures its
+// output like executeGoCode; stopGoExecution kills the Python process
+func executePythonCode(content string, colorBG string) string {
+	return runCapturingOutput(colorBG, func(ctx context.Context, _ io.Writer) error {
+		return py.RunCodeContext(ctx, nil, content)
+	})
 }
@@ -1,32 +1,269 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/HazelnutParadise/idensyra/igonb"
+	"github.com/HazelnutParadise/idensyra/mcp"
 	"github.com/HazelnutParadise/insyra/py"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// pythonEnvTimeout bounds creating the workspace environment and installing into it
+const pythonEnvTimeout = 15 * time.Minute
+
+// PythonEnvStatus describes the Python environment of the workspace
+type PythonEnvStatus struct {
+	Path             string `json:"path"`
+	Exists           bool   `json:"exists"`
+	RequirementsFile string `json:"requirementsFile"`
+	Locked           bool   `json:"locked"`
+}
+
+// workspacePythonEnv returns the Python environment of the open workspace
+func workspacePythonEnv() (mcp.PythonEnv, error) {
+	if globalWorkspace == nil {
+		return mcp.PythonEnv{}, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	if globalWorkspace.isTemp {
+		return mcp.PythonEnv{}, fmt.Errorf("temporary workspace: please open or create a workspace first")
+	}
+	return mcp.PythonEnv{Root: globalWorkspace.workDir}, nil
+}
+
+// workspacePythonEnvDir returns the directory of the workspace environment, or
+// "" when the workspace has none and Python runs in the Insyra environment
+func workspacePythonEnvDir() string {
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		return env.Dir()
+	}
+	return ""
+}
+
+// activeWorkspacePythonEnv returns the workspace environment if it exists
+func activeWorkspacePythonEnv() (mcp.PythonEnv, bool) {
+	env, err := workspacePythonEnv()
+	if err != nil || !env.Exists() {
+		return mcp.PythonEnv{}, false
+	}
+	return env, true
+}
+
+// withPythonEnvPrelude makes Python code import the packages of the workspace
+// environment, if there is one
+func withPythonEnvPrelude(code string) string {
+	return igonb.PythonEnvPrelude(workspacePythonEnvDir()) + code
+}
+
+// insyraPythonExecutable returns the interpreter of the Insyra environment, so
+// workspace environments match the Python version .py files and cells run with
+func insyraPythonExecutable() (string, error) {
+	var result map[string]any
+	if err := py.RunCode(&result, "import sys\ninsyra.Return({\"executable\": sys.executable}, None)"); err != nil {
+		return "", fmt.Errorf("failed to locate the Insyra Python: %w", err)
+	}
+	executable, _ := result["executable"].(string)
+	if executable == "" {
+		return "", fmt.Errorf("failed to locate the Insyra Python")
+	}
+	return executable, nil
+}
+
+func (a *App) emitPythonPackagesChanged() {
+	if a != nil && a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "python:packages_changed")
+	}
+}
+
+// PipList lists the packages of the workspace environment, or of the Insyra
+// environment when the workspace has none
 func (a *App) PipList() (map[string]string, error) {
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		return env.List(ctx)
+	}
 	return py.PipList()
 }
 
-func (a *App) PipInstall(pkg string) error {
+// PipInstall installs a package into the workspace environment, from the wheels
+// in wheelDir when it is set, or into the Insyra environment when the
+// workspace has none
+func (a *App) PipInstall(pkg string, wheelDir string) error {
 	name := strings.TrimSpace(pkg)
 	if name == "" {
 		return fmt.Errorf("package name cannot be empty")
 	}
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), pythonEnvTimeout)
+		defer cancel()
+		_, err := env.Install(ctx, strings.Fields(name), mcp.PythonInstallOptions{WheelDir: strings.TrimSpace(wheelDir)})
+		return err
+	}
+	if strings.TrimSpace(wheelDir) != "" {
+		return fmt.Errorf("offline installs need a workspace environment")
+	}
 	return py.PipInstall(name)
 }
 
+// pipInstallContext installs a package like PipInstall until ctx is done. pip
+// of the workspace environment is killed then; the Insyra environment cannot
+// be interrupted, so its install is left to finish in the background
+func pipInstallContext(ctx context.Context, name string) error {
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		_, err := env.Install(ctx, []string{name}, mcp.PythonInstallOptions{})
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- py.PipInstall(name) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (a *App) PipUninstall(pkg string) error {
 	name := strings.TrimSpace(pkg)
 	if name == "" {
 		return fmt.Errorf("package name cannot be empty")
 	}
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), pythonEnvTimeout)
+		defer cancel()
+		_, err := env.Uninstall(ctx, strings.Fields(name)...)
+		return err
+	}
 	return py.PipUninstall(name)
 }
 
 func (a *App) ReinstallPyEnv() error {
 	return py.ReinstallPyEnv()
 }
+
+// GetPythonEnvStatus describes the Python environment of the workspace
+func (a *App) GetPythonEnvStatus() (PythonEnvStatus, error) {
+	env, err := workspacePythonEnv()
+	if err != nil {
+		return PythonEnvStatus{}, err
+	}
+	file := env.RequirementsFile()
+	return PythonEnvStatus{
+		Path:             mcp.PythonEnvRelPath,
+		Exists:           env.Exists(),
+		RequirementsFile: file,
+		Locked:           file == mcp.PythonLockFile,
+	}, nil
+}
+
+// CreatePythonEnv creates the workspace environment from the Insyra Python if
+// needed and installs the packages of requirements.lock, requirements.txt or
+// pyproject.toml, from the wheels in wheelDir when it is set
+func (a *App) CreatePythonEnv(wheelDir string) (string, error) {
+	env, err := workspacePythonEnv()
+	if err != nil {
+		return "", err
+	}
+	base := ""
+	if !env.Exists() {
+		if base, err = insyraPythonExecutable(); err != nil {
+			return "", err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pythonEnvTimeout)
+	defer cancel()
+	log, err := env.Create(ctx, base, mcp.PythonInstallOptions{WheelDir: strings.TrimSpace(wheelDir)})
+	a.emitPythonPackagesChanged()
+	return log, err
+}
+
+// LockPythonEnv writes the installed versions of the workspace environment to
+// requirements.lock
+func (a *App) LockPythonEnv() (string, error) {
+	env, err := workspacePythonEnv()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := env.Lock(ctx); err != nil {
+		return "", err
+	}
+	return mcp.PythonLockFile, nil
+}
+
+// ExportPythonEnv saves the installed versions of the workspace environment, or
+// of the Insyra environment when the workspace has none, as a requirements file
+func (a *App) ExportPythonEnv() error {
+	var frozen string
+	if env, ok := activeWorkspacePythonEnv(); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var err error
+		if frozen, err = env.Freeze(ctx); err != nil {
+			return err
+		}
+	} else {
+		packages, err := py.PipList()
+		if err != nil {
+			return err
+		}
+		lines := make([]string, 0, len(packages))
+		for name, version := range packages {
+			lines = append(lines, name+"=="+version)
+		}
+		sort.Slice(lines, func(i, j int) bool { return strings.ToLower(lines[i]) < strings.ToLower(lines[j]) })
+		frozen = strings.Join(lines, "\n") + "\n"
+	}
+
+	filename, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: mcp.PythonRequirementsFile,
+		Title:           "Export Python Packages",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "Requirements Files (*.txt, *.lock)",
+				Pattern:     "*.txt;*.lock",
+			},
+			{
+				DisplayName: "All Files (*.*)",
+				Pattern:     "*.*",
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if filename == "" {
+		return nil // User cancelled
+	}
+	return os.WriteFile(filename, []byte(frozen), 0644)
+}
+
+// RemovePythonEnv deletes the workspace environment; Python then runs in the
+// Insyra environment again
+func (a *App) RemovePythonEnv() error {
+	env, err := workspacePythonEnv()
+	if err != nil {
+		return err
+	}
+	if err := env.Remove(); err != nil {
+		return err
+	}
+	a.emitPythonPackagesChanged()
+	return nil
+}
+
+// SelectWheelDirectory asks for a directory of wheels to install from offline
+func (a *App) SelectWheelDirectory() (string, error) {
+	return runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Wheel Directory",
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+
+	"github.com/HazelnutParadise/idensyra/terminal"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// terminalDir returns the directory new terminals start in: the workspace, or
+// the home directory before one is open
+func terminalDir() string {
+	if globalWorkspace != nil {
+		globalWorkspace.mu.RLock()
+		workDir := globalWorkspace.workDir
+		globalWorkspace.mu.RUnlock()
+		if workDir != "" {
+			return workDir
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	return ""
+}
+
+func (a *App) emitTerminalOutput(id, data string) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "terminal:output", map[string]any{"id": id, "data": data})
+	}
+}
+
+func (a *App) emitTerminalExit(id string, exitCode int) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "terminal:exit", map[string]any{"id": id, "exitCode": exitCode})
+	}
+}
+
+// CreateTerminal starts a shell in the workspace directory. Its output arrives
+// as "terminal:output" events and its end as a "terminal:exit" event.
+func (a *App) CreateTerminal(cols, rows int) (terminal.Info, error) {
+	return a.terminals.Create(terminal.Options{Dir: terminalDir(), Cols: cols, Rows: rows})
+}
+
+// WriteTerminal sends typed input to a terminal
+func (a *App) WriteTerminal(id, data string) error {
+	return a.terminals.Write(id, data)
+}
+
+// ResizeTerminal changes the size of a terminal
+func (a *App) ResizeTerminal(id string, cols, rows int) error {
+	return a.terminals.Resize(id, cols, rows)
+}
+
+// CloseTerminal ends a terminal and its shell
+func (a *App) CloseTerminal(id string) error {
+	return a.terminals.Close(id)
+}
+
+// ListTerminals describes the open terminals
+func (a *App) ListTerminals() []terminal.Info {
+	return a.terminals.List()
+}
+
+// GetTerminalOutput returns the recent output of a terminal, to redraw it
+func (a *App) GetTerminalOutput(id string) (string, error) {
+	output, _, err := a.terminals.Output(id, 0)
+	return output, err
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package terminal
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func start(opts Options) (process, error) {
+	return nil, fmt.Errorf("terminals are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,103 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// unixProcess is a program whose controlling terminal is the slave side of a
+// pty, read and written through the master side
+type unixProcess struct {
+	cmd       *exec.Cmd
+	ptmx      *os.File
+	closeOnce sync.Once
+}
+
+func start(opts Options) (process, error) {
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	if err := setSize(ptmx, opts.Cols, opts.Rows); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	cmd := exec.Command(opts.Shell, opts.Args...)
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	// a new session with the tty as its controlling terminal gives the shell
+	// job control, and a hangup when the pty closes
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	return &unixProcess{cmd: cmd, ptmx: ptmx}, nil
+}
+
+func (p *unixProcess) Read(b []byte) (int, error) {
+	return p.ptmx.Read(b)
+}
+
+func (p *unixProcess) Write(b []byte) (int, error) {
+	return p.ptmx.Write(b)
+}
+
+func (p *unixProcess) Resize(cols, rows int) error {
+	return setSize(p.ptmx, cols, rows)
+}
+
+func (p *unixProcess) Wait() (int, error) {
+	err := p.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func (p *unixProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+func (p *unixProcess) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		err = p.ptmx.Close()
+	})
+	return err
+}
+
+func setSize(f *os.File, cols, rows int) error {
+	return control(f, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+}
+
+// control runs fn with the descriptor of f; unlike f.Fd it keeps f
+// non-blocking, so closing f still interrupts a pending read
+func control(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
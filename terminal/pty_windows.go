@@ -0,0 +1,157 @@
+package terminal
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// conPTY is a program attached to a Windows pseudo console, read and written
+// through pipes
+type conPTY struct {
+	console   windows.Handle
+	process   windows.Handle
+	in        *os.File
+	out       *os.File
+	closeOnce sync.Once
+}
+
+func start(opts Options) (process, error) {
+	var inRead, inWrite, outRead, outWrite windows.Handle
+	if err := windows.CreatePipe(&inRead, &inWrite, nil, 0); err != nil {
+		return nil, err
+	}
+	if err := windows.CreatePipe(&outRead, &outWrite, nil, 0); err != nil {
+		windows.CloseHandle(inRead)
+		windows.CloseHandle(inWrite)
+		return nil, err
+	}
+	var console windows.Handle
+	err := windows.CreatePseudoConsole(coord(opts.Cols, opts.Rows), inRead, outWrite, 0, &console)
+	// the pseudo console keeps its own copies of these ends
+	windows.CloseHandle(inRead)
+	windows.CloseHandle(outWrite)
+	if err != nil {
+		windows.CloseHandle(inWrite)
+		windows.CloseHandle(outRead)
+		return nil, err
+	}
+	p := &conPTY{
+		console: console,
+		in:      os.NewFile(uintptr(inWrite), "conpty-in"),
+		out:     os.NewFile(uintptr(outRead), "conpty-out"),
+	}
+	if err := p.spawn(opts); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *conPTY) spawn(opts Options) error {
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return err
+	}
+	defer attrs.Delete()
+	// the attribute's value is the console handle itself
+	if err := attrs.Update(windows.PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE, *(*unsafe.Pointer)(unsafe.Pointer(&p.console)), unsafe.Sizeof(p.console)); err != nil {
+		return err
+	}
+	si := windows.StartupInfoEx{ProcThreadAttributeList: attrs.List()}
+	si.Cb = uint32(unsafe.Sizeof(si))
+
+	commandLine, err := windows.UTF16PtrFromString(windows.ComposeCommandLine(append([]string{opts.Shell}, opts.Args...)))
+	if err != nil {
+		return err
+	}
+	var dir *uint16
+	if opts.Dir != "" {
+		if dir, err = windows.UTF16PtrFromString(opts.Dir); err != nil {
+			return err
+		}
+	}
+	env := environmentBlock(opts.Env)
+	var pi windows.ProcessInformation
+	flags := uint32(windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_UNICODE_ENVIRONMENT)
+	if err := windows.CreateProcess(nil, commandLine, nil, nil, false, flags, &env[0], dir, &si.StartupInfo, &pi); err != nil {
+		return err
+	}
+	windows.CloseHandle(pi.Thread)
+	p.process = pi.Process
+	return nil
+}
+
+func (p *conPTY) Read(b []byte) (int, error) {
+	return p.out.Read(b)
+}
+
+func (p *conPTY) Write(b []byte) (int, error) {
+	return p.in.Write(b)
+}
+
+func (p *conPTY) Resize(cols, rows int) error {
+	return windows.ResizePseudoConsole(p.console, coord(cols, rows))
+}
+
+func (p *conPTY) Wait() (int, error) {
+	defer windows.CloseHandle(p.process)
+	if _, err := windows.WaitForSingleObject(p.process, windows.INFINITE); err != nil {
+		return 0, err
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(p.process, &code); err != nil {
+		return 0, err
+	}
+	return int(code), nil
+}
+
+func (p *conPTY) Kill() error {
+	return windows.TerminateProcess(p.process, 1)
+}
+
+// Close closes the pseudo console, which ends the output pipe
+func (p *conPTY) Close() error {
+	p.closeOnce.Do(func() {
+		windows.ClosePseudoConsole(p.console)
+		p.in.Close()
+		p.out.Close()
+	})
+	return nil
+}
+
+func coord(cols, rows int) windows.Coord {
+	return windows.Coord{X: int16(cols), Y: int16(rows)}
+}
+
+// environmentBlock returns env as a Unicode environment block, where a later
+// value of a variable replaces an earlier one
+func environmentBlock(env []string) []uint16 {
+	index := make(map[string]int)
+	var vars []string
+	for _, kv := range env {
+		if strings.IndexByte(kv, 0) >= 0 {
+			continue
+		}
+		key, _, _ := strings.Cut(kv, "=")
+		key = strings.ToUpper(key)
+		if i, ok := index[key]; ok && key != "" {
+			vars[i] = kv
+			continue
+		}
+		index[key] = len(vars)
+		vars = append(vars, kv)
+	}
+	var block []uint16
+	for _, kv := range vars {
+		u, _ := windows.UTF16FromString(kv)
+		block = append(block, u...)
+	}
+	if len(block) == 0 {
+		block = append(block, 0)
+	}
+	return append(block, 0)
+}
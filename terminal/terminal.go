@@ -0,0 +1,384 @@
+// Package terminal runs interactive shells on pseudo-terminals for the
+// integrated terminal panel. Linux and macOS use the system's pty devices and
+// Windows uses ConPTY.
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ScrollbackSize is how much recent output a session keeps for Output
+const ScrollbackSize = 256 * 1024
+
+const (
+	defaultCols = 80
+	defaultRows = 24
+)
+
+// ErrNotFound is returned for a session ID the manager does not know
+var ErrNotFound = errors.New("terminal session not found")
+
+// Options configures a new session
+type Options struct {
+	// Shell is the program to run; empty means DefaultShell
+	Shell string
+	Args  []string
+	// Dir is the working directory of the shell
+	Dir string
+	// Env is added to the environment of the process as "KEY=value" pairs
+	Env  []string
+	Cols int
+	Rows int
+}
+
+// Info describes a session
+type Info struct {
+	ID       string `json:"id"`
+	Shell    string `json:"shell"`
+	Dir      string `json:"dir"`
+	Cols     int    `json:"cols"`
+	Rows     int    `json:"rows"`
+	Exited   bool   `json:"exited"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// process is a program running on a pseudo-terminal
+type process interface {
+	io.ReadWriter
+	Resize(cols, rows int) error
+	// Wait waits for the program to exit and returns its exit code
+	Wait() (int, error)
+	Kill() error
+	// Close releases the pseudo-terminal, ending reads
+	Close() error
+}
+
+// DefaultShell returns $SHELL, or /bin/sh, and PowerShell on Windows
+func DefaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "powershell.exe"
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+type session struct {
+	info Info
+	proc process
+	done chan struct{}
+
+	mu sync.Mutex
+	// scrollback holds the last output, which ends at offset written
+	scrollback []byte
+	written    int64
+	// partial holds the bytes of a UTF-8 sequence split across reads
+	partial []byte
+}
+
+// Manager keeps the terminal sessions of an application
+type Manager struct {
+	onOutput func(id, data string)
+	onExit   func(id string, exitCode int)
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	nextID   int
+}
+
+// NewManager returns a manager that passes the output of every session to
+// onOutput and reports exited sessions to onExit; either may be nil
+func NewManager(onOutput func(id, data string), onExit func(id string, exitCode int)) *Manager {
+	return &Manager{
+		onOutput: onOutput,
+		onExit:   onExit,
+		sessions: make(map[string]*session),
+	}
+}
+
+// Create starts a shell on a new pseudo-terminal
+func (m *Manager) Create(opts Options) (Info, error) {
+	if opts.Shell == "" {
+		opts.Shell = DefaultShell()
+	}
+	if opts.Cols <= 0 {
+		opts.Cols = defaultCols
+	}
+	if opts.Rows <= 0 {
+		opts.Rows = defaultRows
+	}
+	env := append(os.Environ(), "TERM=xterm-256color")
+	opts.Env = append(env, opts.Env...)
+
+	proc, err := start(opts)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to start %s: %w", opts.Shell, err)
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	s := &session{
+		info: Info{
+			ID:    strconv.Itoa(m.nextID),
+			Shell: opts.Shell,
+			Dir:   opts.Dir,
+			Cols:  opts.Cols,
+			Rows:  opts.Rows,
+		},
+		proc: proc,
+		done: make(chan struct{}),
+	}
+	m.sessions[s.info.ID] = s
+	m.mu.Unlock()
+
+	go m.read(s)
+	return s.info, nil
+}
+
+// read passes the output of s on until the process exits
+func (m *Manager) read(s *session) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.proc.Read(buf)
+		if n > 0 {
+			if data := s.append(buf[:n]); data != "" && m.onOutput != nil {
+				m.onOutput(s.info.ID, data)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	code, err := s.proc.Wait()
+	if err != nil {
+		code = -1
+	}
+	s.proc.Close()
+
+	s.mu.Lock()
+	s.info.Exited = true
+	s.info.ExitCode = code
+	s.mu.Unlock()
+	close(s.done)
+	if m.onExit != nil {
+		m.onExit(s.info.ID, code)
+	}
+}
+
+// append records output in the scrollback and returns it as text, holding
+// back an incomplete UTF-8 sequence at its end until the next read
+func (s *session) append(p []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.partial, p...)
+	s.partial = nil
+	if cut := incompleteSuffix(data); cut > 0 {
+		s.partial = append([]byte(nil), data[len(data)-cut:]...)
+		data = data[:len(data)-cut]
+	}
+	s.scrollback = append(s.scrollback, data...)
+	if over := len(s.scrollback) - ScrollbackSize; over > 0 {
+		s.scrollback = append([]byte(nil), s.scrollback[over:]...)
+	}
+	s.written += int64(len(data))
+	return string(data)
+}
+
+// incompleteSuffix returns the length of a UTF-8 sequence that p ends in the
+// middle of, or 0
+func incompleteSuffix(p []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if !utf8.RuneStart(c) {
+			continue
+		}
+		var need int
+		switch {
+		case c&0xE0 == 0xC0:
+			need = 2
+		case c&0xF0 == 0xE0:
+			need = 3
+		case c&0xF8 == 0xF0:
+			need = 4
+		default:
+			return 0
+		}
+		if need > i {
+			return i
+		}
+		return 0
+	}
+	return 0
+}
+
+func (m *Manager) get(id string) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return s, nil
+}
+
+// Write sends input, such as typed keys, to a session
+func (m *Manager) Write(id, data string) error {
+	s, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-s.done:
+		return fmt.Errorf("terminal session %s has exited", id)
+	default:
+	}
+	if _, err := io.WriteString(s.proc, data); err != nil {
+		return fmt.Errorf("failed to write to terminal: %w", err)
+	}
+	return nil
+}
+
+// Resize changes the size of a session's terminal
+func (m *Manager) Resize(id string, cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", cols, rows)
+	}
+	s, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.info.Exited {
+		return nil
+	}
+	if err := s.proc.Resize(cols, rows); err != nil {
+		return fmt.Errorf("failed to resize terminal: %w", err)
+	}
+	s.info.Cols, s.info.Rows = cols, rows
+	return nil
+}
+
+// Output returns the output of a session from offset on, as far as the
+// scrollback still holds it, and the offset where it ends
+func (m *Manager) Output(id string, offset int64) (string, int64, error) {
+	s, err := m.get(id)
+	if err != nil {
+		return "", 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first := s.written - int64(len(s.scrollback))
+	if offset < first {
+		offset = first
+	}
+	if offset > s.written {
+		offset = s.written
+	}
+	return string(s.scrollback[offset-first:]), s.written, nil
+}
+
+// Done returns a channel that is closed when the session's process exits
+func (m *Manager) Done(id string) (<-chan struct{}, error) {
+	s, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.done, nil
+}
+
+// Close ends a session, killing its process if it still runs
+func (m *Manager) Close(id string) error {
+	s, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		// closing the pseudo-terminal also ends reads that programs the
+		// shell left running still keep open
+		s.proc.Kill()
+		s.proc.Close()
+		<-s.done
+	}
+	return nil
+}
+
+// CloseAll ends every session
+func (m *Manager) CloseAll() {
+	for _, info := range m.List() {
+		m.Close(info.ID)
+	}
+}
+
+// List describes the sessions in the order they were created
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	sessions := make([]*session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+	infos := make([]Info, 0, len(sessions))
+	for _, s := range sessions {
+		s.mu.Lock()
+		infos = append(infos, s.info)
+		s.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.Atoi(infos[i].ID)
+		b, _ := strconv.Atoi(infos[j].ID)
+		return a < b
+	})
+	return infos
+}
+
+// PlainText removes escape sequences from terminal output and turns its line
+// endings into "\n", for readers that are not terminals
+func PlainText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\x1b' && i+1 < len(s) && s[i+1] == '[':
+			// CSI: parameters end at a byte in @..~
+			i += 2
+			for i < len(s) && (s[i] < '@' || s[i] > '~') {
+				i++
+			}
+		case c == '\x1b' && i+1 < len(s) && s[i+1] == ']':
+			// OSC: ends at BEL or ESC \
+			i += 2
+			for i < len(s) && s[i] != '\a' && !(s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\') {
+				i++
+			}
+			if i < len(s) && s[i] == '\x1b' {
+				i++
+			}
+		case c == '\x1b':
+			i++
+		case c == '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				continue
+			}
+			b.WriteByte('\n')
+		case c == '\n' || c == '\t' || c >= ' ':
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
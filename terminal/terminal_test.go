@@ -0,0 +1,138 @@
+package terminal
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func skipWithoutPTY(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("the test runs /bin/sh")
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionRunsShell(t *testing.T) {
+	skipWithoutPTY(t)
+	var mu sync.Mutex
+	var output strings.Builder
+	exited := make(chan int, 1)
+	m := NewManager(func(id, data string) {
+		mu.Lock()
+		output.WriteString(data)
+		mu.Unlock()
+	}, func(id string, code int) {
+		exited <- code
+	})
+	dir := t.TempDir()
+	info, err := m.Create(Options{Shell: "/bin/sh", Dir: dir, Env: []string{"IGO_TERM_TEST=hello"}, Cols: 100, Rows: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID == "" || info.Cols != 100 || info.Rows != 30 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+
+	if err := m.Write(info.ID, "echo $IGO_TERM_TEST-$(pwd); stty size\n"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "echo output", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(output.String(), "hello-"+dir) && strings.Contains(output.String(), "30 100")
+	})
+
+	if err := m.Resize(info.ID, 120, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(info.ID, "stty size; exit 3\n"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exited:
+		if code != 3 {
+			t.Fatalf("exit code = %d, want 3", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell did not exit")
+	}
+	text, end, err := m.Output(info.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "40 120") || end != int64(len(text)) {
+		t.Fatalf("unexpected scrollback %q (end %d)", text, end)
+	}
+	if list := m.List(); len(list) != 1 || !list[0].Exited || list[0].ExitCode != 3 {
+		t.Fatalf("unexpected sessions %+v", list)
+	}
+	if err := m.Write(info.ID, "echo again\n"); err == nil {
+		t.Fatal("expected writing to an exited session to fail")
+	}
+	if err := m.Close(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(info.ID, "x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCloseKillsRunningShell(t *testing.T) {
+	skipWithoutPTY(t)
+	m := NewManager(nil, nil)
+	info, err := m.Create(Options{Shell: "/bin/sh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(info.ID, "sleep 60\n"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		m.CloseAll()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseAll did not return")
+	}
+	if len(m.List()) != 0 {
+		t.Fatal("expected no sessions after CloseAll")
+	}
+}
+
+func TestOutputKeepsSplitRunes(t *testing.T) {
+	s := &session{}
+	word := []byte("é中")
+	if got := s.append(word[:1]); got != "" {
+		t.Fatalf("incomplete rune passed on: %q", got)
+	}
+	if got := s.append(word[1:3]); got != "é" {
+		t.Fatalf("got %q, want é", got)
+	}
+	if got := s.append(word[3:]); got != "中" {
+		t.Fatalf("got %q, want 中", got)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	in := "\x1b]0;user@host\x07\x1b[1;32m$\x1b[0m ls\r\na.csv\tb.csv\r\n\x1b[?2004h"
+	if got, want := PlainText(in), "$ ls\na.csv\tb.csv\n"; got != want {
+		t.Fatalf("PlainText = %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"html"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unicode/utf8"
 
 	"github.com/HazelnutParadise/idensyra/igonb"
@@ -22,15 +24,19 @@ import (
 
 // WorkspaceFile represents a single file in the workspace
 type WorkspaceFile struct {
-	Name         string `json:"name"`
-	Content      string `json:"content"`
-	Modified     bool   `json:"modified"`
-	Size         int64  `json:"size"`
-	TooLarge     bool   `json:"tooLarge"`
-	IsBinary     bool   `json:"isBinary"`
-	IsDir        bool   `json:"isDir"`
-	SavedContent string `json:"-"`
-	IsNew        bool   `json:"-"`
+	Name         string    `json:"name"`
+	Content      string    `json:"content"`
+	Modified     bool      `json:"modified"`
+	Size         int64     `json:"size"`
+	TooLarge     bool      `json:"tooLarge"`
+	IsBinary     bool      `json:"isBinary"`
+	IsDir        bool      `json:"isDir"`
+	Loaded       bool      `json:"loaded"`
+	GitStatus    string    `json:"gitStatus,omitempty"`
+	GitStaged    bool      `json:"gitStaged,omitempty"`
+	SavedContent string    `json:"-"`
+	IsNew        bool      `json:"-"`
+	DiskModTime  time.Time `json:"-"`
 }
 
 // Workspace manages a workspace with multiple Go files
@@ -42,6 +48,7 @@ type Workspace struct {
 	activeFile  string
 	initialized bool
 	modified    bool
+	cache       contentCache // least recently used file contents
 }
 
 var globalWorkspace *Workspace
@@ -109,12 +116,14 @@ func (a *App) InitWorkspace() error {
 	}
 	globalWorkspace.files["main.go"] = defaultFile
 	globalWorkspace.activeFile = "main.go"
+	trackFileContentLocked("main.go", defaultFile)
 
 	// Write default file to directory
 	err = os.WriteFile(filepath.Join(tempDir, "main.go"), []byte(preCode+"\n"+defaultCode+"\n"+endCode), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write default file: %w", err)
 	}
+	recordDiskStateLocked(defaultFile, filepath.Join(tempDir, "main.go"))
 
 	return nil
 }
@@ -126,27 +135,24 @@ func (a *App) GetWorkspaceFiles() []WorkspaceFile {
 	}
 
 	globalWorkspace.mu.Lock()
-	defer globalWorkspace.mu.Unlock()
-
 	refreshWorkspaceFromDiskLocked()
+	files := snapshotWorkspaceLocked()
+	globalWorkspace.mu.Unlock()
 
-	files := make([]WorkspaceFile, 0, len(globalWorkspace.files))
-	for _, file := range globalWorkspace.files {
-		files = append(files, *file)
-	}
-
-	// Sort files by name for consistent ordering
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name < files[j].Name
-	})
-
+	// git runs outside the lock so a slow repository does not block edits
+	annotateGitStatus(files)
 	return files
 }
 
+// snapshotWorkspaceLocked returns the file metadata sorted by name; contents are
+// loaded separately through GetFileContent
 func snapshotWorkspaceLocked() []WorkspaceFile {
 	files := make([]WorkspaceFile, 0, len(globalWorkspace.files))
 	for _, file := range globalWorkspace.files {
-		files = append(files, *file)
+		meta := *file
+		meta.Content = ""
+		meta.SavedContent = ""
+		files = append(files, meta)
 	}
 
 	sort.Slice(files, func(i, j int) bool {
@@ -209,6 +215,8 @@ func refreshWorkspaceFromDiskLocked() {
 				existing.IsDir = true
 				existing.Content = ""
 				existing.SavedContent = ""
+				existing.Loaded = false
+				globalWorkspace.cache.remove(displayName)
 				existing.Modified = false
 				existing.IsNew = false
 				return nil
@@ -228,55 +236,24 @@ func refreshWorkspaceFromDiskLocked() {
 			return nil
 		}
 
-		var contentStr string
-		var isBinary bool
-		tooLarge := isFileTooLarge(info.Size())
-		if !tooLarge {
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-
-			isBinary = shouldTreatAsBinary(displayName, content)
-			if isBinary {
-				contentStr = base64.StdEncoding.EncodeToString(content)
-			} else {
-				contentStr = string(content)
-				if strings.HasSuffix(displayName, ".go") {
-					contentStr = strings.TrimPrefix(contentStr, preCode+"\n")
-					contentStr = strings.TrimSuffix(contentStr, "\n"+endCode)
-				}
-			}
-		}
-
 		if existing, exists := globalWorkspace.files[displayName]; exists {
 			if existing.Modified || existing.IsNew {
 				return nil
 			}
-			existing.Size = info.Size()
-			existing.TooLarge = tooLarge
-			existing.IsBinary = isBinary
-			existing.IsDir = false
-			if existing.Content != contentStr {
-				existing.Content = contentStr
-				existing.SavedContent = contentStr
-				existing.Modified = false
-				existing.IsNew = false
+			if !existing.IsDir && existing.Size == info.Size() && existing.DiskModTime.Equal(info.ModTime()) {
+				return nil
 			}
+			unloadFileContentLocked(displayName, existing)
+			refreshed := newWorkspaceFileFromDisk(displayName, path, info)
+			existing.Size = refreshed.Size
+			existing.TooLarge = refreshed.TooLarge
+			existing.IsBinary = refreshed.IsBinary
+			existing.IsDir = false
+			existing.DiskModTime = refreshed.DiskModTime
 			return nil
 		}
 
-		globalWorkspace.files[displayName] = &WorkspaceFile{
-			Name:         displayName,
-			Content:      contentStr,
-			Size:         info.Size(),
-			TooLarge:     tooLarge,
-			IsBinary:     isBinary,
-			IsDir:        false,
-			SavedContent: contentStr,
-			IsNew:        false,
-			Modified:     false,
-		}
+		globalWorkspace.files[displayName] = newWorkspaceFileFromDisk(displayName, path, info)
 		return nil
 	})
 
@@ -287,6 +264,7 @@ func refreshWorkspaceFromDiskLocked() {
 		if file.Modified || file.IsNew {
 			continue
 		}
+		globalWorkspace.cache.remove(name)
 		delete(globalWorkspace.files, name)
 	}
 
@@ -309,6 +287,27 @@ func refreshWorkspaceFromDiskLocked() {
 	updateWorkspaceModifiedLocked()
 }
 
+// readWorkspaceDiskFile reads a file the way it is held in the workspace: binary
+// content is base64-encoded and files too large to load are left empty
+func readWorkspaceDiskFile(displayName string, path string, size int64) (string, bool, bool, error) {
+	if isFileTooLarge(size) {
+		return "", false, true, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, false, err
+	}
+	if shouldTreatAsBinary(displayName, content) {
+		return base64.StdEncoding.EncodeToString(content), true, false, nil
+	}
+	contentStr := string(content)
+	if wrapsGoCode(displayName) {
+		contentStr = strings.TrimPrefix(contentStr, preCode+"\n")
+		contentStr = strings.TrimSuffix(contentStr, "\n"+endCode)
+	}
+	return contentStr, false, false, nil
+}
+
 // GetActiveFile returns the name of the currently active file
 func (a *App) GetActiveFile() string {
 	if globalWorkspace == nil {
@@ -338,12 +337,15 @@ func (a *App) SetActiveFile(filename string) error {
 		globalWorkspace.mu.Unlock()
 		return fmt.Errorf("file not found: %s", cleanName)
 	}
-	if file := globalWorkspace.files[cleanName]; file.IsDir {
+	file := globalWorkspace.files[cleanName]
+	if file.IsDir {
 		globalWorkspace.mu.Unlock()
 		return fmt.Errorf("path is a directory: %s", cleanName)
 	}
 
 	globalWorkspace.activeFile = cleanName
+	// Preload the content the editor is about to request
+	_ = loadFileContentLocked(cleanName, file)
 	files := snapshotWorkspaceLocked()
 	activeFile := globalWorkspace.activeFile
 	globalWorkspace.mu.Unlock()
@@ -366,8 +368,8 @@ func (a *App) GetFileContent(filename string) (string, error) {
 		return "", err
 	}
 
-	globalWorkspace.mu.RLock()
-	defer globalWorkspace.mu.RUnlock()
+	globalWorkspace.mu.Lock()
+	defer globalWorkspace.mu.Unlock()
 
 	file, exists := globalWorkspace.files[cleanName]
 	if !exists {
@@ -381,6 +383,10 @@ func (a *App) GetFileContent(filename string) (string, error) {
 		return "", fmt.Errorf("file too large to preview")
 	}
 
+	if err := loadFileContentLocked(cleanName, file); err != nil {
+		return "", err
+	}
+
 	return file.Content, nil
 }
 
@@ -441,6 +447,16 @@ func shouldTreatAsBinary(filename string, content []byte) bool {
 	return isBinaryContent(content)
 }
 
+// wrapsGoCode reports whether a file is Go code stored with the package clause the
+// editor hides; percent-format notebooks are stored as they are
+func wrapsGoCode(filename string) bool {
+	if !strings.HasSuffix(filename, ".go") {
+		return false
+	}
+	_, isNotebook := igonb.TextFormatForPath(filename)
+	return !isNotebook
+}
+
 // UpdateFileContent updates the content of a file
 func (a *App) UpdateFileContent(filename string, content string) error {
 	if globalWorkspace == nil {
@@ -472,6 +488,11 @@ func (a *App) UpdateFileContent(filename string, content string) error {
 		return fmt.Errorf("file too large to edit")
 	}
 
+	if err := loadFileContentLocked(cleanName, file); err != nil {
+		globalWorkspace.mu.Unlock()
+		return err
+	}
+
 	if file.Content == content {
 		globalWorkspace.mu.Unlock()
 		return nil
@@ -480,6 +501,7 @@ func (a *App) UpdateFileContent(filename string, content string) error {
 	file.Content = content
 	file.Size = int64(len(content))
 	file.Modified = file.IsNew || file.Content != file.SavedContent
+	trackFileContentLocked(cleanName, file)
 	updateWorkspaceModifiedLocked()
 
 	// Notify frontend that workspace changed
@@ -487,6 +509,7 @@ func (a *App) UpdateFileContent(filename string, content string) error {
 	activeFile := globalWorkspace.activeFile
 	globalWorkspace.mu.Unlock()
 	a.emitWorkspaceChanged(files, activeFile)
+	a.notifyMCPFilesChanged(cleanName)
 
 	return nil
 }
@@ -523,6 +546,11 @@ func (a *App) SaveFile(filename string) error {
 		globalWorkspace.mu.Unlock()
 		return fmt.Errorf("file too large to save")
 	}
+	if !file.Loaded {
+		// Never loaded, so the disk already holds the content
+		globalWorkspace.mu.Unlock()
+		return nil
+	}
 
 	// Only wrap with preCode/endCode for .go files
 	var fullContent []byte
@@ -533,7 +561,7 @@ func (a *App) SaveFile(filename string) error {
 			return fmt.Errorf("failed to decode file: %w", err)
 		}
 		fullContent = decoded
-	} else if strings.HasSuffix(cleanName, ".go") {
+	} else if wrapsGoCode(cleanName) {
 		fullContent = []byte(preCode + "\n" + file.Content + "\n" + endCode)
 	} else {
 		fullContent = []byte(file.Content)
@@ -553,6 +581,7 @@ func (a *App) SaveFile(filename string) error {
 	file.Modified = false
 	file.IsNew = false
 	file.SavedContent = file.Content
+	recordDiskStateLocked(file, filePath)
 
 	updateWorkspaceModifiedLocked()
 
@@ -580,7 +609,7 @@ func (a *App) SaveAllFiles() error {
 	}
 
 	for filename, file := range globalWorkspace.files {
-		if file.IsDir || file.TooLarge {
+		if file.IsDir || file.TooLarge || !file.Loaded {
 			continue
 		}
 		// Only wrap with preCode/endCode for .go files
@@ -591,7 +620,7 @@ func (a *App) SaveAllFiles() error {
 				return fmt.Errorf("failed to decode file %s: %w", filename, err)
 			}
 			fullContent = decoded
-		} else if strings.HasSuffix(filename, ".go") {
+		} else if wrapsGoCode(filename) {
 			fullContent = []byte(preCode + "\n" + file.Content + "\n" + endCode)
 		} else {
 			fullContent = []byte(file.Content)
@@ -608,6 +637,7 @@ func (a *App) SaveAllFiles() error {
 		file.Modified = false
 		file.IsNew = false
 		file.SavedContent = file.Content
+		recordDiskStateLocked(file, filePath)
 	}
 
 	updateWorkspaceModifiedLocked()
@@ -653,7 +683,7 @@ func (a *App) AutoSaveTempWorkspace() error {
 				continue // Skip files that fail to decode
 			}
 			fullContent = decoded
-		} else if strings.HasSuffix(filename, ".go") {
+		} else if wrapsGoCode(filename) {
 			fullContent = []byte(preCode + "\n" + file.Content + "\n" + endCode)
 		} else {
 			fullContent = []byte(file.Content)
@@ -669,6 +699,7 @@ func (a *App) AutoSaveTempWorkspace() error {
 		// Mark as saved but keep Modified=true so user knows there are unsaved changes
 		// relative to their "official" save
 		file.SavedContent = file.Content
+		recordDiskStateLocked(file, filePath)
 	}
 
 	return nil
@@ -694,7 +725,14 @@ func (a *App) CreateNewFile(filename string) error {
 
 	// Create new file with appropriate default content based on extension
 	var content string
-	if strings.HasSuffix(cleanName, ".go") {
+	if format, ok := igonb.TextFormatForPath(cleanName); ok {
+		defaultContent, err := igonb.MarshalText(igonb.NewNotebook(), format)
+		if err != nil {
+			globalWorkspace.mu.Unlock()
+			return fmt.Errorf("failed to create text notebook template: %w", err)
+		}
+		content = string(defaultContent)
+	} else if strings.HasSuffix(cleanName, ".go") {
 		content = defaultCode
 	} else if strings.HasSuffix(cleanName, ".igonb") {
 		defaultContent, err := igonb.DefaultNotebookJSON()
@@ -717,7 +755,7 @@ func (a *App) CreateNewFile(filename string) error {
 		}
 		// Write content to disk (with preCode/endCode for .go files)
 		var fullContent []byte
-		if strings.HasSuffix(cleanName, ".go") {
+		if wrapsGoCode(cleanName) {
 			fullContent = []byte(preCode + "\n" + content + "\n" + endCode)
 		} else {
 			fullContent = []byte(content)
@@ -740,6 +778,10 @@ func (a *App) CreateNewFile(filename string) error {
 		Modified:     false,   // Not modified since saved to disk
 	}
 	globalWorkspace.files[cleanName] = newFile
+	trackFileContentLocked(cleanName, newFile)
+	if globalWorkspace.workDir != "" {
+		recordDiskStateLocked(newFile, filepath.Join(globalWorkspace.workDir, filepath.FromSlash(cleanName)))
+	}
 
 	parentParts := strings.Split(cleanName, "/")
 	if len(parentParts) > 1 {
@@ -765,6 +807,7 @@ func (a *App) CreateNewFile(filename string) error {
 	activeFile := globalWorkspace.activeFile
 	globalWorkspace.mu.Unlock()
 	a.emitWorkspaceChanged(files, activeFile)
+	a.notifyMCPFilesChanged(cleanName)
 
 	return nil
 }
@@ -799,6 +842,7 @@ func (a *App) DeleteFile(filename string) error {
 	}
 
 	delete(globalWorkspace.files, cleanName)
+	globalWorkspace.cache.remove(cleanName)
 
 	// Remove from disk regardless of temp workspace to prevent re-adding on refresh
 	if globalWorkspace.workDir != "" {
@@ -823,6 +867,7 @@ func (a *App) DeleteFile(filename string) error {
 	activeFile := globalWorkspace.activeFile
 	globalWorkspace.mu.Unlock()
 	a.emitWorkspaceChanged(files, activeFile)
+	a.notifyMCPFilesChanged(cleanName)
 
 	return nil
 }
@@ -882,6 +927,10 @@ func (a *App) RenameFile(oldName string, newName string) error {
 	delete(globalWorkspace.files, cleanOld)
 	file.Name = cleanNew
 	globalWorkspace.files[cleanNew] = file
+	globalWorkspace.cache.remove(cleanOld)
+	if file.Loaded {
+		trackFileContentLocked(cleanNew, file)
+	}
 
 	if globalWorkspace.activeFile == cleanOld {
 		globalWorkspace.activeFile = cleanNew
@@ -894,6 +943,7 @@ func (a *App) RenameFile(oldName string, newName string) error {
 	activeFile := globalWorkspace.activeFile
 	globalWorkspace.mu.Unlock()
 	a.emitWorkspaceChanged(files, activeFile)
+	a.notifyMCPFilesChanged(cleanOld, cleanNew)
 
 	return nil
 }
@@ -988,6 +1038,7 @@ func (a *App) DeleteFolder(folderPath string) error {
 	for name := range globalWorkspace.files {
 		if name == cleanPath || strings.HasPrefix(name, prefix) {
 			delete(globalWorkspace.files, name)
+			globalWorkspace.cache.remove(name)
 		}
 	}
 
@@ -1064,11 +1115,15 @@ func (a *App) RenameFolder(oldPath string, newPath string) error {
 			entry.Name = newName
 			updates[newName] = entry
 			delete(globalWorkspace.files, name)
+			globalWorkspace.cache.remove(name)
 		}
 	}
 	globalWorkspace.files[cleanNew] = file
 	for name, entry := range updates {
 		globalWorkspace.files[name] = entry
+		if entry.Loaded {
+			trackFileContentLocked(name, entry)
+		}
 	}
 
 	if globalWorkspace.activeFile == cleanOld {
@@ -1177,40 +1232,10 @@ func (a *App) OpenWorkspace() (string, error) {
 			continue
 		}
 
-		var contentStr string
-		var isBinary bool
-		tooLarge := isFileTooLarge(entry.size)
-		if !tooLarge {
-			content, err := os.ReadFile(entry.path)
-			if err != nil {
-				processedBytes += entry.size
-				processedFiles++
-				emitWorkspaceOpenProgress(a.ctx, "progress", entry.displayName, processedBytes, totalBytes, processedFiles, totalFiles, "")
-				continue
-			}
-
-			isBinary = shouldTreatAsBinary(entry.displayName, content)
-			if isBinary {
-				contentStr = base64.StdEncoding.EncodeToString(content)
-			} else {
-				contentStr = string(content)
-				if strings.HasSuffix(entry.displayName, ".go") {
-					contentStr = strings.TrimPrefix(contentStr, preCode+"\n")
-					contentStr = strings.TrimSuffix(contentStr, "\n"+endCode)
-				}
-			}
-		}
-
-		workspaceFiles[entry.displayName] = &WorkspaceFile{
-			Name:         entry.displayName,
-			Content:      contentStr,
-			Size:         entry.size,
-			TooLarge:     tooLarge,
-			IsBinary:     isBinary,
-			IsDir:        false,
-			SavedContent: contentStr,
-			IsNew:        false,
-			Modified:     false,
+		// Only metadata is read here; contents load when a file is opened
+		info, err := os.Stat(entry.path)
+		if err == nil {
+			workspaceFiles[entry.displayName] = newWorkspaceFileFromDisk(entry.displayName, entry.path, info)
 		}
 
 		processedBytes += entry.size
@@ -1237,6 +1262,7 @@ func (a *App) OpenWorkspace() (string, error) {
 	globalWorkspace.workDir = dirPath
 	globalWorkspace.isTemp = false
 	globalWorkspace.files = make(map[string]*WorkspaceFile)
+	globalWorkspace.cache = contentCache{}
 
 	for filename, file := range workspaceFiles {
 		globalWorkspace.files[filename] = file
@@ -1285,6 +1311,12 @@ func (a *App) CreateWorkspace() (string, error) {
 	globalWorkspace.mu.Lock()
 	defer globalWorkspace.mu.Unlock()
 
+	// Save all current files to new workspace, copying unloaded files from the
+	// old directory before it is removed
+	if err := copyWorkspaceFilesLocked(globalWorkspace.workDir, selectedPath); err != nil {
+		return "", err
+	}
+
 	if globalWorkspace.isTemp {
 		os.RemoveAll(globalWorkspace.workDir)
 	}
@@ -1292,24 +1324,6 @@ func (a *App) CreateWorkspace() (string, error) {
 	// Set new workspace directory
 	globalWorkspace.workDir = selectedPath
 	globalWorkspace.isTemp = false
-
-	// Save all current files to new workspace
-	for filename, file := range globalWorkspace.files {
-		if file.IsDir {
-			folderPath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-			if err := os.MkdirAll(folderPath, 0755); err != nil {
-				return "", fmt.Errorf("failed to create folder %s: %w", filename, err)
-			}
-		}
-		if file.IsDir {
-			continue
-		}
-		fpath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-		if err := os.WriteFile(fpath, []byte(file.Content), 0644); err != nil {
-			return "", fmt.Errorf("failed to write file %s: %w", filename, err)
-		}
-	}
-
 	globalWorkspace.modified = false
 
 	return selectedPath, nil
@@ -1355,8 +1369,8 @@ func (a *App) OpenWorkspaceAt(dirPath string) (string, error) {
 
 		// continue same scanning as in OpenWorkspace
 		var entry workspaceScanEntry
-		entry.displayName = relPath
-		entry.path = relPath
+		entry.displayName = filepath.ToSlash(relPath)
+		entry.path = entry.displayName
 		entry.isDir = d.IsDir()
 		if !d.IsDir() {
 			info, err := d.Info()
@@ -1381,13 +1395,13 @@ func (a *App) OpenWorkspaceAt(dirPath string) (string, error) {
 			workspaceFiles[e.path] = &WorkspaceFile{Name: e.path, IsDir: true}
 			continue
 		}
-		// read file contents
+		// Only metadata is read here; contents load when a file is opened
 		full := filepath.Join(dirPath, filepath.FromSlash(e.path))
-		content, err := os.ReadFile(full)
+		info, err := os.Stat(full)
 		if err != nil {
 			return "", fmt.Errorf("failed to read file %s: %w", e.path, err)
 		}
-		workspaceFiles[e.path] = &WorkspaceFile{Name: e.path, IsDir: false, Content: string(content), Size: int64(len(content))}
+		workspaceFiles[e.path] = newWorkspaceFileFromDisk(e.path, full, info)
 	}
 
 	// Replace current workspace
@@ -1400,6 +1414,7 @@ func (a *App) OpenWorkspaceAt(dirPath string) (string, error) {
 	globalWorkspace.workDir = dirPath
 	globalWorkspace.isTemp = false
 	globalWorkspace.files = make(map[string]*WorkspaceFile)
+	globalWorkspace.cache = contentCache{}
 	for filename, file := range workspaceFiles {
 		globalWorkspace.files[filename] = file
 	}
@@ -1431,6 +1446,12 @@ func (a *App) CreateWorkspaceAt(selectedPath string) (string, error) {
 	globalWorkspace.mu.Lock()
 	defer globalWorkspace.mu.Unlock()
 
+	// Save all current files to new workspace, copying unloaded files from the
+	// old directory before it is removed
+	if err := copyWorkspaceFilesLocked(globalWorkspace.workDir, selectedPath); err != nil {
+		return "", err
+	}
+
 	if globalWorkspace.isTemp {
 		os.RemoveAll(globalWorkspace.workDir)
 	}
@@ -1438,63 +1459,6 @@ func (a *App) CreateWorkspaceAt(selectedPath string) (string, error) {
 	// Set new workspace directory
 	globalWorkspace.workDir = selectedPath
 	globalWorkspace.isTemp = false
-
-	// Save all current files to new workspace
-	for filename, file := range globalWorkspace.files {
-		if file.IsDir {
-			folderPath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-			if err := os.MkdirAll(folderPath, 0755); err != nil {
-				return "", fmt.Errorf("failed to create folder %s: %w", filename, err)
-			}
-			continue
-		}
-		fpath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-		if err := os.WriteFile(fpath, []byte(file.Content), 0644); err != nil {
-			return "", fmt.Errorf("failed to write file %s: %w", filename, err)
-		}
-	}
-
-	// Save all current files to new workspace with handling for large/binary files
-	for filename, file := range globalWorkspace.files {
-		if file.IsDir {
-			folderPath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-			if err := os.MkdirAll(folderPath, 0755); err != nil {
-				return "", fmt.Errorf("failed to create folder %s: %w", filename, err)
-			}
-			continue
-		}
-
-		filePath := filepath.Join(selectedPath, filepath.FromSlash(filename))
-		if file.TooLarge {
-			sourcePath := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(filename))
-			if err := copyFile(sourcePath, filePath); err != nil {
-				return "", fmt.Errorf("failed to copy file %s: %w", filename, err)
-			}
-		} else {
-			var fullContent []byte
-			if file.IsBinary {
-				decoded, err := base64.StdEncoding.DecodeString(file.Content)
-				if err != nil {
-					return "", fmt.Errorf("failed to decode file %s: %w", filename, err)
-				}
-				fullContent = decoded
-			} else if strings.HasSuffix(filename, ".go") {
-				fullContent = []byte(preCode + "\n" + file.Content + "\n" + endCode)
-			} else {
-				fullContent = []byte(file.Content)
-			}
-
-			err = os.WriteFile(filePath, fullContent, 0644)
-			if err != nil {
-				return "", fmt.Errorf("failed to write file %s: %w", filename, err)
-			}
-		}
-
-		file.Modified = false
-		file.IsNew = false
-		file.SavedContent = file.Content
-	}
-
 	globalWorkspace.modified = false
 
 	return selectedPath, nil
@@ -1663,7 +1627,7 @@ func (a *App) ImportFileToWorkspaceAt(targetDir string) error {
 		}
 	}
 
-	globalWorkspace.files[finalName] = &WorkspaceFile{
+	imported := &WorkspaceFile{
 		Name:         finalName,
 		Content:      contentStr,
 		Size:         info.Size(),
@@ -1673,6 +1637,8 @@ func (a *App) ImportFileToWorkspaceAt(targetDir string) error {
 		IsNew:        false,      // Not new since saved to disk
 		Modified:     false,      // Not modified since saved to disk
 	}
+	globalWorkspace.files[finalName] = imported
+	trackFileContentLocked(finalName, imported)
 
 	parentParts := strings.Split(finalName, "/")
 	if len(parentParts) > 1 {
@@ -1787,7 +1753,7 @@ func (a *App) ImportSpecificFileToWorkspace(sourcePath, targetDir string) error
 		}
 	}
 
-	globalWorkspace.files[finalName] = &WorkspaceFile{
+	imported := &WorkspaceFile{
 		Name:         finalName,
 		Content:      contentStr,
 		Size:         info.Size(),
@@ -1797,6 +1763,8 @@ func (a *App) ImportSpecificFileToWorkspace(sourcePath, targetDir string) error
 		IsNew:        false,      // Not new since saved to disk
 		Modified:     false,      // Not modified since saved to disk
 	}
+	globalWorkspace.files[finalName] = imported
+	trackFileContentLocked(finalName, imported)
 
 	parentParts := strings.Split(finalName, "/")
 	if len(parentParts) > 1 {
@@ -1857,11 +1825,31 @@ func readFileConcurrently(filePath string, onProgress func(readBytes, totalBytes
 		return data, nil
 	}
 
+	buf := make([]byte, size)
+	err = readChunksConcurrently(size, func(_ int, start int64, end int64) error {
+		n, err := file.ReadAt(buf[start:end], start)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if int64(n) != end-start {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}, onProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// readChunksConcurrently splits size bytes into concurrentReadChunkSize chunks and
+// runs readChunk for each of them on concurrentReadWorkers goroutines
+func readChunksConcurrently(size int64, readChunk func(index int, start int64, end int64) error, onProgress func(readBytes, totalBytes int64)) error {
 	if onProgress != nil {
 		onProgress(0, size)
 	}
 
-	buf := make([]byte, size)
 	chunkSize := int64(concurrentReadChunkSize)
 	totalChunks := int((size + chunkSize - 1) / chunkSize)
 	workers := concurrentReadWorkers
@@ -1896,17 +1884,12 @@ func readFileConcurrently(filePath string, onProgress func(readBytes, totalBytes
 					end = size
 				}
 
-				n, err := file.ReadAt(buf[start:end], start)
-				if err != nil && err != io.EOF {
+				if err := readChunk(idx, start, end); err != nil {
 					setErr(err)
 					return
 				}
-				if int64(n) != end-start {
-					setErr(io.ErrUnexpectedEOF)
-					return
-				}
 				if onProgress != nil {
-					totalRead := atomic.AddInt64(&bytesRead, int64(n))
+					totalRead := atomic.AddInt64(&bytesRead, end-start)
 					onProgress(totalRead, size)
 				}
 			}
@@ -1915,14 +1898,13 @@ func readFileConcurrently(filePath string, onProgress func(readBytes, totalBytes
 	wg.Wait()
 
 	if readErr != nil {
-		return nil, readErr
+		return readErr
 	}
 
 	if onProgress != nil {
 		onProgress(size, size)
 	}
-
-	return buf, nil
+	return nil
 }
 
 // ExportCurrentFile exports the current active file to a user-selected location
@@ -1967,7 +1949,7 @@ func (a *App) ExportCurrentFile() error {
 
 	// Write file content based on file type
 	var fullContent []byte
-	if file.TooLarge {
+	if file.TooLarge || !file.Loaded {
 		sourcePath := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(globalWorkspace.activeFile))
 		if err := copyFile(sourcePath, filename); err != nil {
 			return fmt.Errorf("failed to export file: %w", err)
@@ -1979,7 +1961,7 @@ func (a *App) ExportCurrentFile() error {
 			return fmt.Errorf("failed to decode file: %w", err)
 		}
 		fullContent = decoded
-	} else if strings.HasSuffix(globalWorkspace.activeFile, ".go") {
+	} else if wrapsGoCode(globalWorkspace.activeFile) {
 		fullContent = []byte(preCode + "\n" + file.Content + "\n" + endCode)
 	} else {
 		fullContent = []byte(file.Content)
@@ -2143,23 +2125,29 @@ func openExcelFromWorkspace(filename string) (*excelize.File, error) {
 
 	globalWorkspace.mu.RLock()
 	file, exists := globalWorkspace.files[cleanName]
-	globalWorkspace.mu.RUnlock()
-
 	if !exists {
+		globalWorkspace.mu.RUnlock()
 		return nil, fmt.Errorf("file not found: %s", cleanName)
 	}
 	if file.IsDir {
+		globalWorkspace.mu.RUnlock()
 		return nil, fmt.Errorf("path is a directory: %s", cleanName)
 	}
 	if file.TooLarge {
+		globalWorkspace.mu.RUnlock()
 		return nil, fmt.Errorf("file too large to preview")
 	}
+	content, err := workspaceFileContentLocked(cleanName, file)
+	globalWorkspace.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
 
-	if file.Content == "" {
+	if content == "" {
 		return nil, fmt.Errorf("file content unavailable")
 	}
 
-	data, err := base64.StdEncoding.DecodeString(file.Content)
+	data, err := base64.StdEncoding.DecodeString(content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode file content: %w", err)
 	}
@@ -2329,6 +2317,7 @@ func (a *App) ConvertIPyNBToIgonb(filename string) (string, error) {
 		Modified:     true,
 	}
 	globalWorkspace.files[finalName] = newFile
+	trackFileContentLocked(finalName, newFile)
 
 	// Write to disk if not temp workspace
 	if !globalWorkspace.isTemp && globalWorkspace.workDir != "" {
@@ -2381,7 +2370,7 @@ func (a *App) GetIPyNBAsIgonbContent(filename string) (string, error) {
 	// For .ipynb files, use memory content (which may have unsaved changes)
 	// This ensures we don't lose edits when switching files
 	var rawContent []byte
-	if file.Content != "" {
+	if file.Loaded {
 		// Use memory content (may have unsaved changes)
 		if file.IsBinary {
 			decoded, err := base64.StdEncoding.DecodeString(file.Content)
@@ -2393,7 +2382,7 @@ func (a *App) GetIPyNBAsIgonbContent(filename string) (string, error) {
 			rawContent = []byte(file.Content)
 		}
 	} else {
-		// Fall back to disk if memory content is empty
+		// Fall back to disk if the content is not loaded
 		filePath := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(cleanName))
 		diskContent, diskErr := os.ReadFile(filePath)
 		if diskErr != nil {
@@ -2433,6 +2422,7 @@ func (a *App) UpdateIPyNBContent(filename string, igonbContent string) error {
 		return fmt.Errorf("failed to convert to ipynb: %w", err)
 	}
 
+	defer a.notifyMCPFilesChanged(cleanName)
 	globalWorkspace.mu.Lock()
 	defer globalWorkspace.mu.Unlock()
 
@@ -2447,15 +2437,101 @@ func (a *App) UpdateIPyNBContent(filename string, igonbContent string) error {
 		return fmt.Errorf("file too large to edit")
 	}
 
+	if err := loadFileContentLocked(cleanName, file); err != nil {
+		return err
+	}
+
 	// Update the file content with the ipynb format
 	file.Content = ipynbJSON
 	file.Size = int64(len(ipynbJSON))
 	file.Modified = file.IsNew || file.Content != file.SavedContent
+	trackFileContentLocked(cleanName, file)
 	updateWorkspaceModifiedLocked()
 
 	return nil
 }
 
+// GetTextNotebookAsIgonbContent returns a .igo.go or .igo.md text notebook in
+// igonb format. When the notebook is paired, the outputs of unchanged cells are
+// taken from its .igonb file.
+func (a *App) GetTextNotebookAsIgonbContent(filename string) (string, error) {
+	cleanName, err := cleanRelativePath(filename)
+	if err != nil {
+		return "", err
+	}
+	format, ok := igonb.TextFormatForPath(cleanName)
+	if !ok {
+		return "", fmt.Errorf("file is not a text notebook: %s", cleanName)
+	}
+
+	content, err := a.GetFileContent(cleanName)
+	if err != nil {
+		return "", err
+	}
+	nb, err := igonb.ParseText([]byte(content), format)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse text notebook: %w", err)
+	}
+	if nb.Paired() {
+		// A missing or unreadable paired file only means there are no outputs yet
+		if pairedContent, err := a.GetFileContent(igonb.PairedPath(cleanName)); err == nil {
+			if paired, err := igonb.Parse([]byte(pairedContent)); err == nil {
+				nb.CopyOutputs(paired)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(nb, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal igonb: %w", err)
+	}
+	return string(data), nil
+}
+
+// UpdateTextNotebookContent updates a .igo.go or .igo.md text notebook from igonb
+// format. The text file gets the cell sources; a paired notebook also gets its
+// .igonb file, created when missing, updated with the outputs.
+func (a *App) UpdateTextNotebookContent(filename string, igonbContent string) error {
+	cleanName, err := cleanRelativePath(filename)
+	if err != nil {
+		return err
+	}
+	format, ok := igonb.TextFormatForPath(cleanName)
+	if !ok {
+		return fmt.Errorf("file is not a text notebook: %s", cleanName)
+	}
+
+	nb, err := igonb.Parse([]byte(igonbContent))
+	if err != nil {
+		return err
+	}
+	text, err := igonb.MarshalText(nb, format)
+	if err != nil {
+		return fmt.Errorf("failed to convert to text notebook: %w", err)
+	}
+	if err := a.UpdateFileContent(cleanName, string(text)); err != nil {
+		return err
+	}
+	if !nb.Paired() {
+		return nil
+	}
+
+	pairedName := igonb.PairedPath(cleanName)
+	globalWorkspace.mu.RLock()
+	_, exists := globalWorkspace.files[pairedName]
+	globalWorkspace.mu.RUnlock()
+	if !exists {
+		if err := a.CreateNewFile(pairedName); err != nil {
+			return fmt.Errorf("failed to create paired notebook: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(nb, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal igonb: %w", err)
+	}
+	return a.UpdateFileContent(pairedName, string(data))
+}
+
 // CleanupWorkspace removes the temporary workspace directory if temp
 func (a *App) CleanupWorkspace() error {
 	if globalWorkspace == nil || !globalWorkspace.initialized {
@@ -2475,6 +2551,7 @@ func (a *App) CleanupWorkspace() error {
 
 	globalWorkspace.initialized = false
 	globalWorkspace.files = nil
+	globalWorkspace.cache = contentCache{}
 	globalWorkspace = nil
 
 	return nil
@@ -2487,6 +2564,7 @@ func (a *App) domReady(ctx context.Context) {
 	if err != nil {
 		fmt.Printf("Failed to initialize workspace: %v\n", err)
 	}
+	a.startWorkspaceWatcher()
 }
 
 // beforeClose is called when the application is about to quit
@@ -2622,6 +2700,9 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
+	a.stopWorkspaceWatcher()
+	a.terminals.CloseAll()
+
 	// Ensure cleanup
 	a.CleanupWorkspace()
 
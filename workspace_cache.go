@@ -0,0 +1,264 @@
+package main
+
+import (
+	"container/list"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+const (
+	// maxLoadedContentBytes is the memory budget for cached file contents.
+	// Unsaved edits, the active file and the file used last are never evicted, so the budget may be exceeded by them.
+	maxLoadedContentBytes = 256 * 1024 * 1024
+	// binarySniffBytes is how much of a file is read to decide whether it is binary
+	binarySniffBytes = 8000
+)
+
+// loadedContentBudget is the budget evictFileContentsLocked keeps cached contents within
+var loadedContentBudget int64 = maxLoadedContentBytes
+
+// contentCache orders loaded file contents from most to least recently used
+type contentCache struct {
+	order *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+type contentCacheEntry struct {
+	name string
+	size int64
+}
+
+// touch records that name was used and holds size bytes
+func (c *contentCache) touch(name string, size int64) {
+	if c.order == nil {
+		c.order = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+	if elem, ok := c.items[name]; ok {
+		entry := elem.Value.(*contentCacheEntry)
+		c.bytes += size - entry.size
+		entry.size = size
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[name] = c.order.PushFront(&contentCacheEntry{name: name, size: size})
+	c.bytes += size
+}
+
+func (c *contentCache) remove(name string) {
+	if c.items == nil {
+		return
+	}
+	if elem, ok := c.items[name]; ok {
+		c.bytes -= elem.Value.(*contentCacheEntry).size
+		c.order.Remove(elem)
+		delete(c.items, name)
+	}
+}
+
+// loadedContentSize is the memory held by a loaded file; equal strings share their bytes
+func loadedContentSize(file *WorkspaceFile) int64 {
+	size := int64(len(file.Content))
+	if file.SavedContent != file.Content {
+		size += int64(len(file.SavedContent))
+	}
+	return size
+}
+
+// trackFileContentLocked marks content set in memory as loaded and accounts for it in the cache
+func trackFileContentLocked(name string, file *WorkspaceFile) {
+	if file.IsDir || file.TooLarge {
+		return
+	}
+	file.Loaded = true
+	globalWorkspace.cache.touch(name, loadedContentSize(file))
+	evictFileContentsLocked()
+}
+
+// loadFileContentLocked reads the content of an unloaded file from disk into the cache.
+// The caller must hold the write lock.
+func loadFileContentLocked(name string, file *WorkspaceFile) error {
+	if file.IsDir || file.TooLarge {
+		return nil
+	}
+	if file.Loaded {
+		globalWorkspace.cache.touch(name, loadedContentSize(file))
+		return nil
+	}
+	fullPath := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(name))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	content, isBinary, tooLarge, err := readWorkspaceDiskFile(name, fullPath, info.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	file.Content = content
+	file.SavedContent = content
+	file.Size = info.Size()
+	file.DiskModTime = info.ModTime()
+	file.IsBinary = isBinary
+	file.TooLarge = tooLarge
+	trackFileContentLocked(name, file)
+	return nil
+}
+
+// unloadFileContentLocked drops the cached content of an unmodified file
+func unloadFileContentLocked(name string, file *WorkspaceFile) {
+	globalWorkspace.cache.remove(name)
+	if file.Modified || file.IsNew {
+		return
+	}
+	file.Content = ""
+	file.SavedContent = ""
+	file.Loaded = false
+}
+
+// evictFileContentsLocked unloads the least recently used contents until the cache fits its budget.
+// The most recently used content stays, as its caller is about to use it.
+func evictFileContentsLocked() {
+	cache := &globalWorkspace.cache
+	for elem := cache.order.Back(); elem != nil && elem != cache.order.Front() && cache.bytes > loadedContentBudget; {
+		prev := elem.Prev()
+		name := elem.Value.(*contentCacheEntry).name
+		file, ok := globalWorkspace.files[name]
+		switch {
+		case !ok || !file.Loaded:
+			cache.remove(name)
+		case file.Modified || file.IsNew || name == globalWorkspace.activeFile:
+		default:
+			unloadFileContentLocked(name, file)
+		}
+		elem = prev
+	}
+}
+
+// workspaceFileContentLocked returns the content of a file as held in the workspace,
+// reading unloaded files from disk without caching them. A read lock is enough.
+func workspaceFileContentLocked(name string, file *WorkspaceFile) (string, error) {
+	if file.Loaded || file.IsDir {
+		return file.Content, nil
+	}
+	if file.TooLarge {
+		return "", fmt.Errorf("file too large to load: %s", name)
+	}
+	fullPath := filepath.Join(globalWorkspace.workDir, filepath.FromSlash(name))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	content, _, _, err := readWorkspaceDiskFile(name, fullPath, info.Size())
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, nil
+}
+
+// newWorkspaceFileFromDisk builds an unloaded workspace entry from file metadata
+func newWorkspaceFileFromDisk(displayName string, fullPath string, info os.FileInfo) *WorkspaceFile {
+	if info.IsDir() {
+		return &WorkspaceFile{Name: displayName, Size: info.Size(), IsDir: true}
+	}
+	tooLarge := isFileTooLarge(info.Size())
+	return &WorkspaceFile{
+		Name:        displayName,
+		Size:        info.Size(),
+		TooLarge:    tooLarge,
+		IsBinary:    !tooLarge && sniffBinaryFile(displayName, fullPath),
+		DiskModTime: info.ModTime(),
+	}
+}
+
+// sniffBinaryFile decides whether a file is binary from its extension and first bytes
+func sniffBinaryFile(displayName string, fullPath string) bool {
+	if isBinaryPreviewFile(displayName) {
+		return true
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, binarySniffBytes)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if n == binarySniffBytes {
+		// Drop a rune cut off at the end of the sample
+		for i := 0; i < utf8.UTFMax-1 && len(head) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(head); r != utf8.RuneError || size != 1 {
+				break
+			}
+			head = head[:len(head)-1]
+		}
+	}
+	return shouldTreatAsBinary(displayName, head)
+}
+
+// workspaceFileBytes converts workspace content to the bytes stored on disk
+func workspaceFileBytes(name string, isBinary bool, content string) ([]byte, error) {
+	if isBinary {
+		decoded, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode file %s: %w", name, err)
+		}
+		return decoded, nil
+	}
+	if wrapsGoCode(name) {
+		return []byte(preCode + "\n" + content + "\n" + endCode), nil
+	}
+	return []byte(content), nil
+}
+
+// copyWorkspaceFilesLocked writes every workspace file to targetDir, copying the
+// files that are not loaded from sourceDir
+func copyWorkspaceFilesLocked(sourceDir string, targetDir string) error {
+	for filename, file := range globalWorkspace.files {
+		targetPath := filepath.Join(targetDir, filepath.FromSlash(filename))
+		if file.IsDir {
+			if err := os.MkdirAll(targetPath, 0755); err != nil {
+				return fmt.Errorf("failed to create folder %s: %w", filename, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+		if !file.Loaded {
+			sourcePath := filepath.Join(sourceDir, filepath.FromSlash(filename))
+			if err := copyFile(sourcePath, targetPath); err != nil {
+				return fmt.Errorf("failed to copy file %s: %w", filename, err)
+			}
+			continue
+		}
+		data, err := workspaceFileBytes(filename, file.IsBinary, file.Content)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(targetPath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", filename, err)
+		}
+		file.Modified = false
+		file.IsNew = false
+		file.SavedContent = file.Content
+	}
+	return nil
+}
+
+// recordDiskStateLocked remembers the size and modification time of a file just
+// written, so refreshes do not mistake the write for an external change
+func recordDiskStateLocked(file *WorkspaceFile, fullPath string) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return
+	}
+	file.DiskModTime = info.ModTime()
+	if !file.Modified {
+		file.Size = info.Size()
+	}
+}
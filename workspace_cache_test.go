@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+)
+
+// useUnloadedTestWorkspace is useTestWorkspace with no file contents cached and
+// a cache budget of budget bytes
+func useUnloadedTestWorkspace(t *testing.T, budget int64, files map[string]string) *App {
+	t.Helper()
+	app, _ := useTestWorkspace(t, files)
+	for name, file := range globalWorkspace.files {
+		unloadFileContentLocked(name, file)
+	}
+	previous := loadedContentBudget
+	loadedContentBudget = budget
+	t.Cleanup(func() { loadedContentBudget = previous })
+	return app
+}
+
+func loadTestFiles(t *testing.T, app *App, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if _, err := app.GetFileContent(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	app := useUnloadedTestWorkspace(t, 10, map[string]string{"a.txt": "aaaa", "b.txt": "bbbb", "c.txt": "cccc"})
+	// a.txt is used again after b.txt, so b.txt is the least recently used
+	loadTestFiles(t, app, "a.txt", "b.txt", "a.txt", "c.txt")
+
+	for name, want := range map[string]bool{"a.txt": true, "b.txt": false, "c.txt": true} {
+		file := globalWorkspace.files[name]
+		if file.Loaded != want {
+			t.Errorf("%s loaded = %v, want %v", name, file.Loaded, want)
+		}
+		if !want && (file.Content != "" || file.SavedContent != "") {
+			t.Errorf("evicted %s still holds its content", name)
+		}
+	}
+	if globalWorkspace.cache.bytes != 8 {
+		t.Errorf("cache holds %d bytes, want 8", globalWorkspace.cache.bytes)
+	}
+	// An evicted file is read from disk again
+	if content, err := app.GetFileContent("b.txt"); err != nil || content != "bbbb" {
+		t.Fatalf("GetFileContent(b.txt) = %q, %v", content, err)
+	}
+}
+
+func TestCacheNeverEvictsModifiedOrActiveFiles(t *testing.T) {
+	app := useUnloadedTestWorkspace(t, 10, map[string]string{
+		"edited.txt": "eeee", "active.txt": "aaaa", "b.txt": "bbbb", "c.txt": "cccc",
+	})
+	if err := app.UpdateFileContent("edited.txt", "mine"); err != nil {
+		t.Fatal(err)
+	}
+	globalWorkspace.activeFile = "active.txt"
+	loadTestFiles(t, app, "active.txt", "b.txt")
+	// Only contents that cannot be evicted remain, yet the file loaded last is returned whole
+	if content, err := app.GetFileContent("c.txt"); err != nil || content != "cccc" {
+		t.Fatalf("GetFileContent(c.txt) = %q, %v", content, err)
+	}
+
+	edited := globalWorkspace.files["edited.txt"]
+	if !edited.Loaded || edited.Content != "mine" || edited.SavedContent != "eeee" {
+		t.Fatalf("modified file evicted: %+v", edited)
+	}
+	if !globalWorkspace.files["active.txt"].Loaded {
+		t.Error("active file evicted")
+	}
+	if globalWorkspace.files["b.txt"].Loaded {
+		t.Error("b.txt kept although it is the least recently used unmodified file")
+	}
+	if !globalWorkspace.files["c.txt"].Loaded {
+		t.Error("the file just loaded was evicted")
+	}
+	// The budget may be exceeded by contents that cannot be evicted
+	if globalWorkspace.cache.bytes <= loadedContentBudget {
+		t.Errorf("cache holds %d bytes, expected more than the budget", globalWorkspace.cache.bytes)
+	}
+}
+
+func TestSnapshotWorkspaceBlanksContent(t *testing.T) {
+	useTestWorkspace(t, map[string]string{"b.txt": "bbbb", "a.txt": "aaaa"})
+	globalWorkspace.files["a.txt"].Content = "edited"
+	globalWorkspace.files["a.txt"].Modified = true
+
+	files := snapshotWorkspaceLocked()
+	if len(files) != 2 || files[0].Name != "a.txt" || files[1].Name != "b.txt" {
+		t.Fatalf("snapshot = %+v, want a.txt and b.txt sorted", files)
+	}
+	for _, file := range files {
+		if file.Content != "" || file.SavedContent != "" {
+			t.Errorf("snapshot of %s holds content", file.Name)
+		}
+		if !file.Loaded || file.Size != 4 {
+			t.Errorf("snapshot of %s lost its metadata: %+v", file.Name, file)
+		}
+	}
+	if !files[0].Modified {
+		t.Error("snapshot lost the modified flag")
+	}
+	if globalWorkspace.files["a.txt"].Content != "edited" || globalWorkspace.files["b.txt"].Content != "bbbb" {
+		t.Error("snapshot blanked the workspace's own contents")
+	}
+}
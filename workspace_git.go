@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+)
+
+// gitStatusTTL is how long a git status is reused by GetWorkspaceFiles, which the
+// frontend calls after most actions
+const gitStatusTTL = 2 * time.Second
+
+// gitStatusCache holds the last git status of the workspace directory
+var gitStatusCache struct {
+	mu       sync.Mutex
+	dir      string
+	fetched  time.Time
+	statuses map[string]mcp.GitFileStatus
+}
+
+// workspaceGitRepo returns the git CLI wrapper for the current workspace directory
+func workspaceGitRepo() (mcp.GitRepo, error) {
+	if globalWorkspace == nil {
+		return mcp.GitRepo{}, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	if globalWorkspace.isTemp {
+		return mcp.GitRepo{}, fmt.Errorf("temporary workspace: please open or create a workspace first")
+	}
+	return mcp.GitRepo{Dir: globalWorkspace.workDir}, nil
+}
+
+// cachedGitStatuses returns the git status of each changed file by workspace path.
+// Workspaces outside a repository have no statuses.
+func cachedGitStatuses(repo mcp.GitRepo) map[string]mcp.GitFileStatus {
+	gitStatusCache.mu.Lock()
+	defer gitStatusCache.mu.Unlock()
+	if gitStatusCache.dir == repo.Dir && time.Since(gitStatusCache.fetched) < gitStatusTTL {
+		return gitStatusCache.statuses
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	statuses := make(map[string]mcp.GitFileStatus)
+	if list, err := repo.Status(ctx); err == nil {
+		for _, st := range list {
+			statuses[st.Path] = st
+		}
+	}
+	gitStatusCache.dir = repo.Dir
+	gitStatusCache.fetched = time.Now()
+	gitStatusCache.statuses = statuses
+	return statuses
+}
+
+// invalidateGitStatus makes the next status lookup ask git again
+func invalidateGitStatus() {
+	gitStatusCache.mu.Lock()
+	gitStatusCache.fetched = time.Time{}
+	gitStatusCache.mu.Unlock()
+}
+
+// annotateGitStatus fills in the git status of workspace file entries
+func annotateGitStatus(files []WorkspaceFile) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return
+	}
+	statuses := cachedGitStatuses(repo)
+	if len(statuses) == 0 {
+		return
+	}
+	for i := range files {
+		if st, ok := statuses[files[i].Name]; ok {
+			files[i].GitStatus = st.Status
+			files[i].GitStaged = st.Staged
+		}
+	}
+}
+
+// GetGitStatus lists the changed files of the workspace repository
+func (a *App) GetGitStatus() ([]mcp.GitFileStatus, error) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return nil, err
+	}
+	invalidateGitStatus()
+	return repo.Status(context.Background())
+}
+
+// GetGitDiff returns the saved changes of a file, or of every changed file when name
+// is empty, against HEAD
+func (a *App) GetGitDiff(name string) (string, error) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		if name, err = cleanRelativePath(name); err != nil {
+			return "", err
+		}
+	}
+	return repo.Diff(context.Background(), name)
+}
+
+// GitStage saves unsaved edits below paths and stages them
+func (a *App) GitStage(paths []string) error {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return err
+	}
+	cleaned, err := cleanGitPaths(paths)
+	if err != nil {
+		return err
+	}
+	if err := saveModifiedBelow(a, cleaned); err != nil {
+		return err
+	}
+	defer invalidateGitStatus()
+	return repo.Stage(context.Background(), cleaned)
+}
+
+// GitUnstage removes the staged changes of paths from the next commit
+func (a *App) GitUnstage(paths []string) error {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return err
+	}
+	cleaned, err := cleanGitPaths(paths)
+	if err != nil {
+		return err
+	}
+	defer invalidateGitStatus()
+	return repo.Unstage(context.Background(), cleaned)
+}
+
+// GitCommit commits the staged changes
+func (a *App) GitCommit(message string) (*mcp.GitCommit, error) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return nil, err
+	}
+	defer invalidateGitStatus()
+	return repo.Commit(context.Background(), message)
+}
+
+// GetGitLog returns recent commits touching name, or of the whole repository when
+// name is empty
+func (a *App) GetGitLog(name string, limit int) ([]mcp.GitCommit, error) {
+	repo, err := workspaceGitRepo()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if name, err = cleanRelativePath(name); err != nil {
+			return nil, err
+		}
+	}
+	return repo.Log(context.Background(), name, limit)
+}
+
+func cleanGitPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths given")
+	}
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		clean, err := cleanRelativePath(p)
+		if err != nil {
+			return nil, err
+		}
+		cleaned = append(cleaned, clean)
+	}
+	return cleaned, nil
+}
+
+// saveModifiedBelow saves the unsaved edits of files at or below paths, so what is
+// staged matches what the editor shows
+func saveModifiedBelow(a *App, paths []string) error {
+	globalWorkspace.mu.RLock()
+	var modified []string
+	for name, file := range globalWorkspace.files {
+		if !file.Modified || file.IsDir {
+			continue
+		}
+		for _, p := range paths {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				modified = append(modified, name)
+				break
+			}
+		}
+	}
+	globalWorkspace.mu.RUnlock()
+
+	for _, name := range modified {
+		if err := a.SaveFile(name); err != nil {
+			return fmt.Errorf("failed to save %s before staging: %w", name, err)
+		}
+	}
+	return nil
+}
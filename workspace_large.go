@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// maxLargeFileWindowBytes caps the bytes returned by one range or line window read
+	maxLargeFileWindowBytes = 4 * 1024 * 1024
+	// maxLargeFileWindowLines caps the lines returned by one line window read
+	maxLargeFileWindowLines = 10000
+)
+
+// LargeFileInfo describes a file read through the chunked viewer
+type LargeFileInfo struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Lines int64  `json:"lines"`
+}
+
+// LargeFileWindow is a slice of a large file. Lines are 1-based and inclusive;
+// offsets are byte offsets with End exclusive.
+type LargeFileWindow struct {
+	Name        string `json:"name"`
+	Content     string `json:"content"`
+	StartLine   int64  `json:"startLine"`
+	EndLine     int64  `json:"endLine"`
+	StartOffset int64  `json:"startOffset"`
+	EndOffset   int64  `json:"endOffset"`
+	TotalLines  int64  `json:"totalLines"`
+	Size        int64  `json:"size"`
+	Truncated   bool   `json:"truncated"`
+}
+
+// lineIndex records how many lines start before each read chunk, so a line is found
+// by reading a single chunk instead of scanning the whole file
+type lineIndex struct {
+	size       int64
+	modTime    time.Time
+	chunkLines []int64 // newlines before chunk i; the last entry is the total
+	lines      int64
+}
+
+var (
+	lineIndexMu sync.Mutex
+	lineIndexes = make(map[string]*lineIndex)
+)
+
+// largeFilePath resolves a workspace file that the chunked viewer may read
+func largeFilePath(filename string) (string, string, error) {
+	if globalWorkspace == nil {
+		return "", "", fmt.Errorf("workspace not initialized")
+	}
+	cleanName, err := cleanRelativePath(filename)
+	if err != nil {
+		return "", "", err
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	file, exists := globalWorkspace.files[cleanName]
+	if !exists {
+		return "", "", fmt.Errorf("file not found: %s", cleanName)
+	}
+	if file.IsDir {
+		return "", "", fmt.Errorf("path is a directory: %s", cleanName)
+	}
+	return cleanName, filepath.Join(globalWorkspace.workDir, filepath.FromSlash(cleanName)), nil
+}
+
+// loadLineIndex returns the line index of a file, rebuilding it when the file changed
+func (a *App) loadLineIndex(name string, fullPath string) (*lineIndex, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	lineIndexMu.Lock()
+	idx, ok := lineIndexes[fullPath]
+	lineIndexMu.Unlock()
+	if ok && idx.describes(info) {
+		return idx, nil
+	}
+
+	idx, err = buildLineIndex(fullPath, info, func(readBytes, totalBytes int64) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "workspace:large-file-progress", map[string]any{
+				"fileName":   name,
+				"bytesRead":  readBytes,
+				"totalBytes": totalBytes,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	lineIndexMu.Lock()
+	lineIndexes[fullPath] = idx
+	lineIndexMu.Unlock()
+	return idx, nil
+}
+
+// describes reports whether the index was built from the file as described by info
+func (idx *lineIndex) describes(info os.FileInfo) bool {
+	return idx.size == info.Size() && idx.modTime.Equal(info.ModTime())
+}
+
+// buildLineIndex counts the newlines of every chunk concurrently
+func buildLineIndex(fullPath string, info os.FileInfo, onProgress func(readBytes, totalBytes int64)) (*lineIndex, error) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	size := info.Size()
+	chunkSize := int64(concurrentReadChunkSize)
+	counts := make([]int64, (size+chunkSize-1)/chunkSize)
+	if size > 0 {
+		err = readChunksConcurrently(size, func(index int, start int64, end int64) error {
+			buf := make([]byte, end-start)
+			if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+				return err
+			}
+			counts[index] = int64(bytes.Count(buf, []byte{'\n'}))
+			return nil
+		}, onProgress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to index file: %w", err)
+		}
+	}
+
+	idx := &lineIndex{size: size, modTime: info.ModTime(), chunkLines: make([]int64, len(counts)+1)}
+	for i, n := range counts {
+		idx.chunkLines[i+1] = idx.chunkLines[i] + n
+	}
+	newlines := idx.chunkLines[len(counts)]
+	idx.lines = newlines
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, size-1); err != nil {
+			return nil, fmt.Errorf("failed to index file: %w", err)
+		}
+		if last[0] != '\n' {
+			idx.lines++
+		}
+	}
+	return idx, nil
+}
+
+// lineOffset returns the byte offset at which a 1-based line starts; lines past
+// the end start at the file size
+func (idx *lineIndex) lineOffset(file *os.File, line int64) (int64, error) {
+	if line <= 1 {
+		return 0, nil
+	}
+	newline := line - 1 // the line starts after its predecessor's newline
+	total := idx.chunkLines[len(idx.chunkLines)-1]
+	if newline > total {
+		return idx.size, nil
+	}
+	chunk := sort.Search(len(idx.chunkLines)-1, func(i int) bool {
+		return idx.chunkLines[i+1] >= newline
+	})
+	chunkSize := int64(concurrentReadChunkSize)
+	start := int64(chunk) * chunkSize
+	buf := make([]byte, min(chunkSize, idx.size-start))
+	if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return 0, err
+	}
+	remaining := newline - idx.chunkLines[chunk]
+	for pos := 0; pos < len(buf); {
+		i := bytes.IndexByte(buf[pos:], '\n')
+		if i < 0 {
+			break
+		}
+		pos += i + 1
+		remaining--
+		if remaining == 0 {
+			return start + int64(pos), nil
+		}
+	}
+	return 0, fmt.Errorf("line index out of date")
+}
+
+// GetLargeFileInfo indexes a file and returns its size and line count
+func (a *App) GetLargeFileInfo(filename string) (LargeFileInfo, error) {
+	cleanName, fullPath, err := largeFilePath(filename)
+	if err != nil {
+		return LargeFileInfo{}, err
+	}
+	idx, err := a.loadLineIndex(cleanName, fullPath)
+	if err != nil {
+		return LargeFileInfo{}, err
+	}
+	return LargeFileInfo{Name: cleanName, Size: idx.size, Lines: idx.lines}, nil
+}
+
+// ReadLargeFileLines returns count lines starting at the 1-based startLine. The
+// window is cut at whole lines when it would exceed maxLargeFileWindowBytes.
+func (a *App) ReadLargeFileLines(filename string, startLine int64, count int64) (LargeFileWindow, error) {
+	cleanName, fullPath, err := largeFilePath(filename)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	idx, err := a.loadLineIndex(cleanName, fullPath)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	startLine = max(startLine, 1)
+	count = min(max(count, 1), maxLargeFileWindowLines)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return LargeFileWindow{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	start, err := idx.lineOffset(file, startLine)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	end, err := idx.lineOffset(file, startLine+count)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	truncated := false
+	if end-start > maxLargeFileWindowBytes {
+		end = start + maxLargeFileWindowBytes
+		truncated = true
+	}
+	buf := make([]byte, end-start)
+	if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return LargeFileWindow{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	if truncated {
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+			buf = buf[:i+1]
+		} else {
+			// A single line longer than the window is cut at a rune boundary
+			buf = trimIncompleteRune(buf)
+		}
+		end = start + int64(len(buf))
+	}
+
+	window := LargeFileWindow{
+		Name:        cleanName,
+		Content:     string(buf),
+		StartLine:   startLine,
+		StartOffset: start,
+		EndOffset:   end,
+		TotalLines:  idx.lines,
+		Size:        idx.size,
+		Truncated:   truncated,
+	}
+	window.EndLine = startLine + countLines(buf) - 1
+	return window, nil
+}
+
+// ReadLargeFileRange returns up to length bytes starting at offset, trimmed to
+// whole UTF-8 runes. Line numbers are filled in from the line index.
+func (a *App) ReadLargeFileRange(filename string, offset int64, length int64) (LargeFileWindow, error) {
+	cleanName, fullPath, err := largeFilePath(filename)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	idx, err := a.loadLineIndex(cleanName, fullPath)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	offset = min(max(offset, 0), idx.size)
+	length = min(max(length, 0), maxLargeFileWindowBytes, idx.size-offset)
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return LargeFileWindow{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, length)
+	if _, err := file.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return LargeFileWindow{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	// Skip continuation bytes of a rune that started before the offset
+	skip := 0
+	for skip < len(buf) && skip < utf8.UTFMax-1 && !utf8.RuneStart(buf[skip]) {
+		skip++
+	}
+	buf = trimIncompleteRune(buf[skip:])
+	start := offset + int64(skip)
+
+	startLine, err := idx.lineAt(file, start)
+	if err != nil {
+		return LargeFileWindow{}, err
+	}
+	window := LargeFileWindow{
+		Name:        cleanName,
+		Content:     string(buf),
+		StartLine:   startLine,
+		EndLine:     startLine + max(countLines(buf), 1) - 1,
+		StartOffset: start,
+		EndOffset:   start + int64(len(buf)),
+		TotalLines:  idx.lines,
+		Size:        idx.size,
+		Truncated:   start+int64(len(buf)) < idx.size,
+	}
+	return window, nil
+}
+
+// lineAt returns the 1-based line containing a byte offset
+func (idx *lineIndex) lineAt(file *os.File, offset int64) (int64, error) {
+	chunkSize := int64(concurrentReadChunkSize)
+	chunk := offset / chunkSize
+	if chunk >= int64(len(idx.chunkLines)-1) {
+		return idx.lines, nil
+	}
+	start := chunk * chunkSize
+	buf := make([]byte, offset-start)
+	if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+		return 0, err
+	}
+	return idx.chunkLines[chunk] + int64(bytes.Count(buf, []byte{'\n'})) + 1, nil
+}
+
+// countLines counts the lines in b, including a final line without a newline
+func countLines(b []byte) int64 {
+	n := int64(bytes.Count(b, []byte{'\n'}))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// trimIncompleteRune drops a rune cut off at the end of b
+func trimIncompleteRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax-1 && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// SearchLargeFile searches one file line by line, streaming it from disk
+func (a *App) SearchLargeFile(filename string, opts mcp.SearchOptions) (*mcp.SearchResult, error) {
+	cleanName, _, err := largeFilePath(filename)
+	if err != nil {
+		return nil, err
+	}
+	opts.Include = nil
+	opts.Exclude = nil
+	return mcp.Search(context.Background(), singleFileSearchFS{workspaceSearchFS: workspaceSearchFS{app: a}, path: cleanName}, opts, nil)
+}
+
+// singleFileSearchFS limits a workspace search to one file
+type singleFileSearchFS struct {
+	workspaceSearchFS
+	path string
+}
+
+// ListFiles returns the searched file
+func (s singleFileSearchFS) ListFiles() ([]string, error) {
+	return []string{s.path}, nil
+}
+
+// AppendToLargeFile appends text to the end of a file on disk
+func (a *App) AppendToLargeFile(filename string, text string) error {
+	cleanName, fullPath, err := largeFileForWrite(filename)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append to file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to append to file: %w", err)
+	}
+	a.largeFileWritten(cleanName, fullPath)
+	return nil
+}
+
+// PatchLargeFileLines replaces the 1-based inclusive line range startLine..endLine
+// with text. An endLine before startLine inserts text before startLine. The file is
+// rewritten through a temporary file next to it and renamed into place.
+func (a *App) PatchLargeFileLines(filename string, startLine int64, endLine int64, text string) error {
+	cleanName, fullPath, err := largeFileForWrite(filename)
+	if err != nil {
+		return err
+	}
+	idx, err := a.loadLineIndex(cleanName, fullPath)
+	if err != nil {
+		return err
+	}
+	startLine = max(startLine, 1)
+	if startLine > idx.lines+1 {
+		return fmt.Errorf("line %d is past the end of the file (%d lines)", startLine, idx.lines)
+	}
+	endLine = min(max(endLine, startLine-1), idx.lines)
+
+	src, err := os.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	// Offsets from an index of an earlier version would cut the file in the wrong places
+	if !idx.describes(info) {
+		return fmt.Errorf("file changed on disk while patching: %s", cleanName)
+	}
+	start, err := idx.lineOffset(src, startLine)
+	if err != nil {
+		return err
+	}
+	end, err := idx.lineOffset(src, endLine+1)
+	if err != nil {
+		return err
+	}
+	// Keep the line break between the patch and the lines after it
+	if text != "" && end < idx.size && text[len(text)-1] != '\n' {
+		text += "\n"
+	}
+	// A patch at the end of a file without a trailing newline starts a new line
+	if start == idx.size && start > 0 && text != "" {
+		last := make([]byte, 1)
+		if _, err := src.ReadAt(last, start-1); err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		if last[0] != '\n' {
+			text = "\n" + text
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".idensyra-patch-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	err = func() error {
+		if _, err := io.Copy(tmp, io.NewSectionReader(src, 0, start)); err != nil {
+			return err
+		}
+		if _, err := tmp.WriteString(text); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tmp, io.NewSectionReader(src, end, idx.size-end)); err != nil {
+			return err
+		}
+		return tmp.Close()
+	}()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	src.Close()
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	a.largeFileWritten(cleanName, fullPath)
+	return nil
+}
+
+// largeFileForWrite resolves a file that may be changed on disk directly; files with
+// unsaved edits would silently lose them, so they are refused
+func largeFileForWrite(filename string) (string, string, error) {
+	cleanName, fullPath, err := largeFilePath(filename)
+	if err != nil {
+		return "", "", err
+	}
+	globalWorkspace.mu.RLock()
+	file := globalWorkspace.files[cleanName]
+	unsaved := file != nil && (file.Modified || file.IsNew)
+	globalWorkspace.mu.RUnlock()
+	if unsaved {
+		return "", "", fmt.Errorf("file has unsaved changes: %s", cleanName)
+	}
+	return cleanName, fullPath, nil
+}
+
+// largeFileWritten refreshes the metadata of a file changed on disk by the chunked editor
+func (a *App) largeFileWritten(name string, fullPath string) {
+	info, err := os.Stat(fullPath)
+	if err == nil {
+		globalWorkspace.mu.Lock()
+		if file, ok := globalWorkspace.files[name]; ok && !file.Modified && !file.IsNew {
+			unloadFileContentLocked(name, file)
+			updated := newWorkspaceFileFromDisk(name, fullPath, info)
+			file.Size = updated.Size
+			file.TooLarge = updated.TooLarge
+			file.IsBinary = updated.IsBinary
+			file.DiskModTime = updated.DiskModTime
+		}
+		globalWorkspace.mu.Unlock()
+	}
+	a.notifyMCPFilesChanged(name)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildLineIndex(t *testing.T) {
+	long := strings.Repeat("0123456789abcde\n", 300000)
+	for _, tc := range []struct {
+		name    string
+		content string
+		lines   int64
+	}{
+		{"empty", "", 0},
+		{"single newline", "\n", 1},
+		{"trailing newline", "one\ntwo\n", 2},
+		{"no trailing newline", "one\ntwo", 2},
+		{"several chunks", long, 300000},
+		{"several chunks without trailing newline", long + "end", 300001},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fullPath := filepath.Join(t.TempDir(), "data.txt")
+			if err := os.WriteFile(fullPath, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(fullPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx, err := buildLineIndex(fullPath, info, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.lines != tc.lines || idx.size != int64(len(tc.content)) {
+				t.Fatalf("index has %d lines and %d bytes, want %d and %d", idx.lines, idx.size, tc.lines, len(tc.content))
+			}
+			if !idx.describes(info) {
+				t.Fatal("index does not describe the file it was built from")
+			}
+			if err := os.WriteFile(fullPath, []byte(tc.content+"more\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			changed, err := os.Stat(fullPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.describes(changed) {
+				t.Fatal("index still describes the file after it grew")
+			}
+		})
+	}
+}
+
+func TestReadLargeFileLines(t *testing.T) {
+	long := strings.Repeat("0123456789abcde\n", 300000)
+	app, _ := useTestWorkspace(t, map[string]string{
+		"lines.txt":   "one\ntwo\nthree\n",
+		"partial.txt": "one\ntwo\nthree",
+		"long.txt":    long,
+	})
+	for _, tc := range []struct {
+		file               string
+		start, count       int64
+		content            string
+		startLine, endLine int64
+		startOffset        int64
+	}{
+		{"lines.txt", 2, 1, "two\n", 2, 2, 4},
+		{"lines.txt", 0, 2, "one\ntwo\n", 1, 2, 0},
+		{"lines.txt", 3, 10, "three\n", 3, 3, 8},
+		{"lines.txt", 5, 2, "", 5, 4, 14},
+		{"partial.txt", 3, 1, "three", 3, 3, 8},
+		{"partial.txt", 2, 5, "two\nthree", 2, 3, 4},
+		{"long.txt", 200001, 2, "0123456789abcde\n0123456789abcde\n", 200001, 200002, 200000 * 16},
+	} {
+		window, err := app.ReadLargeFileLines(tc.file, tc.start, tc.count)
+		if err != nil {
+			t.Fatalf("ReadLargeFileLines(%s, %d, %d): %v", tc.file, tc.start, tc.count, err)
+		}
+		if window.Content != tc.content || window.StartLine != tc.startLine || window.EndLine != tc.endLine || window.StartOffset != tc.startOffset {
+			t.Errorf("ReadLargeFileLines(%s, %d, %d) = %q lines %d-%d at %d, want %q lines %d-%d at %d",
+				tc.file, tc.start, tc.count, window.Content, window.StartLine, window.EndLine, window.StartOffset,
+				tc.content, tc.startLine, tc.endLine, tc.startOffset)
+		}
+		if window.EndOffset != window.StartOffset+int64(len(window.Content)) {
+			t.Errorf("ReadLargeFileLines(%s, %d, %d) ends at %d", tc.file, tc.start, tc.count, window.EndOffset)
+		}
+	}
+}
+
+func TestPatchLargeFileLines(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		content    string
+		start, end int64
+		text       string
+		want       string
+	}{
+		{"replace", "one\ntwo\nthree\n", 2, 2, "TWO", "one\nTWO\nthree\n"},
+		{"replace with several lines", "one\ntwo\nthree\n", 2, 2, "a\nb\n", "one\na\nb\nthree\n"},
+		{"insert", "one\ntwo\nthree\n", 2, 1, "inserted", "one\ninserted\ntwo\nthree\n"},
+		{"delete", "one\ntwo\nthree\n", 2, 3, "", "one\n"},
+		{"append", "one\ntwo\nthree\n", 4, 3, "four\n", "one\ntwo\nthree\nfour\n"},
+		{"end past the last line", "one\ntwo\nthree\n", 3, 10, "THREE\n", "one\ntwo\nTHREE\n"},
+		{"append without trailing newline", "one\ntwo", 3, 2, "three", "one\ntwo\nthree"},
+		{"replace last line without trailing newline", "one\ntwo", 2, 2, "TWO", "one\nTWO"},
+		{"empty file", "", 1, 0, "first\n", "first\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app, dir := useTestWorkspace(t, map[string]string{"data.txt": tc.content})
+			if err := app.PatchLargeFileLines("data.txt", tc.start, tc.end, tc.text); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "data.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.want {
+				t.Fatalf("patched file = %q, want %q", data, tc.want)
+			}
+			file := globalWorkspace.files["data.txt"]
+			if file.Loaded || file.Size != int64(len(tc.want)) {
+				t.Fatalf("workspace entry not refreshed: loaded %v, size %d", file.Loaded, file.Size)
+			}
+		})
+	}
+}
+
+func TestPatchLargeFileLinesRefuses(t *testing.T) {
+	app, dir := useTestWorkspace(t, map[string]string{"data.txt": "one\ntwo\n", "edited.txt": "one\n"})
+	if err := app.PatchLargeFileLines("data.txt", 4, 4, "x"); err == nil || !strings.Contains(err.Error(), "past the end") {
+		t.Fatalf("patch past the end: err = %v", err)
+	}
+	if err := app.UpdateFileContent("edited.txt", "mine"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.PatchLargeFileLines("edited.txt", 1, 1, "x"); err == nil || !strings.Contains(err.Error(), "unsaved") {
+		t.Fatalf("patch of a file with unsaved edits: err = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "data.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\ntwo\n" {
+		t.Fatalf("refused patch changed the file: %q", data)
+	}
+}
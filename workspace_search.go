@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// searchBatchInterval is how often streamed search matches are flushed to the frontend
+const searchBatchInterval = 100 * time.Millisecond
+
+// workspaceSearchFS searches files as the user sees them: unsaved edits are searched
+// in memory and files too large to load are read from disk
+type workspaceSearchFS struct {
+	app *App
+}
+
+// ListFiles returns every file of the workspace
+func (w workspaceSearchFS) ListFiles() ([]string, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	defer globalWorkspace.mu.RUnlock()
+	files := make([]string, 0, len(globalWorkspace.files))
+	for name, file := range globalWorkspace.files {
+		if !file.IsDir {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Open returns the in-memory content of loaded text files and the disk content otherwise
+func (w workspaceSearchFS) Open(path string) (io.ReadCloser, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	file, exists := globalWorkspace.files[path]
+	var content string
+	var onDisk bool
+	var err error
+	if exists {
+		content = file.Content
+		onDisk = file.TooLarge || file.IsBinary
+		if !onDisk && !file.Loaded {
+			content, err = workspaceFileContentLocked(path, file)
+		}
+	}
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+
+	switch {
+	case !exists:
+		return nil, fmt.Errorf("file not found: %s: %w", path, os.ErrNotExist)
+	case err != nil:
+		return nil, err
+	case onDisk:
+		return os.Open(filepath.Join(workDir, filepath.FromSlash(path)))
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// WriteFile stores a replacement as an unsaved edit, or on disk for files too large to edit
+func (w workspaceSearchFS) WriteFile(path string, data []byte) error {
+	if globalWorkspace == nil {
+		return fmt.Errorf("workspace not initialized")
+	}
+	globalWorkspace.mu.RLock()
+	file, exists := globalWorkspace.files[path]
+	tooLarge := exists && file.TooLarge
+	workDir := globalWorkspace.workDir
+	globalWorkspace.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("file not found: %s", path)
+	}
+	if !tooLarge {
+		return w.app.UpdateFileContent(path, string(data))
+	}
+	fullPath := filepath.Join(workDir, filepath.FromSlash(path))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fullPath, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	globalWorkspace.mu.Lock()
+	if file, ok := globalWorkspace.files[path]; ok {
+		file.Size = int64(len(data))
+	}
+	globalWorkspace.mu.Unlock()
+	return nil
+}
+
+// WorkspaceSearchDone is emitted as "workspace:search-done" when a search finishes
+type WorkspaceSearchDone struct {
+	ID            string `json:"id"`
+	Matches       int    `json:"matches"`
+	FilesSearched int    `json:"filesSearched"`
+	FilesMatched  int    `json:"filesMatched"`
+	Truncated     bool   `json:"truncated"`
+	Cancelled     bool   `json:"cancelled"`
+	Error         string `json:"error,omitempty"`
+}
+
+var (
+	workspaceSearchMu      sync.Mutex
+	workspaceSearchCancels = make(map[string]context.CancelFunc)
+)
+
+// SearchWorkspace starts a search over all workspace files and returns its ID.
+// Matches are streamed in batches as "workspace:search-matches" events with
+// {id, matches}, followed by one "workspace:search-done" event.
+func (a *App) SearchWorkspace(opts mcp.SearchOptions) (string, error) {
+	if globalWorkspace == nil {
+		return "", fmt.Errorf("workspace not initialized")
+	}
+	if strings.TrimSpace(opts.Query) == "" {
+		return "", fmt.Errorf("search query cannot be empty")
+	}
+	id := mcp.NewRequestID()
+	ctx, cancel := context.WithCancel(context.Background())
+	workspaceSearchMu.Lock()
+	workspaceSearchCancels[id] = cancel
+	workspaceSearchMu.Unlock()
+
+	go func() {
+		defer func() {
+			workspaceSearchMu.Lock()
+			delete(workspaceSearchCancels, id)
+			workspaceSearchMu.Unlock()
+			cancel()
+		}()
+
+		var batch []mcp.SearchMatch
+		lastFlush := time.Now()
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if a.ctx != nil {
+				runtime.EventsEmit(a.ctx, "workspace:search-matches", map[string]any{"id": id, "matches": batch})
+			}
+			batch = nil
+			lastFlush = time.Now()
+		}
+		result, err := mcp.Search(ctx, workspaceSearchFS{app: a}, opts, func(m mcp.SearchMatch) {
+			batch = append(batch, m)
+			if time.Since(lastFlush) >= searchBatchInterval {
+				flush()
+			}
+		})
+		flush()
+
+		done := WorkspaceSearchDone{ID: id, Cancelled: ctx.Err() != nil}
+		if result != nil {
+			done.Matches = len(result.Matches)
+			done.FilesSearched = result.FilesSearched
+			done.FilesMatched = result.FilesMatched
+			done.Truncated = result.Truncated
+		}
+		if err != nil && !done.Cancelled {
+			done.Error = err.Error()
+		}
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, "workspace:search-done", done)
+		}
+	}()
+	return id, nil
+}
+
+// CancelWorkspaceSearch stops a running search
+func (a *App) CancelWorkspaceSearch(id string) {
+	workspaceSearchMu.Lock()
+	cancel := workspaceSearchCancels[id]
+	workspaceSearchMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// ReplaceInWorkspace replaces matches across workspace files. Loaded files become
+// unsaved edits; with dryRun only the counts are returned.
+func (a *App) ReplaceInWorkspace(opts mcp.SearchOptions, replacement string, dryRun bool) (*mcp.ReplaceResult, error) {
+	if globalWorkspace == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	result, err := mcp.Replace(context.Background(), workspaceSearchFS{app: a}, opts, replacement, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if paths := result.ReplacedPaths(); !dryRun && len(paths) > 0 && a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "workspace:files-replaced", paths)
+	}
+	return result, nil
+}
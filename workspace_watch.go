@@ -0,0 +1,734 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HazelnutParadise/idensyra/mcp"
+	"github.com/fsnotify/fsnotify"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// workspaceWatchInterval is how often the workspace directory is scanned when
+	// the platform watcher is unavailable
+	workspaceWatchInterval = 500 * time.Millisecond
+	// workspaceWatchTick is how often pending changes are checked
+	workspaceWatchTick = 100 * time.Millisecond
+	// workspaceWatchDebounce is how long the directory must stay unchanged before
+	// pending changes are applied, so a script writing many files settles first
+	workspaceWatchDebounce = 400 * time.Millisecond
+	// workspaceWatchMaxWait is the longest changes stay pending while something
+	// keeps writing, so the file map still catches up
+	workspaceWatchMaxWait = 2 * time.Second
+)
+
+// Conflict resolutions accepted by ResolveWorkspaceConflict
+const (
+	conflictReload = "reload"
+	conflictKeep   = "keep"
+)
+
+// WorkspaceChange is emitted as "workspace:files-changed" after external changes were applied
+type WorkspaceChange struct {
+	Created  []string `json:"created"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+// WorkspaceConflict is a file with unsaved edits that changed or disappeared on disk.
+// It is emitted as "workspace:conflict" and kept until resolved.
+type WorkspaceConflict struct {
+	Path     string    `json:"path"`
+	Deleted  bool      `json:"deleted"`
+	Detected time.Time `json:"detected"`
+}
+
+type workspaceDiskEntry struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// workspaceWatcher applies external changes of the workspace directory to the
+// file map. It follows the platform watcher's events and falls back to scanning
+// the directory when no watcher can be set up.
+type workspaceWatcher struct {
+	app  *App
+	stop chan struct{}
+	done chan struct{}
+
+	// notify delivers change events; nil while the directory is scanned instead.
+	// It, snapshot and lastScan are only used by the run goroutine.
+	notify   *fsnotify.Watcher
+	snapshot map[string]workspaceDiskEntry
+	lastScan time.Time
+
+	mu          sync.Mutex
+	workDir     string
+	pending     map[string]struct{}
+	firstChange time.Time
+	lastChange  time.Time
+	conflicts   map[string]*WorkspaceConflict
+	// diskContent holds the disk version of conflicting files
+	diskContent map[string]string
+}
+
+func newWorkspaceWatcher(app *App) *workspaceWatcher {
+	return &workspaceWatcher{
+		app:         app,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		pending:     make(map[string]struct{}),
+		conflicts:   make(map[string]*WorkspaceConflict),
+		diskContent: make(map[string]string),
+	}
+}
+
+// startWorkspaceWatcher starts watching the current workspace directory
+func (a *App) startWorkspaceWatcher() {
+	if a.watcher != nil {
+		return
+	}
+	a.watcher = newWorkspaceWatcher(a)
+	go a.watcher.run()
+}
+
+// stopWorkspaceWatcher stops watching and waits for the watcher to exit
+func (a *App) stopWorkspaceWatcher() {
+	if a.watcher == nil {
+		return
+	}
+	close(a.watcher.stop)
+	<-a.watcher.done
+	a.watcher = nil
+}
+
+func (w *workspaceWatcher) run() {
+	defer close(w.done)
+	if notify, err := fsnotify.NewWatcher(); err == nil {
+		w.notify = notify
+	}
+	defer func() {
+		if w.notify != nil {
+			w.notify.Close()
+		}
+	}()
+	ticker := time.NewTicker(workspaceWatchTick)
+	defer ticker.Stop()
+	for {
+		var events chan fsnotify.Event
+		var errs chan error
+		if w.notify != nil {
+			events, errs = w.notify.Events, w.notify.Errors
+		}
+		select {
+		case <-w.stop:
+			return
+		case event, ok := <-events:
+			if ok {
+				w.handleEvent(event)
+			}
+		case err, ok := <-errs:
+			if ok && errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Events were lost, so compare everything once
+				w.queueAll()
+			}
+		case <-ticker.C:
+			w.tick(time.Now())
+		}
+	}
+}
+
+// tick follows a switch of workspace, scans when polling and applies pending
+// changes once the directory is quiet or they waited workspaceWatchMaxWait
+func (w *workspaceWatcher) tick(now time.Time) {
+	if globalWorkspace == nil {
+		return
+	}
+	globalWorkspace.mu.RLock()
+	workDir := globalWorkspace.workDir
+	initialized := globalWorkspace.initialized
+	globalWorkspace.mu.RUnlock()
+	if !initialized || workDir == "" {
+		return
+	}
+
+	w.mu.Lock()
+	switched := workDir != w.workDir
+	if switched {
+		// A newly opened workspace starts from its current state
+		w.workDir = workDir
+		w.pending = make(map[string]struct{})
+		w.conflicts = make(map[string]*WorkspaceConflict)
+		w.diskContent = make(map[string]string)
+	}
+	w.mu.Unlock()
+	if switched {
+		w.watchWorkspace(workDir)
+		return
+	}
+	if w.notify == nil && now.Sub(w.lastScan) >= workspaceWatchInterval {
+		w.poll(workDir, now)
+	}
+
+	paths := w.takePending(now)
+	if len(paths) == 0 {
+		return
+	}
+	disk, paths := statWorkspaceChanges(workDir, paths)
+	w.apply(workDir, disk, paths)
+}
+
+// watchWorkspace watches every visible directory of workDir, or takes a first
+// scan to poll against when that is not possible
+func (w *workspaceWatcher) watchWorkspace(workDir string) {
+	if w.notify != nil {
+		for _, dir := range w.notify.WatchList() {
+			_ = w.notify.Remove(dir)
+		}
+		if err := w.watchTree(workDir, workDir); err == nil {
+			return
+		}
+		// Usually the limit of watches was reached
+		w.notify.Close()
+		w.notify = nil
+	}
+	w.snapshot = scanWorkspaceDisk(workDir)
+	w.lastScan = time.Now()
+}
+
+// watchTree adds a watch for dir and every visible directory below it
+func (w *workspaceWatcher) watchTree(workDir, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != workDir {
+			relPath, err := filepath.Rel(workDir, path)
+			if err != nil || isHiddenPath(relPath, true) {
+				return filepath.SkipDir
+			}
+		}
+		return w.notify.Add(path)
+	})
+}
+
+// handleEvent queues the path of a change event. New directories are watched
+// too; their contents are picked up when the change is applied.
+func (w *workspaceWatcher) handleEvent(event fsnotify.Event) {
+	w.mu.Lock()
+	workDir := w.workDir
+	w.mu.Unlock()
+	if workDir == "" {
+		return
+	}
+	relPath, err := filepath.Rel(workDir, event.Name)
+	if err != nil || relPath == "." || strings.HasPrefix(relPath, "..") {
+		return
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// Watches of a moved directory would report its old path
+		prefix := event.Name + string(os.PathSeparator)
+		for _, dir := range w.notify.WatchList() {
+			if dir == event.Name || strings.HasPrefix(dir, prefix) {
+				_ = w.notify.Remove(dir)
+			}
+		}
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !isHiddenPath(relPath, true) {
+			if err := w.watchTree(workDir, event.Name); err != nil {
+				w.notify.Close()
+				w.notify = nil
+				w.snapshot = scanWorkspaceDisk(workDir)
+				w.lastScan = time.Now()
+			}
+		}
+	}
+	if isHiddenPath(relPath, false) {
+		return
+	}
+	w.queue(filepath.ToSlash(relPath))
+}
+
+// queueAll queues every path on disk and in the file map
+func (w *workspaceWatcher) queueAll() {
+	w.mu.Lock()
+	workDir := w.workDir
+	w.mu.Unlock()
+	if workDir == "" || globalWorkspace == nil {
+		return
+	}
+	var paths []string
+	for path := range scanWorkspaceDisk(workDir) {
+		paths = append(paths, path)
+	}
+	globalWorkspace.mu.RLock()
+	for path := range globalWorkspace.files {
+		paths = append(paths, path)
+	}
+	globalWorkspace.mu.RUnlock()
+	w.queue(paths...)
+}
+
+// poll scans the directory and queues the paths that changed since the last scan
+func (w *workspaceWatcher) poll(workDir string, now time.Time) {
+	current := scanWorkspaceDisk(workDir)
+	w.lastScan = now
+	var changed []string
+	for path, entry := range current {
+		if old, ok := w.snapshot[path]; !ok || old != entry {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.snapshot {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	w.snapshot = current
+	w.queue(changed...)
+}
+
+// queue marks paths as changed
+func (w *workspaceWatcher) queue(paths ...string) {
+	if len(paths) == 0 {
+		return
+	}
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		w.firstChange = now
+	}
+	for _, path := range paths {
+		w.pending[path] = struct{}{}
+	}
+	w.lastChange = now
+}
+
+// takePending returns the pending paths, sorted, once the directory stayed
+// unchanged for workspaceWatchDebounce or they waited workspaceWatchMaxWait
+func (w *workspaceWatcher) takePending(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if now.Sub(w.lastChange) < workspaceWatchDebounce && now.Sub(w.firstChange) < workspaceWatchMaxWait {
+		return nil
+	}
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	w.pending = make(map[string]struct{})
+	sort.Strings(paths)
+	return paths
+}
+
+// scanWorkspaceDisk stats every visible entry of the workspace directory
+func scanWorkspaceDisk(workDir string) map[string]workspaceDiskEntry {
+	entries := make(map[string]workspaceDiskEntry)
+	scanWorkspaceTree(workDir, workDir, entries)
+	return entries
+}
+
+// scanWorkspaceTree adds the visible entries below dir to entries
+func scanWorkspaceTree(workDir, dir string, entries map[string]workspaceDiskEntry) {
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == workDir {
+			return nil
+		}
+		relPath, err := filepath.Rel(workDir, path)
+		if err != nil {
+			return nil
+		}
+		if isHiddenPath(relPath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entry := workspaceDiskEntry{isDir: d.IsDir()}
+		if !entry.isDir {
+			entry.size = info.Size()
+			entry.modTime = info.ModTime()
+		}
+		entries[filepath.ToSlash(relPath)] = entry
+		return nil
+	})
+}
+
+// statWorkspaceChanges stats changed paths. It adds the contents of changed
+// directories and, for paths gone from disk, the files the map holds below them.
+func statWorkspaceChanges(workDir string, paths []string) (map[string]workspaceDiskEntry, []string) {
+	disk := make(map[string]workspaceDiskEntry)
+	var missing []string
+	for _, path := range paths {
+		fullPath := filepath.Join(workDir, filepath.FromSlash(path))
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			missing = append(missing, path)
+			continue
+		}
+		if isHiddenPath(filepath.FromSlash(path), info.IsDir()) {
+			continue
+		}
+		if info.IsDir() {
+			disk[path] = workspaceDiskEntry{isDir: true}
+			scanWorkspaceTree(workDir, fullPath, disk)
+			continue
+		}
+		disk[path] = workspaceDiskEntry{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	all := make(map[string]struct{}, len(paths)+len(disk))
+	for _, path := range paths {
+		all[path] = struct{}{}
+	}
+	for path := range disk {
+		all[path] = struct{}{}
+	}
+	if len(missing) > 0 {
+		globalWorkspace.mu.RLock()
+		for name := range globalWorkspace.files {
+			for _, path := range missing {
+				if strings.HasPrefix(name, path+"/") {
+					if _, onDisk := disk[name]; !onDisk {
+						all[name] = struct{}{}
+					}
+				}
+			}
+		}
+		globalWorkspace.mu.RUnlock()
+	}
+	expanded := make([]string, 0, len(all))
+	for path := range all {
+		expanded = append(expanded, path)
+	}
+	sort.Strings(expanded)
+	return disk, expanded
+}
+
+// workspaceDiskRead is what apply read from disk for a changed file before
+// taking the workspace lock
+type workspaceDiskRead struct {
+	// inMap, loaded and modTime describe the file map entry when the read was
+	// planned; the read is stale if it changed meanwhile
+	inMap   bool
+	loaded  bool
+	modTime time.Time
+
+	// fresh is the new entry of a file whose contents are not held in memory
+	fresh    *WorkspaceFile
+	content  string
+	isBinary bool
+	tooLarge bool
+}
+
+// readWorkspaceChanges reads the changed files of disk without holding the
+// workspace lock: the contents of files held in memory, the metadata of the rest
+func readWorkspaceChanges(workDir string, disk map[string]workspaceDiskEntry, paths []string) map[string]*workspaceDiskRead {
+	reads := make(map[string]*workspaceDiskRead)
+	globalWorkspace.mu.RLock()
+	for _, path := range paths {
+		if entry, onDisk := disk[path]; !onDisk || entry.isDir {
+			continue
+		}
+		read := &workspaceDiskRead{}
+		if file, inMap := globalWorkspace.files[path]; inMap {
+			read.inMap = true
+			read.loaded = file.Loaded || file.Modified || file.IsNew
+			read.modTime = file.DiskModTime
+		}
+		reads[path] = read
+	}
+	globalWorkspace.mu.RUnlock()
+
+	for path, read := range reads {
+		fullPath := filepath.Join(workDir, filepath.FromSlash(path))
+		if !read.loaded {
+			info, err := os.Stat(fullPath)
+			if err != nil || info.IsDir() {
+				delete(reads, path)
+				continue
+			}
+			read.fresh = newWorkspaceFileFromDisk(path, fullPath, info)
+			continue
+		}
+		content, isBinary, tooLarge, err := readWorkspaceDiskFile(path, fullPath, disk[path].size)
+		if err != nil {
+			delete(reads, path)
+			continue
+		}
+		read.content, read.isBinary, read.tooLarge = content, isBinary, tooLarge
+	}
+	return reads
+}
+
+// stale reports whether file changed in the map since the read was planned,
+// e.g. because it was saved or loaded meanwhile
+func (r *workspaceDiskRead) stale(file *WorkspaceFile, inMap bool) bool {
+	if inMap != r.inMap {
+		return true
+	}
+	if !inMap {
+		return false
+	}
+	loaded := file.Loaded || file.Modified || file.IsNew
+	return loaded != r.loaded || !file.DiskModTime.Equal(r.modTime)
+}
+
+// apply updates the file map for changed paths. Files with unsaved edits are
+// never overwritten; a differing disk version is reported as a conflict instead.
+// Files are read before the workspace lock is taken; paths whose entry changed
+// in between are queued again.
+func (w *workspaceWatcher) apply(workDir string, disk map[string]workspaceDiskEntry, paths []string) {
+	var change WorkspaceChange
+	var newConflicts []WorkspaceConflict
+	var cleared, stale []string
+	diskUpdates := make(map[string]string)
+
+	reads := readWorkspaceChanges(workDir, disk, paths)
+
+	globalWorkspace.mu.Lock()
+	if globalWorkspace.workDir != workDir {
+		globalWorkspace.mu.Unlock()
+		return
+	}
+	for _, path := range paths {
+		entry, onDisk := disk[path]
+		file, inMap := globalWorkspace.files[path]
+
+		switch {
+		case !onDisk:
+			if !inMap {
+				continue
+			}
+			if file.Modified && !file.IsNew && !file.IsDir {
+				newConflicts = append(newConflicts, WorkspaceConflict{Path: path, Deleted: true, Detected: time.Now()})
+				continue
+			}
+			if file.IsNew {
+				continue
+			}
+			delete(globalWorkspace.files, path)
+			globalWorkspace.cache.remove(path)
+			change.Deleted = append(change.Deleted, path)
+
+		case entry.isDir:
+			if inMap && file.IsDir {
+				continue
+			}
+			if inMap && (file.Modified || file.IsNew) {
+				continue
+			}
+			globalWorkspace.files[path] = &WorkspaceFile{Name: path, IsDir: true}
+			change.Created = append(change.Created, path)
+
+		default:
+			read, ok := reads[path]
+			if !ok {
+				continue
+			}
+			if read.stale(file, inMap) {
+				stale = append(stale, path)
+				continue
+			}
+			if read.fresh != nil {
+				// Contents are not held in memory, so only the metadata changes
+				if inMap && !file.IsDir && read.fresh.Size == file.Size && read.fresh.DiskModTime.Equal(file.DiskModTime) {
+					continue
+				}
+				globalWorkspace.cache.remove(path)
+				globalWorkspace.files[path] = read.fresh
+				if inMap {
+					change.Modified = append(change.Modified, path)
+				} else {
+					change.Created = append(change.Created, path)
+				}
+				continue
+			}
+			content, tooLarge := read.content, read.tooLarge
+			if !file.IsDir && content == file.SavedContent && tooLarge == file.TooLarge && (!tooLarge || entry.size == file.Size) {
+				// Our own save, a touch, or a change that was reverted
+				file.DiskModTime = entry.modTime
+				cleared = append(cleared, path)
+				continue
+			}
+			if file.Modified || file.IsNew {
+				if content == file.Content {
+					// The disk caught up with the unsaved edits
+					file.SavedContent = content
+					file.IsNew = false
+					file.Modified = false
+					file.DiskModTime = entry.modTime
+					cleared = append(cleared, path)
+					continue
+				}
+				newConflicts = append(newConflicts, WorkspaceConflict{Path: path, Detected: time.Now()})
+				diskUpdates[path] = content
+				continue
+			}
+			file.Content = content
+			file.SavedContent = content
+			file.Size = entry.size
+			file.TooLarge = tooLarge
+			file.IsBinary = read.isBinary
+			file.IsDir = false
+			file.DiskModTime = entry.modTime
+			trackFileContentLocked(path, file)
+			change.Modified = append(change.Modified, path)
+		}
+	}
+	if globalWorkspace.activeFile != "" {
+		if _, exists := globalWorkspace.files[globalWorkspace.activeFile]; !exists {
+			globalWorkspace.activeFile = ""
+		}
+	}
+	updateWorkspaceModifiedLocked()
+	globalWorkspace.mu.Unlock()
+
+	w.queue(stale...)
+	w.mu.Lock()
+	for _, path := range cleared {
+		delete(w.conflicts, path)
+		delete(w.diskContent, path)
+	}
+	for path, content := range diskUpdates {
+		w.diskContent[path] = content
+	}
+	for i := range newConflicts {
+		conflict := newConflicts[i]
+		w.conflicts[conflict.Path] = &conflict
+		if conflict.Deleted {
+			delete(w.diskContent, conflict.Path)
+		}
+	}
+	w.mu.Unlock()
+
+	changed := append(append(append([]string{}, change.Created...), change.Modified...), change.Deleted...)
+	if len(changed) > 0 {
+		if w.app.ctx != nil {
+			runtime.EventsEmit(w.app.ctx, "workspace:files-changed", change)
+		}
+		w.app.notifyMCPFilesChanged(changed...)
+	}
+	if w.app.ctx != nil {
+		for _, conflict := range newConflicts {
+			runtime.EventsEmit(w.app.ctx, "workspace:conflict", conflict)
+		}
+	}
+}
+
+// GetWorkspaceConflicts returns the files whose unsaved edits conflict with changes on disk
+func (a *App) GetWorkspaceConflicts() []WorkspaceConflict {
+	conflicts := []WorkspaceConflict{}
+	if a.watcher == nil {
+		return conflicts
+	}
+	a.watcher.mu.Lock()
+	defer a.watcher.mu.Unlock()
+	for _, conflict := range a.watcher.conflicts {
+		conflicts = append(conflicts, *conflict)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return conflicts
+}
+
+// DiffWorkspaceConflict returns a unified diff from the disk version of a conflicting
+// file to the unsaved edits
+func (a *App) DiffWorkspaceConflict(path string) (string, error) {
+	conflict, diskContent, err := a.workspaceConflict(path)
+	if err != nil {
+		return "", err
+	}
+	globalWorkspace.mu.RLock()
+	file, exists := globalWorkspace.files[conflict.Path]
+	var content string
+	if exists {
+		content = file.Content
+	}
+	globalWorkspace.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("file not found: %s", conflict.Path)
+	}
+	if conflict.Deleted {
+		return fmt.Sprintf("%s was deleted on disk; the unsaved edits are kept in the editor\n", conflict.Path), nil
+	}
+	return mcp.UnifiedDiff(conflict.Path, diskContent, content), nil
+}
+
+// ResolveWorkspaceConflict resolves a conflict: "reload" takes the disk version and
+// drops the unsaved edits, "keep" keeps the edits so the next save overwrites the disk
+func (a *App) ResolveWorkspaceConflict(path string, action string) error {
+	conflict, diskContent, err := a.workspaceConflict(path)
+	if err != nil {
+		return err
+	}
+	if action != conflictReload && action != conflictKeep {
+		return fmt.Errorf("unknown conflict resolution: %s", action)
+	}
+
+	globalWorkspace.mu.Lock()
+	file, exists := globalWorkspace.files[conflict.Path]
+	switch {
+	case !exists:
+	case conflict.Deleted && action == conflictReload:
+		delete(globalWorkspace.files, conflict.Path)
+		globalWorkspace.cache.remove(conflict.Path)
+		if globalWorkspace.activeFile == conflict.Path {
+			globalWorkspace.activeFile = ""
+		}
+	case conflict.Deleted:
+		// Saving recreates the file
+		file.IsNew = true
+	case action == conflictReload:
+		file.Content = diskContent
+		file.SavedContent = diskContent
+		file.Size = int64(len(diskContent))
+		file.Modified = false
+		trackFileContentLocked(conflict.Path, file)
+	default:
+		file.SavedContent = diskContent
+		file.Modified = file.Content != diskContent
+	}
+	updateWorkspaceModifiedLocked()
+	globalWorkspace.mu.Unlock()
+
+	a.watcher.mu.Lock()
+	delete(a.watcher.conflicts, conflict.Path)
+	delete(a.watcher.diskContent, conflict.Path)
+	a.watcher.mu.Unlock()
+	a.notifyMCPFilesChanged(conflict.Path)
+	return nil
+}
+
+func (a *App) workspaceConflict(path string) (WorkspaceConflict, string, error) {
+	if globalWorkspace == nil || a.watcher == nil {
+		return WorkspaceConflict{}, "", fmt.Errorf("workspace not initialized")
+	}
+	cleanName, err := cleanRelativePath(path)
+	if err != nil {
+		return WorkspaceConflict{}, "", err
+	}
+	a.watcher.mu.Lock()
+	defer a.watcher.mu.Unlock()
+	conflict, ok := a.watcher.conflicts[cleanName]
+	if !ok {
+		return WorkspaceConflict{}, "", fmt.Errorf("no conflict for %s", cleanName)
+	}
+	return *conflict, a.watcher.diskContent[cleanName], nil
+}
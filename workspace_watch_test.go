@@ -0,0 +1,286 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestWorkspace makes globalWorkspace a workspace in a temporary directory
+// holding files, all loaded, and returns an app watching it
+func useTestWorkspace(t *testing.T, files map[string]string) (*App, string) {
+	t.Helper()
+	dir := t.TempDir()
+	previous := globalWorkspace
+	t.Cleanup(func() { globalWorkspace = previous })
+	globalWorkspace = &Workspace{
+		workDir:     dir,
+		files:       make(map[string]*WorkspaceFile),
+		initialized: true,
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := newWorkspaceFileFromDisk(name, fullPath, info)
+		globalWorkspace.files[name] = file
+		if err := loadFileContentLocked(name, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := &App{}
+	app.watcher = newWorkspaceWatcher(app)
+	app.watcher.workDir = dir
+	return app, dir
+}
+
+// writeDiskFile changes a file behind the workspace's back, moving its
+// modification time so the change is seen even on coarse clocks
+func writeDiskFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	fullPath := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fullPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// applyDiskChanges applies the changes of paths the way the watcher does
+func applyDiskChanges(app *App, dir string, paths ...string) {
+	disk, paths := statWorkspaceChanges(dir, paths)
+	app.watcher.apply(dir, disk, paths)
+}
+
+func TestWatcherReloadsUnmodifiedFile(t *testing.T) {
+	app, dir := useTestWorkspace(t, map[string]string{"notes.txt": "old"})
+	writeDiskFile(t, dir, "notes.txt", "new content")
+	applyDiskChanges(app, dir, "notes.txt")
+
+	file := globalWorkspace.files["notes.txt"]
+	if file.Content != "new content" || file.SavedContent != "new content" || file.Modified {
+		t.Fatalf("file = %q (saved %q, modified %v), want the disk version", file.Content, file.SavedContent, file.Modified)
+	}
+	if conflicts := app.GetWorkspaceConflicts(); len(conflicts) != 0 {
+		t.Fatalf("conflicts = %v, want none", conflicts)
+	}
+}
+
+func TestWatcherIgnoresOwnSave(t *testing.T) {
+	app, dir := useTestWorkspace(t, map[string]string{"notes.txt": "old"})
+	if err := app.UpdateFileContent("notes.txt", "mine"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SaveFile("notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	applyDiskChanges(app, dir, "notes.txt")
+
+	file := globalWorkspace.files["notes.txt"]
+	if file.Content != "mine" || file.Modified {
+		t.Fatalf("file = %q (modified %v), want the saved content", file.Content, file.Modified)
+	}
+	if conflicts := app.GetWorkspaceConflicts(); len(conflicts) != 0 {
+		t.Fatalf("own save reported as conflict: %v", conflicts)
+	}
+}
+
+func TestWatcherConflictResolution(t *testing.T) {
+	for _, tc := range []struct {
+		action       string
+		wantContent  string
+		wantSaved    string
+		wantModified bool
+	}{
+		{conflictReload, "theirs", "theirs", false},
+		{conflictKeep, "mine", "theirs", true},
+	} {
+		t.Run(tc.action, func(t *testing.T) {
+			app, dir := useTestWorkspace(t, map[string]string{"notes.txt": "base\n"})
+			if err := app.UpdateFileContent("notes.txt", "mine"); err != nil {
+				t.Fatal(err)
+			}
+			writeDiskFile(t, dir, "notes.txt", "theirs")
+			applyDiskChanges(app, dir, "notes.txt")
+
+			conflicts := app.GetWorkspaceConflicts()
+			if len(conflicts) != 1 || conflicts[0].Path != "notes.txt" || conflicts[0].Deleted {
+				t.Fatalf("conflicts = %+v, want one for notes.txt", conflicts)
+			}
+			if file := globalWorkspace.files["notes.txt"]; file.Content != "mine" {
+				t.Fatalf("unsaved edits overwritten with %q", file.Content)
+			}
+			diff, err := app.DiffWorkspaceConflict("notes.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(diff, "-theirs") || !strings.Contains(diff, "+mine") {
+				t.Fatalf("diff does not go from disk to edits:\n%s", diff)
+			}
+
+			if err := app.ResolveWorkspaceConflict("notes.txt", tc.action); err != nil {
+				t.Fatal(err)
+			}
+			file := globalWorkspace.files["notes.txt"]
+			if file.Content != tc.wantContent || file.SavedContent != tc.wantSaved || file.Modified != tc.wantModified {
+				t.Fatalf("after %s: content %q, saved %q, modified %v", tc.action, file.Content, file.SavedContent, file.Modified)
+			}
+			if conflicts := app.GetWorkspaceConflicts(); len(conflicts) != 0 {
+				t.Fatalf("conflict kept after %s: %v", tc.action, conflicts)
+			}
+		})
+	}
+}
+
+func TestWatcherDeletedWhileModified(t *testing.T) {
+	for _, action := range []string{conflictReload, conflictKeep} {
+		t.Run(action, func(t *testing.T) {
+			app, dir := useTestWorkspace(t, map[string]string{"notes.txt": "base", "other.txt": "other"})
+			if err := app.UpdateFileContent("notes.txt", "mine"); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"notes.txt", "other.txt"} {
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			applyDiskChanges(app, dir, "notes.txt", "other.txt")
+
+			if _, ok := globalWorkspace.files["other.txt"]; ok {
+				t.Fatal("unmodified file deleted on disk is still in the workspace")
+			}
+			conflicts := app.GetWorkspaceConflicts()
+			if len(conflicts) != 1 || conflicts[0].Path != "notes.txt" || !conflicts[0].Deleted {
+				t.Fatalf("conflicts = %+v, want notes.txt deleted", conflicts)
+			}
+			if _, ok := globalWorkspace.files["notes.txt"]; !ok {
+				t.Fatal("modified file dropped when deleted on disk")
+			}
+
+			if err := app.ResolveWorkspaceConflict("notes.txt", action); err != nil {
+				t.Fatal(err)
+			}
+			file, ok := globalWorkspace.files["notes.txt"]
+			if action == conflictReload && ok {
+				t.Fatal("reload kept a file deleted on disk")
+			}
+			if action == conflictKeep && (!ok || !file.IsNew || file.Content != "mine") {
+				t.Fatalf("keep should leave the edits to recreate the file, got %+v", file)
+			}
+		})
+	}
+}
+
+func TestWatcherAppliesDirectoryChanges(t *testing.T) {
+	app, dir := useTestWorkspace(t, map[string]string{"old/a.txt": "a", "old/sub/b.txt": "b"})
+	globalWorkspace.files["old"] = &WorkspaceFile{Name: "old", IsDir: true}
+	globalWorkspace.files["old/sub"] = &WorkspaceFile{Name: "old/sub", IsDir: true}
+	writeDiskFile(t, dir, "new/c.txt", "c")
+	writeDiskFile(t, dir, ".idensyra/state.json", "{}")
+	if err := os.RemoveAll(filepath.Join(dir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	// A watcher reports only the directories themselves
+	applyDiskChanges(app, dir, "new", "old", ".idensyra")
+
+	for _, name := range []string{"old", "old/a.txt", "old/sub", "old/sub/b.txt"} {
+		if _, ok := globalWorkspace.files[name]; ok {
+			t.Errorf("%s still in the workspace", name)
+		}
+	}
+	for _, name := range []string{"new", "new/c.txt"} {
+		if _, ok := globalWorkspace.files[name]; !ok {
+			t.Errorf("%s missing from the workspace", name)
+		}
+	}
+	if _, ok := globalWorkspace.files[".idensyra/state.json"]; ok {
+		t.Error("hidden directory added to the workspace")
+	}
+}
+
+func TestWatcherDetectsStaleReads(t *testing.T) {
+	_, dir := useTestWorkspace(t, map[string]string{"notes.txt": "old"})
+	globalWorkspace.files["later.txt"] = &WorkspaceFile{Name: "later.txt"}
+	writeDiskFile(t, dir, "notes.txt", "new")
+	writeDiskFile(t, dir, "later.txt", "later")
+	disk, paths := statWorkspaceChanges(dir, []string{"notes.txt", "later.txt"})
+	reads := readWorkspaceChanges(dir, disk, paths)
+
+	notes := globalWorkspace.files["notes.txt"]
+	if read := reads["notes.txt"]; read.stale(notes, true) || read.content != "new" {
+		t.Fatalf("fresh read of notes.txt = %+v", read)
+	}
+	// Saved between reading the disk and taking the lock
+	notes.DiskModTime = notes.DiskModTime.Add(time.Second)
+	if !reads["notes.txt"].stale(notes, true) {
+		t.Error("read not stale after the file was saved")
+	}
+	if !reads["notes.txt"].stale(nil, false) {
+		t.Error("read not stale after the file left the map")
+	}
+	// Loaded between reading its metadata and taking the lock
+	later := globalWorkspace.files["later.txt"]
+	if reads["later.txt"].fresh == nil {
+		t.Fatal("metadata of an unloaded file not read")
+	}
+	later.Loaded = true
+	if !reads["later.txt"].stale(later, true) {
+		t.Error("read not stale after the file was loaded")
+	}
+}
+
+func TestWatcherMaxWait(t *testing.T) {
+	app, _ := useTestWorkspace(t, nil)
+	w := app.watcher
+	start := time.Now()
+	w.queue("a.txt")
+	w.firstChange = start
+	w.lastChange = start
+	if paths := w.takePending(start.Add(workspaceWatchDebounce / 2)); paths != nil {
+		t.Fatalf("applied %v before the directory was quiet", paths)
+	}
+	// A writer keeps changing files, so the directory is never quiet
+	w.lastChange = start.Add(workspaceWatchMaxWait - workspaceWatchDebounce/2)
+	paths := w.takePending(start.Add(workspaceWatchMaxWait))
+	if len(paths) != 1 || paths[0] != "a.txt" {
+		t.Fatalf("takePending after the max wait = %v, want [a.txt]", paths)
+	}
+}
+
+func TestWatcherFollowsDiskEvents(t *testing.T) {
+	app, dir := useTestWorkspace(t, nil)
+	app.watcher = nil
+	app.startWorkspaceWatcher()
+	defer app.stopWorkspaceWatcher()
+
+	loaded := func() bool {
+		globalWorkspace.mu.RLock()
+		defer globalWorkspace.mu.RUnlock()
+		_, ok := globalWorkspace.files["sub/new.txt"]
+		return ok
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !loaded() {
+		if time.Now().After(deadline) {
+			t.Fatal("file created on disk never reached the workspace")
+		}
+		// Writes before the watcher is set up are missed, so keep writing
+		writeDiskFile(t, dir, "sub/new.txt", time.Now().String())
+		time.Sleep(workspaceWatchDebounce)
+	}
+}